@@ -28,6 +28,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/types"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 )
 
 // NewTransactor is a utility method to easily create a transaction signer from
@@ -72,7 +73,15 @@ func NewKeyedTransactor(key *ecdsa.PrivateKey) *TransactOpts {
 			if address != keyAddr {
 				return nil, errors.New("not authorized to sign this account")
 			}
-			signature, err := crypto.Sign(signer.Hash(tx).Bytes(), key)
+			var (
+				signature []byte
+				err       error
+			)
+			if sm2.IsSM2(&key.PublicKey) {
+				signature, err = sm2.SignDigest(signer.Hash(tx).Bytes(), key)
+			} else {
+				signature, err = crypto.Sign(signer.Hash(tx).Bytes(), key)
+			}
 			if err != nil {
 				return nil, err
 			}
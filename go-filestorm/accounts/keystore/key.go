@@ -32,11 +32,16 @@ import (
 	"github.com/filestorm/go-filestorm/accounts"
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/pborman/uuid"
 )
 
 const (
 	version = 3
+
+	// curveSM2 marks key files holding an sm2p256v1 key, files without a
+	// curve hold a secp256k1 key.
+	curveSM2 = "sm2"
 )
 
 type Key struct {
@@ -62,6 +67,7 @@ type plainKeyJSON struct {
 	PrivateKey string `json:"privatekey"`
 	Id         string `json:"id"`
 	Version    int    `json:"version"`
+	Curve      string `json:"curve,omitempty"`
 }
 
 type encryptedKeyJSONV3 struct {
@@ -69,6 +75,7 @@ type encryptedKeyJSONV3 struct {
 	Crypto  CryptoJSON `json:"crypto"`
 	Id      string     `json:"id"`
 	Version int        `json:"version"`
+	Curve   string     `json:"curve,omitempty"`
 }
 
 type encryptedKeyJSONV1 struct {
@@ -97,6 +104,7 @@ func (k *Key) MarshalJSON() (j []byte, err error) {
 		hex.EncodeToString(crypto.FromECDSA(k.PrivateKey)),
 		k.Id.String(),
 		version,
+		keyCurve(k.PrivateKey),
 	}
 	j, err = json.Marshal(jStruct)
 	return j, err
@@ -116,7 +124,11 @@ func (k *Key) UnmarshalJSON(j []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	privkey, err := crypto.HexToECDSA(keyJSON.PrivateKey)
+	b, err := hex.DecodeString(keyJSON.PrivateKey)
+	if err != nil {
+		return err
+	}
+	privkey, err := toECDSA(keyJSON.Curve, b)
 	if err != nil {
 		return err
 	}
@@ -127,11 +139,30 @@ func (k *Key) UnmarshalJSON(j []byte) (err error) {
 	return nil
 }
 
-func newKeyFromECDSA(privateKeyECDSA *ecdsa.PrivateKey) *Key {
+// keyCurve returns the curve name stored in the key file of the given key.
+func keyCurve(priv *ecdsa.PrivateKey) string {
+	if sm2.IsSM2(&priv.PublicKey) {
+		return curveSM2
+	}
+	return ""
+}
+
+// toECDSA creates a private key on the named curve from its raw bytes.
+func toECDSA(curve string, d []byte) (*ecdsa.PrivateKey, error) {
+	switch curve {
+	case "":
+		return crypto.ToECDSA(d)
+	case curveSM2:
+		return sm2.ToECDSA(d)
+	}
+	return nil, fmt.Errorf("key curve not supported: %v", curve)
+}
+
+func newKeyFromECDSA(privateKeyECDSA *ecdsa.PrivateKey, hasher crypto.Hasher) *Key {
 	id := uuid.NewRandom()
 	key := &Key{
 		Id:         id,
-		Address:    crypto.PubkeyToAddress(privateKeyECDSA.PublicKey),
+		Address:    hasher.PubkeyToAddress(privateKeyECDSA.PublicKey),
 		PrivateKey: privateKeyECDSA,
 	}
 	return key
@@ -151,26 +182,38 @@ func NewKeyForDirectICAP(rand io.Reader) *Key {
 	if err != nil {
 		panic("key generation: ecdsa.GenerateKey failed: " + err.Error())
 	}
-	key := newKeyFromECDSA(privateKeyECDSA)
+	key := newKeyFromECDSA(privateKeyECDSA, crypto.Keccak256Hasher)
 	if !strings.HasPrefix(key.Address.Hex(), "0x00") {
 		return NewKeyForDirectICAP(rand)
 	}
 	return key
 }
 
-func newKey(rand io.Reader) (*Key, error) {
+func newKey(rand io.Reader, hasher crypto.Hasher) (*Key, error) {
 	privateKeyECDSA, err := ecdsa.GenerateKey(crypto.S256(), rand)
 	if err != nil {
 		return nil, err
 	}
-	return newKeyFromECDSA(privateKeyECDSA), nil
+	return newKeyFromECDSA(privateKeyECDSA, hasher), nil
+}
+
+func newSM2Key(rand io.Reader, hasher crypto.Hasher) (*Key, error) {
+	privateKeyECDSA, err := sm2.GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyFromECDSA(privateKeyECDSA, hasher), nil
 }
 
-func storeNewKey(ks keyStore, rand io.Reader, auth string) (*Key, accounts.Account, error) {
-	key, err := newKey(rand)
+func storeNewKey(ks keyStore, rand io.Reader, auth string, hasher crypto.Hasher) (*Key, accounts.Account, error) {
+	key, err := newKey(rand, hasher)
 	if err != nil {
 		return nil, accounts.Account{}, err
 	}
+	return storeKey(ks, key, auth)
+}
+
+func storeKey(ks keyStore, key *Key, auth string) (*Key, accounts.Account, error) {
 	a := accounts.Account{
 		Address: key.Address,
 		URL:     accounts.URL{Scheme: KeyStoreScheme, Path: ks.JoinPath(keyFileName(key.Address))},
@@ -179,7 +222,7 @@ func storeNewKey(ks keyStore, rand io.Reader, auth string) (*Key, accounts.Accou
 		zeroKey(key.PrivateKey)
 		return nil, a, err
 	}
-	return key, a, err
+	return key, a, nil
 }
 
 func writeTemporaryKeyFile(file string, content []byte) (string, error) {
@@ -37,6 +37,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/types"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/event"
 )
 
@@ -61,6 +62,7 @@ type KeyStore struct {
 	cache    *accountCache                // In-memory account cache over the filesystem storage
 	changes  chan struct{}                // Channel receiving change notifications from the cache
 	unlocked map[common.Address]*unlocked // Currently unlocked account (decrypted private keys)
+	hasher   crypto.Hasher                // Hash function deriving the addresses of the keys
 
 	wallets     []accounts.Wallet       // Wallet wrappers around the individual key files
 	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
@@ -77,8 +79,24 @@ type unlocked struct {
 
 // NewKeyStore creates a keystore for the given directory.
 func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
+	return NewKeyStoreWithPBKDF2(keydir, scryptN, scryptP, StandardPBKDF2Iterations)
+}
+
+// NewKeyStoreWithPBKDF2 creates a keystore for the given directory, which
+// encrypts version 4 (SM4) key files with pbkdf2C PBKDF2 iterations.
+func NewKeyStoreWithPBKDF2(keydir string, scryptN, scryptP, pbkdf2C int) *KeyStore {
+	return NewKeyStoreWithHasher(keydir, scryptN, scryptP, pbkdf2C, crypto.Keccak256Hasher)
+}
+
+// NewKeyStoreWithHasher creates a keystore for the given directory, deriving
+// the addresses of its keys with the hash function of the chain they are used
+// for.
+func NewKeyStoreWithHasher(keydir string, scryptN, scryptP, pbkdf2C int, hasher crypto.Hasher) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
-	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP, false}}
+	ks := &KeyStore{
+		storage: &keyStorePassphrase{keydir, scryptN, scryptP, pbkdf2C, CipherAES, hasher, false},
+		hasher:  hasher,
+	}
 	ks.init(keydir)
 	return ks
 }
@@ -266,7 +284,7 @@ func (ks *KeyStore) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
 		return nil, ErrLocked
 	}
 	// Sign the hash using plain ECDSA operations
-	return crypto.Sign(hash, unlockedKey.PrivateKey)
+	return signHash(hash, unlockedKey.PrivateKey)
 }
 
 // SignTx signs the given transaction with the requested account.
@@ -279,11 +297,29 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	if !found {
 		return nil, ErrLocked
 	}
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), unlockedKey.PrivateKey)
+	return types.SignTx(tx, txSigner(unlockedKey.PrivateKey, chainID, ks.hasher), unlockedKey.PrivateKey)
+}
+
+// signHash signs the hash with the key's curve, SM2 keys sign the hash itself
+// as the digest.
+func signHash(hash []byte, prv *ecdsa.PrivateKey) ([]byte, error) {
+	if sm2.IsSM2(&prv.PublicKey) {
+		return sm2.SignDigest(hash, prv)
 	}
-	return types.SignTx(tx, types.HomesteadSigner{}, unlockedKey.PrivateKey)
+	return crypto.Sign(hash, prv)
+}
+
+// txSigner returns the signer for the key's curve and the chain's hash
+// function. Depending on the presence of the chain ID, secp256k1 keys sign
+// with EIP155 or homestead.
+func txSigner(prv *ecdsa.PrivateKey, chainID *big.Int, hasher crypto.Hasher) types.Signer {
+	switch {
+	case sm2.IsSM2(&prv.PublicKey):
+		return types.NewSM2SignerWithHasher(chainID, hasher)
+	case chainID != nil:
+		return types.NewEIP155SignerWithHasher(chainID, hasher)
+	}
+	return types.NewHomesteadSignerWithHasher(hasher)
 }
 
 // SignHashWithPassphrase signs hash if the private key matching the given address
@@ -295,7 +331,7 @@ func (ks *KeyStore) SignHashWithPassphrase(a accounts.Account, passphrase string
 		return nil, err
 	}
 	defer zeroKey(key.PrivateKey)
-	return crypto.Sign(hash, key.PrivateKey)
+	return signHash(hash, key.PrivateKey)
 }
 
 // SignTxWithPassphrase signs the transaction if the private key matching the
@@ -307,11 +343,7 @@ func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string,
 	}
 	defer zeroKey(key.PrivateKey)
 
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), key.PrivateKey)
-	}
-	return types.SignTx(tx, types.HomesteadSigner{}, key.PrivateKey)
+	return types.SignTx(tx, txSigner(key.PrivateKey, chainID, ks.hasher), key.PrivateKey)
 }
 
 // Unlock unlocks the given account indefinitely.
@@ -408,7 +440,7 @@ func (ks *KeyStore) expire(addr common.Address, u *unlocked, timeout time.Durati
 // NewAccount generates a new key and stores it into the key directory,
 // encrypting it with the passphrase.
 func (ks *KeyStore) NewAccount(passphrase string) (accounts.Account, error) {
-	_, account, err := storeNewKey(ks.storage, crand.Reader, passphrase)
+	_, account, err := storeNewKey(ks.storage, crand.Reader, passphrase, ks.hasher)
 	if err != nil {
 		return accounts.Account{}, err
 	}
@@ -419,6 +451,23 @@ func (ks *KeyStore) NewAccount(passphrase string) (accounts.Account, error) {
 	return account, nil
 }
 
+// NewSM2Account generates a new SM2 key and stores it into the key directory,
+// encrypting it with the passphrase. Its transactions can only be signed for
+// chains using the SM2 signature scheme.
+func (ks *KeyStore) NewSM2Account(passphrase string) (accounts.Account, error) {
+	key, err := newSM2Key(crand.Reader, ks.hasher)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	_, account, err := storeKey(ks.storage, key, passphrase)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	ks.cache.add(account)
+	ks.refreshWallets()
+	return account, nil
+}
+
 // Export exports as a JSON key, encrypted with newPassphrase.
 func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string) (keyJSON []byte, err error) {
 	_, key, err := ks.getDecryptedKey(a, passphrase)
@@ -436,7 +485,7 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 
 // Import stores the given encrypted JSON key into the key directory.
 func (ks *KeyStore) Import(keyJSON []byte, passphrase, newPassphrase string) (accounts.Account, error) {
-	key, err := DecryptKey(keyJSON, passphrase)
+	key, err := DecryptKeyWithHasher(keyJSON, passphrase, ks.hasher)
 	if key != nil && key.PrivateKey != nil {
 		defer zeroKey(key.PrivateKey)
 	}
@@ -448,7 +497,7 @@ func (ks *KeyStore) Import(keyJSON []byte, passphrase, newPassphrase string) (ac
 
 // ImportECDSA stores the given key into the key directory, encrypting it with the passphrase.
 func (ks *KeyStore) ImportECDSA(priv *ecdsa.PrivateKey, passphrase string) (accounts.Account, error) {
-	key := newKeyFromECDSA(priv)
+	key := newKeyFromECDSA(priv, ks.hasher)
 	if ks.cache.hasAddress(key.Address) {
 		return accounts.Account{}, fmt.Errorf("account already exists")
 	}
@@ -474,10 +523,26 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
+// Convert re-encrypts the key file of an existing account with the same
+// passphrase in the format of the given cipher.
+func (ks *KeyStore) Convert(a accounts.Account, passphrase, cipher string) error {
+	storage, ok := ks.storage.(*keyStorePassphrase)
+	if !ok {
+		return errors.New("key store is not encrypted")
+	}
+	a, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return err
+	}
+	converted := *storage
+	converted.cipher = cipher
+	return converted.StoreKey(a.URL.Path, key, passphrase)
+}
+
 // ImportPreSaleKey decrypts the given Filestorm presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
-	a, _, err := importPreSaleKey(ks.storage, keyJSON, passphrase)
+	a, _, err := importPreSaleKey(ks.storage, keyJSON, passphrase, ks.hasher)
 	if err != nil {
 		return a, err
 	}
@@ -18,6 +18,7 @@ package keystore
 
 import (
 	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"os"
 	"runtime"
@@ -28,6 +29,9 @@ import (
 
 	"github.com/filestorm/go-filestorm/accounts"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/event"
 )
 
@@ -85,6 +89,86 @@ func TestSign(t *testing.T) {
 	}
 }
 
+func TestSM2Account(t *testing.T) {
+	for _, encrypted := range []bool{false, true} {
+		dir, ks := tmpKeyStore(t, encrypted)
+		defer os.RemoveAll(dir)
+
+		pass := "foo"
+		a, err := ks.NewSM2Account(pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, key, err := ks.getDecryptedKey(a, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.IsSM2(&key.PrivateKey.PublicKey) {
+			t.Fatal("decrypted key is not an SM2 key")
+		}
+		if err := ks.Unlock(a, pass); err != nil {
+			t.Fatal(err)
+		}
+		sig, err := ks.SignHash(a, testSigData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := sm2.RecoverPubkey(testSigData, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr := crypto.PubkeyToAddress(*pub); addr != a.Address {
+			t.Errorf("recovered address mismatch: have %x, want %x", addr, a.Address)
+		}
+
+		signer := types.NewSM2Signer(big.NewInt(18))
+		tx, err := ks.SignTx(a, types.NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil), big.NewInt(18))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from, err := types.Sender(signer, tx); err != nil || from != a.Address {
+			t.Errorf("sender mismatch: have %x (%v), want %x", from, err, a.Address)
+		}
+	}
+}
+
+// Tests that a keystore for an SM3 chain derives the SM3 addresses of its keys,
+// signs transactions recoverable on that chain and tells apart keys created
+// for the other hash function.
+func TestSM3KeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fst-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ks := NewKeyStoreWithHasher(dir, veryLightScryptN, veryLightScryptP, veryLightPBKDF2C, crypto.SM3Hasher)
+
+	a, err := ks.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := ks.getDecryptedKey(a, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.SM3Hasher.PubkeyToAddress(key.PrivateKey.PublicKey); addr != a.Address {
+		t.Fatalf("account address %x, want SM3 address %x", a.Address, addr)
+	}
+	chainID := big.NewInt(18)
+	tx, err := ks.SignTxWithPassphrase(a, "foo", types.NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil), chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := types.Sender(types.NewEIP155SignerWithHasher(chainID, crypto.SM3Hasher), tx); err != nil || from != a.Address {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, a.Address)
+	}
+
+	keccak := NewKeyStoreWithPBKDF2(dir, veryLightScryptN, veryLightScryptP, veryLightPBKDF2C)
+	if err := keccak.Unlock(a, "foo"); err == nil || !strings.Contains(err.Error(), "another hash function") {
+		t.Fatalf("unlock of an SM3 account with keccak256 addresses: %v", err)
+	}
+}
+
 func TestSignWithPassphrase(t *testing.T) {
 	dir, ks := tmpKeyStore(t, true)
 	defer os.RemoveAll(dir)
@@ -381,7 +465,9 @@ func tmpKeyStore(t *testing.T, encrypted bool) (string, *KeyStore) {
 	}
 	newKs := NewPlaintextKeyStore
 	if encrypted {
-		newKs = func(kd string) *KeyStore { return NewKeyStore(kd, veryLightScryptN, veryLightScryptP) }
+		newKs = func(kd string) *KeyStore {
+			return NewKeyStoreWithPBKDF2(kd, veryLightScryptN, veryLightScryptP, veryLightPBKDF2C)
+		}
 	}
 	return d, newKs(d)
 }
@@ -28,6 +28,7 @@ package keystore
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -42,6 +43,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/math"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 	"github.com/pborman/uuid"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
@@ -74,6 +76,9 @@ type keyStorePassphrase struct {
 	keysDirPath string
 	scryptN     int
 	scryptP     int
+	pbkdf2C     int           // PBKDF2 iterations of version 4 files
+	cipher      string        // CipherAES (or empty) or CipherSM4
+	hasher      crypto.Hasher // Hash function deriving the addresses of the keys
 	// skipKeyFileVerification disables the security-feature which does
 	// reads and decrypts any newly created keyfiles. This should be 'false' in all
 	// cases except tests -- setting this to 'true' is not recommended.
@@ -86,12 +91,21 @@ func (ks keyStorePassphrase) GetKey(addr common.Address, filename, auth string)
 	if err != nil {
 		return nil, err
 	}
-	key, err := DecryptKey(keyjson, auth)
+	key, err := DecryptKeyWithHasher(keyjson, auth, ks.hasher)
 	if err != nil {
 		return nil, err
 	}
 	// Make sure we're really operating on the requested key (no swap attacks)
 	if key.Address != addr {
+		// addresses depend on the chain hash function, tell apart a key file
+		// created for a chain with the other one from a swapped key
+		other := crypto.SM3Hasher
+		if ks.hasher.IsSM3() {
+			other = crypto.Keccak256Hasher
+		}
+		if other.PubkeyToAddress(key.PrivateKey.PublicKey) == addr {
+			return nil, fmt.Errorf("key content mismatch: account %x was created for a chain with another hash function, import the key again on this chain", addr)
+		}
 		return nil, fmt.Errorf("key content mismatch: have account %x, want %x", key.Address, addr)
 	}
 	return key, nil
@@ -99,12 +113,30 @@ func (ks keyStorePassphrase) GetKey(addr common.Address, filename, auth string)
 
 // StoreKey generates a key, encrypts with 'auth' and stores in the given directory
 func StoreKey(dir, auth string, scryptN, scryptP int) (accounts.Account, error) {
-	_, a, err := storeNewKey(&keyStorePassphrase{dir, scryptN, scryptP, false}, rand.Reader, auth)
+	return StoreKeyWithCipher(dir, auth, scryptN, scryptP, StandardPBKDF2Iterations, CipherAES, crypto.Keccak256Hasher)
+}
+
+// StoreKeyWithCipher generates a key, encrypts with 'auth' and stores it in the
+// given directory in the format of the cipher. AES files use the scrypt
+// parameters, SM4 files pbkdf2C PBKDF2 iterations. The address of the key is
+// derived with the hash function of the chain it is created for.
+func StoreKeyWithCipher(dir, auth string, scryptN, scryptP, pbkdf2C int, cipher string, hasher crypto.Hasher) (accounts.Account, error) {
+	_, a, err := storeNewKey(&keyStorePassphrase{dir, scryptN, scryptP, pbkdf2C, cipher, hasher, false}, rand.Reader, auth, hasher)
 	return a, err
 }
 
+func (ks keyStorePassphrase) encryptKey(key *Key, auth string) ([]byte, error) {
+	switch ks.cipher {
+	case "", CipherAES:
+		return EncryptKey(key, auth, ks.scryptN, ks.scryptP)
+	case CipherSM4:
+		return EncryptKeyV4(key, auth, ks.pbkdf2C)
+	}
+	return nil, fmt.Errorf("cipher not supported: %v", ks.cipher)
+}
+
 func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) error {
-	keyjson, err := EncryptKey(key, auth, ks.scryptN, ks.scryptP)
+	keyjson, err := ks.encryptKey(key, auth)
 	if err != nil {
 		return err
 	}
@@ -194,12 +226,20 @@ func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
 		cryptoStruct,
 		key.Id.String(),
 		version,
+		keyCurve(key.PrivateKey),
 	}
 	return json.Marshal(encryptedKeyJSONV3)
 }
 
 // DecryptKey decrypts a key from a json blob, returning the private key itself.
+// The address of the key is derived with Keccak256.
 func DecryptKey(keyjson []byte, auth string) (*Key, error) {
+	return DecryptKeyWithHasher(keyjson, auth, crypto.Keccak256Hasher)
+}
+
+// DecryptKeyWithHasher decrypts a key from a json blob, deriving its address
+// with the hash function of the chain the key is used for.
+func DecryptKeyWithHasher(keyjson []byte, auth string, hasher crypto.Hasher) (*Key, error) {
 	// Parse the json into a simple map to fetch the key version
 	m := make(map[string]interface{})
 	if err := json.Unmarshal(keyjson, &m); err != nil {
@@ -208,6 +248,7 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 	// Depending on the version try to parse one way or another
 	var (
 		keyBytes, keyId []byte
+		curve           string
 		err             error
 	)
 	if version, ok := m["version"].(string); ok && version == "1" {
@@ -216,22 +257,35 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 			return nil, err
 		}
 		keyBytes, keyId, err = decryptKeyV1(k, auth)
+	} else if version, ok := m["version"].(float64); ok && version == versionV4 {
+		k := new(encryptedKeyJSONV4)
+		if err := json.Unmarshal(keyjson, k); err != nil {
+			return nil, err
+		}
+		keyBytes, keyId, err = decryptKeyV4(k, auth)
+		curve = k.Curve
 	} else {
 		k := new(encryptedKeyJSONV3)
 		if err := json.Unmarshal(keyjson, k); err != nil {
 			return nil, err
 		}
 		keyBytes, keyId, err = decryptKeyV3(k, auth)
+		curve = k.Curve
 	}
 	// Handle any decryption errors and return the key
 	if err != nil {
 		return nil, err
 	}
-	key := crypto.ToECDSAUnsafe(keyBytes)
+	var key *ecdsa.PrivateKey
+	if curve == "" {
+		key = crypto.ToECDSAUnsafe(keyBytes)
+	} else if key, err = toECDSA(curve, keyBytes); err != nil {
+		return nil, err
+	}
 
 	return &Key{
 		Id:         uuid.UUID(keyId),
-		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		Address:    hasher.PubkeyToAddress(key.PublicKey),
 		PrivateKey: key,
 	}, nil
 }
@@ -335,11 +389,13 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 	} else if cryptoJSON.KDF == "pbkdf2" {
 		c := ensureInt(cryptoJSON.KDFParams["c"])
 		prf := cryptoJSON.KDFParams["prf"].(string)
-		if prf != "hmac-sha256" {
-			return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", prf)
+		switch prf {
+		case "hmac-sha256":
+			return pbkdf2.Key(authArray, salt, c, dkLen, sha256.New), nil
+		case pbkdf2PRFSM3:
+			return pbkdf2.Key(authArray, salt, c, dkLen, sm3.New), nil
 		}
-		key := pbkdf2.Key(authArray, salt, c, dkLen, sha256.New)
-		return key, nil
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", prf)
 	}
 
 	return nil, fmt.Errorf("unsupported KDF: %s", cryptoJSON.KDF)
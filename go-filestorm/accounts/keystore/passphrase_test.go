@@ -26,6 +26,7 @@ import (
 const (
 	veryLightScryptN = 2
 	veryLightScryptP = 1
+	veryLightPBKDF2C = 3
 )
 
 // Tests that a json key file can be decrypted and encrypted in multiple rounds.
@@ -31,13 +31,15 @@ import (
 	"golang.org/x/crypto/pbkdf2"
 )
 
-// creates a Key and stores that in the given KeyStore by decrypting a presale key JSON
-func importPreSaleKey(keyStore keyStore, keyJSON []byte, password string) (accounts.Account, *Key, error) {
+// creates a Key and stores that in the given KeyStore by decrypting a presale key JSON,
+// the account address is derived with the given hash function
+func importPreSaleKey(keyStore keyStore, keyJSON []byte, password string, hasher crypto.Hasher) (accounts.Account, *Key, error) {
 	key, err := decryptPreSaleKey(keyJSON, password)
 	if err != nil {
 		return accounts.Account{}, nil, err
 	}
 	key.Id = uuid.NewRandom()
+	key.Address = hasher.PubkeyToAddress(key.PrivateKey.PublicKey)
 	a := accounts.Account{
 		Address: key.Address,
 		URL: accounts.URL{
@@ -0,0 +1,204 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/filestorm/go-filestorm/common/math"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+	"github.com/filestorm/go-filestorm/crypto/sm4"
+	"github.com/pborman/uuid"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Key file ciphers. Version 3 files are encrypted with scrypt, AES-128-CTR and
+// a Keccak256 MAC, version 4 files with the national standard algorithms:
+// PBKDF2-HMAC-SM3, SM4-128-CTR (or CBC) and an SM3 MAC.
+const (
+	CipherAES = "aes"
+	CipherSM4 = "sm4"
+)
+
+const (
+	// StandardPBKDF2Iterations is the iteration count of PBKDF2-HMAC-SM3 in
+	// version 4 files, taking approximately 1s CPU time on a modern processor.
+	StandardPBKDF2Iterations = 1 << 19
+
+	// LightPBKDF2Iterations is the iteration count of PBKDF2-HMAC-SM3 in
+	// version 4 files, taking approximately 100ms CPU time on a modern processor.
+	LightPBKDF2Iterations = 1 << 15
+)
+
+const (
+	versionV4 = 4
+
+	sm4CTR       = "sm4-128-ctr"
+	sm4CBC       = "sm4-128-cbc"
+	pbkdf2KDF    = "pbkdf2"
+	pbkdf2PRFSM3 = "hmac-sm3"
+	pbkdf2DKLen  = 32
+)
+
+type encryptedKeyJSONV4 struct {
+	Address string     `json:"address"`
+	Crypto  CryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+	Curve   string     `json:"curve,omitempty"`
+}
+
+// EncryptDataV4 encrypts the data given as 'data' with the password 'auth'
+// using SM4-128-CTR, deriving the key with the given number of PBKDF2-HMAC-SM3
+// iterations.
+func EncryptDataV4(data, auth []byte, iterations int) (CryptoJSON, error) {
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	derivedKey := pbkdf2.Key(auth, salt, iterations, pbkdf2DKLen, sm3.New)
+
+	iv := make([]byte, sm4.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	cipherText, err := sm4CTRXOR(derivedKey[:16], data, iv)
+	if err != nil {
+		return CryptoJSON{}, err
+	}
+	mac := sm3.Sum(append(append([]byte{}, derivedKey[16:32]...), cipherText...))
+
+	return CryptoJSON{
+		Cipher:       sm4CTR,
+		CipherText:   hex.EncodeToString(cipherText),
+		CipherParams: cipherparamsJSON{IV: hex.EncodeToString(iv)},
+		KDF:          pbkdf2KDF,
+		KDFParams: map[string]interface{}{
+			"c":     iterations,
+			"prf":   pbkdf2PRFSM3,
+			"dklen": pbkdf2DKLen,
+			"salt":  hex.EncodeToString(salt),
+		},
+		MAC: hex.EncodeToString(mac[:]),
+	}, nil
+}
+
+// EncryptKeyV4 encrypts a key into a version 4 json blob, using the given
+// number of PBKDF2 iterations.
+func EncryptKeyV4(key *Key, auth string, iterations int) ([]byte, error) {
+	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
+	cryptoStruct, err := EncryptDataV4(keyBytes, []byte(auth), iterations)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedKeyJSONV4{
+		hex.EncodeToString(key.Address[:]),
+		cryptoStruct,
+		key.Id.String(),
+		versionV4,
+		keyCurve(key.PrivateKey),
+	})
+}
+
+// DecryptDataV4 decrypts data encrypted by EncryptDataV4, or with SM4-128-CBC
+// and PKCS#7 padding.
+func DecryptDataV4(cryptoJson CryptoJSON, auth string) ([]byte, error) {
+	if cryptoJson.Cipher != sm4CTR && cryptoJson.Cipher != sm4CBC {
+		return nil, fmt.Errorf("cipher not supported: %v", cryptoJson.Cipher)
+	}
+	if cryptoJson.KDF != pbkdf2KDF || cryptoJson.KDFParams["prf"] != pbkdf2PRFSM3 {
+		return nil, fmt.Errorf("unsupported KDF: %s %v", cryptoJson.KDF, cryptoJson.KDFParams["prf"])
+	}
+	mac, err := hex.DecodeString(cryptoJson.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(cryptoJson.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(cryptoJson.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	derivedKey, err := getKDFKey(cryptoJson, auth)
+	if err != nil {
+		return nil, err
+	}
+	if len(derivedKey) < 32 {
+		return nil, fmt.Errorf("derived key too short: %d", len(derivedKey))
+	}
+
+	calculatedMAC := sm3.Sum(append(append([]byte{}, derivedKey[16:32]...), cipherText...))
+	if !bytes.Equal(calculatedMAC[:], mac) {
+		return nil, ErrDecrypt
+	}
+	if cryptoJson.Cipher == sm4CBC {
+		return sm4CBCDecrypt(derivedKey[:16], cipherText, iv)
+	}
+	return sm4CTRXOR(derivedKey[:16], cipherText, iv)
+}
+
+func decryptKeyV4(keyProtected *encryptedKeyJSONV4, auth string) (keyBytes []byte, keyId []byte, err error) {
+	if keyProtected.Version != versionV4 {
+		return nil, nil, fmt.Errorf("version not supported: %v", keyProtected.Version)
+	}
+	keyId = uuid.Parse(keyProtected.Id)
+	plainText, err := DecryptDataV4(keyProtected.Crypto, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plainText, keyId, err
+}
+
+func sm4CTRXOR(key, inText, iv []byte) ([]byte, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != sm4.BlockSize {
+		return nil, fmt.Errorf("invalid iv length: %d", len(iv))
+	}
+	stream := cipher.NewCTR(block, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
+func sm4CBCDecrypt(key, cipherText, iv []byte) ([]byte, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != sm4.BlockSize || len(cipherText)%sm4.BlockSize != 0 {
+		return nil, ErrDecrypt
+	}
+	decrypter := cipher.NewCBCDecrypter(block, iv)
+	paddedPlaintext := make([]byte, len(cipherText))
+	decrypter.CryptBlocks(paddedPlaintext, cipherText)
+	plaintext := pkcs7Unpad(paddedPlaintext)
+	if plaintext == nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+	"github.com/filestorm/go-filestorm/crypto/sm4"
+	"github.com/pborman/uuid"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Tests that v3 and v4 files can be converted into each other, for secp256k1
+// and SM2 keys.
+func TestKeyEncryptDecryptV4(t *testing.T) {
+	for _, generate := range []func() (*Key, error){
+		func() (*Key, error) { return newKey(rand.Reader, crypto.Keccak256Hasher) },
+		func() (*Key, error) { return newSM2Key(rand.Reader, crypto.Keccak256Hasher) },
+	} {
+		key, err := generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyjson, err := EncryptKeyV4(key, "foo", veryLightPBKDF2C)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed encryptedKeyJSONV4
+		if err := json.Unmarshal(keyjson, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Version != versionV4 || parsed.Crypto.Cipher != sm4CTR || parsed.Crypto.KDFParams["prf"] != pbkdf2PRFSM3 {
+			t.Fatalf("unexpected v4 key file: %s", keyjson)
+		}
+		if _, err := DecryptKey(keyjson, "bar"); err != ErrDecrypt {
+			t.Errorf("expected %v for bad password, got %v", ErrDecrypt, err)
+		}
+		decrypted, err := DecryptKey(keyjson, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted.Address != key.Address || decrypted.PrivateKey.D.Cmp(key.PrivateKey.D) != 0 || decrypted.Id.String() != key.Id.String() {
+			t.Fatalf("decrypted key mismatch: have %x, want %x", decrypted.Address, key.Address)
+		}
+		if sm2.IsSM2(&decrypted.PrivateKey.PublicKey) != sm2.IsSM2(&key.PrivateKey.PublicKey) {
+			t.Fatal("decrypted key curve mismatch")
+		}
+		// Back to version 3
+		keyjson, err = EncryptKey(decrypted, "foo", veryLightScryptN, veryLightScryptP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted, err = DecryptKey(keyjson, "foo"); err != nil || decrypted.Address != key.Address {
+			t.Fatalf("v3 round trip failed: %v", err)
+		}
+	}
+}
+
+// Tests that v4 files encrypted with SM4-CBC can be decrypted.
+func TestDecryptV4CBC(t *testing.T) {
+	key, _ := newKey(rand.Reader, crypto.Keccak256Hasher)
+	keyBytes := key.PrivateKey.D.Bytes()
+	salt, iv := make([]byte, 32), make([]byte, sm4.BlockSize)
+	rand.Read(salt)
+	rand.Read(iv)
+
+	derivedKey := pbkdf2.Key([]byte("foo"), salt, 2, 32, sm3.New)
+	padding := sm4.BlockSize - len(keyBytes)%sm4.BlockSize
+	plainText := append(keyBytes, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := sm4.NewCipher(derivedKey[:16])
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(cipherText, plainText)
+	mac := sm3.Sum(append(derivedKey[16:32:32], cipherText...))
+
+	keyjson, _ := json.Marshal(encryptedKeyJSONV4{
+		Address: hex.EncodeToString(key.Address[:]),
+		Crypto: CryptoJSON{
+			Cipher:       sm4CBC,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherparamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          pbkdf2KDF,
+			KDFParams:    map[string]interface{}{"c": 2, "prf": pbkdf2PRFSM3, "dklen": 32, "salt": hex.EncodeToString(salt)},
+			MAC:          hex.EncodeToString(mac[:]),
+		},
+		Id:      uuid.NewRandom().String(),
+		Version: versionV4,
+	})
+	decrypted, err := DecryptKey(keyjson, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Address != key.Address {
+		t.Errorf("address mismatch: have %x, want %x", decrypted.Address, key.Address)
+	}
+}
+
+// Tests that accounts can be converted between the key file formats and stay
+// usable by the key store.
+func TestKeyStoreConvert(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	a, err := ks.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVersion := func(path string, want int) {
+		t.Helper()
+		keyjson, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m struct {
+			Version int
+			Crypto  CryptoJSON
+		}
+		if err := json.Unmarshal(keyjson, &m); err != nil || m.Version != want {
+			t.Fatalf("key file version: have %d (%v), want %d", m.Version, err, want)
+		}
+		// version 4 files use the PBKDF2 iterations, not the scrypt N
+		if c := m.Crypto.KDFParams["c"]; want == versionV4 && ensureInt(c) != veryLightPBKDF2C {
+			t.Fatalf("PBKDF2 iterations: have %v, want %d", c, veryLightPBKDF2C)
+		}
+	}
+	checkVersion(a.URL.Path, version)
+	if err := ks.Convert(a, "foo", CipherSM4); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(a.URL.Path, versionV4)
+	if err := ks.Unlock(a, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Convert(a, "foo", CipherAES); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(a.URL.Path, version)
+	if err := ks.Convert(a, "bar", CipherSM4); err != ErrDecrypt {
+		t.Errorf("expected %v for bad password, got %v", ErrDecrypt, err)
+	}
+	if err := ks.Convert(a, "foo", "des"); err == nil {
+		t.Error("expected an error for an unknown cipher")
+	}
+	if _, _, err := ks.getDecryptedKey(a, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// New accounts in the SM4 format
+	b, err := StoreKeyWithCipher(dir, "foo", veryLightScryptN, veryLightScryptP, veryLightPBKDF2C, CipherSM4, crypto.Keccak256Hasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(b.URL.Path, versionV4)
+}
@@ -19,13 +19,18 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 
 	"github.com/filestorm/go-filestorm/accounts"
 	"github.com/filestorm/go-filestorm/accounts/keystore"
 	"github.com/filestorm/go-filestorm/cmd/utils"
+	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/console"
+	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/node"
+	"github.com/filestorm/go-filestorm/params"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -54,6 +59,7 @@ passwordfile as argument containing the wallet password in plaintext.`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.AddressHashFlag,
 				},
 				Description: `
 	storm wallet [options] /path/to/my/presale.wallet
@@ -110,6 +116,8 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.KeyCipherFlag,
+					utils.AddressHashFlag,
 				},
 				Description: `
     storm account new
@@ -117,6 +125,13 @@ Print a short summary of all accounts`,
 Creates a new account and prints the address.
 
 The account is saved in encrypted format, you are prompted for a password.
+With --cipher sm4 the key file is encrypted with the national standard
+algorithms (SM4 and SM3) instead of AES and scrypt, deriving the key with
+524288 PBKDF2-HMAC-SM3 iterations (32768 with --lightkdf).
+
+The address is derived with the hash function of the chain initialised in the
+data directory (keccak256 or sm3), run storm init first or give --addresshash
+when the account is created before the chain.
 
 You must remember this password to unlock your account in the future.
 
@@ -135,6 +150,7 @@ password to file or expose in any other way.
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
 					utils.LightKDFFlag,
+					utils.AddressHashFlag,
 				},
 				Description: `
     storm account update <address>
@@ -153,6 +169,28 @@ For non-interactive use the password can be specified with the --password flag:
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "convert",
+				Usage:     "Convert the key file of an existing account to another cipher",
+				Action:    utils.MigrateFlags(accountConvert),
+				ArgsUsage: "<address>",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					utils.KeyCipherFlag,
+					utils.AddressHashFlag,
+				},
+				Description: `
+    storm account convert --cipher sm4 <address>
+
+Re-encrypts the key file of an existing account with the same password in the
+format of the given cipher: "sm4" for SM4 and SM3 (key file version 4) or "aes"
+for AES and scrypt (key file version 3). Both formats can be used by the node.
+You are prompted for the password of the account.
 `,
 			},
 			{
@@ -164,6 +202,7 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.AddressHashFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -299,6 +338,7 @@ func accountCreate(ctx *cli.Context) error {
 		}
 	}
 	utils.SetNodeConfig(ctx, &cfg.Node)
+	setAddressHash(ctx, &cfg.Node)
 	scryptN, scryptP, keydir, err := cfg.Node.AccountConfig()
 
 	if err != nil {
@@ -307,7 +347,7 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	account, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+	account, err := keystore.StoreKeyWithCipher(keydir, password, scryptN, scryptP, cfg.Node.PBKDF2Iterations(), keyCipher(ctx), cfg.Node.AddressHasher)
 
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
@@ -341,6 +381,77 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountConvert re-encrypts the key files of accounts in the format of the
+// --cipher flag.
+func accountConvert(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("No accounts specified to convert")
+	}
+	cipher := keyCipher(ctx)
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	passwords := utils.MakePasswordList(ctx)
+	for i, addr := range ctx.Args() {
+		account, password := unlockAccount(ks, addr, i, passwords)
+		if err := ks.Convert(account, password, cipher); err != nil {
+			utils.Fatalf("Could not convert the account: %v", err)
+		}
+		fmt.Printf("Converted {%x} to the %s key file format\n", account.Address, cipher)
+	}
+	return nil
+}
+
+// keyCipher returns the key file cipher selected with the --cipher flag.
+func keyCipher(ctx *cli.Context) string {
+	cipher := ctx.String(utils.KeyCipherFlag.Name)
+	if cipher != keystore.CipherAES && cipher != keystore.CipherSM4 {
+		utils.Fatalf("Unsupported key file cipher %q", cipher)
+	}
+	return cipher
+}
+
+// setAddressHash selects the hash function the keystore derives account
+// addresses with: the one of the --addresshash flag, otherwise the one of the
+// chain initialised in the data directory. Accounts created with another hash
+// function than the chain would not match the sender of their transactions.
+func setAddressHash(ctx *cli.Context, cfg *node.Config) {
+	hashFunction := ctx.String(utils.AddressHashFlag.Name)
+	if hashFunction == "" {
+		hashFunction = storedHashFunction(cfg)
+	}
+	switch hashFunction {
+	case "", params.HashFunctionKeccak256:
+		cfg.AddressHasher = crypto.Keccak256Hasher
+	case params.HashFunctionSM3:
+		cfg.AddressHasher = crypto.SM3Hasher
+	default:
+		utils.Fatalf("Unsupported address hash function %q", hashFunction)
+	}
+}
+
+// storedHashFunction returns the hash function of the chain initialised in the
+// data directory, "" if there is none.
+func storedHashFunction(cfg *node.Config) string {
+	for _, name := range []string{"chaindata", "lightchaindata"} {
+		path := cfg.ResolvePath(name)
+		if path == "" || !common.FileExist(path) {
+			continue
+		}
+		db, err := rawdb.NewLevelDBDatabaseWithFreezer(path, 0, 0, filepath.Join(path, "ancient"), "")
+		if err != nil {
+			log.Warn("Could not read the chain config, give --addresshash if the chain does not use keccak256", "err", err)
+			return ""
+		}
+		config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+		db.Close()
+		if config != nil {
+			return config.HashFunction
+		}
+	}
+	return ""
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
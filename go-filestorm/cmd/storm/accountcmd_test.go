@@ -18,12 +18,18 @@ package main
 
 import (
 	"io/ioutil"
+	"math/big"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/cespare/cp"
+	"github.com/filestorm/go-filestorm/accounts/keystore"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 )
 
 // These tests are 'smoke tests' for the account related
@@ -116,6 +122,58 @@ Repeat password: {{.InputLine "foobar2"}}
 `)
 }
 
+// Tests that accounts created in a data directory initialised with an SM3
+// genesis have the SM3 address, which signs the transactions of the account.
+func TestAccountNewSM3Chain(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+	genesis := filepath.Join(datadir, "genesis.json")
+	if err := ioutil.WriteFile(genesis, []byte(`{"config": {"chainId": 7, "hashFunction": "sm3"}, "alloc": {}, "difficulty": "0x20000", "gasLimit": "0x2fefd8"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	password := filepath.Join(datadir, "password")
+	if err := ioutil.WriteFile(password, []byte("foobar"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runStorm(t, "--datadir", datadir, "init", genesis).WaitExit()
+
+	storm := runStorm(t, "account", "new", "--datadir", datadir, "--lightkdf", "--password", password)
+	_, matches := storm.ExpectRegexp(`(?s).*Public address of the key:\s+0x([0-9a-fA-F]{40})\n.*`)
+	storm.ExpectExit()
+	if len(matches) != 2 {
+		t.Fatal("no address printed")
+	}
+	// an account created before the chain, with the default address hash
+	other := tmpdir(t)
+	defer os.RemoveAll(other)
+	runStorm(t, "account", "new", "--datadir", other, "--lightkdf", "--password", password).WaitExit()
+
+	ks := keystore.NewKeyStoreWithHasher(filepath.Join(datadir, "keystore"), keystore.LightScryptN, keystore.LightScryptP, keystore.LightPBKDF2Iterations, crypto.SM3Hasher)
+	if len(ks.Accounts()) != 1 {
+		t.Fatalf("have %d accounts, want 1", len(ks.Accounts()))
+	}
+	account := ks.Accounts()[0]
+	if address := common.HexToAddress(matches[1]); account.Address != address {
+		t.Fatalf("account %x, printed %x", account.Address, address)
+	}
+	if err := ks.Unlock(account, "foobar"); err != nil {
+		t.Fatalf("unlock on the SM3 chain: %v", err)
+	}
+	chainID := big.NewInt(7)
+	tx, err := ks.SignTx(account, types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil), chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, err := types.Sender(types.NewEIP155SignerWithHasher(chainID, crypto.SM3Hasher), tx); err != nil || sender != account.Address {
+		t.Fatalf("transaction sender %x, %v, want %x", sender, err, account.Address)
+	}
+
+	otherKs := keystore.NewKeyStoreWithHasher(filepath.Join(other, "keystore"), keystore.LightScryptN, keystore.LightScryptP, keystore.LightPBKDF2Iterations, crypto.SM3Hasher)
+	if err := otherKs.Unlock(otherKs.Accounts()[0], "foobar"); err == nil || !strings.Contains(err.Error(), "another hash function") {
+		t.Fatalf("unlock of a keccak256 account on the SM3 chain: %v", err)
+	}
+}
+
 func TestWalletImport(t *testing.T) {
 	storm := runStorm(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer storm.ExpectExit()
@@ -187,6 +187,7 @@ Use "filestorm dump 0" to dump the genesis block.`,
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.AncientIPFSFlag,
 			utils.CacheFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
@@ -227,6 +228,9 @@ func initGenesis(ctx *cli.Context) error {
 				ConstantinopleBlock: big.NewInt(0),
 				PetersburgBlock:     big.NewInt(0),
 				IstanbulBlock:       big.NewInt(0),
+				NotaryBlock:         big.NewInt(0),
+				CollectionsBlock:    big.NewInt(0),
+				RelayBlock:          big.NewInt(0),
 			},
 		}
 		// In the case of clique, configure the consensus parameters
@@ -562,7 +566,7 @@ func copyDb(ctx *cli.Context) error {
 	if syncMode == downloader.FastSync {
 		syncBloom = trie.NewSyncBloom(uint64(ctx.GlobalInt(utils.CacheFlag.Name)/2), chainDb)
 	}
-	dl := downloader.New(0, chainDb, syncBloom, new(event.TypeMux), chain, nil, nil)
+	dl := downloader.New(0, chainDb, syncBloom, chain.Config().Hasher(), new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
 	db, err := rawdb.NewLevelDBDatabaseWithFreezer(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name)/2, 256, ctx.Args().Get(1), "")
@@ -23,14 +23,19 @@ import (
 	"math/big"
 	"os"
 	"reflect"
+	"strings"
 	"unicode"
 
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/filestorm/go-filestorm/cmd/utils"
+	"github.com/filestorm/go-filestorm/connections"
 	"github.com/filestorm/go-filestorm/fst"
+	"github.com/filestorm/go-filestorm/fst/downloader"
+	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/node"
 	"github.com/filestorm/go-filestorm/params"
+	gossip "github.com/filestorm/go-filestorm/whisper-gossip"
 	whisper "github.com/filestorm/go-filestorm/whisper/whisperv6"
 	"github.com/naoina/toml"
 )
@@ -41,7 +46,7 @@ var (
 		Name:        "dumpconfig",
 		Usage:       "Show configuration values",
 		ArgsUsage:   "",
-		Flags:       append(append(nodeFlags, rpcFlags...), whisperFlags...),
+		Flags:       append(append(append(append(nodeFlags, rpcFlags...), whisperFlags...), gossipFlags...), relayFlags...),
 		Category:    "MISCELLANEOUS COMMANDS",
 		Description: `The dumpconfig command shows configuration values.`,
 	}
@@ -74,10 +79,13 @@ type fststatsConfig struct {
 }
 
 type gethConfig struct {
-	Fst      fst.Config
-	Shh      whisper.Config
-	Node     node.Config
-	Fststats fststatsConfig
+	Fst       fst.Config
+	Appchains []fst.AppchainConfig `toml:",omitempty"`
+	Shh       whisper.Config
+	Gossip    gossip.Config
+	Relay     connections.Config
+	Node      node.Config
+	Fststats  fststatsConfig
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -99,8 +107,8 @@ func defaultNodeConfig() node.Config {
 	cfg := node.DefaultConfig
 	cfg.Name = clientIdentifier
 	cfg.Version = params.VersionWithCommit(gitCommit, gitDate)
-	cfg.HTTPModules = append(cfg.HTTPModules, "fst", "shh")
-	cfg.WSModules = append(cfg.WSModules, "fst", "shh")
+	cfg.HTTPModules = append(cfg.HTTPModules, "fst", "shh", "gossip", "relay")
+	cfg.WSModules = append(cfg.WSModules, "fst", "shh", "gossip", "relay")
 	cfg.IPCPath = "storm.ipc"
 	return cfg
 }
@@ -108,9 +116,11 @@ func defaultNodeConfig() node.Config {
 func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	// Load defaults.
 	cfg := gethConfig{
-		Fst:  fst.DefaultConfig,
-		Shh:  whisper.DefaultConfig,
-		Node: defaultNodeConfig(),
+		Fst:    fst.DefaultConfig,
+		Shh:    whisper.DefaultConfig,
+		Gossip: gossip.DefaultConfig,
+		Relay:  connections.DefaultConfig,
+		Node:   defaultNodeConfig(),
 	}
 
 	// Load config file.
@@ -122,6 +132,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
+	cfg.Node.HTTPModules = appchainModules(cfg.Node.HTTPModules, cfg.Appchains)
+	cfg.Node.WSModules = appchainModules(cfg.Node.WSModules, cfg.Appchains)
+	setAddressHash(ctx, &cfg.Node)
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
 		utils.Fatalf("Failed to create the protocol stack: %v", err)
@@ -131,10 +144,34 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		cfg.Fststats.URL = ctx.GlobalString(utils.EthStatsURLFlag.Name)
 	}
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
+	utils.SetGossipConfig(ctx, &cfg.Gossip)
+	utils.SetRelayConfig(ctx, &cfg.Relay)
 
 	return stack, cfg
 }
 
+// appchainModules extends the given RPC modules with the namespaces the hosted
+// appchains serve them under.
+func appchainModules(modules []string, chains []fst.AppchainConfig) []string {
+	enabled := make(map[string]bool)
+	for _, module := range modules {
+		enabled[module] = true
+	}
+	extended := modules
+	for _, chain := range chains {
+		for _, module := range modules {
+			if strings.Contains(module, ".") {
+				continue // already an appchain namespace
+			}
+			if namespace := fst.AppchainNamespace(chain.Name, module); !enabled[namespace] {
+				enabled[namespace] = true
+				extended = append(extended, namespace)
+			}
+		}
+	}
+	return extended
+}
+
 // enableWhisper returns true in case one of the whisper flags is set.
 func enableWhisper(ctx *cli.Context) bool {
 	for _, flag := range whisperFlags {
@@ -145,6 +182,16 @@ func enableWhisper(ctx *cli.Context) bool {
 	return false
 }
 
+// enableGossip returns true in case one of the gossip flags is set.
+func enableGossip(ctx *cli.Context) bool {
+	for _, flag := range gossipFlags {
+		if ctx.GlobalIsSet(flag.GetName()) {
+			return true
+		}
+	}
+	return false
+}
+
 func makeFullNode(ctx *cli.Context) *node.Node {
 	stack, cfg := makeConfigNode(ctx)
 	if ctx.GlobalIsSet(utils.OverrideIstanbulFlag.Name) {
@@ -155,6 +202,14 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	}
 	utils.RegisterEthService(stack, &cfg.Fst)
 
+	// Host the configured appchains next to the primary chain
+	if len(cfg.Appchains) > 0 {
+		if cfg.Fst.SyncMode == downloader.LightSync {
+			utils.Fatalf("Light clients can't host appchains")
+		}
+		utils.RegisterAppchainService(stack, &cfg.Fst, cfg.Appchains)
+	}
+
 	// Whisper must be explicitly enabled by specifying at least 1 whisper flag or in dev mode
 	shhEnabled := enableWhisper(ctx)
 	shhAutoEnabled := !ctx.GlobalIsSet(utils.WhisperEnabledFlag.Name) && ctx.GlobalIsSet(utils.DeveloperFlag.Name)
@@ -168,8 +223,19 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		if ctx.GlobalIsSet(utils.WhisperRestrictConnectionBetweenLightClientsFlag.Name) {
 			cfg.Shh.RestrictConnectionBetweenLightClients = true
 		}
+		if shhEnabled {
+			log.Warn("Whisper is deprecated, use the gossip protocol (--gossip) instead")
+		}
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
+	// Gossip must be explicitly enabled by specifying at least 1 gossip flag
+	if enableGossip(ctx) {
+		utils.RegisterGossipService(stack, &cfg.Gossip)
+	}
+	// Relay the headers of a foreign chain if configured
+	if cfg.Relay.Endpoint != "" {
+		utils.RegisterRelayService(stack, &cfg.Relay)
+	}
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, cfg.Node.GraphQLEndpoint(), cfg.Node.GraphQLCors, cfg.Node.GraphQLVirtualHosts, cfg.Node.HTTPTimeouts)
@@ -118,18 +118,7 @@ func remoteConsole(ctx *cli.Context) error {
 	// Attach to a remotely running storm instance and start the JavaScript console
 	endpoint := ctx.Args().First()
 	if endpoint == "" {
-		path := node.DefaultDataDir()
-		if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
-			path = ctx.GlobalString(utils.DataDirFlag.Name)
-		}
-		if path != "" {
-			if ctx.GlobalBool(utils.TestnetFlag.Name) {
-				path = filepath.Join(path, "testnet")
-			} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
-				path = filepath.Join(path, "rinkeby")
-			}
-		}
-		endpoint = fmt.Sprintf("%s/storm.ipc", path)
+		endpoint = dataDirIPCEndpoint(ctx)
 	}
 	client, err := dialRPC(endpoint)
 	if err != nil {
@@ -160,6 +149,23 @@ func remoteConsole(ctx *cli.Context) error {
 	return nil
 }
 
+// dataDirIPCEndpoint returns the IPC endpoint of a node running in the data
+// directory selected on the command line.
+func dataDirIPCEndpoint(ctx *cli.Context) string {
+	path := node.DefaultDataDir()
+	if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
+		path = ctx.GlobalString(utils.DataDirFlag.Name)
+	}
+	if path != "" {
+		if ctx.GlobalBool(utils.TestnetFlag.Name) {
+			path = filepath.Join(path, "testnet")
+		} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
+			path = filepath.Join(path, "rinkeby")
+		}
+	}
+	return fmt.Sprintf("%s/storm.ipc", path)
+}
+
 // dialRPC returns a RPC client which connects to the given endpoint.
 // The check for empty endpoint implements the defaulting logic
 // for "storm attach" and "storm monitor" with no argument.
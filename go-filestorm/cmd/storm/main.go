@@ -75,6 +75,7 @@ var (
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
 		utils.AncientFlag,
+		utils.AncientIPFSFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
 		utils.NoUSBFlag,
@@ -141,6 +142,7 @@ var (
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
+		utils.IdentitySchemeFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
 		utils.TestnetFlag,
@@ -188,6 +190,20 @@ var (
 		utils.WhisperRestrictConnectionBetweenLightClientsFlag,
 	}
 
+	gossipFlags = []cli.Flag{
+		utils.GossipEnabledFlag,
+		utils.GossipMaxMessageSizeFlag,
+		utils.GossipMaxTTLFlag,
+		utils.GossipQuotaFlag,
+	}
+
+	relayFlags = []cli.Flag{
+		utils.RelayEndpointFlag,
+		utils.RelayIDFlag,
+		utils.RelayFromFlag,
+		utils.RelayBatchFlag,
+	}
+
 	metricsFlags = []cli.Flag{
 		utils.MetricsEnabledFlag,
 		utils.MetricsEnabledExpensiveFlag,
@@ -230,6 +246,8 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See notarizecmd.go
+		notarizeCommand,
 		// See retesteth.go
 		retestethCommand,
 	}
@@ -240,6 +258,8 @@ func init() {
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, whisperFlags...)
+	app.Flags = append(app.Flags, gossipFlags...)
+	app.Flags = append(app.Flags, relayFlags...)
 	app.Flags = append(app.Flags, metricsFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
@@ -442,6 +462,28 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 			utils.Fatalf("Failed to start mining: %v", err)
 		}
 	}
+	// Start sealing the hosted appchains configured to mine
+	var appchains *fst.Appchains
+	if err := stack.Service(&appchains); err == nil {
+		if err := appchains.StartMining(ctx.GlobalInt(utils.MinerThreadsFlag.Name)); err != nil {
+			utils.Fatalf("Failed to start mining: %v", err)
+		}
+	}
+}
+
+// setFlushCredentials hands the coinbase key to the miners of all chains run by
+// the node, signing the flush transactions sent to the main chain.
+func setFlushCredentials(stack *node.Node, keystore, password string) {
+	var filestorm *fst.Filestorm
+	if err := stack.Service(&filestorm); err == nil {
+		filestorm.Miner().SetFlushCredentials(keystore, password)
+	}
+	var appchains *fst.Appchains
+	if err := stack.Service(&appchains); err == nil {
+		for _, chain := range appchains.Chains() {
+			chain.Miner().SetFlushCredentials(keystore, password)
+		}
+	}
 }
 
 // unlockAccounts unlocks any account specifically requested.
@@ -464,8 +506,6 @@ func unlockAccounts(ctx *cli.Context, stack *node.Node) {
 	}
 	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	passwords := utils.MakePasswordList(ctx)
-	//todo
-	node.DefaultConfig.CoinBasePassword = passwords[0]
 
 	wallets := stack.AccountManager().Wallets()
 	keystorePath := wallets[0].URL()
@@ -479,7 +519,7 @@ func unlockAccounts(ctx *cli.Context, stack *node.Node) {
 	if err != nil {
 		utils.Fatalf("Can't open coinbase keystore file.")
 	}
-	node.DefaultConfig.CoinBaseKeystore = string(keystore)
+	setFlushCredentials(stack, string(keystore), passwords[0])
 
 	for i, account := range unlocks {
 		unlockAccount(ks, account, i, passwords)
@@ -0,0 +1,423 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of go-filestorm.
+//
+// go-filestorm is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-filestorm is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-filestorm. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/filestorm/go-filestorm"
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/cmd/utils"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/flush"
+	"github.com/filestorm/go-filestorm/fstclient"
+	"github.com/filestorm/go-filestorm/internal/fstapi"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/notary"
+	"github.com/filestorm/go-filestorm/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	notaryEndpointFlag = cli.StringFlag{
+		Name:  "endpoint",
+		Usage: "IPC or HTTP endpoint of the node (default = IPC endpoint in the data directory)",
+	}
+	notaryFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "Unlocked account of the node sending the notarization",
+	}
+	notaryAlgorithmFlag = cli.StringFlag{
+		Name:  "algorithm",
+		Usage: `Hash algorithm of the content ("keccak256" or "sm3")`,
+		Value: notary.AlgorithmKeccak256,
+	}
+	notaryMetadataFlag = cli.StringFlag{
+		Name:  "metadata",
+		Usage: "Metadata recorded with the notarization",
+	}
+	notaryOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "File to write the proof to (default = standard output)",
+	}
+	notaryParentFlag = cli.StringFlag{
+		Name:  "parent",
+		Usage: "IPC or HTTP endpoint of a node of the parent chain to verify the anchor with",
+	}
+	notaryContractFlag = cli.StringFlag{
+		Name:  "contract",
+		Usage: "Address of the AppChainBase contract the chain flushes to on the parent chain",
+	}
+
+	notarizeCommand = cli.Command{
+		Action:    utils.MigrateFlags(notarize),
+		Name:      "notarize",
+		Usage:     "Notarize a file on the chain",
+		ArgsUsage: "<file>",
+		Category:  "NOTARIZATION COMMANDS",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			notaryEndpointFlag,
+			notaryFromFlag,
+			notaryAlgorithmFlag,
+			notaryMetadataFlag,
+		},
+		Description: `
+    storm notarize --from <address> [--algorithm sm3] [--metadata <text>] <file>
+
+Hashes the file and records the hash, the owner account and the metadata with
+the notarization system contract of a running node. The file itself is not
+sent to the node. The owner account has to be unlocked in the node. The command
+waits until the notarization is included in a block.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(notarizationProof),
+				Name:      "proof",
+				Usage:     "Retrieve the proof of a notarization",
+				ArgsUsage: "<hash>",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					notaryEndpointFlag,
+					notaryOutFlag,
+				},
+				Description: `
+    storm notarize proof [--out <proof.json>] <hash>
+
+Retrieves the proof of the notarization of a content hash from a running node.
+The proof contains the notarization transaction and receipt with their Merkle
+proofs and the block headers up to the next block flushed to the parent chain,
+and can be verified offline with 'storm notarize verify'. Proofs of recent
+notarizations are anchored once the chain reaches that block.`,
+			},
+			{
+				Action:    utils.MigrateFlags(verifyNotarization),
+				Name:      "verify",
+				Usage:     "Verify the proof of a notarization offline",
+				ArgsUsage: "<proof.json> [<file>]",
+				Flags: []cli.Flag{
+					notaryParentFlag,
+					notaryContractFlag,
+				},
+				Description: `
+    storm notarize verify [--parent <endpoint> --contract <address>] <proof.json> [<file>]
+
+Verifies a notarization proof and prints the notarization. If a file is given,
+it also checks that the file is the notarized content.
+
+The transaction, receipt and headers of the proof are checked offline, which
+shows that they are consistent but not that the chain produced them: anyone can
+build a chain of headers. The proof is valid once its anchor block is found
+among the blocks flushed to the AppChainBase contract of the parent chain, so
+pass a node of the parent chain with --parent and the contract with --contract.
+Without them the anchor is reported as unverified.`,
+			},
+		},
+	}
+)
+
+// notarize hashes the file given as argument and notarizes the hash.
+func notarize(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a file argument.")
+	}
+	if !common.IsHexAddress(ctx.String(notaryFromFlag.Name)) {
+		utils.Fatalf("Missing or invalid --%s account", notaryFromFlag.Name)
+	}
+	content, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read the file: %v", err)
+	}
+	algorithm := ctx.String(notaryAlgorithmFlag.Name)
+	hash, err := notary.HashContent(algorithm, content)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+
+	client := dialNotaryNode(ctx)
+	defer client.Close()
+
+	var receipt fstapi.NotarizationReceipt
+	args := fstapi.NotarizeArgs{
+		From:      common.HexToAddress(ctx.String(notaryFromFlag.Name)),
+		Hash:      &hash,
+		Algorithm: algorithm,
+		Metadata:  ctx.String(notaryMetadataFlag.Name),
+	}
+	if err := client.Call(&receipt, "fst_notarize", args); err != nil {
+		utils.Fatalf("Failed to notarize the file: %v", err)
+	}
+	fmt.Printf("Submitted notarization of %x, waiting for transaction %x\n", hash, receipt.TxHash)
+
+	txReceipt := waitReceipt(fstclient.NewClient(client), receipt.TxHash)
+	if txReceipt.Status != types.ReceiptStatusSuccessful {
+		utils.Fatalf("Notarization transaction %x failed", receipt.TxHash)
+	}
+	fmt.Printf("Notarized %x (%s)\n", hash, algorithm)
+	fmt.Printf("Owner:       %x\n", receipt.Owner)
+	fmt.Printf("Transaction: %x\n", receipt.TxHash)
+	fmt.Printf("Block:       %d\n", txReceipt.BlockNumber)
+	return nil
+}
+
+// waitReceipt polls the receipt of a transaction until it is included.
+func waitReceipt(client *fstclient.Client, txHash common.Hash) *types.Receipt {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := client.TransactionReceipt(context.Background(), txHash)
+		if err == nil {
+			return receipt
+		}
+		if err != filestorm.NotFound {
+			utils.Fatalf("Failed to retrieve the receipt: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// notarizationProof retrieves the proof of the notarization of a hash.
+func notarizationProof(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a hash argument.")
+	}
+	hash := common.HexToHash(ctx.Args().First())
+
+	client := dialNotaryNode(ctx)
+	defer client.Close()
+
+	var proof notary.Proof
+	if err := client.Call(&proof, "fst_getNotarizationProof", hash); err != nil {
+		utils.Fatalf("Failed to retrieve the proof: %v", err)
+	}
+	out, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode the proof: %v", err)
+	}
+	if path := ctx.String(notaryOutFlag.Name); path != "" {
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			utils.Fatalf("Failed to write the proof: %v", err)
+		}
+	} else {
+		fmt.Println(string(out))
+	}
+	if proof.Anchor == nil {
+		log.Warn("Proof is not anchored yet, retrieve it again once the next flush block is reached")
+	}
+	return nil
+}
+
+// verifyNotarization verifies the proof given as first argument.
+func verifyNotarization(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 || len(ctx.Args()) > 2 {
+		utils.Fatalf("This command requires a proof and an optional file argument.")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read the proof: %v", err)
+	}
+	var proof notary.Proof
+	if err := json.Unmarshal(blob, &proof); err != nil {
+		utils.Fatalf("Invalid proof: %v", err)
+	}
+	record, err := notary.Verify(&proof)
+	if err != nil {
+		utils.Fatalf("Proof verification failed: %v", err)
+	}
+	if len(ctx.Args()) == 2 {
+		content, err := ioutil.ReadFile(ctx.Args().Get(1))
+		if err != nil {
+			utils.Fatalf("Failed to read the file: %v", err)
+		}
+		if err := notary.VerifyContent(record, content); err != nil {
+			utils.Fatalf("File verification failed: %v", err)
+		}
+	}
+	anchored := false
+	if endpoint := ctx.String(notaryParentFlag.Name); endpoint != "" {
+		if !common.IsHexAddress(ctx.String(notaryContractFlag.Name)) {
+			utils.Fatalf("Missing or invalid --%s address", notaryContractFlag.Name)
+		}
+		client, err := dialRPC(endpoint)
+		if err != nil {
+			utils.Fatalf("Unable to connect to the parent chain: %v", err)
+		}
+		defer client.Close()
+
+		contract := common.HexToAddress(ctx.String(notaryContractFlag.Name))
+		if err := verifyAnchor(context.Background(), &proof, fstclient.NewClient(client), contract); err != nil {
+			utils.Fatalf("Anchor verification failed: %v", err)
+		}
+		anchored = true
+	}
+	if anchored {
+		fmt.Println("Proof is valid")
+	} else {
+		fmt.Println("Proof is consistent, but its anchor is unverified")
+	}
+	fmt.Printf("Hash:        %x (%s)\n", record.Hash, record.Algorithm)
+	fmt.Printf("Owner:       %x\n", record.Owner)
+	fmt.Printf("Time:        %v\n", time.Unix(int64(record.Timestamp), 0).UTC())
+	fmt.Printf("Chain:       %v\n", proof.ChainID.ToInt())
+	fmt.Printf("Block:       %d\n", record.BlockNumber)
+	fmt.Printf("Transaction: %x\n", record.TxHash)
+	fmt.Printf("Metadata:    %s\n", record.Metadata)
+	if len(ctx.Args()) == 2 {
+		fmt.Println("File:        matches the notarized hash")
+	}
+	switch {
+	case anchored:
+		fmt.Printf("Anchor:      block %d (%x), flushed to the parent chain\n", proof.Anchor.BlockNumber, proof.Anchor.BlockHash)
+	case proof.Anchor != nil:
+		fmt.Printf("Anchor:      block %d (%x), unverified, check it with --%s and --%s\n", proof.Anchor.BlockNumber, proof.Anchor.BlockHash, notaryParentFlag.Name, notaryContractFlag.Name)
+	default:
+		fmt.Println("Anchor:      none, the proof is not anchored to a flushed block")
+	}
+	return nil
+}
+
+// verifyAnchor checks that the anchor block of a proof was flushed to the
+// AppChainBase contract on the parent chain.
+func verifyAnchor(ctx context.Context, proof *notary.Proof, parent bind.ContractCaller, contract common.Address) error {
+	if proof.Anchor == nil {
+		return errors.New("proof is not anchored")
+	}
+	base, err := flush.NewAppChainBaseCaller(contract, parent)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	chainID, err := base.ChainId(opts)
+	if err != nil {
+		return fmt.Errorf("failed to read the chain id: %v", err)
+	}
+	if proof.ChainID == nil || chainID.Cmp(proof.ChainID.ToInt()) != 0 {
+		return fmt.Errorf("contract belongs to chain %v", chainID)
+	}
+	rounds := &flushRounds{base: base, opts: opts, cache: make(map[uint64]*flushRound)}
+	count, err := rounds.count()
+	if err != nil {
+		return err
+	}
+	// The validators flush the blocks in ascending order, search the first
+	// round of the anchor block and check the hashes flushed for it.
+	lo, hi := uint64(0), count
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		round, err := rounds.get(mid)
+		if err != nil {
+			return err
+		}
+		if round.number < proof.Anchor.BlockNumber {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	for id := lo; id < count; id++ {
+		round, err := rounds.get(id)
+		if err != nil {
+			return err
+		}
+		if round.number != proof.Anchor.BlockNumber {
+			break
+		}
+		if strings.EqualFold(round.hash, proof.Anchor.BlockHash.Hex()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("block %d (%x) was not flushed to the parent chain", proof.Anchor.BlockNumber, proof.Anchor.BlockHash)
+}
+
+// flushRound is the block number and hash recorded by a flush.
+type flushRound struct {
+	number uint64
+	hash   string
+	exists bool
+}
+
+// flushRounds reads the flush rounds of an AppChainBase contract, which are
+// numbered from zero without gaps.
+type flushRounds struct {
+	base  *flush.AppChainBaseCaller
+	opts  *bind.CallOpts
+	cache map[uint64]*flushRound
+}
+
+func (r *flushRounds) get(id uint64) (*flushRound, error) {
+	if round, ok := r.cache[id]; ok {
+		return round, nil
+	}
+	res, err := r.base.FlushMapping(r.opts, new(big.Int).SetUint64(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flush %d: %v", id, err)
+	}
+	round := &flushRound{number: res.BlockNumber.Uint64(), hash: res.BlockHash, exists: res.Validator != (common.Address{})}
+	r.cache[id] = round
+	return round, nil
+}
+
+// count returns the number of flush rounds. The contract doesn't expose it,
+// so the rounds are probed with a doubling and then a binary search.
+func (r *flushRounds) count() (uint64, error) {
+	lo, hi := uint64(0), uint64(1)
+	for {
+		round, err := r.get(hi - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !round.exists {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	// Round lo-1 exists, round hi-1 doesn't.
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		round, err := r.get(mid - 1)
+		if err != nil {
+			return 0, err
+		}
+		if round.exists {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// dialNotaryNode connects to the node selected with --endpoint or --datadir.
+func dialNotaryNode(ctx *cli.Context) *rpc.Client {
+	endpoint := ctx.String(notaryEndpointFlag.Name)
+	if endpoint == "" {
+		endpoint = dataDirIPCEndpoint(ctx)
+	}
+	client, err := dialRPC(endpoint)
+	if err != nil {
+		utils.Fatalf("Unable to connect to storm: %v", err)
+	}
+	return client
+}
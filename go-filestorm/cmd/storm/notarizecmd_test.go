@@ -0,0 +1,83 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of go-filestorm.
+//
+// go-filestorm is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-filestorm is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-filestorm. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/accounts/abi/bind/backends"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/flush"
+	"github.com/filestorm/go-filestorm/notary"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+// Tests that the anchor of a proof is only accepted if the parent chain
+// recorded its hash in the AppChainBase contract.
+func TestVerifyAnchor(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+	parent := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: balance}}, 10000000)
+	defer parent.Close()
+
+	chainID := big.NewInt(1337)
+	opts := bind.NewKeyedTransactor(key)
+	opts.Value = new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether))
+	contract, _, base, err := flush.DeployAppChainBase(opts, parent, "test", chainID, big.NewInt(5), big.NewInt(360), []common.Address{addr}, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("failed to deploy AppChainBase: %v", err)
+	}
+	parent.Commit()
+
+	opts.Value = nil
+	flushed := map[uint64]common.Hash{5: {0x05}, 365: {0x01, 0x65}, 725: {0x07, 0x25}}
+	for _, number := range []uint64{5, 365, 725} {
+		if _, err := base.Flush(opts, []common.Address{addr}, new(big.Int).SetUint64(number), flushed[number].String()); err != nil {
+			t.Fatalf("failed to flush block %d: %v", number, err)
+		}
+		parent.Commit()
+	}
+
+	tests := []struct {
+		chainID *big.Int
+		anchor  *notary.Anchor
+		valid   bool
+	}{
+		{chainID, &notary.Anchor{BlockNumber: 5, BlockHash: flushed[5]}, true},
+		{chainID, &notary.Anchor{BlockNumber: 365, BlockHash: flushed[365]}, true},
+		{chainID, &notary.Anchor{BlockNumber: 725, BlockHash: flushed[725]}, true},
+		{chainID, &notary.Anchor{BlockNumber: 365, BlockHash: common.Hash{0xff}}, false},
+		{chainID, &notary.Anchor{BlockNumber: 366, BlockHash: flushed[365]}, false},
+		{chainID, &notary.Anchor{BlockNumber: 1085, BlockHash: common.Hash{0x10, 0x85}}, false},
+		{big.NewInt(1), &notary.Anchor{BlockNumber: 365, BlockHash: flushed[365]}, false},
+		{chainID, nil, false},
+	}
+	for i, tt := range tests {
+		proof := &notary.Proof{ChainID: (*hexutil.Big)(tt.chainID), Anchor: tt.anchor}
+		err := verifyAnchor(context.Background(), proof, parent, contract)
+		if (err == nil) != tt.valid {
+			t.Errorf("test %d: anchor %+v: have error %v, want valid %v", i, tt.anchor, err, tt.valid)
+		}
+	}
+}
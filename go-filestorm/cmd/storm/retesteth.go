@@ -456,7 +456,7 @@ func (api *RetestethAPI) MineBlocks(ctx context.Context, number uint64) (bool, e
 
 func (api *RetestethAPI) mineBlock() error {
 	parentHash := rawdb.ReadCanonicalHash(api.ethDb, api.blockNumber)
-	parent := rawdb.ReadBlock(api.ethDb, parentHash, api.blockNumber)
+	parent := rawdb.ReadBlock(api.ethDb, parentHash, api.blockNumber, api.chainConfig.Hasher())
 	var timestamp uint64
 	if api.blockInterval == 0 {
 		timestamp = uint64(time.Now().Unix())
@@ -471,6 +471,7 @@ func (api *RetestethAPI) mineBlock() error {
 		Extra:      api.extraData,
 		Time:       timestamp,
 	}
+	header.SetHasher(api.chainConfig.Hasher())
 	header.Coinbase = api.author
 	if api.engine != nil {
 		api.engine.Prepare(api.blockchain, header)
@@ -68,6 +68,7 @@ var AppHelpFlagGroups = []flagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.AncientIPFSFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
 			utils.SmartCardDaemonPathFlag,
@@ -191,6 +192,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
+			utils.IdentitySchemeFlag,
 		},
 	},
 	{
@@ -235,7 +237,15 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: metricsFlags,
 	},
 	{
-		Name:  "WHISPER (EXPERIMENTAL)",
+		Name:  "GOSSIP",
+		Flags: gossipFlags,
+	},
+	{
+		Name:  "FOREIGN CHAIN RELAY",
+		Flags: relayFlags,
+	},
+	{
+		Name:  "WHISPER (DEPRECATED)",
 		Flags: whisperFlags,
 	},
 	{
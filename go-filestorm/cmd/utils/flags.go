@@ -36,17 +36,19 @@ import (
 	"github.com/filestorm/go-filestorm/accounts/keystore"
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/fdlimit"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/connections"
 	"github.com/filestorm/go-filestorm/consensus"
 	"github.com/filestorm/go-filestorm/consensus/clique"
 	"github.com/filestorm/go-filestorm/consensus/fstash"
 	"github.com/filestorm/go-filestorm/consensus/pbft"
 	"github.com/filestorm/go-filestorm/core"
 	"github.com/filestorm/go-filestorm/core/vm"
-	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fst"
 	"github.com/filestorm/go-filestorm/fst/downloader"
 	"github.com/filestorm/go-filestorm/fst/gasprice"
 	"github.com/filestorm/go-filestorm/fstdb"
+	"github.com/filestorm/go-filestorm/fstipfs"
 	"github.com/filestorm/go-filestorm/fststats"
 	"github.com/filestorm/go-filestorm/graphql"
 	"github.com/filestorm/go-filestorm/les"
@@ -62,6 +64,7 @@ import (
 	"github.com/filestorm/go-filestorm/p2p/netutil"
 	"github.com/filestorm/go-filestorm/params"
 	"github.com/filestorm/go-filestorm/rpc"
+	gossip "github.com/filestorm/go-filestorm/whisper-gossip"
 	whisper "github.com/filestorm/go-filestorm/whisper/whisperv6"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	cli "gopkg.in/urfave/cli.v1"
@@ -185,6 +188,10 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	AncientIPFSFlag = cli.StringFlag{
+		Name:  "datadir.ancient.ipfs",
+		Usage: "Offload ancient block bodies and receipts to IPFS (HTTP API URL or blockstore directory)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -267,6 +274,15 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KeyCipherFlag = cli.StringFlag{
+		Name:  "cipher",
+		Usage: `Key file cipher ("aes" for scrypt and AES-128-CTR, "sm4" for PBKDF2-HMAC-SM3 and SM4-128-CTR)`,
+		Value: keystore.CipherAES,
+	}
+	AddressHashFlag = cli.StringFlag{
+		Name:  "addresshash",
+		Usage: `Hash function deriving the address of the keys ("keccak256", "sm3"), defaults to the one of the chain initialised in the data directory`,
+	}
 	WhitelistFlag = cli.StringFlag{
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
@@ -679,6 +695,11 @@ var (
 		Name:  "nodekeyhex",
 		Usage: "P2P node key as hex (for testing)",
 	}
+	IdentitySchemeFlag = cli.StringFlag{
+		Name:  "identityscheme",
+		Usage: "P2P node identity scheme, the same for all nodes of a network (v4 = secp256k1/ECIES/AES, sm2 = SM2/SM4)",
+		Value: p2p.IdentitySchemeV4,
+	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
@@ -733,6 +754,44 @@ var (
 		Name:  "shh.restrict-light",
 		Usage: "Restrict connection between two whisper light clients",
 	}
+	GossipEnabledFlag = cli.BoolFlag{
+		Name:  "gossip",
+		Usage: "Enable the gossip messaging protocol",
+	}
+	GossipMaxMessageSizeFlag = cli.IntFlag{
+		Name:  "gossip.maxmessagesize",
+		Usage: "Max gossip message size accepted",
+		Value: int(gossip.DefaultConfig.MaxMessageSize),
+	}
+	GossipMaxTTLFlag = cli.IntFlag{
+		Name:  "gossip.maxttl",
+		Usage: "Max time in seconds gossip messages are stored and forwarded",
+		Value: int(gossip.DefaultConfig.MaxTTL),
+	}
+	GossipQuotaFlag = cli.Uint64Flag{
+		Name:  "gossip.quota",
+		Usage: "Gossip messages per minute accepted from accounts without stake",
+		Value: gossip.DefaultConfig.Quota,
+	}
+
+	// Foreign chain relay settings
+	RelayEndpointFlag = cli.StringFlag{
+		Name:  "relay.endpoint",
+		Usage: "JSON-RPC endpoint of a foreign chain to relay headers from",
+	}
+	RelayIDFlag = cli.StringFlag{
+		Name:  "relay.id",
+		Usage: "Id of the relay of the foreign chain in the relay system contract",
+	}
+	RelayFromFlag = cli.StringFlag{
+		Name:  "relay.from",
+		Usage: "Unlocked account submitting the foreign headers",
+	}
+	RelayBatchFlag = cli.IntFlag{
+		Name:  "relay.batch",
+		Usage: "Foreign headers submitted per transaction",
+		Value: connections.DefaultConfig.Batch,
+	}
 
 	// Metrics flags
 	MetricsEnabledFlag = cli.BoolFlag{
@@ -823,18 +882,30 @@ func setNodeKey(ctx *cli.Context, cfg *p2p.Config) {
 	case file != "" && hex != "":
 		Fatalf("Options %q and %q are mutually exclusive", NodeKeyFileFlag.Name, NodeKeyHexFlag.Name)
 	case file != "":
-		if key, err = crypto.LoadECDSA(file); err != nil {
+		if key, err = p2p.LoadNodeKey(cfg.IdentityScheme, file); err != nil {
 			Fatalf("Option %q: %v", NodeKeyFileFlag.Name, err)
 		}
 		cfg.PrivateKey = key
 	case hex != "":
-		if key, err = crypto.HexToECDSA(hex); err != nil {
+		if key, err = p2p.HexToNodeKey(cfg.IdentityScheme, hex); err != nil {
 			Fatalf("Option %q: %v", NodeKeyHexFlag.Name, err)
 		}
 		cfg.PrivateKey = key
 	}
 }
 
+// setIdentityScheme selects the node identity scheme from the command line flags.
+func setIdentityScheme(ctx *cli.Context, cfg *p2p.Config) {
+	if ctx.GlobalIsSet(IdentitySchemeFlag.Name) {
+		cfg.IdentityScheme = ctx.GlobalString(IdentitySchemeFlag.Name)
+	}
+	switch cfg.IdentityScheme {
+	case "", p2p.IdentitySchemeV4, p2p.IdentitySchemeSM2:
+	default:
+		Fatalf("Option %q: unknown identity scheme %q", IdentitySchemeFlag.Name, cfg.IdentityScheme)
+	}
+}
+
 // setNodeUserIdent creates the user identifier from CLI flags.
 func setNodeUserIdent(ctx *cli.Context, cfg *node.Config) {
 	if identity := ctx.GlobalString(IdentityFlag.Name); len(identity) > 0 {
@@ -922,14 +993,12 @@ func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
 func setNodeIp(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NodeIpFlag.Name) {
 		cfg.NodeIp = ctx.GlobalString(NodeIpFlag.Name)
-		node.DefaultConfig.NodeIp = ctx.GlobalString(NodeIpFlag.Name)
 	}
 }
 //TODO
 func setContractAddress(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(ContractAddressFlag.Name) {
 		cfg.ContractAddress = ctx.GlobalString(ContractAddressFlag.Name)
-		node.DefaultConfig.ContractAddress = ctx.GlobalString(ContractAddressFlag.Name)
 	}
 }
 
@@ -937,7 +1006,6 @@ func setContractAddress(ctx *cli.Context, cfg *node.Config) {
 func setVsFlag(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(VsFlag.Name) {
 		cfg.VsFlag = ctx.GlobalString(VsFlag.Name)
-		node.DefaultConfig.VsFlag = ctx.GlobalString(VsFlag.Name)
 	}
 }
 
@@ -1153,6 +1221,7 @@ func MakePasswordList(ctx *cli.Context) []string {
 }
 
 func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
+	setIdentityScheme(ctx, cfg)
 	setNodeKey(ctx, cfg)
 	setNAT(ctx, cfg)
 	setListenAddress(ctx, cfg)
@@ -1403,6 +1472,14 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	}
 }
 
+// setFlush configures flushing of sealed blocks to the main chain from the
+// node level flush settings.
+func setFlush(nodeConfig *node.Config, cfg *miner.FlushConfig) {
+	cfg.Enabled = strings.EqualFold(nodeConfig.VsFlag, "false")
+	cfg.Endpoint = nodeConfig.NodeIp
+	cfg.Contract = nodeConfig.ContractAddress
+}
+
 func setWhitelist(ctx *cli.Context, cfg *fst.Config) {
 	whitelist := ctx.GlobalString(WhitelistFlag.Name)
 	if whitelist == "" {
@@ -1480,6 +1557,42 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// SetGossipConfig applies gossip-related command line flags to the config.
+func SetGossipConfig(ctx *cli.Context, cfg *gossip.Config) {
+	if ctx.GlobalIsSet(GossipMaxMessageSizeFlag.Name) {
+		cfg.MaxMessageSize = uint32(ctx.GlobalUint(GossipMaxMessageSizeFlag.Name))
+	}
+	if ctx.GlobalIsSet(GossipMaxTTLFlag.Name) {
+		cfg.MaxTTL = uint32(ctx.GlobalUint(GossipMaxTTLFlag.Name))
+	}
+	if ctx.GlobalIsSet(GossipQuotaFlag.Name) {
+		cfg.Quota = ctx.GlobalUint64(GossipQuotaFlag.Name)
+	}
+}
+
+// SetRelayConfig applies relay-related command line flags to the config.
+func SetRelayConfig(ctx *cli.Context, cfg *connections.Config) {
+	if ctx.GlobalIsSet(RelayEndpointFlag.Name) {
+		cfg.Endpoint = ctx.GlobalString(RelayEndpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(RelayIDFlag.Name) {
+		id, err := hexutil.Decode(ctx.GlobalString(RelayIDFlag.Name))
+		if err != nil || len(id) != common.HashLength {
+			Fatalf("Invalid relay id %q", ctx.GlobalString(RelayIDFlag.Name))
+		}
+		cfg.Relay = common.BytesToHash(id)
+	}
+	if ctx.GlobalIsSet(RelayFromFlag.Name) {
+		if !common.IsHexAddress(ctx.GlobalString(RelayFromFlag.Name)) {
+			Fatalf("Invalid relay account %q", ctx.GlobalString(RelayFromFlag.Name))
+		}
+		cfg.From = common.HexToAddress(ctx.GlobalString(RelayFromFlag.Name))
+	}
+	if ctx.GlobalIsSet(RelayBatchFlag.Name) {
+		cfg.Batch = ctx.GlobalInt(RelayBatchFlag.Name)
+	}
+}
+
 // SetEthConfig applies fst-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *fst.Config) {
 	// Avoid conflicting network flags
@@ -1496,6 +1609,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *fst.Config) {
 	setTxPool(ctx, &cfg.TxPool)
 	setFstash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
+	setFlush(stack.Config(), &cfg.Miner.Flush)
 	setWhitelist(ctx, cfg)
 	setLes(ctx, cfg)
 
@@ -1512,6 +1626,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *fst.Config) {
 	if ctx.GlobalIsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
 	}
+	if ctx.GlobalIsSet(AncientIPFSFlag.Name) {
+		cfg.DatabaseFreezerIPFS = ctx.GlobalString(AncientIPFSFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1615,6 +1732,16 @@ func RegisterEthService(stack *node.Node, cfg *fst.Config) {
 	}
 }
 
+// RegisterAppchainService adds the appchains hosted next to the primary chain
+// to the given node.
+func RegisterAppchainService(stack *node.Node, cfg *fst.Config, chains []fst.AppchainConfig) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return fst.NewAppchains(ctx, cfg, chains)
+	}); err != nil {
+		Fatalf("Failed to register the appchains service: %v", err)
+	}
+}
+
 // RegisterShhService configures Whisper and adds it to the given node.
 func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
@@ -1624,6 +1751,40 @@ func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// RegisterGossipService configures the gossip messaging protocol and adds it
+// to the given node. Rate limits are derived from the validators and balances
+// of the local chain.
+func RegisterGossipService(stack *node.Node, cfg *gossip.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *fst.Filestorm
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, fmt.Errorf("gossip requires a full node: %v", err)
+		}
+		db, err := ctx.OpenDatabase("gossip", 16, 16, "gossip/db/")
+		if err != nil {
+			return nil, err
+		}
+		chain := ethServ.BlockChain()
+		return gossip.New(cfg, ctx.AccountManager, db, gossip.ChainQuota(cfg, chain), chain.Config().IsSM2()), nil
+	}); err != nil {
+		Fatalf("Failed to register the gossip service: %v", err)
+	}
+}
+
+// RegisterRelayService configures the relayer of a foreign chain and adds it
+// to the given node.
+func RegisterRelayService(stack *node.Node, cfg *connections.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *fst.Filestorm
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, fmt.Errorf("relayer requires a full node: %v", err)
+		}
+		return connections.New(cfg, ethServ.APIBackend)
+	}); err != nil {
+		Fatalf("Failed to register the relay service: %v", err)
+	}
+}
+
 // RegisterEthStatsService configures the Filestorm Stats daemon and adds it to
 // the given node.
 func RegisterEthStatsService(stack *node.Node, url string) {
@@ -1710,7 +1871,15 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) fstdb.Database {
 	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
 		name = "lightchaindata"
 	}
-	chainDb, err := stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "")
+	var (
+		chainDb fstdb.Database
+		err     error
+	)
+	if ipfs := ctx.GlobalString(AncientIPFSFlag.Name); ipfs != "" {
+		chainDb, err = stack.OpenDatabaseWithAncientStore(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "", fstipfs.Opener(ipfs))
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "")
+	}
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
@@ -1742,9 +1911,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	}
 	var engine consensus.Engine
 	if config.Clique != nil {
-		engine = clique.New(config.Clique, chainDb)
+		engine = clique.New(config.Clique, chainDb, config.Hasher())
 	} else if config.Pbft != nil {
-		engine = pbft.New(config.Pbft, chainDb)
+		engine = pbft.New(config.Pbft, chainDb, config.Hasher())
 	} else {
 		engine = fstash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
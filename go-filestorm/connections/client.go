@@ -0,0 +1,246 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// Client reads headers and receipts of a foreign EVM chain through its
+// Ethereum compatible JSON-RPC API.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects to the JSON-RPC endpoint of a foreign chain.
+func Dial(ctx context.Context, endpoint string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client of a foreign chain on top of an RPC connection.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the connection.
+func (c *Client) Close() {
+	c.c.Close()
+}
+
+// ChainID returns the chain id of the foreign chain.
+func (c *Client) ChainID(ctx context.Context) (uint64, error) {
+	var id hexutil.Uint64
+	err := c.c.CallContext(ctx, &id, "eth_chainId")
+	return uint64(id), err
+}
+
+// BlockNumber returns the number of the head of the foreign chain.
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	var number hexutil.Uint64
+	err := c.c.CallContext(ctx, &number, "eth_blockNumber")
+	return uint64(number), err
+}
+
+// rpcBlock is a block without transaction bodies as returned by the JSON-RPC
+// API of the foreign chain.
+type rpcBlock struct {
+	Hash         common.Hash      `json:"hash"`
+	ParentHash   common.Hash      `json:"parentHash"`
+	UncleHash    common.Hash      `json:"sha3Uncles"`
+	Coinbase     common.Address   `json:"miner"`
+	Root         common.Hash      `json:"stateRoot"`
+	TxHash       common.Hash      `json:"transactionsRoot"`
+	ReceiptHash  common.Hash      `json:"receiptsRoot"`
+	Bloom        types.Bloom      `json:"logsBloom"`
+	Difficulty   *hexutil.Big     `json:"difficulty"`
+	Number       *hexutil.Big     `json:"number"`
+	GasLimit     hexutil.Uint64   `json:"gasLimit"`
+	GasUsed      hexutil.Uint64   `json:"gasUsed"`
+	Time         hexutil.Uint64   `json:"timestamp"`
+	Extra        hexutil.Bytes    `json:"extraData"`
+	MixDigest    common.Hash      `json:"mixHash"`
+	Nonce        types.BlockNonce `json:"nonce"`
+	BaseFee      *hexutil.Big     `json:"baseFeePerGas"`
+	Transactions []common.Hash    `json:"transactions"`
+}
+
+// header converts the block to the header layout of the relay system contract
+// and checks that it reproduces the block hash.
+func (b *rpcBlock) header() (*vm.RelayHeader, error) {
+	if b.Difficulty == nil || b.Number == nil {
+		return nil, errors.New("incomplete header")
+	}
+	header := &vm.RelayHeader{
+		ParentHash:  b.ParentHash,
+		UncleHash:   b.UncleHash,
+		Coinbase:    b.Coinbase,
+		Root:        b.Root,
+		TxHash:      b.TxHash,
+		ReceiptHash: b.ReceiptHash,
+		Bloom:       b.Bloom,
+		Difficulty:  (*big.Int)(b.Difficulty),
+		Number:      (*big.Int)(b.Number),
+		GasLimit:    uint64(b.GasLimit),
+		GasUsed:     uint64(b.GasUsed),
+		Time:        uint64(b.Time),
+		Extra:       b.Extra,
+		MixDigest:   b.MixDigest,
+		Nonce:       b.Nonce,
+	}
+	if b.BaseFee != nil {
+		enc, _ := rlp.EncodeToBytes((*big.Int)(b.BaseFee))
+		header.Rest = []rlp.RawValue{enc}
+	}
+	// Fields of later forks are unknown and change the hash.
+	if hash := header.Hash(); hash != b.Hash {
+		return nil, fmt.Errorf("unsupported header of block %v: hash %x, want %x", b.Number, hash, b.Hash)
+	}
+	return header, nil
+}
+
+func (c *Client) block(ctx context.Context, method string, arg interface{}) (*rpcBlock, error) {
+	var block *rpcBlock
+	if err := c.c.CallContext(ctx, &block, method, arg, false); err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", arg)
+	}
+	return block, nil
+}
+
+// HeaderByNumber returns the canonical header of the foreign chain at number.
+func (c *Client) HeaderByNumber(ctx context.Context, number uint64) (*vm.RelayHeader, error) {
+	block, err := c.block(ctx, "eth_getBlockByNumber", hexutil.Uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	return block.header()
+}
+
+// rpcReceipt is a transaction receipt as returned by the JSON-RPC API of the
+// foreign chain.
+type rpcReceipt struct {
+	Type              *hexutil.Uint64 `json:"type"`
+	Root              hexutil.Bytes   `json:"root"`
+	Status            *hexutil.Uint64 `json:"status"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	Bloom             types.Bloom     `json:"logsBloom"`
+	Logs              []struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+	} `json:"logs"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// encode returns the consensus encoding of the receipt, the value stored in
+// the receipts trie.
+func (r *rpcReceipt) encode() ([]byte, error) {
+	state := []byte(r.Root)
+	if r.Status != nil {
+		state = []byte{}
+		if *r.Status == hexutil.Uint64(types.ReceiptStatusSuccessful) {
+			state = []byte{0x01}
+		}
+	}
+	logs := make([][]interface{}, len(r.Logs))
+	for i, l := range r.Logs {
+		logs[i] = []interface{}{l.Address, l.Topics, []byte(l.Data)}
+	}
+	enc, err := rlp.EncodeToBytes([]interface{}{state, uint64(r.CumulativeGasUsed), r.Bloom, logs})
+	if err != nil {
+		return nil, err
+	}
+	if r.Type != nil && *r.Type != 0 {
+		enc = append([]byte{byte(*r.Type)}, enc...)
+	}
+	return enc, nil
+}
+
+// LogProof is the proof of a transaction receipt on a foreign chain, the
+// arguments of verifyLog of the relay system contract.
+type LogProof struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxIndex     hexutil.Uint64 `json:"txIndex"`
+	Proof       hexutil.Bytes  `json:"proof"`
+}
+
+// ReceiptProof builds the proof of the receipt of a transaction from all the
+// receipts of its block.
+func (c *Client) ReceiptProof(ctx context.Context, txHash common.Hash) (*LogProof, error) {
+	var receipt *rpcReceipt
+	if err := c.c.CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("receipt of %x not found", txHash)
+	}
+	block, err := c.block(ctx, "eth_getBlockByHash", receipt.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	receipts := make([]*rpcReceipt, len(block.Transactions))
+	reqs := make([]rpc.BatchElem, len(block.Transactions))
+	for i, hash := range block.Transactions {
+		reqs[i] = rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[i]}
+	}
+	if err := c.c.BatchCallContext(ctx, reqs); err != nil {
+		return nil, err
+	}
+	encoded := make([][]byte, len(receipts))
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return nil, reqs[i].Error
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("receipt of %x not found", block.Transactions[i])
+		}
+		if encoded[i], err = receipts[i].encode(); err != nil {
+			return nil, err
+		}
+	}
+	root, proof, err := ReceiptProof(encoded, uint64(receipt.TransactionIndex))
+	if err != nil {
+		return nil, err
+	}
+	if root != block.ReceiptHash {
+		return nil, fmt.Errorf("receipts root mismatch: have %x, want %x", root, block.ReceiptHash)
+	}
+	return &LogProof{
+		BlockHash:   block.Hash,
+		BlockNumber: hexutil.Uint64(block.Number.ToInt().Uint64()),
+		TxIndex:     receipt.TransactionIndex,
+		Proof:       proof,
+	}, nil
+}
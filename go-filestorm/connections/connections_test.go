@@ -0,0 +1,210 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb/memorydb"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/rpc"
+	"github.com/filestorm/go-filestorm/trie"
+)
+
+func testReceipts(n int) types.Receipts {
+	receipts := make(types.Receipts, n)
+	for i := range receipts {
+		receipts[i] = &types.Receipt{
+			Status:            uint64(i % 2),
+			CumulativeGasUsed: uint64(21000 * (i + 1)),
+			Logs: []*types.Log{{
+				Address: common.BytesToAddress([]byte{byte(i)}),
+				Topics:  []common.Hash{crypto.Keccak256Hash([]byte{byte(i)})},
+				Data:    make([]byte, i%40),
+			}},
+			TxHash: crypto.Keccak256Hash([]byte("tx"), []byte{byte(i), byte(i >> 8)}),
+		}
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	return receipts
+}
+
+func TestReceiptProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 16, 17, 130, 300} {
+		var (
+			receipts = testReceipts(n)
+			encoded  = make([][]byte, n)
+		)
+		for i, receipt := range receipts {
+			encoded[i], _ = rlp.EncodeToBytes(receipt)
+		}
+		want := types.DeriveSha(receipts, crypto.Keccak256Hasher)
+		for _, index := range []uint64{0, uint64(n / 2), uint64(n - 1)} {
+			root, proof, err := ReceiptProof(encoded, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root != want {
+				t.Fatalf("%d receipts: root mismatch: have %x, want %x", n, root, want)
+			}
+			var nodes [][]byte
+			if err := rlp.DecodeBytes(proof, &nodes); err != nil {
+				t.Fatal(err)
+			}
+			proofDb := memorydb.New()
+			for _, node := range nodes {
+				proofDb.Put(crypto.Keccak256(node), node)
+			}
+			key, _ := rlp.EncodeToBytes(index)
+			value, _, err := trie.VerifyProof(root, key, proofDb)
+			if err != nil {
+				t.Fatalf("%d receipts: proof of %d invalid: %v", n, index, err)
+			}
+			if string(value) != string(encoded[index]) {
+				t.Fatalf("%d receipts: proven value of %d mismatch", n, index)
+			}
+		}
+	}
+	if _, _, err := ReceiptProof(nil, 0); err == nil {
+		t.Fatal("proof of missing receipt succeeded")
+	}
+}
+
+// fakeChain serves the JSON-RPC API of a foreign chain with a single block.
+type fakeChain struct {
+	header   *vm.RelayHeader
+	baseFee  *big.Int
+	receipts types.Receipts
+}
+
+func (c *fakeChain) ChainId() hexutil.Uint64     { return 5 }
+func (c *fakeChain) BlockNumber() hexutil.Uint64 { return hexutil.Uint64(c.header.Number.Uint64()) }
+
+func (c *fakeChain) GetBlockByNumber(number hexutil.Uint64, full bool) map[string]interface{} {
+	if uint64(number) != c.header.Number.Uint64() {
+		return nil
+	}
+	return c.block()
+}
+
+func (c *fakeChain) GetBlockByHash(hash common.Hash, full bool) map[string]interface{} {
+	if hash != c.header.Hash() {
+		return nil
+	}
+	return c.block()
+}
+
+func (c *fakeChain) block() map[string]interface{} {
+	h := c.header
+	txs := make([]common.Hash, len(c.receipts))
+	for i, receipt := range c.receipts {
+		txs[i] = receipt.TxHash
+	}
+	block := map[string]interface{}{
+		"hash":             h.Hash(),
+		"parentHash":       h.ParentHash,
+		"sha3Uncles":       h.UncleHash,
+		"miner":            h.Coinbase,
+		"stateRoot":        h.Root,
+		"transactionsRoot": h.TxHash,
+		"receiptsRoot":     h.ReceiptHash,
+		"logsBloom":        h.Bloom,
+		"difficulty":       (*hexutil.Big)(h.Difficulty),
+		"number":           (*hexutil.Big)(h.Number),
+		"gasLimit":         hexutil.Uint64(h.GasLimit),
+		"gasUsed":          hexutil.Uint64(h.GasUsed),
+		"timestamp":        hexutil.Uint64(h.Time),
+		"extraData":        hexutil.Bytes(h.Extra),
+		"mixHash":          h.MixDigest,
+		"nonce":            h.Nonce,
+		"transactions":     txs,
+	}
+	if c.baseFee != nil {
+		block["baseFeePerGas"] = (*hexutil.Big)(c.baseFee)
+	}
+	return block
+}
+
+func (c *fakeChain) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	for _, receipt := range c.receipts {
+		if receipt.TxHash == hash {
+			return receipt, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown transaction %x", hash)
+}
+
+func TestClient(t *testing.T) {
+	chain := &fakeChain{
+		header: &vm.RelayHeader{
+			ParentHash: common.Hash{1},
+			UncleHash:  crypto.Keccak256Hash([]byte{0xc0}),
+			Difficulty: big.NewInt(2),
+			Number:     big.NewInt(42),
+			GasLimit:   8000000,
+			Time:       1600000000,
+			Extra:      make([]byte, 97),
+		},
+		baseFee:  big.NewInt(7),
+		receipts: testReceipts(20),
+	}
+	enc, _ := rlp.EncodeToBytes(chain.baseFee)
+	chain.header.Rest = []rlp.RawValue{enc}
+	chain.header.ReceiptHash = types.DeriveSha(chain.receipts, crypto.Keccak256Hasher)
+	for i, receipt := range chain.receipts {
+		receipt.BlockHash, receipt.TransactionIndex = chain.header.Hash(), uint(i)
+		receipt.BlockNumber = chain.header.Number
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", chain); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	ctx := context.Background()
+	if id, err := client.ChainID(ctx); err != nil || id != 5 {
+		t.Fatalf("chain id mismatch: have %d, %v", id, err)
+	}
+	header, err := client.HeaderByNumber(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash() != chain.header.Hash() {
+		t.Fatalf("header hash mismatch: have %x, want %x", header.Hash(), chain.header.Hash())
+	}
+	// Unknown header fields change the hash.
+	chain.baseFee = big.NewInt(8)
+	if _, err := client.HeaderByNumber(ctx, 42); err == nil {
+		t.Fatal("header with mismatching hash accepted")
+	}
+	proof, err := client.ReceiptProof(ctx, chain.receipts[13].TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.BlockHash != chain.header.Hash() || proof.BlockNumber != 42 || proof.TxIndex != 13 {
+		t.Fatalf("proof mismatch: %+v", proof)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package connections
+
+import (
+	"fmt"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+// The receipts tries of foreign chains are hashed with Keccak256. The trie
+// package hashes with the hash function of the local chain, which may be SM3,
+// so the proofs are built by the minimal trie below.
+
+// trieEntry is a key, as nibbles, and value of a trie.
+type trieEntry struct {
+	key   []byte
+	value []byte
+}
+
+// ReceiptProof returns the root of the receipts trie of a block and the proof
+// of the receipt at index, the RLP list of the trie nodes on its path, as
+// verified by the relay system contract. Receipts are given in their consensus
+// encoding.
+func ReceiptProof(receipts [][]byte, index uint64) (common.Hash, []byte, error) {
+	if index >= uint64(len(receipts)) {
+		return common.Hash{}, nil, fmt.Errorf("receipt %d out of range, block has %d", index, len(receipts))
+	}
+	entries := make([]trieEntry, len(receipts))
+	for i, receipt := range receipts {
+		key, _ := rlp.EncodeToBytes(uint64(i))
+		entries[i] = trieEntry{keyNibbles(key), receipt}
+	}
+	key, _ := rlp.EncodeToBytes(index)
+	var nodes [][]byte
+	root := buildTrie(entries, 0, keyNibbles(key), &nodes)
+	proof, err := rlp.EncodeToBytes(nodes)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return crypto.Keccak256Hash(root), proof, nil
+}
+
+// buildTrie returns the encoding of the trie node holding entries, whose keys
+// share the first depth nibbles. If the node is on the path to the target key,
+// target is set and the hashed nodes of the path are appended to proof.
+func buildTrie(entries []trieEntry, depth int, target []byte, proof *[][]byte) []byte {
+	var node []interface{}
+	switch {
+	case len(entries) == 0:
+		return nil
+
+	case len(entries) == 1:
+		node = []interface{}{hexPrefix(entries[0].key[depth:], true), entries[0].value}
+
+	default:
+		if n := commonPrefix(entries, depth); n > 0 {
+			prefix := entries[0].key[depth : depth+n]
+			if !hasPrefix(target, entries[0].key[:depth+n]) {
+				target = nil
+			}
+			node = []interface{}{hexPrefix(prefix, false), nodeRef(buildTrie(entries, depth+n, target, proof))}
+			break
+		}
+		var (
+			children [16][]trieEntry
+			value    []byte
+		)
+		for _, entry := range entries {
+			if len(entry.key) == depth {
+				value = entry.value
+				continue
+			}
+			children[entry.key[depth]] = append(children[entry.key[depth]], entry)
+		}
+		node = make([]interface{}, 17)
+		for i, child := range children {
+			next := target
+			if len(target) <= depth || int(target[depth]) != i {
+				next = nil
+			}
+			node[i] = nodeRef(buildTrie(child, depth+1, next, proof))
+		}
+		node[16] = value
+	}
+	enc, _ := rlp.EncodeToBytes(node)
+	if target != nil && (len(enc) >= 32 || depth == 0) {
+		*proof = append(*proof, enc)
+	}
+	return enc
+}
+
+// nodeRef returns the reference to a child node from its parent: small nodes
+// are embedded, larger ones referenced by their hash.
+func nodeRef(enc []byte) interface{} {
+	switch {
+	case enc == nil:
+		return []byte{}
+	case len(enc) < 32:
+		return rlp.RawValue(enc)
+	default:
+		return crypto.Keccak256(enc)
+	}
+}
+
+func commonPrefix(entries []trieEntry, depth int) int {
+	first := entries[0].key[depth:]
+	n := len(first)
+	for _, entry := range entries[1:] {
+		key := entry.key[depth:]
+		if len(key) < n {
+			n = len(key)
+		}
+		for i := 0; i < n; i++ {
+			if key[i] != first[i] {
+				n = i
+				break
+			}
+		}
+	}
+	return n
+}
+
+func hasPrefix(key, prefix []byte) bool {
+	if len(key) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if key[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func keyNibbles(key []byte) []byte {
+	nibbles := make([]byte, len(key)*2)
+	for i, b := range key {
+		nibbles[i*2] = b / 16
+		nibbles[i*2+1] = b % 16
+	}
+	return nibbles
+}
+
+// hexPrefix encodes the nibbles of a leaf or extension node key.
+func hexPrefix(nibbles []byte, leaf bool) []byte {
+	var flag byte
+	if leaf {
+		flag = 2
+	}
+	buf := make([]byte, len(nibbles)/2+1)
+	if len(nibbles)%2 == 1 {
+		buf[0] = (flag+1)<<4 | nibbles[0]
+		nibbles = nibbles[1:]
+	} else {
+		buf[0] = flag << 4
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		buf[i/2+1] = nibbles[i]<<4 | nibbles[i+1]
+	}
+	return buf
+}
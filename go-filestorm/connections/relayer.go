@@ -0,0 +1,322 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package connections connects the chain to foreign EVM chains. A relayer tails
+// a foreign chain over JSON-RPC and submits its headers to the relay system
+// contract, which verifies them like a light client, so that contracts can
+// verify receipt proofs of foreign events without trusting an oracle.
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/internal/fstapi"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/p2p"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+const (
+	rpcTimeout     = 30 * time.Second
+	maxReorgDepth  = 256 // Foreign headers searched back for one known to the relay
+	maxSubmitBatch = 128 // Upper bound of the headers submitted per transaction
+)
+
+var relayABI abi.ABI
+
+func init() {
+	var err error
+	if relayABI, err = abi.JSON(strings.NewReader(vm.RelayABI)); err != nil {
+		panic(err)
+	}
+}
+
+// Config contains the settings of the relayer.
+type Config struct {
+	Endpoint string         `toml:",omitempty"` // JSON-RPC endpoint of the foreign chain
+	Relay    common.Hash    `toml:",omitempty"` // Relay of the foreign chain in the relay system contract
+	From     common.Address `toml:",omitempty"` // Unlocked account sending the header transactions
+	Batch    int            // Headers submitted per transaction
+	Interval time.Duration  // Time between polls of the foreign chain
+}
+
+// DefaultConfig contains the default settings of the relayer.
+var DefaultConfig = Config{
+	Batch:    32,
+	Interval: 15 * time.Second,
+}
+
+// Relayer submits the headers of a foreign chain to its relay.
+type Relayer struct {
+	config    *Config
+	backend   fstapi.Backend
+	nonceLock *fstapi.AddrLocker
+	client    *Client
+
+	lock    sync.Mutex
+	pending common.Hash // Last submitted transaction
+	foreign uint64      // Last seen head number of the foreign chain
+	checked bool        // Whether the chain id of the foreign chain matches the relay
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a relayer sending transactions through the given backend.
+func New(config *Config, backend fstapi.Backend) (*Relayer, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("no foreign chain endpoint")
+	}
+	if config.Relay == (common.Hash{}) {
+		return nil, errors.New("no relay id")
+	}
+	if config.Batch <= 0 || config.Batch > maxSubmitBatch {
+		return nil, fmt.Errorf("invalid batch size %d, want 1..%d", config.Batch, maxSubmitBatch)
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig.Interval
+	}
+	return &Relayer{
+		config:    config,
+		backend:   backend,
+		nonceLock: new(fstapi.AddrLocker),
+		quit:      make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, the relayer has no protocols.
+func (r *Relayer) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the relay RPC API.
+func (r *Relayer) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "relay",
+		Version:   "1.0",
+		Service:   &PublicRelayAPI{r},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, connecting to the foreign chain and starting
+// the relay loop.
+func (r *Relayer) Start(server *p2p.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	client, err := Dial(ctx, r.config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to foreign chain: %v", err)
+	}
+	r.client = client
+
+	r.wg.Add(1)
+	go r.loop()
+	log.Info("Started foreign chain relayer", "relay", r.config.Relay, "endpoint", r.config.Endpoint, "from", r.config.From)
+	return nil
+}
+
+// Stop implements node.Service, terminating the relay loop.
+func (r *Relayer) Stop() error {
+	close(r.quit)
+	r.wg.Wait()
+	r.client.Close()
+	log.Info("Foreign chain relayer stopped")
+	return nil
+}
+
+func (r *Relayer) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.relay(); err != nil {
+			log.Warn("Failed to relay foreign headers", "relay", r.config.Relay, "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// relay submits the next batch of foreign headers unless a submission is still
+// pending.
+func (r *Relayer) relay() error {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.pending != (common.Hash{}) && r.backend.GetPoolTransaction(r.pending) != nil {
+		return nil
+	}
+	statedb, _, err := r.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return err
+	}
+	relay, ok := vm.ReadRelay(statedb, r.config.Relay)
+	if !ok {
+		return fmt.Errorf("relay %x not registered", r.config.Relay)
+	}
+	if !r.checked {
+		id, err := r.client.ChainID(ctx)
+		if err != nil {
+			return err
+		}
+		if id != relay.ChainID {
+			return fmt.Errorf("foreign chain id mismatch: have %d, relay of %d", id, relay.ChainID)
+		}
+		r.checked = true
+	}
+	if r.foreign, err = r.client.BlockNumber(ctx); err != nil {
+		return err
+	}
+	// Find the last header of the foreign canonical chain known to the relay,
+	// the relay head itself unless the foreign chain reorganised.
+	head, _ := vm.ReadRelayRecord(statedb, relay.ID, relay.Head)
+	number := head.Number
+	if number > r.foreign {
+		number = r.foreign
+	}
+	for depth := 0; ; depth++ {
+		header, err := r.client.HeaderByNumber(ctx, number)
+		if err != nil {
+			return err
+		}
+		if _, ok := vm.ReadRelayRecord(statedb, relay.ID, header.Hash()); ok {
+			break
+		}
+		if depth == maxReorgDepth || number == 0 {
+			return fmt.Errorf("no known foreign header within %d blocks of %d", depth, head.Number)
+		}
+		number--
+	}
+	var headers []*vm.RelayHeader
+	for n := number + 1; n <= r.foreign && len(headers) < r.config.Batch; n++ {
+		header, err := r.client.HeaderByNumber(ctx, n)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, header)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	blob, err := rlp.EncodeToBytes(headers)
+	if err != nil {
+		return err
+	}
+	input, err := relayABI.Pack("submit", relay.ID, blob)
+	if err != nil {
+		return err
+	}
+	data := hexutil.Bytes(input)
+	hash, err := fstapi.NewPublicTransactionPoolAPI(r.backend, r.nonceLock).SendTransaction(ctx, fstapi.SendTxArgs{
+		From: r.config.From,
+		To:   &vm.RelayAddress,
+		Data: &data,
+	})
+	if err != nil {
+		return err
+	}
+	r.pending = hash
+	log.Info("Submitted foreign headers", "relay", relay.ID, "from", number+1, "count", len(headers), "foreign", r.foreign, "tx", hash)
+	return nil
+}
+
+// PublicRelayAPI provides the relay status and helpers to register relays and
+// prove foreign events.
+type PublicRelayAPI struct {
+	r *Relayer
+}
+
+// Status contains the progress of the relayer.
+type Status struct {
+	Relay       common.Hash    `json:"relay"`
+	Head        common.Hash    `json:"head"`        // Head of the relay
+	Number      hexutil.Uint64 `json:"number"`      // Number of the head of the relay
+	ForeignHead hexutil.Uint64 `json:"foreignHead"` // Last seen head number of the foreign chain
+	Pending     *common.Hash   `json:"pending"`     // Submission waiting to be mined
+}
+
+// Status returns the progress of the relayer.
+func (api *PublicRelayAPI) Status(ctx context.Context) (*Status, error) {
+	statedb, _, err := api.r.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	status := &Status{Relay: api.r.config.Relay}
+	if relay, ok := vm.ReadRelay(statedb, api.r.config.Relay); ok {
+		head, _ := vm.ReadRelayRecord(statedb, relay.ID, relay.Head)
+		status.Head, status.Number = head.Hash, hexutil.Uint64(head.Number)
+	}
+	api.r.lock.Lock()
+	defer api.r.lock.Unlock()
+
+	status.ForeignHead = hexutil.Uint64(api.r.foreign)
+	if api.r.pending != (common.Hash{}) && api.r.backend.GetPoolTransaction(api.r.pending) != nil {
+		pending := api.r.pending
+		status.Pending = &pending
+	}
+	return status, nil
+}
+
+// Header returns the RLP encoded header of the foreign chain at number, the
+// checkpoint to register a relay with.
+func (api *PublicRelayAPI) Header(ctx context.Context, number hexutil.Uint64) (hexutil.Bytes, error) {
+	header, err := api.r.client.HeaderByNumber(ctx, uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(header)
+}
+
+// ReceiptProof returns the proof of the receipt of a foreign transaction, to
+// verify its logs with the relay system contract.
+func (api *PublicRelayAPI) ReceiptProof(ctx context.Context, txHash common.Hash) (*LogProof, error) {
+	return api.r.client.ReceiptProof(ctx, txHash)
+}
+
+// VerifyLog verifies the log of a foreign transaction against the relay at
+// the latest block, like the relay system contract does.
+func (api *PublicRelayAPI) VerifyLog(ctx context.Context, proof LogProof, logIndex hexutil.Uint64) (map[string]interface{}, error) {
+	statedb, _, err := api.r.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	l, err := vm.VerifyRelayLog(statedb, api.r.config.Relay, proof.BlockHash, uint64(proof.TxIndex), proof.Proof, uint64(logIndex))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"address": l.Address,
+		"topics":  l.Topics,
+		"data":    hexutil.Bytes(l.Data),
+	}, nil
+}
@@ -40,7 +40,6 @@ import (
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/filestorm/go-filestorm/rpc"
 	lru "github.com/hashicorp/golang-lru"
-	"golang.org/x/crypto/sha3"
 )
 
 const (
@@ -61,8 +60,6 @@ var (
 	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new signer
 	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a signer.
 
-	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
-
 	diffInTurn = big.NewInt(2) // Block difficulty for in-turn signatures
 	diffNoTurn = big.NewInt(1) // Block difficulty for out-of-turn signatures
 )
@@ -141,7 +138,8 @@ var (
 // backing account.
 type SignerFn func(accounts.Account, string, []byte) ([]byte, error)
 
-// ecrecover extracts the Filestorm account address from a signed header.
+// ecrecover extracts the Filestorm account address from a signed header, derived
+// with the hash function of the header's chain.
 func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
 	// If the signature's already cached, return that
 	hash := header.Hash()
@@ -160,7 +158,7 @@ func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, er
 		return common.Address{}, err
 	}
 	var signer common.Address
-	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	copy(signer[:], header.Hasher().Hash(pubkey[1:]).Bytes()[12:])
 
 	sigcache.Add(hash, signer)
 	return signer, nil
@@ -172,6 +170,8 @@ type Clique struct {
 	config *params.CliqueConfig // Consensus engine configuration parameters
 	db     fstdb.Database       // Database to store and retrieve snapshot checkpoints
 
+	hasher crypto.Hasher // Hash function of the chain, for the empty uncle hash
+
 	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 
@@ -186,8 +186,9 @@ type Clique struct {
 }
 
 // New creates a Clique proof-of-authority consensus engine with the initial
-// signers set to the ones provided by the user.
-func New(config *params.CliqueConfig, db fstdb.Database) *Clique {
+// signers set to the ones provided by the user, for a chain hashing with the
+// given hash function.
+func New(config *params.CliqueConfig, db fstdb.Database, hasher crypto.Hasher) *Clique {
 	// Set any missing consensus parameters to their defaults
 	conf := *config
 	if conf.Epoch == 0 {
@@ -203,6 +204,7 @@ func New(config *params.CliqueConfig, db fstdb.Database) *Clique {
 		recents:    recents,
 		signatures: signatures,
 		proposals:  make(map[common.Address]bool),
+		hasher:     hasher,
 	}
 }
 
@@ -284,7 +286,7 @@ func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.Header,
 		return errInvalidMixDigest
 	}
 	// Ensure that the block doesn't contain any uncles which are meaningless in PoA
-	if header.UncleHash != uncleHash {
+	if header.UncleHash != types.CalcUncleHash(nil, c.hasher) {
 		return errInvalidUncleHash
 	}
 	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
@@ -555,7 +557,7 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
-	header.UncleHash = types.CalcUncleHash(nil)
+	header.UncleHash = types.CalcUncleHash(nil, c.hasher)
 }
 
 // FinalizeAndAssemble implements consensus.Engine, ensuring no uncles are set,
@@ -563,7 +565,7 @@ func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, sta
 func (c *Clique) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
-	header.UncleHash = types.CalcUncleHash(nil)
+	header.UncleHash = types.CalcUncleHash(nil, c.hasher)
 
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts), nil
@@ -703,9 +705,10 @@ func (c *Clique) GetSigners(chain consensus.ChainReader, header *types.Header) (
 	return snap.signers(), nil
 }
 
-// SealHash returns the hash of a block prior to it being sealed.
+// SealHash returns the hash of a block prior to it being sealed, with the hash
+// function of the header's chain.
 func SealHash(header *types.Header) (hash common.Hash) {
-	hasher := sha3.NewLegacyKeccak256()
+	hasher := header.Hasher().New()
 	encodeSigHeader(hasher, header)
 	hasher.Sum(hash[:0])
 	return hash
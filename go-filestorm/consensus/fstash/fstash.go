@@ -679,3 +679,16 @@ func (fstash *Fstash) GetSigners(chain consensus.ChainReader, header *types.Head
 func SeedHash(block uint64) []byte {
 	return seedHash(block)
 }
+
+// EpochLength is the number of blocks sharing a verification cache and mining
+// dataset.
+const EpochLength = epochLength
+
+// CacheSize returns the size in bytes of the verification cache of the epoch
+// of a block, or 0 if the epoch is beyond the precomputed sizes.
+func CacheSize(block uint64) uint64 {
+	if block/epochLength >= maxEpoch {
+		return 0
+	}
+	return cacheSize(block)
+}
@@ -296,7 +296,7 @@ func benchReadChain(b *testing.B, full bool, count uint64) {
 			header := chain.GetHeaderByNumber(n)
 			if full {
 				hash := header.Hash()
-				rawdb.ReadBody(db, hash, n)
+				rawdb.ReadBody(db, hash, n, crypto.Keccak256Hasher)
 				rawdb.ReadReceipts(db, hash, n, chain.Config())
 			}
 		}
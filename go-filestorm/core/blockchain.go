@@ -203,7 +203,7 @@ func NewBlockChain(db fstdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:    cacheConfig,
 		db:             db,
 		triegc:         prque.New(nil),
-		stateCache:     state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
+		stateCache:     state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit, chainConfig.Hasher()),
 		quit:           make(chan struct{}),
 		shouldPreserve: shouldPreserve,
 		bodyCache:      bodyCache,
@@ -236,7 +236,7 @@ func NewBlockChain(db fstdb.Database, cacheConfig *CacheConfig, chainConfig *par
 
 	// Initialize the chain with ancient data if it isn't empty.
 	if bc.empty() {
-		rawdb.InitDatabaseFromFreezer(bc.db)
+		rawdb.InitDatabaseFromFreezer(bc.db, bc.chainConfig.Hasher())
 	}
 
 	if err := bc.loadLastState(); err != nil {
@@ -654,7 +654,7 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 	if number == nil {
 		return nil
 	}
-	body := rawdb.ReadBody(bc.db, hash, *number)
+	body := rawdb.ReadBody(bc.db, hash, *number, bc.chainConfig.Hasher())
 	if body == nil {
 		return nil
 	}
@@ -726,7 +726,7 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	if block, ok := bc.blockCache.Get(hash); ok {
 		return block.(*types.Block)
 	}
-	block := rawdb.ReadBlock(bc.db, hash, number)
+	block := rawdb.ReadBlock(bc.db, hash, number, bc.chainConfig.Hasher())
 	if block == nil {
 		return nil
 	}
@@ -1049,7 +1049,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 					break
 				}
 				h := rawdb.ReadCanonicalHash(bc.db, frozen)
-				b := rawdb.ReadBlock(bc.db, h, frozen)
+				b := rawdb.ReadBlock(bc.db, h, frozen, bc.chainConfig.Hasher())
 				size += rawdb.WriteAncientBlock(bc.db, b, rawdb.ReadReceipts(bc.db, h, frozen, bc.chainConfig), rawdb.ReadTd(bc.db, h, frozen))
 				count += 1
 
@@ -2184,7 +2184,7 @@ func (bc *BlockChain) GetTransactionLookup(hash common.Hash) *rawdb.LegacyTxLook
 	if lookup, exist := bc.txLookupCache.Get(hash); exist {
 		return lookup.(*rawdb.LegacyTxLookupEntry)
 	}
-	tx, blockHash, blockNumber, txIndex := rawdb.ReadTransaction(bc.db, hash)
+	tx, blockHash, blockNumber, txIndex := rawdb.ReadTransaction(bc.db, hash, bc.chainConfig.Hasher())
 	if tx == nil {
 		return nil
 	}
@@ -27,6 +27,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/event"
 	"github.com/filestorm/go-filestorm/log"
@@ -68,6 +69,7 @@ type ChainIndexerChain interface {
 // affect already finished sections.
 type ChainIndexer struct {
 	chainDb  fstdb.Database      // Chain database to index the data from
+	hasher   crypto.Hasher       // Hash function of the chain
 	indexDb  fstdb.Database      // Prefixed table-view of the db to write index metadata into
 	backend  ChainIndexerBackend // Background processor generating the index data content
 	children []*ChainIndexer     // Child indexers to cascade chain updates to
@@ -96,10 +98,12 @@ type ChainIndexer struct {
 
 // NewChainIndexer creates a new chain indexer to do background processing on
 // chain segments of a given size after certain number of confirmations passed.
-// The throttling parameter might be used to prevent database thrashing.
-func NewChainIndexer(chainDb fstdb.Database, indexDb fstdb.Database, backend ChainIndexerBackend, section, confirm uint64, throttling time.Duration, kind string) *ChainIndexer {
+// The throttling parameter might be used to prevent database thrashing. Headers
+// are hashed with the given hash function of the chain.
+func NewChainIndexer(chainDb fstdb.Database, indexDb fstdb.Database, backend ChainIndexerBackend, section, confirm uint64, throttling time.Duration, kind string, hasher crypto.Hasher) *ChainIndexer {
 	c := &ChainIndexer{
 		chainDb:     chainDb,
+		hasher:      hasher,
 		indexDb:     indexDb,
 		backend:     backend,
 		update:      make(chan struct{}, 1),
@@ -397,7 +401,7 @@ func (c *ChainIndexer) processSection(section uint64, lastHead common.Hash) (com
 		if hash == (common.Hash{}) {
 			return common.Hash{}, fmt.Errorf("canonical block #%d unknown", number)
 		}
-		header := rawdb.ReadHeader(c.chainDb, hash, number)
+		header := rawdb.ReadHeader(c.chainDb, hash, number, c.hasher)
 		if header == nil {
 			return common.Hash{}, fmt.Errorf("block #%d [%x…] not found", number, hash[:4])
 		} else if header.ParentHash != lastHead {
@@ -27,6 +27,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 )
 
 // Runs multiple tests with randomized parameters.
@@ -59,7 +60,7 @@ func testChainIndexer(t *testing.T, count int) {
 			confirmsReq = uint64(rand.Intn(10))
 		)
 		backends[i] = &testChainIndexBackend{t: t, processCh: make(chan uint64)}
-		backends[i].indexer = NewChainIndexer(db, rawdb.NewTable(db, string([]byte{byte(i)})), backends[i], sectionSize, confirmsReq, 0, fmt.Sprintf("indexer-%d", i))
+		backends[i].indexer = NewChainIndexer(db, rawdb.NewTable(db, string([]byte{byte(i)})), backends[i], sectionSize, confirmsReq, 0, fmt.Sprintf("indexer-%d", i), crypto.Keccak256Hasher)
 
 		if sections, _, _ := backends[i].indexer.Sections(); sections != 0 {
 			t.Fatalf("Canonical section count mismatch: have %v, want %v", sections, 0)
@@ -177,8 +177,9 @@ func SetupGenesisBlockWithOverride(db fstdb.Database, genesis *Genesis, override
 
 	// We have the genesis block in database(perhaps in ancient database)
 	// but the corresponding state is missing.
-	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithCache(db, 0)); err != nil {
+	hasher := rawdb.ReadChainConfig(db, stored).Hasher()
+	header := rawdb.ReadHeader(db, stored, 0, hasher)
+	if _, err := state.New(header.Root, state.NewDatabaseWithCache(db, 0, hasher)); err != nil {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
@@ -259,7 +260,8 @@ func (g *Genesis) ToBlock(db fstdb.Database) *types.Block {
 	if db == nil {
 		db = rawdb.NewMemoryDatabase()
 	}
-	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	hasher := g.Config.Hasher()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabaseWithCache(db, 0, hasher))
 	for addr, account := range g.Alloc {
 		statedb.AddBalance(addr, account.Balance)
 		statedb.SetCode(addr, account.Code)
@@ -282,6 +284,7 @@ func (g *Genesis) ToBlock(db fstdb.Database) *types.Block {
 		Coinbase:   g.Coinbase,
 		Root:       root,
 	}
+	head.SetHasher(hasher)
 	if g.GasLimit == 0 {
 		head.GasLimit = params.GenesisGasLimit
 	}
@@ -26,6 +26,7 @@ import (
 	"github.com/filestorm/go-filestorm/consensus/fstash"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/params"
 )
@@ -155,10 +156,47 @@ func TestSetupGenesis(t *testing.T) {
 			t.Errorf("%s: returned hash %s, want %s", test.name, hash.Hex(), test.wantHash.Hex())
 		} else if err == nil {
 			// Check database content.
-			stored := rawdb.ReadBlock(db, test.wantHash, 0)
+			stored := rawdb.ReadBlock(db, test.wantHash, 0, crypto.Keccak256Hasher)
 			if stored.Hash() != test.wantHash {
 				t.Errorf("%s: block in DB has hash %s, want %s", test.name, stored.Hash(), test.wantHash)
 			}
 		}
 	}
 }
+
+func TestSetupGenesisSM3(t *testing.T) {
+	config := *params.AllFstashProtocolChanges
+	config.HashFunction = params.HashFunctionSM3
+	genesis := &Genesis{
+		Config: &config,
+		Alloc:  GenesisAlloc{{1}: {Balance: big.NewInt(1), Storage: map[common.Hash]common.Hash{{1}: {1}}}},
+	}
+	keccakHash := (&Genesis{Config: params.AllFstashProtocolChanges, Alloc: genesis.Alloc}).ToBlock(nil).Hash()
+
+	db := rawdb.NewMemoryDatabase()
+	_, hash, err := SetupGenesisBlock(db, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == keccakHash {
+		t.Fatal("SM3 genesis hash equals the Keccak256 genesis hash")
+	}
+	if block := rawdb.ReadBlock(db, hash, 0, crypto.SM3Hasher); block == nil || block.Hash() != hash {
+		t.Fatal("SM3 genesis block not stored under its hash")
+	}
+	// A Keccak256 chain set up in the same process is not affected
+	keccakDB := rawdb.NewMemoryDatabase()
+	if _, stored, err := SetupGenesisBlock(keccakDB, &Genesis{Config: params.AllFstashProtocolChanges, Alloc: genesis.Alloc}); err != nil || stored != keccakHash {
+		t.Fatalf("keccak chain: have %x (%v), want %x", stored, err, keccakHash)
+	}
+	// Reopening the chain uses the stored config
+	if _, stored, err := SetupGenesisBlock(db, nil); err != nil || stored != hash {
+		t.Fatalf("reopen: have %x (%v), want %x", stored, err, hash)
+	}
+	// The hash function cannot be changed once the chain exists
+	keccakConfig := config
+	keccakConfig.HashFunction = ""
+	if _, _, err := SetupGenesisBlock(db, &Genesis{Config: &keccakConfig, Alloc: genesis.Alloc}); err == nil {
+		t.Fatal("expected an error switching the hash function")
+	}
+}
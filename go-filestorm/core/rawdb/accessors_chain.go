@@ -179,8 +179,11 @@ func ReadHeaderRLP(db fstdb.Reader, hash common.Hash, number uint64) rlp.RawValu
 	// comparison is necessary since ancient database only maintains
 	// the canonical data.
 	data, _ := db.Ancient(freezerHeaderTable, number)
-	if len(data) > 0 && crypto.Keccak256Hash(data) == hash {
-		return data
+	if len(data) > 0 {
+		h, _ := db.Ancient(freezerHashTable, number)
+		if common.BytesToHash(h) == hash {
+			return data
+		}
 	}
 	// Then try to look up the data in leveldb.
 	data, _ = db.Get(headerKey(number, hash))
@@ -192,8 +195,11 @@ func ReadHeaderRLP(db fstdb.Reader, hash common.Hash, number uint64) rlp.RawValu
 	// but when we reach into leveldb, the data was already moved. That would
 	// result in a not found error.
 	data, _ = db.Ancient(freezerHeaderTable, number)
-	if len(data) > 0 && crypto.Keccak256Hash(data) == hash {
-		return data
+	if len(data) > 0 {
+		h, _ := db.Ancient(freezerHashTable, number)
+		if common.BytesToHash(h) == hash {
+			return data
+		}
 	}
 	return nil // Can't find the data anywhere.
 }
@@ -209,8 +215,9 @@ func HasHeader(db fstdb.Reader, hash common.Hash, number uint64) bool {
 	return true
 }
 
-// ReadHeader retrieves the block header corresponding to the hash.
-func ReadHeader(db fstdb.Reader, hash common.Hash, number uint64) *types.Header {
+// ReadHeader retrieves the block header corresponding to the hash, hashing
+// with the given hash function of the chain.
+func ReadHeader(db fstdb.Reader, hash common.Hash, number uint64, hasher crypto.Hasher) *types.Header {
 	data := ReadHeaderRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
@@ -220,6 +227,7 @@ func ReadHeader(db fstdb.Reader, hash common.Hash, number uint64) *types.Header
 		log.Error("Invalid block header RLP", "hash", hash, "err", err)
 		return nil
 	}
+	header.SetHasher(hasher)
 	return header
 }
 
@@ -309,8 +317,9 @@ func HasBody(db fstdb.Reader, hash common.Hash, number uint64) bool {
 	return true
 }
 
-// ReadBody retrieves the block body corresponding to the hash.
-func ReadBody(db fstdb.Reader, hash common.Hash, number uint64) *types.Body {
+// ReadBody retrieves the block body corresponding to the hash, hashing with the
+// given hash function of the chain.
+func ReadBody(db fstdb.Reader, hash common.Hash, number uint64, hasher crypto.Hasher) *types.Body {
 	data := ReadBodyRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
@@ -320,6 +329,7 @@ func ReadBody(db fstdb.Reader, hash common.Hash, number uint64) *types.Body {
 		log.Error("Invalid block body RLP", "hash", hash, "err", err)
 		return nil
 	}
+	body.SetHasher(hasher)
 	return body
 }
 
@@ -480,7 +490,7 @@ func ReadReceipts(db fstdb.Reader, hash common.Hash, number uint64, config *para
 	if receipts == nil {
 		return nil
 	}
-	body := ReadBody(db, hash, number)
+	body := ReadBody(db, hash, number, config.Hasher())
 	if body == nil {
 		log.Error("Missing body but have receipt", "hash", hash, "number", number)
 		return nil
@@ -522,12 +532,12 @@ func DeleteReceipts(db fstdb.KeyValueWriter, hash common.Hash, number uint64) {
 //
 // Note, due to concurrent download of header and block body the header and thus
 // canonical hash can be stored in the database but the body data not (yet).
-func ReadBlock(db fstdb.Reader, hash common.Hash, number uint64) *types.Block {
-	header := ReadHeader(db, hash, number)
+func ReadBlock(db fstdb.Reader, hash common.Hash, number uint64, hasher crypto.Hasher) *types.Block {
+	header := ReadHeader(db, hash, number, hasher)
 	if header == nil {
 		return nil
 	}
-	body := ReadBody(db, hash, number)
+	body := ReadBody(db, hash, number, hasher)
 	if body == nil {
 		return nil
 	}
@@ -591,23 +601,23 @@ func DeleteBlockWithoutNumber(db fstdb.KeyValueWriter, hash common.Hash, number
 // FindCommonAncestor returns the last common ancestor of two block headers
 func FindCommonAncestor(db fstdb.Reader, a, b *types.Header) *types.Header {
 	for bn := b.Number.Uint64(); a.Number.Uint64() > bn; {
-		a = ReadHeader(db, a.ParentHash, a.Number.Uint64()-1)
+		a = ReadHeader(db, a.ParentHash, a.Number.Uint64()-1, a.Hasher())
 		if a == nil {
 			return nil
 		}
 	}
 	for an := a.Number.Uint64(); an < b.Number.Uint64(); {
-		b = ReadHeader(db, b.ParentHash, b.Number.Uint64()-1)
+		b = ReadHeader(db, b.ParentHash, b.Number.Uint64()-1, b.Hasher())
 		if b == nil {
 			return nil
 		}
 	}
 	for a.Hash() != b.Hash() {
-		a = ReadHeader(db, a.ParentHash, a.Number.Uint64()-1)
+		a = ReadHeader(db, a.ParentHash, a.Number.Uint64()-1, a.Hasher())
 		if a == nil {
 			return nil
 		}
-		b = ReadHeader(db, b.ParentHash, b.Number.Uint64()-1)
+		b = ReadHeader(db, b.ParentHash, b.Number.Uint64()-1, b.Hasher())
 		if b == nil {
 			return nil
 		}
@@ -19,6 +19,7 @@ package rawdb
 import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/params"
@@ -69,8 +70,8 @@ func DeleteTxLookupEntry(db fstdb.KeyValueWriter, hash common.Hash) {
 }
 
 // ReadTransaction retrieves a specific transaction from the database, along with
-// its added positional metadata.
-func ReadTransaction(db fstdb.Reader, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
+// its added positional metadata, hashing with the given hash function of the chain.
+func ReadTransaction(db fstdb.Reader, hash common.Hash, hasher crypto.Hasher) (*types.Transaction, common.Hash, uint64, uint64) {
 	blockNumber := ReadTxLookupEntry(db, hash)
 	if blockNumber == nil {
 		return nil, common.Hash{}, 0, 0
@@ -79,7 +80,7 @@ func ReadTransaction(db fstdb.Reader, hash common.Hash) (*types.Transaction, com
 	if blockHash == (common.Hash{}) {
 		return nil, common.Hash{}, 0, 0
 	}
-	body := ReadBody(db, blockHash, *blockNumber)
+	body := ReadBody(db, blockHash, *blockNumber, hasher)
 	if body == nil {
 		log.Error("Transaction referenced missing", "number", blockNumber, "hash", blockHash)
 		return nil, common.Hash{}, 0, 0
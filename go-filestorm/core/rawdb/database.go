@@ -111,6 +111,26 @@ func NewDatabaseWithFreezer(db fstdb.KeyValueStore, freezer string, namespace st
 	if err != nil {
 		return nil, err
 	}
+	fdb, err := NewDatabaseWithAncientStore(db, frdb)
+	if err != nil {
+		frdb.Close()
+		return nil, err
+	}
+	return fdb, nil
+}
+
+// NewFreezer creates an idle chain freezer storing ancient chain segments in
+// append-only flat files inside datadir. It allows other ancient stores to
+// reuse the flat file tables for the data they keep locally.
+func NewFreezer(datadir string, namespace string) (fstdb.AncientStore, error) {
+	return newFreezer(datadir, namespace)
+}
+
+// NewDatabaseWithAncientStore creates a high level database on top of a given
+// key-value data store with an arbitrary ancient store moving immutable chain
+// segments into cold storage. The ancient store is closed together with the
+// returned database, but not if the combination is rejected.
+func NewDatabaseWithAncientStore(db fstdb.KeyValueStore, frdb fstdb.AncientStore) (fstdb.Database, error) {
 	// Since the freezer can be stored separately from the user's key-value database,
 	// there's a fairly high probability that the user requests invalid combinations
 	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
@@ -173,7 +193,7 @@ func NewDatabaseWithFreezer(db fstdb.KeyValueStore, freezer string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
-	go frdb.freeze(db)
+	go freeze(frdb, db)
 
 	return &freezerdb{
 		KeyValueStore: db,
@@ -219,6 +239,24 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
+// NewLevelDBDatabaseWithAncientStore creates a persistent key-value database
+// with an arbitrary ancient store moving immutable chain segments into cold
+// storage. The ancient store is closed if the database cannot be opened.
+func NewLevelDBDatabaseWithAncientStore(file string, cache int, handles int, ancients fstdb.AncientStore, namespace string) (fstdb.Database, error) {
+	kvdb, err := leveldb.New(file, cache, handles, namespace)
+	if err != nil {
+		ancients.Close()
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithAncientStore(kvdb, ancients)
+	if err != nil {
+		ancients.Close()
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // InspectDatabase traverses the entire database and checks the size
 // of all different categories of data.
 func InspectDatabase(db fstdb.Database) error {
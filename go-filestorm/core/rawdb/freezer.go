@@ -251,10 +251,19 @@ func (f *freezer) Sync() error {
 //
 // This functionality is deliberately broken off from block importing to avoid
 // incurring additional data shuffling delays on block propagation.
-func (f *freezer) freeze(db fstdb.KeyValueStore) {
+//
+// The thread only relies on the generic ancient store interface, so it can feed
+// any ancient store, not just the flat file freezer.
+func freeze(f fstdb.AncientStore, db fstdb.KeyValueStore) {
 	nfdb := &nofreezedb{KeyValueStore: db}
 
 	for {
+		frozen, err := f.Ancients()
+		if err != nil {
+			log.Error("Ancient item count unavailable", "err", err)
+			time.Sleep(freezerRecheckInterval)
+			continue
+		}
 		// Retrieve the freezing threshold.
 		hash := ReadHeadBlockHash(nfdb)
 		if hash == (common.Hash{}) {
@@ -274,60 +283,60 @@ func (f *freezer) freeze(db fstdb.KeyValueStore) {
 			time.Sleep(freezerRecheckInterval)
 			continue
 
-		case *number-params.ImmutabilityThreshold <= f.frozen:
-			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", f.frozen)
+		case *number-params.ImmutabilityThreshold <= frozen:
+			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", frozen)
 			time.Sleep(freezerRecheckInterval)
 			continue
 		}
-		head := ReadHeader(nfdb, hash, *number)
-		if head == nil {
+		if len(ReadHeaderRLP(nfdb, hash, *number)) == 0 {
 			log.Error("Current full block unavailable", "number", *number, "hash", hash)
 			time.Sleep(freezerRecheckInterval)
 			continue
 		}
 		// Seems we have data ready to be frozen, process in usable batches
 		limit := *number - params.ImmutabilityThreshold
-		if limit-f.frozen > freezerBatchLimit {
-			limit = f.frozen + freezerBatchLimit
+		if limit-frozen > freezerBatchLimit {
+			limit = frozen + freezerBatchLimit
 		}
 		var (
 			start    = time.Now()
-			first    = f.frozen
+			first    = frozen
 			ancients = make([]common.Hash, 0, limit)
 		)
-		for f.frozen < limit {
+		for frozen < limit {
 			// Retrieves all the components of the canonical block
-			hash := ReadCanonicalHash(nfdb, f.frozen)
+			hash := ReadCanonicalHash(nfdb, frozen)
 			if hash == (common.Hash{}) {
-				log.Error("Canonical hash missing, can't freeze", "number", f.frozen)
+				log.Error("Canonical hash missing, can't freeze", "number", frozen)
 				break
 			}
-			header := ReadHeaderRLP(nfdb, hash, f.frozen)
+			header := ReadHeaderRLP(nfdb, hash, frozen)
 			if len(header) == 0 {
-				log.Error("Block header missing, can't freeze", "number", f.frozen, "hash", hash)
+				log.Error("Block header missing, can't freeze", "number", frozen, "hash", hash)
 				break
 			}
-			body := ReadBodyRLP(nfdb, hash, f.frozen)
+			body := ReadBodyRLP(nfdb, hash, frozen)
 			if len(body) == 0 {
-				log.Error("Block body missing, can't freeze", "number", f.frozen, "hash", hash)
+				log.Error("Block body missing, can't freeze", "number", frozen, "hash", hash)
 				break
 			}
-			receipts := ReadReceiptsRLP(nfdb, hash, f.frozen)
+			receipts := ReadReceiptsRLP(nfdb, hash, frozen)
 			if len(receipts) == 0 {
-				log.Error("Block receipts missing, can't freeze", "number", f.frozen, "hash", hash)
+				log.Error("Block receipts missing, can't freeze", "number", frozen, "hash", hash)
 				break
 			}
-			td := ReadTdRLP(nfdb, hash, f.frozen)
+			td := ReadTdRLP(nfdb, hash, frozen)
 			if len(td) == 0 {
-				log.Error("Total difficulty missing, can't freeze", "number", f.frozen, "hash", hash)
+				log.Error("Total difficulty missing, can't freeze", "number", frozen, "hash", hash)
 				break
 			}
-			log.Trace("Deep froze ancient block", "number", f.frozen, "hash", hash)
+			log.Trace("Deep froze ancient block", "number", frozen, "hash", hash)
 			// Inject all the components into the relevant data tables
-			if err := f.AppendAncient(f.frozen, hash[:], header, body, receipts, td); err != nil {
+			if err := f.AppendAncient(frozen, hash[:], header, body, receipts, td); err != nil {
 				break
 			}
 			ancients = append(ancients, hash)
+			frozen++
 		}
 		// Batch of blocks have been frozen, flush them before wiping from leveldb
 		if err := f.Sync(); err != nil {
@@ -347,7 +356,7 @@ func (f *freezer) freeze(db fstdb.KeyValueStore) {
 		}
 		batch.Reset()
 		// Wipe out side chain also.
-		for number := first; number < f.frozen; number++ {
+		for number := first; number < frozen; number++ {
 			// Always keep the genesis block in active database
 			if number != 0 {
 				for _, hash := range ReadAllHashes(db, number) {
@@ -360,7 +369,7 @@ func (f *freezer) freeze(db fstdb.KeyValueStore) {
 		}
 		// Log something friendly for the user
 		context := []interface{}{
-			"block", f.frozen, "elapsed", common.PrettyDuration(time.Since(start)), "blks", f.frozen - first,
+			"block", frozen, "elapsed", common.PrettyDuration(time.Since(start)), "blks", frozen - first,
 		}
 		if n := len(ancients); n > 0 {
 			context = append(context, []interface{}{"hash", ancients[n-1]}...)
@@ -368,7 +377,7 @@ func (f *freezer) freeze(db fstdb.KeyValueStore) {
 		log.Info("Reimported chain segment", context...)
 
 		// Avoid database thrashing with tiny writes
-		if f.frozen-first < freezerBatchLimit {
+		if frozen-first < freezerBatchLimit {
 			time.Sleep(freezerRecheckInterval)
 		}
 	}
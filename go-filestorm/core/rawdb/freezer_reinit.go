@@ -21,6 +21,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/prque"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/log"
 	"runtime"
@@ -32,8 +33,8 @@ import (
 // InitDatabaseFromFreezer reinitializes an empty database from a previous batch
 // of frozen ancient blocks. The method iterates over all the frozen blocks and
 // injects into the database the block hash->number mappings and the transaction
-// lookup entries.
-func InitDatabaseFromFreezer(db fstdb.Database) error {
+// lookup entries, hashing with the given hash function of the chain.
+func InitDatabaseFromFreezer(db fstdb.Database, hasher crypto.Hasher) error {
 	// If we can't access the freezer or it's empty, abort
 	frozen, err := db.Ancients()
 	if err != nil || frozen == 0 {
@@ -58,7 +59,7 @@ func InitDatabaseFromFreezer(db fstdb.Database) error {
 				// Retrieve the block from the freezer. If successful, pre-cache
 				// the block hash and the individual transaction hashes for storing
 				// into the database.
-				block := ReadBlock(db, ReadCanonicalHash(db, n), n)
+				block := ReadBlock(db, ReadCanonicalHash(db, n), n, hasher)
 				if block != nil {
 					block.Hash()
 					for _, tx := range block.Transactions() {
@@ -19,6 +19,7 @@ package state
 import (
 	"fmt"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/trie"
 
@@ -49,6 +50,9 @@ type Database interface {
 
 	// TrieDB retrieves the low level trie database used for data storage.
 	TrieDB() *trie.Database
+
+	// Hasher returns the hash function of the tries.
+	Hasher() crypto.Hasher
 }
 
 // Trie is a Filestorm Merkle Patricia trie.
@@ -100,16 +104,16 @@ type Trie interface {
 // concurrent use, but does not retain any recent trie nodes in memory. To keep some
 // historical state in memory, use the NewDatabaseWithCache constructor.
 func NewDatabase(db fstdb.Database) Database {
-	return NewDatabaseWithCache(db, 0)
+	return NewDatabaseWithCache(db, 0, crypto.Keccak256Hasher)
 }
 
-// NewDatabaseWithCache creates a backing store for state. The returned database
-// is safe for concurrent use and retains a lot of collapsed RLP trie nodes in a
-// large memory cache.
-func NewDatabaseWithCache(db fstdb.Database, cache int) Database {
+// NewDatabaseWithCache creates a backing store for the state of a chain hashing
+// with the given hash function. The returned database is safe for concurrent use
+// and retains a lot of collapsed RLP trie nodes in a large memory cache.
+func NewDatabaseWithCache(db fstdb.Database, cache int, hasher crypto.Hasher) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
-		db:            trie.NewDatabaseWithCache(db, cache),
+		db:            trie.NewDatabaseWithHasher(db, cache, hasher),
 		codeSizeCache: csc,
 	}
 }
@@ -161,3 +165,8 @@ func (db *cachingDB) ContractCodeSize(addrHash, codeHash common.Hash) (int, erro
 func (db *cachingDB) TrieDB() *trie.Database {
 	return db.db
 }
+
+// Hasher returns the hash function of the tries.
+func (db *cachingDB) Hasher() crypto.Hasher {
+	return db.db.Hasher()
+}
@@ -98,7 +98,7 @@ func (s *StateDB) dump(c collector, excludeCode, excludeStorage, excludeMissingP
 			panic(err)
 		}
 		addr := common.BytesToAddress(s.trie.GetKey(it.Key))
-		obj := newObject(nil, addr, data)
+		obj := newObject(s, addr, data)
 		account := DumpAccount{
 			Balance:  data.Balance.String(),
 			Nonce:    data.Nonce,
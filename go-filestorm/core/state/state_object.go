@@ -23,6 +23,7 @@ import (
 	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/metrics"
 	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/trie"
 	"io"
 	"math/big"
 	"time"
@@ -114,13 +115,14 @@ func newObject(db *StateDB, address common.Address, data Account) *stateObject {
 	if data.CodeHash == nil {
 		data.CodeHash = emptyCodeHash
 	}
+	hasher := db.db.Hasher()
 	if data.Root == (common.Hash{}) {
-		data.Root = emptyRoot
+		data.Root = trie.EmptyRoot(hasher)
 	}
 	return &stateObject{
 		db:             db,
 		address:        address,
-		addrHash:       crypto.Keccak256Hash(address[:]),
+		addrHash:       hasher.Hash(address[:]),
 		data:           data,
 		originStorage:  make(Storage),
 		pendingStorage: make(Storage),
@@ -19,13 +19,15 @@ package state
 import (
 	"bytes"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/filestorm/go-filestorm/trie"
 )
 
-// NewStateSync create a new state trie download scheduler.
-func NewStateSync(root common.Hash, database fstdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+// NewStateSync create a new state trie download scheduler for a chain hashing
+// with the given hash function.
+func NewStateSync(root common.Hash, database fstdb.KeyValueReader, bloom *trie.SyncBloom, hasher crypto.Hasher) *trie.Sync {
 	var syncer *trie.Sync
 	callback := func(leaf []byte, parent common.Hash) error {
 		var obj Account
@@ -36,6 +38,6 @@ func NewStateSync(root common.Hash, database fstdb.KeyValueReader, bloom *trie.S
 		syncer.AddRawEntry(common.BytesToHash(obj.CodeHash), 64, parent)
 		return nil
 	}
-	syncer = trie.NewSync(root, database, callback, bloom)
+	syncer = trie.NewSyncWithHasher(root, database, callback, bloom, hasher)
 	return syncer
 }
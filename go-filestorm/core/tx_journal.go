@@ -23,6 +23,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/rlp"
 )
@@ -44,13 +45,15 @@ func (*devNull) Close() error                      { return nil }
 // created transactions to allow non-executed ones to survive node restarts.
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
+	hasher crypto.Hasher  // Hash function of the chain of the transactions
 	writer io.WriteCloser // Output stream to write new transactions into
 }
 
 // newTxJournal creates a new transaction journal to
-func newTxJournal(path string) *txJournal {
+func newTxJournal(path string, hasher crypto.Hasher) *txJournal {
 	return &txJournal{
-		path: path,
+		path:   path,
+		hasher: hasher,
 	}
 }
 
@@ -104,6 +107,7 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 			break
 		}
 		// New transaction parsed, queue up for later, import if threshold is reached
+		tx.SetHasher(journal.hasher)
 		total++
 
 		if batch = append(batch, tx); batch.Len() > 1024 {
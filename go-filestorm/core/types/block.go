@@ -22,21 +22,41 @@ import (
 	"fmt"
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/trie"
 	"io"
 	"math/big"
 	"reflect"
 	"sync/atomic"
 	"time"
 
-	"golang.org/x/crypto/sha3"
 )
 
 var (
-	EmptyRootHash  = DeriveSha(Transactions{})
-	EmptyUncleHash = rlpHash([]*Header(nil))
+	// EmptyRootHash and EmptyUncleHash are the transaction, receipt and uncle
+	// hashes of empty blocks of chains hashing with Keccak256.
+	EmptyRootHash  = DeriveSha(Transactions{}, crypto.Keccak256Hasher)
+	EmptyUncleHash = rlpHash(crypto.Keccak256Hasher, []*Header(nil))
+
+	emptyUncleHashSM3 = rlpHash(crypto.SM3Hasher, []*Header(nil))
 )
 
+// EmptyRootHashOf returns the transaction and receipt hash of empty blocks of
+// a chain hashing with the given hash function.
+func EmptyRootHashOf(hasher crypto.Hasher) common.Hash {
+	return trie.EmptyRoot(hasher)
+}
+
+// EmptyUncleHashOf returns the uncle hash of blocks without uncles of a chain
+// hashing with the given hash function.
+func EmptyUncleHashOf(hasher crypto.Hasher) common.Hash {
+	if hasher.IsSM3() {
+		return emptyUncleHashSM3
+	}
+	return EmptyUncleHash
+}
+
 // A BlockNonce is a 64-bit hash which proves (combined with the
 // mix-hash) that a sufficient amount of computation has been carried
 // out on a block.
@@ -83,6 +103,8 @@ type Header struct {
 	Extra       []byte         `json:"extraData"        gencodec:"required"`
 	MixDigest   common.Hash    `json:"mixHash"`
 	Nonce       BlockNonce     `json:"nonce"`
+
+	hasher crypto.Hasher // hash function of the chain, not part of the header data
 }
 
 // field type overrides for gencodec
@@ -96,10 +118,22 @@ type headerMarshaling struct {
 	Hash       common.Hash `json:"hash"` // adds call to Hash() in MarshalJSON
 }
 
-// Hash returns the block hash of the header, which is simply the keccak256 hash of its
-// RLP encoding.
+// Hash returns the block hash of the header, which is simply the hash of its
+// RLP encoding with the hash function of its chain.
 func (h *Header) Hash() common.Hash {
-	return rlpHash(h)
+	return rlpHash(h.hasher, h)
+}
+
+// Hasher returns the hash function of the chain of the header.
+func (h *Header) Hasher() crypto.Hasher {
+	return h.hasher
+}
+
+// SetHasher sets the hash function of the chain of the header, Keccak256 unless
+// set. Headers created or decoded for a chain with another hash function have
+// to be given it before they are hashed.
+func (h *Header) SetHasher(hasher crypto.Hasher) {
+	h.hasher = hasher
 }
 
 var headerSize = common.StorageSize(reflect.TypeOf(Header{}).Size())
@@ -129,8 +163,8 @@ func (h *Header) SanityCheck() error {
 	return nil
 }
 
-func rlpHash(x interface{}) (h common.Hash) {
-	hw := sha3.NewLegacyKeccak256()
+func rlpHash(hasher crypto.Hasher, x interface{}) (h common.Hash) {
+	hw := hasher.New()
 	rlp.Encode(hw, x)
 	hw.Sum(h[:0])
 	return h
@@ -143,6 +177,15 @@ type Body struct {
 	Uncles       []*Header
 }
 
+// SetHasher sets the hash function of the chain of a decoded body on its
+// transactions and uncles.
+func (b *Body) SetHasher(hasher crypto.Hasher) {
+	Transactions(b.Transactions).SetHasher(hasher)
+	for _, uncle := range b.Uncles {
+		uncle.SetHasher(hasher)
+	}
+}
+
 // Block represents an entire block in the Filestorm blockchain.
 type Block struct {
 	header       *Header
@@ -198,30 +241,31 @@ type storageblock struct {
 //
 // The values of TxHash, UncleHash, ReceiptHash and Bloom in header
 // are ignored and set to values derived from the given txs, uncles
-// and receipts.
+// and receipts, with the hash function of the header.
 func NewBlock(header *Header, txs []*Transaction, uncles []*Header, receipts []*Receipt) *Block {
 	b := &Block{header: CopyHeader(header), td: new(big.Int)}
+	hasher := header.hasher
 
 	// TODO: panic if len(txs) != len(receipts)
 	if len(txs) == 0 {
-		b.header.TxHash = EmptyRootHash
+		b.header.TxHash = EmptyRootHashOf(hasher)
 	} else {
-		b.header.TxHash = DeriveSha(Transactions(txs))
+		b.header.TxHash = DeriveSha(Transactions(txs), hasher)
 		b.transactions = make(Transactions, len(txs))
 		copy(b.transactions, txs)
 	}
 
 	if len(receipts) == 0 {
-		b.header.ReceiptHash = EmptyRootHash
+		b.header.ReceiptHash = EmptyRootHashOf(hasher)
 	} else {
-		b.header.ReceiptHash = DeriveSha(Receipts(receipts))
+		b.header.ReceiptHash = DeriveSha(Receipts(receipts), hasher)
 		b.header.Bloom = CreateBloom(receipts)
 	}
 
 	if len(uncles) == 0 {
-		b.header.UncleHash = EmptyUncleHash
+		b.header.UncleHash = EmptyUncleHashOf(hasher)
 	} else {
-		b.header.UncleHash = CalcUncleHash(uncles)
+		b.header.UncleHash = CalcUncleHash(uncles, hasher)
 		b.uncles = make([]*Header, len(uncles))
 		for i := range uncles {
 			b.uncles[i] = CopyHeader(uncles[i])
@@ -320,6 +364,20 @@ func (b *Block) Extra() []byte            { return common.CopyBytes(b.header.Ext
 
 func (b *Block) Header() *Header { return CopyHeader(b.header) }
 
+// Hasher returns the hash function of the chain of the block.
+func (b *Block) Hasher() crypto.Hasher { return b.header.hasher }
+
+// SetHasher sets the hash function of the chain of a decoded block on its
+// header, uncles and transactions. It has to be called before the block is
+// hashed.
+func (b *Block) SetHasher(hasher crypto.Hasher) {
+	b.header.SetHasher(hasher)
+	for _, uncle := range b.uncles {
+		uncle.SetHasher(hasher)
+	}
+	b.transactions.SetHasher(hasher)
+}
+
 // Body returns the non-header content of the block.
 func (b *Block) Body() *Body { return &Body{b.transactions, b.uncles} }
 
@@ -348,11 +406,13 @@ func (c *writeCounter) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
-func CalcUncleHash(uncles []*Header) common.Hash {
+// CalcUncleHash returns the uncle hash of a block with the given uncles on a
+// chain hashing with the given hash function.
+func CalcUncleHash(uncles []*Header, hasher crypto.Hasher) common.Hash {
 	if len(uncles) == 0 {
-		return EmptyUncleHash
+		return EmptyUncleHashOf(hasher)
 	}
-	return rlpHash(uncles)
+	return rlpHash(hasher, uncles)
 }
 
 // WithSeal returns a new block with the data from b but the header replaced with
@@ -381,7 +441,7 @@ func (b *Block) WithBody(transactions []*Transaction, uncles []*Header) *Block {
 	return block
 }
 
-// Hash returns the keccak256 hash of b's header.
+// Hash returns the hash of b's header.
 // The hash is computed on the first call and cached thereafter.
 func (b *Block) Hash() common.Hash {
 	if hash := b.hash.Load(); hash != nil {
@@ -19,6 +19,7 @@ package types
 import (
 	"bytes"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/rlp"
 	"math/big"
 	"reflect"
@@ -66,7 +67,7 @@ func TestBlockEncoding(t *testing.T) {
 
 func TestUncleHash(t *testing.T) {
 	uncles := make([]*Header, 0)
-	h := CalcUncleHash(uncles)
+	h := CalcUncleHash(uncles, crypto.Keccak256Hasher)
 	exp := common.HexToHash("1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347")
 	if h != exp {
 		t.Fatalf("empty uncle hash is wrong, got %x != %x", h, exp)
@@ -76,6 +77,6 @@ func BenchmarkUncleHash(b *testing.B) {
 	uncles := make([]*Header, 0)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		CalcUncleHash(uncles)
+		CalcUncleHash(uncles, crypto.Keccak256Hasher)
 	}
 }
@@ -19,6 +19,7 @@ package types
 import (
 	"bytes"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/filestorm/go-filestorm/trie"
 )
@@ -28,9 +29,11 @@ type DerivableList interface {
 	GetRlp(i int) []byte
 }
 
-func DeriveSha(list DerivableList) common.Hash {
+// DeriveSha returns the root hash of the trie of the list items, hashed with
+// the given hash function.
+func DeriveSha(list DerivableList, hasher crypto.Hasher) common.Hash {
 	keybuf := new(bytes.Buffer)
-	trie := new(trie.Trie)
+	trie := trie.NewEmpty(hasher)
 	for i := 0; i < list.Len(); i++ {
 		keybuf.Reset()
 		rlp.Encode(keybuf, uint(i))
@@ -26,7 +26,6 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/hexutil"
-	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/params"
 	"github.com/filestorm/go-filestorm/rlp"
 )
@@ -314,7 +313,7 @@ func (r Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, num
 		if txs[i].To() == nil {
 			// Deriving the signer is expensive, only do if it's actually needed
 			from, _ := Sender(signer, txs[i])
-			r[i].ContractAddress = crypto.CreateAddress(from, txs[i].Nonce())
+			r[i].ContractAddress = config.Hasher().CreateAddress(from, txs[i].Nonce())
 		}
 		// The used gas can be calculated based on previous r
 		if i == 0 {
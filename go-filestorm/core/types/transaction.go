@@ -36,7 +36,8 @@ var (
 )
 
 type Transaction struct {
-	data txdata
+	data   txdata
+	hasher crypto.Hasher // hash function of the chain
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -189,17 +190,29 @@ func (tx *Transaction) To() *common.Address {
 	return &to
 }
 
-// Hash hashes the RLP encoding of tx.
+// Hash hashes the RLP encoding of tx with the hash function of its chain.
 // It uniquely identifies the transaction.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	v := rlpHash(tx.hasher, tx)
 	tx.hash.Store(v)
 	return v
 }
 
+// Hasher returns the hash function of the chain of the transaction.
+func (tx *Transaction) Hasher() crypto.Hasher {
+	return tx.hasher
+}
+
+// SetHasher sets the hash function of the chain of the transaction, Keccak256
+// unless set or signed for a chain with another hash function. Transactions
+// decoded for such a chain have to be given it before they are hashed.
+func (tx *Transaction) SetHasher(hasher crypto.Hasher) {
+	tx.hasher = hasher
+}
+
 // Size returns the true RLP encoded storage size of the transaction, either by
 // encoding and returning it, or returning a previsouly cached value.
 func (tx *Transaction) Size() common.StorageSize {
@@ -240,7 +253,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
+	cpy := &Transaction{data: tx.data, hasher: signer.Hasher()}
 	cpy.data.R, cpy.data.S, cpy.data.V = r, s, v
 	return cpy, nil
 }
@@ -273,6 +286,13 @@ func (s Transactions) GetRlp(i int) []byte {
 	return enc
 }
 
+// SetHasher sets the hash function of the chain of decoded transactions.
+func (s Transactions) SetHasher(hasher crypto.Hasher) {
+	for _, tx := range s {
+		tx.SetHasher(hasher)
+	}
+}
+
 // TxDifference returns a new set which is the difference between a and b.
 func TxDifference(a, b Transactions) Transactions {
 	keep := make(Transactions, 0, len(a))
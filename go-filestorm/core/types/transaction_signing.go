@@ -24,6 +24,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/params"
 )
 
@@ -41,21 +42,58 @@ type sigCache struct {
 // MakeSigner returns a Signer based on the given chain config and block number.
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	var signer Signer
-	switch {
+	switch hasher := config.Hasher(); {
+	case config.IsSM2():
+		signer = NewSM2SignerWithHasher(config.ChainID, hasher)
 	case config.IsEIP155(blockNumber):
-		signer = NewEIP155Signer(config.ChainID)
+		signer = NewEIP155SignerWithHasher(config.ChainID, hasher)
 	case config.IsHomestead(blockNumber):
-		signer = HomesteadSigner{}
+		signer = NewHomesteadSignerWithHasher(hasher)
 	default:
-		signer = FrontierSigner{}
+		signer = FrontierSigner{hasher}
 	}
 	return signer
 }
 
+// NewChainSigner returns the signer for new transactions on a chain with the
+// given config, regardless of the block number.
+func NewChainSigner(config *params.ChainConfig) Signer {
+	if config.IsSM2() {
+		return NewSM2SignerWithHasher(config.ChainID, config.Hasher())
+	}
+	return NewEIP155SignerWithHasher(config.ChainID, config.Hasher())
+}
+
+// NewTxSigner returns the signer to derive the sender of an already signed
+// transaction on a chain with the given config. A nil config is treated as a
+// secp256k1 chain hashing with Keccak256.
+func NewTxSigner(config *params.ChainConfig, tx *Transaction) Signer {
+	var hasher crypto.Hasher
+	if config != nil {
+		hasher = config.Hasher()
+	}
+	switch {
+	case config != nil && config.IsSM2():
+		return NewSM2SignerWithHasher(config.ChainID, hasher)
+	case tx.Protected():
+		return NewEIP155SignerWithHasher(tx.ChainId(), hasher)
+	default:
+		return NewHomesteadSignerWithHasher(hasher)
+	}
+}
+
 // SignTx signs the transaction using the given signer and private key
 func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
 	h := s.Hash(tx)
-	sig, err := crypto.Sign(h[:], prv)
+	var (
+		sig []byte
+		err error
+	)
+	if _, ok := s.(SM2Signer); ok {
+		sig, err = sm2.SignDigest(h[:], prv)
+	} else {
+		sig, err = crypto.Sign(h[:], prv)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -100,40 +138,56 @@ type Signer interface {
 	Hash(tx *Transaction) common.Hash
 	// Equal returns true if the given signer is the same as the receiver.
 	Equal(Signer) bool
+	// Hasher returns the hash function of the chain, used for the hash to be
+	// signed and the sender address.
+	Hasher() crypto.Hasher
 }
 
 // EIP155Transaction implements Signer using the EIP155 rules.
 type EIP155Signer struct {
 	chainId, chainIdMul *big.Int
+	hasher              crypto.Hasher
 }
 
+// NewEIP155Signer returns an EIP155 signer for a chain hashing with Keccak256.
 func NewEIP155Signer(chainId *big.Int) EIP155Signer {
+	return NewEIP155SignerWithHasher(chainId, crypto.Keccak256Hasher)
+}
+
+// NewEIP155SignerWithHasher returns an EIP155 signer for a chain hashing with
+// the given hash function.
+func NewEIP155SignerWithHasher(chainId *big.Int, hasher crypto.Hasher) EIP155Signer {
 	if chainId == nil {
 		chainId = new(big.Int)
 	}
 	return EIP155Signer{
 		chainId:    chainId,
 		chainIdMul: new(big.Int).Mul(chainId, big.NewInt(2)),
+		hasher:     hasher,
 	}
 }
 
 func (s EIP155Signer) Equal(s2 Signer) bool {
 	eip155, ok := s2.(EIP155Signer)
-	return ok && eip155.chainId.Cmp(s.chainId) == 0
+	return ok && eip155.chainId.Cmp(s.chainId) == 0 && eip155.hasher == s.hasher
+}
+
+func (s EIP155Signer) Hasher() crypto.Hasher {
+	return s.hasher
 }
 
 var big8 = big.NewInt(8)
 
 func (s EIP155Signer) Sender(tx *Transaction) (common.Address, error) {
 	if !tx.Protected() {
-		return HomesteadSigner{}.Sender(tx)
+		return NewHomesteadSignerWithHasher(s.hasher).Sender(tx)
 	}
 	if tx.ChainId().Cmp(s.chainId) != 0 {
 		return common.Address{}, ErrInvalidChainId
 	}
 	V := new(big.Int).Sub(tx.data.V, s.chainIdMul)
 	V.Sub(V, big8)
-	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, V, true)
+	return recoverPlain(s.hasher, s.Hash(tx), tx.data.R, tx.data.S, V, true)
 }
 
 // SignatureValues returns signature values. This signature
@@ -153,7 +207,7 @@ func (s EIP155Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (s EIP155Signer) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(s.hasher, []interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
 		tx.data.GasLimit,
@@ -164,13 +218,63 @@ func (s EIP155Signer) Hash(tx *Transaction) common.Hash {
 	})
 }
 
+// SM2Signer implements Signer for chains that sign transactions with SM2
+// instead of secp256k1. Hashes and V values follow EIP155 for protected and
+// the homestead rules for unprotected transactions, the sender is recovered
+// from an sm2p256v1 signature.
+type SM2Signer struct{ EIP155Signer }
+
+// NewSM2Signer returns an SM2 signer for a chain hashing with Keccak256.
+func NewSM2Signer(chainId *big.Int) SM2Signer {
+	return SM2Signer{NewEIP155Signer(chainId)}
+}
+
+// NewSM2SignerWithHasher returns an SM2 signer for a chain hashing with the
+// given hash function.
+func NewSM2SignerWithHasher(chainId *big.Int, hasher crypto.Hasher) SM2Signer {
+	return SM2Signer{NewEIP155SignerWithHasher(chainId, hasher)}
+}
+
+func (s SM2Signer) Equal(s2 Signer) bool {
+	sm2Signer, ok := s2.(SM2Signer)
+	return ok && sm2Signer.chainId.Cmp(s.chainId) == 0 && sm2Signer.hasher == s.hasher
+}
+
+func (s SM2Signer) Sender(tx *Transaction) (common.Address, error) {
+	if !tx.Protected() {
+		V := new(big.Int).Sub(tx.data.V, big.NewInt(27))
+		return recoverSM2(s.hasher, FrontierSigner{s.hasher}.Hash(tx), tx.data.R, tx.data.S, V)
+	}
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	V := new(big.Int).Sub(tx.data.V, s.chainIdMul)
+	V.Sub(V, big.NewInt(35))
+	return recoverSM2(s.hasher, s.EIP155Signer.Hash(tx), tx.data.R, tx.data.S, V)
+}
+
+// Hash returns the hash to be signed by the sender. Without a chain id it is
+// the homestead hash, so unprotected transactions can be recovered.
+func (s SM2Signer) Hash(tx *Transaction) common.Hash {
+	if s.chainId.Sign() == 0 {
+		return FrontierSigner{s.hasher}.Hash(tx)
+	}
+	return s.EIP155Signer.Hash(tx)
+}
+
 // HomesteadTransaction implements TransactionInterface using the
 // homestead rules.
 type HomesteadSigner struct{ FrontierSigner }
 
+// NewHomesteadSignerWithHasher returns a homestead signer for a chain hashing
+// with the given hash function, the zero value signs for Keccak256 chains.
+func NewHomesteadSignerWithHasher(hasher crypto.Hasher) HomesteadSigner {
+	return HomesteadSigner{FrontierSigner{hasher}}
+}
+
 func (s HomesteadSigner) Equal(s2 Signer) bool {
-	_, ok := s2.(HomesteadSigner)
-	return ok
+	hs, ok := s2.(HomesteadSigner)
+	return ok && hs.hasher == s.hasher
 }
 
 // SignatureValues returns signature values. This signature
@@ -180,14 +284,22 @@ func (hs HomesteadSigner) SignatureValues(tx *Transaction, sig []byte) (r, s, v
 }
 
 func (hs HomesteadSigner) Sender(tx *Transaction) (common.Address, error) {
-	return recoverPlain(hs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, true)
+	return recoverPlain(hs.hasher, hs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, true)
 }
 
-type FrontierSigner struct{}
+// FrontierSigner implements Signer using the frontier rules. The zero value
+// signs for chains hashing with Keccak256.
+type FrontierSigner struct {
+	hasher crypto.Hasher
+}
 
 func (s FrontierSigner) Equal(s2 Signer) bool {
-	_, ok := s2.(FrontierSigner)
-	return ok
+	fs, ok := s2.(FrontierSigner)
+	return ok && fs.hasher == s.hasher
+}
+
+func (s FrontierSigner) Hasher() crypto.Hasher {
+	return s.hasher
 }
 
 // SignatureValues returns signature values. This signature
@@ -205,7 +317,7 @@ func (fs FrontierSigner) SignatureValues(tx *Transaction, sig []byte) (r, s, v *
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (fs FrontierSigner) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(fs.hasher, []interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
 		tx.data.GasLimit,
@@ -216,10 +328,10 @@ func (fs FrontierSigner) Hash(tx *Transaction) common.Hash {
 }
 
 func (fs FrontierSigner) Sender(tx *Transaction) (common.Address, error) {
-	return recoverPlain(fs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, false)
+	return recoverPlain(fs.hasher, fs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, false)
 }
 
-func recoverPlain(sighash common.Hash, R, S, Vb *big.Int, homestead bool) (common.Address, error) {
+func recoverPlain(hasher crypto.Hasher, sighash common.Hash, R, S, Vb *big.Int, homestead bool) (common.Address, error) {
 	if Vb.BitLen() > 8 {
 		return common.Address{}, ErrInvalidSig
 	}
@@ -242,10 +354,26 @@ func recoverPlain(sighash common.Hash, R, S, Vb *big.Int, homestead bool) (commo
 		return common.Address{}, errors.New("invalid public key")
 	}
 	var addr common.Address
-	copy(addr[:], crypto.Keccak256(pub[1:])[12:])
+	copy(addr[:], hasher.Hash(pub[1:]).Bytes()[12:])
 	return addr, nil
 }
 
+func recoverSM2(hasher crypto.Hasher, sighash common.Hash, R, S, V *big.Int) (common.Address, error) {
+	if V.Sign() < 0 || V.Cmp(common.Big1) > 0 || R.BitLen() > 256 || S.BitLen() > 256 {
+		return common.Address{}, ErrInvalidSig
+	}
+	sig := make([]byte, sm2.SignatureLength)
+	R.FillBytes(sig[:32])
+	S.FillBytes(sig[32:64])
+	sig[64] = byte(V.Uint64())
+
+	pub, err := sm2.RecoverPubkey(sighash[:], sig)
+	if err != nil {
+		return common.Address{}, ErrInvalidSig
+	}
+	return hasher.PubkeyToAddress(*pub), nil
+}
+
 // deriveChainId derives the chain id from the given v parameter
 func deriveChainId(v *big.Int) *big.Int {
 	if v.BitLen() <= 64 {
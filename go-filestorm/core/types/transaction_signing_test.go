@@ -17,11 +17,13 @@
 package types
 
 import (
+	"crypto/rand"
 	"math/big"
 	"testing"
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/rlp"
 )
 
@@ -44,6 +46,38 @@ func TestEIP155Signing(t *testing.T) {
 	}
 }
 
+func TestSM2Signing(t *testing.T) {
+	key, _ := sm2.GenerateKey(rand.Reader)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	for _, chainId := range []*big.Int{nil, big.NewInt(18)} {
+		signer := NewSM2Signer(chainId)
+		tx, err := SignTx(NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Protected() != (chainId != nil) {
+			t.Errorf("chain id %v: protected %v", chainId, tx.Protected())
+		}
+		from, err := Sender(signer, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from != addr {
+			t.Errorf("exected from and address to be equal. Got %x want %x", from, addr)
+		}
+		// A secp256k1 signer must not recover the SM2 sender
+		if from, err := Sender(NewEIP155Signer(chainId), tx); err == nil && from == addr {
+			t.Errorf("chain id %v: secp256k1 signer recovered the SM2 sender", chainId)
+		}
+	}
+
+	tx, _ := SignTx(NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil), NewSM2Signer(big.NewInt(18)), key)
+	if _, err := Sender(NewSM2Signer(big.NewInt(19)), tx); err != ErrInvalidChainId {
+		t.Errorf("expected %v, got %v", ErrInvalidChainId, err)
+	}
+}
+
 func TestEIP155ChainId(t *testing.T) {
 	key, _ := crypto.GenerateKey()
 	addr := crypto.PubkeyToAddress(key.PublicKey)
@@ -0,0 +1,361 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math/big"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+// CollectionsAddress is the address of the collections system contract, a
+// typed record store shared by all applications on the chain. The contract is
+// implemented natively and called through the Solidity ABI in CollectionsABI
+// once the chain reaches its collections block.
+var CollectionsAddress = common.HexToAddress("0x0000000000000000000000000000000000001001")
+
+// Kinds of collection records. The contract stores the values as opaque bytes,
+// their encoding is defined by the collections package.
+const (
+	CollectionKV uint8 = iota
+	CollectionFile
+	CollectionEncrypted
+)
+
+// Permissions granted by the access list of a collection record.
+const (
+	CollectionRead uint8 = 1 << iota
+	CollectionWrite
+)
+
+// CollectionsABI is the ABI of the collections system contract. Records are
+// addressed by their owner, collection and key. Only the owner may create a
+// record, every write of the owner replaces the access list. Writers granted
+// by the access list may replace the value. A record granting read access to anyone is only readable
+// by the owner and the readers through the contract; as all chain state is
+// public, the access list doesn't protect the content from off-chain readers.
+const CollectionsABI = `[
+	{"type":"function","name":"put","inputs":[{"name":"owner","type":"address"},{"name":"collection","type":"string"},{"name":"key","type":"string"},{"name":"kind","type":"uint8"},{"name":"value","type":"bytes"},{"name":"acl","type":"address[]"},{"name":"permissions","type":"uint8[]"}],"outputs":[]},
+	{"type":"function","name":"get","constant":true,"inputs":[{"name":"owner","type":"address"},{"name":"collection","type":"string"},{"name":"key","type":"string"}],"outputs":[{"name":"kind","type":"uint8"},{"name":"value","type":"bytes"},{"name":"writer","type":"address"},{"name":"blockNumber","type":"uint64"},{"name":"timestamp","type":"uint64"}]},
+	{"type":"event","name":"Put","inputs":[{"name":"id","type":"bytes32","indexed":true},{"name":"owner","type":"address","indexed":true},{"name":"writer","type":"address","indexed":true},{"name":"kind","type":"uint8","indexed":false},{"name":"collection","type":"string","indexed":false},{"name":"key","type":"string","indexed":false}]}
+]`
+
+var collectionsABI abi.ABI
+
+func init() {
+	var err error
+	if collectionsABI, err = abi.JSON(strings.NewReader(CollectionsABI)); err != nil {
+		panic(err)
+	}
+}
+
+// CollectionGrant is an entry of the access list of a collection record.
+type CollectionGrant struct {
+	Address     common.Address
+	Permissions uint8
+}
+
+// CollectionRecord is a record of the collections system contract.
+type CollectionRecord struct {
+	ID          common.Hash
+	Owner       common.Address
+	Kind        uint8
+	Writer      common.Address // Account that last wrote the value
+	BlockNumber uint64         // Block of the last write
+	Timestamp   uint64         // Time of the last write
+	Collection  string
+	Key         string
+	Value       []byte
+	ACL         []CollectionGrant
+}
+
+// Allowed reports whether addr holds the permission on the record. The owner
+// holds all permissions, everyone may read records without read grants.
+func (r *CollectionRecord) Allowed(addr common.Address, permission uint8) bool {
+	if addr == r.Owner {
+		return true
+	}
+	restricted := permission != CollectionRead
+	for _, grant := range r.ACL {
+		if grant.Address == addr && grant.Permissions&permission != 0 {
+			return true
+		}
+		if grant.Permissions&CollectionRead != 0 {
+			restricted = true
+		}
+	}
+	return !restricted
+}
+
+// Storage words of a record, the blobs and the access list are stored like
+// Solidity dynamic arrays: the length in the word itself and the elements from
+// the hash of the word onwards.
+const (
+	recordOwner byte = iota
+	recordInfo       // kind, block number and timestamp of the last write
+	recordWriter
+	recordCollection
+	recordKey
+	recordValue
+	recordACL
+)
+
+// CollectionRecordID returns the identifier of the record of owner under the
+// given collection and key.
+func CollectionRecordID(owner common.Address, collection, key string) common.Hash {
+	return crypto.Keccak256Hash(owner[:], crypto.Keccak256([]byte(collection)), crypto.Keccak256([]byte(key)))
+}
+
+// recordSlot returns the storage slot of the i'th word of the record id.
+func recordSlot(id common.Hash, i byte) common.Hash {
+	return crypto.Keccak256Hash(id[:], []byte{i})
+}
+
+// ownerIndexSlot returns the storage slot of the list of records of owner.
+func ownerIndexSlot(owner common.Address) common.Hash {
+	return crypto.Keccak256Hash(owner[:])
+}
+
+// arraySlot returns the storage slot of the i'th element of the dynamic array
+// whose length is stored in slot.
+func arraySlot(slot common.Hash, i uint64) common.Hash {
+	base := new(big.Int).SetBytes(crypto.Keccak256(slot[:]))
+	return common.BigToHash(base.Add(base, new(big.Int).SetUint64(i)))
+}
+
+// words returns the number of storage words holding n bytes.
+func words(n int) uint64 {
+	return (uint64(n) + 31) / 32
+}
+
+func readLength(db StateDB, slot common.Hash) uint64 {
+	return db.GetState(CollectionsAddress, slot).Big().Uint64()
+}
+
+func readBlob(db StateDB, slot common.Hash) []byte {
+	size := readLength(db, slot)
+	blob := make([]byte, 0, words(int(size))*32)
+	for i := uint64(0); i < words(int(size)); i++ {
+		word := db.GetState(CollectionsAddress, arraySlot(slot, i))
+		blob = append(blob, word[:]...)
+	}
+	return blob[:size]
+}
+
+// writeBlob stores blob in the array at slot, clearing the words of a longer
+// previous blob.
+func writeBlob(db StateDB, slot common.Hash, blob []byte) {
+	old := words(int(readLength(db, slot)))
+	db.SetState(CollectionsAddress, slot, common.BigToHash(new(big.Int).SetUint64(uint64(len(blob)))))
+	for i := uint64(0); i < words(len(blob)); i++ {
+		var word common.Hash
+		copy(word[:], blob[i*32:])
+		db.SetState(CollectionsAddress, arraySlot(slot, i), word)
+	}
+	for i := words(len(blob)); i < old; i++ {
+		db.SetState(CollectionsAddress, arraySlot(slot, i), common.Hash{})
+	}
+}
+
+// ReadCollectionRecord reads a record from the storage of the collections
+// system contract.
+func ReadCollectionRecord(db StateDB, id common.Hash) (*CollectionRecord, bool) {
+	owner := db.GetState(CollectionsAddress, recordSlot(id, recordOwner))
+	if owner == (common.Hash{}) {
+		return nil, false
+	}
+	var (
+		info   = db.GetState(CollectionsAddress, recordSlot(id, recordInfo))
+		writer = db.GetState(CollectionsAddress, recordSlot(id, recordWriter))
+	)
+	record := &CollectionRecord{
+		ID:          id,
+		Owner:       common.BytesToAddress(owner[:]),
+		Kind:        info[7],
+		Writer:      common.BytesToAddress(writer[:]),
+		BlockNumber: binary.BigEndian.Uint64(info[16:24]),
+		Timestamp:   binary.BigEndian.Uint64(info[24:32]),
+		Collection:  string(readBlob(db, recordSlot(id, recordCollection))),
+		Key:         string(readBlob(db, recordSlot(id, recordKey))),
+		Value:       readBlob(db, recordSlot(id, recordValue)),
+	}
+	record.ACL = readACL(db, id)
+	return record, true
+}
+
+func readACL(db StateDB, id common.Hash) []CollectionGrant {
+	slot := recordSlot(id, recordACL)
+	acl := make([]CollectionGrant, readLength(db, slot))
+	for i := range acl {
+		word := db.GetState(CollectionsAddress, arraySlot(slot, uint64(i)))
+		acl[i] = CollectionGrant{Address: common.BytesToAddress(word[12:]), Permissions: word[0]}
+	}
+	return acl
+}
+
+// CollectionRecordIDs returns the identifiers of the records of owner, in the
+// order they were created.
+func CollectionRecordIDs(db StateDB, owner common.Address) []common.Hash {
+	slot := ownerIndexSlot(owner)
+	ids := make([]common.Hash, readLength(db, slot))
+	for i := range ids {
+		ids[i] = db.GetState(CollectionsAddress, arraySlot(slot, uint64(i)))
+	}
+	return ids
+}
+
+// runCollections executes a call to the collections system contract.
+func runCollections(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
+	if len(input) < 4 {
+		return nil, errExecutionReverted
+	}
+	method, err := collectionsABI.MethodById(input[:4])
+	if err != nil {
+		return nil, errExecutionReverted
+	}
+	args, err := method.Inputs.UnpackValues(input[4:])
+	if err != nil {
+		return nil, errExecutionReverted
+	}
+	switch method.Name {
+	case "put":
+		var (
+			collection, key = args[1].(string), args[2].(string)
+			value           = args[4].([]byte)
+			acl             = args[5].([]common.Address)
+			permissions     = args[6].([]uint8)
+		)
+		size := words(len(collection)) + words(len(key)) + words(len(value)) + uint64(len(acl))
+		if !contract.UseGas(params.CollectionsPutGas + size*params.CollectionsWordGas) {
+			return nil, ErrOutOfGas
+		}
+		if readOnly {
+			return nil, errWriteProtection
+		}
+		if len(acl) != len(permissions) || len(value) > params.CollectionsMaxValue {
+			return nil, errExecutionReverted
+		}
+		grants := make([]CollectionGrant, len(acl))
+		for i := range acl {
+			grants[i] = CollectionGrant{Address: acl[i], Permissions: permissions[i]}
+		}
+		return nil, putRecord(evm, contract, args[0].(common.Address), collection, key, args[3].(uint8), value, grants)
+
+	case "get":
+		if !contract.UseGas(params.CollectionsGetGas) {
+			return nil, ErrOutOfGas
+		}
+		id := CollectionRecordID(args[0].(common.Address), args[1].(string), args[2].(string))
+		if !contract.UseGas(words(int(readLength(evm.StateDB, recordSlot(id, recordValue)))) * params.CollectionsReadGas) {
+			return nil, ErrOutOfGas
+		}
+		record, ok := ReadCollectionRecord(evm.StateDB, id)
+		if !ok {
+			record = new(CollectionRecord)
+		} else if !record.Allowed(contract.Caller(), CollectionRead) {
+			return nil, errExecutionReverted
+		}
+		return method.Outputs.Pack(record.Kind, record.Value, record.Writer, record.BlockNumber, record.Timestamp)
+	}
+	return nil, errExecutionReverted
+}
+
+// putRecord creates or replaces the value of a record and emits the Put event.
+func putRecord(evm *EVM, contract *Contract, owner common.Address, collection, key string, kind uint8, value []byte, acl []CollectionGrant) error {
+	// Delegate calls and call code would write the records into the storage
+	// of the calling contract.
+	if contract.Address() != CollectionsAddress || contract.Value().Sign() != 0 {
+		return errExecutionReverted
+	}
+	if owner == (common.Address{}) || kind > CollectionEncrypted {
+		return errExecutionReverted
+	}
+	var (
+		db     = evm.StateDB
+		writer = contract.Caller()
+		id     = CollectionRecordID(owner, collection, key)
+	)
+	if record, ok := ReadCollectionRecord(db, id); ok {
+		// Writers may only replace the value of records of the same kind, the
+		// access list is left to the owner.
+		if record.Kind != kind || !record.Allowed(writer, CollectionWrite) {
+			return errExecutionReverted
+		}
+		if writer != owner && len(acl) > 0 {
+			return errExecutionReverted
+		}
+	} else {
+		if writer != owner {
+			return errExecutionReverted
+		}
+		db.SetState(CollectionsAddress, recordSlot(id, recordOwner), common.BytesToHash(owner[:]))
+		writeBlob(db, recordSlot(id, recordCollection), []byte(collection))
+		writeBlob(db, recordSlot(id, recordKey), []byte(key))
+
+		index := ownerIndexSlot(owner)
+		n := readLength(db, index)
+		db.SetState(CollectionsAddress, arraySlot(index, n), id)
+		db.SetState(CollectionsAddress, index, common.BigToHash(new(big.Int).SetUint64(n+1)))
+	}
+	// A nonce keeps the contract account from being deleted as empty.
+	if db.GetNonce(CollectionsAddress) == 0 {
+		db.SetNonce(CollectionsAddress, 1)
+	}
+	var (
+		number = evm.BlockNumber.Uint64()
+		time   = evm.Time.Uint64()
+		info   common.Hash
+	)
+	info[7] = kind
+	binary.BigEndian.PutUint64(info[16:24], number)
+	binary.BigEndian.PutUint64(info[24:32], time)
+	db.SetState(CollectionsAddress, recordSlot(id, recordInfo), info)
+	db.SetState(CollectionsAddress, recordSlot(id, recordWriter), common.BytesToHash(writer[:]))
+	writeBlob(db, recordSlot(id, recordValue), value)
+
+	if writer == owner {
+		slot := recordSlot(id, recordACL)
+		old := readLength(db, slot)
+		db.SetState(CollectionsAddress, slot, common.BigToHash(new(big.Int).SetUint64(uint64(len(acl)))))
+		for i, grant := range acl {
+			word := common.BytesToHash(grant.Address[:])
+			word[0] = grant.Permissions
+			db.SetState(CollectionsAddress, arraySlot(slot, uint64(i)), word)
+		}
+		for i := uint64(len(acl)); i < old; i++ {
+			db.SetState(CollectionsAddress, arraySlot(slot, i), common.Hash{})
+		}
+	}
+	event := collectionsABI.Events["Put"]
+	data, err := event.Inputs.NonIndexed().Pack(kind, collection, key)
+	if err != nil {
+		return err
+	}
+	db.AddLog(&types.Log{
+		Address:     CollectionsAddress,
+		Topics:      []common.Hash{event.ID(), id, common.BytesToHash(owner[:]), common.BytesToHash(writer[:])},
+		Data:        data,
+		BlockNumber: number,
+	})
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/rawdb"
+	"github.com/filestorm/go-filestorm/core/state"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+func TestCollections(t *testing.T) {
+	var (
+		owner   = common.BytesToAddress([]byte("owner"))
+		writer  = common.BytesToAddress([]byte("writer"))
+		other   = common.BytesToAddress([]byte("other"))
+		long    = bytes.Repeat([]byte("0123456789"), 10)
+		gas     = uint64(1000000)
+		statedb *state.StateDB
+	)
+	statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(7),
+		Time:        big.NewInt(1570000000),
+	}
+	vmenv := NewEVM(vmctx, statedb, params.AllPbftProtocolChanges, Config{})
+
+	put := func(from common.Address, value []byte, acl []common.Address, permissions []uint8) error {
+		input, err := collectionsABI.Pack("put", owner, "settings", "theme", CollectionKV, value, acl, permissions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = vmenv.Call(AccountRef(from), CollectionsAddress, input, gas, new(big.Int))
+		return err
+	}
+	// Only the owner may create the record.
+	if err := put(writer, []byte("dark"), nil, nil); err != errExecutionReverted {
+		t.Fatalf("foreign create: have %v, want %v", err, errExecutionReverted)
+	}
+	acl := []common.Address{writer, other}
+	if err := put(owner, long, acl, []uint8{CollectionWrite, CollectionRead}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	// Granted writers may replace the value, but not the access list.
+	if err := put(writer, []byte("dark"), acl, []uint8{CollectionWrite, CollectionWrite}); err != errExecutionReverted {
+		t.Fatalf("writer acl change: have %v, want %v", err, errExecutionReverted)
+	}
+	if err := put(writer, []byte("dark"), nil, nil); err != nil {
+		t.Fatalf("writer put failed: %v", err)
+	}
+	if err := put(other, []byte("light"), nil, nil); err != errExecutionReverted {
+		t.Fatalf("reader put: have %v, want %v", err, errExecutionReverted)
+	}
+	statedb.Finalise(true)
+
+	id := CollectionRecordID(owner, "settings", "theme")
+	want := &CollectionRecord{
+		ID:          id,
+		Owner:       owner,
+		Kind:        CollectionKV,
+		Writer:      writer,
+		BlockNumber: 7,
+		Timestamp:   1570000000,
+		Collection:  "settings",
+		Key:         "theme",
+		Value:       []byte("dark"),
+		ACL:         []CollectionGrant{{writer, CollectionWrite}, {other, CollectionRead}},
+	}
+	if record, ok := ReadCollectionRecord(statedb, id); !ok || !reflect.DeepEqual(record, want) {
+		t.Fatalf("record mismatch: have %+v, want %+v", record, want)
+	}
+	// The shorter value must not leave words of the longer one behind.
+	if word := statedb.GetState(CollectionsAddress, arraySlot(recordSlot(id, recordValue), 1)); word != (common.Hash{}) {
+		t.Fatalf("stale value word: %x", word)
+	}
+	if ids := CollectionRecordIDs(statedb, owner); len(ids) != 1 || ids[0] != id {
+		t.Fatalf("owner index mismatch: %v", ids)
+	}
+	if logs := statedb.Logs(); len(logs) != 2 || logs[1].Topics[1] != id || logs[1].Topics[3] != common.BytesToHash(writer[:]) {
+		t.Fatalf("unexpected logs: %v", logs)
+	}
+
+	// Reads through the contract honour the read grants.
+	input, _ := collectionsABI.Pack("get", owner, "settings", "theme")
+	ret, _, err := vmenv.StaticCall(AccountRef(other), CollectionsAddress, input, gas)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	var out struct {
+		Kind        uint8
+		Value       []byte
+		Writer      common.Address
+		BlockNumber uint64
+		Timestamp   uint64
+	}
+	if err := collectionsABI.Unpack(&out, "get", ret); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Value, []byte("dark")) || out.Writer != writer || out.BlockNumber != 7 {
+		t.Fatalf("get mismatch: %+v", out)
+	}
+	if _, _, err := vmenv.StaticCall(AccountRef(writer), CollectionsAddress, input, gas); err != errExecutionReverted {
+		t.Fatalf("unauthorized get: have %v, want %v", err, errExecutionReverted)
+	}
+}
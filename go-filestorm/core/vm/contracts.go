@@ -27,6 +27,7 @@ import (
 	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/crypto/blake2b"
 	"github.com/filestorm/go-filestorm/crypto/bn256"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 	"github.com/filestorm/go-filestorm/params"
 
 	//lint:ignore SA1019 Needed for precompile
@@ -77,6 +78,45 @@ var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{9}): &blake2F{},
 }
 
+// PrecompiledContractsSM3 contains the pre-compiled Filestorm contracts of
+// Istanbul chains hashing with SM3, adding SM3 to the Istanbul set.
+var PrecompiledContractsSM3 = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):  ecrecoverSM3,
+	common.BytesToAddress([]byte{2}):  &sha256hash{},
+	common.BytesToAddress([]byte{3}):  &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):  &dataCopy{},
+	common.BytesToAddress([]byte{5}):  &bigModExp{},
+	common.BytesToAddress([]byte{6}):  &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{7}):  &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{8}):  &bn256PairingIstanbul{},
+	common.BytesToAddress([]byte{9}):  &blake2F{},
+	common.BytesToAddress([]byte{10}): &sm3hash{},
+}
+
+// ecrecoverSM3 is the ecrecover contract of chains hashing with SM3, returning
+// the SM3 address of the signer.
+var ecrecoverSM3 = &ecrecover{hasher: crypto.SM3Hasher}
+
+// precompiledContract returns the pre-compiled contract at the address under
+// the given chain rules, nil if there is none.
+func precompiledContract(rules params.Rules, addr common.Address) PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if rules.IsByzantium {
+		precompiles = PrecompiledContractsByzantium
+	}
+	if rules.IsIstanbul {
+		precompiles = PrecompiledContractsIstanbul
+		if rules.IsSM3 {
+			precompiles = PrecompiledContractsSM3
+		}
+	}
+	p := precompiles[addr]
+	if _, ok := p.(*ecrecover); ok && rules.IsSM3 {
+		return ecrecoverSM3
+	}
+	return p
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -86,8 +126,11 @@ func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contr
 	return nil, ErrOutOfGas
 }
 
-// ECRECOVER implemented as a native contract.
-type ecrecover struct{}
+// ECRECOVER implemented as a native contract, deriving the address with the
+// hash function of the chain.
+type ecrecover struct {
+	hasher crypto.Hasher
+}
 
 func (c *ecrecover) RequiredGas(input []byte) uint64 {
 	return params.EcrecoverGas
@@ -121,7 +164,7 @@ func (c *ecrecover) Run(input []byte) ([]byte, error) {
 	}
 
 	// the first byte of pubkey is bitcoin heritage
-	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32), nil
+	return common.LeftPadBytes(c.hasher.Hash(pubKey[1:]).Bytes()[12:], 32), nil
 }
 
 // SHA256 implemented as a native contract.
@@ -139,6 +182,21 @@ func (c *sha256hash) Run(input []byte) ([]byte, error) {
 	return h[:], nil
 }
 
+// SM3 implemented as a native contract.
+type sm3hash struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This method does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *sm3hash) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*params.Sm3PerWordGas + params.Sm3BaseGas
+}
+func (c *sm3hash) Run(input []byte) ([]byte, error) {
+	h := sm3.Sum(input)
+	return h[:], nil
+}
+
 // RIPEMD160 implemented as a native contract.
 type ripemd160hash struct{}
 
@@ -598,6 +598,25 @@ func TestPrecompiledBlake2F(t *testing.T) {
 	}
 }
 
+func TestPrecompiledSM3(t *testing.T) {
+	p := PrecompiledContractsSM3[common.BytesToAddress([]byte{10})]
+	for _, test := range []precompiledTest{
+		{input: "616263", expected: "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0", name: "abc"},
+		{input: "", expected: "1ab21d8355cfa17f8e61194831e81a8f22bec8c728fefb747ed035eb5082aa2b", name: "empty"},
+	} {
+		in := common.Hex2Bytes(test.input)
+		contract := NewContract(AccountRef(common.HexToAddress("1337")), nil, new(big.Int), p.RequiredGas(in))
+		if res, err := RunPrecompiledContract(p, in, contract); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if common.Bytes2Hex(res) != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, common.Bytes2Hex(res))
+		}
+	}
+	if _, ok := PrecompiledContractsIstanbul[common.BytesToAddress([]byte{10})]; ok {
+		t.Error("SM3 precompile active without SM3 chain hash")
+	}
+}
+
 func BenchmarkPrecompiledBlake2F(bench *testing.B) {
 	for _, test := range blake2FTests {
 		benchmarkPrecompiled("09", test, bench)
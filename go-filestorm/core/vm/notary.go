@@ -0,0 +1,174 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+// NotaryAddress is the address of the notarization system contract. The
+// contract is implemented natively and called through the Solidity ABI in
+// NotaryABI once the chain reaches its notary block.
+var NotaryAddress = common.HexToAddress("0x0000000000000000000000000000000000001000")
+
+// Hash algorithms of notarized content.
+const (
+	NotaryKeccak256 uint8 = iota
+	NotarySM3
+)
+
+// NotaryABI is the ABI of the notarization system contract. A content hash can
+// only be notarized once, by its first owner.
+const NotaryABI = `[
+	{"type":"function","name":"notarize","inputs":[{"name":"hash","type":"bytes32"},{"name":"algorithm","type":"uint8"},{"name":"metadata","type":"string"}],"outputs":[]},
+	{"type":"function","name":"getNotarization","constant":true,"inputs":[{"name":"hash","type":"bytes32"}],"outputs":[{"name":"owner","type":"address"},{"name":"algorithm","type":"uint8"},{"name":"blockNumber","type":"uint64"},{"name":"timestamp","type":"uint64"},{"name":"metadataHash","type":"bytes32"}]},
+	{"type":"event","name":"Notarized","inputs":[{"name":"hash","type":"bytes32","indexed":true},{"name":"owner","type":"address","indexed":true},{"name":"algorithm","type":"uint8","indexed":false},{"name":"timestamp","type":"uint64","indexed":false},{"name":"metadata","type":"string","indexed":false}]}
+]`
+
+var notaryABI abi.ABI
+
+func init() {
+	var err error
+	if notaryABI, err = abi.JSON(strings.NewReader(NotaryABI)); err != nil {
+		panic(err)
+	}
+}
+
+// Notarization is a record of the notarization system contract.
+type Notarization struct {
+	Owner        common.Address
+	Algorithm    uint8
+	BlockNumber  uint64
+	Timestamp    uint64
+	MetadataHash common.Hash
+}
+
+// notarySlot returns the storage slot of the i'th word of the record of hash.
+// The words are the owner, the packed algorithm, block number and timestamp,
+// and the hash of the metadata.
+func notarySlot(hash common.Hash, i byte) common.Hash {
+	return crypto.Keccak256Hash(hash[:], []byte{i})
+}
+
+// ReadNotarization reads the record of a content hash from the storage of the
+// notarization system contract.
+func ReadNotarization(db StateDB, hash common.Hash) (*Notarization, bool) {
+	owner := db.GetState(NotaryAddress, notarySlot(hash, 0))
+	if owner == (common.Hash{}) {
+		return nil, false
+	}
+	info := db.GetState(NotaryAddress, notarySlot(hash, 1))
+	return &Notarization{
+		Owner:        common.BytesToAddress(owner[:]),
+		Algorithm:    info[7],
+		BlockNumber:  binary.BigEndian.Uint64(info[16:24]),
+		Timestamp:    binary.BigEndian.Uint64(info[24:32]),
+		MetadataHash: db.GetState(NotaryAddress, notarySlot(hash, 2)),
+	}, true
+}
+
+// runNotary executes a call to the notarization system contract.
+func runNotary(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
+	if len(input) < 4 {
+		return nil, errExecutionReverted
+	}
+	method, err := notaryABI.MethodById(input[:4])
+	if err != nil {
+		return nil, errExecutionReverted
+	}
+	switch method.Name {
+	case "notarize":
+		if !contract.UseGas(params.NotarizeGas + uint64(len(input))*params.NotarizeByteGas) {
+			return nil, ErrOutOfGas
+		}
+		if readOnly {
+			return nil, errWriteProtection
+		}
+		args, err := method.Inputs.UnpackValues(input[4:])
+		if err != nil {
+			return nil, errExecutionReverted
+		}
+		return nil, notarize(evm, contract, common.Hash(args[0].([32]byte)), args[1].(uint8), args[2].(string))
+
+	case "getNotarization":
+		if !contract.UseGas(params.NotaryLookupGas) {
+			return nil, ErrOutOfGas
+		}
+		args, err := method.Inputs.UnpackValues(input[4:])
+		if err != nil {
+			return nil, errExecutionReverted
+		}
+		n, ok := ReadNotarization(evm.StateDB, args[0].([32]byte))
+		if !ok {
+			n = new(Notarization)
+		}
+		return method.Outputs.Pack(n.Owner, n.Algorithm, n.BlockNumber, n.Timestamp, n.MetadataHash)
+	}
+	return nil, errExecutionReverted
+}
+
+// notarize records hash for the caller and emits the Notarized event.
+func notarize(evm *EVM, contract *Contract, hash common.Hash, algorithm uint8, metadata string) error {
+	// Delegate calls and call code would write the records into the storage
+	// of the calling contract.
+	if contract.Address() != NotaryAddress || contract.Value().Sign() != 0 {
+		return errExecutionReverted
+	}
+	if hash == (common.Hash{}) || algorithm > NotarySM3 {
+		return errExecutionReverted
+	}
+	db := evm.StateDB
+	if _, ok := ReadNotarization(db, hash); ok {
+		return errExecutionReverted
+	}
+	// A nonce keeps the contract account from being deleted as empty.
+	if db.GetNonce(NotaryAddress) == 0 {
+		db.SetNonce(NotaryAddress, 1)
+	}
+	var (
+		owner  = contract.Caller()
+		number = evm.BlockNumber.Uint64()
+		time   = evm.Time.Uint64()
+		info   common.Hash
+	)
+	info[7] = algorithm
+	binary.BigEndian.PutUint64(info[16:24], number)
+	binary.BigEndian.PutUint64(info[24:32], time)
+	db.SetState(NotaryAddress, notarySlot(hash, 0), common.BytesToHash(owner[:]))
+	db.SetState(NotaryAddress, notarySlot(hash, 1), info)
+	db.SetState(NotaryAddress, notarySlot(hash, 2), crypto.Keccak256Hash([]byte(metadata)))
+
+	event := notaryABI.Events["Notarized"]
+	data, err := event.Inputs.NonIndexed().Pack(algorithm, time, metadata)
+	if err != nil {
+		return err
+	}
+	db.AddLog(&types.Log{
+		Address:     NotaryAddress,
+		Topics:      []common.Hash{event.ID(), hash, common.BytesToHash(owner[:])},
+		Data:        data,
+		BlockNumber: number,
+	})
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/rawdb"
+	"github.com/filestorm/go-filestorm/core/state"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+func TestNotary(t *testing.T) {
+	var (
+		owner   = common.BytesToAddress([]byte("owner"))
+		other   = common.BytesToAddress([]byte("other"))
+		hash    = crypto.Keccak256Hash([]byte("contract.pdf"))
+		meta    = "sales contract #42"
+		gas     = uint64(200000)
+		statedb *state.StateDB
+	)
+	statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(7),
+		Time:        big.NewInt(1570000000),
+	}
+	vmenv := NewEVM(vmctx, statedb, params.AllPbftProtocolChanges, Config{})
+
+	input, err := notaryABI.Pack("notarize", hash, NotarySM3, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := vmenv.Call(AccountRef(owner), NotaryAddress, input, gas, new(big.Int)); err != nil {
+		t.Fatalf("notarize failed: %v", err)
+	}
+	if _, _, err := vmenv.Call(AccountRef(other), NotaryAddress, input, gas, new(big.Int)); err != errExecutionReverted {
+		t.Fatalf("second notarization: have %v, want %v", err, errExecutionReverted)
+	}
+	if _, _, err := vmenv.StaticCall(AccountRef(other), NotaryAddress, input, gas); err != errWriteProtection {
+		t.Fatalf("static notarization: have %v, want %v", err, errWriteProtection)
+	}
+	statedb.Finalise(true)
+
+	want := &Notarization{owner, NotarySM3, 7, 1570000000, crypto.Keccak256Hash([]byte(meta))}
+	if n, ok := ReadNotarization(statedb, hash); !ok || *n != *want {
+		t.Fatalf("record mismatch: have %+v, want %+v", n, want)
+	}
+	logs := statedb.Logs()
+	if len(logs) != 1 || logs[0].Topics[1] != hash || logs[0].Topics[2] != common.BytesToHash(owner[:]) {
+		t.Fatalf("unexpected logs: %v", logs)
+	}
+
+	input, _ = notaryABI.Pack("getNotarization", hash)
+	ret, _, err := vmenv.StaticCall(AccountRef(other), NotaryAddress, input, gas)
+	if err != nil {
+		t.Fatalf("getNotarization failed: %v", err)
+	}
+	var out Notarization
+	if err := notaryABI.Unpack(&out, "getNotarization", ret); err != nil {
+		t.Fatal(err)
+	}
+	if out != *want {
+		t.Fatalf("getNotarization mismatch: have %+v, want %+v", out, *want)
+	}
+}
+
+func TestNotaryInactive(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+	}
+	vmenv := NewEVM(vmctx, statedb, params.TestChainConfig, Config{})
+
+	hash := crypto.Keccak256Hash([]byte("contract.pdf"))
+	input, _ := notaryABI.Pack("notarize", hash, NotaryKeccak256, "")
+	if _, _, err := vmenv.Call(AccountRef(common.Address{1}), NotaryAddress, input, 200000, new(big.Int)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ReadNotarization(statedb, hash); ok {
+		t.Fatal("notarized before the notary block")
+	}
+}
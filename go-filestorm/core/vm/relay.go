@@ -0,0 +1,843 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/consensus"
+	"github.com/filestorm/go-filestorm/consensus/fstash"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb/memorydb"
+	"github.com/filestorm/go-filestorm/params"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/trie"
+)
+
+// RelayAddress is the address of the relay system contract, a light client of
+// foreign EVM chains. Relayers submit the headers of a foreign chain, the
+// contract verifies their seals, follows the chain with the most total
+// difficulty and verifies receipt proofs against it. The contract is
+// implemented natively and called through the Solidity ABI in RelayABI once the
+// chain reaches its relay block.
+var RelayAddress = common.HexToAddress("0x0000000000000000000000000000000000001002")
+
+// Consensus engines of foreign chains.
+const (
+	RelayFstash uint8 = iota // Proof of work, verified with the fstash (ethash) light cache
+	RelayClique              // Proof of authority, verified against the signers of the last checkpoint
+)
+
+// RelayABI is the ABI of the relay system contract. A relay is registered with
+// a trusted checkpoint header of the foreign chain, and for Clique chains the
+// signers at the checkpoint, and is identified by its registrar and the foreign
+// chain id. Anyone may submit headers extending the known ones. Logs are only
+// verified in canonical blocks with the confirmations requested by the relay.
+const RelayABI = `[
+	{"type":"function","name":"register","inputs":[{"name":"chainId","type":"uint64"},{"name":"engine","type":"uint8"},{"name":"checkpoint","type":"bytes"},{"name":"signers","type":"address[]"},{"name":"epoch","type":"uint64"},{"name":"period","type":"uint64"},{"name":"confirmations","type":"uint64"}],"outputs":[{"name":"id","type":"bytes32"}]},
+	{"type":"function","name":"submit","inputs":[{"name":"id","type":"bytes32"},{"name":"headers","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"head","constant":true,"inputs":[{"name":"id","type":"bytes32"}],"outputs":[{"name":"hash","type":"bytes32"},{"name":"number","type":"uint64"},{"name":"totalDifficulty","type":"uint256"}]},
+	{"type":"function","name":"getHeader","constant":true,"inputs":[{"name":"id","type":"bytes32"},{"name":"hash","type":"bytes32"}],"outputs":[{"name":"number","type":"uint64"},{"name":"timestamp","type":"uint64"},{"name":"parentHash","type":"bytes32"},{"name":"stateRoot","type":"bytes32"},{"name":"receiptsRoot","type":"bytes32"},{"name":"canonical","type":"bool"},{"name":"confirmations","type":"uint64"}]},
+	{"type":"function","name":"verifyLog","constant":true,"inputs":[{"name":"id","type":"bytes32"},{"name":"blockHash","type":"bytes32"},{"name":"txIndex","type":"uint64"},{"name":"proof","type":"bytes"},{"name":"logIndex","type":"uint64"}],"outputs":[{"name":"emitter","type":"address"},{"name":"topics","type":"bytes32[]"},{"name":"data","type":"bytes"}]},
+	{"type":"event","name":"Registered","inputs":[{"name":"id","type":"bytes32","indexed":true},{"name":"owner","type":"address","indexed":true},{"name":"chainId","type":"uint64","indexed":false},{"name":"engine","type":"uint8","indexed":false}]},
+	{"type":"event","name":"NewHead","inputs":[{"name":"id","type":"bytes32","indexed":true},{"name":"hash","type":"bytes32","indexed":true},{"name":"number","type":"uint64","indexed":false}]}
+]`
+
+var relayABI abi.ABI
+
+func init() {
+	var err error
+	if relayABI, err = abi.JSON(strings.NewReader(RelayABI)); err != nil {
+		panic(err)
+	}
+}
+
+// relayPoW verifies the seals of fstash headers. The shared instance keeps the
+// verification caches of recent epochs in memory.
+var relayPoW consensus.Engine = fstash.NewShared()
+
+// relayCachedEpochs is the number of fstash epochs whose verification caches
+// are taken to be in memory, fewer than relayPoW keeps. Verifying a header of
+// another epoch pays for generating its cache.
+const relayCachedEpochs = 2
+
+// relayEpochsSlot is the storage slot of the fstash epochs the relays verified
+// headers of last, each stored plus one in 8 bytes, most recent last.
+var relayEpochsSlot = common.Hash{}
+
+// relayFutureTime is the number of seconds foreign headers may be ahead of the
+// time of the local block, the allowance of the foreign engines.
+const relayFutureTime = 15
+
+const (
+	cliqueVanity = 32 // Bytes of the extra data reserved for the signer vanity
+	cliqueSeal   = 65 // Bytes of the extra data reserved for the signer seal
+)
+
+// relayEmptyUncleHash is the uncle hash of foreign headers without uncles. It
+// is always Keccak256, whatever the hash function of the local chain.
+var relayEmptyUncleHash = crypto.Keccak256Hash([]byte{0xc0})
+
+var (
+	errRelayUnknown        = errors.New("unknown relay")
+	errRelayUnknownParent  = errors.New("unknown parent header")
+	errRelayInvalidHeader  = errors.New("invalid header")
+	errRelayInvalidSeal    = errors.New("invalid seal")
+	errRelayUnauthorized   = errors.New("unauthorized signer")
+	errRelayRecentlySigned = errors.New("recently signed")
+	errRelayUnknownBlock   = errors.New("unknown block")
+	errRelayNotCanonical   = errors.New("block not canonical")
+	errRelayUnconfirmed    = errors.New("block not confirmed")
+	errRelayInvalidProof   = errors.New("invalid receipt proof")
+	errRelayUnknownLog     = errors.New("unknown log")
+)
+
+// RelayHeader is a header of a foreign chain. The layout is the one of
+// Ethereum headers, the fields added by later forks of the foreign chain, like
+// the base fee, are kept encoded in Rest.
+type RelayHeader struct {
+	ParentHash  common.Hash
+	UncleHash   common.Hash
+	Coinbase    common.Address
+	Root        common.Hash
+	TxHash      common.Hash
+	ReceiptHash common.Hash
+	Bloom       types.Bloom
+	Difficulty  *big.Int
+	Number      *big.Int
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   common.Hash
+	Nonce       types.BlockNonce
+	Rest        []rlp.RawValue `rlp:"tail"`
+}
+
+// Hash returns the Keccak256 hash of the header, its block hash on the foreign
+// chain.
+func (h *RelayHeader) Hash() common.Hash {
+	enc, _ := rlp.EncodeToBytes(h)
+	return crypto.Keccak256Hash(enc)
+}
+
+// cliqueSigner recovers the signer of a Clique header from the seal at the end
+// of its extra data.
+func (h *RelayHeader) cliqueSigner() (common.Address, error) {
+	if len(h.Extra) < cliqueVanity+cliqueSeal {
+		return common.Address{}, errRelayInvalidSeal
+	}
+	unsealed := *h
+	unsealed.Extra = h.Extra[:len(h.Extra)-cliqueSeal]
+	enc, err := rlp.EncodeToBytes(&unsealed)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pub, err := crypto.Ecrecover(crypto.Keccak256(enc), h.Extra[len(h.Extra)-cliqueSeal:])
+	if err != nil {
+		return common.Address{}, errRelayInvalidSeal
+	}
+	return common.BytesToAddress(crypto.Keccak256(pub[1:])[12:]), nil
+}
+
+// DecodeRelayHeaders decodes the RLP list of headers submitted to the relay
+// system contract.
+func DecodeRelayHeaders(blob []byte) ([]*RelayHeader, error) {
+	var headers []*RelayHeader
+	if err := rlp.DecodeBytes(blob, &headers); err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		if header.Difficulty == nil || header.Number == nil || !header.Number.IsUint64() {
+			return nil, errRelayInvalidHeader
+		}
+	}
+	return headers, nil
+}
+
+// Relay is a light client of a foreign chain in the relay system contract.
+type Relay struct {
+	ID            common.Hash
+	Owner         common.Address
+	ChainID       uint64
+	Engine        uint8
+	Epoch         uint64 // Clique epoch, the signers change at checkpoints
+	Period        uint64 // Clique period, the minimum seconds between blocks
+	Confirmations uint64 // Blocks required on top of a block to verify its logs
+	Head          common.Hash
+}
+
+// RelayRecord is a foreign header stored by a relay.
+type RelayRecord struct {
+	Hash            common.Hash
+	Number          uint64
+	Time            uint64
+	Difficulty      *big.Int
+	ParentHash      common.Hash
+	Root            common.Hash
+	ReceiptHash     common.Hash
+	TotalDifficulty *big.Int
+	Signers         common.Hash    // Checkpoint whose signers authorize the children (Clique)
+	Signer          common.Address // Signer of the header (Clique)
+}
+
+// Storage words of a relay and of its headers.
+const (
+	relayOwner byte = iota
+	relayInfo       // period, engine, chain id, epoch and confirmations
+	relayHead
+)
+
+const (
+	headerInfo byte = iota // difficulty, number and timestamp
+	headerParent
+	headerRoot
+	headerReceipts
+	headerTD
+	headerSigners
+	headerSigner
+)
+
+// RelayID returns the identifier of the relay of a foreign chain registered by
+// owner.
+func RelayID(owner common.Address, chainID uint64) common.Hash {
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], chainID)
+	return crypto.Keccak256Hash(owner[:], id[:])
+}
+
+// relaySlot returns the storage slot of the i'th word of the relay id.
+func relaySlot(id common.Hash, i byte) common.Hash {
+	return crypto.Keccak256Hash(id[:], []byte{i})
+}
+
+// relayHeaderSlot returns the storage slot of the i'th word of a header of the
+// relay id.
+func relayHeaderSlot(id, hash common.Hash, i byte) common.Hash {
+	return crypto.Keccak256Hash(id[:], hash[:], []byte{i})
+}
+
+// relayCanonicalSlot returns the storage slot of the canonical hash of number.
+func relayCanonicalSlot(id common.Hash, number uint64) common.Hash {
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], number)
+	return crypto.Keccak256Hash(id[:], num[:])
+}
+
+// relaySignersSlot returns the storage slot of the list of signers set by a
+// checkpoint header.
+func relaySignersSlot(id, checkpoint common.Hash) common.Hash {
+	return crypto.Keccak256Hash(id[:], checkpoint[:])
+}
+
+// ReadRelay reads a relay from the storage of the relay system contract.
+func ReadRelay(db StateDB, id common.Hash) (*Relay, bool) {
+	owner := db.GetState(RelayAddress, relaySlot(id, relayOwner))
+	if owner == (common.Hash{}) {
+		return nil, false
+	}
+	info := db.GetState(RelayAddress, relaySlot(id, relayInfo))
+	return &Relay{
+		ID:            id,
+		Owner:         common.BytesToAddress(owner[:]),
+		Engine:        info[7],
+		ChainID:       binary.BigEndian.Uint64(info[8:16]),
+		Epoch:         binary.BigEndian.Uint64(info[16:24]),
+		Period:        uint64(binary.BigEndian.Uint32(info[0:4])),
+		Confirmations: binary.BigEndian.Uint64(info[24:32]),
+		Head:          db.GetState(RelayAddress, relaySlot(id, relayHead)),
+	}, true
+}
+
+// ReadRelayRecord reads a header of a relay.
+func ReadRelayRecord(db StateDB, id, hash common.Hash) (*RelayRecord, bool) {
+	td := db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerTD))
+	if td == (common.Hash{}) {
+		return nil, false
+	}
+	var (
+		info   = db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerInfo))
+		signer = db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerSigner))
+	)
+	return &RelayRecord{
+		Hash:            hash,
+		Number:          binary.BigEndian.Uint64(info[16:24]),
+		Time:            binary.BigEndian.Uint64(info[24:32]),
+		Difficulty:      new(big.Int).SetBytes(info[0:16]),
+		ParentHash:      db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerParent)),
+		Root:            db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerRoot)),
+		ReceiptHash:     db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerReceipts)),
+		TotalDifficulty: td.Big(),
+		Signers:         db.GetState(RelayAddress, relayHeaderSlot(id, hash, headerSigners)),
+		Signer:          common.BytesToAddress(signer[:]),
+	}, true
+}
+
+// RelayCanonicalHash returns the hash of the canonical header of a relay at
+// number, the zero hash if there is none.
+func RelayCanonicalHash(db StateDB, id common.Hash, number uint64) common.Hash {
+	return db.GetState(RelayAddress, relayCanonicalSlot(id, number))
+}
+
+// ReadRelaySigners returns the sorted signers set by a checkpoint of a Clique
+// relay.
+func ReadRelaySigners(db StateDB, id, checkpoint common.Hash) []common.Address {
+	slot := relaySignersSlot(id, checkpoint)
+	signers := make([]common.Address, db.GetState(RelayAddress, slot).Big().Uint64())
+	for i := range signers {
+		word := db.GetState(RelayAddress, arraySlot(slot, uint64(i)))
+		signers[i] = common.BytesToAddress(word[:])
+	}
+	return signers
+}
+
+func writeRelayRecord(db StateDB, id common.Hash, r *RelayRecord) {
+	var info common.Hash
+	copy(info[0:16], common.LeftPadBytes(r.Difficulty.Bytes(), 16))
+	binary.BigEndian.PutUint64(info[16:24], r.Number)
+	binary.BigEndian.PutUint64(info[24:32], r.Time)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerInfo), info)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerParent), r.ParentHash)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerRoot), r.Root)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerReceipts), r.ReceiptHash)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerTD), common.BigToHash(r.TotalDifficulty))
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerSigners), r.Signers)
+	db.SetState(RelayAddress, relayHeaderSlot(id, r.Hash, headerSigner), common.BytesToHash(r.Signer[:]))
+}
+
+func writeRelaySigners(db StateDB, id, checkpoint common.Hash, signers []common.Address) {
+	slot := relaySignersSlot(id, checkpoint)
+	db.SetState(RelayAddress, slot, common.BigToHash(new(big.Int).SetUint64(uint64(len(signers)))))
+	for i, signer := range signers {
+		db.SetState(RelayAddress, arraySlot(slot, uint64(i)), common.BytesToHash(signer[:]))
+	}
+}
+
+// VerifyRelayLog verifies a receipt proof against a canonical and confirmed
+// block of a relay and returns the log at logIndex of the receipt. The proof is
+// the RLP list of the receipts trie nodes on the path to the receipt of the
+// transaction at txIndex.
+func VerifyRelayLog(db StateDB, id, blockHash common.Hash, txIndex uint64, proof []byte, logIndex uint64) (*types.Log, error) {
+	relay, ok := ReadRelay(db, id)
+	if !ok {
+		return nil, errRelayUnknown
+	}
+	record, ok := ReadRelayRecord(db, id, blockHash)
+	if !ok {
+		return nil, errRelayUnknownBlock
+	}
+	if RelayCanonicalHash(db, id, record.Number) != blockHash {
+		return nil, errRelayNotCanonical
+	}
+	head, _ := ReadRelayRecord(db, id, relay.Head)
+	if head.Number-record.Number < relay.Confirmations {
+		return nil, errRelayUnconfirmed
+	}
+	var nodes [][]byte
+	if err := rlp.DecodeBytes(proof, &nodes); err != nil {
+		return nil, errRelayInvalidProof
+	}
+	// The proof database is keyed by Keccak256, the hash of the foreign trie.
+	proofDb := memorydb.New()
+	for _, node := range nodes {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	key, _ := rlp.EncodeToBytes(txIndex)
+	value, _, err := trie.VerifyProof(record.ReceiptHash, key, proofDb)
+	if err != nil || value == nil {
+		return nil, errRelayInvalidProof
+	}
+	// Typed receipts are prefixed by their type.
+	if len(value) > 0 && value[0] < 0x80 {
+		value = value[1:]
+	}
+	var receipt struct {
+		PostStateOrStatus []byte
+		CumulativeGasUsed uint64
+		Bloom             types.Bloom
+		Logs              []struct {
+			Address common.Address
+			Topics  []common.Hash
+			Data    []byte
+		}
+	}
+	if err := rlp.DecodeBytes(value, &receipt); err != nil {
+		return nil, errRelayInvalidProof
+	}
+	if logIndex >= uint64(len(receipt.Logs)) {
+		return nil, errRelayUnknownLog
+	}
+	l := receipt.Logs[logIndex]
+	return &types.Log{
+		Address:     l.Address,
+		Topics:      l.Topics,
+		Data:        l.Data,
+		BlockNumber: record.Number,
+		BlockHash:   blockHash,
+		TxIndex:     uint(txIndex),
+		Index:       uint(logIndex),
+	}, nil
+}
+
+// runRelay executes a call to the relay system contract.
+func runRelay(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
+	if len(input) < 4 {
+		return nil, errExecutionReverted
+	}
+	method, err := relayABI.MethodById(input[:4])
+	if err != nil {
+		return nil, errExecutionReverted
+	}
+	args, err := method.Inputs.UnpackValues(input[4:])
+	if err != nil {
+		return nil, errExecutionReverted
+	}
+	switch method.Name {
+	case "register":
+		signers := args[3].([]common.Address)
+		if !contract.UseGas(params.RelayRegisterGas + uint64(len(signers))*params.RelaySignerGas) {
+			return nil, ErrOutOfGas
+		}
+		if readOnly {
+			return nil, errWriteProtection
+		}
+		id, err := registerRelay(evm, contract, args[0].(uint64), args[1].(uint8), args[2].([]byte), signers, args[4].(uint64), args[5].(uint64), args[6].(uint64))
+		if err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(id)
+
+	case "submit":
+		if readOnly {
+			return nil, errWriteProtection
+		}
+		return nil, submitRelayHeaders(evm, contract, args[0].([32]byte), args[1].([]byte))
+
+	case "head":
+		if !contract.UseGas(params.RelayReadGas) {
+			return nil, ErrOutOfGas
+		}
+		relay, ok := ReadRelay(evm.StateDB, args[0].([32]byte))
+		if !ok {
+			return method.Outputs.Pack(common.Hash{}, uint64(0), new(big.Int))
+		}
+		head, _ := ReadRelayRecord(evm.StateDB, relay.ID, relay.Head)
+		return method.Outputs.Pack(head.Hash, head.Number, head.TotalDifficulty)
+
+	case "getHeader":
+		if !contract.UseGas(params.RelayReadGas) {
+			return nil, ErrOutOfGas
+		}
+		var (
+			id     = common.Hash(args[0].([32]byte))
+			hash   = common.Hash(args[1].([32]byte))
+			record = &RelayRecord{TotalDifficulty: new(big.Int)}
+			known  bool
+			confs  uint64
+		)
+		relay, ok := ReadRelay(evm.StateDB, id)
+		if ok {
+			var r *RelayRecord
+			if r, known = ReadRelayRecord(evm.StateDB, id, hash); known {
+				record = r
+			}
+		}
+		canonical := known && RelayCanonicalHash(evm.StateDB, id, record.Number) == hash
+		if canonical {
+			head, _ := ReadRelayRecord(evm.StateDB, id, relay.Head)
+			confs = head.Number - record.Number
+		}
+		return method.Outputs.Pack(record.Number, record.Time, record.ParentHash, record.Root, record.ReceiptHash, canonical, confs)
+
+	case "verifyLog":
+		proof := args[3].([]byte)
+		if !contract.UseGas(params.RelayVerifyGas + words(len(proof))*params.RelayProofWordGas) {
+			return nil, ErrOutOfGas
+		}
+		l, err := VerifyRelayLog(evm.StateDB, args[0].([32]byte), args[1].([32]byte), args[2].(uint64), proof, args[4].(uint64))
+		if err != nil {
+			return nil, errExecutionReverted
+		}
+		topics := make([][32]byte, len(l.Topics))
+		for i, topic := range l.Topics {
+			topics[i] = topic
+		}
+		return method.Outputs.Pack(l.Address, topics, l.Data)
+	}
+	return nil, errExecutionReverted
+}
+
+// registerRelay creates a relay of the caller starting at a checkpoint header
+// and emits the Registered event.
+func registerRelay(evm *EVM, contract *Contract, chainID uint64, engine uint8, checkpoint []byte, signers []common.Address, epoch, period, confirmations uint64) (common.Hash, error) {
+	// Delegate calls and call code would write the relay into the storage of
+	// the calling contract.
+	if contract.Address() != RelayAddress || contract.Value().Sign() != 0 {
+		return common.Hash{}, errExecutionReverted
+	}
+	var header RelayHeader
+	if err := rlp.DecodeBytes(checkpoint, &header); err != nil || header.Difficulty == nil || header.Difficulty.Sign() <= 0 || header.Difficulty.BitLen() > 128 || header.Number == nil || !header.Number.IsUint64() {
+		return common.Hash{}, errExecutionReverted
+	}
+	switch engine {
+	case RelayFstash:
+		if len(signers) > 0 || period > 0 || fstash.CacheSize(header.Number.Uint64()) == 0 {
+			return common.Hash{}, errExecutionReverted
+		}
+	case RelayClique:
+		if len(signers) == 0 || epoch == 0 || period > math.MaxUint32 {
+			return common.Hash{}, errExecutionReverted
+		}
+	default:
+		return common.Hash{}, errExecutionReverted
+	}
+	var (
+		db    = evm.StateDB
+		owner = contract.Caller()
+		id    = RelayID(owner, chainID)
+		hash  = header.Hash()
+	)
+	if _, ok := ReadRelay(db, id); ok {
+		return common.Hash{}, errExecutionReverted
+	}
+	// A nonce keeps the contract account from being deleted as empty.
+	if db.GetNonce(RelayAddress) == 0 {
+		db.SetNonce(RelayAddress, 1)
+	}
+	var info common.Hash
+	binary.BigEndian.PutUint32(info[0:4], uint32(period))
+	info[7] = engine
+	binary.BigEndian.PutUint64(info[8:16], chainID)
+	binary.BigEndian.PutUint64(info[16:24], epoch)
+	binary.BigEndian.PutUint64(info[24:32], confirmations)
+	db.SetState(RelayAddress, relaySlot(id, relayOwner), common.BytesToHash(owner[:]))
+	db.SetState(RelayAddress, relaySlot(id, relayInfo), info)
+	db.SetState(RelayAddress, relaySlot(id, relayHead), hash)
+
+	record := &RelayRecord{
+		Hash:            hash,
+		Number:          header.Number.Uint64(),
+		Time:            header.Time,
+		Difficulty:      header.Difficulty,
+		ParentHash:      header.ParentHash,
+		Root:            header.Root,
+		ReceiptHash:     header.ReceiptHash,
+		TotalDifficulty: header.Difficulty,
+	}
+	if engine == RelayClique {
+		sorted := make([]common.Address, len(signers))
+		copy(sorted, signers)
+		sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+		writeRelaySigners(db, id, hash, sorted)
+		record.Signers = hash
+	}
+	writeRelayRecord(db, id, record)
+	db.SetState(RelayAddress, relayCanonicalSlot(id, record.Number), hash)
+
+	event := relayABI.Events["Registered"]
+	data, err := event.Inputs.NonIndexed().Pack(chainID, engine)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	db.AddLog(&types.Log{
+		Address:     RelayAddress,
+		Topics:      []common.Hash{event.ID(), id, common.BytesToHash(owner[:])},
+		Data:        data,
+		BlockNumber: evm.BlockNumber.Uint64(),
+	})
+	return id, nil
+}
+
+// submitRelayHeaders verifies and stores a batch of headers of a relay, moving
+// the head of the relay to the header with the most total difficulty. Headers
+// already known are skipped, so that concurrent relayers don't fail.
+func submitRelayHeaders(evm *EVM, contract *Contract, id common.Hash, blob []byte) error {
+	if contract.Address() != RelayAddress || contract.Value().Sign() != 0 {
+		return errExecutionReverted
+	}
+	relay, ok := ReadRelay(evm.StateDB, id)
+	if !ok {
+		return errExecutionReverted
+	}
+	headers, err := DecodeRelayHeaders(blob)
+	if err != nil || len(headers) == 0 {
+		return errExecutionReverted
+	}
+	gas := params.RelayFstashGas
+	if relay.Engine == RelayClique {
+		gas = params.RelayCliqueGas
+	}
+	if !contract.UseGas(uint64(len(headers)) * gas) {
+		return ErrOutOfGas
+	}
+	head, _ := ReadRelayRecord(evm.StateDB, id, relay.Head)
+	for _, header := range headers {
+		record, err := verifyRelayHeader(evm.StateDB, contract, relay, header, evm.Time.Uint64())
+		if err != nil {
+			if err == ErrOutOfGas {
+				return err
+			}
+			return errExecutionReverted
+		}
+		if record != nil && record.TotalDifficulty.Cmp(head.TotalDifficulty) > 0 {
+			if err := setRelayHead(evm, contract, relay, head, record); err != nil {
+				return err
+			}
+			head = record
+		}
+	}
+	return nil
+}
+
+// verifyRelayHeader verifies a header against its parent and stores it. It
+// returns nil if the header is already known. Headers more than
+// relayFutureTime seconds after now are refused, so that a forged chain can't
+// lower its difficulty or pile up blocks faster than the real one.
+func verifyRelayHeader(db StateDB, contract *Contract, relay *Relay, header *RelayHeader, now uint64) (*RelayRecord, error) {
+	hash := header.Hash()
+	if _, ok := ReadRelayRecord(db, relay.ID, hash); ok {
+		return nil, nil
+	}
+	parent, ok := ReadRelayRecord(db, relay.ID, header.ParentHash)
+	if !ok {
+		return nil, errRelayUnknownParent
+	}
+	number := header.Number.Uint64()
+	if number != parent.Number+1 || header.GasUsed > header.GasLimit || header.Difficulty.Sign() <= 0 || header.Difficulty.BitLen() > 128 || header.Time > now+relayFutureTime {
+		return nil, errRelayInvalidHeader
+	}
+	record := &RelayRecord{
+		Hash:            hash,
+		Number:          number,
+		Time:            header.Time,
+		Difficulty:      header.Difficulty,
+		ParentHash:      header.ParentHash,
+		Root:            header.Root,
+		ReceiptHash:     header.ReceiptHash,
+		TotalDifficulty: new(big.Int).Add(parent.TotalDifficulty, header.Difficulty),
+	}
+	switch relay.Engine {
+	case RelayFstash:
+		// The seal hash of fstash covers the fields of the original header
+		// layout only.
+		if len(header.Rest) > 0 || len(header.Extra) > 32 || header.Time <= parent.Time || fstash.CacheSize(number) == 0 {
+			return nil, errRelayInvalidHeader
+		}
+		if header.Difficulty.Cmp(relayMinDifficulty(parent, header.Time)) < 0 {
+			return nil, errRelayInvalidHeader
+		}
+		if err := useRelayEpoch(db, contract, number); err != nil {
+			return nil, err
+		}
+		if err := relayPoW.VerifySeal(nil, &types.Header{
+			ParentHash:  header.ParentHash,
+			UncleHash:   header.UncleHash,
+			Coinbase:    header.Coinbase,
+			Root:        header.Root,
+			TxHash:      header.TxHash,
+			ReceiptHash: header.ReceiptHash,
+			Bloom:       header.Bloom,
+			Difficulty:  header.Difficulty,
+			Number:      header.Number,
+			GasLimit:    header.GasLimit,
+			GasUsed:     header.GasUsed,
+			Time:        header.Time,
+			Extra:       header.Extra,
+			MixDigest:   header.MixDigest,
+			Nonce:       header.Nonce,
+		}); err != nil {
+			return nil, errRelayInvalidSeal
+		}
+
+	case RelayClique:
+		if err := verifyCliqueHeader(db, contract, relay, parent, header, record); err != nil {
+			return nil, err
+		}
+	}
+	writeRelayRecord(db, relay.ID, record)
+	return record, nil
+}
+
+// relayMinDifficulty returns the lowest difficulty a child of parent sealed at
+// time may have under the difficulty rules of any fstash fork, without the
+// difficulty bomb, which only adds to it. The difficulty drops by at most
+// parent/2048 per 9 seconds since the parent and 99 parent/2048 in total, and
+// never below the minimum difficulty, unless the checkpoint already was.
+func relayMinDifficulty(parent *RelayRecord, time uint64) *big.Int {
+	steps := (time - parent.Time) / 9
+	if steps > 99 {
+		steps = 99
+	}
+	drop := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
+	drop.Mul(drop, new(big.Int).SetUint64(steps))
+	min := new(big.Int).Sub(parent.Difficulty, drop)
+
+	floor := params.MinimumDifficulty
+	if parent.Difficulty.Cmp(floor) < 0 {
+		floor = parent.Difficulty
+	}
+	if min.Cmp(floor) < 0 {
+		return floor
+	}
+	return min
+}
+
+// useRelayEpoch charges the generation of the fstash verification cache of the
+// epoch of number, unless the relays verified headers of the epoch recently,
+// and records the epoch as the most recent one. Generating a cache takes far
+// longer than verifying a seal with it, and relayPoW also starts generating the
+// cache of the following epoch, so both are charged.
+func useRelayEpoch(db StateDB, contract *Contract, number uint64) error {
+	var (
+		epoch  = number/fstash.EpochLength + 1
+		word   = db.GetState(RelayAddress, relayEpochsSlot)
+		recent = word[32-8*relayCachedEpochs:]
+	)
+	for i := 0; i < relayCachedEpochs; i++ {
+		if binary.BigEndian.Uint64(recent[8*i:]) == epoch {
+			copy(recent[8*i:], recent[8*i+8:])
+			binary.BigEndian.PutUint64(recent[len(recent)-8:], epoch)
+			db.SetState(RelayAddress, relayEpochsSlot, word)
+			return nil
+		}
+	}
+	items := (fstash.CacheSize(number) + fstash.CacheSize(number+fstash.EpochLength)) / 64
+	if !contract.UseGas(items * params.RelayCacheGas) {
+		return ErrOutOfGas
+	}
+	copy(recent, recent[8:])
+	binary.BigEndian.PutUint64(recent[len(recent)-8:], epoch)
+	db.SetState(RelayAddress, relayEpochsSlot, word)
+	return nil
+}
+
+// verifyCliqueHeader checks the seal of a Clique header against the signers of
+// the last checkpoint and fills in the signer fields of its record. Votes on
+// signers between checkpoints are not tracked, a changed signer set takes
+// effect at the next checkpoint.
+func verifyCliqueHeader(db StateDB, contract *Contract, relay *Relay, parent *RelayRecord, header *RelayHeader, record *RelayRecord) error {
+	if len(header.Extra) < cliqueVanity+cliqueSeal {
+		return errRelayInvalidHeader
+	}
+	var (
+		number     = record.Number
+		checkpoint = number%relay.Epoch == 0
+		list       = header.Extra[cliqueVanity : len(header.Extra)-cliqueSeal]
+	)
+	if checkpoint && (len(list) == 0 || len(list)%common.AddressLength != 0) || !checkpoint && len(list) != 0 {
+		return errRelayInvalidHeader
+	}
+	if header.MixDigest != (common.Hash{}) || header.UncleHash != relayEmptyUncleHash || header.Time < parent.Time+relay.Period {
+		return errRelayInvalidHeader
+	}
+	signer, err := header.cliqueSigner()
+	if err != nil {
+		return err
+	}
+	signers := ReadRelaySigners(db, relay.ID, parent.Signers)
+	authorized := false
+	for _, s := range signers {
+		if s == signer {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		return errRelayUnauthorized
+	}
+	// In-turn signers seal with difficulty 2, the others with difficulty 1.
+	want := big.NewInt(1)
+	if signers[number%uint64(len(signers))] == signer {
+		want = big.NewInt(2)
+	}
+	if header.Difficulty.Cmp(want) != 0 {
+		return errRelayInvalidHeader
+	}
+	// A signer may only seal one of any floor(signers/2)+1 consecutive blocks.
+	ancestor := parent
+	for i := 0; i < len(signers)/2; i++ {
+		if ancestor.Signer == signer {
+			return errRelayRecentlySigned
+		}
+		var ok bool
+		if ancestor, ok = ReadRelayRecord(db, relay.ID, ancestor.ParentHash); !ok {
+			break
+		}
+	}
+	record.Signer = signer
+	record.Signers = parent.Signers
+	if checkpoint {
+		if !contract.UseGas(uint64(len(list)/common.AddressLength) * params.RelaySignerGas) {
+			return ErrOutOfGas
+		}
+		next := make([]common.Address, len(list)/common.AddressLength)
+		for i := range next {
+			next[i] = common.BytesToAddress(list[i*common.AddressLength : (i+1)*common.AddressLength])
+		}
+		writeRelaySigners(db, relay.ID, record.Hash, next)
+		record.Signers = record.Hash
+	}
+	return nil
+}
+
+// setRelayHead moves the head of a relay to record, rewriting the canonical
+// chain down to the common ancestor with the old head, and emits the NewHead
+// event.
+func setRelayHead(evm *EVM, contract *Contract, relay *Relay, old, record *RelayRecord) error {
+	db := evm.StateDB
+	for n := record.Number + 1; n <= old.Number; n++ {
+		if !contract.UseGas(params.RelayCanonicalGas) {
+			return ErrOutOfGas
+		}
+		db.SetState(RelayAddress, relayCanonicalSlot(relay.ID, n), common.Hash{})
+	}
+	for r := record; RelayCanonicalHash(db, relay.ID, r.Number) != r.Hash; {
+		if !contract.UseGas(params.RelayCanonicalGas) {
+			return ErrOutOfGas
+		}
+		db.SetState(RelayAddress, relayCanonicalSlot(relay.ID, r.Number), r.Hash)
+
+		var ok bool
+		if r, ok = ReadRelayRecord(db, relay.ID, r.ParentHash); !ok {
+			break
+		}
+	}
+	db.SetState(RelayAddress, relaySlot(relay.ID, relayHead), record.Hash)
+	relay.Head = record.Hash
+
+	event := relayABI.Events["NewHead"]
+	data, err := event.Inputs.NonIndexed().Pack(record.Number)
+	if err != nil {
+		return err
+	}
+	db.AddLog(&types.Log{
+		Address:     RelayAddress,
+		Topics:      []common.Hash{event.ID(), relay.ID, record.Hash},
+		Data:        data,
+		BlockNumber: evm.BlockNumber.Uint64(),
+	})
+	return nil
+}
@@ -0,0 +1,399 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/consensus"
+	"github.com/filestorm/go-filestorm/consensus/fstash"
+	"github.com/filestorm/go-filestorm/core/rawdb"
+	"github.com/filestorm/go-filestorm/core/state"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb/memorydb"
+	"github.com/filestorm/go-filestorm/params"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/trie"
+)
+
+func newRelayEVM() *EVM {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1570000000),
+	}
+	return NewEVM(vmctx, statedb, params.AllPbftProtocolChanges, Config{})
+}
+
+func callRelay(evm *EVM, from common.Address, method string, args ...interface{}) ([]byte, error) {
+	return callRelayGas(evm, from, 100000000, method, args...)
+}
+
+func callRelayGas(evm *EVM, from common.Address, gas uint64, method string, args ...interface{}) ([]byte, error) {
+	input, err := relayABI.Pack(method, args...)
+	if err != nil {
+		panic(err)
+	}
+	ret, _, err := evm.Call(AccountRef(from), RelayAddress, input, gas, new(big.Int))
+	return ret, err
+}
+
+func encodeHeaders(headers ...*RelayHeader) []byte {
+	blob, err := rlp.EncodeToBytes(headers)
+	if err != nil {
+		panic(err)
+	}
+	return blob
+}
+
+// cliqueSigners seals Clique headers with a fixed set of signers.
+type cliqueSigners struct {
+	keys  map[common.Address]*ecdsa.PrivateKey
+	addrs []common.Address // sorted
+}
+
+func newCliqueSigners(n int) *cliqueSigners {
+	s := &cliqueSigners{keys: make(map[common.Address]*ecdsa.PrivateKey)}
+	for i := 0; i < n; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		s.keys[addr] = key
+		s.addrs = append(s.addrs, addr)
+	}
+	sort.Slice(s.addrs, func(i, j int) bool { return bytes.Compare(s.addrs[i][:], s.addrs[j][:]) < 0 })
+	return s
+}
+
+func (s *cliqueSigners) inturn(number uint64) common.Address {
+	return s.addrs[number%uint64(len(s.addrs))]
+}
+
+// child creates an unsealed child of parent, carrying the signer list if list
+// is set.
+func (s *cliqueSigners) child(parent *RelayHeader, signer common.Address, list bool) *RelayHeader {
+	number := new(big.Int).Add(parent.Number, common.Big1)
+	header := &RelayHeader{
+		ParentHash: parent.Hash(),
+		UncleHash:  relayEmptyUncleHash,
+		Difficulty: big.NewInt(1),
+		Number:     number,
+		GasLimit:   8000000,
+		Time:       parent.Time + 15,
+		Extra:      make([]byte, cliqueVanity),
+	}
+	if signer == s.inturn(number.Uint64()) {
+		header.Difficulty = big.NewInt(2)
+	}
+	if list {
+		for _, addr := range s.addrs {
+			header.Extra = append(header.Extra, addr[:]...)
+		}
+	}
+	return header
+}
+
+// sign appends the seal of signer to the extra data of header.
+func (s *cliqueSigners) sign(header *RelayHeader, signer common.Address) *RelayHeader {
+	enc, _ := rlp.EncodeToBytes(header)
+	sig, err := crypto.Sign(crypto.Keccak256(enc), s.keys[signer])
+	if err != nil {
+		panic(err)
+	}
+	header.Extra = append(header.Extra, sig...)
+	return header
+}
+
+func (s *cliqueSigners) seal(parent *RelayHeader, signer common.Address) *RelayHeader {
+	return s.sign(s.child(parent, signer, false), signer)
+}
+
+// receiptProof returns the receipts root of a block and the proof of the
+// receipt at index.
+func receiptProof(t *testing.T, receipts types.Receipts, index uint64) (common.Hash, []byte) {
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	for i, receipt := range receipts {
+		key, _ := rlp.EncodeToBytes(uint64(i))
+		enc, _ := rlp.EncodeToBytes(receipt)
+		tr.Update(key, enc)
+	}
+	proofDb := memorydb.New()
+	key, _ := rlp.EncodeToBytes(index)
+	if err := tr.Prove(key, 0, proofDb); err != nil {
+		t.Fatal(err)
+	}
+	var nodes [][]byte
+	it := proofDb.NewIterator()
+	for it.Next() {
+		nodes = append(nodes, common.CopyBytes(it.Value()))
+	}
+	it.Release()
+	proof, _ := rlp.EncodeToBytes(nodes)
+	return tr.Hash(), proof
+}
+
+func TestRelayClique(t *testing.T) {
+	var (
+		evm     = newRelayEVM()
+		owner   = common.BytesToAddress([]byte("owner"))
+		relayer = common.BytesToAddress([]byte("relayer"))
+		signers = newCliqueSigners(3)
+		genesis = &RelayHeader{
+			UncleHash:  relayEmptyUncleHash,
+			Difficulty: big.NewInt(1),
+			Number:     big.NewInt(0),
+			Time:       1500000000,
+		}
+	)
+	checkpoint, _ := rlp.EncodeToBytes(genesis)
+	ret, err := callRelay(evm, owner, "register", uint64(5), RelayClique, checkpoint, signers.addrs, uint64(4), uint64(15), uint64(1))
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	id := RelayID(owner, 5)
+	if common.BytesToHash(ret) != id {
+		t.Fatalf("relay id mismatch: have %x, want %x", ret, id)
+	}
+	if _, err := callRelay(evm, owner, "register", uint64(5), RelayClique, checkpoint, signers.addrs, uint64(4), uint64(15), uint64(1)); err != errExecutionReverted {
+		t.Fatalf("second registration: have %v, want %v", err, errExecutionReverted)
+	}
+	// An out of turn fork is overtaken by the in turn one.
+	h1 := signers.seal(genesis, signers.inturn(1))
+	a2 := signers.seal(h1, signers.addrs[0])
+	a3 := signers.seal(a2, signers.addrs[1])
+	if _, err := callRelay(evm, relayer, "submit", id, encodeHeaders(h1, a2, a3)); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if relay, _ := ReadRelay(evm.StateDB, id); relay.Head != a3.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", relay.Head, a3.Hash())
+	}
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, Logs: []*types.Log{}},
+		{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 50000, Logs: []*types.Log{
+			{Address: common.Address{0xaa}, Topics: []common.Hash{{1}, {2}}, Data: []byte("first")},
+			{Address: common.Address{0xbb}, Topics: []common.Hash{{3}}, Data: []byte("second")},
+		}},
+	}
+	root, proof := receiptProof(t, receipts, 1)
+	b2 := signers.child(h1, signers.inturn(2), false)
+	b2.ReceiptHash = root
+	signers.sign(b2, signers.inturn(2))
+	b3 := signers.seal(b2, signers.inturn(3))
+
+	if _, err := callRelay(evm, relayer, "verifyLog", id, b2.Hash(), uint64(1), proof, uint64(1)); err != errExecutionReverted {
+		t.Fatalf("unknown block: have %v, want %v", err, errExecutionReverted)
+	}
+	if _, err := callRelay(evm, relayer, "submit", id, encodeHeaders(b2)); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if RelayCanonicalHash(evm.StateDB, id, 2) != a2.Hash() {
+		t.Fatal("lighter fork became canonical")
+	}
+	if _, err := callRelay(evm, relayer, "verifyLog", id, b2.Hash(), uint64(1), proof, uint64(1)); err != errExecutionReverted {
+		t.Fatalf("non-canonical block: have %v, want %v", err, errExecutionReverted)
+	}
+	// Known headers are skipped.
+	if _, err := callRelay(evm, relayer, "submit", id, encodeHeaders(b2, b3)); err != nil {
+		t.Fatalf("resubmit failed: %v", err)
+	}
+	if relay, _ := ReadRelay(evm.StateDB, id); relay.Head != b3.Hash() {
+		t.Fatalf("head mismatch after reorg: have %x, want %x", relay.Head, b3.Hash())
+	}
+	if RelayCanonicalHash(evm.StateDB, id, 2) != b2.Hash() || RelayCanonicalHash(evm.StateDB, id, 1) != h1.Hash() {
+		t.Fatal("canonical chain not rewritten")
+	}
+	ret, err = callRelay(evm, relayer, "verifyLog", id, b2.Hash(), uint64(1), proof, uint64(1))
+	if err != nil {
+		t.Fatalf("verifyLog failed: %v", err)
+	}
+	out, err := relayABI.Methods["verifyLog"].Outputs.UnpackValues(ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0].(common.Address) != (common.Address{0xbb}) || !reflect.DeepEqual(out[1], [][32]byte{{3}}) || string(out[2].([]byte)) != "second" {
+		t.Fatalf("log mismatch: %v", out)
+	}
+	if _, err := callRelay(evm, relayer, "verifyLog", id, b2.Hash(), uint64(0), proof, uint64(0)); err != errExecutionReverted {
+		t.Fatalf("proof of another receipt: have %v, want %v", err, errExecutionReverted)
+	}
+	if _, err := callRelay(evm, relayer, "verifyLog", id, b3.Hash(), uint64(1), proof, uint64(1)); err != errExecutionReverted {
+		t.Fatalf("unconfirmed block: have %v, want %v", err, errExecutionReverted)
+	}
+	// Headers of strangers, recent signers, unknown parents and checkpoints
+	// without signers are rejected.
+	strangers := newCliqueSigners(1)
+	orphan := signers.seal(signers.seal(genesis, signers.addrs[0]), signers.addrs[1])
+	orphan.ParentHash = common.Hash{1}
+	early := signers.child(b3, signers.inturn(4), true)
+	early.Time = b3.Time + 14
+	future := signers.child(b3, signers.inturn(4), true)
+	future.Time = evm.Time.Uint64() + relayFutureTime + 1
+	invalid := map[string]*RelayHeader{
+		"stranger":         strangers.sign(signers.child(b3, signers.inturn(4), true), strangers.addrs[0]),
+		"recent signer":    signers.sign(signers.child(b3, signers.inturn(3), true), signers.inturn(3)),
+		"unknown parent":   orphan,
+		"checkpoint empty": signers.seal(b3, signers.inturn(4)),
+		"before period":    signers.sign(early, signers.inturn(4)),
+		"future":           signers.sign(future, signers.inturn(4)),
+	}
+	for name, header := range invalid {
+		if _, err := callRelay(evm, relayer, "submit", id, encodeHeaders(header)); err != errExecutionReverted {
+			t.Errorf("%s: have %v, want %v", name, err, errExecutionReverted)
+		}
+	}
+	h4 := signers.sign(signers.child(b3, signers.inturn(4), true), signers.inturn(4))
+	if _, err := callRelay(evm, relayer, "submit", id, encodeHeaders(h4)); err != nil {
+		t.Fatalf("checkpoint submit failed: %v", err)
+	}
+	if have := ReadRelaySigners(evm.StateDB, id, h4.Hash()); !reflect.DeepEqual(have, signers.addrs) {
+		t.Fatalf("checkpoint signers mismatch: have %v, want %v", have, signers.addrs)
+	}
+}
+
+func TestRelayFstash(t *testing.T) {
+	engine := fstash.NewTester(nil, false)
+	defer engine.Close()
+	defer func(pow consensus.Engine) { relayPoW = pow }(relayPoW)
+	relayPoW = engine
+
+	sealAt := func(parent *RelayHeader, time uint64) *RelayHeader {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			UncleHash:  relayEmptyUncleHash,
+			Difficulty: big.NewInt(100),
+			Number:     new(big.Int).Add(parent.Number, common.Big1),
+			GasLimit:   8000000,
+			Time:       time,
+		}
+		results := make(chan *types.Block)
+		if err := engine.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+			t.Fatal(err)
+		}
+		sealed := (<-results).Header()
+		return &RelayHeader{
+			ParentHash: sealed.ParentHash,
+			UncleHash:  sealed.UncleHash,
+			Difficulty: sealed.Difficulty,
+			Number:     sealed.Number,
+			GasLimit:   sealed.GasLimit,
+			Time:       sealed.Time,
+			Extra:      []byte{},
+			MixDigest:  sealed.MixDigest,
+			Nonce:      sealed.Nonce,
+		}
+	}
+	seal := func(parent *RelayHeader) *RelayHeader {
+		return sealAt(parent, parent.Time+13)
+	}
+	var (
+		evm     = newRelayEVM()
+		owner   = common.BytesToAddress([]byte("owner"))
+		genesis = &RelayHeader{Difficulty: big.NewInt(100), Number: big.NewInt(0), Time: 1500000000}
+	)
+	checkpoint, _ := rlp.EncodeToBytes(genesis)
+	if _, err := callRelay(evm, owner, "register", uint64(61), RelayFstash, checkpoint, []common.Address{}, uint64(0), uint64(0), uint64(0)); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	id := RelayID(owner, 61)
+
+	h1 := seal(genesis)
+	h2 := seal(h1)
+	forged := *h2
+	forged.Nonce = types.EncodeNonce(h2.Nonce.Uint64() + 1)
+	if _, err := callRelay(evm, owner, "submit", id, encodeHeaders(h1, &forged)); err != errExecutionReverted {
+		t.Fatalf("forged seal: have %v, want %v", err, errExecutionReverted)
+	}
+	if _, err := callRelay(evm, owner, "submit", id, encodeHeaders(h1, h2)); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	ret, err := callRelay(evm, owner, "head", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := relayABI.Methods["head"].Outputs.UnpackValues(ret)
+	if common.Hash(out[0].([32]byte)) != h2.Hash() || out[1].(uint64) != 2 || out[2].(*big.Int).Uint64() != 300 {
+		t.Fatalf("head mismatch: %v", out)
+	}
+
+	// Headers from the future are refused.
+	if _, err := callRelay(evm, owner, "submit", id, encodeHeaders(sealAt(h2, evm.Time.Uint64()+relayFutureTime+1))); err != errExecutionReverted {
+		t.Fatalf("future header: have %v, want %v", err, errExecutionReverted)
+	}
+	// The verification cache of a recently used epoch is not charged again,
+	// the one of another epoch is.
+	if _, err := callRelayGas(evm, owner, 1000000, "submit", id, encodeHeaders(seal(h2))); err != nil {
+		t.Fatalf("submit in a recent epoch failed: %v", err)
+	}
+	later := &RelayHeader{Difficulty: big.NewInt(100), Number: big.NewInt(5 * fstash.EpochLength), Time: 1560000000}
+	checkpoint, _ = rlp.EncodeToBytes(later)
+	if _, err := callRelay(evm, owner, "register", uint64(62), RelayFstash, checkpoint, []common.Address{}, uint64(0), uint64(0), uint64(0)); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	l1 := seal(later)
+	if _, err := callRelayGas(evm, owner, 1000000, "submit", RelayID(owner, 62), encodeHeaders(l1)); err != ErrOutOfGas {
+		t.Fatalf("submit in a new epoch: have %v, want %v", err, ErrOutOfGas)
+	}
+	if _, err := callRelay(evm, owner, "submit", RelayID(owner, 62), encodeHeaders(l1)); err != nil {
+		t.Fatalf("submit in a new epoch failed: %v", err)
+	}
+	// The difficulty can't drop faster than the fstash rules allow.
+	hard := &RelayHeader{Difficulty: big.NewInt(204800), Number: big.NewInt(0), Time: 1500000000}
+	checkpoint, _ = rlp.EncodeToBytes(hard)
+	if _, err := callRelay(evm, owner, "register", uint64(64), RelayFstash, checkpoint, []common.Address{}, uint64(0), uint64(0), uint64(0)); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if _, err := callRelay(evm, owner, "submit", RelayID(owner, 64), encodeHeaders(sealAt(hard, hard.Time+1000))); err != errExecutionReverted {
+		t.Fatalf("difficulty drop: have %v, want %v", err, errExecutionReverted)
+	}
+	// Headers beyond the precomputed epochs are refused.
+	last := &RelayHeader{Difficulty: big.NewInt(100), Number: big.NewInt(2048 * fstash.EpochLength), Time: 1600000000}
+	checkpoint, _ = rlp.EncodeToBytes(last)
+	if _, err := callRelay(evm, owner, "register", uint64(63), RelayFstash, checkpoint, []common.Address{}, uint64(0), uint64(0), uint64(0)); err != errExecutionReverted {
+		t.Fatalf("register beyond the last epoch: have %v, want %v", err, errExecutionReverted)
+	}
+}
+
+func TestRelayMinDifficulty(t *testing.T) {
+	tests := []struct {
+		parent  int64
+		elapsed uint64
+		min     int64
+	}{
+		{2048000, 8, 2048000},
+		{2048000, 9, 2047000},
+		{2048000, 13, 2047000},
+		{2048000, 90, 2038000},
+		{2048000, 100000, 1949000},
+		{135168, 100000, 131072},
+		{100, 13, 100},
+		{100, 100000, 100},
+	}
+	for _, tt := range tests {
+		parent := &RelayRecord{Difficulty: big.NewInt(tt.parent), Time: 1500000000}
+		if min := relayMinDifficulty(parent, parent.Time+tt.elapsed); min.Int64() != tt.min {
+			t.Errorf("parent %d, %d seconds: have %v, want %d", tt.parent, tt.elapsed, min, tt.min)
+		}
+	}
+}
@@ -31,7 +31,6 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/math"
-	"github.com/filestorm/go-filestorm/rlp"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -82,14 +81,13 @@ func Keccak512(data ...[]byte) []byte {
 
 // CreateAddress creates an filestorm address given the bytes and the nonce
 func CreateAddress(b common.Address, nonce uint64) common.Address {
-	data, _ := rlp.EncodeToBytes([]interface{}{b, nonce})
-	return common.BytesToAddress(Keccak256(data)[12:])
+	return Keccak256Hasher.CreateAddress(b, nonce)
 }
 
 // CreateAddress2 creates an filestorm address given the address bytes, initial
 // contract code hash and a salt.
 func CreateAddress2(b common.Address, salt [32]byte, inithash []byte) common.Address {
-	return common.BytesToAddress(Keccak256([]byte{0xff}, b.Bytes(), salt[:], inithash)[12:])
+	return Keccak256Hasher.CreateAddress2(b, salt, inithash)
 }
 
 // ToECDSA creates a private key with the given D value.
@@ -149,11 +147,17 @@ func UnmarshalPubkey(pub []byte) (*ecdsa.PublicKey, error) {
 	return &ecdsa.PublicKey{Curve: S256(), X: x, Y: y}, nil
 }
 
+// FromECDSAPub encodes a public key as an uncompressed point. Keys without a
+// curve are assumed to be secp256k1.
 func FromECDSAPub(pub *ecdsa.PublicKey) []byte {
 	if pub == nil || pub.X == nil || pub.Y == nil {
 		return nil
 	}
-	return elliptic.Marshal(S256(), pub.X, pub.Y)
+	curve := pub.Curve
+	if curve == nil {
+		curve = S256()
+	}
+	return elliptic.Marshal(curve, pub.X, pub.Y)
 }
 
 // HexToECDSA parses a secp256k1 private key.
@@ -210,9 +214,10 @@ func ValidateSignatureValues(v byte, r, s *big.Int, homestead bool) bool {
 	return r.Cmp(secp256k1N) < 0 && s.Cmp(secp256k1N) < 0 && (v == 0 || v == 1)
 }
 
+// PubkeyToAddress derives the address of a public key from the Keccak256 hash
+// of its uncompressed X and Y coordinates.
 func PubkeyToAddress(p ecdsa.PublicKey) common.Address {
-	pubBytes := FromECDSAPub(&p)
-	return common.BytesToAddress(Keccak256(pubBytes[1:])[12:])
+	return Keccak256Hasher.PubkeyToAddress(p)
 }
 
 func zeroBytes(bytes []byte) {
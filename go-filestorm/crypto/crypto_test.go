@@ -29,6 +29,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 )
 
 var testAddrHex = "970e8128ab834e8eac17ab8e3812f010678cf791"
@@ -43,6 +44,26 @@ func TestKeccak256Hash(t *testing.T) {
 	checkhash(t, "Sha3-256-array", func(in []byte) []byte { h := Keccak256Hash(in); return h[:] }, msg, exp)
 }
 
+func TestHasher(t *testing.T) {
+	msg := []byte("abc")
+	checkhash(t, "Keccak256-hasher", func(in []byte) []byte { h := Keccak256Hasher.Hash(in); return h[:] }, msg, Keccak256(msg))
+	exp, _ := hex.DecodeString("66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0")
+	checkhash(t, "SM3-hasher", func(in []byte) []byte { h := SM3Hasher.Hash(in); return h[:] }, msg, exp)
+
+	key, _ := HexToECDSA(testPrivHex)
+	pub := FromECDSAPub(&key.PublicKey)
+	sum := sm3.Sum(pub[1:])
+	checkAddr(t, common.HexToAddress(testAddrHex), Keccak256Hasher.PubkeyToAddress(key.PublicKey))
+	checkAddr(t, common.BytesToAddress(sum[12:]), SM3Hasher.PubkeyToAddress(key.PublicKey))
+	checkAddr(t, PubkeyToAddress(key.PublicKey), Keccak256Hasher.PubkeyToAddress(key.PublicKey))
+
+	creator := common.HexToAddress(testAddrHex)
+	checkAddr(t, CreateAddress(creator, 1), Keccak256Hasher.CreateAddress(creator, 1))
+	if SM3Hasher.CreateAddress(creator, 1) == CreateAddress(creator, 1) {
+		t.Error("SM3 contract address equals the Keccak256 contract address")
+	}
+}
+
 func TestToECDSAErrors(t *testing.T) {
 	if _, err := HexToECDSA("0000000000000000000000000000000000000000000000000000000000000000"); err == nil {
 		t.Fatal("HexToECDSA should've returned error")
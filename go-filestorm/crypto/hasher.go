@@ -0,0 +1,80 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"hash"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+	"github.com/filestorm/go-filestorm/rlp"
+	"golang.org/x/crypto/sha3"
+)
+
+// Hasher is the hash function of a chain. It hashes the blocks, transactions
+// and state trie of the chain and derives its addresses. The zero value is
+// Keccak256, the hash function of chains which do not select another one.
+type Hasher uint8
+
+const (
+	Keccak256Hasher Hasher = iota
+	SM3Hasher              // GM/T 0004 SM3
+)
+
+// IsSM3 reports whether the hash function is SM3.
+func (h Hasher) IsSM3() bool {
+	return h == SM3Hasher
+}
+
+// New creates a hash.Hash computing the hash function.
+func (h Hasher) New() hash.Hash {
+	if h == SM3Hasher {
+		return sm3.New()
+	}
+	return sha3.NewLegacyKeccak256()
+}
+
+// Hash calculates and returns the hash of the input data.
+func (h Hasher) Hash(data ...[]byte) (out common.Hash) {
+	d := h.New()
+	for _, b := range data {
+		d.Write(b)
+	}
+	d.Sum(out[:0])
+	return out
+}
+
+// PubkeyToAddress derives the address of a public key from the hash of its
+// uncompressed X and Y coordinates.
+func (h Hasher) PubkeyToAddress(p ecdsa.PublicKey) common.Address {
+	pubBytes := FromECDSAPub(&p)
+	return common.BytesToAddress(h.Hash(pubBytes[1:]).Bytes()[12:])
+}
+
+// CreateAddress creates the address of a contract given the address of its
+// creator and the nonce of the creation.
+func (h Hasher) CreateAddress(b common.Address, nonce uint64) common.Address {
+	data, _ := rlp.EncodeToBytes([]interface{}{b, nonce})
+	return common.BytesToAddress(h.Hash(data).Bytes()[12:])
+}
+
+// CreateAddress2 creates the address of a contract given the address of its
+// creator, the hash of its initial code and a salt.
+func (h Hasher) CreateAddress2(b common.Address, salt [32]byte, inithash []byte) common.Address {
+	return common.BytesToAddress(h.Hash([]byte{0xff}, b.Bytes(), salt[:], inithash).Bytes()[12:])
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm2
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"sync"
+)
+
+// curve is a short Weierstrass curve y² = x³ + ax + b over GF(p). Unlike
+// elliptic.CurveParams it does not assume a = -3, so it also covers the test
+// curve of GM/T 0003. The arithmetic uses Jacobian coordinates on big.Int and
+// is not constant time.
+type curve struct {
+	params *elliptic.CurveParams
+	a      *big.Int
+}
+
+var (
+	initOnce sync.Once
+	sm2p256  *curve
+)
+
+// P256 returns the sm2p256v1 curve recommended by GM/T 0003.5.
+func P256() elliptic.Curve {
+	initOnce.Do(func() {
+		sm2p256 = newCurve("sm2p256v1",
+			"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF",
+			"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFC",
+			"28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93",
+			"FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123",
+			"32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7",
+			"BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0",
+		)
+	})
+	return sm2p256
+}
+
+func fromHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("sm2: invalid curve constant " + s)
+	}
+	return n
+}
+
+func newCurve(name, p, a, b, n, gx, gy string) *curve {
+	params := &elliptic.CurveParams{
+		Name: name,
+		P:    fromHex(p),
+		B:    fromHex(b),
+		N:    fromHex(n),
+		Gx:   fromHex(gx),
+		Gy:   fromHex(gy),
+	}
+	params.BitSize = params.P.BitLen()
+	return &curve{params: params, a: fromHex(a)}
+}
+
+func (c *curve) Params() *elliptic.CurveParams {
+	return c.params
+}
+
+// A returns the a coefficient of the curve equation.
+func (c *curve) A() *big.Int {
+	return new(big.Int).Set(c.a)
+}
+
+// polynomial returns x³ + ax + b.
+func (c *curve) polynomial(x *big.Int) *big.Int {
+	p := c.params.P
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	ax := new(big.Int).Mul(c.a, x)
+	y2.Add(y2, ax)
+	y2.Add(y2, c.params.B)
+	return y2.Mod(y2, p)
+}
+
+func (c *curve) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	return c.polynomial(x).Cmp(y2) == 0
+}
+
+// decompress returns the point with the given x coordinate and y parity.
+func (c *curve) decompress(x *big.Int, odd bool) (*big.Int, *big.Int) {
+	if x.Sign() < 0 || x.Cmp(c.params.P) >= 0 {
+		return nil, nil
+	}
+	y := new(big.Int).ModSqrt(c.polynomial(x), c.params.P)
+	if y == nil {
+		return nil, nil
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(c.params.P, y)
+	}
+	return x, y
+}
+
+// jacobian is a point in Jacobian coordinates, z = 0 is the point at infinity.
+type jacobian struct {
+	x, y, z *big.Int
+}
+
+func (c *curve) toJacobian(x, y *big.Int) jacobian {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return jacobian{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	return jacobian{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+func (c *curve) toAffine(j jacobian) (*big.Int, *big.Int) {
+	if j.z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	p := c.params.P
+	zinv := new(big.Int).ModInverse(j.z, p)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+	x := new(big.Int).Mul(j.x, zinv2)
+	x.Mod(x, p)
+	zinv2.Mul(zinv2, zinv)
+	y := new(big.Int).Mul(j.y, zinv2)
+	y.Mod(y, p)
+	return x, y
+}
+
+func (c *curve) double(j jacobian) jacobian {
+	p := c.params.P
+	if j.z.Sign() == 0 || j.y.Sign() == 0 {
+		return jacobian{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	xx := new(big.Int).Mul(j.x, j.x)
+	yy := new(big.Int).Mul(j.y, j.y)
+	yy.Mod(yy, p)
+	zz := new(big.Int).Mul(j.z, j.z)
+	zz.Mod(zz, p)
+
+	// s = 4xy², m = 3x² + az⁴
+	s := new(big.Int).Mul(j.x, yy)
+	s.Lsh(s, 2)
+	s.Mod(s, p)
+	m := new(big.Int).Mul(zz, zz)
+	m.Mul(m, c.a)
+	m.Add(m, xx)
+	m.Add(m, xx)
+	m.Add(m, xx)
+	m.Mod(m, p)
+
+	// x3 = m² - 2s, y3 = m(s - x3) - 8y⁴, z3 = 2yz
+	x3 := new(big.Int).Mul(m, m)
+	x3.Sub(x3, s)
+	x3.Sub(x3, s)
+	x3.Mod(x3, p)
+	y3 := new(big.Int).Sub(s, x3)
+	y3.Mul(y3, m)
+	yyyy := new(big.Int).Mul(yy, yy)
+	yyyy.Lsh(yyyy, 3)
+	y3.Sub(y3, yyyy)
+	y3.Mod(y3, p)
+	z3 := new(big.Int).Mul(j.y, j.z)
+	z3.Lsh(z3, 1)
+	z3.Mod(z3, p)
+	return jacobian{x3, y3, z3}
+}
+
+func (c *curve) add(j1, j2 jacobian) jacobian {
+	p := c.params.P
+	if j1.z.Sign() == 0 {
+		return j2
+	}
+	if j2.z.Sign() == 0 {
+		return j1
+	}
+	z1z1 := new(big.Int).Mul(j1.z, j1.z)
+	z1z1.Mod(z1z1, p)
+	z2z2 := new(big.Int).Mul(j2.z, j2.z)
+	z2z2.Mod(z2z2, p)
+	u1 := new(big.Int).Mul(j1.x, z2z2)
+	u1.Mod(u1, p)
+	u2 := new(big.Int).Mul(j2.x, z1z1)
+	u2.Mod(u2, p)
+	s1 := new(big.Int).Mul(j1.y, j2.z)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, p)
+	s2 := new(big.Int).Mul(j2.y, j1.z)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, p)
+
+	if u1.Cmp(u2) == 0 {
+		if s1.Cmp(s2) == 0 {
+			return c.double(j1)
+		}
+		return jacobian{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	h := new(big.Int).Sub(u2, u1)
+	r := new(big.Int).Sub(s2, s1)
+	hh := new(big.Int).Mul(h, h)
+	hh.Mod(hh, p)
+	hhh := new(big.Int).Mul(h, hh)
+	hhh.Mod(hhh, p)
+	v := new(big.Int).Mul(u1, hh)
+	v.Mod(v, p)
+
+	// x3 = r² - h³ - 2v, y3 = r(v - x3) - s1h³, z3 = z1z2h
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, hhh)
+	x3.Sub(x3, v)
+	x3.Sub(x3, v)
+	x3.Mod(x3, p)
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	s1.Mul(s1, hhh)
+	y3.Sub(y3, s1)
+	y3.Mod(y3, p)
+	z3 := new(big.Int).Mul(j1.z, j2.z)
+	z3.Mul(z3, h)
+	z3.Mod(z3, p)
+	return jacobian{x3, y3, z3}
+}
+
+func (c *curve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.add(c.toJacobian(x1, y1), c.toJacobian(x2, y2)))
+}
+
+func (c *curve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	return c.toAffine(c.double(c.toJacobian(x1, y1)))
+}
+
+func (c *curve) ScalarMult(bx, by *big.Int, k []byte) (*big.Int, *big.Int) {
+	b := c.toJacobian(bx, by)
+	r := jacobian{new(big.Int), new(big.Int), new(big.Int)}
+	for _, byte := range k {
+		for bit := 0; bit < 8; bit++ {
+			r = c.double(r)
+			if byte&0x80 == 0x80 {
+				r = c.add(r, b)
+			}
+			byte <<= 1
+		}
+	}
+	return c.toAffine(r)
+}
+
+func (c *curve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
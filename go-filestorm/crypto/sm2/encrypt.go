@@ -0,0 +1,138 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common/math"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+)
+
+// Overhead is the number of bytes Encrypt adds to the plaintext: the
+// uncompressed point C1 and the SM3 hash C3.
+const Overhead = 65 + sm3.Size
+
+var errDecrypt = errors.New("sm2: decryption failed")
+
+// KDF is the key derivation function of GM/T 0003: the concatenation of
+// SM3(z || ct) for a 32-bit big endian counter ct starting at 1, truncated to
+// klen bytes.
+func KDF(z []byte, klen int) []byte {
+	var (
+		out = make([]byte, 0, klen+sm3.Size)
+		ct  [4]byte
+		h   = sm3.New()
+	)
+	for i := uint32(1); len(out) < klen; i++ {
+		binary.BigEndian.PutUint32(ct[:], i)
+		h.Reset()
+		h.Write(z)
+		h.Write(ct[:])
+		out = h.Sum(out)
+	}
+	return out[:klen]
+}
+
+// Encrypt encrypts msg to pub with the public key encryption algorithm of
+// GM/T 0003.4. The ciphertext is C1 || C3 || C2 where C1 is the uncompressed
+// ephemeral point, C3 the SM3 check hash and C2 the encrypted message.
+func Encrypt(random io.Reader, pub *ecdsa.PublicKey, msg []byte) ([]byte, error) {
+	for {
+		k, err := randScalar(pub.Curve, random)
+		if err != nil {
+			return nil, err
+		}
+		if ct, ok := encryptWithK(pub, msg, k); ok {
+			return ct, nil
+		}
+	}
+}
+
+// encryptWithK encrypts msg with the ephemeral scalar k. It reports false
+// when the derived key stream is all zero and k has to be replaced.
+func encryptWithK(pub *ecdsa.PublicKey, msg []byte, k *big.Int) ([]byte, bool) {
+	c := pub.Curve
+	size := byteLen(c)
+	x1, y1 := c.ScalarBaseMult(k.Bytes())
+	x2, y2 := c.ScalarMult(pub.X, pub.Y, k.Bytes())
+
+	t := KDF(append(math.PaddedBigBytes(x2, size), math.PaddedBigBytes(y2, size)...), len(msg))
+	if len(msg) > 0 && allZero(t) {
+		return nil, false
+	}
+	ct := make([]byte, 0, 1+2*size+sm3.Size+len(msg))
+	ct = append(ct, elliptic.Marshal(c, x1, y1)...)
+	ct = append(ct, checkHash(x2, y2, size, msg)...)
+	for i := range msg {
+		ct = append(ct, msg[i]^t[i])
+	}
+	return ct, true
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt.
+func Decrypt(priv *ecdsa.PrivateKey, ct []byte) ([]byte, error) {
+	c := priv.Curve
+	size := byteLen(c)
+	if len(ct) < 1+2*size+sm3.Size || ct[0] != 4 {
+		return nil, errDecrypt
+	}
+	x1 := new(big.Int).SetBytes(ct[1 : 1+size])
+	y1 := new(big.Int).SetBytes(ct[1+size : 1+2*size])
+	if !c.IsOnCurve(x1, y1) {
+		return nil, errDecrypt
+	}
+	x2, y2 := c.ScalarMult(x1, y1, priv.D.Bytes())
+
+	c3, c2 := ct[1+2*size:1+2*size+sm3.Size], ct[1+2*size+sm3.Size:]
+	t := KDF(append(math.PaddedBigBytes(x2, size), math.PaddedBigBytes(y2, size)...), len(c2))
+	if len(c2) > 0 && allZero(t) {
+		return nil, errDecrypt
+	}
+	msg := make([]byte, len(c2))
+	for i := range c2 {
+		msg[i] = c2[i] ^ t[i]
+	}
+	if subtle.ConstantTimeCompare(c3, checkHash(x2, y2, size, msg)) != 1 {
+		return nil, errDecrypt
+	}
+	return msg, nil
+}
+
+// checkHash computes C3 = SM3(x2 || msg || y2).
+func checkHash(x2, y2 *big.Int, size int, msg []byte) []byte {
+	h := sm3.New()
+	h.Write(math.PaddedBigBytes(x2, size))
+	h.Write(msg)
+	h.Write(math.PaddedBigBytes(y2, size))
+	return h.Sum(nil)
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
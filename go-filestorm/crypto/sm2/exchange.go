@@ -0,0 +1,78 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common/math"
+)
+
+var errKeyExchange = errors.New("sm2: key exchange failed")
+
+// KeyExchange computes the shared key of the key exchange protocol of GM/T
+// 0003.3 from the local static and ephemeral keys and the remote ones. Both
+// sides derive the same klen bytes; initiator tells which side is user A, whose
+// identity hash comes first in the key derivation. The optional key
+// confirmation hashes of the standard are not computed.
+func KeyExchange(klen int, initiator bool, uid, remoteUID []byte, priv, ephemeral *ecdsa.PrivateKey, remotePub, remoteEphemeral *ecdsa.PublicKey) ([]byte, error) {
+	c := priv.Curve
+	if ephemeral.Curve != c || remotePub.Curve != c || remoteEphemeral.Curve != c {
+		return nil, errKeyExchange
+	}
+	if !c.IsOnCurve(remotePub.X, remotePub.Y) || !c.IsOnCurve(remoteEphemeral.X, remoteEphemeral.Y) {
+		return nil, errInvalidPublicKey
+	}
+	n := c.Params().N
+
+	// t = (d + x̄1·r) mod n
+	t := new(big.Int).Mul(reduceX(c.Params(), ephemeral.X), ephemeral.D)
+	t.Add(t, priv.D)
+	t.Mod(t, n)
+
+	// U = [h·t](P + [x̄2]R), the cofactor h of the SM2 curves is 1.
+	x, y := c.ScalarMult(remoteEphemeral.X, remoteEphemeral.Y, reduceX(c.Params(), remoteEphemeral.X).Bytes())
+	x, y = c.Add(remotePub.X, remotePub.Y, x, y)
+	x, y = c.ScalarMult(x, y, t.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errKeyExchange
+	}
+
+	za, zb := ZA(&priv.PublicKey, uid), ZA(remotePub, remoteUID)
+	if !initiator {
+		za, zb = zb, za
+	}
+	size := byteLen(c)
+	z := make([]byte, 0, 2*size+len(za)+len(zb))
+	z = append(z, math.PaddedBigBytes(x, size)...)
+	z = append(z, math.PaddedBigBytes(y, size)...)
+	z = append(z, za...)
+	z = append(z, zb...)
+	return KDF(z, klen), nil
+}
+
+// reduceX computes x̄ = 2^w + (x & (2^w - 1)) with w = ⌈⌈log2(n)⌉/2⌉ - 1.
+func reduceX(params *elliptic.CurveParams, x *big.Int) *big.Int {
+	w := uint((params.N.BitLen()+1)/2 - 1)
+	mask := new(big.Int).Lsh(one, w)
+	v := new(big.Int).Sub(mask, one)
+	v.And(v, x)
+	return v.Add(v, mask)
+}
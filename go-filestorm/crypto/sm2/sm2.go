@@ -0,0 +1,328 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package sm2 implements the SM2 elliptic curve signature algorithm as defined
+// in GM/T 0003-2012 on top of the generic crypto/ecdsa key types, so SM2 keys
+// can be passed around wherever the code base expects an *ecdsa.PrivateKey.
+package sm2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common/math"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+)
+
+// SignatureLength is the size of a recoverable signature: [R || S || V] where
+// V is the parity of the y coordinate of the random point kG.
+const SignatureLength = 65
+
+// DefaultUID is the user identity the standard recommends when none was agreed on.
+var DefaultUID = []byte("1234567812345678")
+
+var (
+	one = big.NewInt(1)
+
+	errInvalidPrivateKey = errors.New("sm2: invalid private key")
+	errInvalidPublicKey  = errors.New("sm2: invalid public key")
+	errInvalidSignature  = errors.New("sm2: invalid signature")
+)
+
+// IsSM2 reports whether the key lives on the sm2p256v1 curve.
+func IsSM2(pub *ecdsa.PublicKey) bool {
+	return pub != nil && pub.Curve == P256()
+}
+
+func byteLen(c elliptic.Curve) int {
+	return (c.Params().BitSize + 7) / 8
+}
+
+// GenerateKey generates a new sm2p256v1 private key.
+func GenerateKey(random io.Reader) (*ecdsa.PrivateKey, error) {
+	return generateKey(P256(), random)
+}
+
+func generateKey(c elliptic.Curve, random io.Reader) (*ecdsa.PrivateKey, error) {
+	d, err := randScalar(c, random)
+	if err != nil {
+		return nil, err
+	}
+	// d must be below n-1 so that 1+d is invertible.
+	for d.Cmp(new(big.Int).Sub(c.Params().N, one)) >= 0 {
+		if d, err = randScalar(c, random); err != nil {
+			return nil, err
+		}
+	}
+	return privateKey(c, d), nil
+}
+
+func privateKey(c elliptic.Curve, d *big.Int) *ecdsa.PrivateKey {
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.PublicKey.Curve = c
+	priv.PublicKey.X, priv.PublicKey.Y = c.ScalarBaseMult(d.Bytes())
+	return priv
+}
+
+// randScalar returns a random integer in [1, n-1].
+func randScalar(c elliptic.Curve, random io.Reader) (*big.Int, error) {
+	k, err := rand.Int(random, new(big.Int).Sub(c.Params().N, one))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, one), nil
+}
+
+// ToECDSA creates an sm2p256v1 private key with the given D value.
+func ToECDSA(d []byte) (*ecdsa.PrivateKey, error) {
+	c := P256()
+	if 8*len(d) != c.Params().BitSize {
+		return nil, errInvalidPrivateKey
+	}
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() <= 0 || k.Cmp(new(big.Int).Sub(c.Params().N, one)) >= 0 {
+		return nil, errInvalidPrivateKey
+	}
+	return privateKey(c, k), nil
+}
+
+// UnmarshalPubkey converts an uncompressed point to an sm2p256v1 public key.
+func UnmarshalPubkey(pub []byte) (*ecdsa.PublicKey, error) {
+	c := P256()
+	size := byteLen(c)
+	if len(pub) != 1+2*size || pub[0] != 4 {
+		return nil, errInvalidPublicKey
+	}
+	x := new(big.Int).SetBytes(pub[1 : 1+size])
+	y := new(big.Int).SetBytes(pub[1+size:])
+	if !c.IsOnCurve(x, y) {
+		return nil, errInvalidPublicKey
+	}
+	return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, nil
+}
+
+// CompressPubkey encodes a public key to the 33-byte compressed format.
+func CompressPubkey(pub *ecdsa.PublicKey) []byte {
+	size := byteLen(pub.Curve)
+	b := make([]byte, 1+size)
+	b[0] = 2 | byte(pub.Y.Bit(0))
+	copy(b[1:], math.PaddedBigBytes(pub.X, size))
+	return b
+}
+
+// DecompressPubkey parses a public key in the 33-byte compressed format.
+func DecompressPubkey(pub []byte) (*ecdsa.PublicKey, error) {
+	c := P256()
+	if len(pub) != 1+byteLen(c) || (pub[0] != 2 && pub[0] != 3) {
+		return nil, errInvalidPublicKey
+	}
+	x, y := c.(*curve).decompress(new(big.Int).SetBytes(pub[1:]), pub[0] == 3)
+	if x == nil {
+		return nil, errInvalidPublicKey
+	}
+	return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, nil
+}
+
+// ZA returns the hash of the signer's identity and the curve parameters that
+// prefixes the message in the standard signature scheme.
+func ZA(pub *ecdsa.PublicKey, uid []byte) []byte {
+	params := pub.Curve.Params()
+	size := byteLen(pub.Curve)
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+	if c, ok := pub.Curve.(*curve); ok {
+		a = c.a
+	}
+	entl := len(uid) * 8
+
+	h := sm3.New()
+	h.Write([]byte{byte(entl >> 8), byte(entl)})
+	h.Write(uid)
+	for _, v := range []*big.Int{a, params.B, params.Gx, params.Gy, pub.X, pub.Y} {
+		h.Write(math.PaddedBigBytes(v, size))
+	}
+	return h.Sum(nil)
+}
+
+// Digest returns e = SM3(ZA || msg), the value the standard scheme signs.
+func Digest(pub *ecdsa.PublicKey, uid, msg []byte) []byte {
+	h := sm3.New()
+	h.Write(ZA(pub, uid))
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// Sign signs msg for the given user identity as specified by GM/T 0003.2.
+func Sign(random io.Reader, priv *ecdsa.PrivateKey, uid, msg []byte) (r, s *big.Int, err error) {
+	e := new(big.Int).SetBytes(Digest(&priv.PublicKey, uid, msg))
+	for {
+		k, err := randScalar(priv.Curve, random)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r, s, _, ok := signWithK(priv, e, k); ok {
+			return r, s, nil
+		}
+	}
+}
+
+// Verify checks a signature created by Sign.
+func Verify(pub *ecdsa.PublicKey, uid, msg []byte, r, s *big.Int) bool {
+	e := new(big.Int).SetBytes(Digest(pub, uid, msg))
+	return verify(pub, e, r, s)
+}
+
+// signWithK computes the signature of e with the nonce k. It also returns the
+// parity of kG and reports false when k has to be replaced.
+func signWithK(priv *ecdsa.PrivateKey, e, k *big.Int) (r, s *big.Int, odd bool, ok bool) {
+	n := priv.Curve.Params().N
+	x1, y1 := priv.Curve.ScalarBaseMult(k.Bytes())
+
+	// r = (e + x1) mod n, r != 0 and r + k != n
+	r = new(big.Int).Add(e, x1)
+	r.Mod(r, n)
+	if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+		return nil, nil, false, false
+	}
+	// s = (1 + d)⁻¹ (k - rd) mod n, s != 0
+	dInv := new(big.Int).Add(priv.D, one)
+	dInv.ModInverse(dInv, n)
+	s = new(big.Int).Mul(r, priv.D)
+	s.Sub(k, s)
+	s.Mul(s, dInv)
+	s.Mod(s, n)
+	if s.Sign() == 0 {
+		return nil, nil, false, false
+	}
+	// A recovered signature can only restore x1 mod n.
+	if x1.Cmp(n) >= 0 {
+		return nil, nil, false, false
+	}
+	return r, s, y1.Bit(0) == 1, true
+}
+
+func validScalar(v, n *big.Int) bool {
+	return v != nil && v.Sign() > 0 && v.Cmp(n) < 0
+}
+
+func verify(pub *ecdsa.PublicKey, e, r, s *big.Int) bool {
+	c := pub.Curve
+	n := c.Params().N
+	if !validScalar(r, n) || !validScalar(s, n) {
+		return false
+	}
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	// R = (e + x1) mod n where (x1, y1) = sG + tP
+	x1, y1 := c.ScalarBaseMult(s.Bytes())
+	x2, y2 := c.ScalarMult(pub.X, pub.Y, t.Bytes())
+	x1, _ = c.Add(x1, y1, x2, y2)
+	x1.Add(x1, e)
+	x1.Mod(x1, n)
+	return x1.Cmp(r) == 0
+}
+
+// SignDigest creates a recoverable signature of a 32 byte digest in the
+// [R || S || V] format where V is 0 or 1. The digest is used as e directly,
+// like SM2_sign_ex of the reference implementation does: the identity hash ZA
+// depends on the public key and would make the signer unrecoverable.
+func SignDigest(digest []byte, priv *ecdsa.PrivateKey) ([]byte, error) {
+	if len(digest) != 32 {
+		return nil, errors.New("sm2: hash is required to be exactly 32 bytes")
+	}
+	if !validScalar(priv.D, new(big.Int).Sub(priv.Curve.Params().N, one)) {
+		return nil, errInvalidPrivateKey
+	}
+	e := new(big.Int).SetBytes(digest)
+	for {
+		k, err := randScalar(priv.Curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r, s, odd, ok := signWithK(priv, e, k)
+		if !ok {
+			continue
+		}
+		size := byteLen(priv.Curve)
+		sig := make([]byte, SignatureLength)
+		copy(sig[:size], math.PaddedBigBytes(r, size))
+		copy(sig[size:2*size], math.PaddedBigBytes(s, size))
+		if odd {
+			sig[2*size] = 1
+		}
+		return sig, nil
+	}
+}
+
+// RecoverPubkey returns the sm2p256v1 public key that created the given
+// signature of digest with SignDigest.
+func RecoverPubkey(digest, sig []byte) (*ecdsa.PublicKey, error) {
+	return recoverPubkey(P256(), digest, sig)
+}
+
+func recoverPubkey(c elliptic.Curve, digest, sig []byte) (*ecdsa.PublicKey, error) {
+	size := byteLen(c)
+	if len(sig) != 2*size+1 || sig[2*size] > 1 {
+		return nil, errInvalidSignature
+	}
+	n := c.Params().N
+	e := new(big.Int).SetBytes(digest)
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size : 2*size])
+	if !validScalar(r, n) || !validScalar(s, n) {
+		return nil, errInvalidSignature
+	}
+
+	// x1 = (r - e) mod n, kG = (x1, y1)
+	x1 := new(big.Int).Sub(r, e)
+	x1.Mod(x1, n)
+	rx, ry := c.(*curve).decompress(x1, sig[2*size] == 1)
+	if rx == nil {
+		return nil, errInvalidSignature
+	}
+	// s(1 + d) = k - rd, so P = dG = (s + r)⁻¹ (kG - sG)
+	t := new(big.Int).Add(s, r)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return nil, errInvalidSignature
+	}
+	t.ModInverse(t, n)
+	sx, sy := c.ScalarBaseMult(new(big.Int).Sub(n, s).Bytes())
+	px, py := c.Add(rx, ry, sx, sy)
+	px, py = c.ScalarMult(px, py, t.Bytes())
+	if px.Sign() == 0 && py.Sign() == 0 {
+		return nil, errInvalidSignature
+	}
+	return &ecdsa.PublicKey{Curve: c, X: px, Y: py}, nil
+}
+
+// VerifyDigest checks a signature created by SignDigest. The signature can
+// be 64 bytes [R || S] or 65 bytes with the recovery id.
+func VerifyDigest(pub *ecdsa.PublicKey, digest, sig []byte) bool {
+	size := byteLen(pub.Curve)
+	if len(sig) != 2*size && len(sig) != 2*size+1 {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size : 2*size])
+	return verify(pub, new(big.Int).SetBytes(digest), r, s)
+}
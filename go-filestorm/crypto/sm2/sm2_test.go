@@ -0,0 +1,254 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm2
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// testCurve is the Fp-256 curve of the examples in GM/T 0003.2 appendix A.
+var testCurve = newCurve("gmt0003-test",
+	"8542D69E4C044F18E8B92435BF6FF7DE457283915C45517D722EDB8B08F1DFC3",
+	"787968B4FA32C3FD2417842E73BBFEFF2F3C848B6831D7E0EC65228B3937E498",
+	"63E4C6D3B23B0C849CF84241484BFE48F61D59A5B16BA06E6E12D1DA27C5249A",
+	"8542D69E4C044F18E8B92435BF6FF7DD297720630485628D5AE74EE7C32E79B7",
+	"421DEBD61B62EAB6746434EBC3CC315E32220B3BADD50BDC4C4E6C147FEDD43D",
+	"0680512BCBB42C07D47349D2153B70C4E5D7FDFCBFA36EA1A85841B9E46E09A2",
+)
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestStandardVector(t *testing.T) {
+	priv := privateKey(testCurve, fromHex("128B2FA8BD433C6C068C8D803DFF79792A519A55171B1B650C23661D15897263"))
+	if priv.X.Cmp(fromHex("0AE4C7798AA0F119471BEE11825BE46202BB79E2A5844495E97C04FF4DF2548A")) != 0 ||
+		priv.Y.Cmp(fromHex("7C0240F88F1CD4E16352A73C17B7F16F07353E53A176D684A9FE0C6BB798E857")) != 0 {
+		t.Fatalf("public key mismatch: %x %x", priv.X, priv.Y)
+	}
+	uid, msg := []byte("ALICE123@YAHOO.COM"), []byte("message digest")
+	if za := ZA(&priv.PublicKey, uid); !bytes.Equal(za, hexBytes("F4A38489E32B45B6F876E3AC2168CA392362DC8F23459C1D1146FC3DBFB7BC9A")) {
+		t.Fatalf("ZA mismatch: %x", za)
+	}
+	e := Digest(&priv.PublicKey, uid, msg)
+	if !bytes.Equal(e, hexBytes("B524F552CD82B8B028476E005C377FB19A87E6FC682D48BB5D42E3D9B9EFFE76")) {
+		t.Fatalf("e mismatch: %x", e)
+	}
+
+	k := fromHex("6CB28D99385C175C94F94E934817663FC176D925DD72B727260DBAAE1FB2F96F")
+	r, s, _, ok := signWithK(priv, new(big.Int).SetBytes(e), k)
+	if !ok {
+		t.Fatal("signing with the standard nonce failed")
+	}
+	if r.Cmp(fromHex("40F1EC59F793D9F49E09DCEF49130D4194F79FB1EED2CAA55BACDB49C4E755D1")) != 0 ||
+		s.Cmp(fromHex("6FC6DAC32C5D5CF10C77DFB20F7C2EB667A457872FB09EC56327A67EC7DEEBE7")) != 0 {
+		t.Fatalf("signature mismatch: r=%x s=%x", r, s)
+	}
+	if !Verify(&priv.PublicKey, uid, msg, r, s) {
+		t.Fatal("standard signature did not verify")
+	}
+	if Verify(&priv.PublicKey, uid, []byte("message digesT"), r, s) {
+		t.Fatal("signature verified a different message")
+	}
+}
+
+func TestCurve(t *testing.T) {
+	c := P256()
+	params := c.Params()
+	if !c.IsOnCurve(params.Gx, params.Gy) {
+		t.Fatal("generator is not on the curve")
+	}
+	if x, y := c.ScalarBaseMult(params.N.Bytes()); x.Sign() != 0 || y.Sign() != 0 {
+		t.Fatal("nG is not the point at infinity")
+	}
+	// 2G + G == 3G
+	x2, y2 := c.Double(params.Gx, params.Gy)
+	x3, y3 := c.Add(x2, y2, params.Gx, params.Gy)
+	if x, y := c.ScalarBaseMult([]byte{3}); x.Cmp(x3) != 0 || y.Cmp(y3) != 0 {
+		t.Fatal("3G mismatch")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("filestorm")
+	r, s, err := Sign(rand.Reader, priv, DefaultUID, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(&priv.PublicKey, DefaultUID, msg, r, s) {
+		t.Fatal("signature did not verify")
+	}
+	if Verify(&priv.PublicKey, []byte("another user"), msg, r, s) {
+		t.Fatal("signature verified for another identity")
+	}
+}
+
+func TestSignDigestRecover(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := hexBytes("B524F552CD82B8B028476E005C377FB19A87E6FC682D48BB5D42E3D9B9EFFE76")
+	for i := 0; i < 4; i++ {
+		sig, err := SignDigest(digest, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := RecoverPubkey(digest, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			t.Fatalf("recovered wrong key: %x %x", pub.X, pub.Y)
+		}
+		if !VerifyDigest(&priv.PublicKey, digest, sig) || !VerifyDigest(pub, digest, sig[:64]) {
+			t.Fatal("digest signature did not verify")
+		}
+		sig[64] ^= 1
+		if pub, err := RecoverPubkey(digest, sig); err == nil && pub.X.Cmp(priv.X) == 0 {
+			t.Fatal("flipped recovery id recovered the signer")
+		}
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	d := make([]byte, 32)
+	priv.D.FillBytes(d)
+	priv2, err := ToECDSA(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv2.X.Cmp(priv.X) != 0 || !IsSM2(&priv2.PublicKey) {
+		t.Fatal("key mismatch after encoding")
+	}
+	pub := make([]byte, 65)
+	pub[0] = 4
+	priv.X.FillBytes(pub[1:33])
+	priv.Y.FillBytes(pub[33:])
+	if _, err := UnmarshalPubkey(pub); err != nil {
+		t.Fatal(err)
+	}
+	pub[64] ^= 1
+	if _, err := UnmarshalPubkey(pub); err == nil {
+		t.Fatal("accepted a point off the curve")
+	}
+	if _, err := ToECDSA(new(big.Int).Sub(P256().Params().N, one).Bytes()); err == nil {
+		t.Fatal("accepted d = n-1")
+	}
+	if IsSM2(&ecdsa.PublicKey{}) {
+		t.Fatal("empty key reported as sm2")
+	}
+}
+
+func TestEncryptStandardVector(t *testing.T) {
+	priv := privateKey(testCurve, fromHex("1649AB77A00637BD5E2EFE283FBF353534AA7F7CB89463F208DDBC2920BB0DA0"))
+	msg := []byte("encryption standard")
+	k := fromHex("4C62EEFD6ECFC2B95B92FD6C3D9575148AFA17425546D49018E5388D49DD7B4F")
+	ct, ok := encryptWithK(&priv.PublicKey, msg, k)
+	if !ok {
+		t.Fatal("encryption with the standard nonce failed")
+	}
+	want := hexBytes("04" +
+		"245C26FB68B1DDDDB12C4B6BF9F2B6D5FE60A383B0D18D1C4144ABF17F6252E7" +
+		"76CB9264C2A7E88E52B19903FDC47378F605E36811F5C07423A24B84400F01B8" +
+		"9C3D7360C30156FAB7C80A0276712DA9D8094A634B766D3A285E07480653426D" +
+		"650053A89B41C418B0C3AAD00D886C00286467")
+	if !bytes.Equal(ct, want) {
+		t.Fatalf("ciphertext mismatch: %x", ct)
+	}
+	dec, err := Decrypt(priv, ct)
+	if err != nil || !bytes.Equal(dec, msg) {
+		t.Fatalf("decryption mismatch: %q %v", dec, err)
+	}
+}
+
+func TestKeyExchangeStandardVector(t *testing.T) {
+	var (
+		a  = privateKey(testCurve, fromHex("6FCBA2EF9AE0AB902BC3BDE3FF915D44BA4CC78F88E2F8E7F8996D3B8CCEEDEE"))
+		b  = privateKey(testCurve, fromHex("5E35D7D3F3C54DBAC72E61819E730B019A84208CA3A35E4C2E353DFCCB2A3B53"))
+		ra = privateKey(testCurve, fromHex("83A2C9C8B96E5AF70BD480B472409A9A327257F1EBB73F5B073354B248668563"))
+		rb = privateKey(testCurve, fromHex("33FE21940342161C55619C4A0C060293D543C80AF19748CE176D83477DE71C80"))
+
+		uidA, uidB = []byte("ALICE123@YAHOO.COM"), []byte("BILL456@YAHOO.COM")
+	)
+	ka, err := KeyExchange(16, true, uidA, uidB, a, ra, &b.PublicKey, &rb.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := KeyExchange(16, false, uidB, uidA, b, rb, &a.PublicKey, &ra.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hexBytes("55B0AC62A6B927BA23703832C853DED4")
+	if !bytes.Equal(ka, want) || !bytes.Equal(kb, want) {
+		t.Fatalf("shared key mismatch: %x %x", ka, kb)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	msg := []byte("filestorm")
+	ct, err := Encrypt(rand.Reader, &priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ct) != len(msg)+Overhead {
+		t.Fatalf("ciphertext length %d, want %d", len(ct), len(msg)+Overhead)
+	}
+	if dec, err := Decrypt(priv, ct); err != nil || !bytes.Equal(dec, msg) {
+		t.Fatalf("decryption mismatch: %q %v", dec, err)
+	}
+	ct[len(ct)-1] ^= 1
+	if _, err := Decrypt(priv, ct); err == nil {
+		t.Fatal("decrypted a modified ciphertext")
+	}
+	other, _ := GenerateKey(rand.Reader)
+	ct[len(ct)-1] ^= 1
+	if _, err := Decrypt(other, ct); err == nil {
+		t.Fatal("decrypted with the wrong key")
+	}
+}
+
+func TestCompressPubkey(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		priv, _ := GenerateKey(rand.Reader)
+		pub, err := DecompressPubkey(CompressPubkey(&priv.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 || !IsSM2(pub) {
+			t.Fatalf("key mismatch after compression")
+		}
+	}
+	if _, err := DecompressPubkey(make([]byte, 33)); err == nil {
+		t.Fatal("accepted an invalid prefix")
+	}
+}
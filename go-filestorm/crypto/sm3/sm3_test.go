@@ -0,0 +1,61 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test vectors from appendix A of GM/T 0004-2012.
+var vectors = []struct {
+	in, out string
+}{
+	{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+	{strings.Repeat("abcd", 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+}
+
+func TestVectors(t *testing.T) {
+	for i, v := range vectors {
+		want, _ := hex.DecodeString(v.out)
+		if sum := Sum([]byte(v.in)); !bytes.Equal(sum[:], want) {
+			t.Errorf("vector %d: Sum = %x, want %x", i, sum, want)
+		}
+		// Feed the input byte by byte to exercise the buffering.
+		h := New()
+		for j := 0; j < len(v.in); j++ {
+			h.Write([]byte{v.in[j]})
+		}
+		if sum := h.Sum(nil); !bytes.Equal(sum, want) {
+			t.Errorf("vector %d: streamed sum = %x, want %x", i, sum, want)
+		}
+		// Sum must not change the state of the hash.
+		if sum := h.Sum(nil); !bytes.Equal(sum, want) {
+			t.Errorf("vector %d: second sum = %x, want %x", i, sum, want)
+		}
+	}
+}
+
+func BenchmarkSum1K(b *testing.B) {
+	data := make([]byte, 1024)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Sum(data)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm4
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from GM/T 0002-2012 appendix A.
+func TestStandardVector(t *testing.T) {
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	want, _ := hex.DecodeString("681edf34d206965e86b3e94f536e4246")
+
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, BlockSize)
+	c.Encrypt(out, key)
+	if !bytes.Equal(out, want) {
+		t.Errorf("encrypt: have %x, want %x", out, want)
+	}
+	c.Decrypt(out, out)
+	if !bytes.Equal(out, key) {
+		t.Errorf("decrypt: have %x, want %x", out, key)
+	}
+}
+
+func TestStandardVectorIterated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 1000000 encryptions in short mode")
+	}
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	want, _ := hex.DecodeString("595298c7c6fd271f0402f804c33d3f66")
+
+	c, _ := NewCipher(key)
+	out := append([]byte{}, key...)
+	for i := 0; i < 1000000; i++ {
+		c.Encrypt(out, out)
+	}
+	if !bytes.Equal(out, want) {
+		t.Errorf("have %x, want %x", out, want)
+	}
+}
+
+func TestKeySize(t *testing.T) {
+	for _, n := range []int{0, 15, 17, 32} {
+		if _, err := NewCipher(make([]byte, n)); err != KeySizeError(n) {
+			t.Errorf("key size %d: expected KeySizeError, got %v", n, err)
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	iv := make([]byte, BlockSize)
+	plain := bytes.Repeat([]byte("filestorm"), 7)[:48]
+
+	c, _ := NewCipher(key)
+	enc := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(c, iv).CryptBlocks(enc, plain)
+	dec := make([]byte, len(enc))
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(dec, enc)
+	if !bytes.Equal(dec, plain) {
+		t.Errorf("CBC round trip: have %x, want %x", dec, plain)
+	}
+	cipher.NewCTR(c, iv).XORKeyStream(enc, plain)
+	cipher.NewCTR(c, iv).XORKeyStream(dec, enc)
+	if !bytes.Equal(dec, plain) {
+		t.Errorf("CTR round trip: have %x, want %x", dec, plain)
+	}
+}
@@ -332,7 +332,7 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 		} else {
 			results[i].RLP = fmt.Sprintf("0x%x", rlpBytes)
 		}
-		if results[i].Block, err = fstapi.RPCMarshalBlock(block, true, true); err != nil {
+		if results[i].Block, err = fstapi.RPCMarshalBlock(block, true, true, api.fst.blockchain.Config()); err != nil {
 			results[i].Block = map[string]interface{}{"error": err.Error()}
 		}
 	}
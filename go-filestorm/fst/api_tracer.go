@@ -147,7 +147,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 
 	// Ensure we have a valid starting state before doing any work
 	origin := start.NumberU64()
-	database := state.NewDatabaseWithCache(api.fst.ChainDb(), 16) // Chain tracing will probably start at genesis
+	database := state.NewDatabaseWithCache(api.fst.ChainDb(), 16, api.fst.blockchain.Config().Hasher()) // Chain tracing will probably start at genesis
 
 	if number := start.NumberU64(); number > 0 {
 		start = api.fst.blockchain.GetBlock(start.ParentHash(), start.NumberU64()-1)
@@ -641,7 +641,7 @@ func (api *PrivateDebugAPI) computeStateDB(block *types.Block, reexec uint64) (*
 	}
 	// Otherwise try to reexec blocks until we find a state or reach our limit
 	origin := block.NumberU64()
-	database := state.NewDatabaseWithCache(api.fst.ChainDb(), 16)
+	database := state.NewDatabaseWithCache(api.fst.ChainDb(), 16, api.fst.blockchain.Config().Hasher())
 
 	for i := uint64(0); i < reexec; i++ {
 		block = api.fst.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
@@ -703,7 +703,7 @@ func (api *PrivateDebugAPI) computeStateDB(block *types.Block, reexec uint64) (*
 // and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
 	// Retrieve the transaction and assemble its EVM context
-	tx, blockHash, _, index := rawdb.ReadTransaction(api.fst.ChainDb(), hash)
+	tx, blockHash, _, index := rawdb.ReadTransaction(api.fst.ChainDb(), hash, api.fst.blockchain.Config().Hasher())
 	if tx == nil {
 		return nil, fmt.Errorf("transaction %#x not found", hash)
 	}
@@ -0,0 +1,261 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/node"
+	"github.com/filestorm/go-filestorm/p2p"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// appchainName restricts the names of hosted appchains to the characters that
+// are safe to use in file paths, protocol names and RPC namespaces.
+var appchainName = regexp.MustCompile("^[a-z][a-z0-9]*$")
+
+// AppchainConfig is the configuration of an appchain hosted by the node next to
+// its primary chain. Settings not listed here are inherited from the primary
+// chain configuration.
+type AppchainConfig struct {
+	Name          string         // Unique name of the chain, prefixing its data directory, protocol and RPC namespaces
+	Genesis       string         // Path of the genesis JSON file of the chain
+	NetworkId     uint64         // Network ID to use for selecting peers to connect to
+	Stormbase     common.Address `toml:",omitempty"` // Public address for block mining rewards (default = first account)
+	Mine          bool           `toml:",omitempty"` // Whether to seal blocks of the chain
+	FlushContract string         `toml:",omitempty"` // AppChainBase contract on the main chain the sealed blocks are flushed into
+	DatabaseCache int            `toml:",omitempty"` // Megabytes of database cache (default = primary chain setting)
+}
+
+// AppchainNamespace returns the RPC namespace the given API namespace of the
+// named appchain is served under, e.g. alpha.fst for alpha's fst namespace.
+func AppchainNamespace(chain, namespace string) string {
+	return chain + "." + namespace
+}
+
+// appchainPath returns the path of the given file or folder of the named
+// appchain, relative to the instance directory of the node.
+func appchainPath(chain, path string) string {
+	return filepath.Join("appchains", chain, path)
+}
+
+// Appchains is a node.Service running several appchains in one node. They all
+// share the p2p server and the account manager of the node, but each of them
+// keeps its own database, event mux, protocol and RPC namespaces.
+type Appchains struct {
+	configs []AppchainConfig
+	chains  []*Filestorm
+}
+
+// NewAppchains creates the appchains of the given configs, deriving the rest of
+// their settings from the primary chain configuration.
+func NewAppchains(ctx *node.ServiceContext, config *Config, configs []AppchainConfig) (*Appchains, error) {
+	if err := validateAppchains(configs); err != nil {
+		return nil, err
+	}
+	// Check all genesis configs before opening any database.
+	chainConfigs := make([]*Config, len(configs))
+	for i, c := range configs {
+		genesis, err := readAppchainGenesis(c.Genesis)
+		if err != nil {
+			return nil, fmt.Errorf("appchain %s: %v", c.Name, err)
+		}
+		chainConfigs[i] = appchainConfig(config, c, genesis)
+		if err := validateAppchainGenesis(chainConfigs[i]); err != nil {
+			return nil, fmt.Errorf("appchain %s: %v", c.Name, err)
+		}
+	}
+	a := &Appchains{configs: configs}
+	for i, c := range configs {
+		chain, err := newFilestorm(ctx, chainConfigs[i], c.Name)
+		if err != nil {
+			a.close()
+			return nil, fmt.Errorf("appchain %s: %v", c.Name, err)
+		}
+		a.chains = append(a.chains, chain)
+		log.Info("Hosting appchain", "name", c.Name, "network", c.NetworkId, "genesis", chain.blockchain.Genesis().Hash())
+	}
+	return a, nil
+}
+
+// validateAppchains checks that the appchain configs can be hosted together.
+func validateAppchains(configs []AppchainConfig) error {
+	names := make(map[string]bool)
+	for _, c := range configs {
+		if !appchainName.MatchString(c.Name) {
+			return fmt.Errorf("invalid appchain name %q, must be lower case alphanumeric", c.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate appchain name %q", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Genesis == "" {
+			return fmt.Errorf("appchain %s: missing genesis file", c.Name)
+		}
+	}
+	return nil
+}
+
+// validateAppchainGenesis checks the genesis chain config of an appchain
+// against its settings.
+func validateAppchainGenesis(config *Config) error {
+	chainConfig := config.Genesis.Config
+	if chainConfig == nil {
+		return errors.New("genesis file without chain config")
+	}
+	// Flushes are sent for the flush blocks of the pbft engine only
+	if config.Miner.Flush.Enabled && chainConfig.Pbft == nil {
+		return errors.New("flushing blocks requires the pbft engine")
+	}
+	return nil
+}
+
+// readAppchainGenesis reads the genesis block specification of an appchain.
+func readAppchainGenesis(path string) (*core.Genesis, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	return genesis, nil
+}
+
+// appchainConfig derives the configuration of an appchain from the one of the
+// primary chain.
+func appchainConfig(base *Config, c AppchainConfig, genesis *core.Genesis) *Config {
+	config := *base
+	config.Genesis = genesis
+	config.NetworkId = c.NetworkId
+
+	// Drop the settings bound to the primary chain or its database
+	config.Whitelist = nil
+	config.Checkpoint = nil
+	config.CheckpointOracle = nil
+	config.OverrideIstanbul = nil
+	config.OverrideMuirGlacier = nil
+	config.DatabaseFreezer = ""
+	config.DatabaseFreezerIPFS = ""
+	config.LightServ, config.LightPeers = 0, 0
+
+	if config.TxPool.Journal != "" {
+		config.TxPool.Journal = appchainPath(c.Name, filepath.Base(config.TxPool.Journal))
+	}
+	if c.DatabaseCache > 0 {
+		config.DatabaseCache = c.DatabaseCache
+	}
+	config.Miner.Stormbase = c.Stormbase
+	config.Miner.Flush.Contract = c.FlushContract
+	config.Miner.Flush.Enabled = config.Miner.Flush.Enabled && c.FlushContract != ""
+
+	return &config
+}
+
+// Chains returns the hosted appchains in configuration order.
+func (a *Appchains) Chains() []*Filestorm {
+	return a.chains
+}
+
+// Chain returns the appchain with the given name, or nil if it isn't hosted.
+func (a *Appchains) Chain(name string) *Filestorm {
+	for _, chain := range a.chains {
+		if chain.name == name {
+			return chain
+		}
+	}
+	return nil
+}
+
+// StartMining starts sealing blocks on the appchains configured to mine.
+func (a *Appchains) StartMining(threads int) error {
+	for i, chain := range a.chains {
+		if !a.configs[i].Mine {
+			continue
+		}
+		if err := chain.StartMining(threads); err != nil {
+			return fmt.Errorf("appchain %s: %v", chain.name, err)
+		}
+	}
+	return nil
+}
+
+// Protocols implements node.Service, returning the protocols of all hosted
+// appchains. Their names are suffixed with the chain name, so peers only run
+// the protocols of the chains they have in common.
+func (a *Appchains) Protocols() []p2p.Protocol {
+	var protos []p2p.Protocol
+	for _, chain := range a.chains {
+		protos = append(protos, chain.Protocols()...)
+	}
+	return protos
+}
+
+// APIs implements node.Service, returning the APIs of all hosted appchains in
+// namespaces prefixed with the chain name.
+func (a *Appchains) APIs() []rpc.API {
+	var apis []rpc.API
+	for _, chain := range a.chains {
+		for _, api := range chain.APIs() {
+			api.Namespace = AppchainNamespace(chain.name, api.Namespace)
+			apis = append(apis, api)
+		}
+	}
+	return apis
+}
+
+// Start implements node.Service, starting all hosted appchains.
+func (a *Appchains) Start(srvr *p2p.Server) error {
+	for i, chain := range a.chains {
+		if err := chain.Start(srvr); err != nil {
+			for _, started := range a.chains[:i] {
+				started.Stop()
+			}
+			return fmt.Errorf("appchain %s: %v", chain.name, err)
+		}
+	}
+	return nil
+}
+
+// Stop implements node.Service, stopping all hosted appchains.
+func (a *Appchains) Stop() error {
+	for _, chain := range a.chains {
+		chain.Stop()
+	}
+	return nil
+}
+
+// close releases the databases of the appchains created so far when the
+// service fails to be constructed.
+func (a *Appchains) close() {
+	for _, chain := range a.chains {
+		chain.blockchain.Stop()
+		chain.chainDb.Close()
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fst
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/consensus/fstash"
+	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/node"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+// writeAppchainGenesis writes the genesis of a fstash chain with the given chain
+// id and hash function into dir, returning the path of the file.
+func writeAppchainGenesis(t *testing.T, dir string, chainID int64, hashFunction string) string {
+	config := *params.AllFstashProtocolChanges
+	config.ChainID = big.NewInt(chainID)
+	config.HashFunction = hashFunction
+
+	blob, err := json.Marshal(&core.Genesis{
+		Config:     &config,
+		ExtraData:  []byte(config.ChainID.String()),
+		Difficulty: big.NewInt(1),
+		GasLimit:   params.GenesisGasLimit,
+		Alloc:      core.GenesisAlloc{},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode genesis: %v", err)
+	}
+	path := filepath.Join(dir, config.ChainID.String()+".json")
+	if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	return path
+}
+
+// newAppchainNode creates an in-memory node running a primary fstash chain and
+// the given appchains.
+func newAppchainNode(t *testing.T, configs []AppchainConfig) *node.Node {
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	config := &Config{Genesis: &core.Genesis{Config: params.AllFstashProtocolChanges}}
+	config.Fstash.PowMode = fstash.ModeFake
+
+	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return New(ctx, config)
+	})
+	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return NewAppchains(ctx, config, configs)
+	})
+	return stack
+}
+
+func TestAppchains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appchains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stack := newAppchainNode(t, []AppchainConfig{
+		{Name: "alpha", Genesis: writeAppchainGenesis(t, dir, 101, ""), NetworkId: 1001},
+		{Name: "beta", Genesis: writeAppchainGenesis(t, dir, 102, ""), NetworkId: 1002},
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	var (
+		primary *Filestorm
+		chains  *Appchains
+	)
+	if err := stack.Service(&primary); err != nil {
+		t.Fatalf("primary chain missing: %v", err)
+	}
+	if err := stack.Service(&chains); err != nil {
+		t.Fatalf("appchains missing: %v", err)
+	}
+	// Every chain has its own genesis, database and event mux
+	alpha, beta := chains.Chain("alpha"), chains.Chain("beta")
+	if alpha == nil || beta == nil || chains.Chain("gamma") != nil {
+		t.Fatalf("hosted chains mismatch: alpha %v, beta %v", alpha != nil, beta != nil)
+	}
+	if id := alpha.BlockChain().Config().ChainID; id.Int64() != 101 {
+		t.Errorf("alpha chain id mismatch: have %v, want 101", id)
+	}
+	if alpha.BlockChain().Genesis().Hash() == beta.BlockChain().Genesis().Hash() || alpha.BlockChain().Genesis().Hash() == primary.BlockChain().Genesis().Hash() {
+		t.Errorf("appchains share a genesis")
+	}
+	if alpha.ChainDb() == beta.ChainDb() || alpha.ChainDb() == primary.ChainDb() {
+		t.Errorf("appchains share a database")
+	}
+	if alpha.EventMux() == beta.EventMux() || alpha.EventMux() == primary.EventMux() {
+		t.Errorf("appchains share an event mux")
+	}
+	// The protocols of the appchains are told apart by name
+	names := make(map[string]int)
+	for _, proto := range chains.Protocols() {
+		names[proto.Name]++
+	}
+	for _, name := range []string{"fst-alpha", "fst-beta"} {
+		if names[name] != len(ProtocolVersions) {
+			t.Errorf("protocol %s versions mismatch: have %d, want %d", name, names[name], len(ProtocolVersions))
+		}
+	}
+	if len(names) != 2 {
+		t.Errorf("protocol names mismatch: have %v", names)
+	}
+	// The APIs of the appchains are served in prefixed namespaces
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	defer client.Close()
+
+	for namespace, want := range map[string]int64{"fst": 1337, "alpha.fst": 101, "beta.fst": 102} {
+		var id hexutil.Big
+		if err := client.Call(&id, namespace+"_chainId"); err != nil {
+			t.Fatalf("%s_chainId failed: %v", namespace, err)
+		}
+		if id.ToInt().Int64() != want {
+			t.Errorf("%s_chainId mismatch: have %v, want %d", namespace, id.ToInt(), want)
+		}
+	}
+	var version string
+	if err := client.Call(&version, "beta.net_version"); err != nil {
+		t.Fatalf("beta.net_version failed: %v", err)
+	}
+	if version != "1002" {
+		t.Errorf("beta network id mismatch: have %s, want 1002", version)
+	}
+}
+
+func TestAppchainsHashFunction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appchains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stack := newAppchainNode(t, []AppchainConfig{
+		{Name: "sm", Genesis: writeAppchainGenesis(t, dir, 103, params.HashFunctionSM3), NetworkId: 1003},
+		{Name: "keccak", Genesis: writeAppchainGenesis(t, dir, 104, ""), NetworkId: 1004},
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	var (
+		primary *Filestorm
+		chains  *Appchains
+	)
+	if err := stack.Service(&primary); err != nil {
+		t.Fatalf("primary chain missing: %v", err)
+	}
+	if err := stack.Service(&chains); err != nil {
+		t.Fatalf("appchains missing: %v", err)
+	}
+	// Every chain hashes with its own hash function
+	for _, tt := range []struct {
+		chain *Filestorm
+		sm3   bool
+	}{{primary, false}, {chains.Chain("sm"), true}, {chains.Chain("keccak"), false}} {
+		genesis, id := tt.chain.BlockChain().Genesis(), tt.chain.BlockChain().Config().ChainID
+		if genesis.Hasher().IsSM3() != tt.sm3 {
+			t.Errorf("chain %v: SM3 hashing mismatch: have %v, want %v", id, genesis.Hasher().IsSM3(), tt.sm3)
+		}
+		if have := tt.chain.BlockChain().GetHeaderByNumber(0).Hash(); have != genesis.Hash() {
+			t.Errorf("chain %v: stored genesis hash mismatch: have %x, want %x", id, have, genesis.Hash())
+		}
+	}
+}
+
+func TestAppchainGenesisValidation(t *testing.T) {
+	sm3 := *params.AllPbftProtocolChanges
+	sm3.HashFunction = params.HashFunctionSM3
+
+	tests := []struct {
+		chainConfig *params.ChainConfig
+		flush       bool
+		err         string
+	}{
+		{params.AllFstashProtocolChanges, false, ""},
+		{params.AllPbftProtocolChanges, true, ""},
+		{&sm3, true, ""},
+		{nil, false, "without chain config"},
+		{params.AllFstashProtocolChanges, true, "pbft engine"},
+		{params.AllCliqueProtocolChanges, true, "pbft engine"},
+	}
+	for i, tt := range tests {
+		config := &Config{Genesis: &core.Genesis{Config: tt.chainConfig}}
+		config.Miner.Flush.Enabled = tt.flush
+		err := validateAppchainGenesis(config)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestAppchainsValidation(t *testing.T) {
+	tests := []struct {
+		configs []AppchainConfig
+		err     string
+	}{
+		{[]AppchainConfig{{Name: "alpha", Genesis: "alpha.json"}, {Name: "beta2", Genesis: "beta.json"}}, ""},
+		{[]AppchainConfig{{Name: "", Genesis: "alpha.json"}}, "invalid appchain name"},
+		{[]AppchainConfig{{Name: "Alpha", Genesis: "alpha.json"}}, "invalid appchain name"},
+		{[]AppchainConfig{{Name: "al_pha", Genesis: "alpha.json"}}, "invalid appchain name"},
+		{[]AppchainConfig{{Name: "alpha", Genesis: "alpha.json"}, {Name: "alpha", Genesis: "beta.json"}}, "duplicate appchain name"},
+		{[]AppchainConfig{{Name: "alpha"}}, "missing genesis file"},
+	}
+	for i, tt := range tests {
+		err := validateAppchains(tt.configs)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/filestorm/go-filestorm/fst/filters"
 	"github.com/filestorm/go-filestorm/fst/gasprice"
 	"github.com/filestorm/go-filestorm/fstdb"
+	"github.com/filestorm/go-filestorm/fstipfs"
 	"github.com/filestorm/go-filestorm/internal/fstapi"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/miner"
@@ -66,6 +67,7 @@ type LesServer interface {
 // Filestorm implements the Filestorm full node service.
 type Filestorm struct {
 	config *Config
+	name   string // Name of a hosted appchain, empty for the primary chain
 
 	// Channel for shutting down the service
 	shutdownChan chan bool
@@ -114,6 +116,13 @@ func (s *Filestorm) SetContractBackend(backend bind.ContractBackend) {
 // New creates a new Filestorm object (including the
 // initialisation of the common Filestorm object)
 func New(ctx *node.ServiceContext, config *Config) (*Filestorm, error) {
+	return newFilestorm(ctx, config, "")
+}
+
+// newFilestorm creates the Filestorm object of the chain with the given name.
+// The empty name denotes the primary chain of the node, any other one a hosted
+// appchain keeping its data, protocol and events apart from the other chains.
+func newFilestorm(ctx *node.ServiceContext, config *Config, name string) (*Filestorm, error) {
 	// Ensure configuration values are compatible and sane
 	if config.SyncMode == downloader.LightSync {
 		return nil, errors.New("can't run fst.Filestorm in light sync mode, use les.LightFilestorm")
@@ -132,7 +141,22 @@ func New(ctx *node.ServiceContext, config *Config) (*Filestorm, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Filestorm object
-	chainDb, err := ctx.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "fst/db/chaindata/")
+	var (
+		chainDb fstdb.Database
+		err     error
+
+		dbName, dbNamespace = "chaindata", "fst/db/chaindata/"
+		eventMux            = ctx.EventMux
+	)
+	if name != "" {
+		dbName, dbNamespace = appchainPath(name, "chaindata"), "fst/db/"+name+"/chaindata/"
+		eventMux = new(event.TypeMux)
+	}
+	if config.DatabaseFreezerIPFS != "" {
+		chainDb, err = ctx.OpenDatabaseWithAncientStore(dbName, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, dbNamespace, fstipfs.Opener(config.DatabaseFreezerIPFS))
+	} else {
+		chainDb, err = ctx.OpenDatabaseWithFreezer(dbName, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, dbNamespace)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -140,13 +164,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Filestorm, error) {
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
+	if config.Miner.Flush.Enabled && chainConfig.Pbft == nil {
+		return nil, errors.New("flushing blocks requires the pbft engine")
+	}
 	log.Info("Initialised chain configuration")
 	//, "config", chainConfig)
 
 	fst := &Filestorm{
 		config:         config,
+		name:           name,
 		chainDb:        chainDb,
-		eventMux:       ctx.EventMux,
+		eventMux:       eventMux,
 		accountManager: ctx.AccountManager,
 		engine:         CreateConsensusEngine(ctx, chainConfig, &config.Fstash, config.Miner.Notify, config.Miner.Noverify, chainDb),
 		shutdownChan:   make(chan bool),
@@ -154,7 +182,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Filestorm, error) {
 		gasPrice:       config.Miner.GasPrice,
 		stormbase:      config.Miner.Stormbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
-		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms),
+		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms, chainConfig.Hasher()),
 	}
 
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
@@ -212,6 +240,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Filestorm, error) {
 	if fst.protocolManager, err = NewProtocolManager(chainConfig, checkpoint, config.SyncMode, config.NetworkId, fst.eventMux, fst.txPool, fst.engine, fst.blockchain, chainDb, cacheLimit, config.Whitelist); err != nil {
 		return nil, err
 	}
+	if name != "" {
+		fst.protocolManager.name = protocolName + "-" + name
+	}
 	fst.miner = miner.New(fst, &config.Miner, chainConfig, fst.EventMux(), fst.engine, fst.isLocalBlock)
 	fst.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
@@ -246,10 +277,10 @@ func makeExtraData(extra []byte) []byte {
 func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainConfig, config *fstash.Config, notify []string, noverify bool, db fstdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
 	if chainConfig.Clique != nil {
-		return clique.New(chainConfig.Clique, db)
+		return clique.New(chainConfig.Clique, db, chainConfig.Hasher())
 	}
 	if chainConfig.Pbft != nil {
-		return pbft.New(chainConfig.Pbft, db)
+		return pbft.New(chainConfig.Pbft, db, chainConfig.Hasher())
 	}
 	// Otherwise assume proof-of-work
 	switch config.PowMode {
@@ -528,7 +559,9 @@ func (s *Filestorm) Protocols() []p2p.Protocol {
 	protos := make([]p2p.Protocol, len(ProtocolVersions))
 	for i, vsn := range ProtocolVersions {
 		protos[i] = s.protocolManager.makeProtocol(vsn)
-		protos[i].Attributes = []enr.Entry{s.currentEthEntry()}
+		if s.name == "" {
+			protos[i].Attributes = []enr.Entry{s.currentEthEntry()}
+		}
 	}
 	if s.lesServer != nil {
 		protos = append(protos, s.lesServer.Protocols()...)
@@ -539,7 +572,10 @@ func (s *Filestorm) Protocols() []p2p.Protocol {
 // Start implements node.Service, starting all internal goroutines needed by the
 // Filestorm protocol implementation.
 func (s *Filestorm) Start(srvr *p2p.Server) error {
-	s.startEthEntryUpdate(srvr.LocalNode())
+	// Only the primary chain is advertised in the node record
+	if s.name == "" {
+		s.startEthEntryUpdate(srvr.LocalNode())
+	}
 
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers(params.BloomBitsBlocks)
@@ -26,6 +26,7 @@ import (
 	"github.com/filestorm/go-filestorm/core/bloombits"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 )
 
@@ -96,15 +97,16 @@ type BloomIndexer struct {
 }
 
 // NewBloomIndexer returns a chain indexer that generates bloom bits data for the
-// canonical chain for fast logs filtering.
-func NewBloomIndexer(db fstdb.Database, size, confirms uint64) *core.ChainIndexer {
+// canonical chain of a chain hashing with the given hash function for fast logs
+// filtering.
+func NewBloomIndexer(db fstdb.Database, size, confirms uint64, hasher crypto.Hasher) *core.ChainIndexer {
 	backend := &BloomIndexer{
 		db:   db,
 		size: size,
 	}
 	table := rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix))
 
-	return core.NewChainIndexer(db, table, backend, size, confirms, bloomThrottling, "bloombits")
+	return core.NewChainIndexer(db, table, backend, size, confirms, bloomThrottling, "bloombits", hasher)
 }
 
 // Reset implements core.ChainIndexerBackend, starting a new bloombits index
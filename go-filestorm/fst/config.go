@@ -115,10 +115,11 @@ type Config struct {
 	UltraLightOnlyAnnounce bool     `toml:",omitempty"` // Whether to only announce headers, or also serve them
 
 	// Database options
-	SkipBcVersionCheck bool `toml:"-"`
-	DatabaseHandles    int  `toml:"-"`
-	DatabaseCache      int
-	DatabaseFreezer    string
+	SkipBcVersionCheck  bool `toml:"-"`
+	DatabaseHandles     int  `toml:"-"`
+	DatabaseCache       int
+	DatabaseFreezer     string
+	DatabaseFreezerIPFS string // IPFS HTTP API or blockstore to offload ancient bodies and receipts to
 
 	TrieCleanCache int
 	TrieDirtyCache int
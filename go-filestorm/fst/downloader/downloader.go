@@ -29,6 +29,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/event"
 	"github.com/filestorm/go-filestorm/log"
@@ -108,8 +109,9 @@ type Downloader struct {
 	queue      *queue   // Scheduler for selecting the hashes to download
 	peers      *peerSet // Set of active peers from which download can proceed
 
-	stateDB    fstdb.Database  // Database to state sync into (and deduplicate via)
-	stateBloom *trie.SyncBloom // Bloom filter for fast trie node existence checks
+	stateDB     fstdb.Database  // Database to state sync into (and deduplicate via)
+	stateBloom  *trie.SyncBloom // Bloom filter for fast trie node existence checks
+	stateHasher crypto.Hasher   // Hash function of the state trie nodes
 
 	// Statistics
 	syncStatsChainOrigin uint64 // Origin block number where syncing started at
@@ -210,13 +212,14 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(checkpoint uint64, stateDb fstdb.Database, stateBloom *trie.SyncBloom, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(checkpoint uint64, stateDb fstdb.Database, stateBloom *trie.SyncBloom, stateHasher crypto.Hasher, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
 	dl := &Downloader{
 		stateDB:        stateDb,
 		stateBloom:     stateBloom,
+		stateHasher:    stateHasher,
 		mux:            mux,
 		checkpoint:     checkpoint,
 		queue:          newQueue(),
@@ -216,7 +216,8 @@ type stateSync struct {
 	d *Downloader // Downloader instance to access and manage current peerset
 
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
-	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
+	keccak hash.Hash                  // Keccak256 hasher to verify code deliveries with
+	hasher hash.Hash                  // Chain hasher to verify trie node deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
 
 	numUncommitted   int
@@ -240,8 +241,9 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB, d.stateBloom),
+		sched:   state.NewStateSync(root, d.stateDB, d.stateBloom, d.stateHasher),
 		keccak:  sha3.NewLegacyKeccak256(),
+		hasher:  d.stateHasher.New(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
 		cancel:  make(chan struct{}),
@@ -473,6 +475,13 @@ func (s *stateSync) processNodeData(blob []byte) (bool, common.Hash, error) {
 	s.keccak.Write(blob)
 	s.keccak.Sum(res.Hash[:0])
 	committed, _, err := s.sched.Process([]trie.SyncResult{res})
+	if err == trie.ErrNotRequested && s.d.stateHasher.IsSM3() {
+		// Contract code is keyed by its Keccak256 hash, trie nodes by SM3
+		s.hasher.Reset()
+		s.hasher.Write(blob)
+		s.hasher.Sum(res.Hash[:0])
+		committed, _, err = s.sched.Process([]trie.SyncResult{res})
+	}
 	return committed, res.Hash, err
 }
 
@@ -27,6 +27,7 @@ import (
 	"github.com/filestorm/go-filestorm/core/bloombits"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/node"
 )
@@ -92,7 +93,7 @@ func benchmarkBloomBits(b *testing.B, sectionSize uint64) {
 		var header *types.Header
 		for i := sectionIdx * sectionSize; i < (sectionIdx+1)*sectionSize; i++ {
 			hash := rawdb.ReadCanonicalHash(db, i)
-			header = rawdb.ReadHeader(db, hash, i)
+			header = rawdb.ReadHeader(db, hash, i, crypto.Keccak256Hasher)
 			if header == nil {
 				b.Fatalf("Error creating bloomBits data")
 			}
@@ -66,6 +66,7 @@ func errResp(code errCode, format string, v ...interface{}) error {
 type ProtocolManager struct {
 	networkID  uint64
 	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
+	name       string        // Protocol name negotiated with peers, distinct per hosted chain
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
@@ -105,6 +106,7 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkID:   networkID,
+		name:        protocolName,
 		forkFilter:  forkid.NewFilter(blockchain),
 		eventMux:    mux,
 		txpool:      txpool,
@@ -152,7 +154,7 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 	if atomic.LoadUint32(&manager.fastSync) == 1 {
 		stateBloom = trie.NewSyncBloom(uint64(cacheLimit), chaindb)
 	}
-	manager.downloader = downloader.New(manager.checkpointNumber, chaindb, stateBloom, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(manager.checkpointNumber, chaindb, stateBloom, config.Hasher(), manager.eventMux, blockchain, nil, manager.removePeer)
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -199,7 +201,7 @@ func (pm *ProtocolManager) makeProtocol(version uint) p2p.Protocol {
 	}
 
 	return p2p.Protocol{
-		Name:    protocolName,
+		Name:    pm.name,
 		Version: version,
 		Length:  length,
 		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
@@ -476,6 +478,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&headers); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		for _, header := range headers {
+			header.SetHasher(pm.blockchain.Config().Hasher())
+		}
 		// If no headers were received, but we're expencting a checkpoint header, consider it that
 		if len(headers) == 0 && p.syncDrop != nil {
 			// Stop the timer either way, decide later to drop or not
@@ -561,6 +566,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		uncles := make([][]*types.Header, len(request))
 
 		for i, body := range request {
+			types.Transactions(body.Transactions).SetHasher(pm.blockchain.Config().Hasher())
+			for _, uncle := range body.Uncles {
+				uncle.SetHasher(pm.blockchain.Config().Hasher())
+			}
 			transactions[i] = body.Transactions
 			uncles[i] = body.Uncles
 		}
@@ -636,7 +645,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			// Retrieve the requested block's receipts, skipping if unknown to us
 			results := pm.blockchain.GetReceiptsByHash(hash)
 			if results == nil {
-				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHashOf(header.Hasher()) {
 					continue
 				}
 			}
@@ -690,6 +699,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := request.sanityCheck(); err != nil {
 			return err
 		}
+		request.Block.SetHasher(pm.blockchain.Config().Hasher())
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
@@ -731,6 +741,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if tx == nil {
 				return errResp(ErrDecode, "transaction %d is nil", i)
 			}
+			tx.SetHasher(pm.blockchain.Config().Hasher())
 			p.MarkTransaction(tx.Hash())
 		}
 		pm.txpool.AddRemotes(txs)
@@ -28,13 +28,15 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/hexutil"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/filestorm/go-filestorm/rpc"
 )
 
 // Client defines typed wrappers for the Filestorm RPC API.
 type Client struct {
-	c *rpc.Client
+	c      *rpc.Client
+	hasher crypto.Hasher // hash function of the chain of the server
 }
 
 // Dial connects a client to the given URL.
@@ -50,9 +52,17 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	return NewClient(c), nil
 }
 
-// NewClient creates a client that uses the given RPC client.
+// NewClient creates a client that uses the given RPC client, for a chain
+// hashing with Keccak256.
 func NewClient(c *rpc.Client) *Client {
-	return &Client{c}
+	return NewClientWithHasher(c, crypto.Keccak256Hasher)
+}
+
+// NewClientWithHasher creates a client that uses the given RPC client, for a
+// chain hashing with the given hash function. Blocks, headers and transactions
+// returned by the client are hashed with it.
+func NewClientWithHasher(c *rpc.Client, hasher crypto.Hasher) *Client {
+	return &Client{c, hasher}
 }
 
 func (ec *Client) Close() {
@@ -112,16 +122,17 @@ func (ec *Client) getBlock(ctx context.Context, method string, args ...interface
 		return nil, err
 	}
 	// Quick-verify transaction and uncle lists. This mostly helps with debugging the server.
-	if head.UncleHash == types.EmptyUncleHash && len(body.UncleHashes) > 0 {
+	emptyUncleHash, emptyRootHash := types.EmptyUncleHashOf(ec.hasher), types.EmptyRootHashOf(ec.hasher)
+	if head.UncleHash == emptyUncleHash && len(body.UncleHashes) > 0 {
 		return nil, fmt.Errorf("server returned non-empty uncle list but block header indicates no uncles")
 	}
-	if head.UncleHash != types.EmptyUncleHash && len(body.UncleHashes) == 0 {
+	if head.UncleHash != emptyUncleHash && len(body.UncleHashes) == 0 {
 		return nil, fmt.Errorf("server returned empty uncle list but block header indicates uncles")
 	}
-	if head.TxHash == types.EmptyRootHash && len(body.Transactions) > 0 {
+	if head.TxHash == emptyRootHash && len(body.Transactions) > 0 {
 		return nil, fmt.Errorf("server returned non-empty transaction list but block header indicates no transactions")
 	}
-	if head.TxHash != types.EmptyRootHash && len(body.Transactions) == 0 {
+	if head.TxHash != emptyRootHash && len(body.Transactions) == 0 {
 		return nil, fmt.Errorf("server returned empty transaction list but block header indicates transactions")
 	}
 	// Load uncles because they are not included in the block response.
@@ -151,12 +162,15 @@ func (ec *Client) getBlock(ctx context.Context, method string, args ...interface
 	// Fill the sender cache of transactions in the block.
 	txs := make([]*types.Transaction, len(body.Transactions))
 	for i, tx := range body.Transactions {
+		tx.tx.SetHasher(ec.hasher)
 		if tx.From != nil {
 			setSenderFromServer(tx.tx, *tx.From, body.Hash)
 		}
 		txs[i] = tx.tx
 	}
-	return types.NewBlockWithHeader(head).WithBody(txs, uncles), nil
+	block := types.NewBlockWithHeader(head).WithBody(txs, uncles)
+	block.SetHasher(ec.hasher)
+	return block, nil
 }
 
 // HeaderByHash returns the block header with the given hash.
@@ -166,6 +180,9 @@ func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.He
 	if err == nil && head == nil {
 		err = filestorm.NotFound
 	}
+	if head != nil {
+		head.SetHasher(ec.hasher)
+	}
 	return head, err
 }
 
@@ -177,6 +194,9 @@ func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.H
 	if err == nil && head == nil {
 		err = filestorm.NotFound
 	}
+	if head != nil {
+		head.SetHasher(ec.hasher)
+	}
 	return head, err
 }
 
@@ -209,6 +229,7 @@ func (ec *Client) TransactionByHash(ctx context.Context, hash common.Hash) (tx *
 	} else if _, r, _ := json.tx.RawSignatureValues(); r == nil {
 		return nil, false, fmt.Errorf("server returned transaction without signature")
 	}
+	json.tx.SetHasher(ec.hasher)
 	if json.From != nil && json.BlockHash != nil {
 		setSenderFromServer(json.tx, *json.From, *json.BlockHash)
 	}
@@ -259,6 +280,7 @@ func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash,
 	} else if _, r, _ := json.tx.RawSignatureValues(); r == nil {
 		return nil, fmt.Errorf("server returned transaction without signature")
 	}
+	json.tx.SetHasher(ec.hasher)
 	if json.From != nil && json.BlockHash != nil {
 		setSenderFromServer(json.tx, *json.From, *json.BlockHash)
 	}
@@ -319,7 +341,9 @@ func (ec *Client) SyncProgress(ctx context.Context) (*filestorm.SyncProgress, er
 }
 
 // SubscribeNewHead subscribes to notifications about the current blockchain head
-// on the given channel.
+// on the given channel. The headers are delivered as decoded, on chains not
+// hashing with Keccak256 they have to be given the hash function with SetHasher
+// before they are hashed.
 func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (filestorm.Subscription, error) {
 	return ec.c.EthSubscribe(ctx, ch, "newHeads")
 }
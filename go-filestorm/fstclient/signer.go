@@ -22,6 +22,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 )
 
 // senderFromServer is a types.Signer that remembers the sender address returned by the RPC
@@ -30,13 +31,14 @@ import (
 type senderFromServer struct {
 	addr      common.Address
 	blockhash common.Hash
+	hasher    crypto.Hasher
 }
 
 var errNotCached = errors.New("sender not cached")
 
 func setSenderFromServer(tx *types.Transaction, addr common.Address, block common.Hash) {
 	// Use types.Sender for side-effect to store our signer into the cache.
-	types.Sender(&senderFromServer{addr, block}, tx)
+	types.Sender(&senderFromServer{addr, block, tx.Hasher()}, tx)
 }
 
 func (s *senderFromServer) Equal(other types.Signer) bool {
@@ -57,3 +59,6 @@ func (s *senderFromServer) Hash(tx *types.Transaction) common.Hash {
 func (s *senderFromServer) SignatureValues(tx *types.Transaction, sig []byte) (R, S, V *big.Int, err error) {
 	panic("can't sign with senderFromServer")
 }
+func (s *senderFromServer) Hasher() crypto.Hasher {
+	return s.hasher
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package fstcollections implements the typed records of the collections
+// system contract: key-value data, file descriptors and data encrypted to
+// the public keys of its recipients. It handles the encoding of the records
+// and their access lists, the contract itself only stores opaque bytes.
+package fstcollections
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/vm"
+)
+
+// Kinds of collection records.
+const (
+	KindKV        = "kv"
+	KindFile      = "file"
+	KindEncrypted = "encrypted"
+)
+
+// Permissions of access list entries.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+var collectionsABI abi.ABI
+
+func init() {
+	var err error
+	if collectionsABI, err = abi.JSON(strings.NewReader(vm.CollectionsABI)); err != nil {
+		panic(err)
+	}
+}
+
+// Grant is an access list entry of a record.
+type Grant struct {
+	Address     common.Address `json:"address"`
+	Permissions []string       `json:"permissions"`
+}
+
+// Record is a decoded record of the collections system contract. Depending on
+// its kind either the file descriptor or the encrypted envelope is set.
+type Record struct {
+	ID          common.Hash    `json:"id"`
+	Owner       common.Address `json:"owner"`
+	Collection  string         `json:"collection"`
+	Key         string         `json:"key"`
+	Kind        string         `json:"kind"`
+	Value       hexutil.Bytes  `json:"value"`
+	File        *File          `json:"file,omitempty"`
+	Envelope    *Envelope      `json:"envelope,omitempty"`
+	Writer      common.Address `json:"writer"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Timestamp   uint64         `json:"timestamp"`
+	ACL         []Grant        `json:"acl"`
+}
+
+// NewRecord decodes a record read from the system contract storage.
+func NewRecord(r *vm.CollectionRecord) (*Record, error) {
+	kind, err := kindName(r.Kind)
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{
+		ID:          r.ID,
+		Owner:       r.Owner,
+		Collection:  r.Collection,
+		Key:         r.Key,
+		Kind:        kind,
+		Value:       r.Value,
+		Writer:      r.Writer,
+		BlockNumber: r.BlockNumber,
+		Timestamp:   r.Timestamp,
+		ACL:         make([]Grant, len(r.ACL)),
+	}
+	for i, grant := range r.ACL {
+		record.ACL[i] = Grant{Address: grant.Address, Permissions: permissionNames(grant.Permissions)}
+	}
+	switch r.Kind {
+	case vm.CollectionFile:
+		if record.File, err = DecodeFile(r.Value); err != nil {
+			return nil, fmt.Errorf("invalid file record %x: %v", r.ID, err)
+		}
+	case vm.CollectionEncrypted:
+		if record.Envelope, err = DecodeEnvelope(r.Value); err != nil {
+			return nil, fmt.Errorf("invalid encrypted record %x: %v", r.ID, err)
+		}
+	}
+	return record, nil
+}
+
+// PackPut creates the call data of a system contract call writing a record of
+// owner. Only the owner's writes may carry an access list.
+func PackPut(owner common.Address, collection, key, kind string, value []byte, acl []Grant) ([]byte, error) {
+	code, err := kindCode(kind)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		addrs       = make([]common.Address, len(acl))
+		permissions = make([]uint8, len(acl))
+	)
+	for i, grant := range acl {
+		addrs[i] = grant.Address
+		if permissions[i], err = permissionBits(grant.Permissions); err != nil {
+			return nil, err
+		}
+	}
+	return collectionsABI.Pack("put", owner, collection, key, code, value, addrs, permissions)
+}
+
+func kindCode(name string) (uint8, error) {
+	switch name {
+	case KindKV:
+		return vm.CollectionKV, nil
+	case KindFile:
+		return vm.CollectionFile, nil
+	case KindEncrypted:
+		return vm.CollectionEncrypted, nil
+	}
+	return 0, fmt.Errorf("unknown record kind %q", name)
+}
+
+func kindName(code uint8) (string, error) {
+	switch code {
+	case vm.CollectionKV:
+		return KindKV, nil
+	case vm.CollectionFile:
+		return KindFile, nil
+	case vm.CollectionEncrypted:
+		return KindEncrypted, nil
+	}
+	return "", fmt.Errorf("unknown record kind %d", code)
+}
+
+func permissionBits(names []string) (uint8, error) {
+	var bits uint8
+	for _, name := range names {
+		switch name {
+		case PermissionRead:
+			bits |= vm.CollectionRead
+		case PermissionWrite:
+			bits |= vm.CollectionWrite
+		default:
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	if bits == 0 {
+		return 0, fmt.Errorf("no permissions granted")
+	}
+	return bits, nil
+}
+
+func permissionNames(bits uint8) []string {
+	var names []string
+	if bits&vm.CollectionRead != 0 {
+		names = append(names, PermissionRead)
+	}
+	if bits&vm.CollectionWrite != 0 {
+		names = append(names, PermissionWrite)
+	}
+	return names
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstcollections
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+)
+
+func TestEnvelope(t *testing.T) {
+	t.Run("secp256k1", func(t *testing.T) {
+		testEnvelope(t, CipherAES256GCM, func() (*ecdsa.PrivateKey, error) { return crypto.GenerateKey() })
+	})
+	t.Run("sm2", func(t *testing.T) {
+		testEnvelope(t, CipherSM4GCM, func() (*ecdsa.PrivateKey, error) { return sm2.GenerateKey(rand.Reader) })
+	})
+}
+
+func testEnvelope(t *testing.T, cipher string, generate func() (*ecdsa.PrivateKey, error)) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, err := generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	data := []byte("medical report of patient 42")
+	env, err := Encrypt(data, []*ecdsa.PublicKey{&keys[0].PublicKey, &keys[1].PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Cipher != cipher {
+		t.Fatalf("cipher mismatch: have %s, want %s", env.Cipher, cipher)
+	}
+	// Decrypt the value as stored on chain.
+	value, err := EncodeEnvelope(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, err = DecodeEnvelope(value); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys[:2] {
+		if plain, err := Decrypt(env, key); err != nil || !bytes.Equal(plain, data) {
+			t.Errorf("recipient %d: decryption mismatch: %q, %v", i, plain, err)
+		}
+	}
+	if _, err := Decrypt(env, keys[2]); err != errNotRecipient {
+		t.Errorf("non-recipient: have %v, want %v", err, errNotRecipient)
+	}
+	env.Ciphertext[0] ^= 0xff
+	if _, err := Decrypt(env, keys[0]); err == nil {
+		t.Error("tampered ciphertext decrypted")
+	}
+}
+
+func TestEncryptMixedRecipients(t *testing.T) {
+	secp, _ := crypto.GenerateKey()
+	gm, _ := sm2.GenerateKey(rand.Reader)
+	if _, err := Encrypt([]byte("data"), []*ecdsa.PublicKey{&secp.PublicKey, &gm.PublicKey}); err != errMixedRecipients {
+		t.Fatalf("have %v, want %v", err, errMixedRecipients)
+	}
+}
+
+func TestUnmarshalPubkey(t *testing.T) {
+	secp, _ := crypto.GenerateKey()
+	gm, _ := sm2.GenerateKey(rand.Reader)
+	tests := []struct {
+		key   *ecdsa.PrivateKey
+		isSM2 bool
+		enc   []byte
+	}{
+		{secp, false, crypto.FromECDSAPub(&secp.PublicKey)},
+		{secp, false, crypto.FromECDSAPub(&secp.PublicKey)[1:]},
+		{secp, false, crypto.CompressPubkey(&secp.PublicKey)},
+		{gm, true, crypto.FromECDSAPub(&gm.PublicKey)},
+		{gm, true, sm2.CompressPubkey(&gm.PublicKey)},
+	}
+	for i, test := range tests {
+		pub, err := UnmarshalPubkey(test.enc, test.isSM2)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if pub.Curve != test.key.Curve || pub.X.Cmp(test.key.X) != 0 || pub.Y.Cmp(test.key.Y) != 0 {
+			t.Errorf("test %d: key mismatch", i)
+		}
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	file := &File{Name: "report.pdf", URI: "ipfs://bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", Size: 11, MimeType: "application/pdf"}
+	value, err := EncodeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := common.Address{2}
+	record, err := NewRecord(&vm.CollectionRecord{
+		Owner:      common.Address{1},
+		Kind:       vm.CollectionFile,
+		Collection: "files",
+		Key:        "report.pdf",
+		Value:      value,
+		ACL:        []vm.CollectionGrant{{Address: reader, Permissions: vm.CollectionRead | vm.CollectionWrite}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Kind != KindFile || !reflect.DeepEqual(record.File, file) {
+		t.Fatalf("file mismatch: have %+v, want %+v", record.File, file)
+	}
+	want := []Grant{{Address: reader, Permissions: []string{PermissionRead, PermissionWrite}}}
+	if !reflect.DeepEqual(record.ACL, want) {
+		t.Fatalf("acl mismatch: have %v, want %v", record.ACL, want)
+	}
+	if _, err := PackPut(common.Address{1}, "files", "report.pdf", KindFile, value, want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PackPut(common.Address{1}, "files", "report.pdf", KindFile, value, []Grant{{Address: reader, Permissions: []string{"admin"}}}); err == nil {
+		t.Fatal("unknown permission accepted")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstcollections
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/ecies"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm4"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+// Ciphers the content of encrypted records is sealed with. Content encrypted
+// to SM2 keys uses SM4, content encrypted to secp256k1 keys AES.
+const (
+	CipherAES256GCM = "aes-256-gcm"
+	CipherSM4GCM    = "sm4-gcm"
+)
+
+var (
+	errNoRecipients    = errors.New("no recipients")
+	errMixedRecipients = errors.New("recipients mix secp256k1 and sm2 keys")
+	errNotRecipient    = errors.New("key is not a recipient of the record")
+)
+
+// Envelope is the value of an encrypted record: the content sealed with a
+// random content key, and the content key encrypted to the public key of each
+// recipient, with ECIES for secp256k1 keys and the SM2 public key encryption
+// for SM2 keys.
+type Envelope struct {
+	Cipher     string        `json:"cipher"`
+	Nonce      hexutil.Bytes `json:"nonce"`
+	Ciphertext hexutil.Bytes `json:"ciphertext"`
+	Recipients []Recipient   `json:"recipients"`
+}
+
+// Recipient is a recipient of an encrypted record.
+type Recipient struct {
+	Address common.Address `json:"address"`
+	Key     hexutil.Bytes  `json:"key"` // Content key encrypted to the recipient
+}
+
+// EncodeEnvelope encodes an envelope as the value of an encrypted record.
+func EncodeEnvelope(env *Envelope) ([]byte, error) {
+	return rlp.EncodeToBytes(env)
+}
+
+// DecodeEnvelope decodes the value of an encrypted record.
+func DecodeEnvelope(value []byte) (*Envelope, error) {
+	env := new(Envelope)
+	if err := rlp.DecodeBytes(value, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// UnmarshalPubkey parses a compressed or uncompressed public key on the curve
+// of the chain's signature scheme.
+func UnmarshalPubkey(pub []byte, isSM2 bool) (*ecdsa.PublicKey, error) {
+	switch {
+	case len(pub) == 33 && isSM2:
+		return sm2.DecompressPubkey(pub)
+	case len(pub) == 33:
+		return crypto.DecompressPubkey(pub)
+	case len(pub) == 64:
+		pub = append([]byte{0x04}, pub...)
+	}
+	if isSM2 {
+		return sm2.UnmarshalPubkey(pub)
+	}
+	return crypto.UnmarshalPubkey(pub)
+}
+
+// Encrypt seals data for the given recipients. All recipient keys have to be
+// on the same curve.
+func Encrypt(data []byte, recipients []*ecdsa.PublicKey) (*Envelope, error) {
+	if len(recipients) == 0 {
+		return nil, errNoRecipients
+	}
+	isSM2 := sm2.IsSM2(recipients[0])
+	for _, pub := range recipients[1:] {
+		if sm2.IsSM2(pub) != isSM2 {
+			return nil, errMixedRecipients
+		}
+	}
+	env := &Envelope{Cipher: CipherAES256GCM}
+	if isSM2 {
+		env.Cipher = CipherSM4GCM
+	}
+	key := make([]byte, keySize(env.Cipher))
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(env.Cipher, key)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, data, nil)
+
+	for _, pub := range recipients {
+		var wrapped []byte
+		if isSM2 {
+			wrapped, err = sm2.Encrypt(rand.Reader, pub, key)
+		} else {
+			wrapped, err = ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), key, nil, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		env.Recipients = append(env.Recipients, Recipient{Address: crypto.PubkeyToAddress(*pub), Key: wrapped})
+	}
+	return env, nil
+}
+
+// Decrypt opens an envelope with the private key of one of its recipients.
+func Decrypt(env *Envelope, prv *ecdsa.PrivateKey) ([]byte, error) {
+	addr := crypto.PubkeyToAddress(prv.PublicKey)
+	for _, recipient := range env.Recipients {
+		if recipient.Address != addr {
+			continue
+		}
+		var (
+			key []byte
+			err error
+		)
+		if sm2.IsSM2(&prv.PublicKey) {
+			key, err = sm2.Decrypt(prv, recipient.Key)
+		} else {
+			key, err = ecies.ImportECDSA(prv).Decrypt(recipient.Key, nil, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != keySize(env.Cipher) {
+			return nil, fmt.Errorf("invalid %s key length %d", env.Cipher, len(key))
+		}
+		aead, err := newAEAD(env.Cipher, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(env.Nonce) != aead.NonceSize() {
+			return nil, fmt.Errorf("invalid nonce length %d", len(env.Nonce))
+		}
+		return aead.Open(nil, env.Nonce, env.Ciphertext, nil)
+	}
+	return nil, errNotRecipient
+}
+
+func keySize(name string) int {
+	if name == CipherSM4GCM {
+		return sm4.BlockSize
+	}
+	return 32
+}
+
+func newAEAD(name string, key []byte) (cipher.AEAD, error) {
+	var (
+		block cipher.Block
+		err   error
+	)
+	switch name {
+	case CipherAES256GCM:
+		block, err = aes.NewCipher(key)
+	case CipherSM4GCM:
+		block, err = sm4.NewCipher(key)
+	default:
+		return nil, fmt.Errorf("unknown cipher %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
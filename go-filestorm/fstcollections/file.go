@@ -0,0 +1,47 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstcollections
+
+import (
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+// File is the descriptor stored by file records. The content itself is kept
+// off chain, e.g. on IPFS, and referenced by its URI.
+type File struct {
+	Name     string         `json:"name"`
+	URI      string         `json:"uri"`
+	Size     hexutil.Uint64 `json:"size"`
+	Hash     common.Hash    `json:"hash"` // Keccak256 of the content, if known
+	MimeType string         `json:"mimeType"`
+}
+
+// EncodeFile encodes a file descriptor as the value of a file record.
+func EncodeFile(f *File) ([]byte, error) {
+	return rlp.EncodeToBytes(f)
+}
+
+// DecodeFile decodes the value of a file record.
+func DecodeFile(value []byte) (*File, error) {
+	f := new(File)
+	if err := rlp.DecodeBytes(value, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstipfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend is the content addressed storage the ancient store offloads block
+// bodies and receipts to.
+type Backend interface {
+	// Put stores data and returns its content identifier.
+	Put(data []byte) (string, error)
+
+	// Get retrieves the data stored under the content identifier.
+	Get(cid string) ([]byte, error)
+}
+
+// apiTimeout is the maximum time a single request to the IPFS HTTP API may take.
+const apiTimeout = time.Minute
+
+// APIBackend stores data through the HTTP API of an IPFS daemon, pinning
+// everything it adds so the daemon's garbage collector keeps it around.
+type APIBackend struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewAPIBackend creates a backend talking to the IPFS HTTP API at endpoint,
+// e.g. http://127.0.0.1:5001.
+func NewAPIBackend(endpoint string) *APIBackend {
+	return &APIBackend{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: apiTimeout},
+	}
+}
+
+// Put implements Backend, adding and pinning data as a CIDv1 object.
+func (b *APIBackend) Put(data []byte) (string, error) {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", "ancient")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+	resp, err := b.call("add", url.Values{"pin": {"true"}, "cid-version": {"1"}, "raw-leaves": {"true"}}, form.FormDataContentType(), body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	var result struct {
+		Hash string
+	}
+	if err := json.NewDecoder(resp).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid ipfs add response: %v", err)
+	}
+	if result.Hash == "" {
+		return "", errors.New("ipfs add returned no cid")
+	}
+	return result.Hash, nil
+}
+
+// Get implements Backend, retrieving the data of cid from the daemon which
+// fetches it from the network if it isn't stored locally.
+func (b *APIBackend) Get(cid string) ([]byte, error) {
+	resp, err := b.call("cat", url.Values{"arg": {cid}}, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	return ioutil.ReadAll(resp)
+}
+
+// call invokes an IPFS HTTP API command and returns the response body.
+func (b *APIBackend) call(command string, args url.Values, contentType string, body io.Reader) (io.ReadCloser, error) {
+	req, err := http.NewRequest("POST", b.endpoint+"/api/v0/"+command+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("ipfs %s failed: %s: %s", command, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// BlockstoreBackend stores data in a local directory, one file per blob named by
+// the CIDv1 of the blob as a single raw block. IPFS assigns the same identifier
+// only to data fitting in one chunk (256 KiB by default). It splits larger data
+// into a unixfs DAG with a different root CID, so the directory is not an IPFS
+// blockstore and an index built with it doesn't work with the APIBackend.
+type BlockstoreBackend struct {
+	dir string
+}
+
+// NewBlockstoreBackend creates a backend keeping blocks in dir.
+func NewBlockstoreBackend(dir string) (*BlockstoreBackend, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &BlockstoreBackend{dir: dir}, nil
+}
+
+// Put implements Backend, writing data atomically into its block file.
+func (b *BlockstoreBackend) Put(data []byte) (string, error) {
+	cid := RawCID(data)
+	path := filepath.Join(b.dir, cid)
+	if _, err := os.Stat(path); err == nil {
+		return cid, nil
+	}
+	tmp, err := ioutil.TempFile(b.dir, "."+cid+".tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return cid, nil
+}
+
+// Get implements Backend.
+func (b *BlockstoreBackend) Get(cid string) ([]byte, error) {
+	if strings.ContainsAny(cid, `/\`) || strings.HasPrefix(cid, ".") {
+		return nil, fmt.Errorf("invalid cid %q", cid)
+	}
+	return ioutil.ReadFile(filepath.Join(b.dir, cid))
+}
+
+// cidBase32 is the lowercase, unpadded base32 of the multibase 'b' prefix.
+var cidBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// RawCID returns the CIDv1 of data stored as a single raw block hashed with
+// sha2-256, in its canonical base32 string form. It is the CID of
+// `ipfs add --cid-version=1 --raw-leaves` for data of up to one chunk only.
+func RawCID(data []byte) string {
+	digest := sha256.Sum256(data)
+
+	// <version 1><codec raw><multihash sha2-256><digest length><digest>
+	cid := append([]byte{0x01, 0x55, 0x12, sha256.Size}, digest[:]...)
+	return "b" + cidBase32.EncodeToString(cid)
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package fstipfs implements an ancient store keeping the bulk of the frozen
+// chain history on IPFS.
+//
+// Block hashes, headers and total difficulties stay in a local flat file
+// freezer, as they are small and needed for chain validation. Block bodies and
+// receipts are pushed into IPFS while they are frozen; the local tables only
+// keep an index entry with the content identifier and a checksum, and the data
+// is fetched back on demand.
+package fstipfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/core/rawdb"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// freezerDir is the directory inside the freezer path holding the local
+	// tables. It keeps index entries apart from a plain freezer at the same path.
+	freezerDir = "ipfs"
+
+	// inlineLimit is the size up to which blobs are kept in the local tables
+	// instead of IPFS, as their index entry wouldn't be any smaller.
+	inlineLimit = 128
+
+	// blobCacheLimit is the number of fetched blobs kept in memory.
+	blobCacheLimit = 256
+)
+
+// Index entry types of the offloaded tables.
+const (
+	entryInline = 0x00 // followed by the blob itself
+	entryIPFS   = 0x01 // followed by the keccak256 checksum of the blob and its cid
+)
+
+// offloaded are the freezer tables whose blobs are stored on IPFS: the block
+// bodies and receipts.
+var offloaded = map[string]bool{
+	"bodies":   true,
+	"receipts": true,
+}
+
+var (
+	errCorruptEntry = errors.New("corrupt ancient index entry")
+	errOutOfOrder   = errors.New("the append operation is out-order")
+)
+
+// Store is an ancient store offloading frozen block bodies and receipts to IPFS.
+type Store struct {
+	local   fstdb.AncientStore // Flat file freezer with the index entries
+	backend Backend            // Content addressed storage of the offloaded blobs
+	cache   *lru.Cache         // Recently fetched blobs by cid
+
+	putMeter metrics.Meter // Meter for the bytes pushed into IPFS
+	getMeter metrics.Meter // Meter for the bytes fetched from IPFS
+}
+
+// New creates an ancient store keeping its local tables in the freezer
+// directory and the offloaded blobs in backend.
+func New(freezer string, backend Backend, namespace string) (*Store, error) {
+	local, err := rawdb.NewFreezer(filepath.Join(freezer, freezerDir), namespace)
+	if err != nil {
+		return nil, err
+	}
+	cache, _ := lru.New(blobCacheLimit)
+	return &Store{
+		local:    local,
+		backend:  backend,
+		cache:    cache,
+		putMeter: metrics.NewRegisteredMeter(namespace+"ancient/ipfs/put", nil),
+		getMeter: metrics.NewRegisteredMeter(namespace+"ancient/ipfs/get", nil),
+	}, nil
+}
+
+// Open creates an ancient store for the given IPFS location: an http(s) URL is
+// the HTTP API of an IPFS daemon, anything else the path of a local blockstore
+// directory.
+func Open(freezer string, location string, namespace string) (*Store, error) {
+	backend, err := NewBackend(location)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Opening IPFS ancient store", "freezer", freezer, "ipfs", location)
+	return New(freezer, backend, namespace)
+}
+
+// Opener returns a constructor opening the IPFS ancient store of location in
+// a freezer directory, as used by the node when opening chain databases.
+func Opener(location string) func(freezer string, namespace string) (fstdb.AncientStore, error) {
+	return func(freezer string, namespace string) (fstdb.AncientStore, error) {
+		return Open(freezer, location, namespace)
+	}
+}
+
+// NewBackend creates the backend for an IPFS location as accepted by Open.
+func NewBackend(location string) (Backend, error) {
+	switch {
+	case location == "":
+		return nil, errors.New("no ipfs location specified")
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		return NewAPIBackend(location), nil
+	default:
+		return NewBlockstoreBackend(location)
+	}
+}
+
+// HasAncient implements fstdb.AncientReader.
+func (s *Store) HasAncient(kind string, number uint64) (bool, error) {
+	return s.local.HasAncient(kind, number)
+}
+
+// Ancient implements fstdb.AncientReader, fetching offloaded blobs from IPFS.
+func (s *Store) Ancient(kind string, number uint64) ([]byte, error) {
+	blob, err := s.local.Ancient(kind, number)
+	if err != nil || !offloaded[kind] {
+		return blob, err
+	}
+	return s.resolve(blob)
+}
+
+// Ancients implements fstdb.AncientReader.
+func (s *Store) Ancients() (uint64, error) {
+	return s.local.Ancients()
+}
+
+// AncientSize implements fstdb.AncientReader. The sizes of the offloaded
+// tables are the sizes of their local index.
+func (s *Store) AncientSize(kind string) (uint64, error) {
+	return s.local.AncientSize(kind)
+}
+
+// AppendAncient implements fstdb.AncientWriter, pushing the block body and
+// receipts into IPFS before appending the block to the local tables.
+func (s *Store) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	// Don't upload anything the local tables would reject.
+	if frozen, err := s.local.Ancients(); err != nil {
+		return err
+	} else if frozen != number {
+		return errOutOfOrder
+	}
+	bodyEntry, err := s.store(body)
+	if err != nil {
+		log.Error("Failed to offload ancient body", "number", number, "err", err)
+		return err
+	}
+	receiptEntry, err := s.store(receipts)
+	if err != nil {
+		log.Error("Failed to offload ancient receipts", "number", number, "err", err)
+		return err
+	}
+	return s.local.AppendAncient(number, hash, header, bodyEntry, receiptEntry, td)
+}
+
+// TruncateAncients implements fstdb.AncientWriter. Truncated blobs are left
+// pinned in IPFS, they may still be shared with other nodes.
+func (s *Store) TruncateAncients(items uint64) error {
+	return s.local.TruncateAncients(items)
+}
+
+// Sync implements fstdb.AncientWriter. Blobs are already persisted by IPFS
+// when added, only the local tables need flushing.
+func (s *Store) Sync() error {
+	return s.local.Sync()
+}
+
+// Close implements io.Closer.
+func (s *Store) Close() error {
+	return s.local.Close()
+}
+
+// store offloads a blob and returns the index entry referencing it.
+func (s *Store) store(blob []byte) ([]byte, error) {
+	if len(blob) <= inlineLimit {
+		return append([]byte{entryInline}, blob...), nil
+	}
+	cid, err := s.backend.Put(blob)
+	if err != nil {
+		return nil, err
+	}
+	s.putMeter.Mark(int64(len(blob)))
+
+	entry := make([]byte, 0, 1+32+len(cid))
+	entry = append(entry, entryIPFS)
+	entry = append(entry, crypto.Keccak256(blob)...)
+	return append(entry, cid...), nil
+}
+
+// resolve returns the blob referenced by an index entry, fetching it from IPFS
+// if it isn't cached yet.
+func (s *Store) resolve(entry []byte) ([]byte, error) {
+	if len(entry) == 0 {
+		return nil, errCorruptEntry
+	}
+	switch entry[0] {
+	case entryInline:
+		return entry[1:], nil
+
+	case entryIPFS:
+		if len(entry) <= 1+32 {
+			return nil, errCorruptEntry
+		}
+		checksum, cid := entry[1:33], string(entry[33:])
+		if blob, ok := s.cache.Get(cid); ok {
+			return blob.([]byte), nil
+		}
+		blob, err := s.backend.Get(cid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s from ipfs: %v", cid, err)
+		}
+		if !bytes.Equal(crypto.Keccak256(blob), checksum) {
+			return nil, fmt.Errorf("checksum mismatch for %s", cid)
+		}
+		s.getMeter.Mark(int64(len(blob)))
+		s.cache.Add(cid, blob)
+		return blob, nil
+
+	default:
+		return nil, errCorruptEntry
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstipfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRawCID(t *testing.T) {
+	// Same as `ipfs add --cid-version=1 --raw-leaves` of the file contents.
+	want := "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"
+	if cid := RawCID([]byte("hello world")); cid != want {
+		t.Fatalf("cid mismatch: have %s, want %s", cid, want)
+	}
+}
+
+// fakeAPI is an in-memory stand-in for the HTTP API of an IPFS daemon.
+type fakeAPI struct {
+	lock   sync.Mutex
+	blocks map[string][]byte
+	gets   int
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{blocks: make(map[string][]byte)}
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/api/v0/add":
+		if r.URL.Query().Get("pin") != "true" {
+			http.Error(w, "not pinned", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(file)
+		cid := RawCID(data)
+		api.blocks[cid] = data
+		fmt.Fprintf(w, `{"Name":"ancient","Hash":"%s","Size":"%d"}`, cid, len(data))
+
+	case "/api/v0/cat":
+		data, ok := api.blocks[r.URL.Query().Get("arg")]
+		if !ok {
+			http.Error(w, `{"Message":"not found"}`, http.StatusInternalServerError)
+			return
+		}
+		api.gets++
+		w.Write(data)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestStore(t *testing.T, backend Backend) (*Store, string) {
+	dir, err := ioutil.TempDir("", "fstipfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := New(dir, backend, "")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+// testBlob returns a deterministic blob of the given size.
+func testBlob(seed byte, size int) []byte {
+	blob := make([]byte, size)
+	for i := range blob {
+		blob[i] = seed + byte(i)
+	}
+	return blob
+}
+
+func TestStoreAPI(t *testing.T) {
+	api := newFakeAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	backend, err := NewBackend(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, dir := newTestStore(t, backend)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	testStore(t, store)
+	if len(api.blocks) != 3 {
+		t.Errorf("offloaded blob count mismatch: have %d, want 3", len(api.blocks))
+	}
+	// Offloaded blobs are served from the cache once fetched.
+	gets := api.gets
+	if _, err := store.Ancient("bodies", 1); err != nil {
+		t.Fatal(err)
+	}
+	if api.gets != gets {
+		t.Errorf("cached blob fetched again")
+	}
+}
+
+func TestStoreBlockstore(t *testing.T) {
+	blocks, err := ioutil.TempDir("", "fstipfs-blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(blocks)
+
+	backend, err := NewBackend(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, dir := newTestStore(t, backend)
+	defer os.RemoveAll(dir)
+
+	testStore(t, store)
+
+	// Reopen the store and check the data is retrieved through the index.
+	store.Close()
+	if store, err = New(dir, backend, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if frozen, _ := store.Ancients(); frozen != 3 {
+		t.Fatalf("ancient count mismatch after reopen: have %d, want 3", frozen)
+	}
+	if blob, err := store.Ancient("receipts", 2); err != nil || !bytes.Equal(blob, testBlob(4, 500)) {
+		t.Fatalf("receipts mismatch after reopen: %x, %v", blob, err)
+	}
+	// Tampered blocks must be detected.
+	if err := ioutil.WriteFile(blocks+"/"+RawCID(testBlob(3, 400)), []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Ancient("bodies", 2); err == nil {
+		t.Fatal("tampered body accepted")
+	}
+}
+
+// testStore appends three blocks to an empty store and checks their retrieval.
+func testStore(t *testing.T, store *Store) {
+	blocks := []struct {
+		body, receipts []byte
+	}{
+		{[]byte{0xc2, 0xc0, 0xc0}, []byte{0xc0}},
+		{testBlob(1, 200), testBlob(2, inlineLimit)},
+		{testBlob(3, 400), testBlob(4, 500)},
+	}
+	for i, block := range blocks {
+		hash, header, td := testBlob(byte(i), 32), testBlob(byte(i), 150), []byte{byte(i)}
+		if err := store.AppendAncient(uint64(i), hash, header, block.body, block.receipts, td); err != nil {
+			t.Fatalf("block %d: append failed: %v", i, err)
+		}
+	}
+	if err := store.AppendAncient(5, nil, nil, nil, nil, nil); err == nil {
+		t.Fatal("out of order append accepted")
+	}
+	if err := store.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range blocks {
+		n := uint64(i)
+		if blob, err := store.Ancient("hashes", n); err != nil || !bytes.Equal(blob, testBlob(byte(i), 32)) {
+			t.Errorf("block %d: hash mismatch: %x, %v", i, blob, err)
+		}
+		if blob, err := store.Ancient("headers", n); err != nil || !bytes.Equal(blob, testBlob(byte(i), 150)) {
+			t.Errorf("block %d: header mismatch: %x, %v", i, blob, err)
+		}
+		if blob, err := store.Ancient("bodies", n); err != nil || !bytes.Equal(blob, block.body) {
+			t.Errorf("block %d: body mismatch: %x, %v", i, blob, err)
+		}
+		if blob, err := store.Ancient("receipts", n); err != nil || !bytes.Equal(blob, block.receipts) {
+			t.Errorf("block %d: receipts mismatch: %x, %v", i, blob, err)
+		}
+	}
+	if ok, _ := store.HasAncient("bodies", 3); ok {
+		t.Error("missing body reported present")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/fstcollections"
+	"github.com/filestorm/go-filestorm/internal/fstapi"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// CollectionRecord represents a record of the collections system contract.
+type CollectionRecord struct {
+	record *fstcollections.Record
+}
+
+func (c *CollectionRecord) ID(ctx context.Context) common.Hash {
+	return c.record.ID
+}
+
+func (c *CollectionRecord) Owner(ctx context.Context) common.Address {
+	return c.record.Owner
+}
+
+func (c *CollectionRecord) Collection(ctx context.Context) string {
+	return c.record.Collection
+}
+
+func (c *CollectionRecord) Key(ctx context.Context) string {
+	return c.record.Key
+}
+
+func (c *CollectionRecord) Kind(ctx context.Context) string {
+	return c.record.Kind
+}
+
+func (c *CollectionRecord) Value(ctx context.Context) hexutil.Bytes {
+	return c.record.Value
+}
+
+func (c *CollectionRecord) File(ctx context.Context) *File {
+	if c.record.File == nil {
+		return nil
+	}
+	return &File{c.record.File}
+}
+
+func (c *CollectionRecord) Recipients(ctx context.Context) *[]common.Address {
+	if c.record.Envelope == nil {
+		return nil
+	}
+	recipients := make([]common.Address, len(c.record.Envelope.Recipients))
+	for i, recipient := range c.record.Envelope.Recipients {
+		recipients[i] = recipient.Address
+	}
+	return &recipients
+}
+
+func (c *CollectionRecord) Writer(ctx context.Context) common.Address {
+	return c.record.Writer
+}
+
+func (c *CollectionRecord) BlockNumber(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(c.record.BlockNumber)
+}
+
+func (c *CollectionRecord) Timestamp(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(c.record.Timestamp)
+}
+
+func (c *CollectionRecord) Acl(ctx context.Context) []*CollectionGrant {
+	grants := make([]*CollectionGrant, len(c.record.ACL))
+	for i := range c.record.ACL {
+		grants[i] = &CollectionGrant{&c.record.ACL[i]}
+	}
+	return grants
+}
+
+// CollectionGrant represents an access list entry of a collection record.
+type CollectionGrant struct {
+	grant *fstcollections.Grant
+}
+
+func (g *CollectionGrant) Address(ctx context.Context) common.Address {
+	return g.grant.Address
+}
+
+func (g *CollectionGrant) Permissions(ctx context.Context) []string {
+	return g.grant.Permissions
+}
+
+// File represents the descriptor of a file record.
+type File struct {
+	file *fstcollections.File
+}
+
+func (f *File) Name(ctx context.Context) string {
+	return f.file.Name
+}
+
+func (f *File) URI(ctx context.Context) string {
+	return f.file.URI
+}
+
+func (f *File) Size(ctx context.Context) hexutil.Uint64 {
+	return f.file.Size
+}
+
+func (f *File) Hash(ctx context.Context) common.Hash {
+	return f.file.Hash
+}
+
+func (f *File) MimeType(ctx context.Context) string {
+	return f.file.MimeType
+}
+
+func (r *Resolver) Record(ctx context.Context, args struct {
+	Owner      common.Address
+	Collection string
+	Key        string
+	Block      *hexutil.Uint64
+}) (*CollectionRecord, error) {
+	number := rpc.LatestBlockNumber
+	if args.Block != nil {
+		number = rpc.BlockNumber(*args.Block)
+	}
+	record, err := fstapi.NewPublicCollectionsAPI(r.backend, nil).Get(ctx, args.Owner, args.Collection, args.Key, &number)
+	if record == nil || err != nil {
+		return nil, err
+	}
+	return &CollectionRecord{record}, nil
+}
+
+func (r *Resolver) Files(ctx context.Context, args struct {
+	Owner      common.Address
+	Collection *string
+}) ([]*CollectionRecord, error) {
+	files, err := fstapi.NewPublicCollectionsAPI(r.backend, nil).ListFiles(ctx, args.Owner, args.Collection)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*CollectionRecord, len(files))
+	for i, file := range files {
+		ret[i] = &CollectionRecord{file}
+	}
+	return ret, nil
+}
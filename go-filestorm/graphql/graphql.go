@@ -132,7 +132,7 @@ type Transaction struct {
 // resolve returns the internal transaction object, fetching it if needed.
 func (t *Transaction) resolve(ctx context.Context) (*types.Transaction, error) {
 	if t.tx == nil {
-		tx, blockHash, _, index := rawdb.ReadTransaction(t.backend.ChainDb(), t.hash)
+		tx, blockHash, _, index := rawdb.ReadTransaction(t.backend.ChainDb(), t.hash, t.backend.ChainConfig().Hasher())
 		if tx != nil {
 			t.tx = tx
 			blockNrOrHash := rpc.BlockNumberOrHashWithHash(blockHash, false)
@@ -213,11 +213,7 @@ func (t *Transaction) From(ctx context.Context, args BlockNumberArgs) (*Account,
 	if err != nil || tx == nil {
 		return nil, err
 	}
-	var signer types.Signer = types.HomesteadSigner{}
-	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(types.NewTxSigner(t.backend.ChainConfig(), tx), tx)
 
 	return &Account{
 		backend:       t.backend,
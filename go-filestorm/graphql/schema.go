@@ -291,6 +291,57 @@ const schema string = `
       estimateGas(data: CallData!): Long!
     }
 
+    # CollectionRecord is a record of the collections system contract.
+    type CollectionRecord {
+        # ID is the identifier of the record, derived from its owner, collection
+        # and key.
+        id: Bytes32!
+        # Owner is the account owning the record.
+        owner: Address!
+        # Collection is the collection of the owner the record belongs to.
+        collection: String!
+        # Key is the key of the record within its collection.
+        key: String!
+        # Kind is the kind of the record: kv, file or encrypted.
+        kind: String!
+        # Value is the encoded value of the record.
+        value: Bytes!
+        # File is the descriptor of a file record.
+        file: File
+        # Recipients are the accounts an encrypted record is encrypted to.
+        recipients: [Address!]
+        # Writer is the account that last wrote the record.
+        writer: Address!
+        # BlockNumber is the number of the block the record was last written in.
+        blockNumber: Long!
+        # Timestamp is the time of the block the record was last written in.
+        timestamp: Long!
+        # ACL is the access list of the record.
+        acl: [CollectionGrant!]!
+    }
+
+    # CollectionGrant is an access list entry of a collection record.
+    type CollectionGrant {
+        # Address is the account granted access.
+        address: Address!
+        # Permissions are the granted permissions: read and write.
+        permissions: [String!]!
+    }
+
+    # File is the descriptor of a file stored off chain.
+    type File {
+        # Name is the name of the file.
+        name: String!
+        # URI locates the file content.
+        uri: String!
+        # Size is the size of the file content in bytes.
+        size: Long!
+        # Hash is the Keccak256 hash of the file content, if known.
+        hash: Bytes32!
+        # MimeType is the media type of the file content.
+        mimeType: String!
+    }
+
     type Query {
         # Block fetches an Filestorm block by number or by hash. If neither is
         # supplied, the most recent known block is returned.
@@ -311,6 +362,11 @@ const schema string = `
         protocolVersion: Int!
         # Syncing returns information on the current synchronisation state.
         syncing: SyncState
+        # Record returns a record of the collections system contract.
+        record(owner: Address!, collection: String!, key: String!, block: Long): CollectionRecord
+        # Files returns the file records of an account, optionally limited to
+        # one collection.
+        files(owner: Address!, collection: String): [CollectionRecord!]!
     }
 
     type Mutation {
@@ -104,6 +104,16 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTransactionPoolAPI(apiBackend, nonceLock),
 			Public:    true,
+		}, {
+			Namespace: "fst",
+			Version:   "1.0",
+			Service:   NewPublicNotaryAPI(apiBackend, nonceLock),
+			Public:    true,
+		}, {
+			Namespace: "collections",
+			Version:   "1.0",
+			Service:   NewPublicCollectionsAPI(apiBackend, nonceLock),
+			Public:    true,
 		}, {
 			Namespace: "txpool",
 			Version:   "1.0",
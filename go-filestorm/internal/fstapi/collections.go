@@ -0,0 +1,237 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/state"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/fstcollections"
+	"github.com/filestorm/go-filestorm/params"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// PublicCollectionsAPI provides an API to store and retrieve typed records in
+// the collections system contract.
+type PublicCollectionsAPI struct {
+	b         Backend
+	nonceLock *AddrLocker
+}
+
+// NewPublicCollectionsAPI creates a new collections API.
+func NewPublicCollectionsAPI(b Backend, nonceLock *AddrLocker) *PublicCollectionsAPI {
+	return &PublicCollectionsAPI{b, nonceLock}
+}
+
+// CollectionWriteArgs represents the arguments common to all record writes.
+// The owner defaults to the sender, writing records of other owners requires a
+// write grant. Only the owner's writes may set the access list, and every
+// write of the owner replaces it.
+type CollectionWriteArgs struct {
+	From       common.Address         `json:"from"`
+	Owner      *common.Address        `json:"owner"`
+	Collection string                 `json:"collection"`
+	Key        string                 `json:"key"`
+	ACL        []fstcollections.Grant `json:"acl"`
+	Gas        *hexutil.Uint64        `json:"gas"`
+	GasPrice   *hexutil.Big           `json:"gasPrice"`
+	Nonce      *hexutil.Uint64        `json:"nonce"`
+}
+
+// PutKVArgs represents the arguments to write a key-value record.
+type PutKVArgs struct {
+	CollectionWriteArgs
+	Value hexutil.Bytes `json:"value"`
+}
+
+// PutFileArgs represents the arguments to write a file record.
+type PutFileArgs struct {
+	CollectionWriteArgs
+	File fstcollections.File `json:"file"`
+}
+
+// PutEncryptedArgs represents the arguments to write an encrypted record. The
+// data is encrypted to the public keys of the recipients, who are granted read
+// access to the record.
+type PutEncryptedArgs struct {
+	CollectionWriteArgs
+	Data       hexutil.Bytes   `json:"data"`
+	Recipients []hexutil.Bytes `json:"recipients"`
+}
+
+// CollectionWriteReceipt acknowledges a submitted record write. The record is
+// written once its transaction is included in a block.
+type CollectionWriteReceipt struct {
+	ID     common.Hash    `json:"id"`
+	Owner  common.Address `json:"owner"`
+	TxHash common.Hash    `json:"transactionHash"`
+}
+
+// PutKV sends a transaction writing a key-value record.
+func (s *PublicCollectionsAPI) PutKV(ctx context.Context, args PutKVArgs) (*CollectionWriteReceipt, error) {
+	return s.put(ctx, args.CollectionWriteArgs, fstcollections.KindKV, args.Value)
+}
+
+// PutFile sends a transaction writing a file record.
+func (s *PublicCollectionsAPI) PutFile(ctx context.Context, args PutFileArgs) (*CollectionWriteReceipt, error) {
+	value, err := fstcollections.EncodeFile(&args.File)
+	if err != nil {
+		return nil, err
+	}
+	return s.put(ctx, args.CollectionWriteArgs, fstcollections.KindFile, value)
+}
+
+// PutEncrypted encrypts the data to the recipients and sends a transaction
+// writing the encrypted record.
+func (s *PublicCollectionsAPI) PutEncrypted(ctx context.Context, args PutEncryptedArgs) (*CollectionWriteReceipt, error) {
+	isSM2 := s.b.ChainConfig().IsSM2()
+	keys := make([]*ecdsa.PublicKey, len(args.Recipients))
+	for i, recipient := range args.Recipients {
+		key, err := fstcollections.UnmarshalPubkey(recipient, isSM2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %d: %v", i, err)
+		}
+		keys[i] = key
+	}
+	env, err := fstcollections.Encrypt(args.Data, keys)
+	if err != nil {
+		return nil, err
+	}
+	value, err := fstcollections.EncodeEnvelope(env)
+	if err != nil {
+		return nil, err
+	}
+	if args.Owner == nil || *args.Owner == args.From {
+		for _, recipient := range env.Recipients {
+			args.ACL = append(args.ACL, fstcollections.Grant{Address: recipient.Address, Permissions: []string{fstcollections.PermissionRead}})
+		}
+	}
+	return s.put(ctx, args.CollectionWriteArgs, fstcollections.KindEncrypted, value)
+}
+
+// put sends a transaction writing a record with the system contract.
+func (s *PublicCollectionsAPI) put(ctx context.Context, args CollectionWriteArgs, kind string, value []byte) (*CollectionWriteReceipt, error) {
+	next := new(big.Int).Add(s.b.CurrentBlock().Number(), common.Big1)
+	if !s.b.ChainConfig().IsCollections(next) {
+		return nil, errors.New("collections are not enabled on this chain")
+	}
+	owner := args.From
+	if args.Owner != nil {
+		owner = *args.Owner
+	}
+	if len(value) > params.CollectionsMaxValue {
+		return nil, fmt.Errorf("record value too large: %d > %d bytes", len(value), params.CollectionsMaxValue)
+	}
+	input, err := fstcollections.PackPut(owner, args.Collection, args.Key, kind, value, args.ACL)
+	if err != nil {
+		return nil, err
+	}
+	// Fail early instead of sending a transaction that is going to revert.
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	id := vm.CollectionRecordID(owner, args.Collection, args.Key)
+	if err := checkCollectionWrite(statedb, id, args.From, owner, kind, len(args.ACL) > 0); err != nil {
+		return nil, err
+	}
+	data := hexutil.Bytes(input)
+	txHash, err := NewPublicTransactionPoolAPI(s.b, s.nonceLock).SendTransaction(ctx, SendTxArgs{
+		From:     args.From,
+		To:       &vm.CollectionsAddress,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		Data:     &data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionWriteReceipt{ID: id, Owner: owner, TxHash: txHash}, nil
+}
+
+// checkCollectionWrite mirrors the access checks of the system contract.
+func checkCollectionWrite(statedb *state.StateDB, id common.Hash, writer, owner common.Address, kind string, setsACL bool) error {
+	record, ok := vm.ReadCollectionRecord(statedb, id)
+	if !ok {
+		if writer != owner {
+			return fmt.Errorf("record %x does not exist, only its owner may create it", id)
+		}
+		return nil
+	}
+	if current, err := fstcollections.NewRecord(record); err != nil || current.Kind != kind {
+		return fmt.Errorf("record %x is not a %s record", id, kind)
+	}
+	if !record.Allowed(writer, vm.CollectionWrite) {
+		return fmt.Errorf("%x may not write record %x", writer, id)
+	}
+	if setsACL && writer != owner {
+		return errors.New("only the owner may set the access list")
+	}
+	return nil
+}
+
+// Get returns a record at the given block. The access list of the record is
+// enforced for contracts reading through the system contract only, chain state
+// is public. Confidential data has to be stored in encrypted records.
+func (s *PublicCollectionsAPI) Get(ctx context.Context, owner common.Address, collection, key string, blockNr *rpc.BlockNumber) (*fstcollections.Record, error) {
+	number := rpc.LatestBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, number)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	record, ok := vm.ReadCollectionRecord(statedb, vm.CollectionRecordID(owner, collection, key))
+	if !ok {
+		return nil, nil
+	}
+	return fstcollections.NewRecord(record)
+}
+
+// ListFiles returns the file records of owner, optionally limited to one
+// collection, in the order they were created.
+func (s *PublicCollectionsAPI) ListFiles(ctx context.Context, owner common.Address, collection *string) ([]*fstcollections.Record, error) {
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	files := make([]*fstcollections.Record, 0)
+	for _, id := range vm.CollectionRecordIDs(statedb, owner) {
+		record, ok := vm.ReadCollectionRecord(statedb, id)
+		if !ok || record.Kind != vm.CollectionFile {
+			continue
+		}
+		if collection != nil && record.Collection != *collection {
+			continue
+		}
+		file, err := fstcollections.NewRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package fstapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/notary"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// PublicNotaryAPI provides an API to notarize content with the notarization
+// system contract and to retrieve the proofs of notarizations.
+type PublicNotaryAPI struct {
+	b         Backend
+	nonceLock *AddrLocker
+}
+
+// NewPublicNotaryAPI creates a new notarization API.
+func NewPublicNotaryAPI(b Backend, nonceLock *AddrLocker) *PublicNotaryAPI {
+	return &PublicNotaryAPI{b, nonceLock}
+}
+
+// NotarizeArgs represents the arguments to notarize content. Either the
+// content or its hash has to be given.
+type NotarizeArgs struct {
+	From      common.Address  `json:"from"`
+	Content   *hexutil.Bytes  `json:"content"`
+	Hash      *common.Hash    `json:"hash"`
+	Algorithm string          `json:"algorithm"` // keccak256 (default) or sm3
+	Metadata  string          `json:"metadata"`
+	Gas       *hexutil.Uint64 `json:"gas"`
+	GasPrice  *hexutil.Big    `json:"gasPrice"`
+	Nonce     *hexutil.Uint64 `json:"nonce"`
+}
+
+// NotarizationReceipt acknowledges a submitted notarization. The notarization
+// is recorded once its transaction is included in a block.
+type NotarizationReceipt struct {
+	Hash      common.Hash    `json:"hash"`
+	Algorithm string         `json:"algorithm"`
+	Owner     common.Address `json:"owner"`
+	Metadata  string         `json:"metadata"`
+	TxHash    common.Hash    `json:"transactionHash"`
+}
+
+// Notarize hashes the content and sends a transaction from the given account
+// recording the hash with the notarization system contract.
+func (s *PublicNotaryAPI) Notarize(ctx context.Context, args NotarizeArgs) (*NotarizationReceipt, error) {
+	next := new(big.Int).Add(s.b.CurrentBlock().Number(), common.Big1)
+	if !s.b.ChainConfig().IsNotary(next) {
+		return nil, errors.New("notarization is not enabled on this chain")
+	}
+	if args.Algorithm == "" {
+		args.Algorithm = notary.AlgorithmKeccak256
+	}
+	var hash common.Hash
+	switch {
+	case args.Content != nil && args.Hash != nil:
+		return nil, errors.New(`both "content" and "hash" are set`)
+	case args.Content != nil:
+		var err error
+		if hash, err = notary.HashContent(args.Algorithm, *args.Content); err != nil {
+			return nil, err
+		}
+	case args.Hash != nil:
+		hash = *args.Hash
+	default:
+		return nil, errors.New(`missing "content" or "hash"`)
+	}
+
+	// Fail early instead of sending a transaction that is going to revert.
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if record, ok := vm.ReadNotarization(statedb, hash); ok {
+		return nil, fmt.Errorf("%x was notarized by %x in block %d", hash, record.Owner, record.BlockNumber)
+	}
+	input, err := notary.PackNotarize(hash, args.Algorithm, args.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	data := hexutil.Bytes(input)
+	txHash, err := NewPublicTransactionPoolAPI(s.b, s.nonceLock).SendTransaction(ctx, SendTxArgs{
+		From:     args.From,
+		To:       &vm.NotaryAddress,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		Data:     &data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &NotarizationReceipt{
+		Hash:      hash,
+		Algorithm: args.Algorithm,
+		Owner:     args.From,
+		Metadata:  args.Metadata,
+		TxHash:    txHash,
+	}, nil
+}
+
+// GetNotarizationProof returns the proof of the notarization of a content hash.
+// The proof is anchored to the next block flushed to the parent chain once the
+// chain has reached that block.
+func (s *PublicNotaryAPI) GetNotarizationProof(ctx context.Context, hash common.Hash) (*notary.Proof, error) {
+	statedb, head, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	record, ok := vm.ReadNotarization(statedb, hash)
+	if !ok {
+		return nil, fmt.Errorf("%x is not notarized", hash)
+	}
+	block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(record.BlockNumber))
+	if block == nil || err != nil {
+		return nil, fmt.Errorf("notarization block %d not found: %v", record.BlockNumber, err)
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	config := s.b.ChainConfig()
+	proof, err := notary.NewProof(config, block, receipts, hash)
+	if err != nil {
+		return nil, err
+	}
+	if config.Pbft == nil {
+		return proof, nil
+	}
+	anchor, ok := config.Pbft.NextFlushBlock(record.BlockNumber)
+	if !ok || anchor > head.Number.Uint64() {
+		return proof, nil
+	}
+	var headers []*types.Header
+	for n := record.BlockNumber + 1; n <= anchor; n++ {
+		header, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(n))
+		if header == nil || err != nil {
+			return nil, fmt.Errorf("header %d not found: %v", n, err)
+		}
+		headers = append(headers, header)
+	}
+	if err := proof.SetAnchor(headers); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
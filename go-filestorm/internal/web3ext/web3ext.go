@@ -18,24 +18,62 @@
 package web3ext
 
 var Modules = map[string]string{
-	"accounting": AccountingJs,
-	"admin":      AdminJs,
-	"chequebook": ChequebookJs,
-	"clique":     CliqueJs,
-	"pbft":       PbftJs,
-	"fstash":     FstashJs,
-	"debug":      DebugJs,
-	"fst":        FstJs,
-	"miner":      MinerJs,
-	"net":        NetJs,
-	"personal":   PersonalJs,
-	"rpc":        RpcJs,
-	"shh":        ShhJs,
-	"swarmfs":    SwarmfsJs,
-	"txpool":     TxpoolJs,
-	"les":        LESJs,
+	"accounting":  AccountingJs,
+	"admin":       AdminJs,
+	"chequebook":  ChequebookJs,
+	"clique":      CliqueJs,
+	"collections": CollectionsJs,
+	"pbft":        PbftJs,
+	"fstash":      FstashJs,
+	"debug":       DebugJs,
+	"fst":         FstJs,
+	"gossip":      GossipJs,
+	"miner":       MinerJs,
+	"net":         NetJs,
+	"personal":    PersonalJs,
+	"relay":       RelayJs,
+	"rpc":         RpcJs,
+	"shh":         ShhJs,
+	"swarmfs":     SwarmfsJs,
+	"txpool":      TxpoolJs,
+	"les":         LESJs,
 }
 
+const CollectionsJs = `
+web3._extend({
+	property: 'collections',
+	methods: [
+		new web3._extend.Method({
+			name: 'putKV',
+			call: 'collections_putKV',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'putFile',
+			call: 'collections_putFile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'putEncrypted',
+			call: 'collections_putEncrypted',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'get',
+			call: 'collections_get',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'listFiles',
+			call: 'collections_listFiles',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+	]
+});
+`
+
 const ChequebookJs = `
 web3._extend({
 	property: 'chequebook',
@@ -595,6 +633,16 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'notarize',
+			call: 'fst_notarize',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getNotarizationProof',
+			call: 'fst_getNotarizationProof',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -740,6 +788,62 @@ web3._extend({
 });
 `
 
+const GossipJs = `
+web3._extend({
+	property: 'gossip',
+	methods: [
+		new web3._extend.Method({
+			name: 'publish',
+			call: 'gossip_publish',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getMessages',
+			call: 'gossip_getMessages',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'info',
+			getter: 'gossip_info'
+		}),
+	]
+});
+`
+
+const RelayJs = `
+web3._extend({
+	property: 'relay',
+	methods: [
+		new web3._extend.Method({
+			name: 'header',
+			call: 'relay_header',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'receiptProof',
+			call: 'relay_receiptProof',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'verifyLog',
+			call: 'relay_verifyLog',
+			params: 2
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'relay_status'
+		}),
+	]
+});
+`
+
 const ShhJs = `
 web3._extend({
 	property: 'shh',
@@ -22,6 +22,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common/mclock"
 	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/light"
 	"github.com/filestorm/go-filestorm/log"
@@ -31,15 +32,17 @@ import (
 type LesOdr struct {
 	db                                         fstdb.Database
 	indexerConfig                              *light.IndexerConfig
+	hasher                                     crypto.Hasher
 	chtIndexer, bloomTrieIndexer, bloomIndexer *core.ChainIndexer
 	retriever                                  *retrieveManager
 	stop                                       chan struct{}
 }
 
-func NewLesOdr(db fstdb.Database, config *light.IndexerConfig, retriever *retrieveManager) *LesOdr {
+func NewLesOdr(db fstdb.Database, config *light.IndexerConfig, hasher crypto.Hasher, retriever *retrieveManager) *LesOdr {
 	return &LesOdr{
 		db:            db,
 		indexerConfig: config,
+		hasher:        hasher,
 		retriever:     retriever,
 		stop:          make(chan struct{}),
 	}
@@ -82,6 +85,11 @@ func (odr *LesOdr) IndexerConfig() *light.IndexerConfig {
 	return odr.indexerConfig
 }
 
+// Hasher returns the hash function of the chain.
+func (odr *LesOdr) Hasher() crypto.Hasher {
+	return odr.hasher
+}
+
 const (
 	MsgBlockBodies = iota
 	MsgCode
@@ -123,7 +131,7 @@ func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err erro
 		},
 	}
 	sent := mclock.Now()
-	if err = odr.retriever.retrieve(ctx, reqID, rq, func(p distPeer, msg *Msg) error { return lreq.Validate(odr.db, msg) }, odr.stop); err == nil {
+	if err = odr.retriever.retrieve(ctx, reqID, rq, func(p distPeer, msg *Msg) error { return lreq.Validate(odr.db, odr.hasher, msg) }, odr.stop); err == nil {
 		// retrieved from network, store in db
 		req.StoreResult(odr.db)
 		requestRTT.Update(time.Duration(mclock.Now() - sent))
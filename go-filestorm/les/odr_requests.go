@@ -49,7 +49,7 @@ type LesOdrRequest interface {
 	GetCost(*peer) uint64
 	CanSend(*peer) bool
 	Request(uint64, *peer) error
-	Validate(fstdb.Database, *Msg) error
+	Validate(fstdb.Database, crypto.Hasher, *Msg) error
 }
 
 func LesRequest(req light.OdrRequest) LesOdrRequest {
@@ -96,7 +96,7 @@ func (r *BlockRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *BlockRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *BlockRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating block body", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single block body
@@ -110,14 +110,14 @@ func (r *BlockRequest) Validate(db fstdb.Database, msg *Msg) error {
 	body := bodies[0]
 
 	// Retrieve our stored header and validate block content against it
-	header := rawdb.ReadHeader(db, r.Hash, r.Number)
+	header := rawdb.ReadHeader(db, r.Hash, r.Number, hasher)
 	if header == nil {
 		return errHeaderUnavailable
 	}
-	if header.TxHash != types.DeriveSha(types.Transactions(body.Transactions)) {
+	if header.TxHash != types.DeriveSha(types.Transactions(body.Transactions), hasher) {
 		return errTxHashMismatch
 	}
-	if header.UncleHash != types.CalcUncleHash(body.Uncles) {
+	if header.UncleHash != types.CalcUncleHash(body.Uncles, hasher) {
 		return errUncleHashMismatch
 	}
 	// Validations passed, encode and store RLP
@@ -152,7 +152,7 @@ func (r *ReceiptsRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *ReceiptsRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *ReceiptsRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating block receipts", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single block receipt
@@ -167,12 +167,12 @@ func (r *ReceiptsRequest) Validate(db fstdb.Database, msg *Msg) error {
 
 	// Retrieve our stored header and validate receipt content against it
 	if r.Header == nil {
-		r.Header = rawdb.ReadHeader(db, r.Hash, r.Number)
+		r.Header = rawdb.ReadHeader(db, r.Hash, r.Number, hasher)
 	}
 	if r.Header == nil {
 		return errHeaderUnavailable
 	}
-	if r.Header.ReceiptHash != types.DeriveSha(receipt) {
+	if r.Header.ReceiptHash != types.DeriveSha(receipt, hasher) {
 		return errReceiptHashMismatch
 	}
 	// Validations passed, store and return
@@ -214,7 +214,7 @@ func (r *TrieRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *TrieRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *TrieRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating trie proof", "root", r.Id.Root, "key", r.Key)
 
 	if msg.MsgType != MsgProofsV2 {
@@ -222,7 +222,7 @@ func (r *TrieRequest) Validate(db fstdb.Database, msg *Msg) error {
 	}
 	proofs := msg.Obj.(light.NodeList)
 	// Verify the proof and store if checks out
-	nodeSet := proofs.NodeSet()
+	nodeSet := proofs.NodeSet(hasher)
 	reads := &readTraceDB{db: nodeSet}
 	if _, _, err := trie.VerifyProof(r.Id.Root, r.Key, reads); err != nil {
 		return fmt.Errorf("merkle proof verification failed: %v", err)
@@ -267,7 +267,7 @@ func (r *CodeRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *CodeRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *CodeRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating code data", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single code element
@@ -349,7 +349,7 @@ func (r *ChtRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *ChtRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *ChtRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating CHT", "cht", r.ChtNum, "block", r.BlockNum)
 
 	if msg.MsgType != MsgHelperTrieProofs {
@@ -359,7 +359,7 @@ func (r *ChtRequest) Validate(db fstdb.Database, msg *Msg) error {
 	if len(resp.AuxData) != 1 {
 		return errInvalidEntryCount
 	}
-	nodeSet := resp.Proofs.NodeSet()
+	nodeSet := resp.Proofs.NodeSet(hasher)
 	headerEnc := resp.AuxData[0]
 	if len(headerEnc) == 0 {
 		return errHeaderUnavailable
@@ -368,6 +368,7 @@ func (r *ChtRequest) Validate(db fstdb.Database, msg *Msg) error {
 	if err := rlp.DecodeBytes(headerEnc, header); err != nil {
 		return errHeaderUnavailable
 	}
+	header.SetHasher(hasher)
 
 	// Verify the CHT
 	// Note: For untrusted CHT request, there is no proof response but
@@ -450,7 +451,7 @@ func (r *BloomRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *BloomRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *BloomRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating BloomBits", "bloomTrie", r.BloomTrieNum, "bitIdx", r.BitIdx, "sections", r.SectionIndexList)
 
 	// Ensure we have a correct message with a single proof element
@@ -459,7 +460,7 @@ func (r *BloomRequest) Validate(db fstdb.Database, msg *Msg) error {
 	}
 	resps := msg.Obj.(HelperTrieResps)
 	proofs := resps.Proofs
-	nodeSet := proofs.NodeSet()
+	nodeSet := proofs.NodeSet(hasher)
 	reads := &readTraceDB{db: nodeSet}
 
 	r.BloomBits = make([][]byte, len(r.SectionIndexList))
@@ -507,7 +508,7 @@ func (r *TxStatusRequest) Request(reqID uint64, peer *peer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *TxStatusRequest) Validate(db fstdb.Database, msg *Msg) error {
+func (r *TxStatusRequest) Validate(db fstdb.Database, hasher crypto.Hasher, msg *Msg) error {
 	log.Debug("Validating transaction status", "count", len(r.Hashes))
 
 	// Ensure we have a correct message with a single block body
@@ -55,7 +55,7 @@ func TestTrieEntryAccessLes3(t *testing.T) { testAccess(t, 3, tfTrieEntryAccess)
 
 func tfTrieEntryAccess(db fstdb.Database, bhash common.Hash, number uint64) light.OdrRequest {
 	if number := rawdb.ReadHeaderNumber(db, bhash); number != nil {
-		return &light.TrieRequest{Id: light.StateTrieID(rawdb.ReadHeader(db, bhash, *number)), Key: testBankSecureTrieKey}
+		return &light.TrieRequest{Id: light.StateTrieID(rawdb.ReadHeader(db, bhash, *number, crypto.Keccak256Hasher)), Key: testBankSecureTrieKey}
 	}
 	return nil
 }
@@ -68,7 +68,7 @@ func tfCodeAccess(db fstdb.Database, bhash common.Hash, num uint64) light.OdrReq
 	if number != nil {
 		return nil
 	}
-	header := rawdb.ReadHeader(db, bhash, *number)
+	header := rawdb.ReadHeader(db, bhash, *number, crypto.Keccak256Hasher)
 	if header.Number.Uint64() < testContractDeployed {
 		return nil
 	}
@@ -76,8 +76,8 @@ func NewLesServer(e *fst.Filestorm, config *fst.Config) (*LesServer, error) {
 			chainDb:          e.ChainDb(),
 			peers:            newPeerSet(),
 			chainReader:      e.BlockChain(),
-			chtIndexer:       light.NewChtIndexer(e.ChainDb(), nil, params.CHTFrequency, params.HelperTrieProcessConfirmations),
-			bloomTrieIndexer: light.NewBloomTrieIndexer(e.ChainDb(), nil, params.BloomBitsBlocks, params.BloomTrieFrequency),
+			chtIndexer:       light.NewChtIndexer(e.ChainDb(), nil, params.CHTFrequency, params.HelperTrieProcessConfirmations, e.BlockChain().Config().Hasher()),
+			bloomTrieIndexer: light.NewBloomTrieIndexer(e.ChainDb(), nil, params.BloomBitsBlocks, params.BloomTrieFrequency, e.BlockChain().Config().Hasher()),
 			closeCh:          make(chan struct{}),
 		},
 		archiveMode:  e.ArchiveMode(),
@@ -532,7 +532,7 @@ func (h *serverHandler) handleMsg(p *peer, wg *sync.WaitGroup) error {
 					// Retrieve the requested block's receipts, skipping if unknown to us
 					results := h.blockchain.GetReceiptsByHash(hash)
 					if results == nil {
-						if header := h.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+						if header := h.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHashOf(header.Hasher()) {
 							atomic.AddUint32(&p.invalidCount, 1)
 							continue
 						}
@@ -747,6 +747,7 @@ func (h *serverHandler) handleMsg(p *peer, wg *sync.WaitGroup) error {
 			clientErrorMeter.Mark(1)
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		types.Transactions(req.Txs).SetHasher(h.blockchain.Config().Hasher())
 		reqCnt := len(req.Txs)
 		if accept(req.ReqID, uint64(reqCnt), MaxTxSend) {
 			wg.Add(1)
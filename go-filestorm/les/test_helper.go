@@ -157,9 +157,9 @@ func prepare(n int, backend *backends.SimulatedBackend) {
 // testIndexers creates a set of indexers with specified params for testing purpose.
 func testIndexers(db fstdb.Database, odr light.OdrBackend, config *light.IndexerConfig) []*core.ChainIndexer {
 	var indexers [3]*core.ChainIndexer
-	indexers[0] = light.NewChtIndexer(db, odr, config.ChtSize, config.ChtConfirms)
-	indexers[1] = fst.NewBloomIndexer(db, config.BloomSize, config.BloomConfirms)
-	indexers[2] = light.NewBloomTrieIndexer(db, odr, config.BloomSize, config.BloomTrieSize)
+	indexers[0] = light.NewChtIndexer(db, odr, config.ChtSize, config.ChtConfirms, crypto.Keccak256Hasher)
+	indexers[1] = fst.NewBloomIndexer(db, config.BloomSize, config.BloomConfirms, crypto.Keccak256Hasher)
+	indexers[2] = light.NewBloomTrieIndexer(db, odr, config.BloomSize, config.BloomTrieSize, crypto.Keccak256Hasher)
 	// make bloomTrieIndexer as a child indexer of bloom indexer.
 	indexers[1].AddChildIndexer(indexers[2])
 	return indexers[:]
@@ -490,7 +490,7 @@ func newClientServerEnv(t *testing.T, blocks int, protocol int, callback indexer
 	}
 	dist := newRequestDistributor(cPeers, clock)
 	rm := newRetrieveManager(cPeers, dist, nil)
-	odr := NewLesOdr(cdb, light.TestClientIndexerConfig, rm)
+	odr := NewLesOdr(cdb, light.TestClientIndexerConfig, crypto.Keccak256Hasher, rm)
 
 	sindexers := testIndexers(sdb, nil, light.TestServerIndexerConfig)
 	cIndexers := testIndexers(cdb, odr, light.TestClientIndexerConfig)
@@ -26,6 +26,7 @@ import (
 	"github.com/filestorm/go-filestorm/core"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/params"
 )
@@ -281,6 +282,10 @@ func (odr *dummyOdr) IndexerConfig() *IndexerConfig {
 	return odr.indexerConfig
 }
 
+func (odr *dummyOdr) Hasher() crypto.Hasher {
+	return crypto.Keccak256Hasher
+}
+
 // Tests that reorganizing a long difficult chain after a short easy one
 // overwrites the canonical numbers and links in the database.
 func TestReorgLongHeaders(t *testing.T) {
@@ -127,17 +127,18 @@ func (db *NodeSet) Store(target fstdb.KeyValueWriter) {
 // NodeList stores an ordered list of trie nodes. It implements fstdb.KeyValueWriter.
 type NodeList []rlp.RawValue
 
-// Store writes the contents of the list to the given database
-func (n NodeList) Store(db fstdb.KeyValueWriter) {
+// Store writes the contents of the list to the given database, keyed by their
+// hash with the given hash function
+func (n NodeList) Store(db fstdb.KeyValueWriter, hasher crypto.Hasher) {
 	for _, node := range n {
-		db.Put(crypto.Keccak256(node), node)
+		db.Put(hasher.Hash(node).Bytes(), node)
 	}
 }
 
 // NodeSet converts the node list to a NodeSet
-func (n NodeList) NodeSet() *NodeSet {
+func (n NodeList) NodeSet(hasher crypto.Hasher) *NodeSet {
 	db := NewNodeSet()
-	n.Store(db)
+	n.Store(db, hasher)
 	return db
 }
 
@@ -25,6 +25,7 @@ import (
 	"github.com/filestorm/go-filestorm/core"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 )
 
@@ -43,6 +44,7 @@ type OdrBackend interface {
 	BloomIndexer() *core.ChainIndexer
 	Retrieve(ctx context.Context, req OdrRequest) error
 	IndexerConfig() *IndexerConfig
+	Hasher() crypto.Hasher
 }
 
 // OdrRequest is an interface for retrieval requests
@@ -55,6 +57,7 @@ type TrieID struct {
 	BlockHash, Root common.Hash
 	BlockNumber     uint64
 	AccKey          []byte
+	Hasher          crypto.Hasher // Hash function of the chain
 }
 
 // StateTrieID returns a TrieID for a state trie belonging to a certain block
@@ -65,6 +68,7 @@ func StateTrieID(header *types.Header) *TrieID {
 		BlockNumber: header.Number.Uint64(),
 		AccKey:      nil,
 		Root:        header.Root,
+		Hasher:      header.Hasher(),
 	}
 }
 
@@ -77,6 +81,7 @@ func StorageTrieID(state *TrieID, addrHash, root common.Hash) *TrieID {
 		BlockNumber: state.BlockNumber,
 		AccKey:      addrHash[:],
 		Root:        root,
+		Hasher:      state.Hasher,
 	}
 }
 
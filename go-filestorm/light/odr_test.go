@@ -97,6 +97,10 @@ func (odr *testOdr) IndexerConfig() *IndexerConfig {
 	return odr.indexerConfig
 }
 
+func (odr *testOdr) Hasher() crypto.Hasher {
+	return crypto.Keccak256Hasher
+}
+
 type odrTestFn func(ctx context.Context, db fstdb.Database, bc *core.BlockChain, lc *LightChain, bhash common.Hash) ([]byte, error)
 
 func TestOdrGetBlockLes2(t *testing.T) { testChainOdr(t, 1, odrGetBlock) }
@@ -35,7 +35,7 @@ func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*typ
 	hash := rawdb.ReadCanonicalHash(db, number)
 	if (hash != common.Hash{}) {
 		// if there is a canonical hash, there is a header too
-		header := rawdb.ReadHeader(db, hash, number)
+		header := rawdb.ReadHeader(db, hash, number, odr.Hasher())
 		if header == nil {
 			panic("Canonical hash present but header not found")
 		}
@@ -115,6 +115,7 @@ func GetBody(ctx context.Context, odr OdrBackend, hash common.Hash, number uint6
 	if err := rlp.Decode(bytes.NewReader(data), body); err != nil {
 		return nil, err
 	}
+	body.SetHasher(odr.Hasher())
 	return body, nil
 }
 
@@ -122,7 +123,7 @@ func GetBody(ctx context.Context, odr OdrBackend, hash common.Hash, number uint6
 // back from the stored header and body.
 func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*types.Block, error) {
 	// Retrieve the block header and body contents
-	header := rawdb.ReadHeader(odr.Database(), hash, number)
+	header := rawdb.ReadHeader(odr.Database(), hash, number, odr.Hasher())
 	if header == nil {
 		return nil, errNoHeader
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/filestorm/go-filestorm/core"
 	"github.com/filestorm/go-filestorm/core/rawdb"
 	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/params"
@@ -136,17 +137,17 @@ type ChtIndexerBackend struct {
 	trie                 *trie.Trie
 }
 
-// NewChtIndexer creates a Cht chain indexer
-func NewChtIndexer(db fstdb.Database, odr OdrBackend, size, confirms uint64) *core.ChainIndexer {
+// NewChtIndexer creates a Cht chain indexer hashing with the given hash function
+func NewChtIndexer(db fstdb.Database, odr OdrBackend, size, confirms uint64, hasher crypto.Hasher) *core.ChainIndexer {
 	trieTable := rawdb.NewTable(db, ChtTablePrefix)
 	backend := &ChtIndexerBackend{
 		diskdb:      db,
 		odr:         odr,
 		trieTable:   trieTable,
-		triedb:      trie.NewDatabaseWithCache(trieTable, 1), // Use a tiny cache only to keep memory down
+		triedb:      trie.NewDatabaseWithHasher(trieTable, 1, hasher), // Use a tiny cache only to keep memory down
 		sectionSize: size,
 	}
-	return core.NewChainIndexer(db, rawdb.NewTable(db, "chtIndexV2-"), backend, size, confirms, time.Millisecond*100, "cht")
+	return core.NewChainIndexer(db, rawdb.NewTable(db, "chtIndexV2-"), backend, size, confirms, time.Millisecond*100, "cht", hasher)
 }
 
 // fetchMissingNodes tries to retrieve the last entry of the latest trusted CHT from the
@@ -256,20 +257,21 @@ type BloomTrieIndexerBackend struct {
 	sectionHeads      []common.Hash
 }
 
-// NewBloomTrieIndexer creates a BloomTrie chain indexer
-func NewBloomTrieIndexer(db fstdb.Database, odr OdrBackend, parentSize, size uint64) *core.ChainIndexer {
+// NewBloomTrieIndexer creates a BloomTrie chain indexer hashing with the given
+// hash function
+func NewBloomTrieIndexer(db fstdb.Database, odr OdrBackend, parentSize, size uint64, hasher crypto.Hasher) *core.ChainIndexer {
 	trieTable := rawdb.NewTable(db, BloomTrieTablePrefix)
 	backend := &BloomTrieIndexerBackend{
 		diskdb:     db,
 		odr:        odr,
 		trieTable:  trieTable,
-		triedb:     trie.NewDatabaseWithCache(trieTable, 1), // Use a tiny cache only to keep memory down
+		triedb:     trie.NewDatabaseWithHasher(trieTable, 1, hasher), // Use a tiny cache only to keep memory down
 		parentSize: parentSize,
 		size:       size,
 	}
 	backend.bloomTrieRatio = size / parentSize
 	backend.sectionHeads = make([]common.Hash, backend.bloomTrieRatio)
-	return core.NewChainIndexer(db, rawdb.NewTable(db, "bltIndex-"), backend, size, 0, time.Millisecond*100, "bloomtrie")
+	return core.NewChainIndexer(db, rawdb.NewTable(db, "bltIndex-"), backend, size, 0, time.Millisecond*100, "bloomtrie", hasher)
 }
 
 // fetchMissingNodes tries to retrieve the last entries of the latest trusted bloom trie from the
@@ -89,6 +89,10 @@ func (db *odrDatabase) TrieDB() *trie.Database {
 	return nil
 }
 
+func (db *odrDatabase) Hasher() crypto.Hasher {
+	return db.id.Hasher
+}
+
 type odrTrie struct {
 	db   *odrDatabase
 	id   *TrieID
@@ -96,7 +100,7 @@ type odrTrie struct {
 }
 
 func (t *odrTrie) TryGet(key []byte) ([]byte, error) {
-	key = crypto.Keccak256(key)
+	key = t.id.Hasher.Hash(key).Bytes()
 	var res []byte
 	err := t.do(key, func() (err error) {
 		res, err = t.trie.TryGet(key)
@@ -106,14 +110,14 @@ func (t *odrTrie) TryGet(key []byte) ([]byte, error) {
 }
 
 func (t *odrTrie) TryUpdate(key, value []byte) error {
-	key = crypto.Keccak256(key)
+	key = t.id.Hasher.Hash(key).Bytes()
 	return t.do(key, func() error {
 		return t.trie.TryUpdate(key, value)
 	})
 }
 
 func (t *odrTrie) TryDelete(key []byte) error {
-	key = crypto.Keccak256(key)
+	key = t.id.Hasher.Hash(key).Bytes()
 	return t.do(key, func() error {
 		return t.trie.TryDelete(key)
 	})
@@ -151,7 +155,7 @@ func (t *odrTrie) do(key []byte, fn func() error) error {
 	for {
 		var err error
 		if t.trie == nil {
-			t.trie, err = trie.New(t.id.Root, trie.NewDatabase(t.db.backend.Database()))
+			t.trie, err = trie.New(t.id.Root, trie.NewDatabaseWithHasher(t.db.backend.Database(), 0, t.id.Hasher))
 		}
 		if err == nil {
 			err = fn()
@@ -177,7 +181,7 @@ func newNodeIterator(t *odrTrie, startkey []byte) trie.NodeIterator {
 	// Open the actual non-ODR trie if that hasn't happened yet.
 	if t.trie == nil {
 		it.do(func() error {
-			t, err := trie.New(t.id.Root, trie.NewDatabase(t.db.backend.Database()))
+			t, err := trie.New(t.id.Root, trie.NewDatabaseWithHasher(t.db.backend.Database(), 0, t.id.Hasher))
 			if err == nil {
 				it.t.trie = t
 			}
@@ -51,6 +51,15 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in fstash).
+	Flush     FlushConfig    `toml:"-"` // Flushing of sealed blocks to the main chain(only useful in pbft).
+}
+
+// FlushConfig is the configuration of flushing sealed appchain blocks into the
+// AppChainBase contract of the chain on the main chain.
+type FlushConfig struct {
+	Enabled  bool   // Whether sealed flush blocks are sent to the main chain
+	Endpoint string // HTTP host:port of the main chain node
+	Contract string // Address of the AppChainBase contract
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -159,6 +168,12 @@ func (miner *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetFlushCredentials sets the encrypted key and its password used to sign the
+// flush transactions sent to the main chain.
+func (miner *Miner) SetFlushCredentials(keystore, password string) {
+	miner.worker.setFlushCredentials(keystore, password)
+}
+
 // SetRecommitInterval sets the interval for sealing work resubmitting.
 func (miner *Miner) SetRecommitInterval(interval time.Duration) {
 	miner.worker.setRecommitInterval(interval)
@@ -19,13 +19,10 @@ package miner
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"github.com/filestorm/go-filestorm/event"
 	"github.com/filestorm/go-filestorm/flush"
 	"github.com/filestorm/go-filestorm/fstclient"
-	"github.com/filestorm/go-filestorm/node"
 	"math/big"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -164,9 +161,11 @@ type worker struct {
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
-	coinbase common.Address
-	extra    []byte
+	mu            sync.RWMutex // The lock used to protect the coinbase, extra and flush credential fields
+	coinbase      common.Address
+	extra         []byte
+	flushKeystore string // Encrypted key signing the flush transactions
+	flushPassword string // Password decrypting the flush key
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -231,9 +230,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	go worker.newWorkLoop(recommit)
 	go worker.resultLoop()
 	go worker.taskLoop()
-	//TODO
-	fmt.Println(node.DefaultConfig.VsFlag)
-	if strings.EqualFold(node.DefaultConfig.VsFlag,"false") {
+	if config.Flush.Enabled {
 		go worker.sendFlush()
 	}
 
@@ -258,6 +255,14 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setFlushCredentials sets the key used to sign the flush transactions sent to
+// the main chain.
+func (w *worker) setFlushCredentials(keystore, password string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushKeystore, w.flushPassword = keystore, password
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -615,7 +620,7 @@ func (w *worker) resultLoop() {
 				continue
 			}
 
-			if node.DefaultConfig.VsFlag == "false"{
+			if w.config.Flush.Enabled {
 				event := flushEvent{
 					block,
 				}
@@ -638,19 +643,15 @@ func (w *worker) resultLoop() {
 }
 
 func (w *worker) sendFlush()  {
-	flushEpoch := int64(w.chainConfig.Pbft.FlushEpoch)
-	flushWait := int64(5) // wait 10 blocks to flush
-
 		for {
 			select {
 			case data := <- w.flushChan:
-				mod := new(big.Int)
-				mod = mod.Mod(data.block.Number(), big.NewInt(flushEpoch))
-				if mod.Cmp(big.NewInt(flushWait)) == 0 {
+				if w.chainConfig.Pbft.IsFlushBlock(data.block.NumberU64()) {
 					// do flushing
-					client ,err := fstclient.Dial("http://"+ node.DefaultConfig.NodeIp)
+					client ,err := fstclient.Dial("http://"+ w.config.Flush.Endpoint)
 					if err != nil {
-						log.Error("---connect nodeIp error, ip : %s",node.DefaultConfig.NodeIp)
+						log.Error("---connect nodeIp error", "ip", w.config.Flush.Endpoint, "err", err)
+						continue
 					}
 					var validators []common.Address
 					signers, err := w.engine.GetSigners(w.chain, data.block.Header())
@@ -661,12 +662,19 @@ func (w *worker) sendFlush()  {
 						address := common.HexToAddress(signers[i].Hex())
 						validators = append(validators,address)
 					}
-					txHash, err := flush.ClientFlush(client, node.DefaultConfig.CoinBaseKeystore, node.DefaultConfig.CoinBasePassword, node.DefaultConfig.ContractAddress, validators,data.block.Number(),data.block.Hash().String())
+					w.mu.RLock()
+					keystore, password := w.flushKeystore, w.flushPassword
+					w.mu.RUnlock()
+
+					txHash, err := flush.ClientFlush(client, keystore, password, w.config.Flush.Contract, validators,data.block.Number(),data.block.Hash().String())
+					client.Close()
 					if err != nil{
 						log.Error("---flushing to mainnet error ",err)
 					}
 					log.Info("---flushing to mainnet-->", "network", w.chainConfig.ChainID,"flushBlock=", data.block.Number().Uint64() ,"txHash=",txHash )
 				}
+			case <-w.exitCh:
+				return
 			}
 		}
 }
@@ -678,7 +686,7 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		return err
 	}
 	env := &environment{
-		signer:    types.NewEIP155Signer(w.chainConfig.ChainID),
+		signer:    types.NewChainSigner(w.chainConfig),
 		state:     state,
 		ancestors: mapset.NewSet(),
 		family:    mapset.NewSet(),
@@ -906,6 +914,7 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		Extra:      w.extra,
 		Time:       uint64(timestamp),
 	}
+	header.SetHasher(w.chainConfig.Hasher())
 	// Only set the coinbase if our consensus engine is running (avoid spurious block rewards)
 	if w.isRunning() {
 		if w.coinbase == (common.Address{}) {
@@ -41,6 +41,7 @@ import (
 
 const (
 	datadirPrivateKey      = "nodekey"            // Path within the datadir to the node's private key
+	datadirPrivateKeySM2   = "nodekey-sm2"        // Path within the datadir to the node's SM2 private key
 	datadirDefaultKeyStore = "keystore"           // Path within the datadir to the keystore
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
@@ -70,15 +71,17 @@ type Config struct {
 	// in memory.
 	DataDir string
 
-	//TODO
+	// NodeIp is the HTTP host:port of the main chain node that sealed blocks
+	// are flushed to.
 	NodeIp string
-	//TODO
+	// ContractAddress is the address of the AppChainBase contract on the main
+	// chain that sealed blocks are flushed into.
 	ContractAddress string
-	//TODO
+	// Deprecated: the flush transactions are signed with the key of the first
+	// keystore account, these fields are only kept to load old configs.
 	CoinBasePassword string
-	//TODO
 	CoinBaseKeystore string
-	//TODO
+	// VsFlag disables flushing unless set to "false".
 	VsFlag string
 
 	// Configuration of peer-to-peer networking.
@@ -100,6 +103,10 @@ type Config struct {
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// AddressHasher is the hash function deriving the addresses of the keys in
+	// the key store, the one of the chain they sign transactions for.
+	AddressHasher crypto.Hasher `toml:"-"`
+
 	// InsecureUnlockAllowed allows user to unlock accounts in unsafe http environment.
 	InsecureUnlockAllowed bool `toml:",omitempty"`
 
@@ -361,6 +368,19 @@ func (c *Config) ResolvePath(path string) string {
 	return filepath.Join(c.instanceDir(), path)
 }
 
+// resolveFreezer resolves the ancient store directory of the database at root.
+// An empty freezer defaults to the ancient folder inside the database, relative
+// paths are resolved in the instance directory.
+func (c *Config) resolveFreezer(root, freezer string) string {
+	switch {
+	case freezer == "":
+		return filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		return c.ResolvePath(freezer)
+	}
+	return freezer
+}
+
 func (c *Config) instanceDir() string {
 	if c.DataDir == "" {
 		return ""
@@ -378,19 +398,24 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	}
 	// Generate ephemeral key if no datadir is being used.
 	if c.DataDir == "" {
-		key, err := crypto.GenerateKey()
+		key, err := p2p.GenerateNodeKey(c.P2P.IdentityScheme)
 		if err != nil {
 			log.Crit(fmt.Sprintf("Failed to generate ephemeral node key: %v", err))
 		}
 		return key
 	}
 
-	keyfile := c.ResolvePath(datadirPrivateKey)
-	if key, err := crypto.LoadECDSA(keyfile); err == nil {
+	// The sm2 scheme has its own key, the secp256k1 key is never reused for it
+	name := datadirPrivateKey
+	if c.P2P.IdentityScheme == p2p.IdentitySchemeSM2 {
+		name = datadirPrivateKeySM2
+	}
+	keyfile := c.ResolvePath(name)
+	if key, err := p2p.LoadNodeKey(c.P2P.IdentityScheme, keyfile); err == nil {
 		return key
 	}
 	// No persistent key found, generate and store a new one.
-	key, err := crypto.GenerateKey()
+	key, err := p2p.GenerateNodeKey(c.P2P.IdentityScheme)
 	if err != nil {
 		log.Crit(fmt.Sprintf("Failed to generate node key: %v", err))
 	}
@@ -399,7 +424,7 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 		log.Error(fmt.Sprintf("Failed to persist node key: %v", err))
 		return key
 	}
-	keyfile = filepath.Join(instanceDir, datadirPrivateKey)
+	keyfile = filepath.Join(instanceDir, name)
 	if err := crypto.SaveECDSA(keyfile, key); err != nil {
 		log.Error(fmt.Sprintf("Failed to persist node key: %v", err))
 	}
@@ -478,6 +503,14 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 	return scryptN, scryptP, keydir, err
 }
 
+// PBKDF2Iterations determines the PBKDF2 iterations of version 4 (SM4) key files.
+func (c *Config) PBKDF2Iterations() int {
+	if c.UseLightweightKDF {
+		return keystore.LightPBKDF2Iterations
+	}
+	return keystore.StandardPBKDF2Iterations
+}
+
 func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	scryptN, scryptP, keydir, err := conf.AccountConfig()
 	var ephemeral string
@@ -508,7 +541,7 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 		// If/when we implement some form of lockfile for USB and keystore wallets,
 		// we can have both, but it's very confusing for the user to see the same
 		// accounts in both externally and locally, plus very racey.
-		backends = append(backends, keystore.NewKeyStore(keydir, scryptN, scryptP))
+		backends = append(backends, keystore.NewKeyStoreWithHasher(keydir, scryptN, scryptP, conf.PBKDF2Iterations(), conf.AddressHasher))
 		if !conf.NoUSB {
 			// Start a USB hub for Ledger hardware wallets
 			if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
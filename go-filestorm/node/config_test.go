@@ -25,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/p2p"
 )
 
@@ -160,3 +161,33 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that the sm2 identity scheme persists a fresh SM2 node key of its own
+// instead of reusing the secp256k1 node key.
+func TestNodeKeySM2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	v4key := (&Config{Name: "unit-test", DataDir: dir}).NodeKey()
+	config := &Config{Name: "unit-test", DataDir: dir, P2P: p2p.Config{IdentityScheme: p2p.IdentitySchemeSM2}}
+	key := config.NodeKey()
+	if !sm2.IsSM2(&key.PublicKey) {
+		t.Fatalf("node key is not an SM2 key")
+	}
+	if key.D.Cmp(v4key.D) == 0 {
+		t.Fatalf("SM2 node key reuses the secp256k1 node key")
+	}
+	loaded, err := p2p.LoadNodeKey(p2p.IdentitySchemeSM2, filepath.Join(dir, "unit-test", datadirPrivateKeySM2))
+	if err != nil {
+		t.Fatalf("failed to load persisted SM2 node key: %v", err)
+	}
+	if loaded.D.Cmp(key.D) != 0 || config.NodeKey().D.Cmp(key.D) != 0 {
+		t.Fatalf("persisted SM2 node key mismatch")
+	}
+	if (&Config{Name: "unit-test", DataDir: dir}).NodeKey().D.Cmp(v4key.D) != 0 {
+		t.Fatalf("secp256k1 node key changed")
+	}
+}
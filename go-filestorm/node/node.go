@@ -623,14 +623,24 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 		return rawdb.NewMemoryDatabase(), nil
 	}
 	root := n.config.ResolvePath(name)
+	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, n.config.resolveFreezer(root, freezer), namespace)
+}
 
-	switch {
-	case freezer == "":
-		freezer = filepath.Join(root, "ancient")
-	case !filepath.IsAbs(freezer):
-		freezer = n.config.ResolvePath(freezer)
+// OpenDatabaseWithAncientStore opens an existing database with the given name
+// (or creates one if no previous can be found) from within the node's data
+// directory, moving immutable chain segments into the ancient store created by
+// open for the resolved freezer directory. If the node is an ephemeral one, a
+// memory database is returned.
+func (n *Node) OpenDatabaseWithAncientStore(name string, cache, handles int, freezer, namespace string, open AncientStoreOpener) (fstdb.Database, error) {
+	if n.config.DataDir == "" {
+		return rawdb.NewMemoryDatabase(), nil
+	}
+	root := n.config.ResolvePath(name)
+	ancients, err := open(n.config.resolveFreezer(root, freezer), namespace)
+	if err != nil {
+		return nil, err
 	}
-	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+	return rawdb.NewLevelDBDatabaseWithAncientStore(root, cache, handles, ancients, namespace)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
@@ -17,7 +17,6 @@
 package node
 
 import (
-	"path/filepath"
 	"reflect"
 
 	"github.com/filestorm/go-filestorm/accounts"
@@ -58,14 +57,24 @@ func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handl
 		return rawdb.NewMemoryDatabase(), nil
 	}
 	root := ctx.config.ResolvePath(name)
+	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, ctx.config.resolveFreezer(root, freezer), namespace)
+}
 
-	switch {
-	case freezer == "":
-		freezer = filepath.Join(root, "ancient")
-	case !filepath.IsAbs(freezer):
-		freezer = ctx.config.ResolvePath(freezer)
+// OpenDatabaseWithAncientStore opens an existing database with the given name
+// (or creates one if no previous can be found) from within the node's data
+// directory, moving immutable chain segments into the ancient store created by
+// open for the resolved freezer directory. If the node is an ephemeral one, a
+// memory database is returned.
+func (ctx *ServiceContext) OpenDatabaseWithAncientStore(name string, cache int, handles int, freezer string, namespace string, open AncientStoreOpener) (fstdb.Database, error) {
+	if ctx.config.DataDir == "" {
+		return rawdb.NewMemoryDatabase(), nil
 	}
-	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+	root := ctx.config.ResolvePath(name)
+	ancients, err := open(ctx.config.resolveFreezer(root, freezer), namespace)
+	if err != nil {
+		return nil, err
+	}
+	return rawdb.NewLevelDBDatabaseWithAncientStore(root, cache, handles, ancients, namespace)
 }
 
 // ResolvePath resolves a user path into the data directory if that was relative
@@ -91,6 +100,10 @@ func (ctx *ServiceContext) ExtRPCEnabled() bool {
 	return ctx.config.ExtRPCEnabled()
 }
 
+// AncientStoreOpener is the function signature of the constructors of ancient
+// stores replacing the default chain freezer in the given freezer directory.
+type AncientStoreOpener func(freezer string, namespace string) (fstdb.AncientStore, error)
+
 // ServiceConstructor is the function signature of the constructors needed to be
 // registered for service instantiation.
 type ServiceConstructor func(ctx *ServiceContext) (Service, error)
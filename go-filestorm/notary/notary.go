@@ -0,0 +1,97 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package notary implements notarization proofs: self-contained evidence that
+// a content hash was recorded by the notarization system contract, which can
+// be verified offline against the block headers they contain.
+package notary
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+)
+
+// Hash algorithms content can be notarized with.
+const (
+	AlgorithmKeccak256 = "keccak256"
+	AlgorithmSM3       = "sm3"
+)
+
+var notaryABI abi.ABI
+
+func init() {
+	var err error
+	if notaryABI, err = abi.JSON(strings.NewReader(vm.NotaryABI)); err != nil {
+		panic(err)
+	}
+}
+
+// Record is a notarization as recorded by the system contract.
+type Record struct {
+	Hash        common.Hash    `json:"hash"`
+	Algorithm   string         `json:"algorithm"`
+	Owner       common.Address `json:"owner"`
+	Timestamp   uint64         `json:"timestamp"`
+	BlockNumber uint64         `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	Metadata    string         `json:"metadata"`
+}
+
+// HashContent hashes content with the named algorithm.
+func HashContent(algorithm string, content []byte) (common.Hash, error) {
+	switch algorithm {
+	case AlgorithmKeccak256:
+		return crypto.Keccak256Hash(content), nil
+	case AlgorithmSM3:
+		return common.Hash(sm3.Sum(content)), nil
+	}
+	return common.Hash{}, fmt.Errorf("unknown hash algorithm %q", algorithm)
+}
+
+// PackNotarize creates the call data of a system contract call notarizing hash.
+func PackNotarize(hash common.Hash, algorithm, metadata string) ([]byte, error) {
+	code, err := algorithmCode(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return notaryABI.Pack("notarize", hash, code, metadata)
+}
+
+func algorithmCode(name string) (uint8, error) {
+	switch name {
+	case AlgorithmKeccak256:
+		return vm.NotaryKeccak256, nil
+	case AlgorithmSM3:
+		return vm.NotarySM3, nil
+	}
+	return 0, fmt.Errorf("unknown hash algorithm %q", name)
+}
+
+func algorithmName(code uint8) (string, error) {
+	switch code {
+	case vm.NotaryKeccak256:
+		return AlgorithmKeccak256, nil
+	case vm.NotarySM3:
+		return AlgorithmSM3, nil
+	}
+	return "", fmt.Errorf("unknown hash algorithm %d", code)
+}
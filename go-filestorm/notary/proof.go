@@ -0,0 +1,323 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package notary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb/memorydb"
+	"github.com/filestorm/go-filestorm/params"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/trie"
+)
+
+var (
+	errNotNotarized = errors.New("content hash is not notarized")
+	errNoHeaders    = errors.New("proof contains no headers")
+)
+
+// Proof is the evidence of a notarization. It contains the header of the block
+// the notarization was recorded in and the transaction and receipt with their
+// Merkle proofs against that header. Once the chain has flushed a later block
+// to its parent chain, the proof also contains the headers linking the block to
+// this anchor, so the proof can be tied to the public record on the parent chain.
+type Proof struct {
+	ChainID      *hexutil.Big    `json:"chainId"`
+	HashFunction string          `json:"hashFunction"` // chain hash of headers and tries
+	Record       Record          `json:"record"`
+	Headers      []hexutil.Bytes `json:"headers"` // RLP headers from the notarization block up to the anchor
+	TxIndex      uint64          `json:"transactionIndex"`
+	Transaction  hexutil.Bytes   `json:"transaction"`
+	TxProof      []hexutil.Bytes `json:"transactionProof"`
+	Receipt      hexutil.Bytes   `json:"receipt"`
+	ReceiptProof []hexutil.Bytes `json:"receiptProof"`
+	Anchor       *Anchor         `json:"anchor,omitempty"`
+}
+
+// Anchor is a block whose hash was flushed to the parent chain.
+type Anchor struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+}
+
+// NewProof creates the proof of the notarization of hash recorded in block.
+func NewProof(config *params.ChainConfig, block *types.Block, receipts types.Receipts, hash common.Hash) (*Proof, error) {
+	for i, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			record, err := parseLog(log)
+			if err != nil {
+				return nil, err
+			}
+			if record == nil || record.Hash != hash {
+				continue
+			}
+			tx := block.Transactions()[i]
+			record.BlockNumber = block.NumberU64()
+			record.TxHash = tx.Hash()
+
+			p := &Proof{
+				ChainID:      (*hexutil.Big)(config.ChainID),
+				HashFunction: params.HashFunctionKeccak256,
+				Record:       *record,
+				TxIndex:      uint64(i),
+				Transaction:  block.Transactions().GetRlp(i),
+				Receipt:      receipts.GetRlp(i),
+			}
+			if config.IsSM3() {
+				p.HashFunction = params.HashFunctionSM3
+			}
+			header, err := rlp.EncodeToBytes(block.Header())
+			if err != nil {
+				return nil, err
+			}
+			p.Headers = []hexutil.Bytes{header}
+			if p.TxProof, err = proveIndex(block.Transactions(), i, config.Hasher()); err != nil {
+				return nil, err
+			}
+			if p.ReceiptProof, err = proveIndex(receipts, i, config.Hasher()); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+	}
+	return nil, errNotNotarized
+}
+
+// SetAnchor adds the headers following the notarization block up to the anchor
+// block, which is the last of them. Without headers the notarization block is
+// the anchor.
+func (p *Proof) SetAnchor(headers []*types.Header) error {
+	if len(headers) == 0 {
+		last := new(types.Header)
+		if err := rlp.DecodeBytes(p.Headers[0], last); err != nil {
+			return err
+		}
+		last.SetHasher(p.hasher())
+		headers = []*types.Header{last}
+	} else {
+		for _, header := range headers {
+			enc, err := rlp.EncodeToBytes(header)
+			if err != nil {
+				return err
+			}
+			p.Headers = append(p.Headers, enc)
+		}
+	}
+	last := headers[len(headers)-1]
+	p.Anchor = &Anchor{BlockNumber: last.Number.Uint64(), BlockHash: last.Hash()}
+	return nil
+}
+
+// Verify checks the proof and returns the notarization it proves, hashing
+// with the chain hash function named in the proof.
+//
+// Verify only checks that the proof is consistent: the headers can be made up,
+// so the notarization is proven once the anchor hash is found on the parent
+// chain.
+func Verify(p *Proof) (*Record, error) {
+	switch p.HashFunction {
+	case "", params.HashFunctionKeccak256, params.HashFunctionSM3:
+	default:
+		return nil, fmt.Errorf("unsupported chain hash %q", p.HashFunction)
+	}
+	headers, err := verifyHeaders(p)
+	if err != nil {
+		return nil, err
+	}
+	header := headers[0]
+	if header.Number.Uint64() != p.Record.BlockNumber {
+		return nil, fmt.Errorf("record block %d doesn't match header %d", p.Record.BlockNumber, header.Number)
+	}
+
+	// Prove the transaction and its receipt against the header.
+	key, _ := rlp.EncodeToBytes(uint(p.TxIndex))
+	enc, err := verifyMerkle(header.TxHash, key, p.TxProof, p.hasher())
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction proof: %v", err)
+	}
+	if !bytes.Equal(enc, p.Transaction) {
+		return nil, errors.New("transaction doesn't match its proof")
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(enc, tx); err != nil {
+		return nil, err
+	}
+	tx.SetHasher(p.hasher())
+	if tx.Hash() != p.Record.TxHash {
+		return nil, fmt.Errorf("transaction hash mismatch: have %x, want %x", tx.Hash(), p.Record.TxHash)
+	}
+	enc, err = verifyMerkle(header.ReceiptHash, key, p.ReceiptProof, p.hasher())
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt proof: %v", err)
+	}
+	if !bytes.Equal(enc, p.Receipt) {
+		return nil, errors.New("receipt doesn't match its proof")
+	}
+	receipt := new(types.Receipt)
+	if err := rlp.DecodeBytes(enc, receipt); err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, errors.New("notarization transaction failed")
+	}
+
+	// Check the record against the event of the system contract.
+	for _, log := range receipt.Logs {
+		record, err := parseLog(log)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil || record.Hash != p.Record.Hash {
+			continue
+		}
+		record.BlockNumber, record.TxHash = p.Record.BlockNumber, p.Record.TxHash
+		if *record != p.Record {
+			return nil, fmt.Errorf("record doesn't match the receipt: have %+v, want %+v", p.Record, *record)
+		}
+		if record.Timestamp != header.Time {
+			return nil, fmt.Errorf("record timestamp %d doesn't match header %d", record.Timestamp, header.Time)
+		}
+		return record, nil
+	}
+	return nil, errNotNotarized
+}
+
+// VerifyContent checks that content hashes to the notarized hash.
+func VerifyContent(record *Record, content []byte) error {
+	hash, err := HashContent(record.Algorithm, content)
+	if err != nil {
+		return err
+	}
+	if hash != record.Hash {
+		return fmt.Errorf("content hash %x doesn't match the notarized hash %x", hash, record.Hash)
+	}
+	return nil
+}
+
+// verifyHeaders decodes the headers of the proof and checks that they form a
+// chain ending in the anchor.
+func verifyHeaders(p *Proof) ([]*types.Header, error) {
+	if len(p.Headers) == 0 {
+		return nil, errNoHeaders
+	}
+	headers := make([]*types.Header, len(p.Headers))
+	for i, enc := range p.Headers {
+		headers[i] = new(types.Header)
+		if err := rlp.DecodeBytes(enc, headers[i]); err != nil {
+			return nil, fmt.Errorf("invalid header %d: %v", i, err)
+		}
+		headers[i].SetHasher(p.hasher())
+		if i > 0 && (headers[i].ParentHash != headers[i-1].Hash() || headers[i].Number.Uint64() != headers[i-1].Number.Uint64()+1) {
+			return nil, fmt.Errorf("header %d doesn't link to its predecessor", i)
+		}
+	}
+	if p.Anchor != nil {
+		last := headers[len(headers)-1]
+		if last.Number.Uint64() != p.Anchor.BlockNumber || last.Hash() != p.Anchor.BlockHash {
+			return nil, errors.New("headers don't end in the anchor block")
+		}
+	} else if len(headers) > 1 {
+		return nil, errors.New("headers without anchor")
+	}
+	return headers, nil
+}
+
+// hasher returns the chain hash function of the proof.
+func (p *Proof) hasher() crypto.Hasher {
+	if p.HashFunction == params.HashFunctionSM3 {
+		return crypto.SM3Hasher
+	}
+	return crypto.Keccak256Hasher
+}
+
+// parseLog returns the record of a Notarized event of the system contract,
+// or nil if log is a different event.
+func parseLog(log *types.Log) (*Record, error) {
+	event := notaryABI.Events["Notarized"]
+	if log.Address != vm.NotaryAddress || len(log.Topics) != 3 || log.Topics[0] != event.ID() {
+		return nil, nil
+	}
+	var data struct {
+		Algorithm uint8
+		Timestamp uint64
+		Metadata  string
+	}
+	if err := notaryABI.Unpack(&data, "Notarized", log.Data); err != nil {
+		return nil, err
+	}
+	algorithm, err := algorithmName(data.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &Record{
+		Hash:      log.Topics[1],
+		Algorithm: algorithm,
+		Owner:     common.BytesToAddress(log.Topics[2][:]),
+		Timestamp: data.Timestamp,
+		Metadata:  data.Metadata,
+	}, nil
+}
+
+// proveIndex creates the Merkle proof of the index'th item of list in the trie
+// types.DeriveSha derives the list hash from.
+func proveIndex(list types.DerivableList, index int, hasher crypto.Hasher) ([]hexutil.Bytes, error) {
+	t := trie.NewEmpty(hasher)
+	keybuf := new(bytes.Buffer)
+	for i := 0; i < list.Len(); i++ {
+		keybuf.Reset()
+		rlp.Encode(keybuf, uint(i))
+		t.Update(keybuf.Bytes(), list.GetRlp(i))
+	}
+	key, _ := rlp.EncodeToBytes(uint(index))
+	var nodes proofList
+	if err := t.Prove(key, 0, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// verifyMerkle checks a proof created by proveIndex against the trie root.
+func verifyMerkle(root common.Hash, key []byte, proof []hexutil.Bytes, hasher crypto.Hasher) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(hasher.Hash(node).Bytes(), node)
+	}
+	value, _, err := trie.VerifyProof(root, key, db)
+	if err == nil && value == nil {
+		err = errors.New("missing item")
+	}
+	return value, err
+}
+
+// proofList collects the nodes of a Merkle proof.
+type proofList []hexutil.Bytes
+
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, common.CopyBytes(value))
+	return nil
+}
+
+func (l *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
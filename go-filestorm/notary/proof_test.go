@@ -0,0 +1,134 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package notary
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/consensus/fstash"
+	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/core/rawdb"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/core/vm"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testContent = []byte("sales contract #42")
+)
+
+// makeProof notarizes testContent in the first of n blocks and returns the
+// proof together with the chain.
+func makeProof(t *testing.T, n int) (*Proof, []*types.Block) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		config  = params.AllFstashProtocolChanges
+		gspec   = &core.Genesis{Config: config, Alloc: core.GenesisAlloc{testAddr: {Balance: big.NewInt(params.Ether)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewChainSigner(config)
+	)
+	hash, _ := HashContent(AlgorithmSM3, testContent)
+	input, err := PackNotarize(hash, AlgorithmSM3, "signed by both parties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, receipts := core.GenerateChain(config, genesis, fstash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testAddr), vm.NotaryAddress, new(big.Int), 200000, big.NewInt(1), input), signer, testKey)
+			gen.AddTx(tx)
+		}
+	})
+	p, err := NewProof(config, blocks[0], receipts[0], hash)
+	if err != nil {
+		t.Fatalf("failed to create proof: %v", err)
+	}
+	return p, blocks
+}
+
+func TestProof(t *testing.T) {
+	p, blocks := makeProof(t, 4)
+	record, err := Verify(p)
+	if err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+	if record.Owner != testAddr || record.Algorithm != AlgorithmSM3 || record.Metadata != "signed by both parties" || record.BlockNumber != 1 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if err := VerifyContent(record, testContent); err != nil {
+		t.Fatalf("content mismatch: %v", err)
+	}
+	if err := VerifyContent(record, []byte("forged contract")); err == nil {
+		t.Fatal("forged content verified")
+	}
+
+	// Anchor the proof to the last block.
+	var headers []*types.Header
+	for _, block := range blocks[1:] {
+		headers = append(headers, block.Header())
+	}
+	if err := p.SetAnchor(headers); err != nil {
+		t.Fatal(err)
+	}
+	if p.Anchor.BlockHash != blocks[3].Hash() {
+		t.Fatalf("anchor mismatch: have %x, want %x", p.Anchor.BlockHash, blocks[3].Hash())
+	}
+	if _, err := Verify(p); err != nil {
+		t.Fatalf("failed to verify anchored proof: %v", err)
+	}
+
+	// Proofs are handed out as JSON documents.
+	enc, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(Proof)
+	if err := json.Unmarshal(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(dec); err != nil {
+		t.Fatalf("failed to verify decoded proof: %v", err)
+	}
+}
+
+func TestProofTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(p *Proof, blocks []*types.Block)
+	}{
+		{"metadata", func(p *Proof, _ []*types.Block) { p.Record.Metadata = "unsigned" }},
+		{"owner", func(p *Proof, _ []*types.Block) { p.Record.Owner[0]++ }},
+		{"timestamp", func(p *Proof, _ []*types.Block) { p.Record.Timestamp++ }},
+		{"receipt", func(p *Proof, _ []*types.Block) { p.Receipt[len(p.Receipt)-1]++ }},
+		{"transaction proof", func(p *Proof, _ []*types.Block) { p.TxProof = p.TxProof[1:] }},
+		{"hash function", func(p *Proof, _ []*types.Block) { p.HashFunction = params.HashFunctionSM3 }},
+		{"anchor", func(p *Proof, blocks []*types.Block) {
+			p.SetAnchor([]*types.Header{blocks[2].Header()})
+		}},
+	}
+	for _, tt := range tests {
+		p, blocks := makeProof(t, 3)
+		tt.tamper(p, blocks)
+		if _, err := Verify(p); err == nil {
+			t.Errorf("%s: tampered proof verified", tt.name)
+		}
+	}
+}
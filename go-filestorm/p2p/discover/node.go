@@ -25,6 +25,8 @@ import (
 
 	"github.com/filestorm/go-filestorm/common/math"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 	"github.com/filestorm/go-filestorm/p2p/enode"
 )
 
@@ -36,6 +38,8 @@ type node struct {
 	livenessChecks uint      // how often liveness was checked
 }
 
+// encPubkey is an uncompressed public key without the format byte. It is
+// either a secp256k1 key or, in networks of the sm2 identity scheme, an SM2 key.
 type encPubkey [64]byte
 
 func encodePubkey(key *ecdsa.PublicKey) encPubkey {
@@ -51,12 +55,24 @@ func decodePubkey(e encPubkey) (*ecdsa.PublicKey, error) {
 	p.X.SetBytes(e[:half])
 	p.Y.SetBytes(e[half:])
 	if !p.Curve.IsOnCurve(p.X, p.Y) {
+		if p.Curve = sm2.P256(); p.Curve.IsOnCurve(p.X, p.Y) {
+			return p, nil
+		}
 		return nil, errors.New("invalid secp256k1 curve point")
 	}
 	return p, nil
 }
 
+// isSM2 reports whether e is a point on the SM2 curve.
+func (e encPubkey) isSM2() bool {
+	half := len(e) / 2
+	return sm2.P256().IsOnCurve(new(big.Int).SetBytes(e[:half]), new(big.Int).SetBytes(e[half:]))
+}
+
 func (e encPubkey) id() enode.ID {
+	if e.isSM2() {
+		return enode.ID(sm3.Sum(e[:]))
+	}
 	return enode.ID(crypto.Keccak256Hash(e[:]))
 }
 
@@ -71,6 +87,24 @@ func recoverNodeKey(hash, sig []byte) (key encPubkey, err error) {
 	return key, nil
 }
 
+// recoverSM2NodeKey computes the SM2 public key used to sign the
+// given hash from the signature.
+func recoverSM2NodeKey(hash, sig []byte) (key encPubkey, err error) {
+	pubkey, err := sm2.RecoverPubkey(hash, sig)
+	if err != nil {
+		return key, err
+	}
+	return encodePubkey(pubkey), nil
+}
+
+func sm3Hash(data ...[]byte) []byte {
+	h := sm3.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
 func wrapNode(n *enode.Node) *node {
 	return &node{Node: *n}
 }
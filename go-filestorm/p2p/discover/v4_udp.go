@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/p2p/enode"
 	"github.com/filestorm/go-filestorm/p2p/enr"
@@ -186,10 +187,9 @@ func (t *UDPv4) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*node, error) {
 }
 
 func nodeToRPC(n *node) rpcNode {
-	var key ecdsa.PublicKey
 	var ekey encPubkey
-	if err := n.Load((*enode.Secp256k1)(&key)); err == nil {
-		ekey = encodePubkey(&key)
+	if key := n.Pubkey(); key != nil {
+		ekey = encodePubkey(key)
 	}
 	return rpcNode{ID: ekey, IP: n.IP(), UDP: uint16(n.UDP()), TCP: uint16(n.TCP())}
 }
@@ -318,11 +318,11 @@ func (t *UDPv4) Resolve(n *enode.Node) *enode.Node {
 		}
 	}
 	// Otherwise perform a network lookup.
-	var key enode.Secp256k1
-	if n.Load(&key) != nil {
-		return n // no secp256k1 key
+	key := n.Pubkey()
+	if key == nil {
+		return n // no secp256k1 or SM2 key
 	}
-	result := t.LookupPubkey((*ecdsa.PublicKey)(&key))
+	result := t.LookupPubkey(key)
 	for _, rn := range result {
 		if rn.ID() == n.ID() {
 			if rn, err := t.RequestENR(rn); err == nil {
@@ -422,7 +422,7 @@ func (t *UDPv4) newRandomLookup(ctx context.Context) *lookup {
 }
 
 func (t *UDPv4) newLookup(ctx context.Context, targetKey encPubkey) *lookup {
-	target := enode.ID(crypto.Keccak256Hash(targetKey[:]))
+	target := targetKey.id()
 	it := newLookup(ctx, t.tab, target, func(n *node) ([]*node, error) {
 		return t.findnode(n.ID(), n.addr(), targetKey)
 	})
@@ -675,7 +675,13 @@ func (t *UDPv4) encode(priv *ecdsa.PrivateKey, req packetV4) (packet, hash []byt
 		return nil, nil, err
 	}
 	packet = b.Bytes()
-	sig, err := crypto.Sign(crypto.Keccak256(packet[headSize:]), priv)
+	// Nodes with SM2 keys sign with SM2 and hash with SM3. The signature has
+	// the same size, so the packet layout doesn't change.
+	hashFn, sign := crypto.Keccak256, crypto.Sign
+	if sm2.IsSM2(&priv.PublicKey) {
+		hashFn, sign = sm3Hash, sm2.SignDigest
+	}
+	sig, err := sign(hashFn(packet[headSize:]), priv)
 	if err != nil {
 		t.log.Error(fmt.Sprintf("Can't sign %s packet", name), "err", err)
 		return nil, nil, err
@@ -684,7 +690,7 @@ func (t *UDPv4) encode(priv *ecdsa.PrivateKey, req packetV4) (packet, hash []byt
 	// add the hash to the front. Note: this doesn't protect the
 	// packet in any way. Our public key will be part of this hash in
 	// The future.
-	hash = crypto.Keccak256(packet[macSize:])
+	hash = hashFn(packet[macSize:])
 	copy(packet, hash)
 	return packet, hash, nil
 }
@@ -720,7 +726,7 @@ func (t *UDPv4) readLoop(unhandled chan<- ReadPacket) {
 }
 
 func (t *UDPv4) handlePacket(from *net.UDPAddr, buf []byte) error {
-	packet, fromKey, hash, err := decodeV4(buf)
+	packet, fromKey, hash, err := t.decode(buf)
 	if err != nil {
 		t.log.Debug("Bad discv4 packet", "addr", from, "err", err)
 		return err
@@ -736,16 +742,33 @@ func (t *UDPv4) handlePacket(from *net.UDPAddr, buf []byte) error {
 	return err
 }
 
+// decode decodes a packet signed with a key of the local node's curve.
+func (t *UDPv4) decode(buf []byte) (packetV4, encPubkey, []byte, error) {
+	if sm2.IsSM2(&t.priv.PublicKey) {
+		return decodeSM2(buf)
+	}
+	return decodeV4(buf)
+}
+
 func decodeV4(buf []byte) (packetV4, encPubkey, []byte, error) {
+	return decodePacket(buf, crypto.Keccak256, recoverNodeKey)
+}
+
+// decodeSM2 decodes a packet signed with an SM2 node key.
+func decodeSM2(buf []byte) (packetV4, encPubkey, []byte, error) {
+	return decodePacket(buf, sm3Hash, recoverSM2NodeKey)
+}
+
+func decodePacket(buf []byte, hashFn func(...[]byte) []byte, recoverKey func(hash, sig []byte) (encPubkey, error)) (packetV4, encPubkey, []byte, error) {
 	if len(buf) < headSize+1 {
 		return nil, encPubkey{}, nil, errPacketTooSmall
 	}
 	hash, sig, sigdata := buf[:macSize], buf[macSize:headSize], buf[headSize:]
-	shouldhash := crypto.Keccak256(buf[macSize:])
+	shouldhash := hashFn(buf[macSize:])
 	if !bytes.Equal(hash, shouldhash) {
 		return nil, encPubkey{}, nil, errBadHash
 	}
-	fromKey, err := recoverNodeKey(crypto.Keccak256(buf[headSize:]), sig)
+	fromKey, err := recoverKey(hashFn(buf[headSize:]), sig)
 	if err != nil {
 		return nil, fromKey, hash, err
 	}
@@ -888,7 +911,7 @@ func (req *findnodeV4) preverify(t *UDPv4, from *net.UDPAddr, fromID enode.ID, f
 
 func (req *findnodeV4) handle(t *UDPv4, from *net.UDPAddr, fromID enode.ID, mac []byte) {
 	// Determine closest nodes.
-	target := enode.ID(crypto.Keccak256Hash(req.Target[:]))
+	target := req.Target.id()
 	t.tab.mutex.Lock()
 	closest := t.tab.closest(target, bucketSize, true).entries
 	t.tab.mutex.Unlock()
@@ -34,6 +34,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/internal/testlog"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/p2p/enode"
@@ -66,11 +67,15 @@ type udpTest struct {
 }
 
 func newUDPTest(t *testing.T) *udpTest {
+	return newUDPTestWithKeys(t, newkey(), newkey())
+}
+
+func newUDPTestWithKeys(t *testing.T, localkey, remotekey *ecdsa.PrivateKey) *udpTest {
 	test := &udpTest{
 		t:          t,
 		pipe:       newpipe(),
-		localkey:   newkey(),
-		remotekey:  newkey(),
+		localkey:   localkey,
+		remotekey:  remotekey,
 		remoteaddr: &net.UDPAddr{IP: net.IP{10, 0, 1, 99}, Port: 30303},
 	}
 
@@ -121,7 +126,7 @@ func (test *udpTest) waitPacketOut(validate interface{}) (closed bool) {
 	if !ok {
 		return true
 	}
-	p, _, hash, err := decodeV4(dgram.data)
+	p, _, hash, err := test.udp.decode(dgram.data)
 	if err != nil {
 		test.t.Errorf("sent packet decode error: %v", err)
 		return false
@@ -503,6 +508,50 @@ func TestUDPv4_EIP868(t *testing.T) {
 	})
 }
 
+// This test checks endpoint proofs and ENR requests between nodes with SM2 keys.
+func TestUDPv4_SM2(t *testing.T) {
+	localkey, _ := sm2.GenerateKey(crand.Reader)
+	remotekey, _ := sm2.GenerateKey(crand.Reader)
+	test := newUDPTestWithKeys(t, localkey, remotekey)
+	added := make(chan *node, 1)
+	test.table.nodeAddedHook = func(n *node) { added <- n }
+	defer test.close()
+
+	// Packets signed with secp256k1 keys are rejected.
+	enc, _, _ := test.udp.encode(newkey(), &pingV4{Expiration: futureExp})
+	if err := test.udp.handlePacket(test.remoteaddr, enc); err != errBadHash {
+		t.Errorf("wrong error for secp256k1 packet: %v", err)
+	}
+
+	go test.packetIn(nil, &pingV4{From: testRemote, To: testLocalAnnounced, Version: 4, Expiration: futureExp})
+	test.waitPacketOut(func(p *pongV4, to *net.UDPAddr, hash []byte) {})
+	test.waitPacketOut(func(p *pingV4, to *net.UDPAddr, hash []byte) {
+		test.packetIn(nil, &pongV4{ReplyTok: hash, Expiration: futureExp})
+	})
+	select {
+	case n := <-added:
+		if rid := enode.PubkeyToIDSM2(&remotekey.PublicKey); n.ID() != rid {
+			t.Errorf("node has wrong ID: got %v, want %v", n.ID(), rid)
+		}
+		if !sm2.IsSM2(n.Pubkey()) {
+			t.Errorf("node has no SM2 key")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("node was not added within 2 seconds")
+	}
+
+	test.packetIn(nil, &enrRequestV4{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponseV4, addr *net.UDPAddr, hash []byte) {
+		n, err := enode.New(enode.ValidSchemes, &p.Record)
+		if err != nil {
+			t.Fatalf("invalid record: %v", err)
+		}
+		if p.Record.IdentityScheme() != "sm2" || n.ID() != test.udp.Self().ID() {
+			t.Fatalf("wrong node in enrResponse: %v", n)
+		}
+	})
+}
+
 // EIP-8 test vectors.
 var testPackets = []struct {
 	input      string
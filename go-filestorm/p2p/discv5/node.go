@@ -32,6 +32,8 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 )
 
 // Node represents a host on the network.
@@ -304,7 +306,9 @@ func (n NodeID) Pubkey() (*ecdsa.PublicKey, error) {
 	p.X.SetBytes(n[:half])
 	p.Y.SetBytes(n[half:])
 	if !p.Curve.IsOnCurve(p.X, p.Y) {
-		return nil, errors.New("id is invalid secp256k1 curve point")
+		if p.Curve = sm2.P256(); !p.Curve.IsOnCurve(p.X, p.Y) {
+			return nil, errors.New("id is invalid secp256k1 or sm2 curve point")
+		}
 	}
 	return p, nil
 }
@@ -325,6 +329,24 @@ func recoverNodeID(hash, sig []byte) (id NodeID, err error) {
 	return id, nil
 }
 
+// recoverSM2NodeID computes the SM2 public key used to sign the
+// given hash from the signature.
+func recoverSM2NodeID(hash, sig []byte) (id NodeID, err error) {
+	pubkey, err := sm2.RecoverPubkey(hash, sig)
+	if err != nil {
+		return id, err
+	}
+	return PubkeyID(pubkey), nil
+}
+
+func sm3Hash(data ...[]byte) []byte {
+	h := sm3.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
 // distcmp compares the distances a->target and b->target.
 // Returns -1 if a is closer to target, 1 if b is closer to target
 // and 0 if they are equal.
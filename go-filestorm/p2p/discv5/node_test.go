@@ -17,6 +17,8 @@
 package discv5
 
 import (
+	"bytes"
+	crand "crypto/rand"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 )
 
 func ExampleNewNode() {
@@ -218,6 +221,32 @@ func TestNodeID_recover(t *testing.T) {
 	}
 }
 
+func TestNodeID_recoverSM2(t *testing.T) {
+	prv, _ := sm2.GenerateKey(crand.Reader)
+	packet, hash, err := encodePacket(prv, byte(pingPacket), &ping{Version: Version})
+	if err != nil {
+		t.Fatalf("encoding error: %v", err)
+	}
+	var pkt ingressPacket
+	if err := decodePacket(packet, &pkt); err != nil {
+		t.Fatalf("decoding error: %v", err)
+	}
+	if pub := PubkeyID(&prv.PublicKey); pkt.remoteID != pub {
+		t.Errorf("recovered wrong pubkey:\ngot:  %v\nwant: %v", pkt.remoteID, pub)
+	}
+	if !bytes.Equal(pkt.hash, hash) {
+		t.Errorf("packet hash mismatch: got %x, want %x", pkt.hash, hash)
+	}
+
+	pub, err := pkt.remoteID.Pubkey()
+	if err != nil {
+		t.Errorf("Pubkey error: %v", err)
+	}
+	if !reflect.DeepEqual(pub, &prv.PublicKey) {
+		t.Errorf("Pubkey mismatch:\n  got:  %#v\n  want: %#v", pub, &prv.PublicKey)
+	}
+}
+
 func TestNodeID_pubkeyBad(t *testing.T) {
 	ecdsa, err := NodeID{}.Pubkey()
 	if err == nil {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/p2p/netutil"
 	"github.com/filestorm/go-filestorm/rlp"
@@ -136,6 +137,7 @@ type (
 var (
 	versionPrefix     = []byte("temporary discovery v5")
 	versionPrefixSize = len(versionPrefix)
+	sm2VersionPrefix  = []byte("temporary discv5 (sm2)") // same size as versionPrefix
 	sigSize           = 520 / 8
 	headSize          = versionPrefixSize + sigSize // space of packet frame data
 )
@@ -340,17 +342,29 @@ func encodePacket(priv *ecdsa.PrivateKey, ptype byte, req interface{}) (p, hash
 		return nil, nil, err
 	}
 	packet := b.Bytes()
-	sig, err := crypto.Sign(crypto.Keccak256(packet[headSize:]), priv)
+	prefix, hashFn, sign := versionPrefix, crypto.Keccak256, crypto.Sign
+	if sm2.IsSM2(&priv.PublicKey) {
+		prefix, hashFn, sign = sm2VersionPrefix, sm3Hash, sm2.SignDigest
+	}
+	sig, err := sign(hashFn(packet[headSize:]), priv)
 	if err != nil {
 		log.Error(fmt.Sprint("could not sign packet:", err))
 		return nil, nil, err
 	}
-	copy(packet, versionPrefix)
+	copy(packet, prefix)
 	copy(packet[versionPrefixSize:], sig)
-	hash = crypto.Keccak256(packet[versionPrefixSize:])
+	hash = hashFn(packet[versionPrefixSize:])
 	return packet, hash, nil
 }
 
+// packetPrefix returns the version prefix of packets signed by priv.
+func packetPrefix(priv *ecdsa.PrivateKey) []byte {
+	if sm2.IsSM2(&priv.PublicKey) {
+		return sm2VersionPrefix
+	}
+	return versionPrefix
+}
+
 // readLoop runs in its own goroutine. it injects ingress UDP packets
 // into the network loop.
 func (t *udp) readLoop() {
@@ -377,6 +391,11 @@ func (t *udp) readLoop() {
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
 	pkt := ingressPacket{remoteAddr: from}
+	if len(buf) >= versionPrefixSize && !bytes.Equal(buf[:versionPrefixSize], packetPrefix(t.priv)) {
+		// Nodes of the other identity scheme can't be dialed.
+		log.Debug(fmt.Sprintf("Bad packet from %v: %v", from, errBadPrefix))
+		return errBadPrefix
+	}
 	if err := decodePacket(buf, &pkt); err != nil {
 		log.Debug(fmt.Sprintf("Bad packet from %v: %v", from, err))
 		//fmt.Println("bad packet", err)
@@ -393,15 +412,19 @@ func decodePacket(buffer []byte, pkt *ingressPacket) error {
 	buf := make([]byte, len(buffer))
 	copy(buf, buffer)
 	prefix, sig, sigdata := buf[:versionPrefixSize], buf[versionPrefixSize:headSize], buf[headSize:]
-	if !bytes.Equal(prefix, versionPrefix) {
+	hashFn, recoverID := crypto.Keccak256, recoverNodeID
+	switch {
+	case bytes.Equal(prefix, sm2VersionPrefix):
+		hashFn, recoverID = sm3Hash, recoverSM2NodeID
+	case !bytes.Equal(prefix, versionPrefix):
 		return errBadPrefix
 	}
-	fromID, err := recoverNodeID(crypto.Keccak256(buf[headSize:]), sig)
+	fromID, err := recoverID(hashFn(buf[headSize:]), sig)
 	if err != nil {
 		return err
 	}
 	pkt.rawData = buf
-	pkt.hash = crypto.Keccak256(buf[versionPrefixSize:])
+	pkt.hash = hashFn(buf[versionPrefixSize:])
 	pkt.remoteID = fromID
 	switch pkt.ev = nodeEvent(sigdata[0]); pkt.ev {
 	case pingPacket:
@@ -23,6 +23,8 @@ import (
 
 	"github.com/filestorm/go-filestorm/common/math"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 	"github.com/filestorm/go-filestorm/p2p/enr"
 	"github.com/filestorm/go-filestorm/rlp"
 	"golang.org/x/crypto/sha3"
@@ -30,11 +32,13 @@ import (
 
 // List of known secure identity schemes.
 var ValidSchemes = enr.SchemeMap{
-	"v4": V4ID{},
+	"v4":  V4ID{},
+	"sm2": SM2ID{},
 }
 
 var ValidSchemesForTesting = enr.SchemeMap{
 	"v4":   V4ID{},
+	"sm2":  SM2ID{},
 	"null": NullID{},
 }
 
@@ -136,6 +140,119 @@ func signV4Compat(r *enr.Record, pubkey *ecdsa.PublicKey) {
 	}
 }
 
+// SM2ID is the "sm2" identity scheme. It is the "v4" scheme with the SM2
+// curve and SM3 in place of secp256k1 and Keccak256: the record is signed with
+// the SM2 key in the "sm2p256v1" entry and the node address is the SM3 hash of
+// the uncompressed public key.
+type SM2ID struct{}
+
+// SignSM2 signs a record using the sm2 scheme.
+func SignSM2(r *enr.Record, privkey *ecdsa.PrivateKey) error {
+	if !sm2.IsSM2(&privkey.PublicKey) {
+		return fmt.Errorf("invalid private key, need sm2p256v1")
+	}
+	// Copy r to avoid modifying it if signing fails.
+	cpy := *r
+	cpy.Set(enr.ID("sm2"))
+	cpy.Set(SM2Pubkey(privkey.PublicKey))
+
+	h := sm3.New()
+	rlp.Encode(h, cpy.AppendElements(nil))
+	sig, err := sm2.SignDigest(h.Sum(nil), privkey)
+	if err != nil {
+		return err
+	}
+	sig = sig[:len(sig)-1] // remove v
+	if err = cpy.SetSig(SM2ID{}, sig); err == nil {
+		*r = cpy
+	}
+	return err
+}
+
+func (SM2ID) Verify(r *enr.Record, sig []byte) error {
+	var entry sm2raw
+	if err := r.Load(&entry); err != nil {
+		return err
+	}
+	pubkey, err := sm2.DecompressPubkey(entry)
+	if err != nil {
+		return fmt.Errorf("invalid public key")
+	}
+
+	h := sm3.New()
+	rlp.Encode(h, r.AppendElements(nil))
+	if len(sig) != 64 || !sm2.VerifyDigest(pubkey, h.Sum(nil), sig) {
+		return enr.ErrInvalidSig
+	}
+	return nil
+}
+
+func (SM2ID) NodeAddr(r *enr.Record) []byte {
+	var pubkey SM2Pubkey
+	err := r.Load(&pubkey)
+	if err != nil {
+		return nil
+	}
+	id := PubkeyToIDSM2((*ecdsa.PublicKey)(&pubkey))
+	return id[:]
+}
+
+// SM2Pubkey is the "sm2p256v1" key, which holds an SM2 public key.
+type SM2Pubkey ecdsa.PublicKey
+
+func (v SM2Pubkey) ENRKey() string { return "sm2p256v1" }
+
+// EncodeRLP implements rlp.Encoder.
+func (v SM2Pubkey) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, sm2.CompressPubkey((*ecdsa.PublicKey)(&v)))
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (v *SM2Pubkey) DecodeRLP(s *rlp.Stream) error {
+	buf, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	pk, err := sm2.DecompressPubkey(buf)
+	if err != nil {
+		return err
+	}
+	*v = (SM2Pubkey)(*pk)
+	return nil
+}
+
+// sm2raw is an unparsed SM2 public key entry.
+type sm2raw []byte
+
+func (sm2raw) ENRKey() string { return "sm2p256v1" }
+
+// sm2CompatID is the unsigned counterpart of the "sm2" scheme, used for nodes
+// created from discovery information like v4CompatID.
+type sm2CompatID struct {
+	SM2ID
+}
+
+func (sm2CompatID) Verify(r *enr.Record, sig []byte) error {
+	var pubkey SM2Pubkey
+	return r.Load(&pubkey)
+}
+
+func signSM2Compat(r *enr.Record, pubkey *ecdsa.PublicKey) {
+	r.Set((*SM2Pubkey)(pubkey))
+	if err := r.SetSig(sm2CompatID{}, []byte{}); err != nil {
+		panic(err)
+	}
+}
+
+// SignRecord signs a record with the identity scheme matching the key: "sm2"
+// for SM2 keys and "v4" otherwise.
+func SignRecord(r *enr.Record, privkey *ecdsa.PrivateKey) error {
+	if sm2.IsSM2(&privkey.PublicKey) {
+		return SignSM2(r, privkey)
+	}
+	return SignV4(r, privkey)
+}
+
 // NullID is the "null" ENR identity scheme. This scheme stores the node
 // ID in the record without any signature.
 type NullID struct{}
@@ -19,11 +19,14 @@ package enode
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/rand"
 	"encoding/hex"
 	"math/big"
+	"net"
 	"testing"
 
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/p2p/enr"
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/stretchr/testify/assert"
@@ -72,3 +75,48 @@ func TestGetSetSecp256k1(t *testing.T) {
 	require.NoError(t, r.Load(&pk))
 	assert.EqualValues(t, pubkey, &pk)
 }
+
+// TestSignSM2 tests signing, verification and the node address of sm2 records.
+func TestSignSM2(t *testing.T) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	if err := SignSM2(&enr.Record{}, privkey); err == nil {
+		t.Fatal("expected error from SignSM2 with a secp256k1 key")
+	}
+
+	var r enr.Record
+	r.Set(enr.TCP(30303))
+	require.NoError(t, SignRecord(&r, key))
+	assert.Equal(t, "sm2", r.IdentityScheme())
+
+	n, err := New(ValidSchemes, &r)
+	require.NoError(t, err)
+	assert.Equal(t, PubkeyToIDSM2(&key.PublicKey), n.ID())
+	assert.Equal(t, PubkeyToID(&key.PublicKey), n.ID())
+	assert.Equal(t, 30303, n.TCP())
+	pk := n.Pubkey()
+	if pk == nil || pk.X.Cmp(key.X) != 0 || pk.Y.Cmp(key.Y) != 0 || !sm2.IsSM2(pk) {
+		t.Fatalf("wrong public key %v", pk)
+	}
+
+	// Changing the record invalidates the signature.
+	r.Set(enr.TCP(30304))
+	if _, err := New(ValidSchemes, &r); err != enr.ErrInvalidSig {
+		t.Fatalf("wrong error for modified record: %v", err)
+	}
+}
+
+// TestNewV4SM2 checks that nodes built from SM2 discovery information round-trip
+// through enode URLs.
+func TestNewV4SM2(t *testing.T) {
+	key, _ := sm2.GenerateKey(rand.Reader)
+	n := NewV4(&key.PublicKey, net.IP{10, 3, 58, 6}, 30303, 30301)
+	assert.Equal(t, PubkeyToIDSM2(&key.PublicKey), n.ID())
+	require.NoError(t, n.ValidateComplete())
+
+	parsed, err := ParseV4(n.String())
+	require.NoError(t, err)
+	assert.Equal(t, n.ID(), parsed.ID())
+	assert.True(t, sm2.IsSM2(parsed.Pubkey()))
+	assert.Equal(t, 30301, parsed.UDP())
+}
@@ -64,7 +64,7 @@ type lnEndpoint struct {
 // NewLocalNode creates a local node.
 func NewLocalNode(db *DB, key *ecdsa.PrivateKey) *LocalNode {
 	ln := &LocalNode{
-		id:      PubkeyToIDV4(&key.PublicKey),
+		id:      PubkeyToID(&key.PublicKey),
 		db:      db,
 		key:     key,
 		entries: make(map[string]enr.Entry),
@@ -273,7 +273,7 @@ func (ln *LocalNode) sign() {
 	}
 	ln.bumpSeq()
 	r.SetSeq(ln.seq)
-	if err := SignV4(&r, ln.key); err != nil {
+	if err := SignRecord(&r, ln.key); err != nil {
 		panic(fmt.Errorf("enode: can't sign record: %v", err))
 	}
 	n, err := New(ValidSchemes, &r)
@@ -129,10 +129,11 @@ func (n *Node) TCP() int {
 	return int(port)
 }
 
-// Pubkey returns the secp256k1 public key of the node, if present.
+// Pubkey returns the secp256k1 public key of the node, or the SM2 public key
+// of sm2 nodes, if present.
 func (n *Node) Pubkey() *ecdsa.PublicKey {
 	var key ecdsa.PublicKey
-	if n.Load((*Secp256k1)(&key)) != nil {
+	if n.Load((*Secp256k1)(&key)) != nil && n.Load((*SM2Pubkey)(&key)) != nil {
 		return nil
 	}
 	return &key
@@ -160,7 +161,14 @@ func (n *Node) ValidateComplete() error {
 	}
 	// Validate the node key (on curve, etc.).
 	var key Secp256k1
-	return n.Load(&key)
+	if err := n.Load(&key); err != nil {
+		var sm2key SM2Pubkey
+		if n.Load(&sm2key) == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
 }
 
 // String returns the text representation of the record.
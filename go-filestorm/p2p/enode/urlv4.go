@@ -28,6 +28,8 @@ import (
 
 	"github.com/filestorm/go-filestorm/common/math"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
 	"github.com/filestorm/go-filestorm/p2p/enr"
 )
 
@@ -81,7 +83,8 @@ func ParseV4(rawurl string) (*Node, error) {
 }
 
 // NewV4 creates a node from discovery v4 node information. The record
-// contained in the node has a zero-length signature.
+// contained in the node has a zero-length signature. SM2 public keys create
+// nodes of the sm2 identity scheme.
 func NewV4(pubkey *ecdsa.PublicKey, ip net.IP, tcp, udp int) *Node {
 	var r enr.Record
 	if len(ip) > 0 {
@@ -93,8 +96,17 @@ func NewV4(pubkey *ecdsa.PublicKey, ip net.IP, tcp, udp int) *Node {
 	if tcp != 0 {
 		r.Set(enr.TCP(tcp))
 	}
-	signV4Compat(&r, pubkey)
-	n, err := New(v4CompatID{}, &r)
+	var (
+		n   *Node
+		err error
+	)
+	if sm2.IsSM2(pubkey) {
+		signSM2Compat(&r, pubkey)
+		n, err = New(sm2CompatID{}, &r)
+	} else {
+		signV4Compat(&r, pubkey)
+		n, err = New(v4CompatID{}, &r)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -103,8 +115,11 @@ func NewV4(pubkey *ecdsa.PublicKey, ip net.IP, tcp, udp int) *Node {
 
 // isNewV4 returns true for nodes created by NewV4.
 func isNewV4(n *Node) bool {
-	var k s256raw
-	return n.r.IdentityScheme() == "" && n.r.Load(&k) == nil && len(n.r.Signature()) == 0
+	var (
+		k  s256raw
+		sk sm2raw
+	)
+	return n.r.IdentityScheme() == "" && (n.r.Load(&k) == nil || n.r.Load(&sk) == nil) && len(n.r.Signature()) == 0
 }
 
 func parseComplete(rawurl string) (*Node, error) {
@@ -154,7 +169,8 @@ func parseComplete(rawurl string) (*Node, error) {
 	return NewV4(id, ip, int(tcpPort), int(udpPort)), nil
 }
 
-// parsePubkey parses a hex-encoded secp256k1 public key.
+// parsePubkey parses a hex-encoded secp256k1 or SM2 public key. The URL has
+// no curve designator, the curve is the one the point lies on.
 func parsePubkey(in string) (*ecdsa.PublicKey, error) {
 	b, err := hex.DecodeString(in)
 	if err != nil {
@@ -163,7 +179,13 @@ func parsePubkey(in string) (*ecdsa.PublicKey, error) {
 		return nil, fmt.Errorf("wrong length, want %d hex chars", 128)
 	}
 	b = append([]byte{0x4}, b...)
-	return crypto.UnmarshalPubkey(b)
+	key, err := crypto.UnmarshalPubkey(b)
+	if err != nil {
+		if key, sm2err := sm2.UnmarshalPubkey(b); sm2err == nil {
+			return key, nil
+		}
+	}
+	return key, err
 }
 
 func (n *Node) URLv4() string {
@@ -173,9 +195,11 @@ func (n *Node) URLv4() string {
 		key    ecdsa.PublicKey
 	)
 	n.Load(&scheme)
-	n.Load((*Secp256k1)(&key))
+	if n.Load((*Secp256k1)(&key)) != nil {
+		n.Load((*SM2Pubkey)(&key))
+	}
 	switch {
-	case scheme == "v4" || key != ecdsa.PublicKey{}:
+	case scheme == "v4" || scheme == "sm2" || key != ecdsa.PublicKey{}:
 		nodeid = fmt.Sprintf("%x", crypto.FromECDSAPub(&key)[1:])
 	default:
 		nodeid = fmt.Sprintf("%s.%x", scheme, n.id[:])
@@ -201,3 +225,20 @@ func PubkeyToIDV4(key *ecdsa.PublicKey) ID {
 	math.ReadBits(key.Y, e[len(e)/2:])
 	return ID(crypto.Keccak256Hash(e))
 }
+
+// PubkeyToIDSM2 derives the sm2 node address from the given public key.
+func PubkeyToIDSM2(key *ecdsa.PublicKey) ID {
+	e := make([]byte, 64)
+	math.ReadBits(key.X, e[:len(e)/2])
+	math.ReadBits(key.Y, e[len(e)/2:])
+	return ID(sm3.Sum(e))
+}
+
+// PubkeyToID derives the node address of the identity scheme matching the key:
+// sm2 for SM2 keys and v4 otherwise.
+func PubkeyToID(key *ecdsa.PublicKey) ID {
+	if sm2.IsSM2(key) {
+		return PubkeyToIDSM2(key)
+	}
+	return PubkeyToIDV4(key)
+}
@@ -38,6 +38,8 @@ import (
 	"github.com/filestorm/go-filestorm/common/bitutil"
 	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/crypto/ecies"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm4"
 	"github.com/filestorm/go-filestorm/metrics"
 	"github.com/filestorm/go-filestorm/rlp"
 	"github.com/golang/snappy"
@@ -174,15 +176,21 @@ func readProtocolHandshake(rw MsgReader) (*protoHandshake, error) {
 // doEncHandshake runs the protocol handshake using authenticated
 // messages. the protocol handshake is the first authenticated message
 // and also verifies whether the encryption handshake 'worked' and the
-// remote side actually provided the right public key.
+// remote side actually provided the right public key. Nodes with SM2
+// keys run the sm2 handshake instead of the v4 one.
 func (t *rlpx) doEncHandshake(prv *ecdsa.PrivateKey, dial *ecdsa.PublicKey) (*ecdsa.PublicKey, error) {
 	var (
 		sec secrets
 		err error
 	)
-	if dial == nil {
+	switch useSM2 := sm2.IsSM2(&prv.PublicKey); {
+	case useSM2 && dial == nil:
+		sec, err = receiverSM2Handshake(t.fd, prv)
+	case useSM2:
+		sec, err = initiatorSM2Handshake(t.fd, prv, dial)
+	case dial == nil:
 		sec, err = receiverEncHandshake(t.fd, prv)
-	} else {
+	default:
 		sec, err = initiatorEncHandshake(t.fd, prv, dial)
 	}
 	if err != nil {
@@ -210,6 +218,7 @@ type secrets struct {
 	AES, MAC              []byte
 	EgressMAC, IngressMAC hash.Hash
 	Token                 []byte
+	SM4                   bool // frames use SM4 keyed with the first 16 bytes of AES and MAC
 }
 
 // RLPx v4 handshake auth (defined in EIP-8).
@@ -558,11 +567,15 @@ type rlpxFrameRW struct {
 }
 
 func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
-	macc, err := aes.NewCipher(s.MAC)
+	newCipher, macKey, encKey := aes.NewCipher, s.MAC, s.AES
+	if s.SM4 {
+		newCipher, macKey, encKey = sm4.NewCipher, s.MAC[:sm4.BlockSize], s.AES[:sm4.BlockSize]
+	}
+	macc, err := newCipher(macKey)
 	if err != nil {
 		panic("invalid MAC secret: " + err.Error())
 	}
-	encc, err := aes.NewCipher(s.AES)
+	encc, err := newCipher(encKey)
 	if err != nil {
 		panic("invalid AES secret: " + err.Error())
 	}
@@ -0,0 +1,271 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+
+	"github.com/filestorm/go-filestorm/crypto/ecies"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/crypto/sm3"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+// The sm2 handshake is the encryption handshake of networks with SM2 node keys.
+// The messages are framed like EIP-8 messages, a two byte size prefix followed
+// by the SM2 encrypted RLP of
+//
+//    auth: [initiator-pubk, initiator-ephemeral-pubk, nonce, version]
+//    ack:  [recipient-ephemeral-pubk, nonce, version]
+//
+// Both sides then run the SM2 key exchange with their static and ephemeral
+// keys. Only the holders of the static keys can derive the shared key, so the
+// first valid frame MAC authenticates the remote side. The frame secrets are
+// derived like in the v4 handshake with SM3 instead of Keccak256, and frames
+// are encrypted with SM4 instead of AES.
+
+var errNotSM2Key = errors.New("remote node key is not an SM2 key")
+
+// sm2Handshake contains the state of the sm2 encryption handshake.
+type sm2Handshake struct {
+	initiator            bool
+	remote               *ecdsa.PublicKey
+	initNonce, respNonce []byte
+	randomPrivKey        *ecdsa.PrivateKey
+	remoteRandomPub      *ecdsa.PublicKey
+}
+
+// sm2 handshake auth.
+type authMsgSM2 struct {
+	InitiatorPubkey [pubLen]byte
+	RandomPubkey    [pubLen]byte
+	Nonce           [shaLen]byte
+	Version         uint
+
+	// Ignore additional fields (forward-compatibility)
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// secrets is called after the handshake is completed.
+// It extracts the connection secrets from the handshake values.
+func (h *sm2Handshake) secrets(prv *ecdsa.PrivateKey, auth, authResp []byte) (secrets, error) {
+	shared, err := sm2.KeyExchange(shaLen, h.initiator, sm2.DefaultUID, sm2.DefaultUID, prv, h.randomPrivKey, h.remote, h.remoteRandomPub)
+	if err != nil {
+		return secrets{}, err
+	}
+
+	// derive base secrets from the key exchange
+	sharedSecret := sm3Hash(shared, sm3Hash(h.respNonce, h.initNonce))
+	sm4Secret := sm3Hash(shared, sharedSecret)
+	s := secrets{
+		Remote: ecies.ImportECDSAPublic(h.remote),
+		AES:    sm4Secret,
+		MAC:    sm3Hash(shared, sm4Secret),
+		SM4:    true,
+	}
+
+	// setup sm3 instances for the MACs
+	mac1 := sm3.New()
+	mac1.Write(xor(s.MAC, h.respNonce))
+	mac1.Write(auth)
+	mac2 := sm3.New()
+	mac2.Write(xor(s.MAC, h.initNonce))
+	mac2.Write(authResp)
+	if h.initiator {
+		s.EgressMAC, s.IngressMAC = mac1, mac2
+	} else {
+		s.EgressMAC, s.IngressMAC = mac2, mac1
+	}
+	return s, nil
+}
+
+// initiatorSM2Handshake negotiates a session token on conn with the sm2
+// handshake. it should be called on the dialing side of the connection.
+func initiatorSM2Handshake(conn io.ReadWriter, prv *ecdsa.PrivateKey, remote *ecdsa.PublicKey) (s secrets, err error) {
+	if !sm2.IsSM2(remote) {
+		return s, errNotSM2Key
+	}
+	h := &sm2Handshake{initiator: true, remote: remote}
+	authMsg, err := h.makeAuthMsg(prv)
+	if err != nil {
+		return s, err
+	}
+	authPacket, err := sealSM2(authMsg, remote)
+	if err != nil {
+		return s, err
+	}
+	if _, err = conn.Write(authPacket); err != nil {
+		return s, err
+	}
+
+	authRespMsg := new(authRespV4)
+	authRespPacket, err := readSM2HandshakeMsg(authRespMsg, prv, conn)
+	if err != nil {
+		return s, err
+	}
+	h.respNonce = authRespMsg.Nonce[:]
+	if h.remoteRandomPub, err = importSM2PublicKey(authRespMsg.RandomPubkey[:]); err != nil {
+		return s, err
+	}
+	return h.secrets(prv, authPacket, authRespPacket)
+}
+
+// makeAuthMsg creates the initiator handshake message.
+func (h *sm2Handshake) makeAuthMsg(prv *ecdsa.PrivateKey) (*authMsgSM2, error) {
+	// Generate random initiator nonce.
+	h.initNonce = make([]byte, shaLen)
+	_, err := rand.Read(h.initNonce)
+	if err != nil {
+		return nil, err
+	}
+	// Generate random keypair for the key exchange.
+	h.randomPrivKey, err = sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(authMsgSM2)
+	copy(msg.InitiatorPubkey[:], exportSM2Pubkey(&prv.PublicKey))
+	copy(msg.RandomPubkey[:], exportSM2Pubkey(&h.randomPrivKey.PublicKey))
+	copy(msg.Nonce[:], h.initNonce)
+	msg.Version = 4
+	return msg, nil
+}
+
+// receiverSM2Handshake negotiates a session token on conn with the sm2
+// handshake. it should be called on the listening side of the connection.
+func receiverSM2Handshake(conn io.ReadWriter, prv *ecdsa.PrivateKey) (s secrets, err error) {
+	authMsg := new(authMsgSM2)
+	authPacket, err := readSM2HandshakeMsg(authMsg, prv, conn)
+	if err != nil {
+		return s, err
+	}
+	h := new(sm2Handshake)
+	if err := h.handleAuthMsg(authMsg); err != nil {
+		return s, err
+	}
+
+	authRespMsg, err := h.makeAuthResp()
+	if err != nil {
+		return s, err
+	}
+	authRespPacket, err := sealSM2(authRespMsg, h.remote)
+	if err != nil {
+		return s, err
+	}
+	if _, err = conn.Write(authRespPacket); err != nil {
+		return s, err
+	}
+	return h.secrets(prv, authPacket, authRespPacket)
+}
+
+func (h *sm2Handshake) handleAuthMsg(msg *authMsgSM2) (err error) {
+	// Import the remote identity and ephemeral key.
+	if h.remote, err = importSM2PublicKey(msg.InitiatorPubkey[:]); err != nil {
+		return err
+	}
+	if h.remoteRandomPub, err = importSM2PublicKey(msg.RandomPubkey[:]); err != nil {
+		return err
+	}
+	h.initNonce = msg.Nonce[:]
+
+	// Generate random keypair for the key exchange.
+	// If a private key is already set, use it instead of generating one (for testing).
+	if h.randomPrivKey == nil {
+		h.randomPrivKey, err = sm2.GenerateKey(rand.Reader)
+	}
+	return err
+}
+
+func (h *sm2Handshake) makeAuthResp() (msg *authRespV4, err error) {
+	// Generate random nonce.
+	h.respNonce = make([]byte, shaLen)
+	if _, err = rand.Read(h.respNonce); err != nil {
+		return nil, err
+	}
+
+	msg = new(authRespV4)
+	copy(msg.Nonce[:], h.respNonce)
+	copy(msg.RandomPubkey[:], exportSM2Pubkey(&h.randomPrivKey.PublicKey))
+	msg.Version = 4
+	return msg, nil
+}
+
+// sealSM2 encodes msg, pads it like sealEIP8 does and encrypts it to remote.
+func sealSM2(msg interface{}, remote *ecdsa.PublicKey) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := rlp.Encode(buf, msg); err != nil {
+		return nil, err
+	}
+	buf.Write(padSpace[:mrand.Intn(len(padSpace)-100)+100])
+	prefix := make([]byte, 2)
+	binary.BigEndian.PutUint16(prefix, uint16(buf.Len()+sm2.Overhead))
+
+	enc, err := sm2.Encrypt(rand.Reader, remote, buf.Bytes())
+	return append(prefix, enc...), err
+}
+
+func readSM2HandshakeMsg(msg interface{}, prv *ecdsa.PrivateKey, r io.Reader) ([]byte, error) {
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return buf, err
+	}
+	size := binary.BigEndian.Uint16(buf)
+	if size < sm2.Overhead {
+		return buf, fmt.Errorf("size underflow, need at least %d bytes", sm2.Overhead)
+	}
+	buf = append(buf, make([]byte, size)...)
+	if _, err := io.ReadFull(r, buf[2:]); err != nil {
+		return buf, err
+	}
+	dec, err := sm2.Decrypt(prv, buf[2:])
+	if err != nil {
+		return buf, err
+	}
+	// Can't use rlp.DecodeBytes here because it rejects
+	// trailing data (forward-compatibility).
+	s := rlp.NewStream(bytes.NewReader(dec), 0)
+	return buf, s.Decode(msg)
+}
+
+// importSM2PublicKey unmarshals 512 bit SM2 public keys.
+func importSM2PublicKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	if len(pubKey) != pubLen {
+		return nil, fmt.Errorf("invalid public key length %v (expect %d)", len(pubKey), pubLen)
+	}
+	return sm2.UnmarshalPubkey(append([]byte{0x04}, pubKey...))
+}
+
+func exportSM2Pubkey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.Marshal(pub.Curve, pub.X, pub.Y)[1:]
+}
+
+func sm3Hash(data ...[]byte) []byte {
+	h := sm3.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
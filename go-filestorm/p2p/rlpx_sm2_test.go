@@ -0,0 +1,137 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"crypto/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/p2p/simulations/pipes"
+)
+
+func TestSM2Handshake(t *testing.T) {
+	var (
+		prv0, _ = sm2.GenerateKey(rand.Reader)
+		hs0     = &protoHandshake{Version: 5, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:], Caps: []Cap{{"a", 0}}}
+
+		prv1, _ = sm2.GenerateKey(rand.Reader)
+		hs1     = &protoHandshake{Version: 5, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:], Caps: []Cap{{"b", 1}}}
+
+		wg sync.WaitGroup
+	)
+	fd0, fd1, err := pipes.TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer fd0.Close()
+		rlpx := newRLPX(fd0).(*rlpx)
+		rpubkey, err := rlpx.doEncHandshake(prv0, &prv1.PublicKey)
+		if err != nil {
+			t.Errorf("dial side enc handshake failed: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(rpubkey, &prv1.PublicKey) {
+			t.Errorf("dial side remote pubkey mismatch: got %v, want %v", rpubkey, &prv1.PublicKey)
+			return
+		}
+		phs, err := rlpx.doProtoHandshake(hs0)
+		if err != nil {
+			t.Errorf("dial side proto handshake error: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(phs.ID, hs1.ID) {
+			t.Errorf("dial side proto handshake mismatch: got %x", phs.ID)
+			return
+		}
+		if err := SendItems(rlpx, 8, "filestorm"); err != nil {
+			t.Errorf("dial side write error: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer fd1.Close()
+		rlpx := newRLPX(fd1).(*rlpx)
+		rpubkey, err := rlpx.doEncHandshake(prv1, nil)
+		if err != nil {
+			t.Errorf("listen side enc handshake failed: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(rpubkey, &prv0.PublicKey) {
+			t.Errorf("listen side remote pubkey mismatch: got %v, want %v", rpubkey, &prv0.PublicKey)
+			return
+		}
+		phs, err := rlpx.doProtoHandshake(hs1)
+		if err != nil {
+			t.Errorf("listen side proto handshake error: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(phs.ID, hs0.ID) {
+			t.Errorf("listen side proto handshake mismatch: got %x", phs.ID)
+			return
+		}
+		if err := ExpectMsg(rlpx, 8, []string{"filestorm"}); err != nil {
+			t.Errorf("listen side read error: %v", err)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSM2HandshakeWrongKey(t *testing.T) {
+	var (
+		prv0, _  = sm2.GenerateKey(rand.Reader)
+		prv1, _  = sm2.GenerateKey(rand.Reader)
+		other, _ = sm2.GenerateKey(rand.Reader)
+		s256, _  = crypto.GenerateKey()
+	)
+	// An SM2 node can't dial a secp256k1 node.
+	fd0, fd1, err := pipes.TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd1.Close()
+	if _, err := newRLPX(fd0).doEncHandshake(prv0, &s256.PublicKey); err != errNotSM2Key {
+		t.Fatalf("wrong error for secp256k1 remote: %v", err)
+	}
+	fd0.Close()
+
+	// The listener can't read an auth message encrypted to another key.
+	fd0, fd1, err = pipes.TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := newRLPX(fd1).doEncHandshake(prv1, nil)
+		fd1.Close()
+		errc <- err
+	}()
+	if _, err := newRLPX(fd0).doEncHandshake(prv0, &other.PublicKey); err == nil {
+		t.Error("dial side handshake succeeded with the wrong remote key")
+	}
+	fd0.Close()
+	if err := <-errc; err == nil {
+		t.Error("listen side handshake succeeded with the wrong remote key")
+	}
+}
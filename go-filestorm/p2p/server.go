@@ -20,10 +20,13 @@ package p2p
 import (
 	"bytes"
 	"crypto/ecdsa"
+	crand "crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -32,6 +35,7 @@ import (
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/mclock"
 	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
 	"github.com/filestorm/go-filestorm/event"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/p2p/discover"
@@ -68,11 +72,63 @@ const (
 
 var errServerStopped = errors.New("server stopped")
 
+// Node identity schemes.
+const (
+	IdentitySchemeV4  = "v4"  // secp256k1 node keys, ECIES handshake, AES frames
+	IdentitySchemeSM2 = "sm2" // SM2 node keys, SM2 key exchange handshake, SM4 frames
+)
+
+// GenerateNodeKey creates a new node key for the given identity scheme.
+func GenerateNodeKey(scheme string) (*ecdsa.PrivateKey, error) {
+	if scheme == IdentitySchemeSM2 {
+		return sm2.GenerateKey(crand.Reader)
+	}
+	return crypto.GenerateKey()
+}
+
+// HexToNodeKey parses a hex encoded node key of the given identity scheme.
+func HexToNodeKey(scheme, hexkey string) (*ecdsa.PrivateKey, error) {
+	if scheme != IdentitySchemeSM2 {
+		return crypto.HexToECDSA(hexkey)
+	}
+	b, err := hex.DecodeString(hexkey)
+	if err != nil {
+		return nil, errors.New("invalid hex string")
+	}
+	return sm2.ToECDSA(b)
+}
+
+// LoadNodeKey loads a hex encoded node key of the given identity scheme from
+// the given file. The sm2 scheme needs a key generated for it, a secp256k1 key
+// is never reused on the SM2 curve as that would link the two identities.
+func LoadNodeKey(scheme, file string) (*ecdsa.PrivateKey, error) {
+	if scheme != IdentitySchemeSM2 {
+		return crypto.LoadECDSA(file)
+	}
+	buf := make([]byte, 64)
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	if _, err := io.ReadFull(fd, buf); err != nil {
+		return nil, err
+	}
+	return HexToNodeKey(scheme, string(buf))
+}
+
 // Config holds Server options.
 type Config struct {
-	// This field must be set to a valid secp256k1 private key.
+	// This field must be set to a valid secp256k1 private key, or to an SM2
+	// private key for the sm2 identity scheme.
 	PrivateKey *ecdsa.PrivateKey `toml:"-"`
 
+	// IdentityScheme selects the node identity scheme, IdentitySchemeV4 if
+	// empty. It determines the node key type, the node record, the RLPx
+	// handshake and the discovery packet signatures, so all nodes of a network
+	// must use the same scheme.
+	IdentityScheme string `toml:",omitempty"`
+
 	// MaxPeers is the maximum number of peers that can be
 	// connected. It must be greater than zero.
 	MaxPeers int
@@ -445,6 +501,18 @@ func (srv *Server) Start() (err error) {
 	if srv.PrivateKey == nil {
 		return errors.New("Server.PrivateKey must be set to a non-nil key")
 	}
+	switch srv.IdentityScheme {
+	case "", IdentitySchemeV4:
+		if sm2.IsSM2(&srv.PrivateKey.PublicKey) {
+			return fmt.Errorf("Server.PrivateKey is an SM2 key, need identity scheme %q", IdentitySchemeSM2)
+		}
+	case IdentitySchemeSM2:
+		if !sm2.IsSM2(&srv.PrivateKey.PublicKey) {
+			return fmt.Errorf("Server.PrivateKey must be an SM2 key for identity scheme %q", IdentitySchemeSM2)
+		}
+	default:
+		return fmt.Errorf("unknown identity scheme %q", srv.IdentityScheme)
+	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
 	}
@@ -959,9 +1027,8 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	// If dialing, figure out the remote public key.
 	var dialPubkey *ecdsa.PublicKey
 	if dialDest != nil {
-		dialPubkey = new(ecdsa.PublicKey)
-		if err := dialDest.Load((*enode.Secp256k1)(dialPubkey)); err != nil {
-			return errors.New("dial destination doesn't have a secp256k1 public key")
+		if dialPubkey = dialDest.Pubkey(); dialPubkey == nil {
+			return errors.New("dial destination doesn't have a secp256k1 or SM2 public key")
 		}
 	}
 
@@ -993,7 +1060,7 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		clog.Trace("Failed proto handshake", "err", err)
 		return err
 	}
-	if id := c.node.ID(); !bytes.Equal(crypto.Keccak256(phs.ID), id[:]) {
+	if !bytes.Equal(phs.ID, crypto.FromECDSAPub(remotePubkey)[1:]) {
 		clog.Trace("Wrong devp2p handshake identity", "phsid", hex.EncodeToString(phs.ID))
 		return DiscUnexpectedIdentity
 	}
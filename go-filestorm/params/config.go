@@ -223,23 +223,23 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllFstashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(FstashConfig), nil, nil}
+	AllFstashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), "", "", new(FstashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Filestorm core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), "", "", nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
 	// AllPbftProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Filestorm core developers into the Pbft consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllPbftProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &PbftConfig{Period: 0, Epoch: 36000, FlushEpoch: 360}}
+	AllPbftProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), "", "", nil, nil, &PbftConfig{Period: 0, Epoch: 36000, FlushEpoch: 360}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(FstashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, "", "", new(FstashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -311,6 +311,14 @@ type ChainConfig struct {
 	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	MuirGlacierBlock    *big.Int `json:"muirGlacierBlock,omitempty"`    // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
+	NotaryBlock         *big.Int `json:"notaryBlock,omitempty"`         // Notarization system contract switch block (nil = not deployed, 0 = already activated)
+	CollectionsBlock    *big.Int `json:"collectionsBlock,omitempty"`    // Collections system contract switch block (nil = not deployed, 0 = already activated)
+	RelayBlock          *big.Int `json:"relayBlock,omitempty"`          // Relay system contract switch block (nil = not deployed, 0 = already activated)
+
+	// SignatureScheme selects the curve transactions are signed with (empty = secp256k1)
+	SignatureScheme string `json:"signatureScheme,omitempty"`
+	// HashFunction selects the hash of blocks, transactions, the state trie and addresses (empty = keccak256)
+	HashFunction string `json:"hashFunction,omitempty"`
 
 	// Various consensus engines
 	Fstash *FstashConfig `json:"fstash,omitempty"`
@@ -318,6 +326,18 @@ type ChainConfig struct {
 	Pbft   *PbftConfig   `json:"pbft,omitempty"`
 }
 
+// Transaction signature schemes a chain can be configured with.
+const (
+	SignatureSchemeSecp256k1 = "secp256k1"
+	SignatureSchemeSM2       = "sm2" // GM/T 0003 SM2 on the sm2p256v1 curve
+)
+
+// Chain hash functions a chain can be configured with.
+const (
+	HashFunctionKeccak256 = "keccak256"
+	HashFunctionSM3       = "sm3" // GM/T 0004 SM3
+)
+
 // FstashConfig is the consensus engine configs for proof-of-work based sealing.
 type FstashConfig struct{}
 
@@ -349,6 +369,29 @@ func (c *PbftConfig) String() string {
 	return "pbft"
 }
 
+// FlushOffset is the position within a flush epoch of the block whose hash is
+// flushed to the parent chain, leaving the preceding blocks time to settle.
+const FlushOffset = 5
+
+// IsFlushBlock returns whether the hash of block number is flushed to the
+// parent chain.
+func (c *PbftConfig) IsFlushBlock(number uint64) bool {
+	return c.FlushEpoch != 0 && number%c.FlushEpoch == FlushOffset
+}
+
+// NextFlushBlock returns the first block at or after number whose hash is
+// flushed to the parent chain. It returns false if the chain doesn't flush.
+func (c *PbftConfig) NextFlushBlock(number uint64) (uint64, bool) {
+	if c.FlushEpoch <= FlushOffset {
+		return 0, false
+	}
+	next := number - number%c.FlushEpoch + FlushOffset
+	for next < number {
+		next += c.FlushEpoch
+	}
+	return next, true
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -436,6 +479,41 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsNotary returns whether num is either equal to the notary block or greater.
+func (c *ChainConfig) IsNotary(num *big.Int) bool {
+	return isForked(c.NotaryBlock, num)
+}
+
+// IsCollections returns whether num is either equal to the collections block or greater.
+func (c *ChainConfig) IsCollections(num *big.Int) bool {
+	return isForked(c.CollectionsBlock, num)
+}
+
+// IsRelay returns whether num is either equal to the relay block or greater.
+func (c *ChainConfig) IsRelay(num *big.Int) bool {
+	return isForked(c.RelayBlock, num)
+}
+
+// IsSM2 returns whether transactions on the chain are signed with SM2.
+func (c *ChainConfig) IsSM2() bool {
+	return c.SignatureScheme == SignatureSchemeSM2
+}
+
+// IsSM3 returns whether the chain hashes blocks, transactions, the state trie
+// and addresses with SM3.
+func (c *ChainConfig) IsSM3() bool {
+	return c != nil && c.HashFunction == HashFunctionSM3
+}
+
+// Hasher returns the hash function of blocks, transactions, the state trie and
+// addresses of the chain.
+func (c *ChainConfig) Hasher() crypto.Hasher {
+	if c.IsSM3() {
+		return crypto.SM3Hasher
+	}
+	return crypto.Keccak256Hasher
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -457,6 +535,16 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 // CheckConfigForkOrder checks that we don't "skip" any forks, storm isn't pluggable enough
 // to guarantee that forks can be implemented in a different order than on official networks
 func (c *ChainConfig) CheckConfigForkOrder() error {
+	switch c.SignatureScheme {
+	case "", SignatureSchemeSecp256k1, SignatureSchemeSM2:
+	default:
+		return fmt.Errorf("unsupported signature scheme %q", c.SignatureScheme)
+	}
+	switch c.HashFunction {
+	case "", HashFunctionKeccak256, HashFunctionSM3:
+	default:
+		return fmt.Errorf("unsupported hash function %q", c.HashFunction)
+	}
 	type fork struct {
 		name  string
 		block *big.Int
@@ -492,6 +580,12 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 }
 
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	if c.IsSM2() != newcfg.IsSM2() {
+		return newCompatError("signature scheme", common.Big0, common.Big0)
+	}
+	if c.IsSM3() != newcfg.IsSM3() {
+		return newCompatError("hash function", common.Big0, common.Big0)
+	}
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
 	}
@@ -531,6 +625,15 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.NotaryBlock, newcfg.NotaryBlock, head) {
+		return newCompatError("notary block", c.NotaryBlock, newcfg.NotaryBlock)
+	}
+	if isForkIncompatible(c.CollectionsBlock, newcfg.CollectionsBlock, head) {
+		return newCompatError("collections block", c.CollectionsBlock, newcfg.CollectionsBlock)
+	}
+	if isForkIncompatible(c.RelayBlock, newcfg.RelayBlock, head) {
+		return newCompatError("relay block", c.RelayBlock, newcfg.RelayBlock)
+	}
 	return nil
 }
 
@@ -598,6 +701,7 @@ type Rules struct {
 	ChainID                                                 *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
+	IsSM3, IsNotary, IsCollections, IsRelay                 bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -616,5 +720,9 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsConstantinople: c.IsConstantinople(num),
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
+		IsSM3:            c.IsSM3(),
+		IsNotary:         c.IsNotary(num),
+		IsCollections:    c.IsCollections(num),
+		IsRelay:          c.IsRelay(num),
 	}
 }
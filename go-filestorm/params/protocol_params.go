@@ -110,11 +110,39 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	// Notarization system contract gas prices
+
+	NotarizeGas     uint64 = 65000 // Price of recording a notarization: three storage slots and the event
+	NotarizeByteGas uint64 = 8     // Per-byte price of the notarization call data carrying the metadata
+	NotaryLookupGas uint64 = 2400  // Price of reading a notarization
+
+	// Collections system contract gas prices
+
+	CollectionsPutGas   uint64 = 40000 // Price of writing a record: the record header, indexes and the event
+	CollectionsWordGas  uint64 = 20000 // Price per 32 byte word of record content and access list stored
+	CollectionsGetGas   uint64 = 2400  // Price of reading a record header
+	CollectionsReadGas  uint64 = 200   // Price per 32 byte word of record content read
+	CollectionsMaxValue        = 16384 // Maximum size of a record value
+
+	// Relay system contract gas prices
+
+	RelayRegisterGas  uint64 = 150000 // Price of registering a relay and storing its checkpoint
+	RelaySignerGas    uint64 = 20000  // Price per Clique signer stored
+	RelayCliqueGas    uint64 = 150000 // Price of verifying and storing a Clique header
+	RelayFstashGas    uint64 = 300000 // Price of verifying and storing an fstash header
+	RelayCacheGas     uint64 = 64     // Price per 64 byte item of an fstash verification cache generated for an epoch not used recently
+	RelayCanonicalGas uint64 = 20000  // Price per canonical chain entry written on a head change
+	RelayReadGas      uint64 = 2400   // Price of reading the head or a header
+	RelayVerifyGas    uint64 = 20000  // Price of verifying a receipt proof
+	RelayProofWordGas uint64 = 40     // Price per 32 byte word of receipt proof hashed
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas        uint64 = 3000 // Elliptic curve sender recovery gas price
 	Sha256BaseGas       uint64 = 60   // Base price for a SHA256 operation
 	Sha256PerWordGas    uint64 = 12   // Per-word price for a SHA256 operation
+	Sm3BaseGas          uint64 = 60   // Base price for an SM3 operation
+	Sm3PerWordGas       uint64 = 12   // Per-word price for an SM3 operation
 	Ripemd160BaseGas    uint64 = 600  // Base price for a RIPEMD160 operation
 	Ripemd160PerWordGas uint64 = 120  // Per-word price for a RIPEMD160 operation
 	IdentityBaseGas     uint64 = 15   // Base price for a data copy operation
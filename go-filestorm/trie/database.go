@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 	"github.com/filestorm/go-filestorm/log"
 	"github.com/filestorm/go-filestorm/metrics"
@@ -72,6 +73,7 @@ const secureKeyLength = 11 + 32
 // servers even while the trie is executing expensive garbage collection.
 type Database struct {
 	diskdb fstdb.KeyValueStore // Persistent storage for matured trie nodes
+	hasher crypto.Hasher       // Hash function of the tries in the database
 
 	cleans  *fastcache.Cache            // GC friendly memory cache of clean node RLPs
 	dirties map[common.Hash]*cachedNode // Data and references relationships of dirty nodes
@@ -287,12 +289,19 @@ func NewDatabase(diskdb fstdb.KeyValueStore) *Database {
 // before its written out to disk or garbage collected. It also acts as a read cache
 // for nodes loaded from disk.
 func NewDatabaseWithCache(diskdb fstdb.KeyValueStore, cache int) *Database {
+	return NewDatabaseWithHasher(diskdb, cache, crypto.Keccak256Hasher)
+}
+
+// NewDatabaseWithHasher creates a new trie database like NewDatabaseWithCache,
+// for tries hashing with the given hash function instead of Keccak256.
+func NewDatabaseWithHasher(diskdb fstdb.KeyValueStore, cache int, hasher crypto.Hasher) *Database {
 	var cleans *fastcache.Cache
 	if cache > 0 {
 		cleans = fastcache.New(cache * 1024 * 1024)
 	}
 	return &Database{
 		diskdb: diskdb,
+		hasher: hasher,
 		cleans: cleans,
 		dirties: map[common.Hash]*cachedNode{{}: {
 			children: make(map[common.Hash]uint16),
@@ -301,6 +310,11 @@ func NewDatabaseWithCache(diskdb fstdb.KeyValueStore, cache int) *Database {
 	}
 }
 
+// Hasher returns the hash function of the tries in the database.
+func (db *Database) Hasher() crypto.Hasher {
+	return db.hasher
+}
+
 // DiskDB retrieves the persistent storage backing the trie database.
 func (db *Database) DiskDB() fstdb.KeyValueReader {
 	return db.diskdb
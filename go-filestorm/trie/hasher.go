@@ -21,13 +21,15 @@ import (
 	"sync"
 
 	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/rlp"
 	"golang.org/x/crypto/sha3"
 )
 
 type hasher struct {
 	tmp    sliceBuffer
-	sha    keccakState
+	sha    hash.Hash
+	fn     crypto.Hasher // hash function computed by sha
 	onleaf LeafCallback
 }
 
@@ -55,13 +57,16 @@ var hasherPool = sync.Pool{
 	New: func() interface{} {
 		return &hasher{
 			tmp: make(sliceBuffer, 0, 550), // cap is as large as a full fullNode.
-			sha: sha3.NewLegacyKeccak256().(keccakState),
+			sha: sha3.NewLegacyKeccak256(),
 		}
 	},
 }
 
-func newHasher(onleaf LeafCallback) *hasher {
+func newHasher(fn crypto.Hasher, onleaf LeafCallback) *hasher {
 	h := hasherPool.Get().(*hasher)
+	if h.fn != fn {
+		h.sha, h.fn = fn.New(), fn
+	}
 	h.onleaf = onleaf
 	return h
 }
@@ -210,6 +215,10 @@ func (h *hasher) makeHashNode(data []byte) hashNode {
 	n := make(hashNode, h.sha.Size())
 	h.sha.Reset()
 	h.sha.Write(data)
-	h.sha.Read(n)
+	if sha, ok := h.sha.(keccakState); ok {
+		sha.Read(n)
+	} else {
+		h.sha.Sum(n[:0])
+	}
 	return n
 }
@@ -76,6 +76,63 @@ func TestProof(t *testing.T) {
 	}
 }
 
+func TestSM3Proof(t *testing.T) {
+	keccakTrie, vals := randomTrie(100)
+	keccakRoot := keccakTrie.Hash()
+
+	trie := NewEmpty(crypto.SM3Hasher)
+	if root := trie.Hash(); root != EmptyRoot(crypto.SM3Hasher) || root == emptyRoot {
+		t.Fatalf("empty SM3 root %x", root)
+	}
+	for _, kv := range vals {
+		trie.Update(kv.k, kv.v)
+	}
+	root := trie.Hash()
+	if root == keccakRoot {
+		t.Fatal("SM3 root equals the Keccak256 root")
+	}
+	// hashers from the shared pool keep the hash function of each trie
+	again := new(Trie)
+	for _, kv := range vals {
+		again.Update(kv.k, kv.v)
+	}
+	if again.Hash() != keccakRoot {
+		t.Fatal("Keccak256 root changed after hashing with SM3")
+	}
+	// tries committed to an SM3 database are found again under their SM3 hashes
+	triedb := NewDatabaseWithHasher(memorydb.New(), 0, crypto.SM3Hasher)
+	stored, _ := New(common.Hash{}, triedb)
+	for _, kv := range vals {
+		stored.Update(kv.k, kv.v)
+	}
+	if committed, _ := stored.Commit(nil); committed != root {
+		t.Fatalf("committed SM3 root %x, want %x", committed, root)
+	}
+	triedb.Commit(root, false)
+	reopened, err := New(root, triedb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range vals {
+		if !bytes.Equal(reopened.Get(kv.k), kv.v) {
+			t.Fatalf("reopened SM3 trie misses key %x", kv.k)
+		}
+	}
+	for _, kv := range vals {
+		proof := memorydb.New()
+		if err := trie.Prove(kv.k, 0, proof); err != nil {
+			t.Fatalf("missing key %x while constructing proof", kv.k)
+		}
+		val, _, err := VerifyProof(root, kv.k, proof)
+		if err != nil {
+			t.Fatalf("failed to verify proof for key %x: %v", kv.k, err)
+		}
+		if !bytes.Equal(val, kv.v) {
+			t.Fatalf("verified value mismatch for key %x: have %x, want %x", kv.k, val, kv.v)
+		}
+	}
+}
+
 func TestOneElementProof(t *testing.T) {
 	trie := new(Trie)
 	updateString(trie, "k", "v")
@@ -22,6 +22,7 @@ import (
 
 	"github.com/filestorm/go-filestorm/common"
 	"github.com/filestorm/go-filestorm/common/prque"
+	"github.com/filestorm/go-filestorm/crypto"
 	"github.com/filestorm/go-filestorm/fstdb"
 )
 
@@ -75,16 +76,24 @@ type Sync struct {
 	requests map[common.Hash]*request // Pending requests pertaining to a key hash
 	queue    *prque.Prque             // Priority queue with the pending requests
 	bloom    *SyncBloom               // Bloom filter for fast node existence checks
+	hasher   crypto.Hasher            // Hash function of the synced tries
 }
 
 // NewSync creates a new trie data download scheduler.
 func NewSync(root common.Hash, database fstdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom) *Sync {
+	return NewSyncWithHasher(root, database, callback, bloom, crypto.Keccak256Hasher)
+}
+
+// NewSyncWithHasher creates a new trie data download scheduler for tries hashing
+// with the given hash function instead of Keccak256.
+func NewSyncWithHasher(root common.Hash, database fstdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom, hasher crypto.Hasher) *Sync {
 	ts := &Sync{
 		database: database,
 		membatch: newSyncMemBatch(),
 		requests: make(map[common.Hash]*request),
 		queue:    prque.New(nil),
 		bloom:    bloom,
+		hasher:   hasher,
 	}
 	ts.AddSubTrie(root, 0, common.Hash{}, callback)
 	return ts
@@ -93,7 +102,7 @@ func NewSync(root common.Hash, database fstdb.KeyValueReader, callback LeafCallb
 // AddSubTrie registers a new trie to the sync code, rooted at the designated parent.
 func (s *Sync) AddSubTrie(root common.Hash, depth int, parent common.Hash, callback LeafCallback) {
 	// Short circuit if the trie is empty or already known
-	if root == emptyRoot {
+	if root == EmptyRoot(s.hasher) {
 		return
 	}
 	if _, ok := s.membatch.batch[root]; ok {
@@ -30,23 +30,42 @@ var (
 	// emptyRoot is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 
+	// emptyRootSM3 is the known root hash of an empty trie hashed with SM3.
+	emptyRootSM3 = crypto.SM3Hasher.Hash([]byte{0x80})
+
 	// emptyState is the known hash of an empty state trie entry.
 	emptyState = crypto.Keccak256Hash(nil)
 )
 
+// EmptyRoot returns the root hash of an empty trie hashed with the given hash
+// function.
+func EmptyRoot(hasher crypto.Hasher) common.Hash {
+	if hasher.IsSM3() {
+		return emptyRootSM3
+	}
+	return emptyRoot
+}
+
 // LeafCallback is a callback type invoked when a trie operation reaches a leaf
 // node. It's used by state sync and commit to allow handling external references
 // between account and storage tries.
 type LeafCallback func(leaf []byte, parent common.Hash) error
 
 // Trie is a Merkle Patricia Trie.
-// The zero value is an empty trie with no database.
+// The zero value is an empty trie with no database hashing with Keccak256.
 // Use New to create a trie that sits on top of a database.
 //
 // Trie is not safe for concurrent use.
 type Trie struct {
-	db   *Database
-	root node
+	db     *Database
+	root   node
+	hasher crypto.Hasher
+}
+
+// NewEmpty creates an empty trie with no database hashing with the given hash
+// function.
+func NewEmpty(hasher crypto.Hasher) *Trie {
+	return &Trie{hasher: hasher}
 }
 
 // newFlag returns the cache flag value for a newly created node.
@@ -65,9 +84,10 @@ func New(root common.Hash, db *Database) (*Trie, error) {
 		panic("trie.New called without a database")
 	}
 	trie := &Trie{
-		db: db,
+		db:     db,
+		hasher: db.hasher,
 	}
-	if root != (common.Hash{}) && root != emptyRoot {
+	if root != (common.Hash{}) && root != EmptyRoot(db.hasher) {
 		rootnode, err := trie.resolveHash(root[:], nil)
 		if err != nil {
 			return nil, err
@@ -425,9 +445,9 @@ func (t *Trie) Commit(onleaf LeafCallback) (root common.Hash, err error) {
 
 func (t *Trie) hashRoot(db *Database, onleaf LeafCallback) (node, node, error) {
 	if t.root == nil {
-		return hashNode(emptyRoot.Bytes()), nil, nil
+		return hashNode(EmptyRoot(t.hasher).Bytes()), nil, nil
 	}
-	h := newHasher(onleaf)
+	h := newHasher(t.hasher, onleaf)
 	defer returnHasherToPool(h)
 	return h.hash(t.root, db, true)
 }
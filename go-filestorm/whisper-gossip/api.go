@@ -0,0 +1,192 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// DefaultTTL is the time to live of published envelopes if none is given.
+const DefaultTTL = 60 * 60
+
+// PublicGossipAPI provides the gossip RPC service.
+type PublicGossipAPI struct {
+	g *Gossip
+}
+
+// NewPublicGossipAPI creates a new gossip RPC service.
+func NewPublicGossipAPI(g *Gossip) *PublicGossipAPI {
+	return &PublicGossipAPI{g}
+}
+
+// Info contains diagnostic information.
+type Info struct {
+	Envelopes      int    `json:"envelopes"`      // Number of stored envelopes
+	Peers          int    `json:"peers"`          // Number of connected gossip peers
+	MaxMessageSize uint32 `json:"maxMessageSize"` // Maximum accepted payload size
+	MaxTTL         uint32 `json:"maxTTL"`         // Maximum accepted time to live
+}
+
+// Info returns diagnostic information about the gossip node.
+func (api *PublicGossipAPI) Info(ctx context.Context) Info {
+	api.g.lock.RLock()
+	defer api.g.lock.RUnlock()
+
+	return Info{
+		Envelopes:      len(api.g.envelopes),
+		Peers:          len(api.g.peers),
+		MaxMessageSize: api.g.config.MaxMessageSize,
+		MaxTTL:         api.g.config.MaxTTL,
+	}
+}
+
+// PublishArgs represents the arguments to publish a message.
+type PublishArgs struct {
+	From      common.Address  `json:"from"`
+	Topic     string          `json:"topic"`
+	Payload   hexutil.Bytes   `json:"payload"`
+	Recipient hexutil.Bytes   `json:"recipient"` // Public key to encrypt the payload to, broadcast if empty
+	TTL       *hexutil.Uint64 `json:"ttl"`
+}
+
+// Publish signs a message with an unlocked account and sends it to the network.
+// It returns the hash of the envelope.
+func (api *PublicGossipAPI) Publish(ctx context.Context, args PublishArgs) (common.Hash, error) {
+	if args.Topic == "" {
+		return common.Hash{}, errors.New(`missing "topic"`)
+	}
+	ttl := uint64(DefaultTTL)
+	if args.TTL != nil {
+		ttl = uint64(*args.TTL)
+	}
+	if ttl == 0 || ttl > uint64(api.g.config.MaxTTL) {
+		return common.Hash{}, fmt.Errorf("ttl must be between 1 and %d seconds", api.g.config.MaxTTL)
+	}
+	env := NewEnvelope(TopicHash(args.Topic), uint32(ttl), args.Payload)
+	if len(args.Recipient) > 0 {
+		recipient, err := unmarshalPubkey(args.Recipient, api.g.isSM2)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("invalid recipient: %v", err)
+		}
+		if err := env.Encrypt(recipient); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if err := api.g.Publish(args.From, env); err != nil {
+		return common.Hash{}, err
+	}
+	return env.Hash(), nil
+}
+
+// CriteriaArgs represents the arguments selecting messages.
+type CriteriaArgs struct {
+	Topics     []string        `json:"topics"`
+	Sender     *common.Address `json:"sender"`
+	PrivateKey hexutil.Bytes   `json:"privateKey"` // Key decrypting messages to its account
+}
+
+func (api *PublicGossipAPI) criteria(args CriteriaArgs) (*Criteria, error) {
+	criteria := &Criteria{Sender: args.Sender}
+	for _, topic := range args.Topics {
+		criteria.Topics = append(criteria.Topics, TopicHash(topic))
+	}
+	if len(args.PrivateKey) > 0 {
+		var err error
+		if api.g.isSM2 {
+			criteria.Key, err = sm2.ToECDSA(args.PrivateKey)
+		} else {
+			criteria.Key, err = crypto.ToECDSA(args.PrivateKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+	}
+	return criteria, nil
+}
+
+// GetMessages returns the stored messages matching the criteria, ordered by
+// the time they were sent. Clients use it to pick up the messages published
+// while they were offline.
+func (api *PublicGossipAPI) GetMessages(ctx context.Context, args CriteriaArgs) ([]*Message, error) {
+	criteria, err := api.criteria(args)
+	if err != nil {
+		return nil, err
+	}
+	msgs := api.g.Messages(criteria)
+	if msgs == nil {
+		msgs = []*Message{}
+	}
+	return msgs, nil
+}
+
+// Messages creates a subscription notifying the new messages matching the
+// criteria.
+func (api *PublicGossipAPI) Messages(ctx context.Context, args CriteriaArgs) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	criteria, err := api.criteria(args)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		sub    = api.g.Subscribe(criteria)
+	)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, msg); err != nil {
+					log.Error("Failed to send notification", "err", err)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// unmarshalPubkey parses an uncompressed public key with or without the 0x04
+// prefix on the curve of the chain's accounts.
+func unmarshalPubkey(pub []byte, isSM2 bool) (*ecdsa.PublicKey, error) {
+	if len(pub) == 64 {
+		pub = append([]byte{0x04}, pub...)
+	}
+	if isSM2 {
+		return sm2.UnmarshalPubkey(pub)
+	}
+	return crypto.UnmarshalPubkey(pub)
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/consensus"
+	"github.com/filestorm/go-filestorm/core"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/log"
+)
+
+// signerLister is implemented by the consensus engines with a validator set,
+// like pbft.
+type signerLister interface {
+	GetSigners(chain consensus.ChainReader, header *types.Header) ([]common.Address, error)
+}
+
+// ChainQuota returns the quota function of the configured rate limits based on
+// the validator set and the balances of the chain at its current head.
+func ChainQuota(config *Config, chain *core.BlockChain) QuotaFunc {
+	var (
+		lock       sync.Mutex
+		head       common.Hash
+		validators map[common.Address]bool
+	)
+	isValidator := func(addr common.Address, header *types.Header) bool {
+		engine, ok := chain.Engine().(signerLister)
+		if !ok {
+			return false
+		}
+		lock.Lock()
+		defer lock.Unlock()
+
+		if header.Hash() != head {
+			signers, err := engine.GetSigners(chain, header)
+			if err != nil {
+				log.Warn("Failed to retrieve validators for gossip rate limits", "number", header.Number, "err", err)
+				return false
+			}
+			head, validators = header.Hash(), make(map[common.Address]bool)
+			for _, signer := range signers {
+				validators[signer] = true
+			}
+		}
+		return validators[addr]
+	}
+	return func(addr common.Address) uint64 {
+		header := chain.CurrentHeader()
+		if isValidator(addr, header) {
+			return config.ValidatorQuota
+		}
+		quota := config.Quota
+		if config.ValidatorQuota <= quota || config.StakeQuota == 0 || config.StakeUnit == nil || config.StakeUnit.Sign() <= 0 {
+			return quota
+		}
+		statedb, err := chain.StateAt(header.Root)
+		if err != nil {
+			return quota
+		}
+		units := new(big.Int).Div(statedb.GetBalance(addr), config.StakeUnit)
+		if max := (config.ValidatorQuota - quota) / config.StakeQuota; units.Cmp(new(big.Int).SetUint64(max)) > 0 {
+			return config.ValidatorQuota
+		}
+		return quota + units.Uint64()*config.StakeQuota
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import "math/big"
+
+// Config represents the configuration state of a gossip node.
+type Config struct {
+	MaxMessageSize uint32 `toml:",omitempty"` // Maximum size of an envelope payload
+	MaxTTL         uint32 `toml:",omitempty"` // Maximum time to live of an envelope in seconds, bounds store-and-forward
+	StoreSize      int    `toml:",omitempty"` // Maximum number of envelopes stored for forwarding
+
+	// Rate limits in envelopes per minute. Validators of the chain get the
+	// validator quota, other accounts the base quota plus the stake quota for
+	// every stake unit of their balance, up to the validator quota. There is no
+	// base quota by default: accounts are free to create, so anyone could fill
+	// the store of every node with envelopes of fresh accounts.
+	Quota          uint64   `toml:",omitempty"`
+	ValidatorQuota uint64   `toml:",omitempty"`
+	StakeQuota     uint64   `toml:",omitempty"`
+	StakeUnit      *big.Int `toml:",omitempty"`
+}
+
+// DefaultConfig represents the default configuration.
+var DefaultConfig = Config{
+	MaxMessageSize: 256 * 1024,
+	MaxTTL:         24 * 60 * 60,
+	StoreSize:      65536,
+	Quota:          0,
+	ValidatorQuota: 600,
+	StakeQuota:     60,
+	StakeUnit:      new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil),
+}
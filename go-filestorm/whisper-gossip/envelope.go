@@ -0,0 +1,191 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/common/hexutil"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/ecies"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+var (
+	errNotRecipient = errors.New("key is not the recipient of the envelope")
+	errInvalidSig   = errors.New("invalid envelope signature")
+)
+
+// TopicHash returns the topic identifier envelopes of the named topic carry.
+func TopicHash(name string) common.Hash {
+	return crypto.Keccak256Hash([]byte(name))
+}
+
+// Envelope is a message as it travels through the network. It is signed by
+// the account publishing it, which is accountable for it in the rate limits.
+// Envelopes with a recipient carry a payload encrypted to the recipient's
+// public key, the others are readable by everyone.
+type Envelope struct {
+	Topic     common.Hash
+	Expiry    uint64         // Unix time the envelope expires at
+	TTL       uint32         // Time to live in seconds, the envelope was sent at Expiry - TTL
+	Recipient common.Address // Account the payload is encrypted to, zero for broadcasts
+	Payload   []byte
+	Signature []byte
+
+	hash common.Hash // Cached hash of the envelope
+}
+
+// NewEnvelope creates an unsigned envelope living ttl seconds from now.
+func NewEnvelope(topic common.Hash, ttl uint32, payload []byte) *Envelope {
+	return &Envelope{
+		Topic:   topic,
+		Expiry:  uint64(time.Now().Unix()) + uint64(ttl),
+		TTL:     ttl,
+		Payload: payload,
+	}
+}
+
+// Encrypt encrypts the payload to the public key of the recipient, with ECIES
+// for secp256k1 keys and the SM2 public key encryption for SM2 keys.
+func (e *Envelope) Encrypt(recipient *ecdsa.PublicKey) error {
+	var (
+		enc []byte
+		err error
+	)
+	if sm2.IsSM2(recipient) {
+		enc, err = sm2.Encrypt(rand.Reader, recipient, e.Payload)
+	} else {
+		enc, err = ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(recipient), e.Payload, nil, nil)
+	}
+	if err != nil {
+		return err
+	}
+	e.Recipient, e.Payload = crypto.PubkeyToAddress(*recipient), enc
+	return nil
+}
+
+// Decrypt returns the payload decrypted with the recipient's private key.
+func (e *Envelope) Decrypt(prv *ecdsa.PrivateKey) ([]byte, error) {
+	if e.Recipient != crypto.PubkeyToAddress(prv.PublicKey) {
+		return nil, errNotRecipient
+	}
+	if sm2.IsSM2(&prv.PublicKey) {
+		return sm2.Decrypt(prv, e.Payload)
+	}
+	return ecies.ImportECDSA(prv).Decrypt(e.Payload, nil, nil)
+}
+
+// SigningData returns the data the publisher signs. Account managers sign
+// the Keccak256 hash of it.
+func (e *Envelope) SigningData() []byte {
+	data, _ := rlp.EncodeToBytes([]interface{}{e.Topic, e.Expiry, e.TTL, e.Recipient, e.Payload})
+	return data
+}
+
+// Sign signs the envelope with a private key.
+func (e *Envelope) Sign(prv *ecdsa.PrivateKey) error {
+	var (
+		hash = crypto.Keccak256(e.SigningData())
+		sig  []byte
+		err  error
+	)
+	if sm2.IsSM2(&prv.PublicKey) {
+		sig, err = sm2.SignDigest(hash, prv)
+	} else {
+		sig, err = crypto.Sign(hash, prv)
+	}
+	if err != nil {
+		return err
+	}
+	e.Signature, e.hash = sig, common.Hash{}
+	return nil
+}
+
+// SenderKey recovers the public key of the publisher, with SM2 on chains whose
+// accounts sign with SM2 keys.
+func (e *Envelope) SenderKey(isSM2 bool) (*ecdsa.PublicKey, error) {
+	if len(e.Signature) != crypto.SignatureLength {
+		return nil, errInvalidSig
+	}
+	var (
+		hash = crypto.Keccak256(e.SigningData())
+		pub  *ecdsa.PublicKey
+		err  error
+	)
+	if isSM2 {
+		pub, err = sm2.RecoverPubkey(hash, e.Signature)
+	} else {
+		pub, err = crypto.SigToPub(hash, e.Signature)
+	}
+	if err != nil {
+		return nil, errInvalidSig
+	}
+	return pub, nil
+}
+
+// Hash returns the hash identifying the envelope.
+func (e *Envelope) Hash() common.Hash {
+	if e.hash == (common.Hash{}) {
+		enc, _ := rlp.EncodeToBytes(e)
+		e.hash = crypto.Keccak256Hash(enc)
+	}
+	return e.hash
+}
+
+// Sent returns the time the envelope was published at.
+func (e *Envelope) Sent() uint64 {
+	return e.Expiry - uint64(e.TTL)
+}
+
+// Encrypted reports whether the payload is encrypted to a recipient.
+func (e *Envelope) Encrypted() bool {
+	return e.Recipient != (common.Address{})
+}
+
+// validate checks the envelope against the local limits at time now.
+func (e *Envelope) validate(config *Config, now uint64) error {
+	switch {
+	case uint32(len(e.Payload)) > config.MaxMessageSize:
+		return fmt.Errorf("oversized payload: %d > %d bytes", len(e.Payload), config.MaxMessageSize)
+	case e.Expiry < uint64(e.TTL):
+		return errors.New("invalid expiry")
+	case e.Expiry <= now:
+		return errors.New("expired envelope")
+	case e.TTL > config.MaxTTL || e.Sent() > now+uint64(maxClockDrift/time.Second):
+		return errors.New("envelope from the future")
+	}
+	return nil
+}
+
+// Message is a received envelope as delivered to subscribers.
+type Message struct {
+	Hash      common.Hash     `json:"hash"`
+	Topic     common.Hash     `json:"topic"`
+	Sender    common.Address  `json:"sender"`
+	SenderKey hexutil.Bytes   `json:"senderKey"`
+	Recipient *common.Address `json:"recipient,omitempty"`
+	Payload   hexutil.Bytes   `json:"payload"`
+	Sent      uint64          `json:"sent"`
+	Expiry    uint64          `json:"expiry"`
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"crypto/ecdsa"
+	"sort"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+// Criteria selects the messages delivered to a subscriber.
+type Criteria struct {
+	Topics []common.Hash     // Topics of the messages, all topics if empty
+	Sender *common.Address   // Publisher of the messages, any publisher if nil
+	Key    *ecdsa.PrivateKey // Key decrypting the messages to its account, broadcasts only if nil
+}
+
+// match returns the message of an envelope matching the criteria, or nil.
+func (c *Criteria) match(env *Envelope, sender *ecdsa.PublicKey) *Message {
+	if len(c.Topics) > 0 {
+		found := false
+		for _, topic := range c.Topics {
+			if topic == env.Topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	addr := crypto.PubkeyToAddress(*sender)
+	if c.Sender != nil && *c.Sender != addr {
+		return nil
+	}
+	msg := &Message{
+		Hash:      env.Hash(),
+		Topic:     env.Topic,
+		Sender:    addr,
+		SenderKey: crypto.FromECDSAPub(sender),
+		Payload:   env.Payload,
+		Sent:      env.Sent(),
+		Expiry:    env.Expiry,
+	}
+	if env.Encrypted() {
+		if c.Key == nil {
+			return nil
+		}
+		payload, err := env.Decrypt(c.Key)
+		if err != nil {
+			return nil
+		}
+		recipient := env.Recipient
+		msg.Recipient, msg.Payload = &recipient, payload
+	}
+	return msg
+}
+
+// Subscription delivers the new messages matching its criteria.
+type Subscription struct {
+	g        *Gossip
+	criteria *Criteria
+	ch       chan *Message
+}
+
+// Messages returns the channel messages are delivered on. It is closed when
+// the gossip node stops.
+func (s *Subscription) Messages() <-chan *Message {
+	return s.ch
+}
+
+// Unsubscribe stops the delivery of messages.
+func (s *Subscription) Unsubscribe() {
+	s.g.subLock.Lock()
+	defer s.g.subLock.Unlock()
+
+	if _, ok := s.g.subs[s]; ok {
+		s.close()
+	}
+}
+
+// close removes the subscription, the subscription lock has to be held.
+func (s *Subscription) close() {
+	delete(s.g.subs, s)
+	close(s.ch)
+}
+
+// sortMessages orders messages by the time they were sent.
+func sortMessages(msgs []*Message) {
+	sort.SliceStable(msgs, func(i, j int) bool {
+		if msgs[i].Sent != msgs[j].Sent {
+			return msgs[i].Sent < msgs[j].Sent
+		}
+		return msgs[i].Hash.Big().Cmp(msgs[j].Hash.Big()) < 0
+	})
+}
@@ -0,0 +1,442 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gossip implements a topic based publish/subscribe messaging protocol
+// for the members of a chain, replacing the proof-of-work spam protection of
+// Whisper with the accountability of chain accounts.
+//
+// Envelopes are signed by the account publishing them and forwarded by all
+// nodes. Every node rate limits the publishers: validators get the largest
+// quota, other accounts a quota growing with their stake. Payloads can be
+// encrypted to the public key of a recipient. Nodes store the envelopes until
+// they expire and hand them to peers connecting later, so members that were
+// offline still receive them.
+package gossip
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/accounts"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstdb"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/p2p"
+	"github.com/filestorm/go-filestorm/rlp"
+	"github.com/filestorm/go-filestorm/rpc"
+)
+
+// Protocol constants.
+const (
+	ProtocolName       = "gossip"
+	ProtocolVersion    = uint64(1)
+	ProtocolVersionStr = "1.0"
+
+	statusCode    = 0 // Handshake message with the protocol version
+	envelopesCode = 1 // Batch of envelopes
+	numberOfCodes = 2
+
+	maxBatchSize     = 1024 * 1024 // Soft limit of the bytes sent in one message
+	envelopeOverhead = 160         // Approximate size of an envelope without its payload
+
+	broadcastInterval = 300 * time.Millisecond // Interval envelopes are sent to peers at
+	expirationCycle   = time.Second            // Interval expired envelopes are dropped at
+	maxClockDrift     = 30 * time.Second       // Tolerated clock difference of publishers
+	subscriptionQueue = 256                    // Undelivered messages buffered per subscription
+	maxRateLimited    = 256                    // Rate limited envelopes a peer may relay per minute
+)
+
+var (
+	errRateLimited     = errors.New("publisher exceeded its gossip quota")
+	errStoreFull       = errors.New("gossip store is full")
+	errPeerRateLimited = errors.New("peer relays rate limited envelopes")
+)
+
+// envelopePrefix prefixes the keys of the stored envelopes, followed by the
+// big endian expiry and the envelope hash.
+var envelopePrefix = []byte("e")
+
+// Gossip represents a gossip protocol node.
+type Gossip struct {
+	config   *Config
+	am       *accounts.Manager
+	db       fstdb.KeyValueStore // Persistent store of the envelopes, may be nil
+	isSM2    bool                // Whether accounts sign with SM2 keys
+	limiter  *limiter
+	protocol p2p.Protocol
+
+	lock      sync.RWMutex
+	envelopes map[common.Hash]*Envelope
+	peers     map[*peer]struct{}
+
+	subLock sync.Mutex
+	subs    map[*Subscription]struct{}
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a gossip node. Envelopes are persisted in db if given, which is
+// closed when the node stops. The quota function rate limits publishers, if nil everyone gets the base quota.
+func New(config *Config, am *accounts.Manager, db fstdb.KeyValueStore, quota QuotaFunc, isSM2 bool) *Gossip {
+	if config == nil {
+		config = &DefaultConfig
+	}
+	if quota == nil {
+		quota = func(common.Address) uint64 { return config.Quota }
+	}
+	g := &Gossip{
+		config:    config,
+		am:        am,
+		db:        db,
+		isSM2:     isSM2,
+		limiter:   newLimiter(quota),
+		envelopes: make(map[common.Hash]*Envelope),
+		peers:     make(map[*peer]struct{}),
+		subs:      make(map[*Subscription]struct{}),
+		quit:      make(chan struct{}),
+	}
+	g.protocol = p2p.Protocol{
+		Name:    ProtocolName,
+		Version: uint(ProtocolVersion),
+		Length:  numberOfCodes,
+		Run:     g.handlePeer,
+		NodeInfo: func() interface{} {
+			return map[string]interface{}{
+				"version":        ProtocolVersionStr,
+				"maxMessageSize": g.config.MaxMessageSize,
+				"maxTTL":         g.config.MaxTTL,
+			}
+		},
+	}
+	return g
+}
+
+// Protocols implements node.Service, returning the gossip protocol.
+func (g *Gossip) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{g.protocol}
+}
+
+// APIs implements node.Service, returning the gossip RPC API.
+func (g *Gossip) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: ProtocolName,
+			Version:   ProtocolVersionStr,
+			Service:   NewPublicGossipAPI(g),
+			Public:    true,
+		},
+	}
+}
+
+// Start implements node.Service, loading the stored envelopes and starting
+// the expiration loop.
+func (g *Gossip) Start(*p2p.Server) error {
+	g.load()
+	g.wg.Add(1)
+	go g.expireLoop()
+	log.Info("Started gossip protocol", "version", ProtocolVersion, "envelopes", len(g.envelopes))
+	return nil
+}
+
+// Stop implements node.Service, terminating the gossip node.
+func (g *Gossip) Stop() error {
+	close(g.quit)
+	g.wg.Wait()
+
+	g.subLock.Lock()
+	for sub := range g.subs {
+		sub.close()
+	}
+	g.subLock.Unlock()
+
+	if g.db != nil {
+		g.db.Close()
+	}
+	log.Info("Gossip protocol stopped")
+	return nil
+}
+
+// Publish signs an envelope with the given account of the account manager
+// and sends it to the network.
+func (g *Gossip) Publish(from common.Address, env *Envelope) error {
+	if g.am == nil {
+		return errors.New("no account manager")
+	}
+	account := accounts.Account{Address: from}
+	wallet, err := g.am.Find(account)
+	if err != nil {
+		return err
+	}
+	if env.Signature, err = wallet.SignData(account, accounts.MimetypeTextPlain, env.SigningData()); err != nil {
+		return err
+	}
+	return g.Send(env)
+}
+
+// Send sends a signed envelope to the network.
+func (g *Gossip) Send(env *Envelope) error {
+	return g.add(env, true)
+}
+
+// Envelopes returns the unexpired envelopes stored by the node.
+func (g *Gossip) Envelopes() []*Envelope {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	envs := make([]*Envelope, 0, len(g.envelopes))
+	for _, env := range g.envelopes {
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+// add validates an envelope and stores it for forwarding. Known envelopes are
+// ignored.
+func (g *Gossip) add(env *Envelope, local bool) error {
+	now := time.Now()
+	if err := env.validate(g.config, uint64(now.Unix())); err != nil {
+		return err
+	}
+	hash := env.Hash()
+
+	g.lock.RLock()
+	_, known := g.envelopes[hash]
+	g.lock.RUnlock()
+	if known {
+		return nil
+	}
+	pub, err := env.SenderKey(g.isSM2)
+	if err != nil {
+		return err
+	}
+	sender := crypto.PubkeyToAddress(*pub)
+	if !g.limiter.allow(sender, now) {
+		if !local {
+			log.Trace("Dropped rate limited envelope", "hash", hash, "sender", sender)
+		}
+		return errRateLimited
+	}
+	g.lock.Lock()
+	if _, known := g.envelopes[hash]; known {
+		g.lock.Unlock()
+		return nil
+	}
+	if len(g.envelopes) >= g.config.StoreSize {
+		g.lock.Unlock()
+		return errStoreFull
+	}
+	g.envelopes[hash] = env
+	g.lock.Unlock()
+
+	if g.db != nil {
+		blob, err := rlp.EncodeToBytes(env)
+		if err != nil {
+			return err
+		}
+		if err := g.db.Put(envelopeKey(env), blob); err != nil {
+			log.Error("Failed to store gossip envelope", "hash", hash, "err", err)
+		}
+	}
+	g.deliver(env, pub)
+	return nil
+}
+
+// deliver hands a new envelope to the matching local subscriptions.
+func (g *Gossip) deliver(env *Envelope, sender *ecdsa.PublicKey) {
+	g.subLock.Lock()
+	defer g.subLock.Unlock()
+
+	for sub := range g.subs {
+		if msg := sub.criteria.match(env, sender); msg != nil {
+			select {
+			case sub.ch <- msg:
+			default:
+				log.Warn("Gossip subscription queue full, message dropped", "hash", msg.Hash)
+			}
+		}
+	}
+}
+
+// Subscribe creates a subscription delivering the new messages matching the
+// criteria.
+func (g *Gossip) Subscribe(criteria *Criteria) *Subscription {
+	sub := &Subscription{
+		g:        g,
+		criteria: criteria,
+		ch:       make(chan *Message, subscriptionQueue),
+	}
+	g.subLock.Lock()
+	g.subs[sub] = struct{}{}
+	g.subLock.Unlock()
+	return sub
+}
+
+// Messages returns the stored messages matching the criteria, ordered by the
+// time they were sent.
+func (g *Gossip) Messages(criteria *Criteria) []*Message {
+	var msgs []*Message
+	for _, env := range g.Envelopes() {
+		pub, err := env.SenderKey(g.isSM2)
+		if err != nil {
+			continue
+		}
+		if msg := criteria.match(env, pub); msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	sortMessages(msgs)
+	return msgs
+}
+
+// expireLoop periodically drops expired envelopes.
+func (g *Gossip) expireLoop() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(expirationCycle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			g.expire(now)
+			g.limiter.prune(now)
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// expire drops the envelopes expired at time now.
+func (g *Gossip) expire(now time.Time) {
+	var expired []*Envelope
+
+	g.lock.Lock()
+	for hash, env := range g.envelopes {
+		if env.Expiry <= uint64(now.Unix()) {
+			delete(g.envelopes, hash)
+			expired = append(expired, env)
+		}
+	}
+	peers := make([]*peer, 0, len(g.peers))
+	for p := range g.peers {
+		peers = append(peers, p)
+	}
+	g.lock.Unlock()
+
+	for _, env := range expired {
+		for _, p := range peers {
+			p.known.Remove(env.Hash())
+		}
+		if g.db != nil {
+			g.db.Delete(envelopeKey(env))
+		}
+	}
+}
+
+// load reads the envelopes persisted by a previous run, dropping the expired
+// ones.
+func (g *Gossip) load() {
+	if g.db == nil {
+		return
+	}
+	now := uint64(time.Now().Unix())
+	it := g.db.NewIteratorWithPrefix(envelopePrefix)
+	defer it.Release()
+
+	for it.Next() {
+		env := new(Envelope)
+		if err := rlp.DecodeBytes(it.Value(), env); err != nil || env.Expiry <= now {
+			g.db.Delete(it.Key())
+			continue
+		}
+		g.envelopes[env.Hash()] = env
+	}
+}
+
+// envelopeKey returns the database key of an envelope.
+func envelopeKey(env *Envelope) []byte {
+	key := make([]byte, len(envelopePrefix)+8, len(envelopePrefix)+8+common.HashLength)
+	copy(key, envelopePrefix)
+	binary.BigEndian.PutUint64(key[len(envelopePrefix):], env.Expiry)
+	return append(key, env.Hash().Bytes()...)
+}
+
+// handlePeer is called by the p2p server for every connected gossip peer.
+func (g *Gossip) handlePeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	gp := newPeer(g, p, rw)
+	if err := gp.handshake(); err != nil {
+		return err
+	}
+	g.lock.Lock()
+	g.peers[gp] = struct{}{}
+	g.lock.Unlock()
+
+	defer func() {
+		g.lock.Lock()
+		delete(g.peers, gp)
+		g.lock.Unlock()
+	}()
+
+	gp.start()
+	defer gp.stop()
+
+	return g.readLoop(gp)
+}
+
+// readLoop processes the messages of a peer until it disconnects.
+func (g *Gossip) readLoop(p *peer) error {
+	for {
+		msg, err := p.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > uint32(maxBatchSize+envelopeOverhead)+g.config.MaxMessageSize {
+			msg.Discard()
+			return fmt.Errorf("oversized message: %d bytes", msg.Size)
+		}
+		switch msg.Code {
+		case envelopesCode:
+			var envs []*Envelope
+			if err := msg.Decode(&envs); err != nil {
+				return fmt.Errorf("invalid envelopes: %v", err)
+			}
+			for _, env := range envs {
+				p.known.Add(env.Hash())
+				switch err := g.add(env, false); err {
+				case nil, errStoreFull:
+				case errRateLimited:
+					// Peers forward what their own limiter let through, so a
+					// few refusals are expected, a flood is not.
+					if p.rateLimited(time.Now()) {
+						return errPeerRateLimited
+					}
+				case errInvalidSig:
+					return err
+				default:
+					log.Trace("Dropped invalid envelope", "peer", p.p.ID(), "hash", env.Hash(), "err", err)
+				}
+			}
+		default:
+			msg.Discard()
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/crypto/sm2"
+	"github.com/filestorm/go-filestorm/fstdb/leveldb"
+	"github.com/filestorm/go-filestorm/fstdb/memorydb"
+	"github.com/filestorm/go-filestorm/p2p"
+	"github.com/filestorm/go-filestorm/p2p/enode"
+)
+
+func TestEnvelope(t *testing.T) {
+	t.Run("secp256k1", func(t *testing.T) {
+		testEnvelope(t, false, func() (*ecdsa.PrivateKey, error) { return crypto.GenerateKey() })
+	})
+	t.Run("sm2", func(t *testing.T) {
+		testEnvelope(t, true, func() (*ecdsa.PrivateKey, error) { return sm2.GenerateKey(rand.Reader) })
+	})
+}
+
+func testEnvelope(t *testing.T, isSM2 bool, generate func() (*ecdsa.PrivateKey, error)) {
+	sender, _ := generate()
+	recipient, _ := generate()
+	other, _ := generate()
+
+	env := NewEnvelope(TopicHash("orders"), 60, []byte("ship 10 units"))
+	if err := env.Encrypt(&recipient.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Sign(sender); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := env.SenderKey(isSM2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(*pub) != crypto.PubkeyToAddress(sender.PublicKey) {
+		t.Fatal("sender mismatch")
+	}
+	if payload, err := env.Decrypt(recipient); err != nil || !bytes.Equal(payload, []byte("ship 10 units")) {
+		t.Fatalf("decryption mismatch: %q, %v", payload, err)
+	}
+	if _, err := env.Decrypt(other); err != errNotRecipient {
+		t.Fatalf("foreign decryption: have %v, want %v", err, errNotRecipient)
+	}
+	// Any change of the signed fields changes the sender.
+	env.TTL++
+	if pub, err := env.SenderKey(isSM2); err == nil && crypto.PubkeyToAddress(*pub) == crypto.PubkeyToAddress(sender.PublicKey) {
+		t.Fatal("tampered envelope kept its sender")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	var (
+		staked = common.Address{1}
+		other  = common.Address{2}
+		now    = time.Now()
+	)
+	l := newLimiter(func(addr common.Address) uint64 {
+		if addr == staked {
+			return 2
+		}
+		return 0
+	})
+	if !l.allow(staked, now) || !l.allow(staked, now) {
+		t.Fatal("envelopes within quota rejected")
+	}
+	if l.allow(staked, now) {
+		t.Fatal("envelope over quota accepted")
+	}
+	if !l.allow(staked, now.Add(30*time.Second)) {
+		t.Fatal("refilled quota rejected")
+	}
+	if l.allow(other, now) {
+		t.Fatal("envelope without quota accepted")
+	}
+	l.prune(now.Add(2 * quotaRefresh))
+	if len(l.buckets) != 0 {
+		t.Fatalf("idle buckets not pruned: %d", len(l.buckets))
+	}
+}
+
+func TestLimiterBuckets(t *testing.T) {
+	var (
+		now    = time.Now()
+		quotas = make(map[common.Address]uint64)
+	)
+	l := newLimiter(func(addr common.Address) uint64 { return quotas[addr] })
+	for i := 0; i < maxBuckets; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		quotas[addr] = 1
+		l.allow(addr, now)
+	}
+	// The buckets of active accounts with quota are kept, new publishers are
+	// refused until they become idle.
+	staked := common.Address{1}
+	quotas[staked] = 1
+	if l.allow(staked, now) || len(l.buckets) != maxBuckets {
+		t.Fatalf("publisher beyond the bucket limit accepted, %d buckets", len(l.buckets))
+	}
+	if !l.allow(staked, now.Add(quotaRefresh)) || len(l.buckets) != 1 {
+		t.Fatalf("publisher refused after the buckets became idle, %d buckets", len(l.buckets))
+	}
+	// Accounts without quota make room for new publishers.
+	for i := 0; i < maxBuckets; i++ {
+		l.allow(common.BigToAddress(big.NewInt(int64(maxBuckets+i))), now)
+	}
+	if len(l.buckets) > maxBuckets {
+		t.Fatalf("bucket count beyond the limit: %d", len(l.buckets))
+	}
+	other := common.Address{2}
+	quotas[other] = 1
+	if !l.allow(other, now) {
+		t.Fatal("publisher refused for buckets of accounts without quota")
+	}
+}
+
+// connect runs the gossip protocol between two nodes until the returned
+// function is called.
+func connect(t *testing.T, a, b *Gossip) func() {
+	rwa, rwb := p2p.MsgPipe()
+	go a.handlePeer(p2p.NewPeer(enode.ID{1}, "b", nil), rwa)
+	go b.handlePeer(p2p.NewPeer(enode.ID{2}, "a", nil), rwb)
+	return func() {
+		rwa.Close()
+		rwb.Close()
+	}
+}
+
+// testConfig gives every account a base quota, the test accounts have no stake.
+var testConfig = func() Config {
+	config := DefaultConfig
+	config.Quota = 6
+	return config
+}()
+
+func newTestGossip(t *testing.T, config *Config) *Gossip {
+	if config == nil {
+		config = &testConfig
+	}
+	g := New(config, nil, memorydb.New(), nil, false)
+	if err := g.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func signedEnvelope(t *testing.T, key *ecdsa.PrivateKey, topic string, payload string) *Envelope {
+	env := NewEnvelope(TopicHash(topic), 60, []byte(payload))
+	if err := env.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func waitMessage(t *testing.T, sub *Subscription) *Message {
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	return nil
+}
+
+func TestGossipForwarding(t *testing.T) {
+	var (
+		a, b, c = newTestGossip(t, nil), newTestGossip(t, nil), newTestGossip(t, nil)
+		key, _  = crypto.GenerateKey()
+	)
+	defer a.Stop()
+	defer b.Stop()
+	defer c.Stop()
+
+	sub := c.Subscribe(&Criteria{Topics: []common.Hash{TopicHash("orders")}})
+	defer sub.Unsubscribe()
+
+	// c is offline while the envelope is published, it receives it from b
+	// when it connects later.
+	disconnect := connect(t, a, b)
+	defer disconnect()
+	if err := a.Send(signedEnvelope(t, key, "invoices", "ignored")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(signedEnvelope(t, key, "orders", "ship 10 units")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.Envelopes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	disconnect = connect(t, b, c)
+	defer disconnect()
+
+	msg := waitMessage(t, sub)
+	if string(msg.Payload) != "ship 10 units" || msg.Sender != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("message mismatch: %+v", msg)
+	}
+	if msgs := c.Messages(&Criteria{}); len(msgs) != 2 {
+		t.Fatalf("stored message count mismatch: have %d, want 2", len(msgs))
+	}
+}
+
+func TestGossipEncrypted(t *testing.T) {
+	var (
+		g            = newTestGossip(t, nil)
+		key, _       = crypto.GenerateKey()
+		recipient, _ = crypto.GenerateKey()
+	)
+	defer g.Stop()
+
+	public := g.Subscribe(&Criteria{})
+	private := g.Subscribe(&Criteria{Key: recipient})
+	defer public.Unsubscribe()
+	defer private.Unsubscribe()
+
+	env := NewEnvelope(TopicHash("orders"), 60, []byte("secret"))
+	env.Encrypt(&recipient.PublicKey)
+	env.Sign(key)
+	if err := g.Send(env); err != nil {
+		t.Fatal(err)
+	}
+	msg := waitMessage(t, private)
+	if string(msg.Payload) != "secret" || msg.Recipient == nil || *msg.Recipient != crypto.PubkeyToAddress(recipient.PublicKey) {
+		t.Fatalf("message mismatch: %+v", msg)
+	}
+	select {
+	case msg := <-public.Messages():
+		t.Fatalf("encrypted message delivered without key: %+v", msg)
+	default:
+	}
+}
+
+func TestGossipValidation(t *testing.T) {
+	config := DefaultConfig
+	config.Quota = 1
+	g := newTestGossip(t, &config)
+	defer g.Stop()
+
+	key, _ := crypto.GenerateKey()
+	if err := g.Send(signedEnvelope(t, key, "orders", "first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Send(signedEnvelope(t, key, "orders", "second")); err != errRateLimited {
+		t.Fatalf("over quota: have %v, want %v", err, errRateLimited)
+	}
+	env := NewEnvelope(TopicHash("orders"), 60, []byte("unsigned"))
+	if err := g.Send(env); err != errInvalidSig {
+		t.Fatalf("unsigned: have %v, want %v", err, errInvalidSig)
+	}
+	env = NewEnvelope(TopicHash("orders"), config.MaxTTL+1, nil)
+	env.Sign(key)
+	if err := g.Send(env); err == nil {
+		t.Fatal("envelope beyond max ttl accepted")
+	}
+	env = NewEnvelope(TopicHash("orders"), 60, make([]byte, config.MaxMessageSize+1))
+	env.Sign(key)
+	if err := g.Send(env); err == nil {
+		t.Fatal("oversized envelope accepted")
+	}
+}
+
+func TestGossipPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gossip-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, _ := leveldb.New(dir, 16, 16, "")
+	g := New(&testConfig, nil, db, nil, false)
+	g.Start(nil)
+
+	key, _ := crypto.GenerateKey()
+	env := signedEnvelope(t, key, "orders", "persisted")
+	if err := g.Send(env); err != nil {
+		t.Fatal(err)
+	}
+	g.Stop()
+
+	db, _ = leveldb.New(dir, 16, 16, "")
+	g = New(&testConfig, nil, db, nil, false)
+	g.Start(nil)
+	defer g.Stop()
+	if envs := g.Envelopes(); len(envs) != 1 || envs[0].Hash() != env.Hash() {
+		t.Fatalf("stored envelopes mismatch: %v", envs)
+	}
+	g.expire(time.Unix(int64(env.Expiry), 0))
+	if envs := g.Envelopes(); len(envs) != 0 {
+		t.Fatalf("expired envelopes kept: %v", envs)
+	}
+	if it := db.NewIteratorWithPrefix(envelopePrefix); it.Next() {
+		t.Fatal("expired envelope kept in database")
+	}
+}
+
+func TestGossipDefaultQuota(t *testing.T) {
+	g := newTestGossip(t, &DefaultConfig)
+	defer g.Stop()
+
+	key, _ := crypto.GenerateKey()
+	if err := g.Send(signedEnvelope(t, key, "orders", "unstaked")); err != errRateLimited {
+		t.Fatalf("account without stake: have %v, want %v", err, errRateLimited)
+	}
+}
+
+func TestGossipRateLimitedPeer(t *testing.T) {
+	g := newTestGossip(t, &DefaultConfig)
+	defer g.Stop()
+
+	rw, remote := p2p.MsgPipe()
+	defer remote.Close()
+	errc := make(chan error, 1)
+	go func() { errc <- g.handlePeer(p2p.NewPeer(enode.ID{1}, "flooder", nil), rw) }()
+	if err := p2p.Send(remote, statusCode, []interface{}{ProtocolVersion}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+		}
+	}()
+	// Every envelope comes from a fresh account without quota.
+	for i := 0; i <= maxRateLimited; i++ {
+		key, _ := crypto.GenerateKey()
+		if err := p2p.Send(remote, envelopesCode, []*Envelope{signedEnvelope(t, key, "spam", "spam")}); err != nil {
+			break
+		}
+	}
+	select {
+	case err := <-errc:
+		if err != errPeerRateLimited {
+			t.Fatalf("peer dropped with %v, want %v", err, errPeerRateLimited)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer relaying rate limited envelopes not dropped")
+	}
+	if envs := g.Envelopes(); len(envs) != 0 {
+		t.Fatalf("rate limited envelopes stored: %d", len(envs))
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+)
+
+// quotaRefresh is the interval the quota of an active account is looked up
+// again at, so stake and validator changes take effect.
+const quotaRefresh = time.Minute
+
+// maxBuckets is the number of accounts rate limited at the same time. New
+// publishers are refused while that many accounts with quota are active.
+const maxBuckets = 16384
+
+// QuotaFunc returns the number of envelopes per minute an account may publish.
+type QuotaFunc func(addr common.Address) uint64
+
+// limiter rate limits publishers with a token bucket per account, holding up
+// to a minute's worth of their quota.
+type limiter struct {
+	quota   QuotaFunc
+	lock    sync.Mutex
+	buckets map[common.Address]*bucket
+}
+
+type bucket struct {
+	tokens  float64   // Envelopes the account may publish right now
+	quota   uint64    // Envelopes per minute
+	updated time.Time // Time the tokens were last refilled
+	checked time.Time // Time the quota was last looked up
+}
+
+func newLimiter(quota QuotaFunc) *limiter {
+	return &limiter{
+		quota:   quota,
+		buckets: make(map[common.Address]*bucket),
+	}
+}
+
+// allow reports whether addr may publish another envelope at time now and
+// takes the envelope off its quota if so.
+func (l *limiter) allow(addr common.Address, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	b := l.buckets[addr]
+	if b == nil {
+		if len(l.buckets) >= maxBuckets && !l.evict(now) {
+			return false
+		}
+		quota := l.quota(addr)
+		b = &bucket{tokens: float64(quota), quota: quota, updated: now, checked: now}
+		l.buckets[addr] = b
+	}
+	if now.Sub(b.checked) >= quotaRefresh {
+		b.quota, b.checked = l.quota(addr), now
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens += elapsed.Minutes() * float64(b.quota)
+		if b.tokens > float64(b.quota) {
+			b.tokens = float64(b.quota)
+		}
+		b.updated = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets of accounts that didn't publish for a while, their
+// buckets would be full again anyway.
+func (l *limiter) prune(now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for addr, b := range l.buckets {
+		if now.Sub(b.updated) >= quotaRefresh {
+			delete(l.buckets, addr)
+		}
+	}
+}
+
+// evict makes room for a new bucket by dropping the idle buckets and the ones
+// of accounts without quota, which only save looking their quota up again. It
+// reports whether there is room now.
+func (l *limiter) evict(now time.Time) bool {
+	for addr, b := range l.buckets {
+		if b.quota == 0 || now.Sub(b.updated) >= quotaRefresh {
+			delete(l.buckets, addr)
+		}
+	}
+	return len(l.buckets) < maxBuckets
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The go-filestorm Authors
+// This file is part of the go-filestorm library.
+//
+// The go-filestorm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-filestorm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-filestorm library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"fmt"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/filestorm/go-filestorm/log"
+	"github.com/filestorm/go-filestorm/p2p"
+	"github.com/filestorm/go-filestorm/rlp"
+)
+
+// peer represents a gossip protocol peer connection.
+type peer struct {
+	host *Gossip
+	p    *p2p.Peer
+	rw   p2p.MsgReadWriter
+
+	known mapset.Set // Envelopes already known by the peer to avoid wasting bandwidth
+	quit  chan struct{}
+
+	limited      int       // Rate limited envelopes relayed since limitedSince
+	limitedSince time.Time // Start of the minute the rate limited envelopes are counted in
+}
+
+// newPeer creates a new gossip peer object, but does not run the handshake itself.
+func newPeer(host *Gossip, remote *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		host:  host,
+		p:     remote,
+		rw:    rw,
+		known: mapset.NewSet(),
+		quit:  make(chan struct{}),
+	}
+}
+
+// start initiates the peer updater, periodically sending the stored envelopes
+// the peer doesn't know yet. Peers connecting late receive all unexpired
+// envelopes this way.
+func (p *peer) start() {
+	go p.update()
+	log.Trace("Gossip peer started", "peer", p.p.ID())
+}
+
+// stop terminates the peer updater.
+func (p *peer) stop() {
+	close(p.quit)
+	log.Trace("Gossip peer stopped", "peer", p.p.ID())
+}
+
+// handshake exchanges the protocol versions with the remote peer.
+func (p *peer) handshake() error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p2p.Send(p.rw, statusCode, []interface{}{ProtocolVersion})
+	}()
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != statusCode {
+		return fmt.Errorf("peer [%x] sent packet %x before status packet", p.p.ID(), msg.Code)
+	}
+	s := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := s.List(); err != nil {
+		return fmt.Errorf("peer [%x] sent bad status message: %v", p.p.ID(), err)
+	}
+	version, err := s.Uint()
+	if err != nil {
+		return fmt.Errorf("peer [%x] sent bad status message: %v", p.p.ID(), err)
+	}
+	if version != ProtocolVersion {
+		return fmt.Errorf("peer [%x]: protocol version mismatch %d != %d", p.p.ID(), version, ProtocolVersion)
+	}
+	// Ignore the remaining status fields (forward-compatibility)
+	msg.Discard()
+
+	if err := <-errc; err != nil {
+		return fmt.Errorf("peer [%x] failed to send status packet: %v", p.p.ID(), err)
+	}
+	return nil
+}
+
+// rateLimited counts an envelope relayed by the peer that exceeded the quota of
+// its publisher and reports whether the peer relayed more than maxRateLimited
+// of them within a minute.
+func (p *peer) rateLimited(now time.Time) bool {
+	if now.Sub(p.limitedSince) >= time.Minute {
+		p.limited, p.limitedSince = 0, now
+	}
+	p.limited++
+	return p.limited > maxRateLimited
+}
+
+// update periodically sends the unknown envelopes to the peer.
+func (p *peer) update() {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.broadcast(); err != nil {
+				log.Trace("Gossip broadcast failed", "peer", p.p.ID(), "err", err)
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// broadcast sends the envelopes the peer doesn't know yet in batches.
+func (p *peer) broadcast() error {
+	var (
+		batch []*Envelope
+		size  int
+	)
+	for _, env := range p.host.Envelopes() {
+		if p.known.Contains(env.Hash()) {
+			continue
+		}
+		batch = append(batch, env)
+		size += len(env.Payload) + envelopeOverhead
+		if size >= maxBatchSize {
+			if err := p.send(batch); err != nil {
+				return err
+			}
+			batch, size = nil, 0
+		}
+	}
+	if len(batch) > 0 {
+		return p.send(batch)
+	}
+	return nil
+}
+
+func (p *peer) send(batch []*Envelope) error {
+	if err := p2p.Send(p.rw, envelopesCode, batch); err != nil {
+		return err
+	}
+	for _, env := range batch {
+		p.known.Add(env.Hash())
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	resty "gopkg.in/resty.v1"
+)
+
+// headers carrying the request signature
+const (
+	AuthTimestampHeader = "X-Storm-Timestamp"
+	AuthNonceHeader     = "X-Storm-Nonce"
+	AuthSignatureHeader = "X-Storm-Signature"
+)
+
+var (
+	errMissingSignature = errors.New("missing request signature")
+	errInvalidSignature = errors.New("invalid request signature")
+	errStaleTimestamp   = errors.New("request timestamp out of the accepted window")
+	errReplayedNonce    = errors.New("request nonce already used")
+	errNotFileOwner     = errors.New("signer is not the owner of the file")
+	errNotOperator      = errors.New("signer is not an operator")
+	errFileStored       = errors.New("file is already stored and has no owner")
+)
+
+// nonceLock makes the nonce check and record atomic within this process
+var nonceLock sync.Mutex
+
+// operatorKey signs requests sent to peer stormcatchers, loaded from operator-key-file
+var operatorKey *ecdsa.PrivateKey
+
+func (a AccessType) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Remove:
+		return "delete"
+	case Verify:
+		return "verify"
+	}
+	return "unknown"
+}
+
+// authMessageHash returns the EIP-191 personal message hash signed by clients:
+// keccak256("\x19Ethereum Signed Message:\n" + len(message) + message), where the
+// message is "stormcatcher\n<method>\n<file hash>\n<timestamp>\n<nonce>".
+func authMessageHash(method string, fileHash string, timestamp int64, nonce string) []byte {
+	message := fmt.Sprintf("stormcatcher\n%s\n%s\n%d\n%s", method, fileHash, timestamp, nonce)
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+}
+
+// signRequest adds the signature headers to a request sent with the given key.
+func signRequest(req *resty.Request, key *ecdsa.PrivateKey, method string, fileHash string) error {
+	timestamp := time.Now().Unix()
+	nonceBuf := make([]byte, 16)
+	if _, err := rand.Read(nonceBuf); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBuf)
+	sig, err := crypto.Sign(authMessageHash(method, fileHash, timestamp, nonce), key)
+	if err != nil {
+		return err
+	}
+	sig[64] += 27
+	req.SetHeader(AuthTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.SetHeader(AuthNonceHeader, nonce)
+	req.SetHeader(AuthSignatureHeader, "0x"+hex.EncodeToString(sig))
+	return nil
+}
+
+// recoverSigner returns the lower case hex address which signed the request.
+func recoverSigner(r *http.Request, method string, fileHash string) (string, error) {
+	timestampString := r.Header.Get(AuthTimestampHeader)
+	nonce := r.Header.Get(AuthNonceHeader)
+	sigString := r.Header.Get(AuthSignatureHeader)
+	if timestampString == "" || nonce == "" || sigString == "" {
+		return "", errMissingSignature
+	}
+	if len(nonce) > 64 {
+		return "", errInvalidSignature
+	}
+
+	timestamp, err := strconv.ParseInt(timestampString, 10, 64)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+	now := time.Now().Unix()
+	if timestamp < now-authMaxSkew || timestamp > now+authMaxSkew {
+		return "", errStaleTimestamp
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(sigString, "0x"))
+	if err != nil || len(sig) != 65 {
+		return "", errInvalidSignature
+	}
+	// accept both 0/1 and 27/28 recovery ids
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.Ecrecover(authMessageHash(method, fileHash, timestamp, nonce), sig)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+	pubKey, err := crypto.UnmarshalPubkey(pub)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+	signer := strings.ToLower(crypto.PubkeyToAddress(*pubKey).Hex())
+
+	if err := useNonce(signer, nonce, timestamp); err != nil {
+		return "", err
+	}
+	return signer, nil
+}
+
+// useNonce records the nonce of a signer, a nonce can only be used once within the timestamp window.
+func useNonce(signer string, nonce string, timestamp int64) error {
+	nonceLock.Lock()
+	defer nonceLock.Unlock()
+	member := signer + "/" + nonce
+	if _, err := metadataStore.SortedSetScore(IpfsAuthNonceName, member); err == nil {
+		return errReplayedNonce
+	}
+	return metadataStore.SortedSetAdd(IpfsAuthNonceName, member, timestamp)
+}
+
+func isOperator(address string) bool {
+	for _, operator := range operatorAddresses {
+		if operator == address {
+			return true
+		}
+	}
+	return false
+}
+
+// getFileOwner returns the address recorded as owner when the file was first
+// written or uploaded to this node, "" if not recorded.
+func getFileOwner(originalFileHash string) (string, error) {
+	return metadataStore.HashGet(IpfsFileOwnerName, originalFileHash)
+}
+
+func setFileOwner(originalFileHash string, owner string) error {
+	return metadataStore.HashSet(IpfsFileOwnerName, originalFileHash, owner)
+}
+
+func deleteFileOwner(originalFileHash string) error {
+	_, err := metadataStore.HashDelete(IpfsFileOwnerName, originalFileHash)
+	return err
+}
+
+// fileOwner returns the owner of the file, "" if it has none. With storage deals
+// enabled the deal owner on the storage contract is authoritative, the locally
+// recorded owner only counts for files which have no deal on chain.
+func fileOwner(originalFileHash string) (string, error) {
+	if dealContract != nil {
+		terms, err := dealContract.GetDeal(originalFileHash)
+		if err != nil {
+			return "", err
+		}
+		if terms.Owner != (common.Address{}) {
+			return strings.ToLower(terms.Owner.Hex()), nil
+		}
+	}
+	return getFileOwner(originalFileHash)
+}
+
+// isFileStored tells whether this node keeps the file, as a copy or as a shard.
+func isFileStored(originalFileHash string) bool {
+	return GetAlteredFileHash(originalFileHash) != "" || isShardedFile(originalFileHash)
+}
+
+// authorize checks the request signature and whether the signer can access the file.
+// Operators can access any file, other signers only the files they own. A file
+// without owner can only be written when it is not stored on this node yet, the
+// signer then becomes its owner: content already stored, like files copied from
+// peers by repair, never changes owner through a write. It returns the signer, and
+// false with the error response already sent if the request is rejected.
+func authorize(w http.ResponseWriter, r *http.Request, accessType AccessType, fileHash string) (string, bool) {
+	if !authEnabled {
+		return "", true
+	}
+
+	signer, err := recoverSigner(r, accessType.String(), fileHash)
+	if err != nil {
+		log.Info("Rejected request", accessType, fileHash, err)
+		http.Error(w, err.Error(), 401)
+		return "", false
+	}
+	if isOperator(signer) {
+		return signer, true
+	}
+
+	owner, err := fileOwner(fileHash)
+	if err != nil {
+		log.Info("Can not get file owner", fileHash, err)
+		http.Error(w, "Can not get file owner.", 500)
+		return "", false
+	}
+	if owner != "" && owner == signer {
+		return signer, true
+	}
+	rejection := errNotFileOwner
+	if owner == "" && accessType == Write {
+		if !isFileStored(fileHash) {
+			return signer, true
+		}
+		rejection = errFileStored
+	}
+	log.Info("Rejected request", accessType, fileHash, signer, rejection)
+	http.Error(w, rejection.Error(), 403)
+	return "", false
+}
+
+// authorizeAccount checks the request signature for routes which are not about a
+// single file. Only the given account and operators are accepted, with an empty
+// account only operators are, which is used for listings of every file.
+func authorizeAccount(w http.ResponseWriter, r *http.Request, method string, account string) bool {
+	if !authEnabled {
+		return true
+	}
+
+	signer, err := recoverSigner(r, method, account)
+	if err != nil {
+		log.Info("Rejected request", method, account, err)
+		http.Error(w, err.Error(), 401)
+		return false
+	}
+	if isOperator(signer) || (account != "" && signer == account) {
+		return true
+	}
+	log.Info("Rejected request", method, account, signer, errNotFileOwner)
+	http.Error(w, errNotFileOwner.Error(), 403)
+	return false
+}
+
+// authorizeOperator checks the request signature and only accepts operators, it
+// guards the routes peers call to store data on each other.
+func authorizeOperator(w http.ResponseWriter, r *http.Request, accessType AccessType, fileHash string) bool {
+	if !authEnabled {
+		return true
+	}
+
+	signer, err := recoverSigner(r, accessType.String(), fileHash)
+	if err != nil {
+		log.Info("Rejected request", accessType, fileHash, err)
+		http.Error(w, err.Error(), 401)
+		return false
+	}
+	if !isOperator(signer) {
+		log.Info("Rejected request", accessType, fileHash, signer, errNotOperator)
+		http.Error(w, errNotOperator.Error(), 403)
+		return false
+	}
+	return true
+}
+
+// claimFileOwner records the signer as owner of a written file if it has none yet,
+// it is only called for files which were not stored before the write.
+func claimFileOwner(originalFileHash string, signer string) {
+	if signer == "" || isOperator(signer) {
+		return
+	}
+	if owner, err := getFileOwner(originalFileHash); err == nil && owner == "" {
+		setFileOwner(originalFileHash, signer)
+	}
+}
+
+func pruneAuthNonces() {
+	expired, err := metadataStore.SortedSetRangeByScore(IpfsAuthNonceName, 0, time.Now().Unix()-authMaxSkew-1)
+	if err != nil {
+		log.Info("Failed getting expired nonces.", err)
+		return
+	}
+	for _, member := range expired {
+		metadataStore.SortedSetRemove(IpfsAuthNonceName, member)
+	}
+}
+
+func initAuth() {
+	operatorAddresses = []string{}
+	for _, address := range strings.Split(operatorAddressesString, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			operatorAddresses = append(operatorAddresses, strings.ToLower(address))
+		}
+	}
+	if operatorKeyFile != "" {
+		key, err := crypto.LoadECDSA(operatorKeyFile)
+		if err != nil {
+			log.Fatal("Can not load operator key", operatorKeyFile, err)
+		}
+		operatorKey = key
+	}
+	if !authEnabled {
+		log.Warning("Request authentication is disabled, anyone can read, write and delete files")
+	}
+}
+
+func initAuthWorker() {
+	// nonces older than the timestamp window can not be replayed anymore
+	go func() {
+		for {
+			time.Sleep(time.Duration(authMaxSkew) * time.Second)
+			pruneAuthNonces()
+		}
+	}()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+	resty "gopkg.in/resty.v1"
+)
+
+func signedTestRequest(key *ecdsa.PrivateKey, path string, method string, fileHash string) *http.Request {
+	req := httptest.NewRequest("GET", path, nil)
+	if key != nil {
+		signed := resty.R()
+		signRequest(signed, key, method, fileHash)
+		for k := range signed.Header {
+			req.Header.Set(k, signed.Header.Get(k))
+		}
+	}
+	return req
+}
+
+func keyAddress(key *ecdsa.PrivateKey) string {
+	return strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).Hex())
+}
+
+func TestAuth(t *testing.T) {
+	Convey("Test signed request authentication", t, func() {
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&authEnabled, true)
+
+		owner, _ := crypto.GenerateKey()
+		other, _ := crypto.GenerateKey()
+		operator, _ := crypto.GenerateKey()
+		stubs.Stub(&operatorAddresses, []string{keyAddress(operator)})
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		serve := func(handler http.HandlerFunc, req *http.Request) int {
+			w := httptest.NewRecorder()
+			handler(w, req)
+			return w.Code
+		}
+		writePath := "/ipfs/write?file_hash=" + fileHash
+		deletePath := "/ipfs/delete?file_hash=" + fileHash
+
+		Convey("Unsigned requests are rejected", func() {
+			So(serve(writeHandler, signedTestRequest(nil, writePath, "write", fileHash)), ShouldEqual, 401)
+			So(serve(deleteHandler, signedTestRequest(nil, deletePath, "delete", fileHash)), ShouldEqual, 401)
+		})
+
+		Convey("First writer becomes the owner", func() {
+			So(serve(writeHandler, signedTestRequest(owner, writePath, "write", fileHash)), ShouldEqual, 200)
+			recorded, _ := getFileOwner(fileHash)
+			So(recorded, ShouldEqual, keyAddress(owner))
+
+			Convey("Others can not delete it", func() {
+				So(serve(deleteHandler, signedTestRequest(other, deletePath, "delete", fileHash)), ShouldEqual, 403)
+				So(serve(writeHandler, signedTestRequest(other, writePath, "write", fileHash)), ShouldEqual, 403)
+			})
+
+			Convey("Owner can delete it", func() {
+				So(serve(deleteHandler, signedTestRequest(owner, deletePath, "delete", fileHash)), ShouldEqual, 200)
+			})
+
+			Convey("Operators can delete it", func() {
+				So(serve(deleteHandler, signedTestRequest(operator, deletePath, "delete", fileHash)), ShouldEqual, 200)
+			})
+
+			Convey("Signature for another method is not accepted", func() {
+				So(serve(deleteHandler, signedTestRequest(owner, deletePath, "read", fileHash)), ShouldEqual, 403)
+			})
+		})
+
+		Convey("Data routes require a signature", func() {
+			filesPath := "/files/" + fileHash
+			So(serve(gatewayHandler, signedTestRequest(nil, filesPath, "read", fileHash)), ShouldEqual, 401)
+			So(serve(shardGetHandler, signedTestRequest(nil, "/shard/get?shard_id=0&file_hash="+fileHash, "read", fileHash)), ShouldEqual, 401)
+			So(serve(shardManifestHandler, signedTestRequest(nil, "/shard/manifest?file_hash="+fileHash, "read", fileHash)), ShouldEqual, 401)
+			So(serve(replicaHasHandler, signedTestRequest(nil, "/replica/has?file_hash="+fileHash, "read", fileHash)), ShouldEqual, 401)
+			So(serve(replicaHealthHandler, signedTestRequest(nil, "/replica/health", "health", "")), ShouldEqual, 401)
+
+			dealsPath := "/deals?owner=" + keyAddress(owner)
+			So(serve(dealsHandler, signedTestRequest(nil, dealsPath, "deals", keyAddress(owner))), ShouldEqual, 401)
+			So(serve(dealsHandler, signedTestRequest(other, dealsPath, "deals", keyAddress(owner))), ShouldEqual, 403)
+			So(serve(dealsHandler, signedTestRequest(owner, dealsPath, "deals", keyAddress(owner))), ShouldEqual, 200)
+
+			Convey("Only the owner and operators can read a file", func() {
+				So(serve(writeHandler, signedTestRequest(owner, writePath, "write", fileHash)), ShouldEqual, 200)
+				So(serve(gatewayHandler, signedTestRequest(other, filesPath, "read", fileHash)), ShouldEqual, 403)
+				// authorized, but not stored on this node
+				So(serve(gatewayHandler, signedTestRequest(owner, filesPath, "read", fileHash)), ShouldEqual, 404)
+				So(serve(gatewayHandler, signedTestRequest(operator, filesPath, "read", fileHash)), ShouldEqual, 404)
+			})
+
+			Convey("Only operators can list file health", func() {
+				So(serve(replicaHealthHandler, signedTestRequest(owner, "/replica/health", "health", "")), ShouldEqual, 403)
+				So(serve(replicaHealthHandler, signedTestRequest(operator, "/replica/health", "health", "")), ShouldEqual, 200)
+			})
+		})
+
+		Convey("Files without owner can not be deleted", func() {
+			So(serve(deleteHandler, signedTestRequest(other, deletePath, "delete", fileHash)), ShouldEqual, 403)
+		})
+
+		Convey("Stored files without owner can not be claimed by writing them", func() {
+			updateFileHashMapping(fileHash, "QmW8ubjTcjVz2VKn497bEZ5wQaLPS6chLU5DaQSq3NWMa1")
+			So(serve(writeHandler, signedTestRequest(other, writePath, "write", fileHash)), ShouldEqual, 403)
+			recorded, _ := getFileOwner(fileHash)
+			So(recorded, ShouldEqual, "")
+			So(serve(deleteHandler, signedTestRequest(other, deletePath, "delete", fileHash)), ShouldEqual, 403)
+			So(serve(writeHandler, signedTestRequest(operator, writePath, "write", fileHash)), ShouldEqual, 200)
+		})
+
+		Convey("The deal owner on the storage contract decides", func() {
+			contract := &testDealContract{deals: map[string]*DealTerms{}, balances: map[common.Address]*big.Int{}}
+			contract.deals[fileHash] = &DealTerms{Owner: crypto.PubkeyToAddress(owner.PublicKey)}
+			dealContract = contract
+			defer func() { dealContract = nil }()
+			// a stale local record does not matter once the file has a deal
+			setFileOwner(fileHash, keyAddress(other))
+
+			So(serve(writeHandler, signedTestRequest(other, writePath, "write", fileHash)), ShouldEqual, 403)
+			So(serve(deleteHandler, signedTestRequest(other, deletePath, "delete", fileHash)), ShouldEqual, 403)
+			So(serve(deleteHandler, signedTestRequest(owner, deletePath, "delete", fileHash)), ShouldEqual, 200)
+
+			contract.err = errors.New("rpc down")
+			So(serve(deleteHandler, signedTestRequest(owner, deletePath, "delete", fileHash)), ShouldEqual, 500)
+		})
+
+		Convey("Verify requests are signed", func() {
+			verifyPath := "/verify?offset=0&file_hash=" + fileHash
+			So(serve(verifyHandler, signedTestRequest(nil, verifyPath, "verify", fileHash)), ShouldEqual, 401)
+			So(serve(writeHandler, signedTestRequest(owner, writePath, "write", fileHash)), ShouldEqual, 200)
+			So(serve(verifyHandler, signedTestRequest(other, verifyPath, "verify", fileHash)), ShouldEqual, 403)
+			// authorized, but not stored on this node
+			So(serve(verifyHandler, signedTestRequest(owner, verifyPath, "verify", fileHash)), ShouldEqual, 404)
+			So(serve(verifyHandler, signedTestRequest(operator, verifyPath, "verify", fileHash)), ShouldEqual, 404)
+		})
+
+		Convey("Replayed requests are rejected", func() {
+			req := signedTestRequest(owner, writePath, "write", fileHash)
+			replay := httptest.NewRequest("GET", writePath, nil)
+			replay.Header = req.Header
+			So(serve(writeHandler, req), ShouldEqual, 200)
+			So(serve(writeHandler, replay), ShouldEqual, 401)
+		})
+
+		Convey("Stale requests are rejected", func() {
+			req := signedTestRequest(owner, writePath, "write", fileHash)
+			req.Header.Set(AuthTimestampHeader, strconv.FormatInt(time.Now().Unix()-authMaxSkew-10, 10))
+			So(serve(writeHandler, req), ShouldEqual, 401)
+		})
+
+		Convey("Expired nonces are pruned", func() {
+			useNonce(keyAddress(owner), "old", time.Now().Unix()-authMaxSkew-10)
+			useNonce(keyAddress(owner), "new", time.Now().Unix())
+			pruneAuthNonces()
+			So(useNonce(keyAddress(owner), "old", time.Now().Unix()), ShouldBeNil)
+			So(useNonce(keyAddress(owner), "new", time.Now().Unix()), ShouldEqual, errReplayedNonce)
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// blob store names accepted by --blob-store
+const (
+	IpfsBlobStore = "ipfs"
+	FsBlobStore   = "fs"
+	S3BlobStore   = "s3"
+)
+
+// BlobStat describes a stored blob.
+type BlobStat struct {
+	Size   int64
+	Pinned bool
+}
+
+// BlobStore keeps the altered file content. Every implementation is content addressed:
+// Put returns the id the content can be fetched with later.
+type BlobStore interface {
+	// Put stores the content, pins it and returns its id.
+	Put(r io.Reader) (string, error)
+	Get(id string) (io.ReadCloser, error)
+	// Range reads at most length bytes starting at offset.
+	Range(id string, offset int64, length int64) ([]byte, error)
+	// Delete unpins the content, the space may only be reclaimed by the next GC.
+	Delete(id string) error
+	Pin(id string) error
+	GC() error
+	Stat(id string) (*BlobStat, error)
+	// Ping checks the store can be reached.
+	Ping() error
+}
+
+var blobStore BlobStore
+
+// initBlobStore sets up blobStore according to blobStoreType.
+func initBlobStore() {
+	store, err := newBlobStore(blobStoreType)
+	if err != nil {
+		log.Fatal("Can not init blob store", blobStoreType, err)
+	}
+	blobStore = store
+}
+
+func newBlobStore(storeType string) (BlobStore, error) {
+	switch storeType {
+	case IpfsBlobStore:
+		return newIpfsBlobStore(ipfsHostPort), nil
+	case FsBlobStore:
+		return newFsBlobStore(blobStorePath)
+	case S3BlobStore:
+		return newS3BlobStore(s3Endpoint, s3Region, s3Bucket, s3AccessKey, s3SecretKey), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", storeType)
+	}
+}
+
+// checkoutBlob copies the blob into a new tmp file.
+func checkoutBlob(id string) (*os.File, error) {
+	r, err := blobStore.Get(id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer r.Close()
+
+	tmpfile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmpfile, r); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return nil, err
+	}
+	return tmpfile, nil
+}
+
+// isBlobPinned reports whether the blob exists and is pinned in the blob store.
+func isBlobPinned(id string) bool {
+	stat, err := blobStore.Stat(id)
+	return err == nil && stat.Pinned
+}
+
+// checkInFile adds the tmp file to the blob store and returns its id.
+// If originalFileHash is "", it's not to checkin new written file but to restore
+// original file, so there is no need to update the hash mapping.
+func checkInFile(tmpfile *os.File, originalFileHash string) (string, error) {
+	tmpfile.Seek(0, 0)
+	id, err := blobStore.Put(tmpfile)
+	if err != nil {
+		return "", err
+	}
+	if originalFileHash == "" {
+		return id, nil
+	}
+	if err := updateFileHashMapping(originalFileHash, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// deleteStoredFile unpins a stored file, it is removed from storage by the next gc.
+func deleteStoredFile(fileHash string, clearCache bool) error {
+	id := fileHash
+
+	// if it's to delete altered file which is the 'real' delete instead of
+	// clearing restored file during file read
+	if !clearCache {
+		id = GetAlteredFileHash(fileHash)
+	}
+	err := blobStore.Delete(id)
+	log.Info(fmt.Sprintf("delete/unpin %s, clearCache=%v: %v", id, clearCache, err))
+	return err
+}
+
+func readStoredFileChunk(originalFileHash string, offset int64, limit int64) []byte {
+	alteredFileHash := GetAlteredFileHash(originalFileHash)
+	b, err := blobStore.Range(alteredFileHash, offset, limit)
+	log.Info("verify read", alteredFileHash, offset, limit, err)
+	if err != nil {
+		return []byte{}
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newS3StandIn starts a minimal S3 compatible server keeping objects in memory.
+// It supports the object calls used by s3BlobStore and checks the credential and payload hash.
+func newS3StandIn(accessKey string) *httptest.Server {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+accessKey+"/") {
+			http.Error(w, "AccessDenied", 403)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			h := sha256.Sum256(body)
+			if hex.EncodeToString(h[:]) != r.Header.Get("x-amz-content-sha256") {
+				http.Error(w, "XAmzContentSHA256Mismatch", 400)
+				return
+			}
+			objects[r.URL.Path] = body
+		case "GET", "HEAD":
+			if strings.Count(r.URL.Path, "/") == 1 {
+				// bucket
+				return
+			}
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.Error(w, "NoSuchKey", 404)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+		case "DELETE":
+			delete(objects, r.URL.Path)
+			w.WriteHeader(204)
+		}
+	}))
+}
+
+func testBlobStore(store BlobStore) {
+	content := generateTestFileContent(1000)
+
+	Convey("Store can be reached", func() {
+		So(store.Ping(), ShouldBeNil)
+	})
+
+	Convey("Put returns the content address", func() {
+		id, err := store.Put(bytes.NewReader(content))
+		So(err, ShouldBeNil)
+		h := sha256.Sum256(content)
+		So(id, ShouldEqual, hex.EncodeToString(h[:]))
+
+		// same content, same id
+		id2, _ := store.Put(bytes.NewReader(content))
+		So(id2, ShouldEqual, id)
+	})
+
+	Convey("Get, Range and Stat", func() {
+		id, _ := store.Put(bytes.NewReader(content))
+		r, err := store.Get(id)
+		So(err, ShouldBeNil)
+		got, _ := ioutil.ReadAll(r)
+		r.Close()
+		So(bytes.Equal(got, content), ShouldBeTrue)
+
+		chunk, err := store.Range(id, 995, 10)
+		So(err, ShouldBeNil)
+		So(string(chunk), ShouldEqual, "56789")
+		chunk, _ = store.Range(id, 10, 3)
+		So(string(chunk), ShouldEqual, "012")
+
+		stat, err := store.Stat(id)
+		So(err, ShouldBeNil)
+		So(stat.Size, ShouldEqual, 1000)
+		So(stat.Pinned, ShouldBeTrue)
+		So(store.Pin(id), ShouldBeNil)
+	})
+
+	Convey("Delete", func() {
+		id, _ := store.Put(bytes.NewReader(content))
+		So(store.Delete(id), ShouldBeNil)
+		So(store.GC(), ShouldBeNil)
+		_, err := store.Stat(id)
+		So(err, ShouldNotBeNil)
+		So(store.Pin(id), ShouldNotBeNil)
+	})
+
+	Convey("Invalid ids are rejected", func() {
+		_, err := store.Get("../../etc/passwd")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestFsBlobStore(t *testing.T) {
+	Convey("Test fs blob store", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_fs_blob_test_")
+		defer os.RemoveAll(dir)
+		store, err := newFsBlobStore(dir)
+		So(err, ShouldBeNil)
+		testBlobStore(store)
+	})
+}
+
+func TestS3BlobStore(t *testing.T) {
+	Convey("Test s3 blob store", t, func() {
+		server := newS3StandIn("test_access_key")
+		defer server.Close()
+		store := newS3BlobStore(server.URL, "us-east-1", "stormcatcher", "test_access_key", "test_secret_key")
+		testBlobStore(store)
+
+		Convey("Requests with a wrong key are refused", func() {
+			badStore := newS3BlobStore(server.URL, "us-east-1", "stormcatcher", "wrong_key", "test_secret_key")
+			_, err := badStore.Put(bytes.NewReader([]byte("hello")))
+			So(fmt.Sprint(err), ShouldContainSubstring, "403")
+		})
+	})
+}
@@ -1,11 +1,45 @@
 package main
 
 // will be set in flags
+var configFile string
 var listenAddressAndPort string
 var redisHostPort string
 var ipfsHostPort string
+var storeBackend string
+var storePath string
+var blobStoreType string
+var blobStorePath string
+var s3Endpoint string
+var s3Region string
+var s3Bucket string
+var s3AccessKey string
+var s3SecretKey string
+var ecDataShards int // 0 keeps a whole altered copy of each file
+var ecParityShards int
+var nodeShardId int
+var shardPeersString string
+var shardPeers []string // stormcatcher host:port of each shard id
+var replicationFactor int
+var replicationPeersString string
+var replicationPeers []string
+var authEnabled bool
+var operatorAddressesString string
+var operatorAddresses []string // lower case hex addresses allowed to access any file
+var operatorKeyFile string
+var chainRPC string
+var dealContractAddress string
+var dealWarnPeriod int64  // in seconds
+var dealGracePeriod int64 // in seconds
+var uploadPath string
+var uploadMaxSize int64 // in bytes, 0 is unlimited
 var queueConcurrency = 10
+var queueConcurrencies map[string]int            // per queue concurrency, overrides queueConcurrency
+var shutdownTimeout = 60                         // in seconds
 var ipfsGCInterval = 100                         // in seconds
 var ipfsUnpinInterval = 100                      // in seconds
 var unpinInterval = 60                           // in seconds
 var RestoredFileUnpinInterval = int64(3600 * 24) // in seconds
+var repairInterval = 600                         // in seconds
+var authMaxSkew = int64(300)                     // in seconds, accepted age of a signed request
+var dealInterval = 3600                          // in seconds
+var uploadExpiry = int64(3600 * 24)              // in seconds, uploads are deleted after it
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/naoina/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// These settings are the same as in cmd/storm of go-filestorm: keys are the field names.
+var tomlSettings = toml.Config{
+	NormFieldName: func(rt reflect.Type, key string) string {
+		return key
+	},
+	FieldToKey: func(rt reflect.Type, field string) string {
+		return field
+	},
+	MissingField: func(rt reflect.Type, field string) error {
+		return fmt.Errorf("field '%s' is not defined in %s", field, rt.String())
+	},
+}
+
+type ServerConfig struct {
+	ListenHostPort  string
+	ShutdownTimeout int // in seconds, time given to tasks in flight on shutdown
+}
+
+type StoreConfig struct {
+	Backend       string
+	Path          string
+	RedisHostPort string
+}
+
+type BlobStoreConfig struct {
+	Type         string
+	Path         string
+	IpfsHostPort string
+	S3Endpoint   string
+	S3Region     string
+	S3Bucket     string
+	S3AccessKey  string
+	S3SecretKey  string
+}
+
+type ErasureCodingConfig struct {
+	DataShards   int
+	ParityShards int
+	ShardId      int
+	Peers        []string
+}
+
+type ReplicationConfig struct {
+	Factor int
+	Peers  []string
+}
+
+type AuthConfig struct {
+	Enabled           bool
+	OperatorAddresses []string
+	OperatorKeyFile   string
+}
+
+type DealsConfig struct {
+	ChainRPC    string
+	Contract    string
+	WarnPeriod  int64 // in seconds
+	GracePeriod int64 // in seconds
+}
+
+type UploadsConfig struct {
+	Path    string
+	MaxSize int64 // in bytes, 0 is unlimited
+	Expiry  int64 // in seconds
+}
+
+// IntervalsConfig sets the periods of the background workers, in seconds, it is reloaded on SIGHUP.
+type IntervalsConfig struct {
+	GC                int
+	Unpin             int
+	RestoredFileUnpin int64
+	Repair            int
+	Deal              int
+}
+
+// QueuesConfig sets the number of workers of each queue, it is reloaded on SIGHUP.
+type QueuesConfig struct {
+	Concurrency int
+	PerQueue    map[string]int `toml:",omitempty"`
+}
+
+// Config is the layout of the file given with --config, a TOML file or, with a .yaml
+// or .yml extension, a YAML file using the same keys. Flags set on the command line
+// take precedence over the file.
+type Config struct {
+	Server        ServerConfig
+	Store         StoreConfig
+	BlobStore     BlobStoreConfig
+	ErasureCoding ErasureCodingConfig
+	Replication   ReplicationConfig
+	Auth          AuthConfig
+	Deals         DealsConfig
+	Uploads       UploadsConfig
+	Intervals     IntervalsConfig
+	Queues        QueuesConfig
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// currentConfig returns the settings in use, which are the defaults a config file overrides.
+func currentConfig() *Config {
+	return &Config{
+		Server: ServerConfig{ListenHostPort: listenAddressAndPort, ShutdownTimeout: shutdownTimeout},
+		Store:  StoreConfig{Backend: storeBackend, Path: storePath, RedisHostPort: redisHostPort},
+		BlobStore: BlobStoreConfig{
+			Type:         blobStoreType,
+			Path:         blobStorePath,
+			IpfsHostPort: ipfsHostPort,
+			S3Endpoint:   s3Endpoint,
+			S3Region:     s3Region,
+			S3Bucket:     s3Bucket,
+			S3AccessKey:  s3AccessKey,
+			S3SecretKey:  s3SecretKey,
+		},
+		ErasureCoding: ErasureCodingConfig{
+			DataShards:   ecDataShards,
+			ParityShards: ecParityShards,
+			ShardId:      nodeShardId,
+			Peers:        splitList(shardPeersString),
+		},
+		Replication: ReplicationConfig{Factor: replicationFactor, Peers: splitList(replicationPeersString)},
+		Auth: AuthConfig{
+			Enabled:           authEnabled,
+			OperatorAddresses: splitList(operatorAddressesString),
+			OperatorKeyFile:   operatorKeyFile,
+		},
+		Deals: DealsConfig{
+			ChainRPC:    chainRPC,
+			Contract:    dealContractAddress,
+			WarnPeriod:  dealWarnPeriod,
+			GracePeriod: dealGracePeriod,
+		},
+		Uploads: UploadsConfig{Path: uploadPath, MaxSize: uploadMaxSize, Expiry: uploadExpiry},
+		Intervals: IntervalsConfig{
+			GC:                ipfsGCInterval,
+			Unpin:             ipfsUnpinInterval,
+			RestoredFileUnpin: RestoredFileUnpinInterval,
+			Repair:            repairInterval,
+			Deal:              dealInterval,
+		},
+		Queues: QueuesConfig{Concurrency: queueConcurrency, PerQueue: queueConcurrencies},
+	}
+}
+
+func (cfg *Config) apply() {
+	listenAddressAndPort = cfg.Server.ListenHostPort
+	shutdownTimeout = cfg.Server.ShutdownTimeout
+	storeBackend = cfg.Store.Backend
+	storePath = cfg.Store.Path
+	redisHostPort = cfg.Store.RedisHostPort
+	blobStoreType = cfg.BlobStore.Type
+	blobStorePath = cfg.BlobStore.Path
+	ipfsHostPort = cfg.BlobStore.IpfsHostPort
+	s3Endpoint = cfg.BlobStore.S3Endpoint
+	s3Region = cfg.BlobStore.S3Region
+	s3Bucket = cfg.BlobStore.S3Bucket
+	s3AccessKey = cfg.BlobStore.S3AccessKey
+	s3SecretKey = cfg.BlobStore.S3SecretKey
+	ecDataShards = cfg.ErasureCoding.DataShards
+	ecParityShards = cfg.ErasureCoding.ParityShards
+	nodeShardId = cfg.ErasureCoding.ShardId
+	shardPeersString = strings.Join(cfg.ErasureCoding.Peers, ",")
+	replicationFactor = cfg.Replication.Factor
+	replicationPeersString = strings.Join(cfg.Replication.Peers, ",")
+	authEnabled = cfg.Auth.Enabled
+	operatorAddressesString = strings.Join(cfg.Auth.OperatorAddresses, ",")
+	operatorKeyFile = cfg.Auth.OperatorKeyFile
+	chainRPC = cfg.Deals.ChainRPC
+	dealContractAddress = cfg.Deals.Contract
+	dealWarnPeriod = cfg.Deals.WarnPeriod
+	dealGracePeriod = cfg.Deals.GracePeriod
+	uploadPath = cfg.Uploads.Path
+	uploadMaxSize = cfg.Uploads.MaxSize
+	uploadExpiry = cfg.Uploads.Expiry
+	ipfsGCInterval = cfg.Intervals.GC
+	ipfsUnpinInterval = cfg.Intervals.Unpin
+	RestoredFileUnpinInterval = cfg.Intervals.RestoredFileUnpin
+	repairInterval = cfg.Intervals.Repair
+	dealInterval = cfg.Intervals.Deal
+	queueConcurrency = cfg.Queues.Concurrency
+	queueConcurrencies = cfg.Queues.PerQueue
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Uploads.Expiry < 24 {
+		return errors.New("Uploads.Expiry must be at least 24 seconds")
+	}
+	if cfg.Queues.Concurrency < 1 {
+		return errors.New("Queues.Concurrency must be at least 1")
+	}
+	for queueName, limit := range cfg.Queues.PerQueue {
+		if _, ok := queueHandlers[queueName]; !ok {
+			return fmt.Errorf("unknown queue %q in Queues.PerQueue", queueName)
+		}
+		if limit < 0 {
+			return fmt.Errorf("negative concurrency for queue %q", queueName)
+		}
+	}
+	return nil
+}
+
+func loadConfig(file string, cfg *Config) error {
+	var err error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = loadYAMLConfig(file, cfg)
+	default:
+		err = loadTOMLConfig(file, cfg)
+	}
+	if err != nil {
+		return err
+	}
+	return cfg.validate()
+}
+
+func loadTOMLConfig(file string, cfg *Config) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = tomlSettings.NewDecoder(bufio.NewReader(f)).Decode(cfg)
+	// Add file name to errors that have a line number.
+	if _, ok := err.(*toml.LineError); ok {
+		err = errors.New(file + ", " + err.Error())
+	}
+	return err
+}
+
+// loadYAMLConfig decodes a YAML file through JSON, so the keys are the field names
+// like in TOML files and unknown keys are rejected as well.
+func loadYAMLConfig(file string, cfg *Config) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return errors.New(file + ", " + err.Error())
+	}
+	if doc == nil {
+		return nil
+	}
+	mDoc, err := json.Marshal(yamlToJSON(doc))
+	if err != nil {
+		return errors.New(file + ", " + err.Error())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(mDoc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return errors.New(file + ", " + err.Error())
+	}
+	return nil
+}
+
+// yamlToJSON converts the maps decoded by yaml, which have interface keys, to maps with string keys.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = yamlToJSON(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = yamlToJSON(value)
+		}
+	}
+	return v
+}
+
+// explicitFlags returns the flags given on the command line with their value.
+var explicitFlags = func() map[string]string {
+	flags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	return flags
+}
+
+// initConfig applies the config file, if any, then the flags given on the command line again.
+func initConfig() {
+	if configFile == "" {
+		return
+	}
+	flags := explicitFlags()
+	cfg := currentConfig()
+	if err := loadConfig(configFile, cfg); err != nil {
+		log.Fatal("Can not load config file", err)
+	}
+	cfg.apply()
+	for name, value := range flags {
+		flag.Set(name, value)
+	}
+	log.Info("Loaded config file", configFile)
+}
+
+// reloadConfig applies the queue limits and the worker intervals of the config file
+// to the running node, other settings only change on restart.
+func reloadConfig() {
+	if configFile == "" {
+		log.Info("No config file to reload")
+		return
+	}
+	cfg := currentConfig()
+	if err := loadConfig(configFile, cfg); err != nil {
+		log.Error("Can not reload config file, keeping current settings", err)
+		return
+	}
+	ipfsGCInterval = cfg.Intervals.GC
+	ipfsUnpinInterval = cfg.Intervals.Unpin
+	RestoredFileUnpinInterval = cfg.Intervals.RestoredFileUnpin
+	repairInterval = cfg.Intervals.Repair
+	dealInterval = cfg.Intervals.Deal
+	queueConcurrency = cfg.Queues.Concurrency
+	queueConcurrencies = cfg.Queues.PerQueue
+	for name, value := range explicitFlags() {
+		flag.Set(name, value)
+	}
+	applyQueueLimits()
+	log.Info("Reloaded queue limits and intervals from", configFile)
+}
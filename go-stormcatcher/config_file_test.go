@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeTestConfig(content string) string {
+	f, _ := ioutil.TempFile("", "stormcatcher_config_test_")
+	f.WriteString(content)
+	f.Close()
+	return f.Name()
+}
+
+func TestConfigFile(t *testing.T) {
+	Convey("Test config file", t, func() {
+		stubs := Stub(&queueConcurrency, 10)
+		defer stubs.Reset()
+		stubs.Stub(&queueConcurrencies, map[string]int(nil))
+		stubs.Stub(&ipfsGCInterval, ipfsGCInterval)
+		stubs.Stub(&replicationPeersString, "")
+		stubs.Stub(&storeBackend, storeBackend)
+		stubs.Stub(&shutdownTimeout, shutdownTimeout)
+
+		Convey("Load and apply a config file", func() {
+			file := writeTestConfig(`
+[Server]
+ShutdownTimeout = 30
+
+[Store]
+Backend = "memory"
+
+[Replication]
+Peers = ["127.0.0.1:18081", "127.0.0.1:18082"]
+
+[Intervals]
+GC = 600
+
+[Queues]
+Concurrency = 4
+
+[Queues.PerQueue]
+ipfs_write_queue = 8
+`)
+			defer os.Remove(file)
+			cfg := currentConfig()
+			So(loadConfig(file, cfg), ShouldBeNil)
+			cfg.apply()
+			So(shutdownTimeout, ShouldEqual, 30)
+			So(storeBackend, ShouldEqual, MemoryBackend)
+			So(replicationPeersString, ShouldEqual, "127.0.0.1:18081,127.0.0.1:18082")
+			So(ipfsGCInterval, ShouldEqual, 600)
+			So(queueLimit(IpfsWriteQueueName), ShouldEqual, 8)
+			So(queueLimit(IpfsReadQueueName), ShouldEqual, 4)
+		})
+
+		Convey("Load a YAML config file", func() {
+			file := writeTestConfig(`
+Server:
+  ShutdownTimeout: 30
+Replication:
+  Peers: ["127.0.0.1:18081", "127.0.0.1:18082"]
+Queues:
+  Concurrency: 4
+  PerQueue:
+    ipfs_write_queue: 8
+`)
+			defer os.Remove(file)
+			yamlFile := file + ".yaml"
+			os.Rename(file, yamlFile)
+			defer os.Remove(yamlFile)
+			before := currentConfig()
+			cfg := currentConfig()
+			So(loadConfig(yamlFile, cfg), ShouldBeNil)
+			So(cfg.Server.ShutdownTimeout, ShouldEqual, 30)
+			So(cfg.Replication.Peers, ShouldResemble, []string{"127.0.0.1:18081", "127.0.0.1:18082"})
+			So(cfg.Queues.Concurrency, ShouldEqual, 4)
+			So(cfg.Queues.PerQueue, ShouldResemble, map[string]int{IpfsWriteQueueName: 8})
+			So(cfg.Intervals, ShouldResemble, before.Intervals)
+
+			ioutil.WriteFile(yamlFile, []byte("Server:\n  NoSuchSetting: 1\n"), 0644)
+			So(loadConfig(yamlFile, currentConfig()), ShouldNotBeNil)
+		})
+
+		Convey("Queue limits and intervals are reloaded", func() {
+			file := writeTestConfig("[Intervals]\nGC = 900\n[Queues]\nConcurrency = 5\n")
+			defer os.Remove(file)
+			stubs.Stub(&configFile, file)
+			stubs.StubFunc(&explicitFlags, map[string]string{})
+			reloadConfig()
+			So(ipfsGCInterval, ShouldEqual, 900)
+			So(queueConcurrency, ShouldEqual, 5)
+		})
+
+		Convey("Settings missing in the file keep their value", func() {
+			file := writeTestConfig("[Queues]\nConcurrency = 3\n")
+			defer os.Remove(file)
+			before := currentConfig()
+			cfg := currentConfig()
+			So(loadConfig(file, cfg), ShouldBeNil)
+			So(cfg.Queues.Concurrency, ShouldEqual, 3)
+			So(cfg.Server, ShouldResemble, before.Server)
+			So(cfg.Intervals, ShouldResemble, before.Intervals)
+		})
+
+		Convey("Flags given on the command line take precedence", func() {
+			file := writeTestConfig("[Queues]\nConcurrency = 3\n")
+			defer os.Remove(file)
+			stubs.Stub(&configFile, file)
+			stubs.StubFunc(&explicitFlags, map[string]string{"queue-concurrency": "6"})
+			initConfig()
+			So(queueConcurrency, ShouldEqual, 6)
+			reloadConfig()
+			So(queueConcurrency, ShouldEqual, 6)
+		})
+
+		Convey("Invalid config files are rejected", func() {
+			for _, content := range []string{
+				"[Queues]\nConcurrency = 0\n",
+				"[Queues]\nConcurrency = 2\n[Queues.PerQueue]\nno_such_queue = 1\n",
+				"[Queues]\nConcurrency = 2\n[Queues.PerQueue]\nipfs_read_queue = -1\n",
+				"[Server]\nNoSuchSetting = 1\n",
+			} {
+				file := writeTestConfig(content)
+				defer os.Remove(file)
+				So(loadConfig(file, currentConfig()), ShouldNotBeNil)
+			}
+			So(loadConfig("/nonexistent/stormcatcher.toml", currentConfig()), ShouldNotBeNil)
+		})
+	})
+}
@@ -8,6 +8,16 @@ var IpfsFileHashStatName = "ipfs_file_hash_stat"
 var IpfsProxyReadQueueName = "ipfs_proxy_read_queue"
 var IpfsProxyWriteQueueName = "ipfs_proxy_write_queue"
 var IpfsUnpinFileHashQueueName = "ipfs_unpin_filehash_queue"
+var IpfsShardWriteQueueName = "ipfs_shard_write_queue"
+var IpfsShardReadQueueName = "ipfs_shard_read_queue"
+var IpfsShardManifestName = "ipfs_shard_manifest"
+var IpfsFileHealthName = "ipfs_file_health"
+var IpfsFileOwnerName = "ipfs_file_owner"
+var IpfsAuthNonceName = "ipfs_auth_nonce"
+var IpfsStorageDealName = "ipfs_storage_deal"
+var IpfsUploadName = "ipfs_upload"
+var IpfsUploadQueueName = "ipfs_upload_queue"
+var IpfsInFlightTaskName = "ipfs_in_flight_task"
 var IpfsPrefix = "ipfs_tmp_"
 var IpfsChunkSize = int64(16 * 1024)  // in bytes
 var ipfsVerifyReadLength = int64(256) // in bytes
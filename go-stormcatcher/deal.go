@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/fstclient"
+	validator "gopkg.in/validator.v2"
+)
+
+// storage deal status reported by the deal scheduler
+const (
+	DealActive   = "active"
+	DealExpiring = "expiring" // within the warn period before paid until
+	DealGrace    = "grace"    // expired, kept for the grace period
+	DealExpired  = "expired"  // delete enqueued
+
+	DealUnregistered = "unregistered" // no deal on chain, kept until deleted
+)
+
+// dealContractABI is the part of solidity/FileStormManager/FileStormStorage.sol used by stormcatcher.
+const dealContractABI = `[
+{"constant":true,"inputs":[{"name":"","type":"string"}],"name":"deals","outputs":[{"name":"owner","type":"address"},{"name":"paidUntil","type":"uint256"},{"name":"price","type":"uint256"},{"name":"period","type":"uint256"},{"name":"autoRenew","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[{"name":"","type":"address"}],"name":"balances","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":false,"inputs":[{"name":"fileHash","type":"string"}],"name":"renew","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"},
+{"constant":false,"inputs":[{"name":"fileHash","type":"string"},{"name":"fileOwner","type":"address"},{"name":"size","type":"uint256"}],"name":"register","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"}
+]`
+
+var errNoOperatorKey = errors.New("operator key is required to send transactions")
+
+// DealTerms is a deal as recorded by the storage contract, the owner is the zero address if the file has no deal.
+type DealTerms struct {
+	Owner     common.Address
+	PaidUntil *big.Int
+	Price     *big.Int
+	Period    *big.Int
+	AutoRenew bool
+}
+
+// DealContract reads, renews and registers storage deals on chain.
+type DealContract interface {
+	GetDeal(fileHash string) (*DealTerms, error)
+	Balance(owner common.Address) (*big.Int, error)
+	Renew(fileHash string) error
+	Register(fileHash string, owner common.Address, size int64) error
+}
+
+// Deal is the local view of the storage deal of a stored file.
+type Deal struct {
+	Filehash  string `json:"file_hash"`
+	Owner     string `json:"owner"`
+	PaidUntil int64  `json:"paid_until"`
+	AutoRenew bool   `json:"auto_renew"`
+	Status    string `json:"status"`
+	WarnedAt  int64  `json:"warned_at,omitempty"`
+	CheckedAt int64  `json:"checked_at"`
+}
+
+type DealsRequest struct {
+	Owner string `validate:"regexp=^0x[0-9a-fA-F]{40}$"`
+}
+
+// dealContract is nil when storage deals are not enabled
+var dealContract DealContract
+
+type chainDealContract struct {
+	contract *bind.BoundContract
+}
+
+func newChainDealContract(rpcURL string, address string) (*chainDealContract, error) {
+	client, err := fstclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(strings.NewReader(dealContractABI))
+	if err != nil {
+		return nil, err
+	}
+	contract := bind.NewBoundContract(common.HexToAddress(address), parsed, client, client, client)
+	return &chainDealContract{contract: contract}, nil
+}
+
+func (c *chainDealContract) GetDeal(fileHash string) (*DealTerms, error) {
+	terms := new(DealTerms)
+	if err := c.contract.Call(nil, terms, "deals", fileHash); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func (c *chainDealContract) Balance(owner common.Address) (*big.Int, error) {
+	balance := new(*big.Int)
+	if err := c.contract.Call(nil, balance, "balances", owner); err != nil {
+		return nil, err
+	}
+	return *balance, nil
+}
+
+func (c *chainDealContract) Renew(fileHash string) error {
+	if operatorKey == nil {
+		return errNoOperatorKey
+	}
+	tx, err := c.contract.Transact(bind.NewKeyedTransactor(operatorKey), "renew", fileHash)
+	if err != nil {
+		return err
+	}
+	log.Info("Sent deal renew transaction", fileHash, tx.Hash().Hex())
+	return nil
+}
+
+func (c *chainDealContract) Register(fileHash string, owner common.Address, size int64) error {
+	if operatorKey == nil {
+		return errNoOperatorKey
+	}
+	tx, err := c.contract.Transact(bind.NewKeyedTransactor(operatorKey), "register", fileHash, owner, big.NewInt(size))
+	if err != nil {
+		return err
+	}
+	log.Info("Sent file register transaction", fileHash, owner.Hex(), tx.Hash().Hex())
+	return nil
+}
+
+func saveDeal(deal *Deal) error {
+	mDeal, _ := json.Marshal(deal)
+	return metadataStore.HashSet(IpfsStorageDealName, deal.Filehash, string(mDeal))
+}
+
+func getDeal(originalFileHash string) (*Deal, error) {
+	result, err := metadataStore.HashGet(IpfsStorageDealName, originalFileHash)
+	if err != nil {
+		return nil, err
+	}
+	if result == "" {
+		return nil, errKeyNotFound
+	}
+	deal := new(Deal)
+	if err := json.Unmarshal([]byte(result), deal); err != nil {
+		return nil, err
+	}
+	return deal, nil
+}
+
+func deleteDeal(originalFileHash string) error {
+	_, err := metadataStore.HashDelete(IpfsStorageDealName, originalFileHash)
+	return err
+}
+
+// refreshDeal reads the deal from the storage contract. It returns false if the
+// file has no deal on chain, in which case only the owner is updated from the
+// local record, and an error if the contract can not be read.
+func refreshDeal(deal *Deal) (bool, error) {
+	terms, err := dealContract.GetDeal(deal.Filehash)
+	if err != nil {
+		return false, err
+	}
+	if terms.Owner == (common.Address{}) {
+		if owner, err := getFileOwner(deal.Filehash); err == nil {
+			deal.Owner = owner
+		}
+		return false, nil
+	}
+	deal.Owner = strings.ToLower(terms.Owner.Hex())
+	deal.PaidUntil = terms.PaidUntil.Int64()
+	deal.AutoRenew = terms.AutoRenew
+	return true, nil
+}
+
+// renewDeal renews the deal from the prepaid balance of its owner when it allows.
+func renewDeal(deal *Deal) bool {
+	terms, err := dealContract.GetDeal(deal.Filehash)
+	if err != nil || !terms.AutoRenew || terms.Owner == (common.Address{}) {
+		return false
+	}
+	balance, err := dealContract.Balance(terms.Owner)
+	if err != nil {
+		log.Info("Can not get prepaid balance", deal.Filehash, deal.Owner, err)
+		return false
+	}
+	if balance.Cmp(terms.Price) < 0 {
+		log.Info("Prepaid balance too low to renew deal", deal.Filehash, deal.Owner)
+		return false
+	}
+	if err := dealContract.Renew(deal.Filehash); err != nil {
+		log.Error("Can not renew deal", deal.Filehash, err)
+		return false
+	}
+	return true
+}
+
+// warnDeal tells about a deal running out, once per warn period.
+func warnDeal(deal *Deal, now int64) {
+	if deal.WarnedAt >= deal.PaidUntil-dealWarnPeriod {
+		return
+	}
+	log.Warning("Storage deal running out", deal.Filehash, "owner", deal.Owner, "paid until", time.Unix(deal.PaidUntil, 0))
+	deal.WarnedAt = now
+}
+
+// checkDeal moves the deal of a stored file through its lifecycle. Deals running
+// out are renewed when possible, and files are deleted after the grace period.
+// A file is only deleted once the contract has been read and shows its deal
+// expired: while the contract can not be read the previous status is kept, and
+// files without deal on chain are never deleted.
+func checkDeal(originalFileHash string) *Deal {
+	now := time.Now().Unix()
+	deal, err := getDeal(originalFileHash)
+	if err != nil {
+		deal = &Deal{Filehash: originalFileHash}
+	}
+
+	if deal.PaidUntil-dealWarnPeriod <= now {
+		// the owner may have paid since the last check
+		onChain, err := refreshDeal(deal)
+		if err != nil {
+			log.Info("Can not get deal from contract, keep status", originalFileHash, deal.Status, err)
+			return deal
+		}
+		deal.CheckedAt = now
+		if !onChain {
+			if deal.Status != DealUnregistered {
+				log.Warning("Stored file has no storage deal on chain", originalFileHash, "owner", deal.Owner)
+			}
+			deal.Status = DealUnregistered
+			saveDeal(deal)
+			return deal
+		}
+		// a renewal shows up at the next check, once its transaction is mined
+		if deal.PaidUntil-dealWarnPeriod <= now && deal.AutoRenew {
+			renewDeal(deal)
+		}
+	}
+	deal.CheckedAt = now
+
+	switch {
+	case now < deal.PaidUntil-dealWarnPeriod:
+		deal.Status = DealActive
+	case now < deal.PaidUntil:
+		deal.Status = DealExpiring
+		warnDeal(deal, now)
+	case now < deal.PaidUntil+dealGracePeriod:
+		deal.Status = DealGrace
+		warnDeal(deal, now)
+	default:
+		deal.Status = DealExpired
+		log.Info("Storage deal expired, delete file", originalFileHash, deal.Owner)
+		if err := AddTaskToQueue(IpfsDeleteQueueName, originalFileHash); err != nil {
+			log.Error("Can not enqueue delete of expired file", originalFileHash, err)
+		}
+	}
+	saveDeal(deal)
+	return deal
+}
+
+func runDealScheduler() {
+	fileHashes, err := metadataStore.HashKeys(IpfsFileHashStatName)
+	if err != nil {
+		log.Info("Failed getting file stats.", err)
+		return
+	}
+	manifestHashes, err := metadataStore.HashKeys(IpfsShardManifestName)
+	if err != nil {
+		log.Info("Failed getting shard manifests.", err)
+		return
+	}
+	for _, fileHash := range append(fileHashes, manifestHashes...) {
+		// shard stats are covered by their manifest
+		if strings.Contains(fileHash, "/") {
+			continue
+		}
+		checkDeal(fileHash)
+	}
+}
+
+func initDeals() {
+	if chainRPC == "" || dealContractAddress == "" {
+		log.Info("Storage deals disabled, stored files are kept until deleted")
+		return
+	}
+	contract, err := newChainDealContract(chainRPC, dealContractAddress)
+	if err != nil {
+		log.Fatal("Can not connect to deal contract", chainRPC, dealContractAddress, err)
+	}
+	dealContract = contract
+	log.Info("Storage deals:", dealContractAddress, "warn", dealWarnPeriod, "grace", dealGracePeriod)
+}
+
+func initDealWorker() {
+	if dealContract == nil {
+		return
+	}
+	// check storage deals periodically
+	go func() {
+		for {
+			runDealScheduler()
+			time.Sleep(time.Duration(dealInterval) * time.Second)
+		}
+	}()
+}
+
+func dealsHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/deals?owner=0x53e5c08cb895599e7cfa5da58a783a56e9f140db"
+	// with auth enabled the request is signed by the owner with the "deals" method and the owner as file hash
+	dealsRequest := DealsRequest{Owner: r.URL.Query().Get("owner")}
+	if errs := validator.Validate(dealsRequest); errs != nil || dealsRequest.Owner == "" {
+		http.Error(w, fmt.Sprintf("Invalid owner parameter: %v", errs), 400)
+		return
+	}
+	owner := strings.ToLower(dealsRequest.Owner)
+	if !authorizeAccount(w, r, "deals", owner) {
+		return
+	}
+
+	fileHashes, err := metadataStore.HashKeys(IpfsStorageDealName)
+	if err != nil {
+		http.Error(w, "Can not list deals.", 500)
+		return
+	}
+	deals := []*Deal{}
+	for _, fileHash := range fileHashes {
+		if deal, err := getDeal(fileHash); err == nil && deal.Owner == owner {
+			deals = append(deals, deal)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deals)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testDealContract struct {
+	err        error
+	deals      map[string]*DealTerms
+	balances   map[common.Address]*big.Int
+	renewed    []string
+	registered []string
+}
+
+func (c *testDealContract) GetDeal(fileHash string) (*DealTerms, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if terms, ok := c.deals[fileHash]; ok {
+		return terms, nil
+	}
+	return &DealTerms{PaidUntil: big.NewInt(0), Price: big.NewInt(0), Period: big.NewInt(0)}, nil
+}
+
+func (c *testDealContract) Balance(owner common.Address) (*big.Int, error) {
+	if balance, ok := c.balances[owner]; ok {
+		return balance, nil
+	}
+	return big.NewInt(0), nil
+}
+
+func (c *testDealContract) Renew(fileHash string) error {
+	c.renewed = append(c.renewed, fileHash)
+	return nil
+}
+
+func (c *testDealContract) Register(fileHash string, owner common.Address, size int64) error {
+	c.registered = append(c.registered, fileHash)
+	c.deals[fileHash] = &DealTerms{Owner: owner, PaidUntil: big.NewInt(time.Now().Unix()), Price: big.NewInt(1), Period: big.NewInt(1)}
+	return nil
+}
+
+func TestDeal(t *testing.T) {
+	Convey("Test storage deal lifecycle", t, func() {
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		// signed requests are covered by TestAuth
+		stubs.Stub(&authEnabled, false)
+		stubs.Stub(&dealWarnPeriod, int64(100))
+		stubs.Stub(&dealGracePeriod, int64(50))
+
+		owner := common.HexToAddress("0x53e5c08cb895599e7cfa5da58a783a56e9f140db")
+		contract := &testDealContract{deals: map[string]*DealTerms{}, balances: map[common.Address]*big.Int{}}
+		// stubbing a nil interface can not be reset
+		dealContract = contract
+		defer func() { dealContract = nil }()
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		updateFileHashStat(fileHash, FileHashStat{Size: 15})
+		now := time.Now().Unix()
+		setTerms := func(paidUntil int64, autoRenew bool) {
+			contract.deals[fileHash] = &DealTerms{
+				Owner:     owner,
+				PaidUntil: big.NewInt(paidUntil),
+				Price:     big.NewInt(10),
+				Period:    big.NewInt(1000),
+				AutoRenew: autoRenew,
+			}
+		}
+
+		Convey("Paid deal is active", func() {
+			setTerms(now+1000, false)
+			deal := checkDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealActive)
+			So(deal.Owner, ShouldEqual, strings.ToLower(owner.Hex()))
+			So(deal.WarnedAt, ShouldEqual, 0)
+		})
+
+		Convey("Deal running out is warned", func() {
+			setTerms(now+10, false)
+			deal := checkDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealExpiring)
+			So(deal.WarnedAt, ShouldBeGreaterThan, 0)
+			So(len(contract.renewed), ShouldEqual, 0)
+		})
+
+		Convey("Deal running out is renewed from prepaid balance", func() {
+			setTerms(now+10, true)
+			contract.balances[owner] = big.NewInt(10)
+			checkDeal(fileHash)
+			So(contract.renewed, ShouldResemble, []string{fileHash})
+		})
+
+		Convey("Deal is not renewed without enough balance", func() {
+			setTerms(now+10, true)
+			contract.balances[owner] = big.NewInt(9)
+			checkDeal(fileHash)
+			So(len(contract.renewed), ShouldEqual, 0)
+		})
+
+		Convey("Expired deal is kept during the grace period", func() {
+			setTerms(now-10, false)
+			deal := checkDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealGrace)
+			length, _ := taskQueue.Len(IpfsDeleteQueueName)
+			So(length, ShouldEqual, 0)
+		})
+
+		Convey("Expired deal is deleted after the grace period", func() {
+			setTerms(now-60, false)
+			runDealScheduler()
+			deal, _ := getDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealExpired)
+			task, _ := taskQueue.PopBlock(IpfsDeleteQueueName, 0)
+			So(task, ShouldEqual, fileHash)
+		})
+
+		Convey("File without deal on chain is kept", func() {
+			deal := checkDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealUnregistered)
+			runDealScheduler()
+			length, _ := taskQueue.Len(IpfsDeleteQueueName)
+			So(length, ShouldEqual, 0)
+		})
+
+		Convey("Status is kept while the contract can not be read", func() {
+			setTerms(now+10, false)
+			So(checkDeal(fileHash).Status, ShouldEqual, DealExpiring)
+			contract.err = errors.New("connection refused")
+			// the file would be past its grace period if the chain was not read again
+			stubs.Stub(&dealGracePeriod, int64(-100))
+			deal := checkDeal(fileHash)
+			So(deal.Status, ShouldEqual, DealExpiring)
+			length, _ := taskQueue.Len(IpfsDeleteQueueName)
+			So(length, ShouldEqual, 0)
+
+			Convey("File without local record is not deleted either", func() {
+				deleteDeal(fileHash)
+				runDealScheduler()
+				length, _ := taskQueue.Len(IpfsDeleteQueueName)
+				So(length, ShouldEqual, 0)
+			})
+		})
+
+		Convey("Deals are listed by owner", func() {
+			setTerms(now+1000, false)
+			checkDeal(fileHash)
+
+			w := httptest.NewRecorder()
+			dealsHandler(w, httptest.NewRequest("GET", "/deals?owner="+owner.Hex(), nil))
+			So(w.Code, ShouldEqual, 200)
+			deals := []*Deal{}
+			json.Unmarshal(w.Body.Bytes(), &deals)
+			So(len(deals), ShouldEqual, 1)
+			So(deals[0].Filehash, ShouldEqual, fileHash)
+
+			w = httptest.NewRecorder()
+			dealsHandler(w, httptest.NewRequest("GET", "/deals?owner=0x0000000000000000000000000000000000000001", nil))
+			So(w.Body.String(), ShouldEqual, "[]\n")
+
+			w = httptest.NewRecorder()
+			dealsHandler(w, httptest.NewRequest("GET", "/deals?owner=bad", nil))
+			So(w.Code, ShouldEqual, 400)
+		})
+	})
+}
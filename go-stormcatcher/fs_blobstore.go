@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var sha256IdRegexp = regexp.MustCompile("^[0-9a-f]{64}$")
+
+var errInvalidBlobId = errors.New("invalid blob id")
+
+var fsBlobTmpFileExpiry = 3600 // in seconds
+
+// fsBlobStore keeps blobs in a local directory, addressed by the sha256 of their content.
+// blobs live in <root>/blobs/<first 2 hex chars>/<id>, tmp files in <root>/tmp.
+// There is no pin set: a stored blob is pinned, Delete removes it right away and GC
+// only cleans up tmp files left by interrupted writes.
+type fsBlobStore struct {
+	root string
+}
+
+func newFsBlobStore(root string) (*fsBlobStore, error) {
+	for _, dir := range []string{"blobs", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &fsBlobStore{root: root}, nil
+}
+
+func (s *fsBlobStore) blobPath(id string) (string, error) {
+	if !sha256IdRegexp.MatchString(id) {
+		return "", errInvalidBlobId
+	}
+	return filepath.Join(s.root, "blobs", id[:2], id), nil
+}
+
+func (s *fsBlobStore) Put(r io.Reader) (string, error) {
+	tmpfile, err := ioutil.TempFile(filepath.Join(s.root, "tmp"), IpfsPrefix)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmpfile, h), r); err != nil {
+		tmpfile.Close()
+		return "", err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(h.Sum(nil))
+	path, _ := s.blobPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpfile.Name(), path); err != nil {
+		return "", err
+	}
+	log.Info("fs blob added", id)
+	return id, nil
+}
+
+func (s *fsBlobStore) Get(id string) (io.ReadCloser, error) {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *fsBlobStore) Range(id string, offset int64, length int64) ([]byte, error) {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (s *fsBlobStore) Delete(id string) error {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fsBlobStore) Pin(id string) error {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(path)
+	return err
+}
+
+func (s *fsBlobStore) GC() error {
+	tmpFiles, err := ioutil.ReadDir(filepath.Join(s.root, "tmp"))
+	if err != nil {
+		return err
+	}
+	// skip recent tmp files, they may belong to a Put in progress
+	cutoff := time.Now().Add(-time.Duration(fsBlobTmpFileExpiry) * time.Second)
+	for _, f := range tmpFiles {
+		if f.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(s.root, "tmp", f.Name()))
+		}
+	}
+	return nil
+}
+
+func (s *fsBlobStore) Ping() error {
+	_, err := os.Stat(filepath.Join(s.root, "blobs"))
+	return err
+}
+
+func (s *fsBlobStore) Stat(id string) (*BlobStat, error) {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobStat{Size: fi.Size(), Pinned: true}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	validator "gopkg.in/validator.v2"
+)
+
+var gatewayReadSize = int64(1024 * 1024) // in bytes, max restored bytes fetched per blob store call
+
+var errInvalidSeek = errors.New("invalid seek position")
+
+// restoredFileReader restores the original content of an altered blob on the fly.
+// The altered blob is made of IpfsChunkSize chunks, each one an 8 bytes random
+// header followed by IpfsChunkSize-8 bytes of the original file, so any original
+// offset maps to a known altered offset and only the needed chunks are fetched.
+type restoredFileReader struct {
+	id     string // altered blob id
+	size   int64  // size of the original file
+	offset int64  // read position in the original file
+}
+
+func newRestoredFileReader(alteredFileHash string, size int64) *restoredFileReader {
+	return &restoredFileReader{id: alteredFileHash, size: size}
+}
+
+func (r *restoredFileReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	want := int64(len(p))
+	if want > r.size-r.offset {
+		want = r.size - r.offset
+	}
+	if want > gatewayReadSize {
+		want = gatewayReadSize
+	}
+	if want == 0 {
+		return 0, nil
+	}
+
+	dataPerChunk := IpfsChunkSize - 8
+	startChunk := r.offset / dataPerChunk
+	endChunk := (r.offset + want - 1) / dataPerChunk
+	alteredSize := r.size + 8*((r.size+dataPerChunk-1)/dataPerChunk)
+	alteredStart := startChunk * IpfsChunkSize
+	alteredEnd := (endChunk + 1) * IpfsChunkSize
+	if alteredEnd > alteredSize {
+		alteredEnd = alteredSize
+	}
+
+	buf, err := blobStore.Range(r.id, alteredStart, alteredEnd-alteredStart)
+	if err != nil {
+		return 0, err
+	}
+
+	// strip the chunk headers
+	n := int64(0)
+	for chunk := startChunk; chunk <= endChunk && n < want; chunk++ {
+		from := (chunk-startChunk)*IpfsChunkSize + 8
+		if chunk == startChunk {
+			from += r.offset % dataPerChunk
+		}
+		to := (chunk-startChunk)*IpfsChunkSize + IpfsChunkSize
+		if to > int64(len(buf)) {
+			to = int64(len(buf))
+		}
+		if from >= to {
+			break
+		}
+		if to-from > want-n {
+			to = from + want - n
+		}
+		n += int64(copy(p[n:], buf[from:to]))
+	}
+	if n == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.offset += n
+	bytesReadTotal.Add(float64(n))
+	return int(n), nil
+}
+
+func (r *restoredFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errInvalidSeek
+	}
+	if offset < 0 {
+		return 0, errInvalidSeek
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func gatewayHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/files/QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	// curl -H "Range: bytes=0-1023" "http://127.0.0.1:18080/files/QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN?filename=movie.mp4"
+	// with auth enabled the request is signed with the "read" method like /ipfs/read
+	fileHash := strings.TrimPrefix(r.URL.Path, "/files/")
+	readRequest := ReadRequest{Filehash: fileHash}
+	if errs := validator.Validate(readRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid file hash: %v", errs), 400)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed.", 405)
+		return
+	}
+	if _, ok := authorize(w, r, Read, fileHash); !ok {
+		return
+	}
+
+	if manifest, err := getShardManifest(fileHash); err == nil {
+		// sharded files are rebuilt from their shards before being served
+		restoredTmpFile, err := reconstructFile(manifest)
+		if err != nil {
+			log.Error("Can not reconstruct file", fileHash, err)
+			http.Error(w, "Can not reconstruct file.", 503)
+			return
+		}
+		defer removeShardFiles([]*os.File{restoredTmpFile})
+		serveFile(w, r, fileHash, restoredTmpFile)
+		return
+	}
+	alteredFileHash := GetAlteredFileHash(fileHash)
+	err, stat := getFileHashStat(fileHash)
+	if alteredFileHash == "" || err != nil {
+		http.Error(w, "File not found.", 404)
+		return
+	}
+	serveFile(w, r, fileHash, newRestoredFileReader(alteredFileHash, stat.Size))
+}
+
+// serveFile serves the restored content of a file.
+func serveFile(w http.ResponseWriter, r *http.Request, fileHash string, content io.ReadSeeker) {
+	// the content of a file hash never changes
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", fileHash))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	// ServeContent handles ranges and conditional requests, and sniffs the
+	// content type from the file name extension or the first 512 bytes
+	name := r.URL.Query().Get("filename")
+	if name != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+	}
+	http.ServeContent(w, r, name, time.Time{}, content)
+}
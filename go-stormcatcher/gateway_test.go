@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGateway(t *testing.T) {
+	Convey("Test streaming gateway", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_gateway_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		// signed requests are covered by TestAuth
+		stubs.Stub(&authEnabled, false)
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir)
+		initBlobStore()
+		stubs.Stub(&IpfsChunkSize, int64(100))
+		stubs.Stub(&gatewayReadSize, int64(250))
+
+		fileSize := 1234
+		content := generateTestFileContent(fileSize)
+		original := generateTestFile(fileSize)
+		defer os.Remove(original.Name())
+		altered := createAlteredTmpFile(original)
+		defer os.Remove(altered.Name())
+		altered.Seek(0, 0)
+		alteredFileHash, _ := blobStore.Put(altered)
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		updateFileHashMapping(fileHash, alteredFileHash)
+		updateFileHashStat(fileHash, FileHashStat{Size: int64(fileSize)})
+
+		get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			gatewayHandler(w, req)
+			return w
+		}
+
+		Convey("Whole file is restored", func() {
+			w := get("/files/"+fileHash, nil)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(bytes.Equal(w.Body.Bytes(), content), ShouldBeTrue)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+			So(w.Header().Get("ETag"), ShouldEqual, fmt.Sprintf("\"%s\"", fileHash))
+		})
+
+		Convey("Range across chunks is restored", func() {
+			w := get("/files/"+fileHash, map[string]string{"Range": "bytes=90-1099"})
+			So(w.Code, ShouldEqual, http.StatusPartialContent)
+			So(bytes.Equal(w.Body.Bytes(), content[90:1100]), ShouldBeTrue)
+			So(w.Header().Get("Content-Range"), ShouldEqual, "bytes 90-1099/1234")
+		})
+
+		Convey("Suffix range is restored", func() {
+			w := get("/files/"+fileHash, map[string]string{"Range": "bytes=-10"})
+			So(w.Code, ShouldEqual, http.StatusPartialContent)
+			So(bytes.Equal(w.Body.Bytes(), content[fileSize-10:]), ShouldBeTrue)
+		})
+
+		Convey("Matching ETag is not modified", func() {
+			w := get("/files/"+fileHash, map[string]string{"If-None-Match": fmt.Sprintf("\"%s\"", fileHash)})
+			So(w.Code, ShouldEqual, http.StatusNotModified)
+		})
+
+		Convey("Content type follows the file name", func() {
+			w := get("/files/"+fileHash+"?filename=data.json", nil)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		})
+
+		Convey("Unknown file is not found", func() {
+			w := get("/files/QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFM", nil)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Invalid file hash is rejected", func() {
+			w := get("/files/bad", nil)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
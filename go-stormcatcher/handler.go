@@ -12,9 +12,6 @@ import (
 	validator "gopkg.in/validator.v2"
 )
 
-var q2cMapping map[string](chan string)
-var handlerMapping map[string]func(string) error
-
 func handleIPFSRead(originalFileHash string) error {
 	log.Info("inside read gorouting")
 
@@ -22,7 +19,7 @@ func handleIPFSRead(originalFileHash string) error {
 	alteredFileHash := GetAlteredFileHash(originalFileHash)
 
 	// step 2
-	alteredTmpfile, errCheckout := checkoutIPFSFile(alteredFileHash)
+	alteredTmpfile, errCheckout := checkoutBlob(alteredFileHash)
 	if errCheckout != nil {
 		return errCheckout
 	}
@@ -33,14 +30,19 @@ func handleIPFSRead(originalFileHash string) error {
 	restoredTmpFile := createRestoredTmpFile(alteredTmpfile)
 	defer os.Remove(restoredTmpFile.Name())
 
-	// step 4, originalFileHash set to "" means it's not to checkin new written file
-	if errCheckInIpfs := checkInIpfsFile(restoredTmpFile, ""); errCheckInIpfs != nil {
-		return errCheckInIpfs
+	// step 4, originalFileHash set to "" means it's not to checkin new written file.
+	// With the ipfs blob store the restored id is the original file hash.
+	restoredFileHash, errCheckIn := checkInFile(restoredTmpFile, "")
+	if errCheckIn != nil {
+		return errCheckIn
 	}
 
 	// step 5, remember to unpin restored file
 	tFuture := time.Now().Unix() + RestoredFileUnpinInterval
-	unpinFileHashQueueAdd(originalFileHash, tFuture)
+	unpinFileHashQueueAdd(restoredFileHash, tFuture)
+	if f, err := restoredTmpFile.Stat(); err == nil {
+		bytesReadTotal.Add(float64(f.Size()))
+	}
 
 	log.Info("Ipfs read complete:", originalFileHash)
 
@@ -50,6 +52,12 @@ func handleIPFSRead(originalFileHash string) error {
 func HandleIPFSWrite(fileHash string) error {
 	log.Info("Inside write gorouting")
 
+	if isLocalCopyPinned(fileHash) {
+		// already stored, e.g. a task run again after a restart
+		log.Info("Ipfs write complete:", fileHash)
+		return nil
+	}
+
 	// step 1, get the file from ipfs network
 	log.Info("Downloading", fileHash, "...")
 	tmpFile, errCheckout := checkoutIPFSFile(fileHash)
@@ -67,30 +75,45 @@ func HandleIPFSWrite(fileHash string) error {
 	log.Info("Created alterd tmp file to", alteredTmpfile.Name())
 	defer os.Remove(alteredTmpfile.Name())
 
-	// step 3, check in the altered file into ipfs network
-	if errCheckInIpfs := checkInIpfsFile(alteredTmpfile, fileHash); errCheckInIpfs != nil {
-		return errCheckInIpfs
+	// step 3, check in the altered file into the blob store
+	if _, errCheckIn := checkInFile(alteredTmpfile, fileHash); errCheckIn != nil {
+		return errCheckIn
 	}
+	bytesWrittenTotal.Add(float64(stat.Size))
 	log.Info("Ipfs write complete:", fileHash)
 
 	return nil
 }
 
 func runIpfsGC() {
-	ipfsRepoGc()
+	if err := blobStore.GC(); err != nil {
+		log.Info("Blob store gc failed", err)
+		gcRunsTotal.WithLabelValues("failure").Inc()
+		return
+	}
+	gcRunsTotal.WithLabelValues("success").Inc()
 }
 
 func HandleIPFSDelete(fileHash string) error {
 	log.Info("inside delete gorouting")
 
+	if manifest, err := getShardManifest(fileHash); err == nil {
+		return handleShardDelete(manifest)
+	}
+	if GetAlteredFileHash(fileHash) == "" {
+		// already deleted, e.g. a task run again after a restart
+		log.Info("Ipfs delete complete:", fileHash)
+		return nil
+	}
+
 	// false means we actually want to delete a file instead of just clear a cached checkout file
-	if err := deleteIpfsFile(fileHash, false); err != nil {
+	if err := deleteStoredFile(fileHash, false); err != nil {
 		log.Info("Ipfs delete interrupted:", fileHash)
 		return err
 	}
 	log.Info("Ipfs delete complete:", fileHash)
 
-	if isIpfsFilePined(fileHash) {
+	if isBlobPinned(fileHash) {
 		// if there is a cached file, make it unpin now
 		tFuture := time.Now().Unix()
 		unpinFileHashQueueAdd(fileHash, tFuture)
@@ -108,6 +131,11 @@ func HandleIPFSDelete(fileHash string) error {
 	}
 	log.Info("Removed file hash from redis hash mappings")
 
+	if err := deleteFileRecords(fileHash); err != nil {
+		log.Info("Can not remove file owner, deal and health records")
+		return err
+	}
+
 	return nil
 }
 
@@ -180,17 +208,17 @@ var HandleIPFSVerify = func(originalFileHash string, offset int64) (error, []byt
 	if isStraddle {
 		mOffset := ret[0]
 		mLength := ret[1]
-		mBytes := readIpfsFileChunk(originalFileHash, mOffset, mLength)
+		mBytes := readStoredFileChunk(originalFileHash, mOffset, mLength)
 		log.Info(fmt.Sprintf("verify read 1 %s %d %d", originalFileHash, mOffset, mLength), len(mBytes))
 		nOffset := ret[2]
 		nLength := ret[3]
-		nBytes := readIpfsFileChunk(originalFileHash, nOffset, nLength)
+		nBytes := readStoredFileChunk(originalFileHash, nOffset, nLength)
 		log.Info(fmt.Sprintf("verify read 2 %s %d %d", originalFileHash, nOffset, nLength), len(nBytes))
 		return nil, append(mBytes, nBytes...)
 	} else {
 		mOffset := ret[0]
 		mLength := ret[1]
-		mBytes := readIpfsFileChunk(originalFileHash, mOffset, mLength)
+		mBytes := readStoredFileChunk(originalFileHash, mOffset, mLength)
 		log.Info(fmt.Sprintf("verify read 1 %s %d %d", originalFileHash, mOffset, mLength), len(mBytes))
 		return nil, mBytes
 	}
@@ -252,9 +280,9 @@ func handleIPFSProxyWrite(mProxyWriteRequest string) error {
 	log.Info("Created alterd tmp file to", alteredTmpfile.Name())
 	defer os.Remove(alteredTmpfile.Name())
 
-	// step 3, check in the altered file into ipfs network
-	if errCheckInIpfs := checkInIpfsFile(alteredTmpfile, originalFileHash); errCheckInIpfs != nil {
-		return errCheckInIpfs
+	// step 3, check in the altered file into the blob store
+	if _, errCheckIn := checkInFile(alteredTmpfile, originalFileHash); errCheckIn != nil {
+		return errCheckIn
 	}
 	log.Info("Ipfs write complete:", originalFileHash)
 
@@ -302,9 +330,10 @@ func directDownloadHandler(w http.ResponseWriter, r *http.Request) {
 
 func handleRestoreToLocal(alteredFileHash string) {
 	// step 1
-	alteredTmpfile, errCheckout := checkoutIPFSFile(alteredFileHash)
+	alteredTmpfile, errCheckout := checkoutBlob(alteredFileHash)
 	if errCheckout != nil {
-		log.Errorf("Check out ipfs file error: %v, %s", errCheckout, alteredFileHash)
+		log.Errorf("Check out stored file error: %v, %s", errCheckout, alteredFileHash)
+		return
 	}
 	log.Infof("Checkout altered file hash %s with tmp file %s", alteredFileHash, alteredTmpfile.Name())
 	defer os.Remove(alteredTmpfile.Name())
@@ -319,71 +348,6 @@ func handleRestoreToLocal(alteredFileHash string) {
 	}
 }
 
-func initThrottledQueues() {
-	q2cMapping = make(map[string](chan string))
-	queueNames := []string{
-		IpfsReadQueueName,
-		IpfsWriteQueueName,
-		IpfsDeleteQueueName,
-		IpfsProxyWriteQueueName,
-		IpfsProxyReadQueueName,
-	}
-
-	// this create multiple goroutine constantly push new tasks into queue
-	// push rate is throttled by queueConcurrency(=10)
-	for _, queueName := range queueNames {
-		c := make(chan string, queueConcurrency)
-		q2cMapping[queueName] = c
-		go func(queueName string, _c chan string) {
-			log.Info("q2c mapping", queueName, "->", _c)
-			for {
-				// timeout is zero, so this will block on new tasks
-				newTask, err := getTaskFromQueueBlock(queueName)
-				if err != nil {
-					log.Error("Can't connect to redis", redisHostPort, err)
-					time.Sleep(time.Duration(1) * time.Second)
-				} else {
-					log.Info("Enqueue new task in", queueName, newTask)
-					_c <- newTask
-				}
-			}
-		}(queueName, c)
-	}
-}
-
-func initThrottledQueueHandlers() {
-	// init handler mapping
-	handlerMapping = make(map[string]func(string) error)
-	handlerMapping[IpfsReadQueueName] = handleIPFSRead
-	handlerMapping[IpfsWriteQueueName] = HandleIPFSWrite
-	handlerMapping[IpfsDeleteQueueName] = HandleIPFSDelete
-	handlerMapping[IpfsProxyReadQueueName] = handleIPFSProxyRead
-	handlerMapping[IpfsProxyWriteQueueName] = handleIPFSProxyWrite
-
-	queueNames := []string{
-		IpfsReadQueueName,
-		IpfsWriteQueueName,
-		IpfsDeleteQueueName,
-		IpfsProxyWriteQueueName,
-		IpfsProxyReadQueueName,
-	}
-	for _, queueName := range queueNames {
-		go func(queueName string) {
-			c := q2cMapping[queueName]
-			log.Info("c from q:", queueName, c)
-			for {
-				// block on next task
-				task := <-c
-				log.Info(fmt.Sprintf("call %s handler with %v", queueName, task))
-				err := handlerMapping[queueName](task)
-				if err != nil {
-					log.Info("Can't handl task, error:", err)
-				}
-			}
-		}(queueName)
-	}
-}
-
 func initGCWorker() {
 	// run ipfs repo gc periodically
 	go func() {
@@ -395,16 +359,18 @@ func initGCWorker() {
 }
 
 func runIpfsUnpin() {
+	unpinRunsTotal.Inc()
 	tNow := time.Now().Unix()
 	fileHashes, err := unpinFileHashQueueRange(int64(0), tNow)
 	if err == nil {
 		for _, fileHash := range fileHashes {
 			// true means it's a delete to clear restored file
-			err := deleteIpfsFile(fileHash, true)
+			err := deleteStoredFile(fileHash, true)
 			if err != nil {
 				log.Info("Failed to clear cache for file", fileHash)
 			} else {
 				log.Info("Unpined cache for file", fileHash)
+				unpinnedFilesTotal.Inc()
 				// remove the file hash from unpin queue
 				unpinFileHashQueueRemove(fileHash)
 			}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,170 +12,221 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestMonkeyCRUD(t *testing.T) {
-	Convey("Test Monkey CRUD operations", t, func() {
-		// setup
-		initRedisClient()
-		// init queue and queue handler
-		initThrottledQueues()
-		initThrottledQueueHandlers()
-		//init gc routine
-		initGCWorker()
-
-		Convey("Test Monkey write", func() {
-			tmpFilesBefore, _ := ioutil.ReadDir(os.TempDir())
-			tmpFilesLengthBefore := len(tmpFilesBefore)
-			// generate a new file and add it to ipfs for test
-			fileSize := 20000000 // about 20m
-			tmpFile := generateTestFile(fileSize)
-			defer os.Remove(tmpFile.Name())
-			fileHash, _ := AddIpfsFile(tmpFile)
-			tmpFile.Close()
-			HandleIPFSWrite(fileHash)
-
-			//verify redis mappings and stats
-			alteredFileHash, _ := redisClient.HGet(IpfsFileHashMappingName, fileHash).Result()
-			So(alteredFileHash != "", ShouldBeTrue)
-			originalFileHash, _ := redisClient.HGet(IpfsFileHashMappingName, alteredFileHash).Result()
-			So(originalFileHash == fileHash, ShouldBeTrue)
-			_, fileHashStat := getFileHashStat(fileHash)
-			So(fileHashStat.Size == int64(fileSize), ShouldBeTrue)
-
-			//check if tmp file get deleted
-			tmpFilesAfter, _ := ioutil.ReadDir(os.TempDir())
-			tmpFilesLengthAfter := len(tmpFilesAfter)
-			// the only additional tmp file is the original file generated at the beginning of the test
-			So(tmpFilesLengthAfter == tmpFilesLengthBefore+1, ShouldBeTrue)
-		})
+// testIpfsNetwork stands in for the ipfs network files are written from: it maps
+// the ipfs hash of the files added to it to their path.
+type testIpfsNetwork map[string]string
 
-		Convey("Test Monkey delete", func() {
-			// generate a new file and add it to ipfs for test
-			fileSize := 20000000 // about 20m
-			fileContent := make([]byte, fileSize)
-			tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
-			defer os.Remove(tmpFile.Name())
-			tmpFile.Write(fileContent)
-			fileHash, _ := AddIpfsFile(tmpFile)
-			tmpFile.Close()
-			HandleIPFSWrite(fileHash)
-			//verify redis mappings and stats
-			alteredFileHash, _ := redisClient.HGet(IpfsFileHashMappingName, fileHash).Result()
-
-			//reset unpin interval to 1 second
-			stubs := Stub(&RestoredFileUnpinInterval, int64(1))
-			defer stubs.Reset()
-			//read the file
-			handleIPFSRead(fileHash)
-
-			// both altered file hash and original file hash should be unpin now
-			So(isIpfsFilePined(fileHash), ShouldBeTrue)
-			So(isIpfsFilePined(alteredFileHash), ShouldBeTrue)
-
-			// delete the file
-			HandleIPFSDelete(fileHash)
-			// sleep for 2 seconds then run unpin
-			time.Sleep(time.Duration(2) * time.Second)
-			runIpfsUnpin()
-
-			// both altered file hash and original file hash should be unpin now
-			So(isIpfsFilePined(fileHash), ShouldBeFalse)
-			So(isIpfsFilePined(alteredFileHash), ShouldBeFalse)
-
-			//verify redis mappings and stats are deleted as well
-			alteredFileHashAfterDelete, _ := redisClient.HGet(IpfsFileHashMappingName, fileHash).Result()
-			So(alteredFileHashAfterDelete == "", ShouldBeTrue)
-			originalFileHashAfterDelete, _ := redisClient.HGet(IpfsFileHashMappingName, alteredFileHash).Result()
-			So(originalFileHashAfterDelete == "", ShouldBeTrue)
-			_, fileHashStat := getFileHashStat(fileHash)
-			So(fileHashStat == nil, ShouldBeTrue)
-		})
+func (n testIpfsNetwork) add(tmpFile *os.File) string {
+	tmpFile.Seek(0, 0)
+	fileHash, _ := ipfsFileHash(tmpFile)
+	n[fileHash] = tmpFile.Name()
+	return fileHash
+}
 
-		Convey("Test Monkey verify", func() {
-
-			// generate a new file and add it to ipfs for test
-			fileSize := 20000000 // about 20m
-			fileContent := make([]byte, fileSize)
-			tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
-			defer os.Remove(tmpFile.Name())
-			tmpFile.Write(fileContent)
-			fileHash, _ := AddIpfsFile(tmpFile)
-			tmpFile.Close()
-			HandleIPFSWrite(fileHash)
-
-			_, verifyBytes256 := HandleIPFSVerify(fileHash, 100)
-			So(len(verifyBytes256) == 256, ShouldBeTrue)
-			_, verifyBytes128 := HandleIPFSVerify(fileHash, int64(fileSize-128))
-			So(len(verifyBytes128) == 128, ShouldBeTrue)
-		})
+func (n testIpfsNetwork) checkout(hash string) (*os.File, error) {
+	path, ok := n[hash]
+	if !ok {
+		return nil, errors.New("file not found in ipfs network")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpfile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	tmpfile.Write(content)
+	tmpfile.Seek(0, 0)
+	return tmpfile, nil
+}
 
-		Convey("Test Monkey read", func() {
-			// generate a new file and add it to ipfs for test
-			fileSize := 20000000 // about 20m
-			fileContent := make([]byte, fileSize)
-			tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
-			defer os.Remove(tmpFile.Name())
-			tmpFile.Write(fileContent)
-			fileHash, _ := AddIpfsFile(tmpFile)
-			tmpFile.Close()
-			HandleIPFSWrite(fileHash)
-
-			handleIPFSRead(fileHash)
-			score, _ := unpinFileHashQueueGet(fileHash)
-			So(int64(score) > time.Now().Unix(), ShouldBeTrue)
-		})
+// restoredFileHashes returns the restored copies waiting to be unpinned.
+func restoredFileHashes() []string {
+	fileHashes, _ := unpinFileHashQueueRange(0, time.Now().Unix()+RestoredFileUnpinInterval+1000)
+	return fileHashes
+}
 
-		Convey("Test clear restored file after n seconds", func() {
-			fileSize := 20000000 // about 20m
-
-			// file1, generate a new file and add it to ipfs for test
-			tmpFile1 := generateTestFile(fileSize)
-			defer os.Remove(tmpFile1.Name())
-			fileHash1, _ := AddIpfsFile(tmpFile1)
-			tmpFile1.Close()
-			HandleIPFSWrite(fileHash1)
-
-			// file2, generate a new file and add it to ipfs for test
-			tmpFile2 := generateTestFile(fileSize + 1)
-			defer os.Remove(tmpFile2.Name())
-			fileHash2, _ := AddIpfsFile(tmpFile2)
-			tmpFile2.Close()
-			HandleIPFSWrite(fileHash2)
-
-			// file3, generate a new file and add it to ipfs for test
-			tmpFile3 := generateTestFile(fileSize + 2)
-			defer os.Remove(tmpFile3.Name())
-			fileHash3, _ := AddIpfsFile(tmpFile3)
-			tmpFile3.Close()
-			HandleIPFSWrite(fileHash3)
-
-			//reset unpin interval to 1 second
-			stubs := Stub(&RestoredFileUnpinInterval, int64(1))
+func TestMonkeyCRUD(t *testing.T) {
+	for _, backend := range []string{MemoryBackend, LevelDBBackend} {
+		Convey("Test Monkey CRUD operations with the "+backend+" backend", t, func() {
+			// setup, files are kept by the fs blob store and written from a stand in
+			// of the ipfs network, so no external service is needed
+			dir, _ := ioutil.TempDir("", "stormcatcher_integration_test_")
+			defer os.RemoveAll(dir)
+			stubs := Stub(&storeBackend, backend)
 			defer stubs.Reset()
-			tNow := time.Now().Unix()
-			// read it once and it should be pinned
-			handleIPFSRead(fileHash1)
-			handleIPFSRead(fileHash2)
-			handleIPFSRead(fileHash3)
-
-			// 3 files need to be unpinned in the future
-			fileHashes, _ := unpinFileHashQueueRange(tNow, tNow+1000)
-			So(len(fileHashes) == 3, ShouldBeTrue)
-
-			So(isIpfsFilePined(fileHash1), ShouldBeTrue)
-			So(isIpfsFilePined(fileHash2), ShouldBeTrue)
-			So(isIpfsFilePined(fileHash3), ShouldBeTrue)
-
-			// sleep for 2 seconds then run unpin
-			time.Sleep(time.Duration(2) * time.Second)
-			runIpfsUnpin()
-			So(isIpfsFilePined(fileHash1), ShouldBeFalse)
-			So(isIpfsFilePined(fileHash2), ShouldBeFalse)
-			So(isIpfsFilePined(fileHash3), ShouldBeFalse)
-
-			// No file needs to be unpinned in the future
-			tNow = time.Now().Unix()
-			fileHashes, _ = unpinFileHashQueueRange(tNow, tNow+10)
-			So(len(fileHashes) == 0, ShouldBeTrue)
+			stubs.Stub(&storePath, filepath.Join(dir, "store"))
+			initStoreBackend()
+			defer metadataStore.Close()
+			stubs.Stub(&blobStoreType, FsBlobStore)
+			stubs.Stub(&blobStorePath, filepath.Join(dir, "blobs"))
+			initBlobStore()
+			network := testIpfsNetwork{}
+			stubs.Stub(&checkoutIPFSFile, network.checkout)
+			// init queue and queue handler
+			initQueueWorkers()
+			defer stopQueueWorkers(0)
+
+			Convey("Test Monkey write", func() {
+				tmpFilesBefore, _ := ioutil.ReadDir(os.TempDir())
+				tmpFilesLengthBefore := len(tmpFilesBefore)
+				// generate a new file and add it to ipfs for test
+				fileSize := 20000000 // about 20m
+				tmpFile := generateTestFile(fileSize)
+				defer os.Remove(tmpFile.Name())
+				fileHash := network.add(tmpFile)
+				tmpFile.Close()
+				So(HandleIPFSWrite(fileHash), ShouldBeNil)
+
+				//verify metadata mappings and stats
+				alteredFileHash, _ := metadataStore.HashGet(IpfsFileHashMappingName, fileHash)
+				So(alteredFileHash != "", ShouldBeTrue)
+				originalFileHash, _ := metadataStore.HashGet(IpfsFileHashMappingName, alteredFileHash)
+				So(originalFileHash == fileHash, ShouldBeTrue)
+				_, fileHashStat := getFileHashStat(fileHash)
+				So(fileHashStat.Size == int64(fileSize), ShouldBeTrue)
+				So(isBlobPinned(alteredFileHash), ShouldBeTrue)
+
+				//check if tmp file get deleted
+				tmpFilesAfter, _ := ioutil.ReadDir(os.TempDir())
+				tmpFilesLengthAfter := len(tmpFilesAfter)
+				// the only additional tmp file is the original file generated at the beginning of the test
+				So(tmpFilesLengthAfter == tmpFilesLengthBefore+1, ShouldBeTrue)
+			})
+
+			Convey("Test Monkey delete", func() {
+				// generate a new file and add it to ipfs for test
+				fileSize := 20000000 // about 20m
+				fileContent := make([]byte, fileSize)
+				tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
+				defer os.Remove(tmpFile.Name())
+				tmpFile.Write(fileContent)
+				fileHash := network.add(tmpFile)
+				tmpFile.Close()
+				So(HandleIPFSWrite(fileHash), ShouldBeNil)
+				//verify metadata mappings and stats
+				alteredFileHash, _ := metadataStore.HashGet(IpfsFileHashMappingName, fileHash)
+
+				//reset unpin interval to 1 second
+				stubs := Stub(&RestoredFileUnpinInterval, int64(1))
+				defer stubs.Reset()
+				//read the file
+				So(handleIPFSRead(fileHash), ShouldBeNil)
+				restored := restoredFileHashes()
+				So(len(restored), ShouldEqual, 1)
+
+				// both altered file and restored file should be pinned now
+				So(isBlobPinned(restored[0]), ShouldBeTrue)
+				So(isBlobPinned(alteredFileHash), ShouldBeTrue)
+
+				// delete the file
+				So(HandleIPFSDelete(fileHash), ShouldBeNil)
+				// sleep for 2 seconds then run unpin
+				time.Sleep(time.Duration(2) * time.Second)
+				runIpfsUnpin()
+
+				// both altered file and restored file should be unpinned now
+				So(isBlobPinned(restored[0]), ShouldBeFalse)
+				So(isBlobPinned(alteredFileHash), ShouldBeFalse)
+
+				//verify metadata mappings and stats are deleted as well
+				alteredFileHashAfterDelete, _ := metadataStore.HashGet(IpfsFileHashMappingName, fileHash)
+				So(alteredFileHashAfterDelete == "", ShouldBeTrue)
+				originalFileHashAfterDelete, _ := metadataStore.HashGet(IpfsFileHashMappingName, alteredFileHash)
+				So(originalFileHashAfterDelete == "", ShouldBeTrue)
+				_, fileHashStat := getFileHashStat(fileHash)
+				So(fileHashStat == nil, ShouldBeTrue)
+			})
+
+			Convey("Test Monkey verify", func() {
+
+				// generate a new file and add it to ipfs for test
+				fileSize := 20000000 // about 20m
+				fileContent := make([]byte, fileSize)
+				tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
+				defer os.Remove(tmpFile.Name())
+				tmpFile.Write(fileContent)
+				fileHash := network.add(tmpFile)
+				tmpFile.Close()
+				So(HandleIPFSWrite(fileHash), ShouldBeNil)
+
+				_, verifyBytes256 := HandleIPFSVerify(fileHash, 100)
+				So(len(verifyBytes256) == 256, ShouldBeTrue)
+				_, verifyBytes128 := HandleIPFSVerify(fileHash, int64(fileSize-128))
+				So(len(verifyBytes128) == 128, ShouldBeTrue)
+			})
+
+			Convey("Test Monkey read", func() {
+				// generate a new file and add it to ipfs for test
+				fileSize := 20000000 // about 20m
+				fileContent := make([]byte, fileSize)
+				tmpFile, _ := ioutil.TempFile("", "ipfs_monkey_test_")
+				defer os.Remove(tmpFile.Name())
+				tmpFile.Write(fileContent)
+				fileHash := network.add(tmpFile)
+				tmpFile.Close()
+				So(HandleIPFSWrite(fileHash), ShouldBeNil)
+
+				So(handleIPFSRead(fileHash), ShouldBeNil)
+				restored := restoredFileHashes()
+				So(len(restored), ShouldEqual, 1)
+				score, _ := unpinFileHashQueueGet(restored[0])
+				So(int64(score) > time.Now().Unix(), ShouldBeTrue)
+			})
+
+			Convey("Test clear restored file after n seconds", func() {
+				fileSize := 20000000 // about 20m
+
+				// file1, generate a new file and add it to ipfs for test
+				tmpFile1 := generateTestFile(fileSize)
+				defer os.Remove(tmpFile1.Name())
+				fileHash1 := network.add(tmpFile1)
+				tmpFile1.Close()
+				So(HandleIPFSWrite(fileHash1), ShouldBeNil)
+
+				// file2, generate a new file and add it to ipfs for test
+				tmpFile2 := generateTestFile(fileSize + 1)
+				defer os.Remove(tmpFile2.Name())
+				fileHash2 := network.add(tmpFile2)
+				tmpFile2.Close()
+				So(HandleIPFSWrite(fileHash2), ShouldBeNil)
+
+				// file3, generate a new file and add it to ipfs for test
+				tmpFile3 := generateTestFile(fileSize + 2)
+				defer os.Remove(tmpFile3.Name())
+				fileHash3 := network.add(tmpFile3)
+				tmpFile3.Close()
+				So(HandleIPFSWrite(fileHash3), ShouldBeNil)
+
+				//reset unpin interval to 1 second
+				stubs := Stub(&RestoredFileUnpinInterval, int64(1))
+				defer stubs.Reset()
+				tNow := time.Now().Unix()
+				// read it once and it should be pinned
+				handleIPFSRead(fileHash1)
+				handleIPFSRead(fileHash2)
+				handleIPFSRead(fileHash3)
+
+				// 3 files need to be unpinned in the future
+				fileHashes, _ := unpinFileHashQueueRange(tNow, tNow+1000)
+				So(len(fileHashes) == 3, ShouldBeTrue)
+				for _, fileHash := range fileHashes {
+					So(isBlobPinned(fileHash), ShouldBeTrue)
+				}
+
+				// sleep for 2 seconds then run unpin
+				time.Sleep(time.Duration(2) * time.Second)
+				runIpfsUnpin()
+				for _, fileHash := range fileHashes {
+					So(isBlobPinned(fileHash), ShouldBeFalse)
+				}
+
+				// No file needs to be unpinned in the future
+				tNow = time.Now().Unix()
+				fileHashes, _ = unpinFileHashQueueRange(tNow, tNow+10)
+				So(len(fileHashes) == 0, ShouldBeTrue)
+			})
 		})
-	})
+	}
 }
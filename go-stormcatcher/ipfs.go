@@ -27,7 +27,7 @@ func getRandomIntBuf() []byte {
 	return bs[0:8]
 }
 
-func checkoutIPFSFile(hash string) (*os.File, error) {
+var checkoutIPFSFile = func(hash string) (*os.File, error) {
 	// get file content from ipfs using cat endpoint
 	url := fmt.Sprintf(
 		"%s/%s?%s",
@@ -95,41 +95,6 @@ func createRestoredTmpFile(alteredTmpfile *os.File) *os.File {
 	return restoredTmpFile
 }
 
-type IpfsMkdirResponse struct {
-	Message string
-	Code    int64
-	Type    string
-}
-
-func getDirNameFromOriginalFileHash(originalFileHash string) string {
-	return originalFileHash[0:8]
-}
-
-func mkdirIpfsFile(originalFileHash string) (string, error) {
-	// take the first 8 bytes as dir name
-	dirName := getDirNameFromOriginalFileHash(originalFileHash)
-	// make new dir in ipfs
-	url := fmt.Sprintf(
-		"%s/%s?%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		"api/v0/files/mkdir",
-		fmt.Sprintf("arg=/%s", dirName),
-	)
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Error(err)
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	// handle response
-	body, err := ioutil.ReadAll(resp.Body)
-	var m IpfsMkdirResponse
-	err = json.Unmarshal(body, &m)
-	log.Info("ipfs mkdir", m.Code, m.Message, dirName)
-	return dirName, nil
-}
-
 type IpfsAddResponse struct {
 	Name string
 	Hash string
@@ -164,133 +129,154 @@ func AddIpfsFile(tmpFile *os.File) (string, error) {
 	return m.Hash, nil
 }
 
-type IpfsCpResponse struct {
+func isIpfsFilePined(fileHash string) bool {
+	// curl "http://localhost:5001/api/v0/pin/ls?arg=/ipfs/QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7j"
+	url := fmt.Sprintf(
+		"%s/%s",
+		fmt.Sprintf("http://%s", ipfsHostPort),
+		fmt.Sprintf("api/v0/pin/ls?arg=/ipfs/%s", fileHash),
+	)
+	r, err := resty.R().Get(url)
+	log.Info("List pinned file url", url, err)
+	if err != nil {
+		return false
+	} else {
+		// Hash pinned. {"Keys":{"QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7j":{"Type":"recursive"}}}
+		// Hash not pinned. {"Message":"path '/ipfs/QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7i' is not pinned","Code":0,"Type":"error"}
+		_r := string(r.Body())
+		return !strings.Contains(_r, "is not pinned")
+	}
+}
+
+type IpfsUnpinResponse struct {
 	Message string
 	Code    int
 	Type    string
 }
 
-func checkInIpfsFile(alteredTmpfile *os.File, originalFileHash string) error {
-	// add altered file to ipfs
-	alteredFileHash, _ := AddIpfsFile(alteredTmpfile)
+type IpfsFilesStatResponse struct {
+	Hash string
+	Size int64
+	Type string
+}
 
-	// if originalFileHash is "", it's not to checkin new written file
-	// but to restore original file in ipfs.
-	// No need to update mapping and run cp command
-	if originalFileHash == "" {
-		return nil
-	}
+// ipfsBlobStore keeps blobs in a go-ipfs node through its HTTP API.
+type ipfsBlobStore struct {
+	hostPort string
+}
 
-	// update the redis hash mapping
-	updateFileHashMapping(originalFileHash, alteredFileHash)
+func newIpfsBlobStore(hostPort string) *ipfsBlobStore {
+	return &ipfsBlobStore{hostPort: hostPort}
+}
 
-	// run a ipfs cp command to put the file in a directory for file/read later
-	// this is because only files/read support offset and limit
-	dirName, errMkdirIpfs := mkdirIpfsFile(originalFileHash)
-	if errMkdirIpfs != nil {
-		return errMkdirIpfs
-	}
-	url := fmt.Sprintf(
-		"%s/%s?%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		"api/v0/files/cp",
-		fmt.Sprintf("arg=/ipfs/%s&arg=/%s/%s", alteredFileHash, dirName, originalFileHash),
-	)
-	log.Info(url)
+func (s *ipfsBlobStore) apiURL(command string, query string) string {
+	return fmt.Sprintf("http://%s/api/v0/%s?%s", s.hostPort, command, query)
+}
 
-	resp, errFilesCp := resty.R().Get(url)
-	if errFilesCp != nil {
-		return errFilesCp
+func (s *ipfsBlobStore) Put(r io.Reader) (string, error) {
+	resp, err := resty.
+		R().
+		SetFileReader("file", IpfsPrefix, r).
+		SetFormData(map[string]string{}).
+		Post(s.apiURL("add", "pin=true"))
+	if err != nil {
+		return "", err
 	}
-	var m IpfsCpResponse
-	json.Unmarshal(resp.Body(), &m)
-	log.Info("ipfs cp cmd response:", m.Code, m.Message, m.Type)
 
-	return nil
+	var m IpfsAddResponse
+	json.Unmarshal(resp.Body(), &m)
+	if m.Hash == "" {
+		return "", fmt.Errorf("ipfs add failed: %s", string(resp.Body()))
+	}
+	log.Info("ipfs file added", m.Hash, m.Size)
+	return m.Hash, nil
 }
 
-type IpfsUnpinResponse struct {
-	Message string
-	Code    int
-	Type    string
+func (s *ipfsBlobStore) Get(id string) (io.ReadCloser, error) {
+	resp, err := http.Get(s.apiURL("cat", fmt.Sprintf("arg=/ipfs/%s", id)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("ipfs cat %s failed with status %d", id, resp.StatusCode)
+	}
+	return resp.Body, nil
 }
 
-func deleteIpfsFile(originalFileHash string, clearCache bool) error {
-	// this actually unpin the object and wait for next gc to remove it from ipfs storage
-	fileHashToUnpin := originalFileHash
-
-	// if it's to delete altered file which is the 'real' delete instead of
-	// clearing restored file during file read
-	if !clearCache {
-		fileHashToUnpin = GetAlteredFileHash(originalFileHash)
+func (s *ipfsBlobStore) Range(id string, offset int64, length int64) ([]byte, error) {
+	// curl "http://localhost:5001/api/v0/cat?arg=/ipfs/QmYcvhVaowRfwh88HRXm4a1Xj19h2CJ12yK4orK2bppj2L&offset=100&length=10"
+	r, err := resty.R().Get(s.apiURL("cat", fmt.Sprintf("arg=/ipfs/%s&offset=%d&length=%d", id, offset, length)))
+	if err != nil {
+		return nil, err
 	}
-	url := fmt.Sprintf(
-		"%s/%s?%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		"api/v0/pin/rm",
-		fmt.Sprintf("arg=/ipfs/%s&recursive=true", fileHashToUnpin),
-	)
+	if r.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("ipfs cat %s failed with status %d", id, r.StatusCode())
+	}
+	return r.Body(), nil
+}
 
-	log.Info(url)
-	resp, err := resty.R().Get(url)
+func (s *ipfsBlobStore) Delete(id string) error {
+	// this actually unpin the object and wait for next gc to remove it from ipfs storage
+	resp, err := resty.R().Get(s.apiURL("pin/rm", fmt.Sprintf("arg=/ipfs/%s&recursive=true", id)))
 	if err != nil {
 		return err
 	}
-	body := resp.Body()
 	var m IpfsUnpinResponse
-	json.Unmarshal(body, &m)
-	log.Info(fmt.Sprintf("delete/unpin response, clearCache=%v: %s", clearCache, string(body)))
-
+	json.Unmarshal(resp.Body(), &m)
+	log.Info("ipfs unpin response:", id, string(resp.Body()))
 	return nil
 }
 
-func ipfsRepoGc() {
-	url := fmt.Sprintf(
-		"%s/%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		"api/v0/repo/gc?quiet=true&stream-errors=false",
-	)
+func (s *ipfsBlobStore) Pin(id string) error {
+	resp, err := resty.R().Get(s.apiURL("pin/add", fmt.Sprintf("arg=/ipfs/%s&recursive=true", id)))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("ipfs pin %s failed: %s", id, string(resp.Body()))
+	}
+	return nil
+}
 
+func (s *ipfsBlobStore) GC() error {
+	url := s.apiURL("repo/gc", "quiet=true&stream-errors=false")
 	_, err := resty.R().Get(url)
 	if err != nil {
 		log.Error("Ipfs RPC error", url, err)
 	} else {
 		log.Info("Ipfs RPC connected", url)
 	}
+	return err
 }
 
-func readIpfsFileChunk(originalFileHash string, offset int64, limit int64) []byte {
-	filePath := fmt.Sprintf("/%s/%s", getDirNameFromOriginalFileHash(originalFileHash), originalFileHash)
-	// curl "http://localhost:5001/api/v0/files/read?arg=/QmYcvhVaowRfwh88HRXm4a1Xj19h2CJ12yK4orK2bppj2L&offset=100&count=10"
-	url := fmt.Sprintf(
-		"%s/%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		fmt.Sprintf("api/v0/files/read?arg=%s&offset=%d&count=%d", filePath, offset, limit),
-	)
-	r, err := resty.R().Get(url)
-	log.Info("verify url", url, err)
+func (s *ipfsBlobStore) Stat(id string) (*BlobStat, error) {
+	r, err := resty.R().Get(s.apiURL("files/stat", fmt.Sprintf("arg=/ipfs/%s", id)))
 	if err != nil {
-		return []byte{}
-	} else {
-		return []byte(r.Body())
+		return nil, err
+	}
+	var m IpfsFilesStatResponse
+	json.Unmarshal(r.Body(), &m)
+	if m.Hash == "" {
+		return nil, fmt.Errorf("ipfs stat %s failed: %s", id, string(r.Body()))
 	}
+
+	// Hash pinned. {"Keys":{"QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7j":{"Type":"recursive"}}}
+	// Hash not pinned. {"Message":"path '/ipfs/QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7i' is not pinned","Code":0,"Type":"error"}
+	p, err := resty.R().Get(s.apiURL("pin/ls", fmt.Sprintf("arg=/ipfs/%s", id)))
+	if err != nil {
+		return nil, err
+	}
+	return &BlobStat{Size: m.Size, Pinned: !strings.Contains(string(p.Body()), "is not pinned")}, nil
 }
 
-func isIpfsFilePined(fileHash string) bool {
-	// curl "http://localhost:5001/api/v0/pin/ls?arg=/ipfs/QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7j"
-	url := fmt.Sprintf(
-		"%s/%s",
-		fmt.Sprintf("http://%s", ipfsHostPort),
-		fmt.Sprintf("api/v0/pin/ls?arg=/ipfs/%s", fileHash),
-	)
-	r, err := resty.R().Get(url)
-	log.Info("List pinned file url", url, err)
+func (s *ipfsBlobStore) Ping() error {
+	resp, err := resty.R().Get(s.apiURL("version", ""))
 	if err != nil {
-		return false
-	} else {
-		// Hash pinned. {"Keys":{"QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7j":{"Type":"recursive"}}}
-		// Hash not pinned. {"Message":"path '/ipfs/QmZdA4wjEBgwTYWCJNnfVr474Fz3ueiqTr4fVTHGmnfF7i' is not pinned","Code":0,"Type":"error"}
-		_r := string(r.Body())
-		return !strings.Contains(_r, "is not pinned")
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("ipfs version failed with status %d", resp.StatusCode())
 	}
+	return nil
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// key prefixes used inside the leveldb database
+const (
+	levelDBQueuePrefix     = "q"
+	levelDBHashPrefix      = "h"
+	levelDBZMemberPrefix   = "z"
+	levelDBZScoreIdxPrefix = "s"
+)
+
+// levelDBStore implements Queue and MetadataStore with an embedded leveldb database,
+// so that small storage nodes do not need to run a redis server.
+type levelDBStore struct {
+	db   *leveldb.DB
+	mu   sync.Mutex
+	cond *sync.Cond
+	// next sequence number per queue, loaded lazily from the last stored task
+	queueSeq map[string]uint64
+}
+
+func newLevelDBStore(path string) (*levelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &levelDBStore{
+		db:       db,
+		queueSeq: make(map[string]uint64),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+func levelDBKey(prefix string, name string, suffix []byte) []byte {
+	key := make([]byte, 0, len(prefix)+len(name)+len(suffix)+2)
+	key = append(key, prefix...)
+	key = append(key, 0)
+	key = append(key, name...)
+	key = append(key, 0)
+	return append(key, suffix...)
+}
+
+func encodeUint64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+// encodeScore keeps the byte order of the encoded scores the same as their numeric order.
+func encodeScore(score int64) []byte {
+	return encodeUint64(uint64(score) ^ (1 << 63))
+}
+
+func decodeScore(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+}
+
+// nextQueueSeq must be called with s.mu held.
+func (s *levelDBStore) nextQueueSeq(queueName string) uint64 {
+	if seq, ok := s.queueSeq[queueName]; ok {
+		s.queueSeq[queueName] = seq + 1
+		return seq
+	}
+	seq := uint64(0)
+	iter := s.db.NewIterator(util.BytesPrefix(levelDBKey(levelDBQueuePrefix, queueName, nil)), nil)
+	if iter.Last() {
+		k := iter.Key()
+		seq = binary.BigEndian.Uint64(k[len(k)-8:]) + 1
+	}
+	iter.Release()
+	s.queueSeq[queueName] = seq + 1
+	return seq
+}
+
+func (s *levelDBStore) Push(queueName string, task string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := levelDBKey(levelDBQueuePrefix, queueName, encodeUint64(s.nextQueueSeq(queueName)))
+	if err := s.db.Put(key, []byte(task), nil); err != nil {
+		return err
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *levelDBStore) PopBlock(queueName string, timeout time.Duration) (string, error) {
+	deadline := popDeadline(timeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := util.BytesPrefix(levelDBKey(levelDBQueuePrefix, queueName, nil))
+	for {
+		iter := s.db.NewIterator(prefix, nil)
+		if iter.First() {
+			key := append([]byte{}, iter.Key()...)
+			task := string(iter.Value())
+			iter.Release()
+			if err := s.db.Delete(key, nil); err != nil {
+				return "", err
+			}
+			return task, nil
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return "", err
+		}
+		if !condWaitUntil(s.cond, deadline) {
+			return "", errQueueEmpty
+		}
+	}
+}
+
+func (s *levelDBStore) Len(queueName string) (int64, error) {
+	iter := s.db.NewIterator(util.BytesPrefix(levelDBKey(levelDBQueuePrefix, queueName, nil)), nil)
+	defer iter.Release()
+	n := int64(0)
+	for iter.Next() {
+		n++
+	}
+	return n, iter.Error()
+}
+
+func (s *levelDBStore) HashGet(table string, key string) (string, error) {
+	v, err := s.db.Get(levelDBKey(levelDBHashPrefix, table, []byte(key)), nil)
+	if err == leveldb.ErrNotFound {
+		return "", nil
+	}
+	return string(v), err
+}
+
+func (s *levelDBStore) HashSet(table string, key string, value string) error {
+	return s.db.Put(levelDBKey(levelDBHashPrefix, table, []byte(key)), []byte(value), nil)
+}
+
+func (s *levelDBStore) HashDelete(table string, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := levelDBKey(levelDBHashPrefix, table, []byte(key))
+	ok, err := s.db.Has(k, nil)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, s.db.Delete(k, nil)
+}
+
+func (s *levelDBStore) HashKeys(table string) ([]string, error) {
+	prefix := levelDBKey(levelDBHashPrefix, table, nil)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	keys := []string{}
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()[len(prefix):]))
+	}
+	return keys, iter.Error()
+}
+
+func (s *levelDBStore) SortedSetAdd(set string, member string, score int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	memberKey := levelDBKey(levelDBZMemberPrefix, set, []byte(member))
+	batch := new(leveldb.Batch)
+	if old, err := s.db.Get(memberKey, nil); err == nil {
+		batch.Delete(levelDBKey(levelDBZScoreIdxPrefix, set, append(old, member...)))
+	} else if err != leveldb.ErrNotFound {
+		return err
+	}
+	encoded := encodeScore(score)
+	batch.Put(memberKey, encoded)
+	batch.Put(levelDBKey(levelDBZScoreIdxPrefix, set, append(encoded, member...)), nil)
+	return s.db.Write(batch, nil)
+}
+
+func (s *levelDBStore) SortedSetRemove(set string, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	memberKey := levelDBKey(levelDBZMemberPrefix, set, []byte(member))
+	old, err := s.db.Get(memberKey, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	batch.Delete(memberKey)
+	batch.Delete(levelDBKey(levelDBZScoreIdxPrefix, set, append(old, member...)))
+	return s.db.Write(batch, nil)
+}
+
+func (s *levelDBStore) SortedSetRangeByScore(set string, min int64, max int64) ([]string, error) {
+	prefix := levelDBKey(levelDBZScoreIdxPrefix, set, nil)
+	start := levelDBKey(levelDBZScoreIdxPrefix, set, encodeScore(min))
+	iter := s.db.NewIterator(&util.Range{Start: start, Limit: util.BytesPrefix(prefix).Limit}, nil)
+	defer iter.Release()
+	members := []string{}
+	for iter.Next() {
+		k := iter.Key()[len(prefix):]
+		if decodeScore(k[:8]) > max {
+			break
+		}
+		members = append(members, string(k[8:]))
+	}
+	return members, iter.Error()
+}
+
+func (s *levelDBStore) SortedSetScore(set string, member string) (int64, error) {
+	v, err := s.db.Get(levelDBKey(levelDBZMemberPrefix, set, []byte(member)), nil)
+	if err == leveldb.ErrNotFound {
+		return 0, errKeyNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return decodeScore(v), nil
+}
+
+func (s *levelDBStore) Ping() error {
+	_, err := s.db.GetProperty("leveldb.stats")
+	return err
+}
+
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore implements Queue and MetadataStore in process memory.
+// Nothing survives a restart, so it is meant for tests and throwaway nodes.
+type memoryStore struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	queues     map[string][]string
+	hashes     map[string]map[string]string
+	sortedSets map[string]map[string]int64
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		queues:     make(map[string][]string),
+		hashes:     make(map[string]map[string]string),
+		sortedSets: make(map[string]map[string]int64),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *memoryStore) Push(queueName string, task string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[queueName] = append(s.queues[queueName], task)
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *memoryStore) PopBlock(queueName string, timeout time.Duration) (string, error) {
+	deadline := popDeadline(timeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queues[queueName]) == 0 {
+		if !condWaitUntil(s.cond, deadline) {
+			return "", errQueueEmpty
+		}
+	}
+	task := s.queues[queueName][0]
+	s.queues[queueName] = s.queues[queueName][1:]
+	return task, nil
+}
+
+func (s *memoryStore) Len(queueName string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.queues[queueName])), nil
+}
+
+func (s *memoryStore) HashGet(table string, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hashes[table][key], nil
+}
+
+func (s *memoryStore) HashSet(table string, key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hashes[table] == nil {
+		s.hashes[table] = make(map[string]string)
+	}
+	s.hashes[table][key] = value
+	return nil
+}
+
+func (s *memoryStore) HashDelete(table string, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.hashes[table][key]
+	delete(s.hashes[table], key)
+	return ok, nil
+}
+
+func (s *memoryStore) HashKeys(table string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.hashes[table]))
+	for k := range s.hashes[table] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *memoryStore) SortedSetAdd(set string, member string, score int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sortedSets[set] == nil {
+		s.sortedSets[set] = make(map[string]int64)
+	}
+	s.sortedSets[set][member] = score
+	return nil
+}
+
+func (s *memoryStore) SortedSetRemove(set string, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sortedSets[set], member)
+	return nil
+}
+
+func (s *memoryStore) SortedSetRangeByScore(set string, min int64, max int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := []string{}
+	for member, score := range s.sortedSets[set] {
+		if score >= min && score <= max {
+			members = append(members, member)
+		}
+	}
+	// same order as redis: by score, then lexicographically
+	scores := s.sortedSets[set]
+	sort.Slice(members, func(i, j int) bool {
+		if scores[members[i]] != scores[members[j]] {
+			return scores[members[i]] < scores[members[j]]
+		}
+		return members[i] < members[j]
+	})
+	return members, nil
+}
+
+func (s *memoryStore) SortedSetScore(set string, member string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score, ok := s.sortedSets[set][member]
+	if !ok {
+		return 0, errKeyNotFound
+	}
+	return score, nil
+}
+
+func (s *memoryStore) Ping() error {
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
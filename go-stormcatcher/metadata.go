@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var AddTaskToQueue = func(queueName string, taskName string) error {
+	if err := taskQueue.Push(queueName, taskName); err != nil {
+		log.Info("Enqueue failed", queueName, taskName, err)
+		return err
+	} else {
+		log.Info("Enqueue", queueName, taskName)
+		return nil
+	}
+}
+
+func getTaskFromQueueBlock(queueName string, timeout time.Duration) (string, error) {
+	return taskQueue.PopBlock(queueName, timeout)
+}
+
+var GetAlteredFileHash = func(originalFileHash string) string {
+	s, _ := metadataStore.HashGet(IpfsFileHashMappingName, originalFileHash)
+	log.Info(IpfsFileHashMappingName, originalFileHash, s)
+	return s
+}
+
+func updateFileHashMapping(originalFileHash string, alteredFileHash string) error {
+	// make the mapping bi-directional
+	if err := metadataStore.HashSet(IpfsFileHashMappingName, originalFileHash, alteredFileHash); err != nil {
+		return err
+	}
+	if err := metadataStore.HashSet(IpfsFileHashMappingName, alteredFileHash, originalFileHash); err != nil {
+		return err
+	}
+	log.Info(IpfsFileHashMappingName, originalFileHash, alteredFileHash)
+
+	return nil
+}
+
+func updateFileHashStat(originalFileHash string, stat FileHashStat) error {
+	mStat, _ := json.Marshal(stat)
+	if err := metadataStore.HashSet(IpfsFileHashStatName, originalFileHash, string(mStat)); err != nil {
+		return err
+	}
+	log.Info(IpfsFileHashStatName, originalFileHash, string(mStat))
+	return nil
+}
+
+func deleteFileHashStat(originalFileHash string) error {
+	_, err := metadataStore.HashDelete(IpfsFileHashStatName, originalFileHash)
+	return err
+}
+
+func deleteFileHashMapping(h string, tableName string) error {
+	deleted, err := metadataStore.HashDelete(tableName, h)
+	if deleted {
+		log.Info("Deleted metadata entry", h, "from", tableName)
+	} else {
+		log.Info("Deleted no metadata entry with", h, "from", tableName)
+	}
+	return err
+}
+
+func deleteFileHashMappings(originalFileHash string) error {
+	alteredFileHash := GetAlteredFileHash(originalFileHash)
+	var retErr error = nil
+	if err := deleteFileHashMapping(originalFileHash, IpfsFileHashMappingName); err != nil {
+		retErr = err
+	}
+	if err := deleteFileHashMapping(alteredFileHash, IpfsFileHashMappingName); err != nil {
+		retErr = err
+	}
+
+	return retErr
+}
+
+type FileHashStat struct {
+	Size int64 `json:"size"`
+}
+
+func getFileHashStat(originalFileHash string) (error, *FileHashStat) {
+	result, _ := metadataStore.HashGet(IpfsFileHashStatName, originalFileHash)
+	if result == "" {
+		return errors.New("File not found"), nil
+	}
+	stat := new(FileHashStat)
+	json.Unmarshal([]byte(result), &stat)
+
+	return nil, stat
+}
+
+func unpinFileHashQueueAdd(unpinFileHash string, unpinTimeStamp int64) error {
+	// if called with existing filehash, its unpinTimeStamp will be updated.
+	return metadataStore.SortedSetAdd(IpfsUnpinFileHashQueueName, unpinFileHash, unpinTimeStamp)
+}
+
+func unpinFileHashQueueRemove(unpinFileHash string) error {
+	err := metadataStore.SortedSetRemove(IpfsUnpinFileHashQueueName, unpinFileHash)
+	if err != nil {
+		log.Info("Can not remove file hash from unpin queue", unpinFileHash)
+	} else {
+		log.Info("Removed file hash from unpin queue", unpinFileHash)
+	}
+	return err
+}
+
+func unpinFileHashQueueRange(cutoffTimeMin int64, cutoffTimeMax int64) ([]string, error) {
+	return metadataStore.SortedSetRangeByScore(IpfsUnpinFileHashQueueName, cutoffTimeMin, cutoffTimeMax)
+}
+
+// deleteFileRecords removes the owner, deal and health records of a deleted file.
+func deleteFileRecords(originalFileHash string) error {
+	if err := deleteFileOwner(originalFileHash); err != nil {
+		return err
+	}
+	if err := deleteDeal(originalFileHash); err != nil {
+		return err
+	}
+	_, err := metadataStore.HashDelete(IpfsFileHealthName, originalFileHash)
+	return err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "stormcatcher",
+		Name:      "task_duration_seconds",
+		Help:      "Time spent handling a queued task.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+	}, []string{"queue"})
+	tasksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "tasks_total",
+		Help:      "Queued tasks handled, by result.",
+	}, []string{"queue", "result"})
+	bytesWrittenTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "bytes_written_total",
+		Help:      "Bytes of original files written to the blob store.",
+	})
+	bytesReadTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "bytes_read_total",
+		Help:      "Bytes of original files restored from the blob store.",
+	})
+	gcRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "gc_runs_total",
+		Help:      "Blob store garbage collection runs, by result.",
+	}, []string{"result"})
+	unpinRunsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "unpin_runs_total",
+		Help:      "Runs of the restored file unpin worker.",
+	})
+	unpinnedFilesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "stormcatcher",
+		Name:      "unpinned_files_total",
+		Help:      "Restored files unpinned by the unpin worker.",
+	})
+)
+
+var queueDepthDesc = prometheus.NewDesc("stormcatcher_queue_depth", "Tasks waiting in a queue.", []string{"queue"}, nil)
+
+// queueDepthCollector reads the queue lengths from the queue backend at scrape time.
+type queueDepthCollector struct{}
+
+func (c queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (c queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, queueName := range taskQueueNames {
+		length, err := taskQueue.Len(queueName)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(length), queueName)
+	}
+}
+
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		queueDepthCollector{},
+		taskDuration,
+		tasksTotal,
+		bytesWrittenTotal,
+		bytesReadTotal,
+		gcRunsTotal,
+		unpinRunsTotal,
+		unpinnedFilesTotal,
+	)
+	return registry
+}
+
+func observeTask(queueName string, start time.Time, err error) {
+	taskDuration.WithLabelValues(queueName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		tasksTotal.WithLabelValues(queueName, "failure").Inc()
+	} else {
+		tasksTotal.WithLabelValues(queueName, "success").Inc()
+	}
+}
+
+// workersReady is set to 1 once the queue workers are running
+var workersReady int32
+
+type HealthCheck struct {
+	Name  string `json:"name"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Ok     bool          `json:"ok"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+var pingIpfsAPI = func() error {
+	return newIpfsBlobStore(ipfsHostPort).Ping()
+}
+
+// runHealthChecks checks the metadata store, the ipfs api used to fetch new files
+// and the blob store when it is not ipfs.
+func runHealthChecks() HealthResponse {
+	response := HealthResponse{Ok: true}
+	add := func(name string, err error) {
+		check := HealthCheck{Name: name, Ok: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+			response.Ok = false
+		}
+		response.Checks = append(response.Checks, check)
+	}
+	add(storeBackend, metadataStore.Ping())
+	add(IpfsBlobStore, pingIpfsAPI())
+	if blobStoreType != IpfsBlobStore {
+		add(blobStoreType, blobStore.Ping())
+	}
+	return response
+}
+
+func writeHealthResponse(w http.ResponseWriter, response HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/healthz"
+	writeHealthResponse(w, runHealthChecks())
+}
+
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/readyz"
+	response := runHealthChecks()
+	check := HealthCheck{Name: "workers", Ok: atomic.LoadInt32(&workersReady) == 1}
+	if !check.Ok {
+		check.Error = "queue workers not started"
+		response.Ok = false
+	}
+	response.Checks = append(response.Checks, check)
+	writeHealthResponse(w, response)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(newMetricsRegistry(), promhttp.HandlerOpts{})
+}
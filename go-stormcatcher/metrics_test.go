@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetrics(t *testing.T) {
+	Convey("Test metrics endpoint", t, func() {
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		taskQueue.Push(IpfsWriteQueueName, fileHash)
+		taskQueue.Push(IpfsWriteQueueName, fileHash)
+		observeTask(IpfsDeleteQueueName, time.Now(), nil)
+		observeTask(IpfsDeleteQueueName, time.Now(), errors.New("failed"))
+
+		w := httptest.NewRecorder()
+		metricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		So(w.Code, ShouldEqual, 200)
+		body := w.Body.String()
+		So(body, ShouldContainSubstring, `stormcatcher_queue_depth{queue="ipfs_write_queue"} 2`)
+		So(body, ShouldContainSubstring, `stormcatcher_queue_depth{queue="ipfs_read_queue"} 0`)
+		So(body, ShouldContainSubstring, `stormcatcher_tasks_total{queue="ipfs_delete_queue",result="failure"}`)
+		So(body, ShouldContainSubstring, `stormcatcher_task_duration_seconds_count{queue="ipfs_delete_queue"}`)
+		So(body, ShouldContainSubstring, "stormcatcher_bytes_written_total")
+		So(body, ShouldContainSubstring, "stormcatcher_unpin_runs_total")
+	})
+}
+
+func TestHealth(t *testing.T) {
+	Convey("Test health and readiness endpoints", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_health_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir)
+		initBlobStore()
+		stubs.StubFunc(&pingIpfsAPI, nil)
+
+		get := func(handler func(w *httptest.ResponseRecorder)) (int, HealthResponse) {
+			w := httptest.NewRecorder()
+			handler(w)
+			var response HealthResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return w.Code, response
+		}
+		healthz := func(w *httptest.ResponseRecorder) { healthzHandler(w, httptest.NewRequest("GET", "/healthz", nil)) }
+		readyz := func(w *httptest.ResponseRecorder) { readyzHandler(w, httptest.NewRequest("GET", "/readyz", nil)) }
+
+		Convey("Healthy node", func() {
+			code, response := get(healthz)
+			So(code, ShouldEqual, 200)
+			So(response.Ok, ShouldBeTrue)
+			So(len(response.Checks), ShouldEqual, 3)
+		})
+
+		Convey("Unreachable ipfs api", func() {
+			stubs.StubFunc(&pingIpfsAPI, errors.New("connection refused"))
+			code, response := get(healthz)
+			So(code, ShouldEqual, 503)
+			So(response.Ok, ShouldBeFalse)
+			So(response.Checks[1].Error, ShouldEqual, "connection refused")
+		})
+
+		Convey("Missing blob store directory", func() {
+			os.RemoveAll(dir)
+			code, response := get(healthz)
+			So(code, ShouldEqual, 503)
+			So(strings.Contains(response.Checks[2].Error, "no such file"), ShouldBeTrue)
+		})
+
+		Convey("Not ready before the workers start", func() {
+			atomic.StoreInt32(&workersReady, 0)
+			code, _ := get(readyz)
+			So(code, ShouldEqual, 503)
+
+			atomic.StoreInt32(&workersReady, 1)
+			defer atomic.StoreInt32(&workersReady, 0)
+			code, _ = get(readyz)
+			So(code, ShouldEqual, 200)
+		})
+	})
+}
@@ -1,19 +1,14 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"strconv"
+	"time"
 
 	"github.com/go-redis/redis"
 )
 
 var redisClient *redis.Client
 
-func initRedisClient() {
-	redisClient = getRedisClient(redisHostPort, "")
-}
-
 func getRedisClient(redisHostPort string, redisPassword string) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr:     redisHostPort,
@@ -23,121 +18,94 @@ func getRedisClient(redisHostPort string, redisPassword string) *redis.Client {
 	return client
 }
 
-var AddTaskToQueue = func(queueName string, taskName string) error {
-	if result, err := redisClient.LPush(queueName, taskName).Result(); err != nil {
-		log.Info("Redis LPush failed", result, err)
-		return err
-	} else {
-		log.Info("Redis LPush", queueName, taskName)
-		return nil
-	}
+// redisStore implements Queue and MetadataStore on top of a redis server.
+type redisStore struct {
+	client *redis.Client
+}
+
+func newRedisStore(redisHostPort string, redisPassword string) *redisStore {
+	redisClient = getRedisClient(redisHostPort, redisPassword)
+	return &redisStore{client: redisClient}
+}
+
+func (s *redisStore) Push(queueName string, task string) error {
+	_, err := s.client.LPush(queueName, task).Result()
+	return err
 }
 
-func getTaskFromQueueBlock(queueName string) (string, error) {
-	r, err := redisClient.BRPop(0, queueName).Result()
+func (s *redisStore) PopBlock(queueName string, timeout time.Duration) (string, error) {
+	if timeout > 0 && timeout < time.Second {
+		// redis counts in seconds and 0 would block forever
+		timeout = time.Second
+	}
+	r, err := s.client.BRPop(timeout, queueName).Result()
 	// r: key, value
+	if err == redis.Nil {
+		return "", errQueueEmpty
+	}
 	if err != nil {
 		return "", err
 	}
 	return r[1], nil
 }
 
-var GetAlteredFileHash = func(originalFileHash string) string {
-	s, _ := redisClient.HGet(IpfsFileHashMappingName, originalFileHash).Result()
-	log.Info(IpfsFileHashMappingName, originalFileHash, s)
-	return s
+func (s *redisStore) Len(queueName string) (int64, error) {
+	return s.client.LLen(queueName).Result()
 }
 
-func updateFileHashMapping(originalFileHash string, alteredFileHash string) error {
-	// make the mapping bi-directional
-	redisClient.HSet(IpfsFileHashMappingName, originalFileHash, alteredFileHash)
-	redisClient.HSet(IpfsFileHashMappingName, alteredFileHash, originalFileHash)
-	log.Info(IpfsFileHashMappingName, originalFileHash, alteredFileHash)
-
-	return nil
+func (s *redisStore) HashGet(table string, key string) (string, error) {
+	v, err := s.client.HGet(table, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return v, err
 }
 
-func updateFileHashStat(originalFileHash string, stat FileHashStat) error {
-	mStat, _ := json.Marshal(stat)
-	redisClient.HSet(IpfsFileHashStatName, originalFileHash, string(mStat))
-	log.Info(IpfsFileHashStatName, originalFileHash, string(mStat))
-	return nil
+func (s *redisStore) HashSet(table string, key string, value string) error {
+	return s.client.HSet(table, key, value).Err()
 }
 
-func deleteFileHashStat(originalFileHash string) error {
-	_, err := redisClient.HDel(IpfsFileHashStatName, originalFileHash).Result()
-	if err != nil {
-		return err
-	} else {
-		return nil
-	}
+func (s *redisStore) HashDelete(table string, key string) (bool, error) {
+	deleted, err := s.client.HDel(table, key).Result()
+	return deleted == 1, err
 }
 
-func deleteFileHashMapping(h string, tableName string) error {
-	delete, err := redisClient.HDel(tableName, h).Result()
-	if delete == 1 {
-		log.Info("Deleted redis entry", h, "from", tableName)
-	} else {
-		log.Info("Deleted no redis entry with", h, "from", tableName)
-	}
-	if err != nil {
-		return err
-	} else {
-		return nil
-	}
+func (s *redisStore) HashKeys(table string) ([]string, error) {
+	return s.client.HKeys(table).Result()
 }
-func deleteFileHashMappings(originalFileHash string) error {
-	alteredFileHash := GetAlteredFileHash(originalFileHash)
-	var retErr error = nil
-	if err := deleteFileHashMapping(originalFileHash, IpfsFileHashMappingName); err != nil {
-		retErr = err
-	}
-	if err := deleteFileHashMapping(alteredFileHash, IpfsFileHashMappingName); err != nil {
-		retErr = err
-	}
 
-	return retErr
+func (s *redisStore) SortedSetAdd(set string, member string, score int64) error {
+	value := redis.Z{
+		Member: member,
+		Score:  float64(score),
+	}
+	return s.client.ZAdd(set, value).Err()
 }
 
-type FileHashStat struct {
-	Size int64 `json:"size"`
+func (s *redisStore) SortedSetRemove(set string, member string) error {
+	return s.client.ZRem(set, member).Err()
 }
 
-func getFileHashStat(originalFileHash string) (error, *FileHashStat) {
-	result, _ := redisClient.HGet(IpfsFileHashStatName, originalFileHash).Result()
-	if result == "" {
-		return errors.New("File not found"), nil
+func (s *redisStore) SortedSetRangeByScore(set string, min int64, max int64) ([]string, error) {
+	zRange := redis.ZRangeBy{
+		Min: strconv.FormatInt(min, 10),
+		Max: strconv.FormatInt(max, 10),
 	}
-	stat := new(FileHashStat)
-	json.Unmarshal([]byte(result), &stat)
-
-	return nil, stat
+	return s.client.ZRangeByScore(set, zRange).Result()
 }
 
-func unpinFileHashQueueAdd(unpinFileHash string, unpinTimeStamp int64) error {
-	// if called with existing filehash, its unpinTimeStamp will be updated.
-	value := redis.Z{
-		Member: unpinFileHash,
-		Score:  float64(unpinTimeStamp),
+func (s *redisStore) SortedSetScore(set string, member string) (int64, error) {
+	score, err := s.client.ZScore(set, member).Result()
+	if err == redis.Nil {
+		return 0, errKeyNotFound
 	}
-	_, err := redisClient.ZAdd(IpfsUnpinFileHashQueueName, value).Result()
-	return err
+	return int64(score), err
 }
 
-func unpinFileHashQueueRemove(unpinFileHash string) error {
-	_, err := redisClient.ZRem(IpfsUnpinFileHashQueueName, unpinFileHash).Result()
-	if err != nil {
-		log.Info("Can not remove file hash from unpin queue", unpinFileHash)
-	} else {
-		log.Info("Removed file hash from unpin queue", unpinFileHash)
-	}
-	return err
+func (s *redisStore) Ping() error {
+	return s.client.Ping().Err()
 }
 
-func unpinFileHashQueueRange(cutoffTimeMin int64, cutoffTimeMax int64) ([]string, error) {
-	zRange := redis.ZRangeBy{
-		Min: strconv.Itoa(int(cutoffTimeMin)),
-		Max: strconv.Itoa(int(cutoffTimeMax)),
-	}
-	return redisClient.ZRangeByScore(IpfsUnpinFileHashQueueName, zRange).Result()
+func (s *redisStore) Close() error {
+	return s.client.Close()
 }
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	resty "gopkg.in/resty.v1"
+	validator "gopkg.in/validator.v2"
+)
+
+// file health status reported by the repair loop
+const (
+	FileHealthy         = "healthy"
+	FileRepairing       = "repairing"
+	FileUnderReplicated = "under_replicated"
+)
+
+// FileHealth is the result of the last audit of a stored file.
+type FileHealth struct {
+	Filehash    string `json:"file_hash"`
+	LocalPinned bool   `json:"local_pinned"`
+	Replicas    int    `json:"replicas"` // nodes known to hold the file, this one included
+	Target      int    `json:"target"`
+	Requested   int    `json:"requested"` // peers asked to store a new replica
+	Status      string `json:"status"`
+	CheckedAt   int64  `json:"checked_at"`
+}
+
+type ReplicaResponse struct {
+	Filehash string `json:"file_hash"`
+	Pinned   bool   `json:"pinned"`
+}
+
+func saveFileHealth(health *FileHealth) error {
+	mHealth, _ := json.Marshal(health)
+	return metadataStore.HashSet(IpfsFileHealthName, health.Filehash, string(mHealth))
+}
+
+func getFileHealth(originalFileHash string) (*FileHealth, error) {
+	result, err := metadataStore.HashGet(IpfsFileHealthName, originalFileHash)
+	if err != nil {
+		return nil, err
+	}
+	if result == "" {
+		return nil, errKeyNotFound
+	}
+	health := new(FileHealth)
+	if err := json.Unmarshal([]byte(result), health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// isLocalCopyPinned reports whether this node holds a pinned altered copy of the file.
+func isLocalCopyPinned(originalFileHash string) bool {
+	alteredFileHash := GetAlteredFileHash(originalFileHash)
+	return alteredFileHash != "" && isBlobPinned(alteredFileHash)
+}
+
+// repairLocalCopy pins the altered copy again if the blob store still has it,
+// otherwise the file is written again from the ipfs network.
+func repairLocalCopy(originalFileHash string) error {
+	alteredFileHash := GetAlteredFileHash(originalFileHash)
+	if alteredFileHash != "" && blobStore.Pin(alteredFileHash) == nil {
+		log.Info("Repaired local copy by pinning", originalFileHash, alteredFileHash)
+		return nil
+	}
+	log.Info("Local copy lost, write it again", originalFileHash)
+	return AddTaskToQueue(IpfsWriteQueueName, originalFileHash)
+}
+
+// queryPeerReplica asks a peer stormcatcher whether it holds a pinned copy of the file.
+var queryPeerReplica = func(peer string, originalFileHash string) (bool, error) {
+	url := fmt.Sprintf("http://%s/replica/has?file_hash=%s", peer, originalFileHash)
+	req := resty.R()
+	if operatorKey != nil {
+		if err := signRequest(req, operatorKey, Read.String(), originalFileHash); err != nil {
+			return false, err
+		}
+	}
+	resp, err := req.Get(url)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return false, fmt.Errorf("peer %s replied with status %d", peer, resp.StatusCode())
+	}
+	var m ReplicaResponse
+	if err := json.Unmarshal(resp.Body(), &m); err != nil {
+		return false, err
+	}
+	return m.Pinned, nil
+}
+
+// requestPeerReplica asks a peer stormcatcher to store a copy of the file.
+var requestPeerReplica = func(peer string, originalFileHash string) error {
+	url := fmt.Sprintf("http://%s/ipfs/write?file_hash=%s", peer, originalFileHash)
+	req := resty.R()
+	if operatorKey != nil {
+		// peers only accept writes of owned files from their operators
+		if err := signRequest(req, operatorKey, Write.String(), originalFileHash); err != nil {
+			return err
+		}
+	}
+	resp, err := req.Get(url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("peer %s replied with status %d", peer, resp.StatusCode())
+	}
+	return nil
+}
+
+// auditFile checks the local copy and the replicas held by peers, and asks
+// peers without a copy to store one until the replication factor is reached.
+func auditFile(originalFileHash string) *FileHealth {
+	health := &FileHealth{
+		Filehash:  originalFileHash,
+		Target:    replicationFactor,
+		CheckedAt: time.Now().Unix(),
+	}
+
+	health.LocalPinned = isLocalCopyPinned(originalFileHash)
+	if health.LocalPinned {
+		health.Replicas++
+	} else if err := repairLocalCopy(originalFileHash); err != nil {
+		log.Error("Can not repair local copy", originalFileHash, err)
+	}
+
+	missingPeers := []string{}
+	for _, peer := range replicationPeers {
+		has, err := queryPeerReplica(peer, originalFileHash)
+		if err != nil {
+			// unreachable peers are not counted as replicas, but not asked either
+			log.Info("Can not query replica from peer", peer, originalFileHash, err)
+			continue
+		}
+		if has {
+			health.Replicas++
+		} else {
+			missingPeers = append(missingPeers, peer)
+		}
+	}
+
+	for _, peer := range missingPeers {
+		if health.Replicas+health.Requested >= health.Target {
+			break
+		}
+		if err := requestPeerReplica(peer, originalFileHash); err != nil {
+			log.Info("Can not request replica from peer", peer, originalFileHash, err)
+			continue
+		}
+		health.Requested++
+	}
+
+	switch {
+	case health.LocalPinned && health.Replicas >= health.Target:
+		health.Status = FileHealthy
+	case !health.LocalPinned || health.Replicas+health.Requested >= health.Target:
+		health.Status = FileRepairing
+	default:
+		health.Status = FileUnderReplicated
+	}
+	saveFileHealth(health)
+	log.Info("Audited file", originalFileHash, health.Status, health.Replicas, "/", health.Target)
+	return health
+}
+
+// auditShard checks the shard kept by this node and writes it again if it is lost.
+func auditShard(manifest *ShardManifest) *FileHealth {
+	health := &FileHealth{
+		Filehash:  manifest.Filehash,
+		Target:    1,
+		CheckedAt: time.Now().Unix(),
+	}
+	cid := manifest.Shards[manifest.LocalShardId].Cid
+	health.LocalPinned = isBlobPinned(cid)
+	if health.LocalPinned {
+		health.Replicas = 1
+		health.Status = FileHealthy
+	} else {
+		health.Status = FileRepairing
+		if blobStore.Pin(cid) != nil {
+			mShardWriteRequest, _ := json.Marshal(ShardWriteRequest{Filehash: manifest.Filehash, ShardId: manifest.LocalShardId})
+			AddTaskToQueue(IpfsShardWriteQueueName, string(mShardWriteRequest))
+		}
+	}
+	saveFileHealth(health)
+	return health
+}
+
+func runRepair() {
+	fileHashes, err := metadataStore.HashKeys(IpfsFileHashStatName)
+	if err != nil {
+		log.Info("Failed getting file stats.", err)
+		return
+	}
+	for _, fileHash := range fileHashes {
+		// shard stats are audited with their manifest
+		if strings.Contains(fileHash, "/") {
+			continue
+		}
+		auditFile(fileHash)
+	}
+
+	manifestHashes, err := metadataStore.HashKeys(IpfsShardManifestName)
+	if err != nil {
+		log.Info("Failed getting shard manifests.", err)
+		return
+	}
+	for _, fileHash := range manifestHashes {
+		if manifest, err := getShardManifest(fileHash); err == nil {
+			auditShard(manifest)
+		}
+	}
+}
+
+func initRepairWorker() {
+	// audit and repair stored files periodically
+	go func() {
+		for {
+			time.Sleep(time.Duration(repairInterval) * time.Second)
+			runRepair()
+		}
+	}()
+}
+
+func replicaHasHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/replica/has?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	fileHash := r.URL.Query().Get("file_hash")
+	readRequest := ReadRequest{Filehash: fileHash}
+	if errs := validator.Validate(readRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
+		return
+	}
+	if _, ok := authorize(w, r, Read, fileHash); !ok {
+		return
+	}
+
+	if GetAlteredFileHash(fileHash) == "" {
+		http.Error(w, "File not found.", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReplicaResponse{Filehash: fileHash, Pinned: isLocalCopyPinned(fileHash)})
+}
+
+func replicaHealthHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/replica/health?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	// curl "http://127.0.0.1:18080/replica/health"
+	// a single file is signed with the "read" method, the listing with "health" by an operator
+	if fileHash := r.URL.Query().Get("file_hash"); fileHash != "" {
+		if _, ok := authorize(w, r, Read, fileHash); !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		health, err := getFileHealth(fileHash)
+		if err != nil {
+			http.Error(w, "File not audited.", 404)
+			return
+		}
+		json.NewEncoder(w).Encode(health)
+		return
+	}
+	if !authorizeAccount(w, r, "health", "") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fileHashes, err := metadataStore.HashKeys(IpfsFileHealthName)
+	if err != nil {
+		http.Error(w, "Can not list file health.", 500)
+		return
+	}
+	healths := []*FileHealth{}
+	for _, fileHash := range fileHashes {
+		if health, err := getFileHealth(fileHash); err == nil {
+			healths = append(healths, health)
+		}
+	}
+	json.NewEncoder(w).Encode(healths)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepair(t *testing.T) {
+	Convey("Test repair loop", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_repair_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir)
+		initBlobStore()
+		stubs.Stub(&replicationFactor, 3)
+		stubs.Stub(&replicationPeers, []string{"peer1", "peer2", "peer3"})
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		alteredFileHash, _ := blobStore.Put(bytes.NewReader([]byte("altered content")))
+		updateFileHashMapping(fileHash, alteredFileHash)
+		updateFileHashStat(fileHash, FileHashStat{Size: 15})
+
+		requested := []string{}
+		stubs.Stub(&requestPeerReplica, func(peer string, originalFileHash string) error {
+			requested = append(requested, peer)
+			return nil
+		})
+
+		Convey("File with enough replicas is healthy", func() {
+			stubs.Stub(&queryPeerReplica, func(peer string, originalFileHash string) (bool, error) {
+				return peer != "peer3", nil
+			})
+			health := auditFile(fileHash)
+			So(health.Status, ShouldEqual, FileHealthy)
+			So(health.Replicas, ShouldEqual, 3)
+			So(len(requested), ShouldEqual, 0)
+
+			saved, _ := getFileHealth(fileHash)
+			So(saved.Status, ShouldEqual, FileHealthy)
+		})
+
+		Convey("Under replicated file is copied to peers", func() {
+			stubs.Stub(&queryPeerReplica, func(peer string, originalFileHash string) (bool, error) {
+				return false, nil
+			})
+			health := auditFile(fileHash)
+			So(health.Status, ShouldEqual, FileRepairing)
+			So(health.Replicas, ShouldEqual, 1)
+			So(requested, ShouldResemble, []string{"peer1", "peer2"})
+		})
+
+		Convey("Unreachable peers are not counted", func() {
+			stubs.Stub(&queryPeerReplica, func(peer string, originalFileHash string) (bool, error) {
+				return false, errors.New("connection refused")
+			})
+			health := auditFile(fileHash)
+			So(health.Status, ShouldEqual, FileUnderReplicated)
+			So(health.Replicas, ShouldEqual, 1)
+			So(len(requested), ShouldEqual, 0)
+		})
+
+		Convey("Lost local copy is written again", func() {
+			stubs.Stub(&replicationPeers, []string{})
+			stubs.Stub(&replicationFactor, 1)
+			blobStore.Delete(alteredFileHash)
+
+			runRepair()
+			health, _ := getFileHealth(fileHash)
+			So(health.LocalPinned, ShouldBeFalse)
+			So(health.Status, ShouldEqual, FileRepairing)
+			task, _ := taskQueue.PopBlock(IpfsWriteQueueName, 0)
+			So(task, ShouldEqual, fileHash)
+		})
+	})
+}
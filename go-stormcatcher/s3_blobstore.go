@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sha256 of an empty payload, used to sign requests without body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3BlobStore keeps blobs in a bucket of an S3 compatible object storage, using path
+// style urls and AWS signature version 4. Blobs are addressed by the sha256 of their
+// content and stored under blobs/<id>. Object storage has no pin set or garbage
+// collector: a stored blob is pinned, Delete removes it right away and GC does nothing.
+type s3BlobStore struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3BlobStore(endpoint string, region string, bucket string, accessKey string, secretKey string) *s3BlobStore {
+	return &s3BlobStore{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{},
+	}
+}
+
+func (s *s3BlobStore) objectPath(id string) (string, error) {
+	if !sha256IdRegexp.MatchString(id) {
+		return "", errInvalidBlobId
+	}
+	return fmt.Sprintf("/%s/blobs/%s", s.bucket, id), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds the AWS signature version 4 headers to the request.
+func (s *s3BlobStore) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func (s *s3BlobStore) do(method string, path string, body io.Reader, contentLength int64, payloadHash string, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(s.endpoint + path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = contentLength
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s failed with status %d: %s", method, path, resp.StatusCode, string(msg))
+	}
+	return resp, nil
+}
+
+func (s *s3BlobStore) Put(r io.Reader) (string, error) {
+	// spool the content to a tmp file, the id and the payload hash are needed
+	// before the upload starts
+	tmpfile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpfile, h), r)
+	if err != nil {
+		return "", err
+	}
+	tmpfile.Seek(0, 0)
+
+	id := hex.EncodeToString(h.Sum(nil))
+	path, _ := s.objectPath(id)
+	resp, err := s.do("PUT", path, tmpfile, size, id, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	log.Info("s3 blob added", id, size)
+	return id, nil
+}
+
+func (s *s3BlobStore) Get(id string) (io.ReadCloser, error) {
+	path, err := s.objectPath(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do("GET", path, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3BlobStore) Range(id string, offset int64, length int64) ([]byte, error) {
+	path, err := s.objectPath(id)
+	if err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		return []byte{}, nil
+	}
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := s.do("GET", path, nil, 0, emptyPayloadHash, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(io.LimitReader(resp.Body, length))
+}
+
+func (s *s3BlobStore) Delete(id string) error {
+	path, err := s.objectPath(id)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do("DELETE", path, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3BlobStore) Pin(id string) error {
+	_, err := s.Stat(id)
+	return err
+}
+
+func (s *s3BlobStore) GC() error {
+	return nil
+}
+
+func (s *s3BlobStore) Ping() error {
+	resp, err := s.do("HEAD", "/"+s.bucket, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3BlobStore) Stat(id string) (*BlobStat, error) {
+	path, err := s.objectPath(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do("HEAD", path, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &BlobStat{Size: resp.ContentLength, Pinned: true}, nil
+}
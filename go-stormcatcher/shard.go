@@ -0,0 +1,727 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+	resty "gopkg.in/resty.v1"
+	validator "gopkg.in/validator.v2"
+)
+
+// noShardId means the file is not assigned to a shard
+const noShardId = -1
+
+var errNotEnoughShards = errors.New("not enough valid shards to reconstruct the file")
+
+// ShardInfo describes one erasure coded shard of a file.
+type ShardInfo struct {
+	ShardId int    `json:"shard_id"`
+	Node    string `json:"node"`          // stormcatcher host:port the shard is assigned to
+	Hash    string `json:"hash"`          // sha256 of the shard content, used to check fetched shards
+	Cid     string `json:"cid,omitempty"` // id of the altered shard in the blob store of its node, "" if not stored
+}
+
+// ShardManifest records how a file is split into data and parity shards. The node
+// splitting the file pushes each shard to the node it is assigned to, then sends
+// the manifest with the ids of all the shards to every node keeping one, each with
+// its own LocalShardId.
+type ShardManifest struct {
+	Filehash     string      `json:"file_hash"`
+	Size         int64       `json:"size"`
+	DataShards   int         `json:"data_shards"`
+	ParityShards int         `json:"parity_shards"`
+	ShardSize    int64       `json:"shard_size"`
+	LocalShardId int         `json:"local_shard_id"`
+	Shards       []ShardInfo `json:"shards"`
+}
+
+type ShardWriteRequest struct {
+	Filehash string `validate:"len=46,regexp=^[a-zA-Z0-9]*$"`
+	ShardId  int    `validate:"min=0"`
+}
+
+type ShardGetRequest struct {
+	Filehash string `validate:"len=46,regexp=^[a-zA-Z0-9]*$"`
+	ShardId  string `validate:"nonzero,regexp=^[0-9]*$"`
+}
+
+type ShardPutRequest struct {
+	Filehash string `validate:"len=46,regexp=^[a-zA-Z0-9]*$"`
+	ShardId  string `validate:"nonzero,regexp=^[0-9]*$"`
+	Hash     string `validate:"len=64,regexp=^[0-9a-f]*$"`
+}
+
+// ShardPutResponse is the reply of a node storing a shard pushed to it.
+type ShardPutResponse struct {
+	Filehash string `json:"file_hash"`
+	ShardId  int    `json:"shard_id"`
+	Cid      string `json:"cid"`
+}
+
+// shardKey is the key of a shard in the hash mapping and the stat hash.
+func shardKey(originalFileHash string, shardId int) string {
+	return fmt.Sprintf("%s/%d", originalFileHash, shardId)
+}
+
+func parseHostPortList(peers string) []string {
+	if peers == "" {
+		return []string{}
+	}
+	return strings.Split(peers, ",")
+}
+
+func shardPeer(shardId int) string {
+	if shardId < len(shardPeers) {
+		return shardPeers[shardId]
+	}
+	return ""
+}
+
+func saveShardManifest(manifest *ShardManifest) error {
+	mManifest, _ := json.Marshal(manifest)
+	return metadataStore.HashSet(IpfsShardManifestName, manifest.Filehash, string(mManifest))
+}
+
+func getShardManifest(originalFileHash string) (*ShardManifest, error) {
+	result, err := metadataStore.HashGet(IpfsShardManifestName, originalFileHash)
+	if err != nil {
+		return nil, err
+	}
+	if result == "" {
+		return nil, errKeyNotFound
+	}
+	manifest := new(ShardManifest)
+	if err := json.Unmarshal([]byte(result), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func isShardedFile(originalFileHash string) bool {
+	_, err := getShardManifest(originalFileHash)
+	return err == nil
+}
+
+func sha256File(f *os.File) (string, error) {
+	f.Seek(0, 0)
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func removeShardFiles(files []*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+}
+
+// encodeShards splits the file into data shards and computes the parity shards,
+// each shard is written into its own tmp file.
+func encodeShards(src *os.File, size int64, dataShards int, parityShards int) ([]*os.File, error) {
+	enc, err := reedsolomon.NewStream(dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*os.File, dataShards+parityShards)
+	for i := range files {
+		f, err := ioutil.TempFile("", IpfsPrefix)
+		if err != nil {
+			removeShardFiles(files)
+			return nil, err
+		}
+		files[i] = f
+	}
+
+	data := make([]io.Writer, dataShards)
+	for i := range data {
+		data[i] = files[i]
+	}
+	src.Seek(0, 0)
+	if err := enc.Split(src, data, size); err != nil {
+		removeShardFiles(files)
+		return nil, err
+	}
+
+	dataReaders := make([]io.Reader, dataShards)
+	for i := range dataReaders {
+		files[i].Seek(0, 0)
+		dataReaders[i] = files[i]
+	}
+	parity := make([]io.Writer, parityShards)
+	for i := range parity {
+		parity[i] = files[dataShards+i]
+	}
+	if err := enc.Encode(dataReaders, parity); err != nil {
+		removeShardFiles(files)
+		return nil, err
+	}
+	return files, nil
+}
+
+// fetchShard returns the restored content of a shard in a tmp file. The shard is
+// read by its id from the blob store if this node keeps it, or if the blob store is
+// ipfs where any node can serve it, otherwise from the node it is assigned to.
+var fetchShard = func(manifest *ShardManifest, shardId int) (*os.File, error) {
+	cid := manifest.Shards[shardId].Cid
+	if shardId == manifest.LocalShardId || (cid != "" && blobStoreType == IpfsBlobStore) {
+		alteredTmpfile, err := checkoutBlob(cid)
+		if err == nil {
+			defer os.Remove(alteredTmpfile.Name())
+			return createRestoredTmpFile(alteredTmpfile), nil
+		}
+		if shardId == manifest.LocalShardId {
+			return nil, err
+		}
+		log.Info("Can not get shard by id, ask its node", manifest.Filehash, shardId, err)
+	}
+
+	node := manifest.Shards[shardId].Node
+	if node == "" {
+		return nil, fmt.Errorf("no node assigned to shard %d", shardId)
+	}
+	url := fmt.Sprintf("http://%s/shard/get?file_hash=%s&shard_id=%d", node, manifest.Filehash, shardId)
+	req := resty.R().SetDoNotParseResponse(true)
+	if operatorKey != nil {
+		// peers only serve shards to their operators
+		if err := signRequest(req, operatorKey, Read.String(), manifest.Filehash); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := req.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("fetch shard %d from %s failed with status %d", shardId, node, resp.StatusCode())
+	}
+	tmpfile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmpfile, body); err != nil {
+		removeShardFiles([]*os.File{tmpfile})
+		return nil, err
+	}
+	return tmpfile, nil
+}
+
+// reconstructFile rebuilds the original file from any DataShards valid shards.
+func reconstructFile(manifest *ShardManifest) (*os.File, error) {
+	n := manifest.DataShards + manifest.ParityShards
+	files := make([]*os.File, n)
+	defer removeShardFiles(files)
+
+	valid := 0
+	for i := 0; i < n && valid < manifest.DataShards; i++ {
+		f, err := fetchShard(manifest, i)
+		if err != nil {
+			log.Info("Can not fetch shard", manifest.Filehash, i, err)
+			continue
+		}
+		if h, _ := sha256File(f); h != manifest.Shards[i].Hash {
+			log.Error("Shard content mismatch", manifest.Filehash, i)
+			removeShardFiles([]*os.File{f})
+			continue
+		}
+		files[i] = f
+		valid++
+	}
+	if valid < manifest.DataShards {
+		return nil, errNotEnoughShards
+	}
+
+	enc, err := reedsolomon.NewStream(manifest.DataShards, manifest.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	// rebuild the missing data shards, missing parity shards are not needed
+	readers := make([]io.Reader, n)
+	fill := make([]io.Writer, n)
+	missing := false
+	for i := 0; i < n; i++ {
+		if files[i] != nil {
+			files[i].Seek(0, 0)
+			readers[i] = files[i]
+		} else if i < manifest.DataShards {
+			f, err := ioutil.TempFile("", IpfsPrefix)
+			if err != nil {
+				return nil, err
+			}
+			files[i] = f
+			fill[i] = f
+			missing = true
+		}
+	}
+	if missing {
+		if err := enc.Reconstruct(readers, fill); err != nil {
+			return nil, err
+		}
+	}
+
+	data := make([]io.Reader, manifest.DataShards)
+	for i := range data {
+		files[i].Seek(0, 0)
+		data[i] = files[i]
+	}
+	restoredTmpFile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.Join(restoredTmpFile, data, manifest.Size); err != nil {
+		removeShardFiles([]*os.File{restoredTmpFile})
+		return nil, err
+	}
+	return restoredTmpFile, nil
+}
+
+// storeShard alters the restored content of a shard and checks it in under its
+// shard key. A shard already stored is kept, so pushing it again is harmless.
+func storeShard(originalFileHash string, shardId int, shardFile *os.File, size int64) (string, error) {
+	key := shardKey(originalFileHash, shardId)
+	if cid := GetAlteredFileHash(key); cid != "" && isBlobPinned(cid) {
+		return cid, nil
+	}
+	alteredTmpfile := createAlteredTmpFile(shardFile)
+	defer os.Remove(alteredTmpfile.Name())
+	cid, err := checkInFile(alteredTmpfile, key)
+	if err != nil {
+		return "", err
+	}
+	updateFileHashStat(key, FileHashStat{Size: size})
+	return cid, nil
+}
+
+// pushShard sends the restored content of a shard to the node it is assigned to,
+// which stores it and replies with the id of its altered copy.
+var pushShard = func(node string, originalFileHash string, shardId int, hash string, shardFile *os.File) (string, error) {
+	url := fmt.Sprintf("http://%s/shard/put?file_hash=%s&shard_id=%d&hash=%s", node, originalFileHash, shardId, hash)
+	shardFile.Seek(0, 0)
+	req := resty.R().SetBody(shardFile)
+	if operatorKey != nil {
+		// peers only accept shards from their operators
+		if err := signRequest(req, operatorKey, Write.String(), originalFileHash); err != nil {
+			return "", err
+		}
+	}
+	resp, err := req.Post(url)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("push shard %d to %s failed with status %d", shardId, node, resp.StatusCode())
+	}
+	var m ShardPutResponse
+	if err := json.Unmarshal(resp.Body(), &m); err != nil {
+		return "", err
+	}
+	return m.Cid, nil
+}
+
+// pushShardManifest sends the manifest to a node keeping one of the shards.
+var pushShardManifest = func(node string, manifest *ShardManifest) error {
+	url := fmt.Sprintf("http://%s/shard/manifest?file_hash=%s", node, manifest.Filehash)
+	mManifest, _ := json.Marshal(manifest)
+	req := resty.R().SetHeader("Content-Type", "application/json").SetBody(mManifest)
+	if operatorKey != nil {
+		if err := signRequest(req, operatorKey, Write.String(), manifest.Filehash); err != nil {
+			return err
+		}
+	}
+	resp, err := req.Post(url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("push manifest to %s failed with status %d", node, resp.StatusCode())
+	}
+	return nil
+}
+
+// HandleShardWrite splits a file into data and parity shards on this node only: it
+// keeps the shard given in the request and pushes every other shard to the node it
+// is assigned to. The manifest with the ids of all the stored shards is then sent
+// to each of these nodes. Nodes which already got their shard this way skip the
+// write, and nodes asked to store a shard again keep the one they have.
+func HandleShardWrite(mShardWriteRequest string) error {
+	shardWriteRequest := new(ShardWriteRequest)
+	json.Unmarshal([]byte(mShardWriteRequest), &shardWriteRequest)
+	fileHash := shardWriteRequest.Filehash
+	shardId := shardWriteRequest.ShardId
+	log.Info("Inside shard write gorouting", fileHash, shardId)
+
+	if shardId >= ecDataShards+ecParityShards {
+		return fmt.Errorf("shard id %d out of range", shardId)
+	}
+	if isShardStored(fileHash, shardId) {
+		log.Info("Shard already stored:", fileHash, shardId)
+		return nil
+	}
+
+	// step 1, get the file from ipfs network
+	tmpFile, errCheckout := checkoutIPFSFile(fileHash)
+	if errCheckout != nil {
+		return errCheckout
+	}
+	defer os.Remove(tmpFile.Name())
+	f, _ := tmpFile.Stat()
+	if f.Size() == 0 {
+		// an empty file has nothing to split
+		return HandleIPFSWrite(fileHash)
+	}
+	return writeShards(fileHash, shardId, tmpFile, f.Size())
+}
+
+// isShardStored tells whether this node already keeps the given shard of the file.
+func isShardStored(originalFileHash string, shardId int) bool {
+	manifest, err := getShardManifest(originalFileHash)
+	return err == nil && manifest.LocalShardId == shardId && shardId < len(manifest.Shards) &&
+		isBlobPinned(manifest.Shards[shardId].Cid)
+}
+
+// writeShards splits a local copy of the original file, keeps shardId on this node
+// and pushes the other shards to their node.
+func writeShards(fileHash string, shardId int, file *os.File, size int64) error {
+	// step 2, split the file into data and parity shards
+	shardFiles, errEncode := encodeShards(file, size, ecDataShards, ecParityShards)
+	if errEncode != nil {
+		return errEncode
+	}
+	defer removeShardFiles(shardFiles)
+	shardStat, _ := shardFiles[0].Stat()
+
+	manifest := &ShardManifest{
+		Filehash:     fileHash,
+		Size:         size,
+		DataShards:   ecDataShards,
+		ParityShards: ecParityShards,
+		ShardSize:    shardStat.Size(),
+		LocalShardId: shardId,
+	}
+	for i, shardFile := range shardFiles {
+		h, err := sha256File(shardFile)
+		if err != nil {
+			return err
+		}
+		manifest.Shards = append(manifest.Shards, ShardInfo{ShardId: i, Node: shardPeer(i), Hash: h})
+	}
+
+	// step 3, keep the local shard and push the others to their node
+	stored := 0
+	for i, shardFile := range shardFiles {
+		var cid string
+		var err error
+		switch node := manifest.Shards[i].Node; {
+		case i == shardId:
+			cid, err = storeShard(fileHash, i, shardFile, manifest.ShardSize)
+		case node == "":
+			err = fmt.Errorf("no node assigned to shard %d", i)
+		default:
+			cid, err = pushShard(node, fileHash, i, manifest.Shards[i].Hash, shardFile)
+		}
+		if err != nil {
+			if i == shardId {
+				return err
+			}
+			// the file can be read back as long as DataShards shards are stored
+			log.Error("Can not store shard", fileHash, i, err)
+			continue
+		}
+		manifest.Shards[i].Cid = cid
+		stored++
+	}
+	if stored < manifest.DataShards {
+		return errNotEnoughShards
+	}
+	if err := saveShardManifest(manifest); err != nil {
+		return err
+	}
+
+	// step 4, tell every node keeping a shard where the others are
+	for i, shard := range manifest.Shards {
+		if i == shardId || shard.Cid == "" {
+			continue
+		}
+		peerManifest := *manifest
+		peerManifest.LocalShardId = i
+		if err := pushShardManifest(shard.Node, &peerManifest); err != nil {
+			log.Error("Can not push shard manifest", fileHash, shard.Node, err)
+		}
+	}
+	bytesWrittenTotal.Add(float64(manifest.ShardSize))
+	log.Info("Shard write complete:", fileHash, shardId, stored, "shards stored")
+	return nil
+}
+
+func handleShardRead(originalFileHash string) error {
+	manifest, err := getShardManifest(originalFileHash)
+	if err != nil {
+		return err
+	}
+
+	restoredTmpFile, err := reconstructFile(manifest)
+	if err != nil {
+		return err
+	}
+	defer removeShardFiles([]*os.File{restoredTmpFile})
+
+	restoredFileHash, errCheckIn := checkInFile(restoredTmpFile, "")
+	if errCheckIn != nil {
+		return errCheckIn
+	}
+
+	// remember to unpin restored file
+	tFuture := time.Now().Unix() + RestoredFileUnpinInterval
+	unpinFileHashQueueAdd(restoredFileHash, tFuture)
+	bytesReadTotal.Add(float64(manifest.Size))
+
+	log.Info("Shard read complete:", originalFileHash)
+	return nil
+}
+
+func handleShardDelete(manifest *ShardManifest) error {
+	key := shardKey(manifest.Filehash, manifest.LocalShardId)
+	if err := deleteStoredFile(key, false); err != nil {
+		return err
+	}
+	if err := deleteFileHashStat(key); err != nil {
+		return err
+	}
+	if err := deleteFileHashMappings(key); err != nil {
+		return err
+	}
+	if _, err := metadataStore.HashDelete(IpfsShardManifestName, manifest.Filehash); err != nil {
+		return err
+	}
+	if err := deleteFileRecords(manifest.Filehash); err != nil {
+		return err
+	}
+	log.Info("Shard delete complete:", manifest.Filehash, manifest.LocalShardId)
+	return nil
+}
+
+var HandleShardVerify = func(originalFileHash string, shardId int, offset int64) (error, []byte) {
+	manifest, err := getShardManifest(originalFileHash)
+	if err != nil {
+		return err, []byte{}
+	}
+	if manifest.LocalShardId != shardId {
+		return fmt.Errorf("shard %d is not stored on this node", shardId), []byte{}
+	}
+	// the shard stat and mapping are kept under the shard key
+	return HandleIPFSVerify(shardKey(originalFileHash, shardId), offset)
+}
+
+func enqueueShardWrite(w http.ResponseWriter, fileHash string, shardId int) bool {
+	shardWriteRequest := ShardWriteRequest{Filehash: fileHash, ShardId: shardId}
+	if errs := validator.Validate(shardWriteRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid parameter: %v", errs), 400)
+		return false
+	}
+
+	mShardWriteRequest, _ := json.Marshal(shardWriteRequest)
+	if err := AddTaskToQueue(IpfsShardWriteQueueName, string(mShardWriteRequest)); err != nil {
+		http.Error(w, "Can not enqueue shard write request.", 500)
+		return false
+	}
+	return true
+}
+
+func shardWriteHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query, this node keeps shard 2 and pushes the others to their node:
+	// curl "http://127.0.0.1:18080/shard/write?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN&shard_id=2"
+	shardId := nodeShardId
+	if shardIdString := r.URL.Query().Get("shard_id"); shardIdString != "" {
+		id, err := strconv.Atoi(shardIdString)
+		if err != nil {
+			http.Error(w, "Can not parse shard_id value.", 400)
+			return
+		}
+		shardId = id
+	}
+	if ecDataShards == 0 {
+		http.Error(w, "Erasure coding is not enabled on this node.", 400)
+		return
+	}
+	fileHash := r.URL.Query().Get("file_hash")
+	signer, ok := authorize(w, r, Write, fileHash)
+	if !ok {
+		return
+	}
+	if enqueueShardWrite(w, fileHash, shardId) {
+		claimFileOwner(fileHash, signer)
+	}
+}
+
+func shardReadHandler(w http.ResponseWriter, r *http.Request) {
+	// sample query:
+	// curl "http://127.0.0.1:18080/shard/read?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	fileHash := r.URL.Query().Get("file_hash")
+	readRequest := ReadRequest{Filehash: fileHash}
+	if errs := validator.Validate(readRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
+		return
+	}
+	if _, ok := authorize(w, r, Read, fileHash); !ok {
+		return
+	}
+
+	if err := AddTaskToQueue(IpfsShardReadQueueName, fileHash); err != nil {
+		http.Error(w, "Can not enqueue shard read request.", 500)
+		return
+	}
+}
+
+// shardGetHandler serves the restored content of the shard kept by this node to its peers.
+func shardGetHandler(w http.ResponseWriter, r *http.Request) {
+	shardGetRequest := ShardGetRequest{
+		Filehash: r.URL.Query().Get("file_hash"),
+		ShardId:  r.URL.Query().Get("shard_id"),
+	}
+	if errs := validator.Validate(shardGetRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid parameter: %v", errs), 400)
+		return
+	}
+	if _, ok := authorize(w, r, Read, shardGetRequest.Filehash); !ok {
+		return
+	}
+	shardId, _ := strconv.Atoi(shardGetRequest.ShardId)
+
+	manifest, err := getShardManifest(shardGetRequest.Filehash)
+	if err != nil || manifest.LocalShardId != shardId {
+		http.Error(w, "Shard not found.", 404)
+		return
+	}
+	shardFile, err := fetchShard(manifest, shardId)
+	if err != nil {
+		http.Error(w, "Can not read shard.", 500)
+		return
+	}
+	defer removeShardFiles([]*os.File{shardFile})
+	shardFile.Seek(0, 0)
+	io.Copy(w, shardFile)
+}
+
+// shardPutHandler stores a shard pushed by the node which split the file, the body
+// is the restored content of the shard.
+func shardPutHandler(w http.ResponseWriter, r *http.Request) {
+	shardPutRequest := ShardPutRequest{
+		Filehash: r.URL.Query().Get("file_hash"),
+		ShardId:  r.URL.Query().Get("shard_id"),
+		Hash:     r.URL.Query().Get("hash"),
+	}
+	if errs := validator.Validate(shardPutRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid parameter: %v", errs), 400)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed.", 405)
+		return
+	}
+	if !authorizeOperator(w, r, Write, shardPutRequest.Filehash) {
+		return
+	}
+	shardId, _ := strconv.Atoi(shardPutRequest.ShardId)
+	if ecDataShards == 0 || shardId >= ecDataShards+ecParityShards {
+		http.Error(w, "Shard id out of range.", 400)
+		return
+	}
+
+	shardFile, err := ioutil.TempFile("", IpfsPrefix)
+	if err != nil {
+		http.Error(w, "Can not store shard.", 500)
+		return
+	}
+	defer removeShardFiles([]*os.File{shardFile})
+	if _, err := io.Copy(shardFile, r.Body); err != nil {
+		http.Error(w, "Can not read shard.", 400)
+		return
+	}
+	if h, _ := sha256File(shardFile); h != shardPutRequest.Hash {
+		http.Error(w, "Shard content mismatch.", 400)
+		return
+	}
+	size, _ := shardFile.Seek(0, io.SeekEnd)
+	cid, err := storeShard(shardPutRequest.Filehash, shardId, shardFile, size)
+	if err != nil {
+		log.Error("Can not store pushed shard", shardPutRequest.Filehash, shardId, err)
+		http.Error(w, "Can not store shard.", 500)
+		return
+	}
+	bytesWrittenTotal.Add(float64(size))
+	log.Info("Stored pushed shard", shardPutRequest.Filehash, shardId, cid)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ShardPutResponse{Filehash: shardPutRequest.Filehash, ShardId: shardId, Cid: cid})
+}
+
+// shardManifestHandler serves the manifest of a sharded file, and saves the
+// manifest posted by the node which split it.
+func shardManifestHandler(w http.ResponseWriter, r *http.Request) {
+	fileHash := r.URL.Query().Get("file_hash")
+	readRequest := ReadRequest{Filehash: fileHash}
+	if errs := validator.Validate(readRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
+		return
+	}
+	if r.Method == http.MethodPost {
+		saveShardManifestHandler(w, r, fileHash)
+		return
+	}
+	if _, ok := authorize(w, r, Read, fileHash); !ok {
+		return
+	}
+
+	manifest, err := getShardManifest(fileHash)
+	if err != nil {
+		http.Error(w, "Manifest not found.", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+func saveShardManifestHandler(w http.ResponseWriter, r *http.Request, fileHash string) {
+	if !authorizeOperator(w, r, Write, fileHash) {
+		return
+	}
+	manifest := new(ShardManifest)
+	if err := json.NewDecoder(r.Body).Decode(manifest); err != nil {
+		http.Error(w, "Invalid manifest.", 400)
+		return
+	}
+	n := manifest.DataShards + manifest.ParityShards
+	if manifest.Filehash != fileHash || manifest.DataShards <= 0 || len(manifest.Shards) != n ||
+		manifest.LocalShardId < 0 || manifest.LocalShardId >= n {
+		http.Error(w, "Invalid manifest.", 400)
+		return
+	}
+	// the manifest must point at the shard this node stored
+	if cid := GetAlteredFileHash(shardKey(fileHash, manifest.LocalShardId)); cid == "" || cid != manifest.Shards[manifest.LocalShardId].Cid {
+		http.Error(w, "Shard not stored on this node.", 409)
+		return
+	}
+	if err := saveShardManifest(manifest); err != nil {
+		http.Error(w, "Can not save manifest.", 500)
+		return
+	}
+	log.Info("Saved shard manifest", fileHash, manifest.LocalShardId)
+}
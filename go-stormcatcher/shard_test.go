@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func encodeTestInput(fileHash string, accessType AccessType, shardId int) string {
+	word := func(n int) string {
+		return fmt.Sprintf("%064x", n)
+	}
+	data := hex.EncodeToString([]byte(fileHash))
+	data += strings.Repeat("0", 128-len(data))
+	input := "633fb659" + word(32) + word(int(accessType)) + word(len(fileHash)) + data
+	if shardId != noShardId {
+		input += word(shardId)
+	}
+	return input
+}
+
+func TestDecodeInput(t *testing.T) {
+	Convey("Test decode catch input", t, func() {
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+
+		Convey("Input without shard id", func() {
+			h, accessType, shardId, err := decodeInput(encodeTestInput(fileHash, Write, noShardId))
+			So(err, ShouldBeNil)
+			So(h, ShouldEqual, fileHash)
+			So(accessType, ShouldEqual, Write)
+			So(shardId, ShouldEqual, noShardId)
+		})
+
+		Convey("Input with shard id", func() {
+			h, accessType, shardId, err := decodeInput(encodeTestInput(fileHash, Read, 3))
+			So(err, ShouldBeNil)
+			So(h, ShouldEqual, fileHash)
+			So(accessType, ShouldEqual, Read)
+			So(shardId, ShouldEqual, 3)
+		})
+
+		Convey("Input with wrong function code", func() {
+			_, _, _, err := decodeInput("deadbeef" + encodeTestInput(fileHash, Read, 3)[8:])
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func copyTestShard(f *os.File) *os.File {
+	f.Seek(0, 0)
+	content, _ := ioutil.ReadAll(f)
+	c, _ := ioutil.TempFile("", IpfsPrefix)
+	c.Write(content)
+	return c
+}
+
+func TestErasureCoding(t *testing.T) {
+	Convey("Test erasure coded shards", t, func() {
+		fileSize := 100003
+		original := generateTestFile(fileSize)
+		defer os.Remove(original.Name())
+
+		shardFiles, err := encodeShards(original, int64(fileSize), 4, 2)
+		So(err, ShouldBeNil)
+		defer removeShardFiles(shardFiles)
+		So(len(shardFiles), ShouldEqual, 6)
+
+		manifest := &ShardManifest{Filehash: "test", Size: int64(fileSize), DataShards: 4, ParityShards: 2, LocalShardId: noShardId}
+		for i, f := range shardFiles {
+			h, _ := sha256File(f)
+			manifest.Shards = append(manifest.Shards, ShardInfo{ShardId: i, Hash: h})
+		}
+
+		Convey("Reconstruct from any 4 of 6 shards", func() {
+			// shard 1 is lost and shard 2 is corrupted
+			stubs := Stub(&fetchShard, func(manifest *ShardManifest, shardId int) (*os.File, error) {
+				switch shardId {
+				case 1:
+					return nil, fmt.Errorf("node offline")
+				case 2:
+					c := copyTestShard(shardFiles[shardId])
+					c.WriteAt([]byte("corrupted"), 0)
+					return c, nil
+				}
+				return copyTestShard(shardFiles[shardId]), nil
+			})
+			defer stubs.Reset()
+
+			restored, err := reconstructFile(manifest)
+			So(err, ShouldBeNil)
+			defer removeShardFiles([]*os.File{restored})
+			restored.Seek(0, 0)
+			content, _ := ioutil.ReadAll(restored)
+			So(bytes.Equal(content, generateTestFileContent(fileSize)), ShouldBeTrue)
+		})
+
+		Convey("Fail with less than 4 shards", func() {
+			stubs := Stub(&fetchShard, func(manifest *ShardManifest, shardId int) (*os.File, error) {
+				if shardId < 3 {
+					return nil, fmt.Errorf("node offline")
+				}
+				return copyTestShard(shardFiles[shardId]), nil
+			})
+			defer stubs.Reset()
+
+			_, err := reconstructFile(manifest)
+			So(err, ShouldEqual, errNotEnoughShards)
+		})
+	})
+}
+
+func TestShardWrite(t *testing.T) {
+	Convey("Test shards are pushed to their node", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_shard_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		// signed requests are covered by TestAuth
+		stubs.Stub(&authEnabled, false)
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir)
+		initBlobStore()
+		stubs.Stub(&ecDataShards, 4)
+		stubs.Stub(&ecParityShards, 2)
+		stubs.Stub(&shardPeers, []string{"", "peer1", "peer2", "peer3", "peer4", "peer5"})
+
+		fileSize := 100003
+		content := generateTestFileContent(fileSize)
+		original := generateTestFile(fileSize)
+		defer os.Remove(original.Name())
+		network := testIpfsNetwork{}
+		fileHash := network.add(original)
+		stubs.Stub(&checkoutIPFSFile, network.checkout)
+
+		// the peers keep their shard in a blob store of their own
+		peerStore, _ := newFsBlobStore(filepath.Join(dir, "peers"))
+		offline := map[string]bool{}
+		pushed := map[int]string{}
+		stubs.Stub(&pushShard, func(node string, originalFileHash string, shardId int, hash string, shardFile *os.File) (string, error) {
+			if offline[node] {
+				return "", fmt.Errorf("node offline")
+			}
+			if h, _ := sha256File(shardFile); h != hash {
+				return "", fmt.Errorf("shard content mismatch")
+			}
+			alteredTmpfile := createAlteredTmpFile(shardFile)
+			defer os.Remove(alteredTmpfile.Name())
+			alteredTmpfile.Seek(0, 0)
+			cid, err := peerStore.Put(alteredTmpfile)
+			pushed[shardId] = node
+			return cid, err
+		})
+		manifests := map[string]*ShardManifest{}
+		stubs.Stub(&pushShardManifest, func(node string, manifest *ShardManifest) error {
+			manifests[node] = manifest
+			return nil
+		})
+		write := func() error {
+			mShardWriteRequest, _ := json.Marshal(ShardWriteRequest{Filehash: fileHash, ShardId: 0})
+			return HandleShardWrite(string(mShardWriteRequest))
+		}
+
+		Convey("The manifest records the id of every shard", func() {
+			So(write(), ShouldBeNil)
+			So(len(pushed), ShouldEqual, 5)
+			manifest, err := getShardManifest(fileHash)
+			So(err, ShouldBeNil)
+			So(manifest.LocalShardId, ShouldEqual, 0)
+			for i, shard := range manifest.Shards {
+				So(shard.Cid, ShouldNotBeEmpty)
+				if i > 0 {
+					So(manifests[shard.Node].LocalShardId, ShouldEqual, i)
+					So(manifests[shard.Node].Shards, ShouldResemble, manifest.Shards)
+				}
+			}
+			So(isBlobPinned(manifest.Shards[0].Cid), ShouldBeTrue)
+
+			Convey("Writing the file again does nothing", func() {
+				pushed = map[int]string{}
+				So(write(), ShouldBeNil)
+				So(len(pushed), ShouldEqual, 0)
+			})
+
+			Convey("Shards are fetched by their id to serve the file", func() {
+				stubs.Stub(&fetchShard, func(manifest *ShardManifest, shardId int) (*os.File, error) {
+					var store BlobStore = peerStore
+					if shardId == manifest.LocalShardId {
+						store = blobStore
+					}
+					r, err := store.Get(manifest.Shards[shardId].Cid)
+					if err != nil {
+						return nil, err
+					}
+					defer r.Close()
+					alteredTmpfile, _ := ioutil.TempFile("", IpfsPrefix)
+					defer os.Remove(alteredTmpfile.Name())
+					io.Copy(alteredTmpfile, r)
+					return createRestoredTmpFile(alteredTmpfile), nil
+				})
+				w := httptest.NewRecorder()
+				gatewayHandler(w, httptest.NewRequest("GET", "/files/"+fileHash, nil))
+				So(w.Code, ShouldEqual, 200)
+				So(bytes.Equal(w.Body.Bytes(), content), ShouldBeTrue)
+			})
+		})
+
+		Convey("The file is stored while enough shards are", func() {
+			offline["peer5"] = true
+			So(write(), ShouldBeNil)
+			manifest, _ := getShardManifest(fileHash)
+			So(manifest.Shards[5].Cid, ShouldBeEmpty)
+			So(manifests["peer5"], ShouldBeNil)
+
+			offline["peer2"], offline["peer3"] = true, true
+			So(write(), ShouldBeNil)
+		})
+
+		Convey("The write fails with less than data shards stored", func() {
+			offline["peer1"], offline["peer2"], offline["peer3"] = true, true, true
+			So(write(), ShouldEqual, errNotEnoughShards)
+			So(isShardedFile(fileHash), ShouldBeFalse)
+		})
+	})
+
+	Convey("Test a pushed shard is stored", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_shard_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&authEnabled, false)
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir)
+		initBlobStore()
+		stubs.Stub(&ecDataShards, 4)
+		stubs.Stub(&ecParityShards, 2)
+
+		fileHash := "QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+		shard := generateTestFileContent(1000)
+		h := sha256.Sum256(shard)
+		hash := hex.EncodeToString(h[:])
+		put := func(hash string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			path := fmt.Sprintf("/shard/put?file_hash=%s&shard_id=3&hash=%s", fileHash, hash)
+			shardPutHandler(w, httptest.NewRequest("POST", path, bytes.NewReader(shard)))
+			return w
+		}
+		postManifest := func(manifest *ShardManifest) int {
+			mManifest, _ := json.Marshal(manifest)
+			w := httptest.NewRecorder()
+			shardManifestHandler(w, httptest.NewRequest("POST", "/shard/manifest?file_hash="+fileHash, bytes.NewReader(mManifest)))
+			return w.Code
+		}
+
+		w := put(hash)
+		So(w.Code, ShouldEqual, 200)
+		var m ShardPutResponse
+		json.Unmarshal(w.Body.Bytes(), &m)
+		So(m.Cid, ShouldEqual, GetAlteredFileHash(shardKey(fileHash, 3)))
+		So(isBlobPinned(m.Cid), ShouldBeTrue)
+
+		Convey("Pushing it again keeps the stored shard", func() {
+			w := put(hash)
+			var again ShardPutResponse
+			json.Unmarshal(w.Body.Bytes(), &again)
+			So(again.Cid, ShouldEqual, m.Cid)
+		})
+
+		Convey("A shard with another content is refused", func() {
+			So(put(strings.Repeat("0", 64)).Code, ShouldEqual, 400)
+		})
+
+		Convey("The manifest must point at the stored shard", func() {
+			manifest := &ShardManifest{Filehash: fileHash, Size: 4000, DataShards: 4, ParityShards: 2, ShardSize: 1000, LocalShardId: 3}
+			for i := 0; i < 6; i++ {
+				manifest.Shards = append(manifest.Shards, ShardInfo{ShardId: i, Cid: fmt.Sprintf("cid%d", i)})
+			}
+			So(postManifest(manifest), ShouldEqual, 409)
+			manifest.Shards[3].Cid = m.Cid
+			So(postManifest(manifest), ShouldEqual, 200)
+			saved, _ := getShardManifest(fileHash)
+			So(saved.LocalShardId, ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// storage backend names accepted by --store-backend
+const (
+	RedisBackend   = "redis"
+	LevelDBBackend = "leveldb"
+	MemoryBackend  = "memory"
+)
+
+var errKeyNotFound = errors.New("key not found")
+
+var errQueueEmpty = errors.New("queue empty")
+
+// Queue is a set of named FIFO task queues feeding the throttled queue handlers.
+type Queue interface {
+	// Push adds a task to the tail of the named queue.
+	Push(queueName string, task string) error
+	// PopBlock removes the oldest task from the named queue, blocking until one is available.
+	// It returns errQueueEmpty once the timeout elapsed, a zero timeout blocks without limit.
+	PopBlock(queueName string, timeout time.Duration) (string, error)
+	// Len returns the number of tasks waiting in the named queue.
+	Len(queueName string) (int64, error)
+}
+
+// MetadataStore keeps the file hash mapping, the file stat hash and the unpin sorted set.
+// Hash tables map a key to a string value, sorted sets map a member to an int64 score.
+type MetadataStore interface {
+	// HashGet returns "" and no error if the key does not exist.
+	HashGet(table string, key string) (string, error)
+	HashSet(table string, key string, value string) error
+	// HashDelete reports whether the key existed.
+	HashDelete(table string, key string) (bool, error)
+	HashKeys(table string) ([]string, error)
+
+	// SortedSetAdd inserts the member or updates its score if it already exists.
+	SortedSetAdd(set string, member string, score int64) error
+	SortedSetRemove(set string, member string) error
+	// SortedSetRangeByScore returns members with min <= score <= max, lowest score first.
+	SortedSetRangeByScore(set string, min int64, max int64) ([]string, error)
+	// SortedSetScore returns errKeyNotFound if the member does not exist.
+	SortedSetScore(set string, member string) (int64, error)
+
+	Ping() error
+	Close() error
+}
+
+var taskQueue Queue
+var metadataStore MetadataStore
+
+// initStoreBackend sets up taskQueue and metadataStore according to storeBackend.
+func initStoreBackend() {
+	queue, store, err := newStoreBackend(storeBackend)
+	if err != nil {
+		log.Fatal("Can not init store backend", storeBackend, err)
+	}
+	taskQueue = queue
+	metadataStore = store
+}
+
+func newStoreBackend(backend string) (Queue, MetadataStore, error) {
+	switch backend {
+	case RedisBackend:
+		s := newRedisStore(redisHostPort, "")
+		return s, s, nil
+	case LevelDBBackend:
+		s, err := newLevelDBStore(storePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s, nil
+	case MemoryBackend:
+		s := newMemoryStore()
+		return s, s, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+}
+
+// condWaitUntil waits on cond, whose lock must be held, and reports false once the
+// deadline passed so the caller can give up. A zero deadline waits without limit.
+func condWaitUntil(cond *sync.Cond, deadline time.Time) bool {
+	if deadline.IsZero() {
+		cond.Wait()
+		return true
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	t := time.AfterFunc(remaining, func() {
+		cond.L.Lock()
+		cond.Broadcast()
+		cond.L.Unlock()
+	})
+	cond.Wait()
+	t.Stop()
+	return true
+}
+
+func popDeadline(timeout time.Duration) time.Time {
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
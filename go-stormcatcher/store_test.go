@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func unpinFileHashQueueGet(unpinFileHash string) (int64, error) {
+	return metadataStore.SortedSetScore(IpfsUnpinFileHashQueueName, unpinFileHash)
+}
+
+func testStoreBackend(queue Queue, store MetadataStore) {
+	Convey("Queue is first in first out", func() {
+		queue.Push("test_queue", "task_1")
+		queue.Push("test_queue", "task_2")
+		queue.Push("other_queue", "task_3")
+		n, _ := queue.Len("test_queue")
+		So(n, ShouldEqual, 2)
+
+		task, err := queue.PopBlock("test_queue", 0)
+		So(err, ShouldBeNil)
+		So(task, ShouldEqual, "task_1")
+		task, _ = queue.PopBlock("test_queue", 0)
+		So(task, ShouldEqual, "task_2")
+		n, _ = queue.Len("test_queue")
+		So(n, ShouldEqual, 0)
+	})
+
+	Convey("PopBlock waits for new tasks", func() {
+		done := make(chan string)
+		go func() {
+			task, _ := queue.PopBlock("blocking_queue", 0)
+			done <- task
+		}()
+		time.Sleep(time.Duration(50) * time.Millisecond)
+		queue.Push("blocking_queue", "late_task")
+		select {
+		case task := <-done:
+			So(task, ShouldEqual, "late_task")
+		case <-time.After(time.Duration(2) * time.Second):
+			So("PopBlock timed out", ShouldBeEmpty)
+		}
+	})
+
+	Convey("PopBlock gives up after the timeout", func() {
+		start := time.Now()
+		_, err := queue.PopBlock("empty_queue", time.Duration(100)*time.Millisecond)
+		So(err, ShouldEqual, errQueueEmpty)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, time.Duration(100)*time.Millisecond)
+	})
+
+	Convey("Hash set, get and delete", func() {
+		v, err := store.HashGet(IpfsFileHashMappingName, "missing")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "")
+
+		store.HashSet(IpfsFileHashMappingName, "original", "altered")
+		store.HashSet(IpfsFileHashMappingName, "altered", "original")
+		v, _ = store.HashGet(IpfsFileHashMappingName, "original")
+		So(v, ShouldEqual, "altered")
+		keys, _ := store.HashKeys(IpfsFileHashMappingName)
+		So(keys, ShouldResemble, []string{"altered", "original"})
+
+		deleted, _ := store.HashDelete(IpfsFileHashMappingName, "original")
+		So(deleted, ShouldBeTrue)
+		deleted, _ = store.HashDelete(IpfsFileHashMappingName, "original")
+		So(deleted, ShouldBeFalse)
+		v, _ = store.HashGet(IpfsFileHashMappingName, "original")
+		So(v, ShouldEqual, "")
+	})
+
+	Convey("Sorted set range and update", func() {
+		t := time.Now().Unix()
+		store.SortedSetAdd(IpfsUnpinFileHashQueueName, "some_hash_3", t+3)
+		store.SortedSetAdd(IpfsUnpinFileHashQueueName, "some_hash_1", t+1)
+		store.SortedSetAdd(IpfsUnpinFileHashQueueName, "some_hash_2", t+2)
+
+		members, _ := store.SortedSetRangeByScore(IpfsUnpinFileHashQueueName, t, t+2)
+		So(members, ShouldResemble, []string{"some_hash_1", "some_hash_2"})
+
+		// updating the score moves the member instead of adding a new one
+		store.SortedSetAdd(IpfsUnpinFileHashQueueName, "some_hash_1", t+5)
+		members, _ = store.SortedSetRangeByScore(IpfsUnpinFileHashQueueName, t, t+10)
+		So(members, ShouldResemble, []string{"some_hash_2", "some_hash_3", "some_hash_1"})
+		score, _ := store.SortedSetScore(IpfsUnpinFileHashQueueName, "some_hash_1")
+		So(score, ShouldEqual, t+5)
+
+		store.SortedSetRemove(IpfsUnpinFileHashQueueName, "some_hash_2")
+		members, _ = store.SortedSetRangeByScore(IpfsUnpinFileHashQueueName, t, t+10)
+		So(members, ShouldResemble, []string{"some_hash_3", "some_hash_1"})
+		_, err := store.SortedSetScore(IpfsUnpinFileHashQueueName, "some_hash_2")
+		So(err, ShouldEqual, errKeyNotFound)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	Convey("Test memory store backend", t, func() {
+		s := newMemoryStore()
+		testStoreBackend(s, s)
+	})
+}
+
+func TestLevelDBStore(t *testing.T) {
+	Convey("Test leveldb store backend", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_leveldb_test_")
+		defer os.RemoveAll(dir)
+		s, err := newLevelDBStore(dir)
+		So(err, ShouldBeNil)
+		defer s.Close()
+		testStoreBackend(s, s)
+	})
+
+	Convey("Test leveldb queue survives reopen", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_leveldb_test_")
+		defer os.RemoveAll(dir)
+		s, _ := newLevelDBStore(dir)
+		s.Push(IpfsWriteQueueName, "task_1")
+		s.Push(IpfsWriteQueueName, "task_2")
+		s.Close()
+
+		s, _ = newLevelDBStore(dir)
+		defer s.Close()
+		s.Push(IpfsWriteQueueName, "task_3")
+		task, _ := s.PopBlock(IpfsWriteQueueName, 0)
+		So(task, ShouldEqual, "task_1")
+		task, _ = s.PopBlock(IpfsWriteQueueName, 0)
+		So(task, ShouldEqual, "task_2")
+		task, _ = s.PopBlock(IpfsWriteQueueName, 0)
+		So(task, ShouldEqual, "task_3")
+	})
+}
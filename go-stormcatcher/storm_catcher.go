@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	validator "gopkg.in/validator.v2"
 )
@@ -15,9 +20,36 @@ import (
 var log = setupLogging(true)
 
 func init() {
+	flag.StringVar(&configFile, "config", "", "TOML or YAML config file, flags given on the command line take precedence")
 	flag.StringVar(&listenAddressAndPort, "listen-host-port", "127.0.0.1:18080", "host:port, e.g. 127.0.0.1:18080")
 	flag.StringVar(&redisHostPort, "redis-host-port", "localhost:6379", "host:port, e.g. 127.0.0.1:6379")
 	flag.StringVar(&ipfsHostPort, "ipfs-host-port", "localhost:5001", "host:port, e.g. 127.0.0.1:5001")
+	flag.StringVar(&storeBackend, "store-backend", RedisBackend, "queue and metadata backend: redis, leveldb or memory")
+	flag.StringVar(&storePath, "store-path", "./stormcatcher_db", "database directory of the leveldb store backend")
+	flag.StringVar(&blobStoreType, "blob-store", IpfsBlobStore, "where altered files are stored: ipfs, fs or s3")
+	flag.StringVar(&blobStorePath, "blob-store-path", "./stormcatcher_blobs", "root directory of the fs blob store")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "http://127.0.0.1:9000", "endpoint of the s3 blob store")
+	flag.StringVar(&s3Region, "s3-region", "us-east-1", "region of the s3 blob store")
+	flag.StringVar(&s3Bucket, "s3-bucket", "stormcatcher", "bucket of the s3 blob store")
+	flag.StringVar(&s3AccessKey, "s3-access-key", "", "access key of the s3 blob store")
+	flag.StringVar(&s3SecretKey, "s3-secret-key", "", "secret key of the s3 blob store")
+	flag.IntVar(&ecDataShards, "ec-data-shards", 0, "number of reed-solomon data shards, 0 disables erasure coding")
+	flag.IntVar(&ecParityShards, "ec-parity-shards", 0, "number of reed-solomon parity shards")
+	flag.IntVar(&nodeShardId, "shard-id", 0, "shard id kept by this node when the request does not give one")
+	flag.StringVar(&shardPeersString, "shard-peers", "", "stormcatcher host:port of each shard id, comma separated")
+	flag.IntVar(&replicationFactor, "replication-factor", 1, "number of nodes that should hold a copy of each file")
+	flag.StringVar(&replicationPeersString, "replication-peers", "", "peer stormcatcher host:port list used for replication, comma separated")
+	flag.BoolVar(&authEnabled, "auth", true, "require signed requests on every endpoint serving or changing stored data")
+	flag.StringVar(&operatorAddressesString, "operator-addresses", "", "addresses allowed to access any file, comma separated")
+	flag.StringVar(&operatorKeyFile, "operator-key-file", "", "hex private key file used to sign requests sent to peers and contract transactions, it must be an operator of the storage contract")
+	flag.StringVar(&chainRPC, "chain-rpc", "", "rpc endpoint of the chain holding the storage contract, e.g. http://127.0.0.1:8545")
+	flag.StringVar(&dealContractAddress, "deal-contract", "", "address of the storage deal contract, deals are disabled if not set")
+	flag.Int64Var(&dealWarnPeriod, "deal-warn-period", 7*24*3600, "seconds before paid until a deal is renewed or warned about")
+	flag.Int64Var(&dealGracePeriod, "deal-grace-period", 3*24*3600, "seconds after paid until before an unpaid file is deleted")
+	flag.StringVar(&uploadPath, "upload-path", "./stormcatcher_uploads", "directory of the altered data of uploads in progress")
+	flag.Int64Var(&uploadMaxSize, "upload-max-size", 0, "largest upload accepted in bytes, 0 is unlimited")
+	flag.IntVar(&queueConcurrency, "queue-concurrency", queueConcurrency, "number of workers of each queue")
+	flag.IntVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeout, "seconds given to tasks in flight on shutdown, unfinished ones are queued again on the next start")
 	// 加合约 --sub-chain-base
 }
 
@@ -75,13 +107,17 @@ type DirectDownloadRequest struct {
 
 func readHandler(w http.ResponseWriter, r *http.Request) {
 	// sample query:
-	// curl "http://127.0.0.1:18080/ipfs/read?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	// curl -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" -H "X-Storm-Signature: 0x..." \
+	//   "http://127.0.0.1:18080/ipfs/read?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
 	fileHash := r.URL.Query().Get("file_hash")
 	readRequest := ReadRequest{Filehash: fileHash}
 	if errs := validator.Validate(readRequest); errs != nil {
 		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
 		return
 	}
+	if _, ok := authorize(w, r, Read, fileHash); !ok {
+		return
+	}
 
 	if err := AddTaskToQueue(IpfsReadQueueName, fileHash); err != nil {
 		http.Error(w, "Can not enqueue read request.", 500)
@@ -91,29 +127,39 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 
 func writeHandler(w http.ResponseWriter, r *http.Request) {
 	// sample query:
-	// curl "http://127.0.0.1:18080/ipfs/write?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	// curl -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" -H "X-Storm-Signature: 0x..." \
+	//   "http://127.0.0.1:18080/ipfs/write?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
 	fileHash := r.URL.Query().Get("file_hash")
 	writeRequest := WriteRequest{Filehash: fileHash}
 	if errs := validator.Validate(writeRequest); errs != nil {
 		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
 		return
 	}
+	signer, ok := authorize(w, r, Write, fileHash)
+	if !ok {
+		return
+	}
 
 	if err := AddTaskToQueue(IpfsWriteQueueName, fileHash); err != nil {
 		http.Error(w, "Can not enqueue write request.", 500)
 		return
 	}
+	claimFileOwner(fileHash, signer)
 }
 
 func deleteHandler(w http.ResponseWriter, r *http.Request) {
 	// sample query:
-	// curl "http://127.0.0.1:18080/ipfs/delete?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
+	// curl -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" -H "X-Storm-Signature: 0x..." \
+	//   "http://127.0.0.1:18080/ipfs/delete?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN"
 	fileHash := r.URL.Query().Get("file_hash")
 	deleteRequest := DeleteRequest{Filehash: fileHash}
 	if errs := validator.Validate(deleteRequest); errs != nil {
 		http.Error(w, fmt.Sprintf("Invalid file_hash parameter: %v", errs), 400)
 		return
 	}
+	if _, ok := authorize(w, r, Remove, fileHash); !ok {
+		return
+	}
 
 	if err := AddTaskToQueue(IpfsDeleteQueueName, fileHash); err != nil {
 		http.Error(w, "Can not enqueue delete request.", 500)
@@ -123,11 +169,11 @@ func deleteHandler(w http.ResponseWriter, r *http.Request) {
 
 func verifyHandler(w http.ResponseWriter, r *http.Request) {
 	// sample query:
-	// curl "http://127.0.0.1:18080/verify?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN&offset=0"
+	// curl -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" -H "X-Storm-Signature: 0x..." \
+	//   "http://127.0.0.1:18080/verify?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN&offset=0"
+	// curl -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" -H "X-Storm-Signature: 0x..." \
+	//   "http://127.0.0.1:18080/verify?file_hash=QmTor1GsqZQwJdFoTYjAdEEjXDZgYDm1oc3Lj8waHUKRFN&offset=0&shard_id=2"
 	q := r.URL.Query()
-	randomInt := q.Get("random_int")
-	block_number:= q.Get("block_number")
-	next_number := q.Get("next_number")
 
 	// 需要拿到合约地址
 	// 调用合约拿到合约里的fileHash[] fileHash是一个struct, 里面有被验证次数，给文件一个ID
@@ -137,29 +183,59 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 发一个交易，把结果写到子链链上。data： ‘shard ID: 哈希值’
 
-	// verifyRequest := VerifyRequest{Filehash: fileHash, Offset: randomInt}
-	// if errs := validator.Validate(verifyRequest); errs != nil {
-	// 	http.Error(w, fmt.Sprintf("Invalid verify parameter: %v", errs), 400)
-	// 	return
-	// }
-
-	// offset, errParse := strconv.ParseInt(verifyRequest.Offset, 10, 64)
-	// if errParse != nil {
-	// 	http.Error(w, "Can not parse offset value.", 400)
-	// 	return
-	// }
-
-	// err, result := HandleIPFSVerify(fileHash, offset)
-	// if err != nil {
-	// 	http.Error(w, err.Error(), 404)
-	// }
-	// w.Write(result)
+	verifyRequest := VerifyRequest{Filehash: q.Get("file_hash"), Offset: q.Get("offset")}
+	if errs := validator.Validate(verifyRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid verify parameter: %v", errs), 400)
+		return
+	}
+	if _, ok := authorize(w, r, Verify, verifyRequest.Filehash); !ok {
+		return
+	}
+
+	offset, errParse := strconv.ParseInt(verifyRequest.Offset, 10, 64)
+	if errParse != nil {
+		http.Error(w, "Can not parse offset value.", 400)
+		return
+	}
+
+	var err error
+	var result []byte
+	if shardIdString := q.Get("shard_id"); shardIdString != "" {
+		shardId, errParse := strconv.Atoi(shardIdString)
+		if errParse != nil {
+			http.Error(w, "Can not parse shard_id value.", 400)
+			return
+		}
+		err, result = HandleShardVerify(verifyRequest.Filehash, shardId, offset)
+	} else {
+		err, result = HandleIPFSVerify(verifyRequest.Filehash, offset)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	w.Write(result)
 }
 
 func catchHandler(w http.ResponseWriter, r *http.Request) {
 	input := r.URL.Query().Get("input")
 
-	switch fileHash, accessType, shardId, ipfsId, nil := decodeInput(input); accessType {
+	fileHash, accessType, shardId, err := decodeInput(input)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid input parameter: %v", err), 400)
+		return
+	}
+	if accessType != Read && accessType != Write && accessType != Remove {
+		http.Error(w, "Invalid access type.", 400)
+		return
+	}
+	// the decoded file hash is validated below before anything is enqueued
+	signer, ok := authorize(w, r, accessType, fileHash)
+	if !ok {
+		return
+	}
+
+	switch accessType {
 	case Remove:
 		deleteRequest := DeleteRequest{Filehash: fileHash}
 		if errs := validator.Validate(deleteRequest); errs != nil {
@@ -178,10 +254,22 @@ func catchHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if ecDataShards > 0 {
+			// erasure coded, this node only keeps the shard assigned to it
+			if shardId == noShardId {
+				shardId = nodeShardId
+			}
+			if enqueueShardWrite(w, fileHash, shardId) {
+				claimFileOwner(fileHash, signer)
+			}
+			return
+		}
+
 		if err := AddTaskToQueue(IpfsWriteQueueName, fileHash); err != nil {
 			http.Error(w, "Can not enqueue write request.", 500)
 			return
 		}
+		claimFileOwner(fileHash, signer)
 	case Read:
 		readRequest := ReadRequest{Filehash: fileHash}
 		if errs := validator.Validate(readRequest); errs != nil {
@@ -189,7 +277,11 @@ func catchHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := AddTaskToQueue(IpfsReadQueueName, fileHash); err != nil {
+		queueName := IpfsReadQueueName
+		if isShardedFile(fileHash) {
+			queueName = IpfsShardReadQueueName
+		}
+		if err := AddTaskToQueue(queueName, fileHash); err != nil {
 			http.Error(w, "Can not enqueue read request.", 500)
 			return
 		}
@@ -199,19 +291,20 @@ func catchHandler(w http.ResponseWriter, r *http.Request) {
 // 新合约会要加上调用服务器的IPFS ADDR
 
 // DecodeIpfsParams decodes input byte array and returns the value of ipfsFile parameters.
-func decodeInput(hexString string) (string, AccessType, uint shardId, string ipfsId, error) {
+// The shard id is an optional word following the file hash, it is noShardId if not given.
+func decodeInput(hexString string) (string, AccessType, int, error) {
 	invalidIpfsHasErr := errors.New("invalid ipfs hash format")
 	log.Debugf("hexString, |%v|", hexString)
 	if len(hexString) < 266 {
 		log.Errorf("Ipfs Input Code is too short. %v", hexString)
-		return "", 0, invalidIpfsHasErr
+		return "", 0, noShardId, invalidIpfsHasErr
 	}
 	log.Debugf("hexString, %v", hexString)
 
 	funcCode := hexString[:8] //get function code
 	if funcCode != "633fb659" {
 		log.Errorf("Invalid ipfs function call. %v", funcCode)
-		return "", 0, errors.New("invalid function call.")
+		return "", 0, noShardId, errors.New("invalid function call.")
 	}
 	hexString = hexString[8:] //remove function code "633fb659"
 
@@ -226,24 +319,38 @@ func decodeInput(hexString string) (string, AccessType, uint shardId, string ipf
 	// hexString = hexString[64:] //remove access mode
 	hexString = hexString[64:] //remove another parameter "000000000000000000000000000000000000000000000000000000000000002e"
 
+	// the 46 bytes file hash is padded to two words, the shard id follows if present
+	shardId := noShardId
+	if len(hexString) >= 192 {
+		shardIdString := hexString[128:192]
+		log.Debugf("shardIdString, %v", shardIdString)
+		id, err := strconv.ParseInt(shardIdString, 16, 32)
+		if err != nil {
+			log.Errorf("Invalid shard id. %v", shardIdString)
+			return "", 0, noShardId, err
+		}
+		shardId = int(id)
+		hexString = hexString[:128]
+	}
+
 	bs, err := hex.DecodeString(hexString)
 	if err != nil {
 		log.Errorf("Decode error: %v", err)
-		return "", 0, err
+		return "", 0, noShardId, err
 	}
 	fileHash := string(bs)
 	if len(fileHash) < 46 {
 		log.Errorf("Ipfs Hash is too short. %v", fileHash)
-		return "", 0, invalidIpfsHasErr
+		return "", 0, noShardId, invalidIpfsHasErr
 	}
 	fileHash = fileHash[0:46]
 	log.Debugf("fileHash, %v", fileHash)
 	if fileHash[0:2] != "Qm" {
 		log.Debugf("hex |%v|", hexString[0:2])
 		log.Errorf("Ipfs Hash is in wrong format. %v", fileHash)
-		return "", 0, invalidIpfsHasErr
+		return "", 0, noShardId, invalidIpfsHasErr
 	}
-	return fileHash, AccessType(accessType), nil
+	return fileHash, AccessType(accessType), shardId, nil
 }
 
 func proxyReadHandler(w http.ResponseWriter, r *http.Request) {
@@ -291,6 +398,8 @@ func proxyWriteHandler(w http.ResponseWriter, r *http.Request) {
 
 func main() {
 	flag.Parse()
+	// apply the config file, flags given on the command line take precedence
+	initConfig()
 
 	// verify does not use queue and should return immediately the result
 	http.HandleFunc("/verify", verifyHandler)
@@ -302,6 +411,32 @@ func main() {
 	http.HandleFunc("/ipfs/write", writeHandler)
 	http.HandleFunc("/ipfs/delete", deleteHandler)
 
+	// erasure coded shards
+	http.HandleFunc("/shard/write", shardWriteHandler)
+	http.HandleFunc("/shard/read", shardReadHandler)
+	http.HandleFunc("/shard/get", shardGetHandler)
+	http.HandleFunc("/shard/put", shardPutHandler)
+	http.HandleFunc("/shard/manifest", shardManifestHandler)
+
+	// replication
+	http.HandleFunc("/replica/has", replicaHasHandler)
+	http.HandleFunc("/replica/health", replicaHealthHandler)
+
+	// resumable uploads
+	http.HandleFunc("/uploads", uploadsHandler)
+	http.HandleFunc("/uploads/", uploadsHandler)
+
+	// storage deals
+	http.HandleFunc("/deals", dealsHandler)
+
+	// observability
+	http.Handle("/metrics", metricsHandler())
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+
+	// streaming gateway
+	http.HandleFunc("/files/", gatewayHandler)
+
 	// // proxy read/write
 	// http.HandleFunc("/proxy/read", proxyReadHandler)
 	// http.HandleFunc("/proxy/write", proxyWriteHandler)
@@ -312,15 +447,30 @@ func main() {
 
 	// print config
 	log.Info("StormCatcher:", listenAddressAndPort)
+	log.Info("Store backend:", storeBackend)
 	log.Info("Redis:", redisHostPort)
 	log.Info("Ipfs:", ipfsHostPort)
+	log.Info("Blob store:", blobStoreType)
+	shardPeers = parseHostPortList(shardPeersString)
+	replicationPeers = parseHostPortList(replicationPeersString)
+	log.Info("Replication factor:", replicationFactor, "peers", replicationPeers)
+	if ecDataShards > 0 {
+		log.Info("Erasure coding:", ecDataShards, "+", ecParityShards, "shard id", nodeShardId, "peers", shardPeers)
+	}
 
-	// init redis
-	initRedisClient()
+	// init queue and metadata backend
+	initStoreBackend()
+	// init blob store
+	initBlobStore()
+	// init request authentication
+	initAuth()
+	// init storage deal contract
+	initDeals()
+	// init upload directory
+	initUploads()
 	// init queue and queue handler
-	initThrottledQueues()
-	log.Info("Channel setup done. Storm Catcher is ready!")
-	initThrottledQueueHandlers()
+	initQueueWorkers()
+	log.Info("Queue workers started. Storm Catcher is ready!")
 
 	//init gc routine
 	initGCWorker()
@@ -328,6 +478,50 @@ func main() {
 	//init unpin worker
 	initUnpinWorker()
 
+	//init repair worker
+	initRepairWorker()
+
+	//init auth nonce worker
+	initAuthWorker()
+
+	//init storage deal worker
+	initDealWorker()
+
+	//init expired upload worker
+	initUploadWorker()
+
 	// start server
-	log.Critical(http.ListenAndServe(listenAddressAndPort, nil))
+	server := &http.Server{Addr: listenAddressAndPort}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// SIGHUP reloads the queue limits and intervals, SIGTERM and SIGINT drain the node and exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			reloadConfig()
+			continue
+		}
+		log.Info("Received", sig, "shutting down")
+		break
+	}
+	shutdown(server)
+}
+
+// shutdown stops accepting requests, then gives the tasks in flight shutdownTimeout
+// seconds to finish. Tasks still running then are queued again on the next start.
+func shutdown(server *http.Server) {
+	timeout := time.Duration(shutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("Can not shut down http server", err)
+	}
+	stopQueueWorkers(timeout)
+	metadataStore.Close()
+	log.Info("Storm Catcher stopped")
 }
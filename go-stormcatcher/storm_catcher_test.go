@@ -11,6 +11,10 @@ import (
 )
 
 func TestStormCatcherEndpoint(t *testing.T) {
+	// signed requests are covered by TestAuth
+	authStubs := Stub(&authEnabled, false)
+	defer authStubs.Reset()
+
 	Convey("Test Ipfs read endpoint", t, func() {
 		Convey("Handle normal case, return 200", func() {
 			// Create a request to pass to our handler.
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/big"
+)
+
+// Files uploaded to stormcatcher never go through an ipfs node before they are
+// altered, so their hash is computed here the way `ipfs add` does with its
+// defaults: 256KiB chunks, balanced layout with 174 links per node, unixfs file
+// nodes in dag-pb blocks and CIDv0.
+var unixfsChunkSize = 256 * 1024
+var unixfsMaxLinks = 174
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// unixfsNode is what a parent needs to link to a built node.
+type unixfsNode struct {
+	hash     []byte // sha2-256 multihash of the block
+	tsize    uint64 // size of the block and all its descendants
+	fileSize uint64 // bytes of file data below the node
+}
+
+// unixfsBuilder reads the chunks of a file one ahead, so it knows when the data
+// runs out like the dag builder of go-ipfs does.
+type unixfsBuilder struct {
+	r    io.Reader
+	next []byte
+	err  error
+}
+
+func (b *unixfsBuilder) prefetch() {
+	buf := make([]byte, unixfsChunkSize)
+	n, err := io.ReadFull(b.r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		b.err = err
+	}
+	b.next = buf[:n]
+}
+
+func (b *unixfsBuilder) done() bool {
+	return b.err != nil || len(b.next) == 0
+}
+
+func (b *unixfsBuilder) nextChunk() []byte {
+	chunk := b.next
+	b.prefetch()
+	return chunk
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// appendField appends a length delimited protobuf field.
+func appendField(buf []byte, tag byte, data []byte) []byte {
+	buf = append(buf, tag)
+	buf = appendVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// unixfsBlock encodes a dag-pb node holding unixfs file data and returns it as a linkable node.
+// Leaves carry data and no links, other nodes carry links and the file size of each child.
+func unixfsBlock(data []byte, children []unixfsNode) unixfsNode {
+	var fileSize uint64
+	if len(children) == 0 {
+		fileSize = uint64(len(data))
+	}
+	for _, child := range children {
+		fileSize += child.fileSize
+	}
+
+	// unixfs Data: Type = File, Data, filesize, blocksizes
+	fsData := []byte{0x08, 0x02}
+	if len(data) > 0 {
+		fsData = appendField(fsData, 0x12, data)
+	}
+	fsData = append(fsData, 0x18)
+	fsData = appendVarint(fsData, fileSize)
+	for _, child := range children {
+		fsData = append(fsData, 0x20)
+		fsData = appendVarint(fsData, child.fileSize)
+	}
+
+	// dag-pb PBNode: links first, then data. go-ipfs always writes the empty link name.
+	block := []byte{}
+	var tsize uint64
+	for _, child := range children {
+		link := appendField(nil, 0x0a, child.hash)
+		link = appendField(link, 0x12, nil)
+		link = append(link, 0x18)
+		link = appendVarint(link, child.tsize)
+		block = appendField(block, 0x12, link)
+		tsize += child.tsize
+	}
+	block = appendField(block, 0x0a, fsData)
+
+	digest := sha256.Sum256(block)
+	return unixfsNode{
+		hash:     append([]byte{0x12, 0x20}, digest[:]...),
+		tsize:    tsize + uint64(len(block)),
+		fileSize: fileSize,
+	}
+}
+
+// fill adds children to a node of the given depth until it is full or the data runs out.
+func (b *unixfsBuilder) fill(children []unixfsNode, depth int) unixfsNode {
+	for len(children) < unixfsMaxLinks && !b.done() {
+		if depth == 1 {
+			children = append(children, unixfsBlock(b.nextChunk(), nil))
+		} else {
+			children = append(children, b.fill(nil, depth-1))
+		}
+	}
+	return unixfsBlock(nil, children)
+}
+
+func base58Encode(b []byte) string {
+	x := new(big.Int).SetBytes(b)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	encoded := []byte{}
+	for x.Sign() > 0 {
+		x.DivMod(x, base, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// ipfsFileHash returns the CIDv0 `ipfs add` gives to the content of r.
+func ipfsFileHash(r io.Reader) (string, error) {
+	b := &unixfsBuilder{r: r}
+	b.prefetch()
+	// a single chunk file is a leaf, bigger ones get a new root each time the tree is full
+	root := unixfsBlock(b.nextChunk(), nil)
+	for depth := 1; !b.done(); depth++ {
+		root = b.fill([]unixfsNode{root}, depth)
+	}
+	if b.err != nil {
+		return "", b.err
+	}
+	return base58Encode(root.hash), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnixfsHash(t *testing.T) {
+	Convey("Test ipfs file hash computed locally", t, func() {
+		Convey("Single chunk files match ipfs add", func() {
+			hash, err := ipfsFileHash(strings.NewReader(""))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH")
+			hash, err = ipfsFileHash(strings.NewReader("hello world\n"))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o")
+		})
+
+		Convey("Bigger files use the balanced layout", func() {
+			stubs := Stub(&unixfsChunkSize, 4)
+			defer stubs.Reset()
+			stubs.Stub(&unixfsMaxLinks, 3)
+
+			leaf := func(data string) unixfsNode { return unixfsBlock([]byte(data), nil) }
+			// the first full node becomes the first child of a deeper root
+			expected := unixfsBlock(nil, []unixfsNode{
+				unixfsBlock(nil, []unixfsNode{leaf("aaaa"), leaf("bbbb"), leaf("cccc")}),
+				unixfsBlock(nil, []unixfsNode{leaf("dddd"), leaf("ee")}),
+			})
+			So(expected.fileSize, ShouldEqual, 18)
+			hash, err := ipfsFileHash(strings.NewReader("aaaabbbbccccddddee"))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, base58Encode(expected.hash))
+
+			// data ending on a chunk boundary does not add an empty leaf
+			expected = unixfsBlock(nil, []unixfsNode{leaf("aaaa"), leaf("bbbb")})
+			hash, _ = ipfsFileHash(strings.NewReader("aaaabbbb"))
+			So(hash, ShouldEqual, base58Encode(expected.hash))
+		})
+
+		Convey("Hash of the restored content of an altered file", func() {
+			stubs := Stub(&IpfsChunkSize, int64(100))
+			defer stubs.Reset()
+			content := generateTestFileContent(1234)
+			original := generateTestFile(len(content))
+			altered := createAlteredTmpFile(original)
+			altered.Seek(0, 0)
+
+			expected, _ := ipfsFileHash(bytes.NewReader(content))
+			hash, err := ipfsFileHash(newRestoringReader(altered))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, expected)
+		})
+	})
+}
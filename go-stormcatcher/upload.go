@@ -0,0 +1,489 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	validator "gopkg.in/validator.v2"
+)
+
+// tus-like upload headers
+const (
+	UploadLengthHeader = "Upload-Length"
+	UploadOffsetHeader = "Upload-Offset"
+)
+
+// upload status
+const (
+	UploadReceiving  = "receiving"
+	UploadProcessing = "processing" // all data received, waiting for the upload queue
+	UploadComplete   = "complete"
+	UploadFailed     = "failed"
+)
+
+var (
+	errUploadOffset   = errors.New("upload offset does not match")
+	errUploadTooLarge = errors.New("data beyond the upload length")
+	errUploadBusy     = errors.New("upload already receiving data")
+)
+
+// Upload is a file sent to this node in chunks. The data is altered as it is
+// received, so the original content is never kept on disk.
+type Upload struct {
+	Id         string `json:"id"`
+	Owner      string `json:"owner,omitempty"`
+	Length     int64  `json:"length"`
+	Offset     int64  `json:"offset"`
+	Status     string `json:"status"`
+	Filehash   string `json:"file_hash,omitempty"`
+	Registered bool   `json:"registered"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type UploadRequest struct {
+	Id string `validate:"len=32,regexp=^[0-9a-f]*$"`
+}
+
+// uploadsReceiving keeps a single request writing to an upload at a time
+var uploadsReceiving = make(map[string]bool)
+var uploadsLock sync.Mutex
+
+func saveUpload(upload *Upload) error {
+	mUpload, _ := json.Marshal(upload)
+	return metadataStore.HashSet(IpfsUploadName, upload.Id, string(mUpload))
+}
+
+func getUpload(id string) (*Upload, error) {
+	result, err := metadataStore.HashGet(IpfsUploadName, id)
+	if err != nil {
+		return nil, err
+	}
+	if result == "" {
+		return nil, errKeyNotFound
+	}
+	upload := new(Upload)
+	if err := json.Unmarshal([]byte(result), upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+func deleteUpload(id string) error {
+	os.Remove(uploadFilePath(id))
+	_, err := metadataStore.HashDelete(IpfsUploadName, id)
+	return err
+}
+
+// uploadFilePath is the altered file of an upload.
+func uploadFilePath(id string) string {
+	return filepath.Join(uploadPath, id+".altered")
+}
+
+func newUploadId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// alteredOffset maps an offset of the original file to the altered file, where
+// each chunk starts with 8 random bytes.
+func alteredOffset(offset int64) int64 {
+	dataSize := IpfsChunkSize - 8
+	return offset/dataSize*IpfsChunkSize + 8 + offset%dataSize
+}
+
+// writeAltered writes original data at offset of the altered file, adding the
+// random header of each chunk it starts. It returns the bytes of data written.
+func writeAltered(f *os.File, offset int64, r io.Reader) (int64, error) {
+	dataSize := IpfsChunkSize - 8
+	buf := make([]byte, dataSize)
+	written := int64(0)
+	for {
+		// fill up to the end of the current chunk
+		n, err := io.ReadFull(r, buf[:dataSize-offset%dataSize])
+		if n > 0 {
+			if offset%dataSize == 0 {
+				if _, err := f.WriteAt(getRandomIntBuf(), alteredOffset(offset)-8); err != nil {
+					return written, err
+				}
+			}
+			if _, err := f.WriteAt(buf[:n], alteredOffset(offset)); err != nil {
+				return written, err
+			}
+			offset += int64(n)
+			written += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// restoringReader strips the random header of each chunk of an altered file.
+type restoringReader struct {
+	r     io.Reader
+	chunk []byte
+	buf   []byte
+}
+
+func newRestoringReader(r io.Reader) *restoringReader {
+	return &restoringReader{r: r, buf: make([]byte, IpfsChunkSize)}
+}
+
+func (rr *restoringReader) Read(p []byte) (int, error) {
+	if len(rr.chunk) == 0 {
+		n, err := io.ReadFull(rr.r, rr.buf)
+		if n <= 8 {
+			if err == nil || err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		rr.chunk = rr.buf[8:n]
+	}
+	n := copy(p, rr.chunk)
+	rr.chunk = rr.chunk[n:]
+	return n, nil
+}
+
+// authorizeUpload checks the request signature, uploads are signed with the
+// "upload" method and can only be continued by the signer which created them.
+func authorizeUpload(w http.ResponseWriter, r *http.Request, subject string, owner string) (string, bool) {
+	if !authEnabled {
+		return "", true
+	}
+	signer, err := recoverSigner(r, "upload", subject)
+	if err != nil {
+		log.Info("Rejected upload request", subject, err)
+		http.Error(w, err.Error(), 401)
+		return "", false
+	}
+	if owner != "" && signer != owner && !isOperator(signer) {
+		http.Error(w, errNotFileOwner.Error(), 403)
+		return "", false
+	}
+	return signer, true
+}
+
+// receiveUpload appends the request body to the upload, the offset reached is
+// saved even if the body is cut short so the client can resume from there.
+func receiveUpload(id string, offset int64, r *http.Request) error {
+	uploadsLock.Lock()
+	if uploadsReceiving[id] {
+		uploadsLock.Unlock()
+		return errUploadBusy
+	}
+	uploadsReceiving[id] = true
+	uploadsLock.Unlock()
+	defer func() {
+		uploadsLock.Lock()
+		delete(uploadsReceiving, id)
+		uploadsLock.Unlock()
+	}()
+
+	// read under the lock, another request may have moved the offset
+	upload, err := getUpload(id)
+	if err != nil {
+		return err
+	}
+	if upload.Status != UploadReceiving || offset != upload.Offset {
+		return errUploadOffset
+	}
+	if r.ContentLength > upload.Length-upload.Offset {
+		return errUploadTooLarge
+	}
+
+	f, err := os.OpenFile(uploadFilePath(upload.Id), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, errWrite := writeAltered(f, upload.Offset, io.LimitReader(r.Body, upload.Length-upload.Offset))
+	upload.Offset += n
+	if upload.Offset == upload.Length {
+		upload.Status = UploadProcessing
+	}
+	if err := saveUpload(upload); err != nil {
+		return err
+	}
+	if upload.Status == UploadProcessing {
+		if err := AddTaskToQueue(IpfsUploadQueueName, upload.Id); err != nil {
+			return err
+		}
+	}
+	return errWrite
+}
+
+// registerUpload records the deal of an uploaded file on the storage contract,
+// the owner then pays it like any other deal. The contract only accepts register
+// from its admins and operators, so the address of operator-key-file has to be
+// added with addOperator first, otherwise the upload is kept but not registered.
+// A deal is only registered for content which was not stored before the upload.
+func registerUpload(upload *Upload, stored bool) bool {
+	if dealContract == nil || upload.Owner == "" {
+		return false
+	}
+	if terms, err := dealContract.GetDeal(upload.Filehash); err == nil && terms.Owner != (common.Address{}) {
+		return true
+	}
+	if stored {
+		log.Info("Not registering uploaded file stored before without deal", upload.Filehash)
+		return false
+	}
+	if err := dealContract.Register(upload.Filehash, common.HexToAddress(upload.Owner), upload.Length); err != nil {
+		log.Error("Can not register uploaded file", upload.Filehash, err)
+		return false
+	}
+	return true
+}
+
+// handleUploadComplete stores a fully received upload under the hash ipfs would give it.
+func handleUploadComplete(id string) error {
+	upload, err := getUpload(id)
+	if err != nil {
+		return err
+	}
+	if upload.Status != UploadProcessing {
+		return nil
+	}
+	// the data stays until the upload expires, pruneUploads retries failed check ins
+	fail := func(err error) error {
+		upload.Error = err.Error()
+		saveUpload(upload)
+		return err
+	}
+
+	alteredFile, err := os.Open(uploadFilePath(id))
+	if err != nil {
+		upload.Status = UploadFailed
+		return fail(err)
+	}
+	defer alteredFile.Close()
+
+	// step 1, the original file hash
+	fileHash, err := ipfsFileHash(newRestoringReader(alteredFile))
+	if err != nil {
+		return fail(err)
+	}
+	upload.Filehash = fileHash
+	log.Info("Upload", id, "is file", fileHash)
+
+	// step 2, check in the altered file, unless this node already stores it, an
+	// erasure coded node splits the file and keeps only its shard. Content stored
+	// before keeps its owner, uploading a copy does not claim it
+	stored := isFileStored(fileHash)
+	if ecDataShards > 0 && upload.Length > 0 {
+		if !isShardStored(fileHash, nodeShardId) {
+			restoredFile := createRestoredTmpFile(alteredFile)
+			err := writeShards(fileHash, nodeShardId, restoredFile, upload.Length)
+			removeShardFiles([]*os.File{restoredFile})
+			if err != nil {
+				return fail(err)
+			}
+		}
+	} else if GetAlteredFileHash(fileHash) == "" {
+		if _, err := checkInFile(alteredFile, fileHash); err != nil {
+			return fail(err)
+		}
+		updateFileHashStat(fileHash, FileHashStat{Size: upload.Length})
+		bytesWrittenTotal.Add(float64(upload.Length))
+	}
+	if !stored {
+		claimFileOwner(fileHash, upload.Owner)
+	}
+
+	// step 3, register the file on the storage contract
+	upload.Registered = registerUpload(upload, stored)
+
+	upload.Status = UploadComplete
+	upload.Error = ""
+	if err := saveUpload(upload); err != nil {
+		return err
+	}
+	os.Remove(uploadFilePath(id))
+	log.Info("Upload complete:", id, fileHash)
+	return nil
+}
+
+// pruneUploads deletes the uploads, finished or not, older than uploadExpiry, and
+// enqueues again the uploads which could not be stored.
+func pruneUploads() {
+	ids, err := metadataStore.HashKeys(IpfsUploadName)
+	if err != nil {
+		log.Info("Failed getting uploads.", err)
+		return
+	}
+	cutoff := time.Now().Unix() - uploadExpiry
+	for _, id := range ids {
+		upload, err := getUpload(id)
+		if err != nil {
+			continue
+		}
+		if upload.CreatedAt > cutoff {
+			if upload.Status == UploadProcessing && upload.Error != "" {
+				AddTaskToQueue(IpfsUploadQueueName, id)
+			}
+			continue
+		}
+		log.Info("Delete expired upload", id, upload.Status)
+		deleteUpload(id)
+	}
+}
+
+func initUploads() {
+	if err := os.MkdirAll(uploadPath, 0700); err != nil {
+		log.Fatal("Can not create upload directory", uploadPath, err)
+	}
+}
+
+func initUploadWorker() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(uploadExpiry/24) * time.Second)
+			pruneUploads()
+		}
+	}()
+}
+
+func writeUpload(w http.ResponseWriter, upload *Upload) {
+	w.Header().Set(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set(UploadLengthHeader, strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func createUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get(UploadLengthHeader), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Invalid Upload-Length header.", 400)
+		return
+	}
+	if uploadMaxSize > 0 && length > uploadMaxSize {
+		http.Error(w, "Upload too large.", 413)
+		return
+	}
+	signer, ok := authorizeUpload(w, r, strconv.FormatInt(length, 10), "")
+	if !ok {
+		return
+	}
+
+	id, err := newUploadId()
+	if err != nil {
+		http.Error(w, "Can not create upload.", 500)
+		return
+	}
+	upload := &Upload{Id: id, Owner: signer, Length: length, Status: UploadReceiving, CreatedAt: time.Now().Unix()}
+	if length == 0 {
+		upload.Status = UploadProcessing
+	}
+	if err := saveUpload(upload); err != nil {
+		http.Error(w, "Can not create upload.", 500)
+		return
+	}
+	f, err := os.Create(uploadFilePath(id))
+	if err != nil {
+		http.Error(w, "Can not create upload.", 500)
+		return
+	}
+	f.Close()
+	if length == 0 {
+		AddTaskToQueue(IpfsUploadQueueName, id)
+	}
+
+	writeUpload(w, upload)
+	w.Header().Set("Location", "/uploads/"+id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(upload)
+}
+
+func uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	// sample queries:
+	// create, signed with the upload length as file hash:
+	// curl -X POST -H "Upload-Length: 1048576" -H "X-Storm-Timestamp: 1571212800" -H "X-Storm-Nonce: 5f1c2a" \
+	//   -H "X-Storm-Signature: 0x..." "http://127.0.0.1:18080/uploads"
+	// send data, signed with the upload id as file hash:
+	// curl -X PATCH -H "Upload-Offset: 0" -H "Content-Type: application/offset+octet-stream" -H "X-Storm-..." \
+	//   --data-binary @part1 "http://127.0.0.1:18080/uploads/0b5e1c6f3d8a4e2f9c7b1a0d6e5f4c3b"
+	// resume from the Upload-Offset header, the status and file hash are in the json body:
+	// curl -I -H "X-Storm-..." "http://127.0.0.1:18080/uploads/0b5e1c6f3d8a4e2f9c7b1a0d6e5f4c3b"
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/uploads"), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed.", 405)
+			return
+		}
+		createUpload(w, r)
+		return
+	}
+
+	uploadRequest := UploadRequest{Id: id}
+	if errs := validator.Validate(uploadRequest); errs != nil {
+		http.Error(w, fmt.Sprintf("Invalid upload id: %v", errs), 400)
+		return
+	}
+	upload, err := getUpload(id)
+	if err != nil {
+		http.Error(w, "Upload not found.", 404)
+		return
+	}
+	if _, ok := authorizeUpload(w, r, id, upload.Owner); !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		writeUpload(w, upload)
+	case http.MethodGet:
+		writeUpload(w, upload)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(upload)
+	case http.MethodPatch:
+		offset, err := strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Upload-Offset header.", 400)
+			return
+		}
+		switch err := receiveUpload(id, offset, r); err {
+		case nil:
+		case errUploadOffset, errUploadBusy:
+			http.Error(w, err.Error(), 409)
+			return
+		case errUploadTooLarge:
+			http.Error(w, err.Error(), 413)
+			return
+		default:
+			log.Error("Upload interrupted", id, err)
+			http.Error(w, "Upload interrupted, resume from Upload-Offset.", 500)
+			return
+		}
+		upload, _ = getUpload(id)
+		writeUpload(w, upload)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if upload.Status == UploadProcessing {
+			http.Error(w, "Upload is being stored.", 409)
+			return
+		}
+		deleteUpload(id)
+	default:
+		http.Error(w, "Method not allowed.", 405)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpload(t *testing.T) {
+	Convey("Test resumable uploads", t, func() {
+		dir, _ := ioutil.TempDir("", "stormcatcher_upload_test_")
+		defer os.RemoveAll(dir)
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&blobStoreType, FsBlobStore)
+		stubs.Stub(&blobStorePath, dir+"/blobs")
+		initBlobStore()
+		stubs.Stub(&uploadPath, dir+"/uploads")
+		initUploads()
+		stubs.Stub(&IpfsChunkSize, int64(100))
+		stubs.Stub(&authEnabled, true)
+
+		contract := &testDealContract{deals: map[string]*DealTerms{}, balances: map[common.Address]*big.Int{}}
+		dealContract = contract
+		defer func() { dealContract = nil }()
+
+		owner, _ := crypto.GenerateKey()
+		other, _ := crypto.GenerateKey()
+		content := generateTestFileContent(1234)
+
+		do := func(method string, path string, subject string, key *ecdsa.PrivateKey, header map[string]string, body []byte) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, bytes.NewReader(body))
+			if key != nil {
+				signed := signedTestRequest(key, path, "upload", subject)
+				for k := range signed.Header {
+					req.Header.Set(k, signed.Header.Get(k))
+				}
+			}
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			uploadsHandler(w, req)
+			return w
+		}
+		create := func(length int) *Upload {
+			w := do("POST", "/uploads", strconv.Itoa(length), owner, map[string]string{UploadLengthHeader: strconv.Itoa(length)}, nil)
+			So(w.Code, ShouldEqual, 201)
+			upload := new(Upload)
+			json.Unmarshal(w.Body.Bytes(), upload)
+			So(w.Header().Get("Location"), ShouldEqual, "/uploads/"+upload.Id)
+			return upload
+		}
+		patch := func(id string, offset int, data []byte) *httptest.ResponseRecorder {
+			return do("PATCH", "/uploads/"+id, id, owner, map[string]string{UploadOffsetHeader: strconv.Itoa(offset)}, data)
+		}
+
+		Convey("Unsigned uploads are rejected", func() {
+			w := do("POST", "/uploads", "10", nil, map[string]string{UploadLengthHeader: "10"}, nil)
+			So(w.Code, ShouldEqual, 401)
+		})
+
+		Convey("Upload in parts, resume and store", func() {
+			upload := create(len(content))
+			So(upload.Owner, ShouldEqual, keyAddress(owner))
+
+			// a part cut at a random place, the next one resumes from the reported offset
+			So(patch(upload.Id, 0, content[:333]).Code, ShouldEqual, 204)
+			w := do("HEAD", "/uploads/"+upload.Id, upload.Id, owner, nil, nil)
+			So(w.Header().Get(UploadOffsetHeader), ShouldEqual, "333")
+			So(w.Header().Get(UploadLengthHeader), ShouldEqual, "1234")
+
+			So(patch(upload.Id, 0, content[:100]).Code, ShouldEqual, 409)
+			So(patch(upload.Id, 333, content[333:]).Code, ShouldEqual, 204)
+			So(patch(upload.Id, 1234, []byte("x")).Code, ShouldEqual, 409)
+
+			// another signer can not see or continue the upload
+			So(do("HEAD", "/uploads/"+upload.Id, upload.Id, other, nil, nil).Code, ShouldEqual, 403)
+
+			task, _ := taskQueue.PopBlock(IpfsUploadQueueName, time.Second)
+			So(task, ShouldEqual, upload.Id)
+			So(handleUploadComplete(task), ShouldBeNil)
+
+			w = do("GET", "/uploads/"+upload.Id, upload.Id, owner, nil, nil)
+			json.Unmarshal(w.Body.Bytes(), upload)
+			So(upload.Status, ShouldEqual, UploadComplete)
+			expected, _ := ipfsFileHash(bytes.NewReader(content))
+			So(upload.Filehash, ShouldEqual, expected)
+			So(upload.Registered, ShouldBeTrue)
+			So(contract.registered, ShouldResemble, []string{expected})
+
+			// stored altered like written files, owned by the uploader
+			restored, _ := ioutil.ReadAll(&restoredFileReader{id: GetAlteredFileHash(expected), size: int64(len(content))})
+			So(restored, ShouldResemble, content)
+			_, stat := getFileHashStat(expected)
+			So(stat.Size, ShouldEqual, 1234)
+			fileOwner, _ := getFileOwner(expected)
+			So(fileOwner, ShouldEqual, keyAddress(owner))
+			_, err := os.Stat(uploadFilePath(upload.Id))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("Uploading a copy of a stored file does not claim it", func() {
+			expected, _ := ipfsFileHash(bytes.NewReader(content))
+			updateFileHashMapping(expected, "QmW8ubjTcjVz2VKn497bEZ5wQaLPS6chLU5DaQSq3NWMa1")
+
+			upload := create(len(content))
+			So(patch(upload.Id, 0, content).Code, ShouldEqual, 204)
+			task, _ := taskQueue.PopBlock(IpfsUploadQueueName, time.Second)
+			So(handleUploadComplete(task), ShouldBeNil)
+
+			upload, _ = getUpload(upload.Id)
+			So(upload.Status, ShouldEqual, UploadComplete)
+			So(upload.Registered, ShouldBeFalse)
+			So(contract.registered, ShouldBeEmpty)
+			fileOwner, _ := getFileOwner(expected)
+			So(fileOwner, ShouldEqual, "")
+		})
+
+		Convey("Data beyond the upload length is rejected", func() {
+			upload := create(10)
+			So(patch(upload.Id, 0, content[:11]).Code, ShouldEqual, 413)
+		})
+
+		Convey("Uploads can be cancelled", func() {
+			upload := create(10)
+			So(do("DELETE", "/uploads/"+upload.Id, upload.Id, owner, nil, nil).Code, ShouldEqual, 200)
+			So(do("HEAD", "/uploads/"+upload.Id, upload.Id, owner, nil, nil).Code, ShouldEqual, 404)
+		})
+
+		Convey("Expired uploads are deleted", func() {
+			upload := create(10)
+			stubs.Stub(&uploadExpiry, int64(-1))
+			pruneUploads()
+			_, err := getUpload(upload.Id)
+			So(err, ShouldEqual, errKeyNotFound)
+		})
+
+		Convey("Uploads on erasure coded nodes are split into shards", func() {
+			stubs.Stub(&ecDataShards, 2)
+			stubs.Stub(&ecParityShards, 1)
+			stubs.Stub(&nodeShardId, 0)
+			stubs.Stub(&shardPeers, []string{"", "peer1", "peer2"})
+			pushed := map[int]string{}
+			stubs.Stub(&pushShard, func(node string, originalFileHash string, shardId int, hash string, shardFile *os.File) (string, error) {
+				pushed[shardId] = node
+				return "cid" + strconv.Itoa(shardId), nil
+			})
+			stubs.Stub(&pushShardManifest, func(node string, manifest *ShardManifest) error { return nil })
+
+			upload := create(len(content))
+			So(patch(upload.Id, 0, content).Code, ShouldEqual, 204)
+			task, _ := taskQueue.PopBlock(IpfsUploadQueueName, time.Second)
+			So(handleUploadComplete(task), ShouldBeNil)
+
+			expected, _ := ipfsFileHash(bytes.NewReader(content))
+			upload, _ = getUpload(upload.Id)
+			So(upload.Status, ShouldEqual, UploadComplete)
+			So(upload.Filehash, ShouldEqual, expected)
+			So(GetAlteredFileHash(expected), ShouldEqual, "")
+			So(pushed, ShouldResemble, map[int]string{1: "peer1", 2: "peer2"})
+			manifest, err := getShardManifest(expected)
+			So(err, ShouldBeNil)
+			So(manifest.Size, ShouldEqual, len(content))
+			So(isShardStored(expected, 0), ShouldBeTrue)
+		})
+	})
+}
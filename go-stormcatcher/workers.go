@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// taskQueueNames lists the queues served by the queue workers
+var taskQueueNames = []string{
+	IpfsReadQueueName,
+	IpfsWriteQueueName,
+	IpfsDeleteQueueName,
+	IpfsProxyWriteQueueName,
+	IpfsProxyReadQueueName,
+	IpfsShardWriteQueueName,
+	IpfsShardReadQueueName,
+	IpfsUploadQueueName,
+}
+
+// queueHandlers handle the tasks of each queue. A task still running when the node
+// stops is run again from the start on the next start, so handlers must be
+// idempotent: running a task again after it completed, fully or in part, is harmless.
+var queueHandlers = map[string]func(string) error{
+	IpfsReadQueueName:       handleIPFSRead,
+	IpfsWriteQueueName:      HandleIPFSWrite,
+	IpfsDeleteQueueName:     HandleIPFSDelete,
+	IpfsProxyReadQueueName:  handleIPFSProxyRead,
+	IpfsProxyWriteQueueName: handleIPFSProxyWrite,
+	IpfsShardWriteQueueName: HandleShardWrite,
+	IpfsShardReadQueueName:  handleShardRead,
+	IpfsUploadQueueName:     handleUploadComplete,
+}
+
+// workerPool owns the queue workers of a node. Its workers keep polling the queue
+// the pool was started with, and stopping the pool only stops its own workers.
+type workerPool struct {
+	queue    Queue
+	store    MetadataStore
+	stopping int32 // set to 1 when the pool stops, workers then stop taking tasks
+	queues   map[string]*queueWorkers
+}
+
+// queueWorkerPool is the pool started by initQueueWorkers
+var queueWorkerPool *workerPool
+
+// queuePollTimeout bounds how long an idle worker waits for a task before checking
+// whether it should stop
+var queuePollTimeout = time.Duration(2) * time.Second
+
+// queueWorkers runs the handler of one queue on a changeable number of goroutines
+// and keeps track of the tasks in flight.
+type queueWorkers struct {
+	pool      *workerPool
+	queueName string
+	handler   func(string) error
+	mu        sync.Mutex
+	limit     int
+	running   int
+	inFlight  map[int]string // worker id -> task
+	nextId    int
+	wg        sync.WaitGroup // tasks in flight
+}
+
+func newWorkerPool(queue Queue, store MetadataStore) *workerPool {
+	return &workerPool{queue: queue, store: store, queues: make(map[string]*queueWorkers)}
+}
+
+func (p *workerPool) stopped() bool {
+	return atomic.LoadInt32(&p.stopping) == 1
+}
+
+// inFlightKey names the record of a task in flight in the IpfsInFlightTaskName table.
+func inFlightKey(queueName string, id int) string {
+	return fmt.Sprintf("%s/%d", queueName, id)
+}
+
+// requeueInFlight returns the tasks which were in flight when the node last
+// stopped to their queue. Their handlers may have been interrupted half way,
+// so they are run again from the start.
+func (p *workerPool) requeueInFlight() {
+	keys, err := p.store.HashKeys(IpfsInFlightTaskName)
+	if err != nil {
+		log.Error("Can not list tasks in flight", err)
+		return
+	}
+	for _, key := range keys {
+		task, err := p.store.HashGet(IpfsInFlightTaskName, key)
+		if err != nil {
+			continue
+		}
+		queueName := key[:strings.LastIndex(key, "/")]
+		log.Info("Returning unfinished task to queue", queueName, task)
+		if err := p.queue.Push(queueName, task); err == nil {
+			p.store.HashDelete(IpfsInFlightTaskName, key)
+		}
+	}
+}
+
+// start returns the unfinished tasks of the last run to their queue and starts the workers.
+func (p *workerPool) start() {
+	p.requeueInFlight()
+	for _, queueName := range taskQueueNames {
+		q := p.newQueueWorkers(queueName, queueHandlers[queueName])
+		p.queues[queueName] = q
+		q.setLimit(queueLimit(queueName))
+		log.Info("Queue workers", queueName, queueLimit(queueName))
+	}
+}
+
+// applyLimits changes the number of workers of each queue to the configured limit.
+func (p *workerPool) applyLimits() {
+	for queueName, q := range p.queues {
+		q.setLimit(queueLimit(queueName))
+		log.Info("Queue workers", queueName, queueLimit(queueName))
+	}
+}
+
+// stop waits for the tasks in flight to finish, at most timeout. Tasks still running
+// then are not queued again while their handler may still finish them: they stay
+// recorded as in flight, and the next start of the node queues them again.
+func (p *workerPool) stop(timeout time.Duration) {
+	atomic.StoreInt32(&p.stopping, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for _, q := range p.queues {
+			q.wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Info("All tasks in flight finished")
+		return
+	case <-time.After(timeout):
+	}
+
+	for queueName, q := range p.queues {
+		for _, task := range q.unfinished() {
+			log.Info("Task still running, it is queued again on the next start", queueName, task)
+		}
+	}
+}
+
+func (p *workerPool) newQueueWorkers(queueName string, handler func(string) error) *queueWorkers {
+	return &queueWorkers{pool: p, queueName: queueName, handler: handler, inFlight: make(map[int]string)}
+}
+
+// setLimit starts or retires workers, retired workers exit after their current task.
+func (q *queueWorkers) setLimit(limit int) {
+	q.mu.Lock()
+	q.limit = limit
+	ids := []int{}
+	for ; q.running < q.limit; q.running++ {
+		ids = append(ids, q.nextId)
+		q.nextId++
+	}
+	q.mu.Unlock()
+	for _, id := range ids {
+		go q.work(id)
+	}
+}
+
+// retire reports whether the worker must exit, it is then not counted as running anymore.
+func (q *queueWorkers) retire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pool.stopped() || q.running > q.limit {
+		q.running--
+		return true
+	}
+	return false
+}
+
+// begin records a task in flight, it reports false if the pool started stopping.
+func (q *queueWorkers) begin(id int, task string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pool.stopped() {
+		q.running--
+		return false
+	}
+	q.inFlight[id] = task
+	q.wg.Add(1)
+	q.pool.store.HashSet(IpfsInFlightTaskName, inFlightKey(q.queueName, id), task)
+	return true
+}
+
+func (q *queueWorkers) end(id int) {
+	q.pool.store.HashDelete(IpfsInFlightTaskName, inFlightKey(q.queueName, id))
+	q.mu.Lock()
+	delete(q.inFlight, id)
+	q.mu.Unlock()
+	q.wg.Done()
+}
+
+func (q *queueWorkers) work(id int) {
+	for {
+		if q.retire() {
+			return
+		}
+		task, err := q.pool.queue.PopBlock(q.queueName, queuePollTimeout)
+		if err == errQueueEmpty {
+			continue
+		}
+		if err != nil {
+			log.Error("Can't get task from queue", q.queueName, err)
+			time.Sleep(time.Duration(1) * time.Second)
+			continue
+		}
+		if !q.begin(id, task) {
+			// popped while stopping, leave it to the next start
+			q.pool.queue.Push(q.queueName, task)
+			return
+		}
+
+		log.Info(fmt.Sprintf("call %s handler with %v", q.queueName, task))
+		start := time.Now()
+		err = q.handler(task)
+		observeTask(q.queueName, start, err)
+		q.end(id)
+		if err != nil {
+			log.Info("Can't handl task, error:", err)
+		}
+	}
+}
+
+// unfinished returns the tasks still in flight.
+func (q *queueWorkers) unfinished() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tasks := []string{}
+	for _, task := range q.inFlight {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// queueLimit returns the concurrency of a queue, queueConcurrencies overrides queueConcurrency.
+func queueLimit(queueName string) int {
+	if limit, ok := queueConcurrencies[queueName]; ok {
+		return limit
+	}
+	return queueConcurrency
+}
+
+func initQueueWorkers() {
+	queueWorkerPool = newWorkerPool(taskQueue, metadataStore)
+	queueWorkerPool.start()
+	atomic.StoreInt32(&workersReady, 1)
+}
+
+// applyQueueLimits changes the number of workers of each queue to the configured limit.
+func applyQueueLimits() {
+	if queueWorkerPool != nil {
+		queueWorkerPool.applyLimits()
+	}
+}
+
+// stopQueueWorkers waits for the tasks in flight to finish, at most timeout, see workerPool.stop.
+func stopQueueWorkers(timeout time.Duration) {
+	atomic.StoreInt32(&workersReady, 0)
+	if queueWorkerPool != nil {
+		queueWorkerPool.stop(timeout)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/prashantv/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueueWorkers(t *testing.T) {
+	Convey("Test queue workers", t, func() {
+		stubs := Stub(&storeBackend, MemoryBackend)
+		defer stubs.Reset()
+		initStoreBackend()
+		stubs.Stub(&queuePollTimeout, time.Duration(50)*time.Millisecond)
+		stubs.Stub(&queueConcurrency, 2)
+		stubs.Stub(&queueConcurrencies, map[string]int{})
+
+		// the handler blocks until released and counts the tasks running at once
+		var running, maxRunning, handled int32
+		release := make(chan struct{})
+		handler := func(task string) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&handled, 1)
+			return nil
+		}
+		stubs.Stub(&taskQueueNames, []string{IpfsWriteQueueName})
+		stubs.Stub(&queueHandlers, map[string]func(string) error{IpfsWriteQueueName: handler})
+		waitFor := func(cond func() bool) bool {
+			for i := 0; i < 100; i++ {
+				if cond() {
+					return true
+				}
+				time.Sleep(time.Duration(20) * time.Millisecond)
+			}
+			return false
+		}
+		workersExited := func(pool *workerPool) func() bool {
+			return func() bool {
+				q := pool.queues[IpfsWriteQueueName]
+				q.mu.Lock()
+				defer q.mu.Unlock()
+				return q.running == 0
+			}
+		}
+
+		for _, task := range []string{"task1", "task2", "task3", "task4"} {
+			AddTaskToQueue(IpfsWriteQueueName, task)
+		}
+		pool := newWorkerPool(taskQueue, metadataStore)
+		pool.start()
+		// the workers of a test must not outlive it
+		defer func() {
+			pool.stop(0)
+			waitFor(workersExited(pool))
+		}()
+		inFlight := func() int {
+			keys, _ := metadataStore.HashKeys(IpfsInFlightTaskName)
+			return len(keys)
+		}
+
+		Convey("The queue limit bounds the tasks in flight", func() {
+			So(waitFor(func() bool { return atomic.LoadInt32(&running) == 2 }), ShouldBeTrue)
+			time.Sleep(time.Duration(100) * time.Millisecond)
+			So(atomic.LoadInt32(&maxRunning), ShouldEqual, 2)
+			So(inFlight(), ShouldEqual, 2)
+
+			// raising the limit starts more workers
+			stubs.Stub(&queueConcurrencies, map[string]int{IpfsWriteQueueName: 4})
+			pool.applyLimits()
+			So(waitFor(func() bool { return atomic.LoadInt32(&running) == 4 }), ShouldBeTrue)
+
+			close(release)
+			So(waitFor(func() bool { return atomic.LoadInt32(&handled) == 4 }), ShouldBeTrue)
+			pool.stop(time.Second)
+			So(waitFor(workersExited(pool)), ShouldBeTrue)
+			So(inFlight(), ShouldEqual, 0)
+		})
+
+		Convey("Tasks running at shutdown are not queued again while they run", func() {
+			So(waitFor(func() bool { return atomic.LoadInt32(&running) == 2 }), ShouldBeTrue)
+			pool.stop(time.Duration(100) * time.Millisecond)
+
+			length, _ := taskQueue.Len(IpfsWriteQueueName)
+			So(length, ShouldEqual, 2)
+			So(inFlight(), ShouldEqual, 2)
+			close(release)
+			// stopped workers take no new task
+			So(waitFor(workersExited(pool)), ShouldBeTrue)
+			So(atomic.LoadInt32(&handled), ShouldEqual, 2)
+			length, _ = taskQueue.Len(IpfsWriteQueueName)
+			So(length, ShouldEqual, 2)
+			// finished after the timeout, nothing is left to run again
+			So(inFlight(), ShouldEqual, 0)
+		})
+
+		Convey("Tasks interrupted by the last stop are queued again on start", func() {
+			So(waitFor(func() bool { return atomic.LoadInt32(&running) == 2 }), ShouldBeTrue)
+			pool.stop(time.Duration(100) * time.Millisecond)
+			// the node exited while the handlers were running
+			metadataStore.HashSet(IpfsInFlightTaskName, inFlightKey(IpfsWriteQueueName, 7), "task5")
+
+			next := newWorkerPool(taskQueue, metadataStore)
+			next.requeueInFlight()
+			length, _ := taskQueue.Len(IpfsWriteQueueName)
+			So(length, ShouldEqual, 5)
+			close(release)
+			So(waitFor(workersExited(pool)), ShouldBeTrue)
+		})
+
+		Convey("Shutdown waits for the tasks in flight", func() {
+			So(waitFor(func() bool { return atomic.LoadInt32(&running) == 2 }), ShouldBeTrue)
+			go func() {
+				time.Sleep(time.Duration(100) * time.Millisecond)
+				close(release)
+			}()
+			pool.stop(time.Duration(5) * time.Second)
+			So(atomic.LoadInt32(&handled), ShouldEqual, 2)
+			length, _ := taskQueue.Len(IpfsWriteQueueName)
+			So(length, ShouldEqual, 2)
+			So(waitFor(workersExited(pool)), ShouldBeTrue)
+		})
+
+		Convey("Node workers follow the node readiness", func() {
+			initQueueWorkers()
+			So(atomic.LoadInt32(&workersReady), ShouldEqual, 1)
+			stopQueueWorkers(0)
+			So(atomic.LoadInt32(&workersReady), ShouldEqual, 0)
+			close(release)
+			So(waitFor(workersExited(queueWorkerPool)), ShouldBeTrue)
+		})
+	})
+}
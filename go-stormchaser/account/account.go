@@ -0,0 +1,85 @@
+// account project account.go
+package account
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"stormchaser/singleLog"
+	"strings"
+
+	"github.com/filestorm/go-filestorm/accounts/keystore"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+//读取密码文件
+func readPassword(passwordFile string) (string, error) {
+
+	if passwordFile == "" {
+		return "", errors.New("password file is required")
+	}
+	password, rErr := ioutil.ReadFile(passwordFile)
+	if rErr != nil {
+		return "", rErr
+	}
+	return strings.TrimRight(string(password), "\r\n"), nil
+}
+
+//解密 keystore 文件
+func decryptKeyFile(keystoreFile, password string) (*ecdsa.PrivateKey, error) {
+
+	keyJson, rErr := ioutil.ReadFile(keystoreFile)
+	if rErr != nil {
+		return nil, rErr
+	}
+	key, dErr := keystore.DecryptKey(keyJson, password)
+	if dErr != nil {
+		return nil, dErr
+	}
+	return key.PrivateKey, nil
+}
+
+/*
+ *加载节点的 storm 账户：
+ *keystoreFile - 指定时直接读取该 keystore 文件
+ *importFile - 十六进制私钥文件，导入到 keystoreDir 后使用
+ *都未指定时使用 keystoreDir 中的第一个账户，目录中没有账户时生成新账户
+ */
+func Load(keystoreDir, keystoreFile, importFile, passwordFile string) (*ecdsa.PrivateKey, error) {
+
+	password, pErr := readPassword(passwordFile)
+	if pErr != nil {
+		return nil, pErr
+	}
+	if keystoreFile != "" {
+		return decryptKeyFile(keystoreFile, password)
+	}
+
+	ks := keystore.NewKeyStore(keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
+	if importFile != "" {
+		key, lErr := crypto.LoadECDSA(importFile)
+		if lErr != nil {
+			return nil, lErr
+		}
+		if ks.HasAddress(crypto.PubkeyToAddress(key.PublicKey)) {
+			return key, nil
+		}
+		acc, iErr := ks.ImportECDSA(key, password)
+		if iErr != nil {
+			return nil, iErr
+		}
+		singleLog.GetInstance().Info("Storm account imported", acc.Address.Hex(), acc.URL.Path)
+		return key, nil
+	}
+
+	accs := ks.Accounts()
+	if len(accs) == 0 {
+		acc, nErr := ks.NewAccount(password)
+		if nErr != nil {
+			return nil, nErr
+		}
+		singleLog.GetInstance().Info("Storm account created, back up the keystore file!", acc.Address.Hex(), acc.URL.Path)
+		return decryptKeyFile(acc.URL.Path, password)
+	}
+	return decryptKeyFile(accs[0].URL.Path, password)
+}
@@ -1,90 +0,0 @@
-package aes
-
-import (
-	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"encoding/base64"
-)
-
-var key = []byte("@#chain$mining@#")
-var iv = []byte("4894046263038526")
-
-type AES_CBC struct {
-}
-
-func AesOperate(operateStr string, operateDir bool) (reStr string, reErr error) {
-
-	if operateDir {
-		//加密
-		reStr, reErr = Encrypt([]byte(operateStr))
-	} else {
-		//解密
-		reStr, reErr = Decrypt(operateStr)
-	}
-	return reStr, reErr
-}
-
-func Encrypt(origData []byte) (string, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-	blockSize := block.BlockSize()
-	origData = PKCS5Padding(origData, blockSize)
-	// origData = ZeroPadding(origData, block.BlockSize())
-	blockMode := cipher.NewCBCEncrypter(block, iv)
-	crypted := make([]byte, len(origData))
-
-	blockMode.CryptBlocks(crypted, origData)
-	return base64.StdEncoding.EncodeToString(crypted), nil
-}
-
-func Decrypt(crypted string) (string, error) {
-	decodeData, err := base64.StdEncoding.DecodeString(crypted)
-	if err != nil {
-		return "", err
-	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-	//blockSize := block.BlockSize()
-	blockMode := cipher.NewCBCDecrypter(block, iv)
-	origData := make([]byte, len(decodeData))
-	blockMode.CryptBlocks(origData, decodeData)
-	origData = PKCS5UnPadding(origData)
-	// origData = ZeroUnPadding(origData)
-	return string(origData), nil
-}
-
-func ZeroPadding(ciphertext []byte, blockSize int) []byte {
-	padding := blockSize - len(ciphertext)%blockSize
-	padtext := bytes.Repeat([]byte{0}, padding)
-	return append(ciphertext, padtext...)
-}
-
-func ZeroUnPadding(origData []byte) []byte {
-	length := len(origData)
-	if length == 0 {
-		return []byte("")
-	}
-	unpadding := int(origData[length-1])
-	return origData[:(length - unpadding)]
-}
-
-func PKCS5Padding(ciphertext []byte, blockSize int) []byte {
-	padding := blockSize - len(ciphertext)%blockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(ciphertext, padtext...)
-}
-
-func PKCS5UnPadding(origData []byte) []byte {
-	length := len(origData)
-	if length == 0 {
-		return []byte("")
-	}
-	// 去掉最后一个字节 unpadding 次
-	unpadding := int(origData[length-1])
-	return origData[:(length - unpadding)]
-}
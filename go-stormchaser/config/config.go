@@ -81,3 +81,20 @@ func SaveConfig(key, value string) error {
 	cfg.SetValue("DEFAULT", key, value)
 	return goconfig.SaveConfigFile(cfg, configPath)
 }
+
+//获取名称以 prefix 开头的配置段
+func GetSections(prefix string) map[string]map[string]string {
+
+	sections := make(map[string]map[string]string)
+	for _, name := range cfg.GetSectionList() {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		values, gErr := cfg.GetSection(name)
+		if gErr != nil {
+			continue
+		}
+		sections[strings.TrimPrefix(name, prefix)] = values
+	}
+	return sections
+}
@@ -0,0 +1,905 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contract
+
+import (
+	"math/big"
+	"strings"
+
+	filestorm "github.com/filestorm/go-filestorm"
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = filestorm.NotFound
+	_ = abi.U256
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// FileStormManagerABI is the input ABI used to generate the binding from.
+const FileStormManagerABI = "[{\"inputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"nodeAddress\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"state\",\"type\":\"uint8\"},{\"indexed\":false,\"name\":\"ip\",\"type\":\"string\"},{\"indexed\":false,\"name\":\"timestamp\",\"type\":\"uint256\"}],\"name\":\"HeartbeatReported\",\"type\":\"event\"},{\"constant\":true,\"inputs\":[],\"name\":\"BLOCK_SECOND\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"DISBURSE_EPOCH\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"DISBURSE_TOTAL\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"HEARTBEAT_MAX_SKEW\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"admin\",\"type\":\"address\"}],\"name\":\"addAdmin\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"nodeId\",\"type\":\"string\"},{\"name\":\"nodeAddress\",\"type\":\"address\"},{\"name\":\"beneficiary\",\"type\":\"address\"},{\"name\":\"stakingCount\",\"type\":\"uint256\"},{\"name\":\"registerType\",\"type\":\"uint256\"}],\"name\":\"addNode\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"admins\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"nodeAddress\",\"type\":\"address\"},{\"name\":\"state\",\"type\":\"uint8\"},{\"name\":\"ip\",\"type\":\"string\"},{\"name\":\"timestamp\",\"type\":\"uint256\"}],\"name\":\"attestationHash\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"nodeAddress\",\"type\":\"address\"}],\"name\":\"disburse\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"disburseMapping\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"state\",\"type\":\"uint8\"},{\"name\":\"ip\",\"type\":\"string\"},{\"name\":\"timestamp\",\"type\":\"uint256\"}],\"name\":\"heartbeat\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"heartbeats\",\"outputs\":[{\"name\":\"blockNumber\",\"type\":\"uint256\"},{\"name\":\"timestamp\",\"type\":\"uint256\"},{\"name\":\"state\",\"type\":\"uint8\"},{\"name\":\"ip\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"nodeAddress\",\"type\":\"address\"}],\"name\":\"isAlive\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"nodeMapping\",\"outputs\":[{\"name\":\"nodeId\",\"type\":\"string\"},{\"name\":\"nodeAddress\",\"type\":\"address\"},{\"name\":\"beneficiary\",\"type\":\"address\"},{\"name\":\"stakingAmount\",\"type\":\"uint256\"},{\"name\":\"disburseAmount\",\"type\":\"uint256\"},{\"name\":\"registerBlock\",\"type\":\"uint256\"},{\"name\":\"registerType\",\"type\":\"uint256\"},{\"name\":\"nodeStatus\",\"type\":\"uint256\"},{\"name\":\"nextRewardBlock\",\"type\":\"uint256\"},{\"name\":\"disburseCount\",\"type\":\"uint256\"},{\"name\":\"disbursedTotal\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"admin\",\"type\":\"address\"}],\"name\":\"removeAdmin\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"staking_amount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"staking_limit\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"nodeAddress\",\"type\":\"address\"},{\"name\":\"state\",\"type\":\"uint8\"},{\"name\":\"ip\",\"type\":\"string\"},{\"name\":\"timestamp\",\"type\":\"uint256\"},{\"name\":\"v\",\"type\":\"uint8\"},{\"name\":\"r\",\"type\":\"bytes32\"},{\"name\":\"s\",\"type\":\"bytes32\"}],\"name\":\"submitHeartbeat\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"registerType\",\"type\":\"uint256\"},{\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"updateDisburseAmount\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"nodeAddress\",\"type\":\"address\"},{\"name\":\"nodeStatus\",\"type\":\"uint256\"}],\"name\":\"updateNodeStatus\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"updateStakingAmount\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"limit\",\"type\":\"uint256\"}],\"name\":\"updateStakingLimit\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
+
+// FileStormManager is an auto generated Go binding around an Filestorm contract.
+type FileStormManager struct {
+	FileStormManagerCaller     // Read-only binding to the contract
+	FileStormManagerTransactor // Write-only binding to the contract
+	FileStormManagerFilterer   // Log filterer for contract events
+}
+
+// FileStormManagerCaller is an auto generated read-only Go binding around an Filestorm contract.
+type FileStormManagerCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FileStormManagerTransactor is an auto generated write-only Go binding around an Filestorm contract.
+type FileStormManagerTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FileStormManagerFilterer is an auto generated log filtering Go binding around an Filestorm contract events.
+type FileStormManagerFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// FileStormManagerSession is an auto generated Go binding around an Filestorm contract,
+// with pre-set call and transact options.
+type FileStormManagerSession struct {
+	Contract     *FileStormManager // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// FileStormManagerCallerSession is an auto generated read-only Go binding around an Filestorm contract,
+// with pre-set call options.
+type FileStormManagerCallerSession struct {
+	Contract *FileStormManagerCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts           // Call options to use throughout this session
+}
+
+// FileStormManagerTransactorSession is an auto generated write-only Go binding around an Filestorm contract,
+// with pre-set transact options.
+type FileStormManagerTransactorSession struct {
+	Contract     *FileStormManagerTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts           // Transaction auth options to use throughout this session
+}
+
+// FileStormManagerRaw is an auto generated low-level Go binding around an Filestorm contract.
+type FileStormManagerRaw struct {
+	Contract *FileStormManager // Generic contract binding to access the raw methods on
+}
+
+// FileStormManagerCallerRaw is an auto generated low-level read-only Go binding around an Filestorm contract.
+type FileStormManagerCallerRaw struct {
+	Contract *FileStormManagerCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// FileStormManagerTransactorRaw is an auto generated low-level write-only Go binding around an Filestorm contract.
+type FileStormManagerTransactorRaw struct {
+	Contract *FileStormManagerTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewFileStormManager creates a new instance of FileStormManager, bound to a specific deployed contract.
+func NewFileStormManager(address common.Address, backend bind.ContractBackend) (*FileStormManager, error) {
+	contract, err := bindFileStormManager(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStormManager{FileStormManagerCaller: FileStormManagerCaller{contract: contract}, FileStormManagerTransactor: FileStormManagerTransactor{contract: contract}, FileStormManagerFilterer: FileStormManagerFilterer{contract: contract}}, nil
+}
+
+// NewFileStormManagerCaller creates a new read-only instance of FileStormManager, bound to a specific deployed contract.
+func NewFileStormManagerCaller(address common.Address, caller bind.ContractCaller) (*FileStormManagerCaller, error) {
+	contract, err := bindFileStormManager(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStormManagerCaller{contract: contract}, nil
+}
+
+// NewFileStormManagerTransactor creates a new write-only instance of FileStormManager, bound to a specific deployed contract.
+func NewFileStormManagerTransactor(address common.Address, transactor bind.ContractTransactor) (*FileStormManagerTransactor, error) {
+	contract, err := bindFileStormManager(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStormManagerTransactor{contract: contract}, nil
+}
+
+// NewFileStormManagerFilterer creates a new log filterer instance of FileStormManager, bound to a specific deployed contract.
+func NewFileStormManagerFilterer(address common.Address, filterer bind.ContractFilterer) (*FileStormManagerFilterer, error) {
+	contract, err := bindFileStormManager(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStormManagerFilterer{contract: contract}, nil
+}
+
+// bindFileStormManager binds a generic wrapper to an already deployed contract.
+func bindFileStormManager(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(FileStormManagerABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_FileStormManager *FileStormManagerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _FileStormManager.Contract.FileStormManagerCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_FileStormManager *FileStormManagerRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _FileStormManager.Contract.FileStormManagerTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_FileStormManager *FileStormManagerRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _FileStormManager.Contract.FileStormManagerTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_FileStormManager *FileStormManagerCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _FileStormManager.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_FileStormManager *FileStormManagerTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _FileStormManager.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_FileStormManager *FileStormManagerTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _FileStormManager.Contract.contract.Transact(opts, method, params...)
+}
+
+// BLOCKSECOND is a free data retrieval call binding the contract method 0x84f66456.
+//
+// Solidity: function BLOCK_SECOND() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) BLOCKSECOND(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "BLOCK_SECOND")
+	return *ret0, err
+}
+
+// BLOCKSECOND is a free data retrieval call binding the contract method 0x84f66456.
+//
+// Solidity: function BLOCK_SECOND() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) BLOCKSECOND() (*big.Int, error) {
+	return _FileStormManager.Contract.BLOCKSECOND(&_FileStormManager.CallOpts)
+}
+
+// BLOCKSECOND is a free data retrieval call binding the contract method 0x84f66456.
+//
+// Solidity: function BLOCK_SECOND() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) BLOCKSECOND() (*big.Int, error) {
+	return _FileStormManager.Contract.BLOCKSECOND(&_FileStormManager.CallOpts)
+}
+
+// DISBURSEEPOCH is a free data retrieval call binding the contract method 0x1a8654f1.
+//
+// Solidity: function DISBURSE_EPOCH() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) DISBURSEEPOCH(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "DISBURSE_EPOCH")
+	return *ret0, err
+}
+
+// DISBURSEEPOCH is a free data retrieval call binding the contract method 0x1a8654f1.
+//
+// Solidity: function DISBURSE_EPOCH() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) DISBURSEEPOCH() (*big.Int, error) {
+	return _FileStormManager.Contract.DISBURSEEPOCH(&_FileStormManager.CallOpts)
+}
+
+// DISBURSEEPOCH is a free data retrieval call binding the contract method 0x1a8654f1.
+//
+// Solidity: function DISBURSE_EPOCH() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) DISBURSEEPOCH() (*big.Int, error) {
+	return _FileStormManager.Contract.DISBURSEEPOCH(&_FileStormManager.CallOpts)
+}
+
+// DISBURSETOTAL is a free data retrieval call binding the contract method 0xdb3e9a32.
+//
+// Solidity: function DISBURSE_TOTAL() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) DISBURSETOTAL(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "DISBURSE_TOTAL")
+	return *ret0, err
+}
+
+// DISBURSETOTAL is a free data retrieval call binding the contract method 0xdb3e9a32.
+//
+// Solidity: function DISBURSE_TOTAL() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) DISBURSETOTAL() (*big.Int, error) {
+	return _FileStormManager.Contract.DISBURSETOTAL(&_FileStormManager.CallOpts)
+}
+
+// DISBURSETOTAL is a free data retrieval call binding the contract method 0xdb3e9a32.
+//
+// Solidity: function DISBURSE_TOTAL() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) DISBURSETOTAL() (*big.Int, error) {
+	return _FileStormManager.Contract.DISBURSETOTAL(&_FileStormManager.CallOpts)
+}
+
+// HEARTBEATMAXSKEW is a free data retrieval call binding the contract method 0xfebfe429.
+//
+// Solidity: function HEARTBEAT_MAX_SKEW() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) HEARTBEATMAXSKEW(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "HEARTBEAT_MAX_SKEW")
+	return *ret0, err
+}
+
+// HEARTBEATMAXSKEW is a free data retrieval call binding the contract method 0xfebfe429.
+//
+// Solidity: function HEARTBEAT_MAX_SKEW() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) HEARTBEATMAXSKEW() (*big.Int, error) {
+	return _FileStormManager.Contract.HEARTBEATMAXSKEW(&_FileStormManager.CallOpts)
+}
+
+// HEARTBEATMAXSKEW is a free data retrieval call binding the contract method 0xfebfe429.
+//
+// Solidity: function HEARTBEAT_MAX_SKEW() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) HEARTBEATMAXSKEW() (*big.Int, error) {
+	return _FileStormManager.Contract.HEARTBEATMAXSKEW(&_FileStormManager.CallOpts)
+}
+
+// Admins is a free data retrieval call binding the contract method 0x429b62e5.
+//
+// Solidity: function admins(address ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) Admins(opts *bind.CallOpts, arg0 common.Address) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "admins", arg0)
+	return *ret0, err
+}
+
+// Admins is a free data retrieval call binding the contract method 0x429b62e5.
+//
+// Solidity: function admins(address ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) Admins(arg0 common.Address) (*big.Int, error) {
+	return _FileStormManager.Contract.Admins(&_FileStormManager.CallOpts, arg0)
+}
+
+// Admins is a free data retrieval call binding the contract method 0x429b62e5.
+//
+// Solidity: function admins(address ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) Admins(arg0 common.Address) (*big.Int, error) {
+	return _FileStormManager.Contract.Admins(&_FileStormManager.CallOpts, arg0)
+}
+
+// AttestationHash is a free data retrieval call binding the contract method 0x8ca38e57.
+//
+// Solidity: function attestationHash(address nodeAddress, uint8 state, string ip, uint256 timestamp) constant returns(bytes32)
+func (_FileStormManager *FileStormManagerCaller) AttestationHash(opts *bind.CallOpts, nodeAddress common.Address, state uint8, ip string, timestamp *big.Int) ([32]byte, error) {
+	var (
+		ret0 = new([32]byte)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "attestationHash", nodeAddress, state, ip, timestamp)
+	return *ret0, err
+}
+
+// AttestationHash is a free data retrieval call binding the contract method 0x8ca38e57.
+//
+// Solidity: function attestationHash(address nodeAddress, uint8 state, string ip, uint256 timestamp) constant returns(bytes32)
+func (_FileStormManager *FileStormManagerSession) AttestationHash(nodeAddress common.Address, state uint8, ip string, timestamp *big.Int) ([32]byte, error) {
+	return _FileStormManager.Contract.AttestationHash(&_FileStormManager.CallOpts, nodeAddress, state, ip, timestamp)
+}
+
+// AttestationHash is a free data retrieval call binding the contract method 0x8ca38e57.
+//
+// Solidity: function attestationHash(address nodeAddress, uint8 state, string ip, uint256 timestamp) constant returns(bytes32)
+func (_FileStormManager *FileStormManagerCallerSession) AttestationHash(nodeAddress common.Address, state uint8, ip string, timestamp *big.Int) ([32]byte, error) {
+	return _FileStormManager.Contract.AttestationHash(&_FileStormManager.CallOpts, nodeAddress, state, ip, timestamp)
+}
+
+// DisburseMapping is a free data retrieval call binding the contract method 0xec2e38b3.
+//
+// Solidity: function disburseMapping(uint256 ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) DisburseMapping(opts *bind.CallOpts, arg0 *big.Int) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "disburseMapping", arg0)
+	return *ret0, err
+}
+
+// DisburseMapping is a free data retrieval call binding the contract method 0xec2e38b3.
+//
+// Solidity: function disburseMapping(uint256 ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) DisburseMapping(arg0 *big.Int) (*big.Int, error) {
+	return _FileStormManager.Contract.DisburseMapping(&_FileStormManager.CallOpts, arg0)
+}
+
+// DisburseMapping is a free data retrieval call binding the contract method 0xec2e38b3.
+//
+// Solidity: function disburseMapping(uint256 ) constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) DisburseMapping(arg0 *big.Int) (*big.Int, error) {
+	return _FileStormManager.Contract.DisburseMapping(&_FileStormManager.CallOpts, arg0)
+}
+
+// Heartbeats is a free data retrieval call binding the contract method 0x02f10088.
+//
+// Solidity: function heartbeats(address ) constant returns(uint256 blockNumber, uint256 timestamp, uint8 state, string ip)
+func (_FileStormManager *FileStormManagerCaller) Heartbeats(opts *bind.CallOpts, arg0 common.Address) (struct {
+	BlockNumber *big.Int
+	Timestamp   *big.Int
+	State       uint8
+	Ip          string
+}, error) {
+	ret := new(struct {
+		BlockNumber *big.Int
+		Timestamp   *big.Int
+		State       uint8
+		Ip          string
+	})
+	out := ret
+	err := _FileStormManager.contract.Call(opts, out, "heartbeats", arg0)
+	return *ret, err
+}
+
+// Heartbeats is a free data retrieval call binding the contract method 0x02f10088.
+//
+// Solidity: function heartbeats(address ) constant returns(uint256 blockNumber, uint256 timestamp, uint8 state, string ip)
+func (_FileStormManager *FileStormManagerSession) Heartbeats(arg0 common.Address) (struct {
+	BlockNumber *big.Int
+	Timestamp   *big.Int
+	State       uint8
+	Ip          string
+}, error) {
+	return _FileStormManager.Contract.Heartbeats(&_FileStormManager.CallOpts, arg0)
+}
+
+// Heartbeats is a free data retrieval call binding the contract method 0x02f10088.
+//
+// Solidity: function heartbeats(address ) constant returns(uint256 blockNumber, uint256 timestamp, uint8 state, string ip)
+func (_FileStormManager *FileStormManagerCallerSession) Heartbeats(arg0 common.Address) (struct {
+	BlockNumber *big.Int
+	Timestamp   *big.Int
+	State       uint8
+	Ip          string
+}, error) {
+	return _FileStormManager.Contract.Heartbeats(&_FileStormManager.CallOpts, arg0)
+}
+
+// IsAlive is a free data retrieval call binding the contract method 0x1703e5f9.
+//
+// Solidity: function isAlive(address nodeAddress) constant returns(bool)
+func (_FileStormManager *FileStormManagerCaller) IsAlive(opts *bind.CallOpts, nodeAddress common.Address) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "isAlive", nodeAddress)
+	return *ret0, err
+}
+
+// IsAlive is a free data retrieval call binding the contract method 0x1703e5f9.
+//
+// Solidity: function isAlive(address nodeAddress) constant returns(bool)
+func (_FileStormManager *FileStormManagerSession) IsAlive(nodeAddress common.Address) (bool, error) {
+	return _FileStormManager.Contract.IsAlive(&_FileStormManager.CallOpts, nodeAddress)
+}
+
+// IsAlive is a free data retrieval call binding the contract method 0x1703e5f9.
+//
+// Solidity: function isAlive(address nodeAddress) constant returns(bool)
+func (_FileStormManager *FileStormManagerCallerSession) IsAlive(nodeAddress common.Address) (bool, error) {
+	return _FileStormManager.Contract.IsAlive(&_FileStormManager.CallOpts, nodeAddress)
+}
+
+// NodeMapping is a free data retrieval call binding the contract method 0xfbd1b4ce.
+//
+// Solidity: function nodeMapping(address ) constant returns(string nodeId, address nodeAddress, address beneficiary, uint256 stakingAmount, uint256 disburseAmount, uint256 registerBlock, uint256 registerType, uint256 nodeStatus, uint256 nextRewardBlock, uint256 disburseCount, uint256 disbursedTotal)
+func (_FileStormManager *FileStormManagerCaller) NodeMapping(opts *bind.CallOpts, arg0 common.Address) (struct {
+	NodeId          string
+	NodeAddress     common.Address
+	Beneficiary     common.Address
+	StakingAmount   *big.Int
+	DisburseAmount  *big.Int
+	RegisterBlock   *big.Int
+	RegisterType    *big.Int
+	NodeStatus      *big.Int
+	NextRewardBlock *big.Int
+	DisburseCount   *big.Int
+	DisbursedTotal  *big.Int
+}, error) {
+	ret := new(struct {
+		NodeId          string
+		NodeAddress     common.Address
+		Beneficiary     common.Address
+		StakingAmount   *big.Int
+		DisburseAmount  *big.Int
+		RegisterBlock   *big.Int
+		RegisterType    *big.Int
+		NodeStatus      *big.Int
+		NextRewardBlock *big.Int
+		DisburseCount   *big.Int
+		DisbursedTotal  *big.Int
+	})
+	out := ret
+	err := _FileStormManager.contract.Call(opts, out, "nodeMapping", arg0)
+	return *ret, err
+}
+
+// NodeMapping is a free data retrieval call binding the contract method 0xfbd1b4ce.
+//
+// Solidity: function nodeMapping(address ) constant returns(string nodeId, address nodeAddress, address beneficiary, uint256 stakingAmount, uint256 disburseAmount, uint256 registerBlock, uint256 registerType, uint256 nodeStatus, uint256 nextRewardBlock, uint256 disburseCount, uint256 disbursedTotal)
+func (_FileStormManager *FileStormManagerSession) NodeMapping(arg0 common.Address) (struct {
+	NodeId          string
+	NodeAddress     common.Address
+	Beneficiary     common.Address
+	StakingAmount   *big.Int
+	DisburseAmount  *big.Int
+	RegisterBlock   *big.Int
+	RegisterType    *big.Int
+	NodeStatus      *big.Int
+	NextRewardBlock *big.Int
+	DisburseCount   *big.Int
+	DisbursedTotal  *big.Int
+}, error) {
+	return _FileStormManager.Contract.NodeMapping(&_FileStormManager.CallOpts, arg0)
+}
+
+// NodeMapping is a free data retrieval call binding the contract method 0xfbd1b4ce.
+//
+// Solidity: function nodeMapping(address ) constant returns(string nodeId, address nodeAddress, address beneficiary, uint256 stakingAmount, uint256 disburseAmount, uint256 registerBlock, uint256 registerType, uint256 nodeStatus, uint256 nextRewardBlock, uint256 disburseCount, uint256 disbursedTotal)
+func (_FileStormManager *FileStormManagerCallerSession) NodeMapping(arg0 common.Address) (struct {
+	NodeId          string
+	NodeAddress     common.Address
+	Beneficiary     common.Address
+	StakingAmount   *big.Int
+	DisburseAmount  *big.Int
+	RegisterBlock   *big.Int
+	RegisterType    *big.Int
+	NodeStatus      *big.Int
+	NextRewardBlock *big.Int
+	DisburseCount   *big.Int
+	DisbursedTotal  *big.Int
+}, error) {
+	return _FileStormManager.Contract.NodeMapping(&_FileStormManager.CallOpts, arg0)
+}
+
+// StakingAmount is a free data retrieval call binding the contract method 0xea7afe5d.
+//
+// Solidity: function staking_amount() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) StakingAmount(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "staking_amount")
+	return *ret0, err
+}
+
+// StakingAmount is a free data retrieval call binding the contract method 0xea7afe5d.
+//
+// Solidity: function staking_amount() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) StakingAmount() (*big.Int, error) {
+	return _FileStormManager.Contract.StakingAmount(&_FileStormManager.CallOpts)
+}
+
+// StakingAmount is a free data retrieval call binding the contract method 0xea7afe5d.
+//
+// Solidity: function staking_amount() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) StakingAmount() (*big.Int, error) {
+	return _FileStormManager.Contract.StakingAmount(&_FileStormManager.CallOpts)
+}
+
+// StakingLimit is a free data retrieval call binding the contract method 0xc49d9526.
+//
+// Solidity: function staking_limit() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCaller) StakingLimit(opts *bind.CallOpts) (*big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+	)
+	out := ret0
+	err := _FileStormManager.contract.Call(opts, out, "staking_limit")
+	return *ret0, err
+}
+
+// StakingLimit is a free data retrieval call binding the contract method 0xc49d9526.
+//
+// Solidity: function staking_limit() constant returns(uint256)
+func (_FileStormManager *FileStormManagerSession) StakingLimit() (*big.Int, error) {
+	return _FileStormManager.Contract.StakingLimit(&_FileStormManager.CallOpts)
+}
+
+// StakingLimit is a free data retrieval call binding the contract method 0xc49d9526.
+//
+// Solidity: function staking_limit() constant returns(uint256)
+func (_FileStormManager *FileStormManagerCallerSession) StakingLimit() (*big.Int, error) {
+	return _FileStormManager.Contract.StakingLimit(&_FileStormManager.CallOpts)
+}
+
+// AddAdmin is a paid mutator transaction binding the contract method 0x70480275.
+//
+// Solidity: function addAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerTransactor) AddAdmin(opts *bind.TransactOpts, admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "addAdmin", admin)
+}
+
+// AddAdmin is a paid mutator transaction binding the contract method 0x70480275.
+//
+// Solidity: function addAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerSession) AddAdmin(admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.AddAdmin(&_FileStormManager.TransactOpts, admin)
+}
+
+// AddAdmin is a paid mutator transaction binding the contract method 0x70480275.
+//
+// Solidity: function addAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) AddAdmin(admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.AddAdmin(&_FileStormManager.TransactOpts, admin)
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0x465db40d.
+//
+// Solidity: function addNode(string nodeId, address nodeAddress, address beneficiary, uint256 stakingCount, uint256 registerType) returns()
+func (_FileStormManager *FileStormManagerTransactor) AddNode(opts *bind.TransactOpts, nodeId string, nodeAddress common.Address, beneficiary common.Address, stakingCount *big.Int, registerType *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "addNode", nodeId, nodeAddress, beneficiary, stakingCount, registerType)
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0x465db40d.
+//
+// Solidity: function addNode(string nodeId, address nodeAddress, address beneficiary, uint256 stakingCount, uint256 registerType) returns()
+func (_FileStormManager *FileStormManagerSession) AddNode(nodeId string, nodeAddress common.Address, beneficiary common.Address, stakingCount *big.Int, registerType *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.AddNode(&_FileStormManager.TransactOpts, nodeId, nodeAddress, beneficiary, stakingCount, registerType)
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0x465db40d.
+//
+// Solidity: function addNode(string nodeId, address nodeAddress, address beneficiary, uint256 stakingCount, uint256 registerType) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) AddNode(nodeId string, nodeAddress common.Address, beneficiary common.Address, stakingCount *big.Int, registerType *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.AddNode(&_FileStormManager.TransactOpts, nodeId, nodeAddress, beneficiary, stakingCount, registerType)
+}
+
+// Disburse is a paid mutator transaction binding the contract method 0x1c8ce890.
+//
+// Solidity: function disburse(address nodeAddress) returns()
+func (_FileStormManager *FileStormManagerTransactor) Disburse(opts *bind.TransactOpts, nodeAddress common.Address) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "disburse", nodeAddress)
+}
+
+// Disburse is a paid mutator transaction binding the contract method 0x1c8ce890.
+//
+// Solidity: function disburse(address nodeAddress) returns()
+func (_FileStormManager *FileStormManagerSession) Disburse(nodeAddress common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.Disburse(&_FileStormManager.TransactOpts, nodeAddress)
+}
+
+// Disburse is a paid mutator transaction binding the contract method 0x1c8ce890.
+//
+// Solidity: function disburse(address nodeAddress) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) Disburse(nodeAddress common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.Disburse(&_FileStormManager.TransactOpts, nodeAddress)
+}
+
+// Heartbeat is a paid mutator transaction binding the contract method 0x92da1b2f.
+//
+// Solidity: function heartbeat(uint8 state, string ip, uint256 timestamp) returns()
+func (_FileStormManager *FileStormManagerTransactor) Heartbeat(opts *bind.TransactOpts, state uint8, ip string, timestamp *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "heartbeat", state, ip, timestamp)
+}
+
+// Heartbeat is a paid mutator transaction binding the contract method 0x92da1b2f.
+//
+// Solidity: function heartbeat(uint8 state, string ip, uint256 timestamp) returns()
+func (_FileStormManager *FileStormManagerSession) Heartbeat(state uint8, ip string, timestamp *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.Heartbeat(&_FileStormManager.TransactOpts, state, ip, timestamp)
+}
+
+// Heartbeat is a paid mutator transaction binding the contract method 0x92da1b2f.
+//
+// Solidity: function heartbeat(uint8 state, string ip, uint256 timestamp) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) Heartbeat(state uint8, ip string, timestamp *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.Heartbeat(&_FileStormManager.TransactOpts, state, ip, timestamp)
+}
+
+// RemoveAdmin is a paid mutator transaction binding the contract method 0x1785f53c.
+//
+// Solidity: function removeAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerTransactor) RemoveAdmin(opts *bind.TransactOpts, admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "removeAdmin", admin)
+}
+
+// RemoveAdmin is a paid mutator transaction binding the contract method 0x1785f53c.
+//
+// Solidity: function removeAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerSession) RemoveAdmin(admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.RemoveAdmin(&_FileStormManager.TransactOpts, admin)
+}
+
+// RemoveAdmin is a paid mutator transaction binding the contract method 0x1785f53c.
+//
+// Solidity: function removeAdmin(address admin) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) RemoveAdmin(admin common.Address) (*types.Transaction, error) {
+	return _FileStormManager.Contract.RemoveAdmin(&_FileStormManager.TransactOpts, admin)
+}
+
+// SubmitHeartbeat is a paid mutator transaction binding the contract method 0x04d25b3f.
+//
+// Solidity: function submitHeartbeat(address nodeAddress, uint8 state, string ip, uint256 timestamp, uint8 v, bytes32 r, bytes32 s) returns()
+func (_FileStormManager *FileStormManagerTransactor) SubmitHeartbeat(opts *bind.TransactOpts, nodeAddress common.Address, state uint8, ip string, timestamp *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "submitHeartbeat", nodeAddress, state, ip, timestamp, v, r, s)
+}
+
+// SubmitHeartbeat is a paid mutator transaction binding the contract method 0x04d25b3f.
+//
+// Solidity: function submitHeartbeat(address nodeAddress, uint8 state, string ip, uint256 timestamp, uint8 v, bytes32 r, bytes32 s) returns()
+func (_FileStormManager *FileStormManagerSession) SubmitHeartbeat(nodeAddress common.Address, state uint8, ip string, timestamp *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	return _FileStormManager.Contract.SubmitHeartbeat(&_FileStormManager.TransactOpts, nodeAddress, state, ip, timestamp, v, r, s)
+}
+
+// SubmitHeartbeat is a paid mutator transaction binding the contract method 0x04d25b3f.
+//
+// Solidity: function submitHeartbeat(address nodeAddress, uint8 state, string ip, uint256 timestamp, uint8 v, bytes32 r, bytes32 s) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) SubmitHeartbeat(nodeAddress common.Address, state uint8, ip string, timestamp *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	return _FileStormManager.Contract.SubmitHeartbeat(&_FileStormManager.TransactOpts, nodeAddress, state, ip, timestamp, v, r, s)
+}
+
+// UpdateDisburseAmount is a paid mutator transaction binding the contract method 0x723499af.
+//
+// Solidity: function updateDisburseAmount(uint256 registerType, uint256 amount) returns()
+func (_FileStormManager *FileStormManagerTransactor) UpdateDisburseAmount(opts *bind.TransactOpts, registerType *big.Int, amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "updateDisburseAmount", registerType, amount)
+}
+
+// UpdateDisburseAmount is a paid mutator transaction binding the contract method 0x723499af.
+//
+// Solidity: function updateDisburseAmount(uint256 registerType, uint256 amount) returns()
+func (_FileStormManager *FileStormManagerSession) UpdateDisburseAmount(registerType *big.Int, amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateDisburseAmount(&_FileStormManager.TransactOpts, registerType, amount)
+}
+
+// UpdateDisburseAmount is a paid mutator transaction binding the contract method 0x723499af.
+//
+// Solidity: function updateDisburseAmount(uint256 registerType, uint256 amount) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) UpdateDisburseAmount(registerType *big.Int, amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateDisburseAmount(&_FileStormManager.TransactOpts, registerType, amount)
+}
+
+// UpdateNodeStatus is a paid mutator transaction binding the contract method 0x31ee0064.
+//
+// Solidity: function updateNodeStatus(address nodeAddress, uint256 nodeStatus) returns()
+func (_FileStormManager *FileStormManagerTransactor) UpdateNodeStatus(opts *bind.TransactOpts, nodeAddress common.Address, nodeStatus *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "updateNodeStatus", nodeAddress, nodeStatus)
+}
+
+// UpdateNodeStatus is a paid mutator transaction binding the contract method 0x31ee0064.
+//
+// Solidity: function updateNodeStatus(address nodeAddress, uint256 nodeStatus) returns()
+func (_FileStormManager *FileStormManagerSession) UpdateNodeStatus(nodeAddress common.Address, nodeStatus *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateNodeStatus(&_FileStormManager.TransactOpts, nodeAddress, nodeStatus)
+}
+
+// UpdateNodeStatus is a paid mutator transaction binding the contract method 0x31ee0064.
+//
+// Solidity: function updateNodeStatus(address nodeAddress, uint256 nodeStatus) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) UpdateNodeStatus(nodeAddress common.Address, nodeStatus *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateNodeStatus(&_FileStormManager.TransactOpts, nodeAddress, nodeStatus)
+}
+
+// UpdateStakingAmount is a paid mutator transaction binding the contract method 0xa3196916.
+//
+// Solidity: function updateStakingAmount(uint256 amount) returns()
+func (_FileStormManager *FileStormManagerTransactor) UpdateStakingAmount(opts *bind.TransactOpts, amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "updateStakingAmount", amount)
+}
+
+// UpdateStakingAmount is a paid mutator transaction binding the contract method 0xa3196916.
+//
+// Solidity: function updateStakingAmount(uint256 amount) returns()
+func (_FileStormManager *FileStormManagerSession) UpdateStakingAmount(amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateStakingAmount(&_FileStormManager.TransactOpts, amount)
+}
+
+// UpdateStakingAmount is a paid mutator transaction binding the contract method 0xa3196916.
+//
+// Solidity: function updateStakingAmount(uint256 amount) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) UpdateStakingAmount(amount *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateStakingAmount(&_FileStormManager.TransactOpts, amount)
+}
+
+// UpdateStakingLimit is a paid mutator transaction binding the contract method 0x4b748179.
+//
+// Solidity: function updateStakingLimit(uint256 limit) returns()
+func (_FileStormManager *FileStormManagerTransactor) UpdateStakingLimit(opts *bind.TransactOpts, limit *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.contract.Transact(opts, "updateStakingLimit", limit)
+}
+
+// UpdateStakingLimit is a paid mutator transaction binding the contract method 0x4b748179.
+//
+// Solidity: function updateStakingLimit(uint256 limit) returns()
+func (_FileStormManager *FileStormManagerSession) UpdateStakingLimit(limit *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateStakingLimit(&_FileStormManager.TransactOpts, limit)
+}
+
+// UpdateStakingLimit is a paid mutator transaction binding the contract method 0x4b748179.
+//
+// Solidity: function updateStakingLimit(uint256 limit) returns()
+func (_FileStormManager *FileStormManagerTransactorSession) UpdateStakingLimit(limit *big.Int) (*types.Transaction, error) {
+	return _FileStormManager.Contract.UpdateStakingLimit(&_FileStormManager.TransactOpts, limit)
+}
+
+// FileStormManagerHeartbeatReportedIterator is returned from FilterHeartbeatReported and is used to iterate over the raw logs and unpacked data for HeartbeatReported events raised by the FileStormManager contract.
+type FileStormManagerHeartbeatReportedIterator struct {
+	Event *FileStormManagerHeartbeatReported // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log         // Log channel receiving the found contract events
+	sub  filestorm.Subscription // Subscription for errors, completion and termination
+	done bool                   // Whether the subscription completed delivering logs
+	fail error                  // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *FileStormManagerHeartbeatReportedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(FileStormManagerHeartbeatReported)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(FileStormManagerHeartbeatReported)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *FileStormManagerHeartbeatReportedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *FileStormManagerHeartbeatReportedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// FileStormManagerHeartbeatReported represents a HeartbeatReported event raised by the FileStormManager contract.
+type FileStormManagerHeartbeatReported struct {
+	NodeAddress common.Address
+	State       uint8
+	Ip          string
+	Timestamp   *big.Int
+	Raw         types.Log // Blockchain specific contextual infos
+}
+
+// FilterHeartbeatReported is a free log retrieval operation binding the contract event 0x10b3c1368d1bd46591385a100bb239b58b721f9cbcf3ce6932a70f4b32408a2e.
+//
+// Solidity: event HeartbeatReported(address indexed nodeAddress, uint8 state, string ip, uint256 timestamp)
+func (_FileStormManager *FileStormManagerFilterer) FilterHeartbeatReported(opts *bind.FilterOpts, nodeAddress []common.Address) (*FileStormManagerHeartbeatReportedIterator, error) {
+
+	var nodeAddressRule []interface{}
+	for _, nodeAddressItem := range nodeAddress {
+		nodeAddressRule = append(nodeAddressRule, nodeAddressItem)
+	}
+
+	logs, sub, err := _FileStormManager.contract.FilterLogs(opts, "HeartbeatReported", nodeAddressRule)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStormManagerHeartbeatReportedIterator{contract: _FileStormManager.contract, event: "HeartbeatReported", logs: logs, sub: sub}, nil
+}
+
+// WatchHeartbeatReported is a free log subscription operation binding the contract event 0x10b3c1368d1bd46591385a100bb239b58b721f9cbcf3ce6932a70f4b32408a2e.
+//
+// Solidity: event HeartbeatReported(address indexed nodeAddress, uint8 state, string ip, uint256 timestamp)
+func (_FileStormManager *FileStormManagerFilterer) WatchHeartbeatReported(opts *bind.WatchOpts, sink chan<- *FileStormManagerHeartbeatReported, nodeAddress []common.Address) (event.Subscription, error) {
+
+	var nodeAddressRule []interface{}
+	for _, nodeAddressItem := range nodeAddress {
+		nodeAddressRule = append(nodeAddressRule, nodeAddressItem)
+	}
+
+	logs, sub, err := _FileStormManager.contract.WatchLogs(opts, "HeartbeatReported", nodeAddressRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(FileStormManagerHeartbeatReported)
+				if err := _FileStormManager.contract.UnpackLog(event, "HeartbeatReported", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseHeartbeatReported is a log parse operation binding the contract event 0x10b3c1368d1bd46591385a100bb239b58b721f9cbcf3ce6932a70f4b32408a2e.
+//
+// Solidity: event HeartbeatReported(address indexed nodeAddress, uint8 state, string ip, uint256 timestamp)
+func (_FileStormManager *FileStormManagerFilterer) ParseHeartbeatReported(log types.Log) (*FileStormManagerHeartbeatReported, error) {
+	event := new(FileStormManagerHeartbeatReported)
+	if err := _FileStormManager.contract.UnpackLog(event, "HeartbeatReported", log); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
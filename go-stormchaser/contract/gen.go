@@ -0,0 +1,5 @@
+// contract project gen.go
+package contract
+
+//FileStormManager.abi 由 solidity/FileStormManager/FileStormManager.sol 编译得到
+//go:generate abigen --abi FileStormManager.abi --pkg contract --type FileStormManager --out fileStormManager.go
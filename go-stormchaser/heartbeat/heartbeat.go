@@ -0,0 +1,243 @@
+// heartbeat project heartbeat.go
+package heartbeat
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"stormchaser/contract"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid heartbeat signature")
+	ErrStaleTimestamp   = errors.New("heartbeat timestamp out of the accepted window")
+)
+
+//节点账户签名的心跳
+type Attestation struct {
+	Contract  string `json:"contract"`
+	Node      string `json:"node"`
+	State     uint8  `json:"state"` //是否正常（0/1）
+	Ip        string `json:"ip"`
+	Timestamp int64  `json:"timestamp"`
+	Signature string `json:"signature"` //0x + r + s + v（27/28）
+}
+
+/*
+ *签名内容，与合约 FileStormManager.attestationHash 一致：
+ *keccak256("\x19Ethereum Signed Message:\n32" + keccak256(合约地址 + 节点地址 + state + ip + timestamp))
+ */
+func AttestationHash(contract, node common.Address, state uint8, ip string, timestamp int64) []byte {
+
+	hash := crypto.Keccak256(
+		contract.Bytes(),
+		node.Bytes(),
+		[]byte{state},
+		[]byte(ip),
+		common.LeftPadBytes(big.NewInt(timestamp).Bytes(), 32),
+	)
+	return crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n32"), hash)
+}
+
+//用节点账户私钥签名心跳
+func NewAttestation(key *ecdsa.PrivateKey, contract common.Address, state uint8, ip string) (*Attestation, error) {
+
+	node := crypto.PubkeyToAddress(key.PublicKey)
+	timestamp := time.Now().Unix()
+	sig, sErr := crypto.Sign(AttestationHash(contract, node, state, ip, timestamp), key)
+	if sErr != nil {
+		return nil, sErr
+	}
+	sig[64] += 27
+
+	return &Attestation{
+		Contract:  contract.Hex(),
+		Node:      node.Hex(),
+		State:     state,
+		Ip:        ip,
+		Timestamp: timestamp,
+		Signature: "0x" + hex.EncodeToString(sig),
+	}, nil
+}
+
+//服务端验证心跳：签名者必须是节点账户，时间戳在 maxSkew 秒内，返回节点地址
+func (a *Attestation) Verify(contract common.Address, maxSkew int64) (common.Address, error) {
+
+	if !common.IsHexAddress(a.Node) || common.HexToAddress(a.Contract) != contract {
+		return common.Address{}, ErrInvalidSignature
+	}
+	now := time.Now().Unix()
+	if a.Timestamp < now-maxSkew || a.Timestamp > now+maxSkew {
+		return common.Address{}, ErrStaleTimestamp
+	}
+
+	sig, dErr := hex.DecodeString(strings.TrimPrefix(a.Signature, "0x"))
+	if dErr != nil || len(sig) != 65 || sig[64] < 27 {
+		return common.Address{}, ErrInvalidSignature
+	}
+	sig = append([]byte{}, sig...)
+	sig[64] -= 27
+
+	node := common.HexToAddress(a.Node)
+	pub, eErr := crypto.SigToPub(AttestationHash(contract, node, a.State, a.Ip, a.Timestamp), sig)
+	if eErr != nil || crypto.PubkeyToAddress(*pub) != node {
+		return common.Address{}, ErrInvalidSignature
+	}
+	return node, nil
+}
+
+//心跳上报，collector 为空时节点直接发送交易，否则交给收集服务聚合提交
+type Reporter struct {
+	key       *ecdsa.PrivateKey
+	contract  common.Address
+	manager   *contract.FileStormManager
+	collector string
+
+	mutex      sync.Mutex
+	lastReport *LastReport
+	succeeded  uint64
+	failed     uint64
+}
+
+//最近一次上报
+type LastReport struct {
+	Time     time.Time `json:"time"`
+	State    uint8     `json:"state"`
+	Ip       string    `json:"ip"`
+	Response string    `json:"response,omitempty"` //交易 hash 或收集服务的返回
+	Error    string    `json:"error,omitempty"`
+}
+
+func NewReporter(key *ecdsa.PrivateKey, manager *contract.FileStormManager, contractAddress common.Address, collector string) *Reporter {
+
+	return &Reporter{key: key, contract: contractAddress, manager: manager, collector: collector}
+}
+
+//节点地址
+func (r *Reporter) Address() common.Address {
+
+	return crypto.PubkeyToAddress(r.key.PublicKey)
+}
+
+//上报心跳
+func (r *Reporter) Report(state uint8, ip string) error {
+
+	response, rErr := r.report(state, ip)
+
+	last := &LastReport{Time: time.Now(), State: state, Ip: ip, Response: response}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if rErr != nil {
+		last.Error = rErr.Error()
+		r.failed++
+	} else {
+		r.succeeded++
+	}
+	r.lastReport = last
+	return rErr
+}
+
+func (r *Reporter) report(state uint8, ip string) (string, error) {
+
+	attestation, aErr := NewAttestation(r.key, r.contract, state, ip)
+	if aErr != nil {
+		return "", aErr
+	}
+	if r.collector != "" {
+		return postAttestation(r.collector, attestation)
+	}
+	tx, tErr := r.manager.Heartbeat(bind.NewKeyedTransactor(r.key),
+		attestation.State, attestation.Ip, big.NewInt(attestation.Timestamp))
+	if tErr != nil {
+		return "", tErr
+	}
+	return tx.Hash().Hex(), nil
+}
+
+//最近一次上报，未上报时返回 nil，以及成功、失败的次数
+func (r *Reporter) LastReport() (*LastReport, uint64, uint64) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.lastReport, r.succeeded, r.failed
+}
+
+//收集服务提交节点签名的心跳，Reporter 的账户必须是合约管理员，返回交易 hash
+func (r *Reporter) Submit(attestation *Attestation, maxSkew int64) (string, error) {
+
+	node, vErr := attestation.Verify(r.contract, maxSkew)
+	if vErr != nil {
+		return "", vErr
+	}
+	if r.manager == nil {
+		return "", errors.New("reporter is not connected to the chain")
+	}
+	sig, _ := hex.DecodeString(strings.TrimPrefix(attestation.Signature, "0x"))
+	var rBytes, sBytes [32]byte
+	copy(rBytes[:], sig[:32])
+	copy(sBytes[:], sig[32:64])
+	tx, tErr := r.manager.SubmitHeartbeat(bind.NewKeyedTransactor(r.key),
+		node, attestation.State, attestation.Ip, big.NewInt(attestation.Timestamp), sig[64], rBytes, sBytes)
+	if tErr != nil {
+		return "", tErr
+	}
+	return tx.Hash().Hex(), nil
+}
+
+//收集服务接口：接收其他节点 POST 的签名心跳（即其 --collector 地址），验证后提交到合约
+func (r *Reporter) CollectHandler(maxSkew int64) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		attestation := new(Attestation)
+		if dErr := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(attestation); dErr != nil {
+			http.Error(w, "invalid heartbeat", http.StatusBadRequest)
+			return
+		}
+		hash, sErr := r.Submit(attestation, maxSkew)
+		switch {
+		case sErr == ErrInvalidSignature || sErr == ErrStaleTimestamp:
+			http.Error(w, sErr.Error(), http.StatusBadRequest)
+			return
+		case sErr != nil:
+			http.Error(w, sErr.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(hash))
+	}
+}
+
+func postAttestation(collector string, attestation *Attestation) (string, error) {
+
+	data, mErr := json.Marshal(attestation)
+	if mErr != nil {
+		return "", mErr
+	}
+	resp, pErr := http.Post(collector, "application/json", bytes.NewReader(data))
+	if pErr != nil {
+		return "", pErr
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != 200 {
+		return string(body), fmt.Errorf("collector rejected heartbeat: %d %s", resp.StatusCode, string(body))
+	}
+	return string(body), nil
+}
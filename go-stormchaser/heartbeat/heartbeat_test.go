@@ -0,0 +1,154 @@
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+var testContract = common.HexToAddress("0x00000000000000000000000000000000000c0de1")
+
+//AttestationHash 必须与合约 attestationHash 的 abi.encodePacked 编码一致：
+//address 20 字节、uint8 1 字节、string 原始字节、uint256 32 字节大端，不补齐
+func TestAttestationHashPacked(t *testing.T) {
+
+	node := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	ip := "10.0.0.1"
+	timestamp := int64(1600000000)
+
+	var packed []byte
+	packed = append(packed, testContract.Bytes()...)
+	packed = append(packed, node.Bytes()...)
+	packed = append(packed, 1)
+	packed = append(packed, []byte(ip)...)
+	stamp := make([]byte, 32)
+	big.NewInt(timestamp).FillBytes(stamp)
+	packed = append(packed, stamp...)
+	if len(packed) != 20+20+1+len(ip)+32 {
+		t.Fatalf("packed length %d", len(packed))
+	}
+
+	prefixed := append([]byte("\x19Ethereum Signed Message:\n32"), crypto.Keccak256(packed)...)
+	want := crypto.Keccak256(prefixed)
+	if got := AttestationHash(testContract, node, 1, ip, timestamp); !bytes.Equal(got, want) {
+		t.Fatalf("AttestationHash = %x, want %x", got, want)
+	}
+
+	//每个字段都参与签名
+	if bytes.Equal(AttestationHash(testContract, node, 0, ip, timestamp), want) ||
+		bytes.Equal(AttestationHash(testContract, node, 1, ip, timestamp+1), want) ||
+		bytes.Equal(AttestationHash(testContract, node, 1, "10.0.0.2", timestamp), want) ||
+		bytes.Equal(AttestationHash(node, node, 1, ip, timestamp), want) {
+		t.Fatal("AttestationHash ignores a field")
+	}
+}
+
+func TestAttestationSignVerify(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	attestation, nErr := NewAttestation(key, testContract, 1, "10.0.0.1")
+	if nErr != nil {
+		t.Fatal(nErr)
+	}
+	node, vErr := attestation.Verify(testContract, 60)
+	if vErr != nil {
+		t.Fatal(vErr)
+	}
+	if node != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("verified node %s, want %s", node.Hex(), crypto.PubkeyToAddress(key.PublicKey).Hex())
+	}
+
+	other, _ := crypto.GenerateKey()
+	tests := []struct {
+		name   string
+		modify func(a *Attestation)
+		want   error
+	}{
+		{"state changed", func(a *Attestation) { a.State = 0 }, ErrInvalidSignature},
+		{"ip changed", func(a *Attestation) { a.Ip = "10.0.0.2" }, ErrInvalidSignature},
+		{"other node", func(a *Attestation) { a.Node = crypto.PubkeyToAddress(other.PublicKey).Hex() }, ErrInvalidSignature},
+		{"other contract", func(a *Attestation) { a.Contract = common.HexToAddress("0x1").Hex() }, ErrInvalidSignature},
+		{"short signature", func(a *Attestation) { a.Signature = a.Signature[:20] }, ErrInvalidSignature},
+		{"stale", func(a *Attestation) { a.Timestamp -= 120 }, ErrStaleTimestamp},
+		{"future", func(a *Attestation) { a.Timestamp += 120 }, ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		modified := *attestation
+		tt.modify(&modified)
+		if _, vErr := modified.Verify(testContract, 60); vErr != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, vErr, tt.want)
+		}
+	}
+}
+
+func TestReportToCollector(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	var received *Attestation
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = new(Attestation)
+		json.NewDecoder(req.Body).Decode(received)
+		w.Write([]byte("0xhash"))
+	}))
+	defer collector.Close()
+
+	reporter := NewReporter(key, nil, testContract, collector.URL)
+	if rErr := reporter.Report(1, "10.0.0.1"); rErr != nil {
+		t.Fatal(rErr)
+	}
+	if received == nil {
+		t.Fatal("collector received nothing")
+	}
+	if node, vErr := received.Verify(testContract, 60); vErr != nil || node != reporter.Address() {
+		t.Fatalf("collector received %+v: %v", received, vErr)
+	}
+	last, succeeded, failed := reporter.LastReport()
+	if last == nil || last.Response != "0xhash" || last.State != 1 || succeeded != 1 || failed != 0 {
+		t.Fatalf("last report %+v, %d succeeded, %d failed", last, succeeded, failed)
+	}
+}
+
+func TestCollectHandler(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	//未连接合约，验证通过的心跳在提交时失败
+	reporter := NewReporter(key, nil, testContract, "")
+	handler := reporter.CollectHandler(60)
+	post := func(attestation *Attestation) int {
+		data, _ := json.Marshal(attestation)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/heartbeat", bytes.NewReader(data)))
+		return w.Code
+	}
+
+	node, _ := crypto.GenerateKey()
+	attestation, _ := NewAttestation(node, testContract, 1, "10.0.0.1")
+	if code := post(attestation); code != http.StatusBadGateway {
+		t.Errorf("valid heartbeat: status %d, want %d", code, http.StatusBadGateway)
+	}
+
+	forged := *attestation
+	forged.State = 0
+	if code := post(&forged); code != http.StatusBadRequest {
+		t.Errorf("forged heartbeat: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	stale := *attestation
+	stale.Timestamp = time.Now().Unix() - 3600
+	if code := post(&stale); code != http.StatusBadRequest {
+		t.Errorf("stale heartbeat: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/heartbeat", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -2,17 +2,24 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"net/http"
 	"os"
+	"stormchaser/account"
 	"stormchaser/config"
+	"stormchaser/contract"
+	"stormchaser/heartbeat"
 	"stormchaser/monitorHCDN"
-	"stormchaser/netWork"
+	"stormchaser/nodeManager"
 	"stormchaser/publicFuncHandler"
 	"stormchaser/singleLog"
+	"stormchaser/status"
+	"stormchaser/updater"
+	"strings"
 	"time"
 
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/fstclient"
 	"github.com/kardianos/service"
 )
 
@@ -29,34 +36,118 @@ func (p *program) run() {
 	for {
 		select {
 		case <-timer.C:
-			netWork.CheckSearchNodeNetRequest()
+			reportHeartbeat()
 		}
 		timer.Reset(time.Second * 3 * 60 * 60)
 	}
 }
 
+//上报签名心跳
+func reportHeartbeat() {
+
+	state := uint8(0)
+	if monitorHCDN.GetSearchMiningState() == "1" {
+		state = 1
+	}
+	ip := strings.TrimSpace(publicFuncHandler.GetExternal())
+	if rErr := reporter.Report(state, ip); rErr != nil {
+		singleLog.GetInstance().Error("Heartbeat report fail", rErr)
+		return
+	}
+	singleLog.GetInstance().Info("Heartbeat reported", reporter.Address().Hex(), state, ip, "score", monitorHCDN.GetScore())
+}
+
 func (p *program) Stop(s service.Service) error {
 	return nil
 }
 
 var searchId string
 
+//收集服务接受的心跳时间偏差（秒），与合约 HEARTBEAT_MAX_SKEW 一致
+const heartbeatMaxSkew = 300
+
+var reporter *heartbeat.Reporter
+var manager *nodeManager.Manager
+
 func main() {
 
-	var path string
+	var path, keystoreDir, keystoreFile, importFile, passwordFile, chainRpc, managerContract, collector, beneficiary string
+	var listen, statusListen, collectListen, releaseUrl, releaseKeys string
+	var updateInterval int
+	var stakingCount int64
 	flag.StringVar(&path, "path", "", "检索矿工缓存目录")
+	flag.StringVar(&keystoreDir, "keystore-dir", "", "storm 账户 keystore 目录，默认为当前目录下的 keystore")
+	flag.StringVar(&keystoreFile, "keystore", "", "storm 账户 keystore 文件，指定时不使用 keystore 目录")
+	flag.StringVar(&importFile, "import-key", "", "导入十六进制私钥文件到 keystore 目录")
+	flag.StringVar(&passwordFile, "password", "", "keystore 密码文件")
+	flag.StringVar(&chainRpc, "chain-rpc", "http://127.0.0.1:8545", "storm 节点 rpc 地址")
+	flag.StringVar(&managerContract, "manager-contract", "", "FileStormManager 合约地址")
+	flag.StringVar(&collector, "collector", "", "心跳收集服务地址，为空时直接发送交易到合约")
+	flag.StringVar(&collectListen, "collect-listen", "", "心跳收集服务监听地址，为空时不提供收集服务，节点账户必须是合约管理员")
+	flag.StringVar(&beneficiary, "beneficiary", "", "收益地址，默认为节点账户")
+	flag.Int64Var(&stakingCount, "staking-count", 1, "注册时质押的份数")
+	flag.StringVar(&listen, "listen", "", "检索矿工接口监听地址，默认为配置文件中的 ListenAddress 或 :52530")
+	flag.StringVar(&releaseUrl, "release-url", "https://file.filestorm.info/management", "happ 发布地址，包含签名的 manifest.json")
+	flag.StringVar(&releaseKeys, "release-key", "", "发布清单签名账户地址，多个用逗号分隔，默认为配置文件中的 ReleaseKey")
+	flag.IntVar(&updateInterval, "update-interval", 6, "检查 happ 新版本的间隔（小时），0 为不检查")
+	flag.StringVar(&statusListen, "status-listen", "", "本地状态接口（/status、/metrics）监听地址，默认为配置文件中的 StatusListenAddress 或 127.0.0.1:52531")
 	flag.Parse()
 	if path == "" {
 		path = publicFuncHandler.GetPath() //当前目录
 	}
+	if keystoreDir == "" {
+		keystoreDir = publicFuncHandler.GetPath() + "/keystore"
+	}
 
 	searchId = config.LoadConfig()
 
+	key, kErr := account.Load(keystoreDir, keystoreFile, importFile, passwordFile)
+	if kErr != nil {
+		singleLog.GetInstance().Error("Storm account load fail! --password is required", kErr)
+		os.Exit(0)
+	}
+	if !common.IsHexAddress(managerContract) {
+		singleLog.GetInstance().Error("Invalid --manager-contract", managerContract)
+		os.Exit(0)
+	}
+	client, dErr := fstclient.Dial(chainRpc)
+	if dErr != nil {
+		singleLog.GetInstance().Error("Storm node connect fail!", dErr)
+		os.Exit(0)
+	}
+	contractAddress := common.HexToAddress(managerContract)
+	fileStormManager, cErr := contract.NewFileStormManager(contractAddress, client)
+	if cErr != nil {
+		singleLog.GetInstance().Error("FileStormManager contract bind fail!", cErr)
+		os.Exit(0)
+	}
+
+	//注册为检索矿工，并定时检查节点状态、领取奖励
+	manager = nodeManager.NewManager(key, client, fileStormManager)
+	beneficiaryAddress := manager.Address()
+	if beneficiary != "" {
+		if !common.IsHexAddress(beneficiary) {
+			singleLog.GetInstance().Error("Invalid --beneficiary", beneficiary)
+			os.Exit(0)
+		}
+		beneficiaryAddress = common.HexToAddress(beneficiary)
+	}
+	if rErr := manager.Register(config.UniqueCode, beneficiaryAddress, stakingCount); rErr != nil {
+		singleLog.GetInstance().Error("Node register fail!", rErr)
+		os.Exit(0)
+	}
+	go manager.Run(time.Second * 10 * 60)
+
+	reporter = heartbeat.NewReporter(key, fileStormManager, contractAddress, collector)
+
 	go publicFuncHandler.CuttingLogFile("storm.out") //日志切割
-	go monitorHCDN.DetectionPing()                   //检测网络（ping）
-	go monitorHCDN.DetectionHcdnState(path)          //检测HCDN状态
+	monitorHCDN.Start(path)                          //网络、HCDN状态探测
 
-	hardDiskDetection(path) //硬盘检测
+	happUpdater := newUpdater(releaseUrl, releaseKeys, path)
+	hardDiskDetection(path, happUpdater) //硬盘检测
+	if updateInterval > 0 {
+		go happUpdater.Run(time.Hour * time.Duration(updateInterval))
+	}
 
 	svcConfig := &service.Config{
 		Name:        "stormchaser",
@@ -72,18 +163,71 @@ func main() {
 	}
 	go s.Run()
 
+	//本地状态接口
+	statusServer := status.NewServer(path, reporter, manager)
+	statusListen = listenAddress(statusListen, "StatusListenAddress", "127.0.0.1:52531")
+	go func() {
+		if lErr := http.ListenAndServe(statusListen, statusServer.Handler()); lErr != nil {
+			singleLog.GetInstance().Error("Status server start fail", lErr)
+		}
+	}()
+
+	//心跳收集服务，其他节点的 --collector 设置为 http://<collect-listen>/heartbeat
+	if collectListen != "" {
+		collectMux := http.NewServeMux()
+		collectMux.HandleFunc("/heartbeat", reporter.CollectHandler(heartbeatMaxSkew))
+		go func() {
+			if lErr := http.ListenAndServe(collectListen, collectMux); lErr != nil {
+				singleLog.GetInstance().Error("Heartbeat collector start fail", lErr)
+			}
+		}()
+	}
+
 	http.HandleFunc("/search/getSearchMiningIdAndIp", getSearchMiningIdAndIpHandler)
-	http.ListenAndServe(":52530", nil)
+	if lErr := http.ListenAndServe(listenAddress(listen, "ListenAddress", ":52530"), nil); lErr != nil {
+		singleLog.GetInstance().Error("Server start fail", lErr)
+		os.Exit(0)
+	}
+}
+
+//监听地址：命令行参数优先，其次为配置文件，最后为默认值
+func listenAddress(flagValue, configKey, defaultValue string) string {
+
+	if flagValue != "" {
+		return flagValue
+	}
+	if value, gErr := config.GetConfig(configKey); gErr == nil && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+//happ 更新，发布清单必须由 releaseKeys 之一签名
+func newUpdater(releaseUrl, releaseKeys, path string) *updater.Updater {
+
+	if releaseKeys == "" {
+		releaseKeys, _ = config.GetConfig("ReleaseKey")
+	}
+	var keys []common.Address
+	for _, key := range strings.Split(releaseKeys, ",") {
+		key = strings.TrimSpace(key)
+		if !common.IsHexAddress(key) {
+			singleLog.GetInstance().Error("Invalid or missing release key, set --release-key or ReleaseKey", key)
+			os.Exit(0)
+		}
+		keys = append(keys, common.HexToAddress(key))
+	}
+	return updater.New(releaseUrl, path, keys)
 }
 
 //硬盘检测
-func hardDiskDetection(path string) {
+func hardDiskDetection(path string, happUpdater *updater.Updater) {
 
 	_, osErr := os.Stat(path + "/hdata")
 	if osErr != nil && os.IsNotExist(osErr) {
 
-		//下载happ安装包
-		dErr := downloadHapp("https://file.filestorm.info/management", path)
+		//下载并验证happ安装包
+		dErr := happUpdater.Update(true)
 		if dErr != nil {
 			singleLog.GetInstance().Error("File download operating fail", dErr)
 			os.Exit(0)
@@ -103,25 +247,6 @@ func hardDiskDetection(path string) {
 	}
 }
 
-func downloadHapp(urlStr, pathStr string) (dErr error) {
-
-	if publicFuncHandler.Platform == "linux" {
-		filePath := pathStr + "/happ"
-		_, dErr = netWork.DownloadFile(urlStr+"/linux/happ", filePath)
-		if dErr == nil {
-			_, dErr = publicFuncHandler.AddExecutePermission(filePath)
-		}
-	} else if publicFuncHandler.Platform == "windows" {
-		_, dErr = netWork.DownloadFile(urlStr+"/windows/happ.exe", pathStr+"/happ.exe")
-		if dErr == nil {
-			_, dErr = netWork.DownloadFile(urlStr+"/windows/happnet.dll", pathStr+"/happnet.dll")
-		}
-	} else {
-		dErr = errors.New("Paltform error")
-	}
-	return dErr
-}
-
 func getSearchMiningIdAndIpHandler(w http.ResponseWriter, req *http.Request) {
 
 	w.Write([]byte(searchId))
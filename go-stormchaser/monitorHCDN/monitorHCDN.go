@@ -2,101 +2,147 @@
 package monitorHCDN
 
 import (
+	"os"
+	"sort"
+	"stormchaser/config"
+	"stormchaser/probe"
 	"stormchaser/publicFuncHandler"
 	"stormchaser/singleLog"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 )
 
-var countMutex sync.Mutex
+const (
+	probeSectionPrefix string = "probe." //配置文件中探测段的前缀，如 [probe.pdata]
+)
+
+//配置文件中没有探测段时使用的默认探测：爱奇艺链接能 ping 通，happ 在运行
+var defaultProbes = map[string]map[string]string{
+	"pdata": {"type": "icmp", "target": "pdata.video.qiyi.com", "interval": "600", "threshold": "0.75"},
+	"stat":  {"type": "icmp", "target": "stat.hcdn.qiyi.com", "interval": "600", "threshold": "0.75"},
+	"happ":  {"type": "process", "target": "happ", "interval": "300", "threshold": "0.4", "restart": "true"},
+}
 
 var (
-	pingNum      int = 0 //ping成功次数
-	detectionNum int = 0 //检测HCDN状态成功次数
+	probes   []*probe.Probe
+	minScore float64 = 1 //得分不低于 minScore 时检索矿工状态为正常
+
+	restartMutex  sync.Mutex
+	restarts      = make(map[string]*ProcessState) //由探测管理的进程
+	restartPaused bool                             //更新程序期间不重新启动进程
 )
 
-//检测爱奇艺链接是否能ping通
-func DetectionPing() {
-
-	var aqiyiArray []string = []string{"pdata.video.qiyi.com", "stat.hcdn.qiyi.com"}
-
-	timer := time.NewTimer(time.Second * 10 * 60)
-	for {
-		select {
-		case <-timer.C:
-			var passNum int = 0
-			for index, value := range aqiyiArray {
-				dataBytes, err := publicFuncHandler.ExecPING(value)
-				if err != nil {
-					singleLog.GetInstance().Error("ping错误：", index, err)
-					continue
-				}
-				if strings.Count(string(dataBytes), "timeout") <= 2 && strings.Count(string(dataBytes), "超时") <= 2 {
-					//通过
-					passNum += 1
-				} else {
-					//未通过
-					singleLog.GetInstance().Error("ping超时：", index)
-				}
-			}
-			if passNum == len(aqiyiArray) {
-				//正常
-				countMutex.Lock()
-				pingNum += 1
-				countMutex.Unlock()
-			} else {
-				//异常
-				singleLog.GetInstance().Error("网络异常（网络波动较大），请检查网络是否链接！")
-			}
+//由探测管理（失败时重新启动）的进程状态
+type ProcessState struct {
+	Name        string    `json:"name"`
+	Running     bool      `json:"running"`
+	Restarts    uint64    `json:"restarts"`
+	LastRestart time.Time `json:"lastRestart,omitempty"`
+}
+
+/*
+ *加载并启动探测，path 为 happ 所在目录
+ *配置文件 DEFAULT 段的 ProbeMinScore 设置正常状态的最低得分（0 - 1，默认 1，即全部探测正常）
+ */
+func Start(path string) {
+
+	sections := config.GetSections(probeSectionPrefix)
+	if len(sections) == 0 {
+		sections = defaultProbes
+	}
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c, pErr := probe.ParseConfig(name, sections[name])
+		if pErr != nil {
+			singleLog.GetInstance().Error("Probe configuration error", pErr)
+			os.Exit(0)
+		}
+		probes = append(probes, probe.New(c))
+		if c.Type == probe.TypeProcess && c.Restart {
+			restarts[c.Target] = &ProcessState{Name: c.Target}
 		}
-		timer.Reset(time.Second * 10 * 60)
 	}
-}
 
-//检测HCDN状态
-func DetectionHcdnState(path string) {
-
-	str := "HAPP"
-	lStr := strings.ToLower(str)
-
-	timer := time.NewTimer(time.Second * 5 * 60)
-	for {
-		select {
-		case <-timer.C:
-			dataBytes, err := publicFuncHandler.ExecPsAndGrep(lStr)
-			if err != nil {
-				publicFuncHandler.ExecStartUpHapp(lStr, path)
-				singleLog.GetInstance().Error("检测状态错误：", err)
-				continue
-			}
-			strs := strings.Split(string(dataBytes), lStr)
-			if len(strs) >= 2 {
-				//运行中
-				countMutex.Lock()
-				detectionNum += 1
-				countMutex.Unlock()
-			} else {
-				//已停止运行，需启动happ
-				publicFuncHandler.ExecStartUpHapp(lStr, path)
+	if value, gErr := config.GetConfig("ProbeMinScore"); gErr == nil && value != "" {
+		score, pErr := strconv.ParseFloat(value, 64)
+		if pErr != nil || score < 0 || score > 1 {
+			singleLog.GetInstance().Error("Invalid ProbeMinScore", value)
+			os.Exit(0)
+		}
+		minScore = score
+	}
+
+	onFail := func(p *probe.Probe, err error) {
+
+		singleLog.GetInstance().Error("Probe fail:", p.Config.Name, err)
+		if p.Config.Type == probe.TypeProcess && p.Config.Restart {
+			//已停止运行，需重新启动
+			restartMutex.Lock()
+			if restartPaused {
+				restartMutex.Unlock()
+				return
 			}
+			restarts[p.Config.Target].Restarts++
+			restarts[p.Config.Target].LastRestart = time.Now()
+			restartMutex.Unlock()
+			go publicFuncHandler.ExecStartUpHapp(p.Config.Target, path)
 		}
-		timer.Reset(time.Second * 5 * 60)
+	}
+	for _, p := range probes {
+		go p.Run(onFail)
 	}
 }
 
-func GetSearchMiningState() string {
+//各探测在 window 内的统计
+func GetProbeStatus() []probe.Status {
 
-	if pingNum >= 14 && detectionNum >= 14 {
-		return "1"
+	status := make([]probe.Status, 0, len(probes))
+	for _, p := range probes {
+		status = append(status, p.Status())
 	}
-	return "0"
+	return status
+}
+
+//暂停或恢复探测失败时重新启动进程
+func SetRestartPaused(paused bool) {
+
+	restartMutex.Lock()
+	defer restartMutex.Unlock()
+	restartPaused = paused
 }
 
-func ClearCount() {
+//由探测管理的进程状态
+func GetProcessState() []ProcessState {
+
+	restartMutex.Lock()
+	states := make([]ProcessState, 0, len(restarts))
+	for _, state := range restarts {
+		states = append(states, *state)
+	}
+	restartMutex.Unlock()
 
-	countMutex.Lock()
-	defer countMutex.Unlock()
-	pingNum = 0
-	detectionNum = 0
+	for i := range states {
+		states[i].Running, _ = probe.ProcessRunning(states[i].Name)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+//检索矿工得分
+func GetScore() float64 {
+
+	return probe.Score(probes)
+}
+
+func GetSearchMiningState() string {
+
+	if len(probes) > 0 && GetScore() >= minScore {
+		return "1"
+	}
+	return "0"
 }
@@ -3,15 +3,20 @@ package netWork
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 )
 
 const (
 	bufSize int = 1024 * 1024 //buf大小
 )
 
+var ErrFileTooLarge = errors.New("file larger than the maximum size")
+
 //下载文件，返回文件长度
 func DownloadFile(fileUrl, filePath string) (int64, error) {
 
@@ -39,3 +44,63 @@ func DownloadFile(fileUrl, filePath string) (int64, error) {
 
 	return written, writer.Flush() //刷新后内容写入
 }
+
+/*
+ *断点续传下载文件，filePath 已存在时从文件末尾继续下载
+ *服务端不支持 Range 时重新下载，返回文件总长度
+ *文件超过 maxSize 时立即停止下载并返回 ErrFileTooLarge
+ */
+func DownloadFileResume(fileUrl, filePath string, maxSize int64) (int64, error) {
+
+	var offset int64
+	if info, sErr := os.Stat(filePath); sErr == nil {
+		offset = info.Size()
+	}
+
+	req, nErr := http.NewRequest("GET", fileUrl, nil)
+	if nErr != nil {
+		return 0, nErr
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	res, gErr := http.DefaultClient.Do(req)
+	if gErr != nil {
+		return 0, gErr
+	}
+	defer res.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		flag |= os.O_APPEND
+	case http.StatusOK:
+		flag |= os.O_TRUNC //不支持 Range，重新下载
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		return offset, nil //已下载完成
+	default:
+		return 0, fmt.Errorf("download %s fail: %s", fileUrl, res.Status)
+	}
+
+	if offset > maxSize {
+		return offset, ErrFileTooLarge
+	}
+
+	file, oErr := os.OpenFile(filePath, flag, 0644)
+	if oErr != nil {
+		return 0, oErr
+	}
+	defer file.Close()
+	writer := bufio.NewWriterSize(file, bufSize)
+
+	//最多多读 1 字节，用于判断是否超过 maxSize
+	written, coErr := io.Copy(writer, io.LimitReader(res.Body, maxSize-offset+1))
+	if fErr := writer.Flush(); coErr == nil {
+		coErr = fErr
+	}
+	if coErr == nil && offset+written > maxSize {
+		coErr = ErrFileTooLarge
+	}
+	return offset + written, coErr
+}
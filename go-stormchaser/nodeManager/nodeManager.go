@@ -0,0 +1,259 @@
+// nodeManager project nodeManager.go
+package nodeManager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"stormchaser/contract"
+	"stormchaser/singleLog"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/accounts/abi/bind"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+	"github.com/filestorm/go-filestorm/fstclient"
+)
+
+//合约 RegisterType，检索矿工为 retrieval
+const (
+	RegisterTypeRetrieval int64 = 2
+)
+
+//合约 NodeStatus
+const (
+	StatusRegistered uint64 = 0
+	StatusWorking    uint64 = 1
+	StatusError      uint64 = 2
+	StatusEnded      uint64 = 3
+)
+
+var statusNames = map[uint64]string{
+	StatusRegistered: "registered",
+	StatusWorking:    "working",
+	StatusError:      "error",
+	StatusEnded:      "ended",
+}
+
+//等待交易打包的最长时间
+var txTimeout = time.Second * 5 * 60
+
+//合约中记录的节点
+type Node struct {
+	NodeId          string         `json:"nodeId"`
+	NodeAddress     common.Address `json:"nodeAddress"`
+	Beneficiary     common.Address `json:"beneficiary"`
+	StakingAmount   *big.Int       `json:"stakingAmount"`
+	DisburseAmount  *big.Int       `json:"disburseAmount"`
+	RegisterBlock   uint64         `json:"registerBlock"`
+	NodeStatus      uint64         `json:"nodeStatus"`
+	NextRewardBlock uint64         `json:"nextRewardBlock"`
+	DisburseCount   uint64         `json:"disburseCount"`
+	DisbursedTotal  *big.Int       `json:"disbursedTotal"`
+}
+
+//是否已注册
+func (n *Node) Registered() bool {
+
+	return n.NodeAddress != (common.Address{})
+}
+
+//状态名称
+func StatusName(status uint64) string {
+
+	if name, ok := statusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprint(status)
+}
+
+//Manager 使用的链接口，*fstclient.Client 实现了该接口
+type Backend interface {
+	bind.DeployBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+var _ Backend = (*fstclient.Client)(nil)
+
+//检索矿工在 FileStormManager 合约中的注册、状态跟踪和领取奖励
+type Manager struct {
+	key     *ecdsa.PrivateKey
+	client  Backend
+	manager *contract.FileStormManager
+
+	mutex sync.Mutex
+	node  *Node //最近一次读取的节点信息
+}
+
+func NewManager(key *ecdsa.PrivateKey, client Backend, manager *contract.FileStormManager) *Manager {
+
+	return &Manager{key: key, client: client, manager: manager}
+}
+
+//节点地址
+func (m *Manager) Address() common.Address {
+
+	return crypto.PubkeyToAddress(m.key.PublicKey)
+}
+
+//最近一次读取的节点信息，未读取时返回 nil
+func (m *Manager) Node() *Node {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.node
+}
+
+//从合约读取节点信息
+func (m *Manager) GetNode() (*Node, error) {
+
+	info, cErr := m.manager.NodeMapping(nil, m.Address())
+	if cErr != nil {
+		return nil, cErr
+	}
+	node := &Node{
+		NodeId:          info.NodeId,
+		NodeAddress:     info.NodeAddress,
+		Beneficiary:     info.Beneficiary,
+		StakingAmount:   info.StakingAmount,
+		DisburseAmount:  info.DisburseAmount,
+		RegisterBlock:   info.RegisterBlock.Uint64(),
+		NodeStatus:      info.NodeStatus.Uint64(),
+		NextRewardBlock: info.NextRewardBlock.Uint64(),
+		DisburseCount:   info.DisburseCount.Uint64(),
+		DisbursedTotal:  info.DisbursedTotal,
+	}
+
+	m.mutex.Lock()
+	m.node = node
+	m.mutex.Unlock()
+	return node, nil
+}
+
+//等待交易打包并检查执行结果
+func (m *Manager) waitMined(tx *types.Transaction) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+	receipt, wErr := bind.WaitMined(ctx, m.client, tx)
+	if wErr != nil {
+		return wErr
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s failed", tx.Hash().Hex())
+	}
+	return nil
+}
+
+//注册为检索矿工并质押 stakingCount 份，已注册时不做任何操作
+func (m *Manager) Register(nodeId string, beneficiary common.Address, stakingCount int64) error {
+
+	node, gErr := m.GetNode()
+	if gErr != nil {
+		return gErr
+	}
+	if node.Registered() {
+		singleLog.GetInstance().Info("Node already registered", m.Address().Hex(), StatusName(node.NodeStatus))
+		return nil
+	}
+
+	stakingAmount, sErr := m.manager.StakingAmount(nil)
+	if sErr != nil {
+		return sErr
+	}
+	value := new(big.Int).Mul(stakingAmount, big.NewInt(stakingCount))
+	balance, bErr := m.client.BalanceAt(context.Background(), m.Address(), nil)
+	if bErr != nil {
+		return bErr
+	}
+	if balance.Cmp(value) < 0 {
+		return fmt.Errorf("balance %s of %s is lower than the staking amount %s", balance, m.Address().Hex(), value)
+	}
+
+	opts := bind.NewKeyedTransactor(m.key)
+	opts.Value = value
+	tx, tErr := m.manager.AddNode(opts, nodeId, m.Address(), beneficiary, big.NewInt(stakingCount), big.NewInt(RegisterTypeRetrieval))
+	if tErr != nil {
+		return tErr
+	}
+	singleLog.GetInstance().Info("Node register transaction sent", tx.Hash().Hex())
+	if wErr := m.waitMined(tx); wErr != nil {
+		return wErr
+	}
+
+	node, gErr = m.GetNode()
+	if gErr != nil {
+		return gErr
+	}
+	if !node.Registered() {
+		return errors.New("node not found in contract after registration")
+	}
+	singleLog.GetInstance().Info("Node registered", m.Address().Hex(), "staking", value)
+	return nil
+}
+
+/*
+ *检查节点状态，可以领取时调用 disburse：
+ *状态为 working，到达 nextRewardBlock，且最近一个周期内有正常的心跳（否则本期奖励为 0）
+ */
+func (m *Manager) Check() error {
+
+	last := m.Node()
+	node, gErr := m.GetNode()
+	if gErr != nil {
+		return gErr
+	}
+	if last != nil && last.NodeStatus != node.NodeStatus {
+		singleLog.GetInstance().Info("Node status changed", StatusName(last.NodeStatus), "->", StatusName(node.NodeStatus))
+	}
+	if !node.Registered() || node.NodeStatus != StatusWorking {
+		return nil
+	}
+
+	header, hErr := m.client.HeaderByNumber(context.Background(), nil)
+	if hErr != nil {
+		return hErr
+	}
+	if header.Number.Uint64() < node.NextRewardBlock {
+		return nil
+	}
+	alive, aErr := m.manager.IsAlive(nil, m.Address())
+	if aErr != nil {
+		return aErr
+	}
+	if !alive {
+		singleLog.GetInstance().Warning("Reward available but no working heartbeat in the last epoch, disburse postponed")
+		return nil
+	}
+
+	tx, tErr := m.manager.Disburse(bind.NewKeyedTransactor(m.key), m.Address())
+	if tErr != nil {
+		return tErr
+	}
+	singleLog.GetInstance().Info("Disburse transaction sent", tx.Hash().Hex())
+	if wErr := m.waitMined(tx); wErr != nil {
+		return wErr
+	}
+	_, gErr = m.GetNode()
+	return gErr
+}
+
+//定时检查节点状态
+func (m *Manager) Run(interval time.Duration) {
+
+	timer := time.NewTimer(interval)
+	for {
+		select {
+		case <-timer.C:
+			if cErr := m.Check(); cErr != nil {
+				singleLog.GetInstance().Error("Node check fail", cErr)
+			}
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,252 @@
+package nodeManager
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"stormchaser/contract"
+	"strings"
+	"testing"
+	"time"
+
+	filestorm "github.com/filestorm/go-filestorm"
+	"github.com/filestorm/go-filestorm/accounts/abi"
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/core/types"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+//测试用的链：按 FileStormManager ABI 应答合约调用，记录发送的交易
+type testChain struct {
+	abi           abi.ABI
+	head          int64
+	balance       *big.Int
+	stakingAmount *big.Int
+	alive         bool
+	failTx        bool
+
+	nodeId          string
+	nodeAddress     common.Address
+	beneficiary     common.Address
+	nodeStatus      uint64
+	nextRewardBlock int64
+	disburseCount   int64
+
+	sent []string
+}
+
+func newTestChain(t *testing.T) *testChain {
+
+	parsed, pErr := abi.JSON(strings.NewReader(contract.FileStormManagerABI))
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	return &testChain{abi: parsed, head: 100, balance: big.NewInt(1000), stakingAmount: big.NewInt(100)}
+}
+
+func (c *testChain) CallContract(ctx context.Context, call filestorm.CallMsg, blockNumber *big.Int) ([]byte, error) {
+
+	method, mErr := c.abi.MethodById(call.Data)
+	if mErr != nil {
+		return nil, mErr
+	}
+	switch method.Name {
+	case "nodeMapping":
+		zero := big.NewInt(0)
+		return method.Outputs.Pack(c.nodeId, c.nodeAddress, c.beneficiary, zero, zero, zero, big.NewInt(RegisterTypeRetrieval),
+			new(big.Int).SetUint64(c.nodeStatus), big.NewInt(c.nextRewardBlock), big.NewInt(c.disburseCount), zero)
+	case "staking_amount":
+		return method.Outputs.Pack(c.stakingAmount)
+	case "isAlive":
+		return method.Outputs.Pack(c.alive)
+	}
+	return nil, errors.New("unexpected call " + method.Name)
+}
+
+func (c *testChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+
+	method, mErr := c.abi.MethodById(tx.Data())
+	if mErr != nil {
+		return mErr
+	}
+	c.sent = append(c.sent, method.Name)
+	if c.failTx {
+		return nil
+	}
+	args, uErr := method.Inputs.UnpackValues(tx.Data()[4:])
+	if uErr != nil {
+		return uErr
+	}
+	switch method.Name {
+	case "addNode":
+		c.nodeId = args[0].(string)
+		c.nodeAddress = args[1].(common.Address)
+		c.beneficiary = args[2].(common.Address)
+		c.nodeStatus = StatusRegistered
+		c.nextRewardBlock = c.head + 10
+	case "disburse":
+		c.disburseCount++
+		c.nextRewardBlock = c.head + 10
+	}
+	return nil
+}
+
+func (c *testChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+
+	if c.failTx {
+		return &types.Receipt{Status: types.ReceiptStatusFailed}, nil
+	}
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful}, nil
+}
+
+func (c *testChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+
+	return c.balance, nil
+}
+
+func (c *testChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+
+	return &types.Header{Number: big.NewInt(c.head)}, nil
+}
+
+func (c *testChain) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+
+	return []byte{1}, nil
+}
+
+func (c *testChain) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+
+	return []byte{1}, nil
+}
+
+func (c *testChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+
+	return uint64(len(c.sent)), nil
+}
+
+func (c *testChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+
+	return big.NewInt(1), nil
+}
+
+func (c *testChain) EstimateGas(ctx context.Context, call filestorm.CallMsg) (uint64, error) {
+
+	return 100000, nil
+}
+
+func (c *testChain) FilterLogs(ctx context.Context, query filestorm.FilterQuery) ([]types.Log, error) {
+
+	return nil, nil
+}
+
+func (c *testChain) SubscribeFilterLogs(ctx context.Context, query filestorm.FilterQuery, ch chan<- types.Log) (filestorm.Subscription, error) {
+
+	return nil, errors.New("not supported")
+}
+
+func newTestManager(t *testing.T) (*Manager, *testChain) {
+
+	chain := newTestChain(t)
+	key, _ := crypto.GenerateKey()
+	fileStormManager, cErr := contract.NewFileStormManager(common.HexToAddress("0x1000"), chain)
+	if cErr != nil {
+		t.Fatal(cErr)
+	}
+	return NewManager(key, chain, fileStormManager), chain
+}
+
+func TestRegister(t *testing.T) {
+
+	m, chain := newTestManager(t)
+	beneficiary := common.HexToAddress("0x2000")
+
+	if rErr := m.Register("node1", beneficiary, 2); rErr != nil {
+		t.Fatal(rErr)
+	}
+	if len(chain.sent) != 1 || chain.sent[0] != "addNode" {
+		t.Fatalf("sent transactions %v, want [addNode]", chain.sent)
+	}
+	node := m.Node()
+	if node == nil || !node.Registered() || node.NodeId != "node1" || node.NodeAddress != m.Address() || node.Beneficiary != beneficiary {
+		t.Fatalf("node not registered: %+v", node)
+	}
+
+	//已注册时不再发送交易
+	if rErr := m.Register("node1", beneficiary, 2); rErr != nil {
+		t.Fatal(rErr)
+	}
+	if len(chain.sent) != 1 {
+		t.Fatalf("sent transactions %v after registering again", chain.sent)
+	}
+}
+
+func TestRegisterLowBalance(t *testing.T) {
+
+	m, chain := newTestManager(t)
+	chain.balance = big.NewInt(199)
+
+	if rErr := m.Register("node1", m.Address(), 2); rErr == nil {
+		t.Fatal("registered with a balance lower than the staking amount")
+	}
+	if len(chain.sent) != 0 {
+		t.Fatalf("sent transactions %v", chain.sent)
+	}
+}
+
+func TestRegisterFailedTransaction(t *testing.T) {
+
+	m, chain := newTestManager(t)
+	chain.failTx = true
+	txTimeout = time.Second
+	defer func() { txTimeout = time.Second * 5 * 60 }()
+
+	if rErr := m.Register("node1", m.Address(), 1); rErr == nil {
+		t.Fatal("failed registration transaction accepted")
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	m, chain := newTestManager(t)
+	if rErr := m.Register("node1", m.Address(), 1); rErr != nil {
+		t.Fatal(rErr)
+	}
+	chain.sent = nil
+
+	tests := []struct {
+		name     string
+		status   uint64
+		head     int64
+		alive    bool
+		disburse bool
+	}{
+		{"not working", StatusRegistered, 200, true, false},
+		{"reward block not reached", StatusWorking, 105, true, false},
+		{"no heartbeat", StatusWorking, 200, false, false},
+		{"reward available", StatusWorking, 200, true, true},
+	}
+	for _, tt := range tests {
+		chain.sent = nil
+		chain.nodeStatus, chain.head, chain.alive = tt.status, tt.head, tt.alive
+		chain.nextRewardBlock = 110
+		if cErr := m.Check(); cErr != nil {
+			t.Fatalf("%s: %v", tt.name, cErr)
+		}
+		if disbursed := len(chain.sent) == 1 && chain.sent[0] == "disburse"; disbursed != tt.disburse {
+			t.Errorf("%s: sent transactions %v, want disburse %v", tt.name, chain.sent, tt.disburse)
+		}
+	}
+	if m.Node().DisburseCount != 1 {
+		t.Errorf("disburse count %d, want 1", m.Node().DisburseCount)
+	}
+}
+
+func TestStatusName(t *testing.T) {
+
+	if name := StatusName(StatusWorking); name != "working" {
+		t.Errorf("StatusName(StatusWorking) = %s", name)
+	}
+	if name := StatusName(9); name != "9" {
+		t.Errorf("StatusName(9) = %s", name)
+	}
+}
@@ -0,0 +1,72 @@
+// probe project icmp.go
+package probe
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+var ErrNoReply = errors.New("no echo reply")
+
+var pingSeq uint32
+
+/*
+ *ICMP echo，不依赖系统 ping 命令的输出格式：
+ *优先使用无需 root 的 udp icmp socket（linux 需 net.ipv4.ping_group_range 允许），失败时使用 raw socket
+ */
+func Ping(host string, timeout time.Duration) error {
+
+	ipAddr, rErr := net.ResolveIPAddr("ip4", host)
+	if rErr != nil {
+		return rErr
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: ipAddr.IP}
+	conn, lErr := icmp.ListenPacket("udp4", "0.0.0.0")
+	if lErr != nil {
+		dst = ipAddr
+		conn, lErr = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+		if lErr != nil {
+			return lErr
+		}
+	}
+	defer conn.Close()
+
+	id, seq := os.Getpid()&0xffff, int(atomic.AddUint32(&pingSeq, 1)&0xffff)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("stormchaser")},
+	}
+	data, mErr := msg.Marshal(nil)
+	if mErr != nil {
+		return mErr
+	}
+	if _, wErr := conn.WriteTo(data, dst); wErr != nil {
+		return wErr
+	}
+
+	deadline := time.Now().Add(timeout)
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, _, rErr := conn.ReadFrom(buf)
+		if rErr != nil {
+			return rErr
+		}
+		reply, pErr := icmp.ParseMessage(1, buf[:n]) //1 - ICMPv4
+		if pErr != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		//udp socket 的 id 由内核改写，只比较 seq
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq {
+			return nil
+		}
+	}
+	return ErrNoReply
+}
@@ -0,0 +1,290 @@
+// probe project probe.go
+package probe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//探测类型
+const (
+	TypeTCP     string = "tcp"
+	TypeHTTP    string = "http"
+	TypeICMP    string = "icmp"
+	TypeProcess string = "process"
+)
+
+/*
+ *探测配置，对应配置文件中的 [probe.名称] 段：
+ *type - tcp / http / icmp / process
+ *target - tcp 为 host:port，http 为 url，icmp 为主机名，process 为进程名
+ *interval、timeout、window - 秒
+ *threshold - window 内成功次数的最低比例
+ *weight - 计算得分时的权重
+ *expect - http 期望的状态码，默认 2xx/3xx
+ *restart - process 探测失败时重新启动该进程
+ */
+type Config struct {
+	Name      string
+	Type      string
+	Target    string
+	Interval  time.Duration
+	Timeout   time.Duration
+	Window    time.Duration
+	Threshold float64
+	Weight    float64
+	Expect    int
+	Restart   bool
+}
+
+//解析配置文件中一个探测段
+func ParseConfig(name string, values map[string]string) (*Config, error) {
+
+	c := &Config{
+		Name:      name,
+		Type:      strings.ToLower(values["type"]),
+		Target:    values["target"],
+		Interval:  time.Second * 5 * 60,
+		Timeout:   time.Second * 5,
+		Window:    time.Second * 3 * 60 * 60,
+		Threshold: 0.75,
+		Weight:    1,
+	}
+	switch c.Type {
+	case TypeTCP, TypeHTTP, TypeICMP, TypeProcess:
+	default:
+		return nil, fmt.Errorf("probe %s: unknown type %q", name, values["type"])
+	}
+	if c.Target == "" {
+		return nil, fmt.Errorf("probe %s: target is required", name)
+	}
+
+	seconds := func(key string, d *time.Duration) error {
+
+		if v, ok := values[key]; ok {
+			n, pErr := strconv.Atoi(v)
+			if pErr != nil || n <= 0 {
+				return fmt.Errorf("probe %s: invalid %s %q", name, key, v)
+			}
+			*d = time.Duration(n) * time.Second
+		}
+		return nil
+	}
+	for key, d := range map[string]*time.Duration{"interval": &c.Interval, "timeout": &c.Timeout, "window": &c.Window} {
+		if sErr := seconds(key, d); sErr != nil {
+			return nil, sErr
+		}
+	}
+	if c.Window < c.Interval {
+		return nil, fmt.Errorf("probe %s: window is shorter than interval", name)
+	}
+
+	if v, ok := values["threshold"]; ok {
+		f, pErr := strconv.ParseFloat(v, 64)
+		if pErr != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("probe %s: invalid threshold %q", name, v)
+		}
+		c.Threshold = f
+	}
+	if v, ok := values["weight"]; ok {
+		f, pErr := strconv.ParseFloat(v, 64)
+		if pErr != nil || f < 0 {
+			return nil, fmt.Errorf("probe %s: invalid weight %q", name, v)
+		}
+		c.Weight = f
+	}
+	if v, ok := values["expect"]; ok {
+		n, pErr := strconv.Atoi(v)
+		if pErr != nil {
+			return nil, fmt.Errorf("probe %s: invalid expect %q", name, v)
+		}
+		c.Expect = n
+	}
+	if v, ok := values["restart"]; ok {
+		b, pErr := strconv.ParseBool(v)
+		if pErr != nil {
+			return nil, fmt.Errorf("probe %s: invalid restart %q", name, v)
+		}
+		c.Restart = b
+	}
+	return c, nil
+}
+
+//执行一次探测
+func Check(c *Config) error {
+
+	switch c.Type {
+	case TypeTCP:
+		conn, dErr := net.DialTimeout("tcp", c.Target, c.Timeout)
+		if dErr != nil {
+			return dErr
+		}
+		return conn.Close()
+	case TypeHTTP:
+		return checkHTTP(c)
+	case TypeICMP:
+		return Ping(c.Target, c.Timeout)
+	case TypeProcess:
+		running, pErr := ProcessRunning(c.Target)
+		if pErr != nil {
+			return pErr
+		}
+		if !running {
+			return fmt.Errorf("process %s is not running", c.Target)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown probe type %q", c.Type)
+}
+
+//进程名是否为 name：去掉路径和 .exe 后完全一致，不区分大小写，不按子串匹配
+func processNameMatches(processName, name string) bool {
+
+	processName = filepath.Base(strings.Replace(processName, "\\", "/", -1))
+	if strings.HasSuffix(strings.ToLower(processName), ".exe") {
+		processName = processName[:len(processName)-len(".exe")]
+	}
+	return processName != "" && strings.EqualFold(processName, name)
+}
+
+func checkHTTP(c *Config) error {
+
+	client := &http.Client{Timeout: c.Timeout}
+	resp, gErr := client.Get(c.Target)
+	if gErr != nil {
+		return gErr
+	}
+	resp.Body.Close()
+	if c.Expect != 0 && resp.StatusCode != c.Expect {
+		return fmt.Errorf("status %d, expected %d", resp.StatusCode, c.Expect)
+	}
+	if c.Expect == 0 && resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+//一次探测结果
+type result struct {
+	at time.Time
+	ok bool
+}
+
+//探测在 window 内的统计
+type Status struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Target    string    `json:"target"`
+	Samples   int       `json:"samples"`
+	Passed    int       `json:"passed"`
+	Ratio     float64   `json:"ratio"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"lastCheck"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+//一个探测及其 window 内的结果
+type Probe struct {
+	Config *Config
+
+	mutex     sync.Mutex
+	results   []result
+	lastCheck time.Time
+	lastErr   error
+}
+
+func New(c *Config) *Probe {
+
+	return &Probe{Config: c}
+}
+
+//记录一次结果，并丢弃 window 之外的结果
+func (p *Probe) Record(at time.Time, err error) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.results = append(p.results, result{at: at, ok: err == nil})
+	p.lastCheck = at
+	p.lastErr = err
+	p.expire(at)
+}
+
+func (p *Probe) expire(now time.Time) {
+
+	i := 0
+	for i < len(p.results) && now.Sub(p.results[i].at) > p.Config.Window {
+		i++
+	}
+	p.results = p.results[i:]
+}
+
+//window 内的统计，没有结果时不健康
+func (p *Probe) Status() Status {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.expire(time.Now())
+	s := Status{
+		Name:      p.Config.Name,
+		Type:      p.Config.Type,
+		Target:    p.Config.Target,
+		Samples:   len(p.results),
+		LastCheck: p.lastCheck,
+	}
+	if p.lastErr != nil {
+		s.LastError = p.lastErr.Error()
+	}
+	for _, r := range p.results {
+		if r.ok {
+			s.Passed++
+		}
+	}
+	if s.Samples > 0 {
+		s.Ratio = float64(s.Passed) / float64(s.Samples)
+		s.Healthy = s.Ratio >= p.Config.Threshold
+	}
+	return s
+}
+
+//定时执行探测，onFail 在探测失败时调用
+func (p *Probe) Run(onFail func(*Probe, error)) {
+
+	timer := time.NewTimer(p.Config.Interval)
+	for {
+		select {
+		case <-timer.C:
+			cErr := Check(p.Config)
+			p.Record(time.Now(), cErr)
+			if cErr != nil && onFail != nil {
+				onFail(p, cErr)
+			}
+		}
+		timer.Reset(p.Config.Interval)
+	}
+}
+
+/*
+ *得分：window 内达到 threshold 的探测的权重之和 / 全部权重之和，范围 0 - 1
+ */
+func Score(probes []*Probe) float64 {
+
+	var total, healthy float64
+	for _, p := range probes {
+		total += p.Config.Weight
+		if p.Status().Healthy {
+			healthy += p.Config.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return healthy / total
+}
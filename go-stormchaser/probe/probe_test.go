@@ -0,0 +1,138 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+
+	c, pErr := ParseConfig("web", map[string]string{
+		"type": "HTTP", "target": "http://127.0.0.1/", "interval": "10", "window": "60",
+		"threshold": "0.5", "weight": "2", "expect": "204", "restart": "true",
+	})
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	want := Config{Name: "web", Type: TypeHTTP, Target: "http://127.0.0.1/", Interval: 10 * time.Second,
+		Timeout: 5 * time.Second, Window: 60 * time.Second, Threshold: 0.5, Weight: 2, Expect: 204, Restart: true}
+	if *c != want {
+		t.Fatalf("ParseConfig = %+v, want %+v", *c, want)
+	}
+
+	invalid := []map[string]string{
+		{"type": "udp", "target": "x"},
+		{"type": "tcp"},
+		{"type": "tcp", "target": "x", "interval": "0"},
+		{"type": "tcp", "target": "x", "interval": "600", "window": "60"},
+		{"type": "tcp", "target": "x", "threshold": "1.5"},
+		{"type": "tcp", "target": "x", "weight": "-1"},
+		{"type": "tcp", "target": "x", "expect": "ok"},
+		{"type": "process", "target": "x", "restart": "maybe"},
+	}
+	for _, values := range invalid {
+		if _, pErr := ParseConfig("bad", values); pErr == nil {
+			t.Errorf("ParseConfig(%v) accepted", values)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		config Config
+		ok     bool
+	}{
+		{Config{Type: TypeTCP, Target: server.Listener.Addr().String()}, true},
+		{Config{Type: TypeTCP, Target: closedAddr}, false},
+		{Config{Type: TypeHTTP, Target: server.URL}, true},
+		{Config{Type: TypeHTTP, Target: server.URL + "/missing"}, false},
+		{Config{Type: TypeHTTP, Target: server.URL, Expect: 204}, false},
+		{Config{Type: TypeProcess, Target: filepath.Base(os.Args[0])}, true},
+		{Config{Type: TypeProcess, Target: "no-such-process-name"}, false},
+	}
+	for _, tt := range tests {
+		tt.config.Timeout = time.Second
+		if cErr := Check(&tt.config); (cErr == nil) != tt.ok {
+			t.Errorf("Check(%s %s) = %v, want ok %v", tt.config.Type, tt.config.Target, cErr, tt.ok)
+		}
+	}
+}
+
+func TestStatusWindow(t *testing.T) {
+
+	p := New(&Config{Name: "p", Type: TypeTCP, Window: time.Hour, Threshold: 0.75, Weight: 1})
+	if s := p.Status(); s.Healthy || s.Samples != 0 {
+		t.Fatalf("probe without results: %+v", s)
+	}
+
+	now := time.Now()
+	p.Record(now.Add(-2*time.Hour), errors.New("expired"))
+	p.Record(now.Add(-3*time.Minute), nil)
+	p.Record(now.Add(-2*time.Minute), nil)
+	p.Record(now.Add(-time.Minute), nil)
+	p.Record(now, errors.New("down"))
+	s := p.Status()
+	if s.Samples != 4 || s.Passed != 3 || s.Ratio != 0.75 || !s.Healthy || s.LastError != "down" {
+		t.Fatalf("status %+v", s)
+	}
+
+	p.Record(now, errors.New("down"))
+	if s := p.Status(); s.Healthy {
+		t.Fatalf("status below threshold is healthy: %+v", s)
+	}
+}
+
+func TestScore(t *testing.T) {
+
+	healthy := New(&Config{Window: time.Hour, Threshold: 1, Weight: 3})
+	healthy.Record(time.Now(), nil)
+	failing := New(&Config{Window: time.Hour, Threshold: 1, Weight: 1})
+	failing.Record(time.Now(), errors.New("down"))
+
+	if score := Score([]*Probe{healthy, failing}); score != 0.75 {
+		t.Errorf("Score = %v, want 0.75", score)
+	}
+	if score := Score(nil); score != 0 {
+		t.Errorf("Score without probes = %v, want 0", score)
+	}
+}
+
+func TestProcessNameMatches(t *testing.T) {
+
+	tests := []struct {
+		processName string
+		match       bool
+	}{
+		{"happ", true},
+		{"HAPP", true},
+		{"/opt/happ/happ", true},
+		{`C:\happ\happ.exe`, true},
+		{"happ.EXE", true},
+		{"grep -i happ", false},
+		{"happd", false},
+		{"myhapp", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := processNameMatches(tt.processName, "happ"); got != tt.match {
+			t.Errorf("processNameMatches(%q, happ) = %v, want %v", tt.processName, got, tt.match)
+		}
+	}
+}
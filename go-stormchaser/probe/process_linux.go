@@ -0,0 +1,32 @@
+// +build linux
+
+package probe
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+//读取 /proc/<pid>/comm 检查进程是否在运行，名称不区分大小写
+func ProcessRunning(name string) (bool, error) {
+
+	dirs, gErr := filepath.Glob("/proc/[0-9]*")
+	if gErr != nil {
+		return false, gErr
+	}
+	name = strings.ToLower(name)
+	if len(name) > 15 {
+		name = name[:15] //comm 最长 15 个字符
+	}
+	for _, dir := range dirs {
+		comm, rErr := ioutil.ReadFile(dir + "/comm")
+		if rErr != nil {
+			continue //进程已退出
+		}
+		if strings.ToLower(strings.TrimSpace(string(comm))) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
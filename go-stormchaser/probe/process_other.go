@@ -0,0 +1,23 @@
+// +build !linux,!windows
+
+package probe
+
+import (
+	"os/exec"
+	"strings"
+)
+
+//没有 /proc 的系统读取 ps 列出的进程名，与 name 完全一致（不区分大小写）才算在运行
+func ProcessRunning(name string) (bool, error) {
+
+	out, eErr := exec.Command("ps", "-A", "-o", "comm=").Output()
+	if eErr != nil {
+		return false, eErr
+	}
+	for _, comm := range strings.Split(string(out), "\n") {
+		if processNameMatches(strings.TrimSpace(comm), name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,27 @@
+// +build windows
+
+package probe
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+//遍历系统进程快照，进程名（去掉 .exe）与 name 完全一致（不区分大小写）才算在运行
+func ProcessRunning(name string) (bool, error) {
+
+	snapshot, sErr := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if sErr != nil {
+		return false, sErr
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for pErr := syscall.Process32First(snapshot, &entry); pErr == nil; pErr = syscall.Process32Next(snapshot, &entry) {
+		if processNameMatches(syscall.UTF16ToString(entry.ExeFile[:]), name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -19,12 +19,6 @@ const (
 	Platform string = "linux"
 )
 
-//命令 (ping)
-func ExecPING(argsStr string) ([]byte, error) {
-
-	return ExecLinuxCommand("ping -c 4 " + argsStr)
-}
-
 //命令 (ps -aux | grep happ)
 func ExecPsAndGrep(argsStr string) ([]byte, error) {
 
@@ -37,6 +31,12 @@ func ExecStartUpHapp(argsStr, path string) ([]byte, error) {
 	return ExecLinuxCommand(path + "/" + argsStr + " " + path)
 }
 
+//命令 (结束进程)
+func ExecKillProcess(argsStr string) ([]byte, error) {
+
+	return ExecLinuxCommand("pkill -x " + argsStr)
+}
+
 //添加执行权限
 func AddExecutePermission(argsStr string) ([]byte, error) {
 
@@ -19,12 +19,6 @@ const (
 	Platform string = "windows"
 )
 
-//命令 (ping)
-func ExecPING(argsStr string) ([]byte, error) {
-
-	return ExecLinuxCommand("ping -n 4 " + argsStr)
-}
-
 //命令 (tasklist | findstr happ)
 func ExecPsAndGrep(argsStr string) ([]byte, error) {
 
@@ -37,6 +31,12 @@ func ExecStartUpHapp(argsStr, path string) ([]byte, error) {
 	return ExecLinuxCommand(path + "\\" + argsStr + ".exe " + path)
 }
 
+//命令 (结束进程)
+func ExecKillProcess(argsStr string) ([]byte, error) {
+
+	return ExecLinuxCommand("taskkill /F /IM " + argsStr + ".exe")
+}
+
 //添加执行权限
 func AddExecutePermission(argsStr string) ([]byte, error) {
 
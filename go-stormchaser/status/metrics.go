@@ -0,0 +1,87 @@
+// status project metrics.go
+package status
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	buildInfoDesc       = prometheus.NewDesc("stormchaser_build_info", "Stormchaser version, always 1.", []string{"version", "address"}, nil)
+	startTimeDesc       = prometheus.NewDesc("stormchaser_start_time_seconds", "Unix time stormchaser started.", nil, nil)
+	miningStateDesc     = prometheus.NewDesc("stormchaser_mining_state", "Retrieval miner state reported in heartbeats, 1 when working.", nil, nil)
+	scoreDesc           = prometheus.NewDesc("stormchaser_probe_score", "Weighted share of healthy probes, 0 to 1.", nil, nil)
+	probeRatioDesc      = prometheus.NewDesc("stormchaser_probe_success_ratio", "Share of successful checks in the probe window.", []string{"probe", "type"}, nil)
+	probeHealthyDesc    = prometheus.NewDesc("stormchaser_probe_healthy", "1 when the probe success ratio meets its threshold.", []string{"probe", "type"}, nil)
+	probeSamplesDesc    = prometheus.NewDesc("stormchaser_probe_samples", "Checks in the probe window.", []string{"probe", "type"}, nil)
+	probeLastCheckDesc  = prometheus.NewDesc("stormchaser_probe_last_check_timestamp_seconds", "Unix time of the last check.", []string{"probe", "type"}, nil)
+	processRunningDesc  = prometheus.NewDesc("stormchaser_process_running", "1 when a managed process is running.", []string{"process"}, nil)
+	processRestartsDesc = prometheus.NewDesc("stormchaser_process_restarts_total", "Restarts of a managed process.", []string{"process"}, nil)
+	reportsDesc         = prometheus.NewDesc("stormchaser_heartbeat_reports_total", "Heartbeat reports, by result.", []string{"result"}, nil)
+	lastReportDesc      = prometheus.NewDesc("stormchaser_heartbeat_last_report_timestamp_seconds", "Unix time of the last heartbeat report.", nil, nil)
+	diskFreeDesc        = prometheus.NewDesc("stormchaser_disk_free_bytes", "Free space of the cache directory.", nil, nil)
+	nodeStatusDesc      = prometheus.NewDesc("stormchaser_node_status", "Node status in FileStormManager: 0 registered, 1 working, 2 error, 3 ended.", nil, nil)
+	nodeDisbursedDesc   = prometheus.NewDesc("stormchaser_node_disburse_total", "Rewards disbursed to the node.", nil, nil)
+)
+
+//抓取时读取状态生成指标
+type collector struct {
+	server *Server
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+
+	for _, desc := range []*prometheus.Desc{
+		buildInfoDesc, startTimeDesc, miningStateDesc, scoreDesc,
+		probeRatioDesc, probeHealthyDesc, probeSamplesDesc, probeLastCheckDesc,
+		processRunningDesc, processRestartsDesc, reportsDesc, lastReportDesc,
+		diskFreeDesc, nodeStatusDesc, nodeDisbursedDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+
+	status := c.server.Status()
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+	boolValue := func(b bool) float64 {
+
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	gauge(buildInfoDesc, 1, status.Version, status.Address)
+	gauge(startTimeDesc, float64(status.StartTime.Unix()))
+	gauge(miningStateDesc, boolValue(status.State == "1"))
+	gauge(scoreDesc, status.Score)
+	for _, p := range status.Probes {
+		gauge(probeRatioDesc, p.Ratio, p.Name, p.Type)
+		gauge(probeHealthyDesc, boolValue(p.Healthy), p.Name, p.Type)
+		gauge(probeSamplesDesc, float64(p.Samples), p.Name, p.Type)
+		if !p.LastCheck.IsZero() {
+			gauge(probeLastCheckDesc, float64(p.LastCheck.Unix()), p.Name, p.Type)
+		}
+	}
+	for _, p := range status.Processes {
+		gauge(processRunningDesc, boolValue(p.Running), p.Name)
+		ch <- prometheus.MustNewConstMetric(processRestartsDesc, prometheus.CounterValue, float64(p.Restarts), p.Name)
+	}
+	for result, count := range status.ReportCount {
+		ch <- prometheus.MustNewConstMetric(reportsDesc, prometheus.CounterValue, float64(count), result)
+	}
+	if status.LastReport != nil {
+		gauge(lastReportDesc, float64(status.LastReport.Time.Unix()))
+	}
+	if status.DiskError == "" {
+		gauge(diskFreeDesc, float64(status.DiskFree))
+	}
+	if status.Node != nil && status.Node.Registered() {
+		gauge(nodeStatusDesc, float64(status.Node.NodeStatus))
+		ch <- prometheus.MustNewConstMetric(nodeDisbursedDesc, prometheus.CounterValue, float64(status.Node.DisburseCount))
+	}
+}
@@ -0,0 +1,100 @@
+// status project status.go
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"stormchaser/heartbeat"
+	"stormchaser/monitorHCDN"
+	"stormchaser/nodeManager"
+	"stormchaser/probe"
+	"stormchaser/publicFuncHandler"
+	"stormchaser/updater"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//版本号，发布时通过 -ldflags "-X stormchaser/status.Version=x.y.z" 设置
+var Version = "dev"
+
+//检索矿工状态
+type Status struct {
+	Version     string                     `json:"version"`
+	HappVersion string                     `json:"happVersion"` //已安装的 happ 版本
+	Address     string                     `json:"address"`
+	StartTime   time.Time                  `json:"startTime"`
+	State       string                     `json:"state"` //GetSearchMiningState
+	Score       float64                    `json:"score"`
+	Probes      []probe.Status             `json:"probes"`
+	Processes   []monitorHCDN.ProcessState `json:"processes"`
+	LastReport  *heartbeat.LastReport      `json:"lastReport"`
+	DiskFree    uint64                     `json:"diskFree"`
+	DiskPath    string                     `json:"diskPath"`
+	DiskError   string                     `json:"diskError,omitempty"`
+	Node        *nodeManager.Node          `json:"node"`
+	NodeStatus  string                     `json:"nodeStatus,omitempty"`
+	ReportCount map[string]uint64          `json:"reportCount"`
+}
+
+//本地状态接口及 Prometheus 指标
+type Server struct {
+	path      string
+	reporter  *heartbeat.Reporter
+	manager   *nodeManager.Manager
+	startTime time.Time
+}
+
+func NewServer(path string, reporter *heartbeat.Reporter, manager *nodeManager.Manager) *Server {
+
+	return &Server{path: path, reporter: reporter, manager: manager, startTime: time.Now()}
+}
+
+//当前状态
+func (s *Server) Status() *Status {
+
+	status := &Status{
+		Version:     Version,
+		HappVersion: updater.InstalledVersion(),
+		Address:     s.reporter.Address().Hex(),
+		StartTime:   s.startTime,
+		State:       monitorHCDN.GetSearchMiningState(),
+		Score:       monitorHCDN.GetScore(),
+		Probes:      monitorHCDN.GetProbeStatus(),
+		Processes:   monitorHCDN.GetProcessState(),
+		Node:        s.manager.Node(),
+	}
+	var succeeded, failed uint64
+	status.LastReport, succeeded, failed = s.reporter.LastReport()
+	status.ReportCount = map[string]uint64{"success": succeeded, "fail": failed}
+	if status.Node != nil {
+		status.NodeStatus = nodeManager.StatusName(status.Node.NodeStatus)
+	}
+
+	var dErr error
+	status.DiskFree, status.DiskPath, dErr = publicFuncHandler.DiskUsage(s.path)
+	if dErr != nil {
+		status.DiskPath = s.path
+		status.DiskError = dErr.Error()
+	}
+	return status
+}
+
+func (s *Server) statusHandler(w http.ResponseWriter, req *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status())
+}
+
+//状态接口路由：/status 和 /metrics
+func (s *Server) Handler() http.Handler {
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{server: s})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.statusHandler)
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return mux
+}
@@ -0,0 +1,87 @@
+package status
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"stormchaser/heartbeat"
+	"stormchaser/nodeManager"
+	"strings"
+	"testing"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+func newTestServer(t *testing.T) (*Server, *heartbeat.Reporter) {
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(collector.Close)
+
+	key, _ := crypto.GenerateKey()
+	reporter := heartbeat.NewReporter(key, nil, common.HexToAddress("0x1000"), collector.URL)
+	return NewServer(t.TempDir(), reporter, nodeManager.NewManager(key, nil, nil)), reporter
+}
+
+func get(t *testing.T, handler http.Handler, path string) string {
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != 200 {
+		t.Fatalf("GET %s: status %d", path, w.Code)
+	}
+	body, _ := ioutil.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestStatus(t *testing.T) {
+
+	server, reporter := newTestServer(t)
+	if rErr := reporter.Report(1, "10.0.0.1"); rErr != nil {
+		t.Fatal(rErr)
+	}
+
+	status := new(Status)
+	if jErr := json.Unmarshal([]byte(get(t, server.Handler(), "/status")), status); jErr != nil {
+		t.Fatal(jErr)
+	}
+	if status.Address != reporter.Address().Hex() || status.Version != Version {
+		t.Errorf("status address %s version %s", status.Address, status.Version)
+	}
+	if status.LastReport == nil || status.LastReport.Ip != "10.0.0.1" || status.ReportCount["success"] != 1 {
+		t.Errorf("last report %+v, count %v", status.LastReport, status.ReportCount)
+	}
+	if status.Node != nil || status.NodeStatus != "" {
+		t.Errorf("node %+v before it was read from the contract", status.Node)
+	}
+	if status.DiskError != "" || status.DiskFree == 0 {
+		t.Errorf("disk free %d, error %s", status.DiskFree, status.DiskError)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+
+	server, reporter := newTestServer(t)
+	reporter.Report(1, "10.0.0.1")
+	metrics := get(t, server.Handler(), "/metrics")
+
+	for _, want := range []string{
+		`stormchaser_build_info{address="` + reporter.Address().Hex() + `",version="` + Version + `"} 1`,
+		`stormchaser_heartbeat_reports_total{result="success"} 1`,
+		`stormchaser_heartbeat_reports_total{result="fail"} 0`,
+		`stormchaser_heartbeat_last_report_timestamp_seconds `,
+		`stormchaser_mining_state 0`,
+		`stormchaser_disk_free_bytes `,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	//未读取节点信息时不输出节点指标
+	if strings.Contains(metrics, "stormchaser_node_status") {
+		t.Error("node status exported before the node was read")
+	}
+}
@@ -0,0 +1,412 @@
+// updater project updater.go
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"stormchaser/config"
+	"stormchaser/monitorHCDN"
+	"stormchaser/netWork"
+	"stormchaser/probe"
+	"stormchaser/publicFuncHandler"
+	"stormchaser/singleLog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+const (
+	manifestName     string = "manifest.json"
+	signatureSuffix  string = ".sig"
+	stagingDirName   string = "update"
+	backupSuffix     string = ".bak"
+	maxManifestSize  int64  = 1024 * 1024
+	versionKey       string = "HappVersion"    //配置文件中已安装的版本
+	badVersionKey    string = "HappBadVersion" //启动失败已回滚的版本，不再安装
+	stopWaitTime            = time.Second * 10
+	defaultStartWait        = time.Second * 30
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid release manifest signature")
+	ErrDowngrade        = errors.New("release manifest version is older than the installed version")
+)
+
+//发布清单中的文件
+type File struct {
+	Name       string `json:"name"`
+	Url        string `json:"url,omitempty"` //相对 baseUrl 的路径，默认为 <platform>/<name>
+	Size       int64  `json:"size"`
+	Sha256     string `json:"sha256"`
+	Executable bool   `json:"executable,omitempty"`
+}
+
+/*
+ *发布清单，baseUrl/manifest.json，签名为 baseUrl/manifest.json.sig：
+ *发布账户对 keccak256(manifest.json) 的签名，十六进制 r + s + v
+ *version 为点分隔的数字（如 1.2.3，可带 v 前缀），只安装比已安装版本更高的版本，
+ *防止重放旧的签名清单降级到有漏洞的版本
+ */
+type Manifest struct {
+	Version   string            `json:"version"`
+	Process   string            `json:"process"` //需要启动的进程名，如 happ
+	Platforms map[string][]File `json:"platforms"`
+}
+
+//解析点分隔的数字版本号
+func parseVersion(version string) ([]uint64, error) {
+
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, pErr := strconv.ParseUint(part, 10, 64)
+		if pErr != nil {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+//比较版本号，a < b 返回 -1，相等返回 0，a > b 返回 1，缺少的部分视为 0（1.2 与 1.2.0 相等）
+func CompareVersions(a, b string) (int, error) {
+
+	va, aErr := parseVersion(a)
+	if aErr != nil {
+		return 0, aErr
+	}
+	vb, bErr := parseVersion(b)
+	if bErr != nil {
+		return 0, bErr
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y uint64
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x < y {
+			return -1, nil
+		}
+		if x > y {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+//验证清单签名，签名者必须是 releaseKeys 之一
+func VerifyManifest(data, sig []byte, releaseKeys []common.Address) error {
+
+	if len(sig) != 65 {
+		return ErrInvalidSignature
+	}
+	sig = append([]byte{}, sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, eErr := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if eErr != nil {
+		return ErrInvalidSignature
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	for _, key := range releaseKeys {
+		if key == signer {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+//下载并更新检索矿工管理的程序（happ）
+type Updater struct {
+	baseUrl     string
+	dir         string
+	releaseKeys []common.Address
+	startWait   time.Duration //启动后等待多久检查进程是否在运行
+
+	mutex sync.Mutex
+}
+
+func New(baseUrl, dir string, releaseKeys []common.Address) *Updater {
+
+	return &Updater{
+		baseUrl:     strings.TrimRight(baseUrl, "/"),
+		dir:         dir,
+		releaseKeys: releaseKeys,
+		startWait:   defaultStartWait,
+	}
+}
+
+func (u *Updater) get(url string) ([]byte, error) {
+
+	resp, gErr := http.Get(url)
+	if gErr != nil {
+		return nil, gErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("get %s fail: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+}
+
+//下载并验证发布清单
+func (u *Updater) FetchManifest() (*Manifest, error) {
+
+	data, gErr := u.get(u.baseUrl + "/" + manifestName)
+	if gErr != nil {
+		return nil, gErr
+	}
+	sigHex, sErr := u.get(u.baseUrl + "/" + manifestName + signatureSuffix)
+	if sErr != nil {
+		return nil, sErr
+	}
+	sig, dErr := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(sigHex)), "0x"))
+	if dErr != nil {
+		return nil, ErrInvalidSignature
+	}
+	if vErr := VerifyManifest(data, sig, u.releaseKeys); vErr != nil {
+		return nil, vErr
+	}
+
+	manifest := &Manifest{}
+	if jErr := json.Unmarshal(data, manifest); jErr != nil {
+		return nil, jErr
+	}
+	if manifest.Version == "" || manifest.Process == "" || len(manifest.Platforms[publicFuncHandler.Platform]) == 0 {
+		return nil, fmt.Errorf("manifest has no %s release", publicFuncHandler.Platform)
+	}
+	if _, pErr := parseVersion(manifest.Version); pErr != nil {
+		return nil, pErr
+	}
+	for _, file := range manifest.Platforms[publicFuncHandler.Platform] {
+		//文件只能在程序目录中
+		if file.Name == "" || filepath.Base(file.Name) != file.Name || len(file.Sha256) != 64 {
+			return nil, fmt.Errorf("invalid manifest file %q", file.Name)
+		}
+	}
+	return manifest, nil
+}
+
+//已安装的版本
+func InstalledVersion() string {
+
+	version, _ := config.GetConfig(versionKey)
+	return version
+}
+
+//断点续传下载文件到暂存目录并验证长度和 sha256
+func (u *Updater) download(staging string, file File) error {
+
+	target := filepath.Join(staging, file.Name)
+	if verify(target, file) == nil {
+		return nil
+	}
+
+	url := file.Url
+	if url == "" {
+		url = publicFuncHandler.Platform + "/" + file.Name
+	}
+	part := target + ".part"
+	size, dErr := netWork.DownloadFileResume(u.baseUrl+"/"+url, part, file.Size)
+	if dErr == netWork.ErrFileTooLarge || size > file.Size {
+		os.Remove(part)
+		return fmt.Errorf("%s is larger than %d bytes", file.Name, file.Size)
+	}
+	if dErr != nil {
+		return dErr
+	}
+	if vErr := verify(part, file); vErr != nil {
+		os.Remove(part) //内容错误，重新下载
+		return vErr
+	}
+	return os.Rename(part, target)
+}
+
+func verify(path string, file File) error {
+
+	f, oErr := os.Open(path)
+	if oErr != nil {
+		return oErr
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, cErr := io.Copy(hash, f)
+	if cErr != nil {
+		return cErr
+	}
+	if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != strings.ToLower(file.Sha256) {
+		return fmt.Errorf("%s sha256 mismatch", file.Name)
+	}
+	return nil
+}
+
+//结束进程并等待退出
+func stopProcess(name string) {
+
+	if running, _ := probe.ProcessRunning(name); !running {
+		return
+	}
+	publicFuncHandler.ExecKillProcess(name)
+	for deadline := time.Now().Add(stopWaitTime); time.Now().Before(deadline); {
+		if running, _ := probe.ProcessRunning(name); !running {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+//启动进程，startWait 后仍在运行即认为启动成功
+func (u *Updater) startProcess(name string) bool {
+
+	go publicFuncHandler.ExecStartUpHapp(name, u.dir)
+	time.Sleep(u.startWait)
+	running, _ := probe.ProcessRunning(name)
+	return running
+}
+
+/*
+ *替换程序文件：原文件改名为 .bak，暂存文件改名到程序目录（同一文件系统内的 rename 是原子的）
+ *返回已替换的文件，用于回滚
+ */
+func (u *Updater) replace(staging string, files []File) ([]File, error) {
+
+	var replaced []File
+	for _, file := range files {
+		target := filepath.Join(u.dir, file.Name)
+		if _, sErr := os.Stat(target); sErr == nil {
+			os.Remove(target + backupSuffix)
+			if rErr := os.Rename(target, target+backupSuffix); rErr != nil {
+				return replaced, rErr
+			}
+		}
+		if rErr := os.Rename(filepath.Join(staging, file.Name), target); rErr != nil {
+			os.Rename(target+backupSuffix, target)
+			return replaced, rErr
+		}
+		replaced = append(replaced, file)
+		if file.Executable {
+			if cErr := os.Chmod(target, 0755); cErr != nil {
+				return replaced, cErr
+			}
+		}
+	}
+	return replaced, nil
+}
+
+//回滚已替换的文件，没有备份的文件（首次安装）直接删除，返回是否恢复了原来的文件
+func (u *Updater) rollback(files []File) bool {
+
+	restored := false
+	for _, file := range files {
+		target := filepath.Join(u.dir, file.Name)
+		if _, sErr := os.Stat(target + backupSuffix); sErr == nil {
+			os.Remove(target)
+			os.Rename(target+backupSuffix, target)
+			restored = true
+		} else {
+			os.Remove(target)
+		}
+	}
+	return restored
+}
+
+/*
+ *检查并安装新版本：下载、验证、停止旧进程、替换、启动
+ *只安装比已安装版本更高的版本，新版本启动失败时回滚到原来的文件并重新启动，该版本记录为失败版本不再安装
+ *force 为 true 时即使版本相同也重新安装（程序文件丢失时），但仍不安装更低的版本
+ */
+func (u *Updater) Update(force bool) error {
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	manifest, fErr := u.FetchManifest()
+	if fErr != nil {
+		return fErr
+	}
+	if installed := InstalledVersion(); installed != "" {
+		order, cErr := CompareVersions(manifest.Version, installed)
+		if cErr != nil {
+			return cErr
+		}
+		if order < 0 {
+			singleLog.GetInstance().Warning("Refusing to install older release", manifest.Version, "installed", installed)
+			return ErrDowngrade
+		}
+		if !force && order == 0 {
+			return nil
+		}
+	}
+	if badVersion, _ := config.GetConfig(badVersionKey); !force && manifest.Version == badVersion {
+		return nil
+	}
+	singleLog.GetInstance().Info("Installing", manifest.Process, manifest.Version)
+
+	files := manifest.Platforms[publicFuncHandler.Platform]
+	staging := filepath.Join(u.dir, stagingDirName, manifest.Version)
+	if mErr := os.MkdirAll(staging, 0755); mErr != nil {
+		return mErr
+	}
+	for _, file := range files {
+		if dErr := u.download(staging, file); dErr != nil {
+			return dErr
+		}
+	}
+
+	//替换期间不由探测重新启动进程
+	monitorHCDN.SetRestartPaused(true)
+	defer monitorHCDN.SetRestartPaused(false)
+
+	stopProcess(manifest.Process)
+	replaced, rErr := u.replace(staging, files)
+	if rErr == nil && u.startProcess(manifest.Process) {
+		for _, file := range files {
+			os.Remove(filepath.Join(u.dir, file.Name) + backupSuffix)
+		}
+		os.RemoveAll(filepath.Join(u.dir, stagingDirName))
+		singleLog.GetInstance().Info("Installed", manifest.Process, manifest.Version)
+		return config.SaveConfig(versionKey, manifest.Version)
+	}
+	if rErr == nil {
+		rErr = fmt.Errorf("%s %s did not start", manifest.Process, manifest.Version)
+	}
+
+	singleLog.GetInstance().Error("Install fail, rolling back", manifest.Version, rErr)
+	stopProcess(manifest.Process)
+	if u.rollback(replaced) {
+		go publicFuncHandler.ExecStartUpHapp(manifest.Process, u.dir)
+	}
+	config.SaveConfig(badVersionKey, manifest.Version)
+	return rErr
+}
+
+//定时检查新版本
+func (u *Updater) Run(interval time.Duration) {
+
+	timer := time.NewTimer(interval)
+	for {
+		select {
+		case <-timer.C:
+			if uErr := u.Update(false); uErr != nil {
+				singleLog.GetInstance().Error("Update fail", uErr)
+			}
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,299 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"stormchaser/config"
+	"stormchaser/netWork"
+	"stormchaser/publicFuncHandler"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filestorm/go-filestorm/common"
+	"github.com/filestorm/go-filestorm/crypto"
+)
+
+func signManifest(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+
+	sig, sErr := crypto.Sign(crypto.Keccak256(data), key)
+	if sErr != nil {
+		t.Fatal(sErr)
+	}
+	sig[64] += 27
+	return sig
+}
+
+func testFile(name string, content []byte) File {
+
+	hash := sha256.Sum256(content)
+	return File{Name: name, Size: int64(len(content)), Sha256: hex.EncodeToString(hash[:]), Executable: true}
+}
+
+//发布服务：签名的 manifest.json 及平台目录下的文件，记录文件请求
+type testRelease struct {
+	key      *ecdsa.PrivateKey
+	manifest []byte
+	files    map[string][]byte
+
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func newTestRelease(t *testing.T, key *ecdsa.PrivateKey, manifest *Manifest, files map[string][]byte) (*testRelease, *httptest.Server) {
+
+	data, _ := json.Marshal(manifest)
+	release := &testRelease{key: key, manifest: data, files: files}
+	sig := hex.EncodeToString(signManifest(t, key, data))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/" + manifestName:
+			w.Write(release.manifest)
+			return
+		case "/" + manifestName + signatureSuffix:
+			w.Write([]byte(sig))
+			return
+		}
+		release.mutex.Lock()
+		release.requests = append(release.requests, req)
+		release.mutex.Unlock()
+		content, ok := files[filepath.Base(req.URL.Path)]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		http.ServeContent(w, req, req.URL.Path, time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return release, server
+}
+
+func TestVerifyManifest(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	keys := []common.Address{crypto.PubkeyToAddress(other.PublicKey), crypto.PubkeyToAddress(key.PublicKey)}
+	data := []byte(`{"version":"1.0.0"}`)
+	sig := signManifest(t, key, data)
+
+	if vErr := VerifyManifest(data, sig, keys); vErr != nil {
+		t.Fatal(vErr)
+	}
+	//v 为 0/1 的签名同样有效
+	raw := append([]byte{}, sig...)
+	raw[64] -= 27
+	if vErr := VerifyManifest(data, raw, keys); vErr != nil {
+		t.Errorf("signature with v 0/1: %v", vErr)
+	}
+	if vErr := VerifyManifest([]byte(`{"version":"0.9.0"}`), sig, keys); vErr != ErrInvalidSignature {
+		t.Errorf("tampered manifest: %v", vErr)
+	}
+	if vErr := VerifyManifest(data, signManifest(t, other, data), keys[1:]); vErr != ErrInvalidSignature {
+		t.Errorf("unknown signer: %v", vErr)
+	}
+	if vErr := VerifyManifest(data, sig[:64], keys); vErr != ErrInvalidSignature {
+		t.Errorf("short signature: %v", vErr)
+	}
+	if vErr := VerifyManifest(data, sig, nil); vErr != ErrInvalidSignature {
+		t.Errorf("no release keys: %v", vErr)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.10.0", "1.9.9", 1},
+		{"1.9.9", "1.10.0", -1},
+		{"2", "1.99.99", 1},
+	}
+	for _, tt := range tests {
+		if got, cErr := CompareVersions(tt.a, tt.b); cErr != nil || got != tt.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, %v, want %d", tt.a, tt.b, got, cErr, tt.want)
+		}
+	}
+	for _, invalid := range []string{"", "1..0", "1.0-beta", "latest"} {
+		if _, cErr := CompareVersions(invalid, "1.0.0"); cErr == nil {
+			t.Errorf("CompareVersions(%q) accepted", invalid)
+		}
+	}
+}
+
+func TestFetchManifest(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	keys := []common.Address{crypto.PubkeyToAddress(key.PublicKey)}
+	content := []byte("happ")
+	manifest := &Manifest{Version: "1.0.0", Process: "happ",
+		Platforms: map[string][]File{publicFuncHandler.Platform: {testFile("happ", content)}}}
+	release, server := newTestRelease(t, key, manifest, nil)
+
+	fetched, fErr := New(server.URL, t.TempDir(), keys).FetchManifest()
+	if fErr != nil {
+		t.Fatal(fErr)
+	}
+	if fetched.Version != "1.0.0" || len(fetched.Platforms[publicFuncHandler.Platform]) != 1 {
+		t.Fatalf("fetched %+v", fetched)
+	}
+
+	//清单内容与签名不符
+	release.manifest = bytes.Replace(release.manifest, []byte("1.0.0"), []byte("0.1.0"), 1)
+	if _, fErr := New(server.URL, t.TempDir(), keys).FetchManifest(); fErr != ErrInvalidSignature {
+		t.Errorf("tampered manifest: %v", fErr)
+	}
+
+	invalid := []*Manifest{
+		{Version: "1.0.0", Process: "happ", Platforms: map[string][]File{publicFuncHandler.Platform: {testFile("../happ", content)}}},
+		{Version: "latest", Process: "happ", Platforms: map[string][]File{publicFuncHandler.Platform: {testFile("happ", content)}}},
+		{Version: "1.0.0", Process: "happ", Platforms: map[string][]File{"other": {testFile("happ", content)}}},
+	}
+	for _, m := range invalid {
+		_, server := newTestRelease(t, key, m, nil)
+		if _, fErr := New(server.URL, t.TempDir(), keys).FetchManifest(); fErr == nil {
+			t.Errorf("manifest %+v accepted", m)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+
+	key, _ := crypto.GenerateKey()
+	content := bytes.Repeat([]byte("happ release "), 1000)
+	release, server := newTestRelease(t, key, &Manifest{}, map[string][]byte{"happ": content})
+	u := New(server.URL, t.TempDir(), nil)
+	staging := t.TempDir()
+	file := testFile("happ", content)
+
+	//从已下载的部分继续
+	part := filepath.Join(staging, "happ.part")
+	ioutil.WriteFile(part, content[:5000], 0644)
+	if dErr := u.download(staging, file); dErr != nil {
+		t.Fatal(dErr)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(staging, "happ")); !bytes.Equal(got, content) {
+		t.Fatal("resumed download content mismatch")
+	}
+	if len(release.requests) != 1 || release.requests[0].Header.Get("Range") != "bytes=5000-" {
+		t.Fatalf("download did not resume, requests %d", len(release.requests))
+	}
+
+	//已下载并验证的文件不再下载
+	if dErr := u.download(staging, file); dErr != nil || len(release.requests) != 1 {
+		t.Fatalf("verified file downloaded again: %v, requests %d", dErr, len(release.requests))
+	}
+
+	//内容与 sha256 不符时删除，下次重新下载
+	bad := testFile("happ", []byte("other content"))
+	bad.Size = file.Size
+	badStaging := t.TempDir()
+	if dErr := u.download(badStaging, bad); dErr == nil {
+		t.Fatal("file with sha256 mismatch accepted")
+	}
+	if _, sErr := os.Stat(filepath.Join(badStaging, "happ.part")); !os.IsNotExist(sErr) {
+		t.Error("mismatching download kept")
+	}
+
+	//比清单中更大的文件，超过大小后停止下载
+	small := file
+	small.Size = 10
+	smallStaging := t.TempDir()
+	if dErr := u.download(smallStaging, small); dErr == nil {
+		t.Error("file larger than the manifest size accepted")
+	}
+	if _, sErr := os.Stat(filepath.Join(smallStaging, "happ.part")); !os.IsNotExist(sErr) {
+		t.Error("oversized download kept")
+	}
+	written, dErr := netWork.DownloadFileResume(server.URL+"/happ", filepath.Join(t.TempDir(), "happ"), 10)
+	if dErr != netWork.ErrFileTooLarge || written != 11 {
+		t.Errorf("oversized download: %d bytes written, %v", written, dErr)
+	}
+}
+
+func TestReplaceRollback(t *testing.T) {
+
+	dir := t.TempDir()
+	staging := t.TempDir()
+	u := New("", dir, nil)
+	files := []File{{Name: "happ", Executable: true}, {Name: "happ.conf"}}
+	ioutil.WriteFile(filepath.Join(dir, "happ"), []byte("old"), 0755)
+	ioutil.WriteFile(filepath.Join(staging, "happ"), []byte("new"), 0644)
+	ioutil.WriteFile(filepath.Join(staging, "happ.conf"), []byte("new conf"), 0644)
+
+	replaced, rErr := u.replace(staging, files)
+	if rErr != nil || len(replaced) != 2 {
+		t.Fatalf("replace: %v, %d replaced", rErr, len(replaced))
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(dir, "happ")); string(got) != "new" {
+		t.Fatalf("replaced content %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "happ")); info.Mode()&0100 == 0 {
+		t.Error("executable not executable after replace")
+	}
+
+	if !u.rollback(replaced) {
+		t.Fatal("rollback did not restore the previous file")
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(dir, "happ")); string(got) != "old" {
+		t.Fatalf("rolled back content %q", got)
+	}
+	//首次安装的文件没有备份，回滚时删除
+	if _, sErr := os.Stat(filepath.Join(dir, "happ.conf")); !os.IsNotExist(sErr) {
+		t.Error("file without backup kept after rollback")
+	}
+	if _, sErr := os.Stat(filepath.Join(dir, "happ"+backupSuffix)); !os.IsNotExist(sErr) {
+		t.Error("backup kept after rollback")
+	}
+}
+
+func TestUpdateRefusesDowngrade(t *testing.T) {
+
+	installed := InstalledVersion()
+	defer config.SaveConfig(versionKey, installed)
+	if sErr := config.SaveConfig(versionKey, "2.0.0"); sErr != nil {
+		t.Fatal(sErr)
+	}
+
+	key, _ := crypto.GenerateKey()
+	keys := []common.Address{crypto.PubkeyToAddress(key.PublicKey)}
+	content := []byte("happ")
+	release := func(version string) (*testRelease, *Updater) {
+
+		manifest := &Manifest{Version: version, Process: "happ",
+			Platforms: map[string][]File{publicFuncHandler.Platform: {testFile("happ", content)}}}
+		r, server := newTestRelease(t, key, manifest, map[string][]byte{"happ": content})
+		return r, New(server.URL, t.TempDir(), keys)
+	}
+
+	//重放旧版本的签名清单
+	old, u := release("1.9.0")
+	if uErr := u.Update(false); uErr != ErrDowngrade {
+		t.Errorf("Update(false) with an older release: %v", uErr)
+	}
+	if uErr := u.Update(true); uErr != ErrDowngrade {
+		t.Errorf("Update(true) with an older release: %v", uErr)
+	}
+	if len(old.requests) != 0 {
+		t.Errorf("older release downloaded, requests %d", len(old.requests))
+	}
+
+	//已安装的版本不再安装
+	same, u := release("2.0")
+	if uErr := u.Update(false); uErr != nil || len(same.requests) != 0 {
+		t.Errorf("Update(false) with the installed release: %v, requests %d", uErr, len(same.requests))
+	}
+	if InstalledVersion() != "2.0.0" {
+		t.Errorf("installed version changed to %s", InstalledVersion())
+	}
+}
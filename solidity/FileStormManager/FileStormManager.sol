@@ -42,6 +42,19 @@ contract FileStormManager is ReentrancyGuard {
 
     mapping(address => Node) public nodeMapping;
 
+    uint256 public HEARTBEAT_MAX_SKEW = 300; // seconds a heartbeat timestamp may be ahead of the block.
+
+    struct Heartbeat {
+      uint256 blockNumber;
+      uint256 timestamp;
+      uint8 state; // 1 when the node is working.
+      string ip;
+    }
+
+    mapping(address => Heartbeat) public heartbeats;
+
+    event HeartbeatReported(address indexed nodeAddress, uint8 state, string ip, uint256 timestamp);
+
     constructor() public payable {
       owner = msg.sender;
       admins[msg.sender] = 1;
@@ -88,6 +101,7 @@ contract FileStormManager is ReentrancyGuard {
       
       require(disburseMapping[registerType]>0, "Wrong registration type.");
       require(stakingCount <= staking_limit, "staking total reached limit.");
+      require(admins[msg.sender] == 1 || msg.value == staking_amount * stakingCount, "Wrong Staking Amount.");
 
       nodeMapping[nodeAddress].nodeId = nodeId;
       nodeMapping[nodeAddress].nodeAddress = nodeAddress;
@@ -109,6 +123,41 @@ contract FileStormManager is ReentrancyGuard {
     }
 
 
+    // message signed by the node account, the same as attestationHash in go-stormchaser/heartbeat.
+    function attestationHash(address nodeAddress, uint8 state, string memory ip, uint256 timestamp) public view returns (bytes32) {
+      bytes32 hash = keccak256(abi.encodePacked(address(this), nodeAddress, state, ip, timestamp));
+      return keccak256(abi.encodePacked("\x19Ethereum Signed Message:\n32", hash));
+    }
+
+    // heartbeat sent by the node account itself.
+    function heartbeat(uint8 state, string memory ip, uint256 timestamp) public {
+      recordHeartbeat(msg.sender, state, ip, timestamp);
+    }
+
+    // heartbeat signed by the node account and submitted by a collector.
+    function submitHeartbeat(address nodeAddress, uint8 state, string memory ip, uint256 timestamp, uint8 v, bytes32 r, bytes32 s) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Submit Heartbeats.");
+      require(ecrecover(attestationHash(nodeAddress, state, ip, timestamp), v, r, s) == nodeAddress, "Invalid Heartbeat Signature.");
+      recordHeartbeat(nodeAddress, state, ip, timestamp);
+    }
+
+    function recordHeartbeat(address nodeAddress, uint8 state, string memory ip, uint256 timestamp) internal {
+      require(nodeAddress != address(0) && nodeMapping[nodeAddress].nodeAddress == nodeAddress, "Node Not Registered.");
+      require(timestamp > heartbeats[nodeAddress].timestamp, "Heartbeat Already Reported.");
+      require(timestamp <= now + HEARTBEAT_MAX_SKEW, "Heartbeat Timestamp In The Future.");
+
+      heartbeats[nodeAddress].blockNumber = block.number;
+      heartbeats[nodeAddress].timestamp = timestamp;
+      heartbeats[nodeAddress].state = state;
+      heartbeats[nodeAddress].ip = ip;
+      emit HeartbeatReported(nodeAddress, state, ip, timestamp);
+    }
+
+    // whether the node reported working within the last epoch.
+    function isAlive(address nodeAddress) public view returns (bool) {
+      return heartbeats[nodeAddress].state == 1 && heartbeats[nodeAddress].blockNumber + DISBURSE_EPOCH >= block.number;
+    }
+
     function disburse(address nodeAddress) nonReentrant public {
       require(admins[msg.sender] == 1 || msg.sender == nodeAddress || msg.sender == nodeMapping[nodeAddress].beneficiary
       , "Only Admins or Node Owners Can Request Disbursement.");
@@ -116,7 +165,9 @@ contract FileStormManager is ReentrancyGuard {
       require(nodeMapping[nodeAddress].nextRewardBlock <= block.number
       , "Next Disbursement block not reached.");
 
-      if (nodeMapping[nodeAddress].nodeStatus == uint(NodeStatus.working)) {
+      // retrieval miners prove they are working with heartbeats.
+      bool alive = nodeMapping[nodeAddress].registerType != uint(RegisterType.retrieval) || isAlive(nodeAddress);
+      if (nodeMapping[nodeAddress].nodeStatus == uint(NodeStatus.working) && alive) {
         nodeMapping[nodeAddress].beneficiary.call{value: nodeMapping[nodeAddress].disburseAmount};
       }
       nodeMapping[nodeAddress].nextRewardBlock = block.number + DISBURSE_EPOCH;
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+
+pragma solidity ^0.6.0;
+/**
+ * @title FileStormStorage.sol
+ * This is the smart contract to manage paid storage deals of files stored by filestorm nodes.
+ */
+
+import "ReentrancyGuard.sol";
+
+contract FileStormStorage is ReentrancyGuard {
+
+    address internal owner;
+    mapping(address => uint) public admins;
+    // storage node operators, allowed to register the files uploaded to their node
+    mapping(address => uint) public operators;
+
+    uint256 public period = 30 days; // a deal is paid period by period.
+    uint256 public price = 1 * 10 ** 17; // price of one period.
+
+    struct Deal {
+      address owner;
+      uint256 paidUntil;
+      uint256 price;
+      uint256 period;
+      bool autoRenew;
+    }
+
+    // file hash => deal
+    mapping(string => Deal) public deals;
+
+    // prepaid balance used to renew deals automatically
+    mapping(address => uint256) public balances;
+
+    // paid deals not disbursed to storage providers yet
+    uint256 public revenue;
+
+    event DealPaid(string fileHash, address owner, uint256 paidUntil);
+    event FileRegistered(string fileHash, address owner, uint256 size);
+
+    constructor() public {
+      owner = msg.sender;
+      admins[msg.sender] = 1;
+    }
+
+    function addAdmin(address admin) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Add Another Admin.");
+      admins[admin] = 1;
+    }
+
+    function removeAdmin(address admin) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Remove Another Admin.");
+      require(admin != msg.sender, "Admins Cannot Remove Self.");
+      admins[admin] = 0;
+    }
+
+    function addOperator(address operator) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Add Operators.");
+      operators[operator] = 1;
+    }
+
+    function removeOperator(address operator) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Remove Operators.");
+      operators[operator] = 0;
+    }
+
+    function updatePrice(uint256 amount, uint256 periodSeconds) public {
+      require(admins[msg.sender] == 1, "Only Admins Can Change Price.");
+      require(periodSeconds > 0, "Period Must Not Be Empty.");
+      price = amount;
+      period = periodSeconds;
+    }
+
+    function deposit() public payable {
+      balances[msg.sender] += msg.value;
+    }
+
+    function withdraw(uint256 amount) nonReentrant public {
+      require(balances[msg.sender] >= amount, "Not Enough Balance.");
+      balances[msg.sender] -= amount;
+      (bool success, ) = msg.sender.call{value: amount}("");
+      require(success, "Withdraw Failed.");
+    }
+
+    // pay the given number of periods of a new or existing deal.
+    function pay(string memory fileHash, uint256 periods, bool autoRenew) public payable {
+      Deal storage deal = deals[fileHash];
+      require(deal.owner == address(0) || deal.owner == msg.sender, "Only Deal Owner Can Pay.");
+      require(periods > 0, "Pay At Least One Period.");
+
+      if (deal.owner == address(0)) {
+        deal.owner = msg.sender;
+        deal.price = price;
+        deal.period = period;
+      }
+      require(msg.value == deal.price * periods, "Wrong Payment Amount.");
+
+      deal.autoRenew = autoRenew;
+      revenue += msg.value;
+      extend(deal, periods);
+      emit DealPaid(fileHash, deal.owner, deal.paidUntil);
+    }
+
+    // register a file uploaded to a storage node, the deal is unpaid until its owner pays it.
+    function register(string memory fileHash, address fileOwner, uint256 size) public {
+      require(operators[msg.sender] == 1 || admins[msg.sender] == 1, "Only Operators Can Register Files.");
+      require(fileOwner != address(0), "Owner Must Not Be Empty.");
+      Deal storage deal = deals[fileHash];
+      require(deal.owner == address(0), "File Already Registered.");
+
+      deal.owner = fileOwner;
+      deal.price = price;
+      deal.period = period;
+      deal.paidUntil = now;
+      emit FileRegistered(fileHash, fileOwner, size);
+    }
+
+    function setAutoRenew(string memory fileHash, bool autoRenew) public {
+      require(deals[fileHash].owner == msg.sender, "Only Deal Owner Can Change Auto Renew.");
+      deals[fileHash].autoRenew = autoRenew;
+    }
+
+    // renew one period from the prepaid balance of the deal owner, anyone can call it.
+    function renew(string memory fileHash) public {
+      Deal storage deal = deals[fileHash];
+      require(deal.owner != address(0), "Deal Not Found.");
+      require(deal.autoRenew, "Auto Renew Is Disabled.");
+      require(balances[deal.owner] >= deal.price, "Not Enough Prepaid Balance.");
+
+      balances[deal.owner] -= deal.price;
+      revenue += deal.price;
+      extend(deal, 1);
+      emit DealPaid(fileHash, deal.owner, deal.paidUntil);
+    }
+
+    function disburse(address payable beneficiary, uint256 amount) nonReentrant public {
+      require(admins[msg.sender] == 1, "Only Admins Can Disburse Revenue.");
+      require(revenue >= amount, "Not Enough Revenue.");
+      revenue -= amount;
+      (bool success, ) = beneficiary.call{value: amount}("");
+      require(success, "Disburse Failed.");
+    }
+
+    function extend(Deal storage deal, uint256 periods) internal {
+      if (deal.paidUntil < now) {
+        deal.paidUntil = now;
+      }
+      deal.paidUntil += deal.period * periods;
+    }
+
+}